@@ -0,0 +1,77 @@
+package main
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/bench"
+	"EverythingSuckz/fsb/internal/utils"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var benchCmd = &cobra.Command{
+	Use:   "bench",
+	Short: "Benchmark the streaming path against a synthetic Telegram upstream.",
+	Long: `Simulate concurrent ranged readers of a file served by a synthetic upstream that answers like Telegram,
+and report the throughput and allocations of the streamer. Nothing connects to Telegram, so it can run in CI
+to catch performance regressions, or on a server to size it for the expected number of viewers.`,
+	Example:            "fsb bench --readers 100 --range 32MB\nfsb bench --readers 500 --client-rate 256KB --latency 100ms --json",
+	Args:               cobra.NoArgs,
+	DisableSuggestions: false,
+	Run:                runBench,
+}
+
+func init() {
+	benchCmd.Flags().IntP("readers", "n", 50, "The number of concurrent readers.")
+	benchCmd.Flags().String("file-size", "2GB", "The size of the synthetic file.")
+	benchCmd.Flags().String("range", "16MB", "The bytes every reader requests, from a random offset.")
+	benchCmd.Flags().Duration("latency", 0, "The delay of every chunk request to the upstream, e.g. 80ms.")
+	benchCmd.Flags().String("client-rate", "0", "How fast every reader consumes the stream per second, e.g. 256KB. 0 reads as fast as possible.")
+	benchCmd.Flags().Int("read-ahead", 2, "STREAM_READ_AHEAD of the streamer.")
+	benchCmd.Flags().String("slow-client-rate", "256KB", "SLOW_CLIENT_RATE of the streamer.")
+	benchCmd.Flags().Bool("json", false, "Print the result as JSON.")
+}
+
+func runBench(cmd *cobra.Command, args []string) {
+	utils.InitLogger(false)
+	log := utils.Logger.Named("Bench")
+	readers, _ := cmd.Flags().GetInt("readers")
+	latency, _ := cmd.Flags().GetDuration("latency")
+	readAhead, _ := cmd.Flags().GetInt("read-ahead")
+	asJSON, _ := cmd.Flags().GetBool("json")
+	sizes := make(map[string]int64)
+	for _, name := range []string{"file-size", "range", "client-rate", "slow-client-rate"} {
+		value, _ := cmd.Flags().GetString(name)
+		size, err := config.ParseByteSize(value)
+		if err != nil {
+			log.Fatal("Invalid size", zap.String("flag", name), zap.Error(err))
+		}
+		sizes[name] = size
+	}
+	config.ValueOf.StreamReadAhead = readAhead
+	if err := config.ValueOf.SlowClientRate.Decode(fmt.Sprint(sizes["slow-client-rate"])); err != nil {
+		log.Fatal("Invalid size", zap.String("flag", "slow-client-rate"), zap.Error(err))
+	}
+	result, err := bench.Run(context.Background(), bench.Options{
+		Readers:    readers,
+		FileSize:   sizes["file-size"],
+		RangeSize:  sizes["range"],
+		Latency:    latency,
+		ClientRate: sizes["client-rate"],
+	})
+	if err != nil {
+		log.Fatal("Benchmark failed", zap.Error(err))
+	}
+	if asJSON {
+		json.NewEncoder(os.Stdout).Encode(result)
+	} else {
+		fmt.Println(result)
+	}
+	if result.Failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/types"
+	"EverythingSuckz/fsb/internal/utils"
+	"EverythingSuckz/fsb/internal/webauth"
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var dashboardAdminCmd = &cobra.Command{
+	Use:   "dashboard-admin <username>",
+	Short: "Create a local admin account of the web dashboard or change its password.",
+	Long: `Store an admin account that signs in to the dashboard at /dashboard with a password instead of Telegram,
+so that users can be managed and links revoked while Telegram is unreachable. The password is read from
+standard input and stored as a bcrypt hash in the database. With --hash, only the hash is printed, to add
+the account to DASHBOARD_ADMINS as username:hash instead.`,
+	Example:            "fsb dashboard-admin alice < password.txt",
+	Args:               cobra.ExactArgs(1),
+	DisableSuggestions: false,
+	Run:                dashboardAdmin,
+}
+
+func init() {
+	dashboardAdminCmd.Flags().Bool("hash", false, "Print the bcrypt hash of the password for DASHBOARD_ADMINS without storing the account.")
+	dashboardAdminCmd.Flags().Bool("delete", false, "Remove the account from the database.")
+}
+
+func dashboardAdmin(cmd *cobra.Command, args []string) {
+	utils.InitLogger(false)
+	log := utils.Logger.Named("Dashboard")
+	username := strings.TrimSpace(args[0])
+	if username == "" || strings.Contains(username, ":") {
+		log.Fatal("Usernames can't be empty or contain a colon")
+	}
+	hashOnly, _ := cmd.Flags().GetBool("hash")
+	remove, _ := cmd.Flags().GetBool("delete")
+	var hash string
+	if !remove {
+		fmt.Fprintf(os.Stderr, "Password of %s: ", username)
+		password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		password = strings.TrimRight(password, "\r\n")
+		if password == "" {
+			log.Fatal("Failed to read the password", zap.Error(err))
+		}
+		hash, err = webauth.HashPassword(password)
+		if err != nil {
+			log.Fatal("Failed to hash the password", zap.Error(err))
+		}
+		if hashOnly {
+			fmt.Printf("%s:%s\n", username, hash)
+			return
+		}
+	}
+	config.Load(log, cmd)
+	if err := database.InitDatabase(log); err != nil {
+		log.Fatal("Failed to initialize database", zap.Error(err))
+	}
+	dashboardRepository := database.GetDashboardRepository()
+	if remove {
+		removed, err := dashboardRepository.Delete(username)
+		if err != nil {
+			log.Fatal("Failed to remove the account", zap.Error(err))
+		}
+		if !removed {
+			log.Fatal("There's no account " + username + " in the database")
+		}
+		log.Sugar().Infof("Removed the dashboard account %s, it's signed out", username)
+		return
+	}
+	err := dashboardRepository.Save(&types.DashboardAccount{Username: username, PasswordHash: hash, CreatedAt: time.Now()})
+	if err != nil {
+		log.Fatal("Failed to store the account", zap.Error(err))
+	}
+	log.Sugar().Infof("Stored the dashboard account %s, sign in at /dashboard", username)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/importer"
+	"EverythingSuckz/fsb/internal/utils"
+	"time"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var importCmd = &cobra.Command{
+	Use:   "import <file>",
+	Short: "Import users from another bot's export or database.",
+	Long: `Import users and their authorization from a CSV or JSON export, or from another bot's SQLite database.
+Columns like id, user_id, username, first_name, authorized, premium_until, expiry and banned are recognized.
+Users who already exist keep their data, the import only adds missing names, authorizations and suspensions.`,
+	Example:            "fsb import users.csv\nfsb import bot.db --table subscribers --dry-run",
+	Args:               cobra.ExactArgs(1),
+	DisableSuggestions: false,
+	Run:                importUsers,
+}
+
+func init() {
+	importCmd.Flags().StringP("format", "f", "", "The format of the file: csv, json or sqlite. Guessed from the extension if empty.")
+	importCmd.Flags().StringP("table", "t", "users", "The table to read from SQLite databases.")
+	importCmd.Flags().Bool("dry-run", false, "Read the file and report what would be imported without storing anything.")
+}
+
+func importUsers(cmd *cobra.Command, args []string) {
+	utils.InitLogger(false)
+	log := utils.Logger.Named("Import")
+	format, _ := cmd.Flags().GetString("format")
+	table, _ := cmd.Flags().GetString("table")
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+	records, err := importer.Read(args[0], format, table)
+	if err != nil {
+		log.Fatal("Failed to read the file", zap.Error(err))
+	}
+	if !dryRun {
+		if err := database.InitDatabase(log); err != nil {
+			log.Fatal("Failed to initialize database", zap.Error(err))
+		}
+	}
+	userRepository := database.GetUserRepository()
+	now := time.Now()
+	var created, updated, authorized, skipped int
+	for i, record := range records {
+		user, err := importer.User(record, now)
+		if err != nil {
+			log.Warn("Skipping record", zap.Int("record", i+1), zap.Error(err))
+			skipped++
+			continue
+		}
+		if user.Authorized {
+			authorized++
+		}
+		if dryRun {
+			created++
+			continue
+		}
+		isNew, err := userRepository.Import(user)
+		if err != nil {
+			log.Fatal("Failed to store user", zap.Int64("userID", user.ID), zap.Error(err))
+		}
+		if isNew {
+			created++
+		} else {
+			updated++
+		}
+	}
+	if dryRun {
+		log.Sugar().Infof("Dry run: %d users would be imported (%d authorized), %d records skipped", created, authorized, skipped)
+		return
+	}
+	log.Sugar().Infof("Imported %d new users and updated %d (%d authorized), %d records skipped", created, updated, authorized, skipped)
+}
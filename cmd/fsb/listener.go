@@ -0,0 +1,137 @@
+package main
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/utils"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/quic-go/quic-go/http3"
+	"go.uber.org/zap"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// systemd passes activated sockets starting at this file descriptor
+const systemdFirstFD = 3
+
+// listen returns the listener the server accepts connections on: a socket passed by
+// systemd, a unix domain socket or the TCP port, in that order of preference.
+func listen(log *zap.Logger) (net.Listener, error) {
+	switch {
+	case config.ValueOf.SystemdSocket:
+		log.Info("Using the socket passed by systemd")
+		return systemdListener()
+	case config.ValueOf.UnixSocket != "":
+		log.Info("Listening on unix socket", zap.String("path", config.ValueOf.UnixSocket))
+		return unixListener(config.ValueOf.UnixSocket, config.ValueOf.UnixSocketMode)
+	default:
+		log.Info("Listening on TCP", zap.Int("port", config.ValueOf.Port))
+		return net.Listen("tcp", fmt.Sprintf(":%d", config.ValueOf.Port))
+	}
+}
+
+// systemdListener returns the first socket passed by systemd socket activation
+func systemdListener() (net.Listener, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, errors.New("no socket was passed by systemd (LISTEN_PID is not set to this process)")
+	}
+	fds, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || fds < 1 {
+		return nil, errors.New("no socket was passed by systemd (LISTEN_FDS is not set)")
+	}
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+	file := os.NewFile(uintptr(systemdFirstFD), "systemd-socket")
+	defer file.Close()
+	return net.FileListener(file)
+}
+
+// unixListener listens on a unix domain socket at path with the given octal permissions,
+// replacing a stale socket file left by a previous run
+func unixListener(path string, mode string) (net.Listener, error) {
+	perm, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid UNIX_SOCKET_MODE %q: %w", mode, err)
+	}
+	if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		os.Remove(path)
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, os.FileMode(perm)); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+// tlsEnabled reports whether the server speaks TLS, TLS_CERT_FILE and TLS_KEY_FILE are set
+func tlsEnabled() bool {
+	return config.ValueOf.TLSCertFile != "" && config.ValueOf.TLSKeyFile != ""
+}
+
+// serve serves the handler on the listener. HTTP/2 is negotiated on TLS connections
+// when TLS_CERT_FILE and TLS_KEY_FILE are set, and can be enabled on plain connections
+// (h2c) with HTTP2_CLEARTEXT for reverse proxies that speak HTTP/2 to the backend.
+// With HTTP3, HTTP/3 is served next to TLS on the UDP port of PORT.
+func serve(log *zap.Logger, listener net.Listener, handler http.Handler) error {
+	h2 := &http2.Server{}
+	server := &http.Server{Handler: handler}
+	if tlsEnabled() {
+		if err := http2.ConfigureServer(server, h2); err != nil {
+			return err
+		}
+		if config.ValueOf.HTTP3 {
+			server.Handler = serveHTTP3(log, handler)
+		}
+		log.Info("Serving HTTPS with HTTP/2")
+		return server.ServeTLS(listener, config.ValueOf.TLSCertFile, config.ValueOf.TLSKeyFile)
+	}
+	if config.ValueOf.HTTP2Cleartext {
+		log.Info("Serving HTTP with cleartext HTTP/2 (h2c)")
+		server.Handler = h2c.NewHandler(handler, h2)
+	}
+	return server.Serve(listener)
+}
+
+// serveHTTP3 serves the handler over QUIC in the background and returns the handler of the
+// TCP listener, which advertises HTTP/3 to the clients with the Alt-Svc header
+func serveHTTP3(log *zap.Logger, handler http.Handler) http.Handler {
+	h3 := &http3.Server{Addr: fmt.Sprintf(":%d", config.ValueOf.Port), Handler: handler}
+	go func() {
+		log.Info("Serving HTTP/3", zap.Int("port", config.ValueOf.Port))
+		if err := h3.ListenAndServeTLS(config.ValueOf.TLSCertFile, config.ValueOf.TLSKeyFile); err != nil {
+			log.Error("HTTP/3 listener stopped", zap.Error(err))
+		}
+	}()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// fails until the QUIC listener is up, the next responses advertise it
+		h3.SetQuicHeaders(w.Header())
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// serveInternal serves the router on a random loopback port for the requests the bot
+// makes to itself, like ffmpeg reading streams, when the main listener isn't plain TCP
+func serveInternal(log *zap.Logger, handler http.Handler) error {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	utils.SetInternalAddress(listener.Addr().String())
+	go func() {
+		if err := http.Serve(listener, handler); err != nil {
+			log.Error("Internal listener stopped", zap.Error(err))
+		}
+	}()
+	return nil
+}
@@ -2,13 +2,14 @@ package main
 
 import (
 	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/version"
 	"fmt"
 	"os"
 
 	"github.com/spf13/cobra"
 )
 
-const versionString = "3.1.0"
+const versionString = version.Version
 
 var rootCmd = &cobra.Command{
 	Use:               "fsb [command]",
@@ -26,6 +27,11 @@ func init() {
 	config.ValueOf.SetFlagsFromConfig(runCmd)
 	rootCmd.AddCommand(runCmd)
 	rootCmd.AddCommand(sessionCmd)
+	rootCmd.AddCommand(importCmd)
+	rootCmd.AddCommand(benchCmd)
+	rootCmd.AddCommand(serviceCmd)
+	rootCmd.AddCommand(rekeyCmd)
+	rootCmd.AddCommand(dashboardAdminCmd)
 	rootCmd.SetVersionTemplate(fmt.Sprintf(`Telegram File Stream Bot version %s`, versionString))
 }
 
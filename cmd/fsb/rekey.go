@@ -0,0 +1,43 @@
+package main
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/crypt"
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/utils"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var rekeyCmd = &cobra.Command{
+	Use:   "rekey",
+	Short: "Encrypt the sensitive columns of the database with the current encryption key.",
+	Long: `Re-encrypt the invite codes of tenants and the notes about users with the first key of ENCRYPTION_KEYS.
+To rotate the key, put the new key first and keep the old one after it, run this command, then remove the
+old key. Values stored before ENCRYPTION_KEYS was set are encrypted too. Stop the bot before running it.`,
+	Example:            "fsb rekey",
+	Args:               cobra.NoArgs,
+	DisableSuggestions: false,
+	Run:                rekey,
+}
+
+func rekey(cmd *cobra.Command, args []string) {
+	utils.InitLogger(false)
+	log := utils.Logger.Named("Rekey")
+	config.Load(log, cmd)
+	if err := crypt.Load(log); err != nil {
+		log.Fatal("Failed to load encryption keys", zap.Error(err))
+	}
+	if !crypt.Enabled() {
+		log.Fatal("Set ENCRYPTION_KEYS first")
+	}
+	if err := database.InitDatabase(log); err != nil {
+		log.Fatal("Failed to initialize database", zap.Error(err))
+	}
+	changed, err := database.Rekey()
+	if err != nil {
+		log.Fatal("Failed to rekey the database, nothing was changed", zap.Error(err))
+	}
+	log.Sugar().Infof("Encrypted %d values with the current key, the other keys can be removed from ENCRYPTION_KEYS", changed)
+}
@@ -2,15 +2,38 @@ package main
 
 import (
 	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/abuse"
+	"EverythingSuckz/fsb/internal/activity"
+	"EverythingSuckz/fsb/internal/alerts"
+	"EverythingSuckz/fsb/internal/billing"
 	"EverythingSuckz/fsb/internal/bot"
 	"EverythingSuckz/fsb/internal/cache"
+	"EverythingSuckz/fsb/internal/category"
+	"EverythingSuckz/fsb/internal/cooldown"
+	"EverythingSuckz/fsb/internal/crash"
+	"EverythingSuckz/fsb/internal/crypt"
 	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/downloads"
+	"EverythingSuckz/fsb/internal/enrich"
+	"EverythingSuckz/fsb/internal/features"
+	"EverythingSuckz/fsb/internal/jobs"
+	"EverythingSuckz/fsb/internal/media"
+	"EverythingSuckz/fsb/internal/oidc"
+	"EverythingSuckz/fsb/internal/onboarding"
 	"EverythingSuckz/fsb/internal/routes"
+	"EverythingSuckz/fsb/internal/security"
+	"EverythingSuckz/fsb/internal/service"
+	"EverythingSuckz/fsb/internal/settings"
+	"EverythingSuckz/fsb/internal/tenant"
+	"EverythingSuckz/fsb/internal/tracing"
 	"EverythingSuckz/fsb/internal/types"
 	"EverythingSuckz/fsb/internal/utils"
-	"fmt"
+	"EverythingSuckz/fsb/internal/version"
+	"EverythingSuckz/fsb/internal/watchdog"
+	"EverythingSuckz/fsb/internal/web"
+	"io"
 	"net/http"
-	"time"
+	"runtime/debug"
 
 	"github.com/spf13/cobra"
 
@@ -25,29 +48,66 @@ var runCmd = &cobra.Command{
 	Run:                runApp,
 }
 
-var startTime time.Time = time.Now()
-
 func runApp(cmd *cobra.Command, args []string) {
 	utils.InitLogger(config.ValueOf.Dev)
 	log := utils.Logger
 	mainLogger := log.Named("Main")
+	serviceDir, _ := cmd.Flags().GetString("service-dir")
+	service.Attach(log, serviceDir)
 	mainLogger.Info("Starting server")
 	config.Load(log, cmd)
+	notify := func(userID int64, message string) error {
+		return bot.Notify(userID, message, nil)
+	}
+	crash.Init(log, notify)
+	tracing.Load(log)
+	billing.Load(log)
+	onboarding.Load(log)
+	if err := web.Load(log); err != nil {
+		log.Panic("Failed to load web assets", zap.Error(err))
+	}
 	router := getRouter(log)
+	routePaths := make([]string, 0, len(router.Routes()))
+	for _, route := range router.Routes() {
+		routePaths = append(routePaths, route.Path)
+	}
+	tenant.ReserveRoutes(routePaths)
 
 	mainBot, err := bot.StartClient(log)
 	if err != nil {
 		log.Panic("Failed to start main bot", zap.Error(err))
 	}
 	
+	if err := crypt.Load(log); err != nil {
+		log.Panic("Failed to load encryption keys", zap.Error(err))
+	}
 	// Initialize database
 	err = database.InitDatabase(log)
 	if err != nil {
 		log.Panic("Failed to initialize database", zap.Error(err))
 	}
+	if err := settings.Load(log); err != nil {
+		log.Panic("Failed to load settings", zap.Error(err))
+	}
+	if err := features.Load(log); err != nil {
+		log.Panic("Failed to load feature flags", zap.Error(err))
+	}
+	if err := tenant.Load(log); err != nil {
+		log.Panic("Failed to load tenants", zap.Error(err))
+	}
+	cooldown.Load(log)
+	if err := category.Load(log); err != nil {
+		log.Panic("Failed to load category rules", zap.Error(err))
+	}
+	enrich.Start(log)
+	if err := oidc.Load(log); err != nil {
+		log.Panic("Failed to load single sign-on", zap.Error(err))
+	}
+	handler := tenant.Handler(router)
 	
 	cache.InitCache(log)
 	cache.InitStatsCache(log)
+	abuse.InitDetector(log)
 	workers, err := bot.StartWorkers(log)
 	if err != nil {
 		log.Panic("Failed to start workers", zap.Error(err))
@@ -55,10 +115,34 @@ func runApp(cmd *cobra.Command, args []string) {
 	}
 	workers.AddDefaultClient(mainBot, mainBot.Self)
 	bot.StartUserBot(log)
-	mainLogger.Info("Server started", zap.Int("port", config.ValueOf.Port))
+	bot.StartReplyUpdater(log)
+	bot.StartAuthorizationExpiry(log)
+	bot.StartUserPurge(log)
+	bot.StartProcessedPurge(log)
+	bot.StartUpdateCheck(log)
+	bot.StartScheduler(log)
+	bot.SetMenuButton(log)
+	activity.Start(log)
+	alerts.Start(log, notify)
+	media.StartJanitor(log)
+	downloads.Start(log, bot.Live)
+	watchdog.Start(log)
+	jobs.Start(log)
+	bot.StartUpdateRecovery(log)
+	listener, err := listen(mainLogger)
+	if err != nil {
+		log.Panic("Failed to listen", zap.Error(err))
+	}
+	// ffmpeg and the other internal clients read streams over plain HTTP on loopback
+	if config.ValueOf.SystemdSocket || config.ValueOf.UnixSocket != "" || tlsEnabled() {
+		if err := serveInternal(mainLogger, handler); err != nil {
+			log.Panic("Failed to start the internal listener", zap.Error(err))
+		}
+	}
+	mainLogger.Info("Server started", zap.String("address", listener.Addr().String()))
 	mainLogger.Info("File Stream Bot", zap.String("version", versionString))
-	mainLogger.Sugar().Infof("Server is running at %s", config.ValueOf.Host)
-	err = router.Run(fmt.Sprintf(":%d", config.ValueOf.Port))
+	mainLogger.Sugar().Infof("Server is running at %s", utils.PublicURL("/"))
+	err = serve(mainLogger, listener, handler)
 	if err != nil {
 		mainLogger.Sugar().Fatalln(err)
 	}
@@ -70,16 +154,24 @@ func getRouter(log *zap.Logger) *gin.Engine {
 	} else {
 		gin.SetMode(gin.ReleaseMode)
 	}
-	router := gin.Default()
+	router := gin.New()
+	// the client IP keys the lockouts, it's only taken from the headers of known proxies
+	if err := router.SetTrustedProxies(config.ValueOf.TrustedProxies); err != nil {
+		log.Fatal("Invalid TRUSTED_PROXIES", zap.Error(err))
+	}
+	router.Use(gin.Logger(), gin.CustomRecoveryWithWriter(io.Discard, func(c *gin.Context, err any) {
+		crash.Report("http", err, string(debug.Stack()))
+		c.AbortWithStatus(http.StatusInternalServerError)
+	}), tracing.Middleware(), security.Middleware())
 	router.Use(gin.ErrorLogger())
-	router.GET("/", func(ctx *gin.Context) {
+	router.Group(config.ValueOf.BasePath).GET("/", func(ctx *gin.Context) {
 		ctx.JSON(http.StatusOK, types.RootResponse{
 			Message: "Server is running.",
 			Ok:      true,
-			Uptime:  utils.TimeFormat(uint64(time.Since(startTime).Seconds())),
+			Uptime:  utils.TimeFormat(uint64(version.Uptime().Seconds())),
 			Version: versionString,
 		})
 	})
-	routes.Load(log, router)
+	routes.Load(log, router, bot.Live)
 	return router
 }
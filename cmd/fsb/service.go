@@ -0,0 +1,89 @@
+package main
+
+import (
+	"EverythingSuckz/fsb/internal/service"
+	"fmt"
+	"os"
+	"runtime"
+
+	"github.com/spf13/cobra"
+)
+
+var serviceCmd = &cobra.Command{
+	Use:   "service",
+	Short: "Run the bot as a background service of the operating system.",
+	Long: `Install the bot as a systemd unit on Linux, a launchd daemon on macOS or a Windows service, which starts
+on boot and restarts it when it crashes. The service runs "fsb run" in the current directory, so run the
+install command from the directory with fsb.env, as root or as an administrator.`,
+	Example:            "fsb service install\nfsb service start\nfsb service stop\nfsb service uninstall",
+	DisableSuggestions: false,
+}
+
+var serviceInstallCmd = &cobra.Command{
+	Use:   "install [-- run flags]",
+	Short: "Install the service, it starts on boot.",
+	Run: func(cmd *cobra.Command, args []string) {
+		user, _ := cmd.Flags().GetString("user")
+		s, err := service.Current(args, user)
+		if err != nil {
+			exitWithError(err)
+		}
+		installed, err := service.Install(s)
+		if err != nil {
+			exitWithError(err)
+		}
+		fmt.Printf("Installed %s, start it with \"fsb service start\".\n", installed)
+	},
+}
+
+var serviceUninstallCmd = &cobra.Command{
+	Use:   "uninstall",
+	Short: "Stop the service and remove it.",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := service.Uninstall(); err != nil {
+			exitWithError(err)
+		}
+		fmt.Println("Uninstalled the service.")
+	},
+}
+
+var serviceStartCmd = &cobra.Command{
+	Use:   "start",
+	Short: "Start the service.",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := service.Start(); err != nil {
+			exitWithError(err)
+		}
+		fmt.Println("Started the service.")
+	},
+}
+
+var serviceStopCmd = &cobra.Command{
+	Use:   "stop",
+	Short: "Stop the service.",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := service.Stop(); err != nil {
+			exitWithError(err)
+		}
+		fmt.Println("Stopped the service.")
+	},
+}
+
+func init() {
+	defaultUser := ""
+	if runtime.GOOS == "linux" {
+		defaultUser = os.Getenv("SUDO_USER")
+	}
+	serviceInstallCmd.Flags().String("user", defaultUser, "The account the service runs as, the user who ran sudo by default on Linux.")
+	serviceCmd.AddCommand(serviceInstallCmd, serviceUninstallCmd, serviceStartCmd, serviceStopCmd)
+	runCmd.Flags().String("service-dir", "", "The directory the service was installed from.")
+	runCmd.Flags().MarkHidden("service-dir")
+}
+
+func exitWithError(err error) {
+	fmt.Println(err)
+	os.Exit(1)
+}
@@ -11,6 +11,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"text/template"
 
 	"github.com/joho/godotenv"
 	"github.com/kelseyhightower/envconfig"
@@ -37,21 +38,172 @@ func (au *allowedUsers) Decode(value string) error {
 	return nil
 }
 
+// byteSize is a size in bytes that can be given with a unit suffix (e.g. 500MB, 2GB)
+type byteSize int64
+
+var byteSizeUnits = []struct {
+	suffix     string
+	multiplier int64
+}{
+	{"TB", 1 << 40},
+	{"GB", 1 << 30},
+	{"MB", 1 << 20},
+	{"KB", 1 << 10},
+	{"B", 1},
+}
+
+func (bs *byteSize) Decode(value string) error {
+	if strings.TrimSpace(value) == "" {
+		return nil
+	}
+	size, err := ParseByteSize(value)
+	if err != nil {
+		return err
+	}
+	*bs = byteSize(size)
+	return nil
+}
+
+// ParseByteSize parses a size in bytes with an optional unit suffix (e.g. 500MB, 2GB)
+func ParseByteSize(value string) (int64, error) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	multiplier := int64(1)
+	for _, unit := range byteSizeUnits {
+		if strings.HasSuffix(value, unit.suffix) {
+			value = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix))
+			multiplier = unit.multiplier
+			break
+		}
+	}
+	size, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, err
+	}
+	if size < 0 {
+		return 0, errors.New("size can't be negative")
+	}
+	return int64(size * float64(multiplier)), nil
+}
+
 type config struct {
-	APIID            int64    `envconfig:"API_ID" required:"true"`
-	APIHash          string   `envconfig:"API_HASH" required:"true"`
-	BotToken         string   `envconfig:"BOT_TOKEN" required:"true"`
-	LogChannelID     int64    `envconfig:"LOG_CHANNEL" required:"true"`
-	Host             string   `envconfig:"HOST" required:"true"`
-	Port             int      `envconfig:"PORT" required:"true"`
-	AllowedUsers     []int64  `envconfig:"ALLOWED_USERS"`
-	ForceSubChannel  string   `envconfig:"FORCE_SUB_CHANNEL"`
-	Dev              bool     `envconfig:"DEV" default:"false"`
-	HashLength       int      `envconfig:"HASH_LENGTH" default:"6"`
-	UseSessionFile   bool     `envconfig:"USE_SESSION_FILE" default:"true"`
-	UserSession      string   `envconfig:"USER_SESSION"`
-	UsePublicIP      bool     `envconfig:"USE_PUBLIC_IP" default:"false"`
-	MultiTokens      []string
+	APIID              int64    `envconfig:"API_ID" required:"true"`
+	APIHash            string   `envconfig:"API_HASH" required:"true"`
+	BotToken           string   `envconfig:"BOT_TOKEN" required:"true"`
+	LogChannelID       int64    `envconfig:"LOG_CHANNEL" required:"true"`
+	Host               string   `envconfig:"HOST" required:"true"`
+	Port               int      `envconfig:"PORT" required:"true"`
+	BasePath           string   `envconfig:"BASE_PATH"`
+	Preset             string   `envconfig:"PRESET"`
+	UnixSocket         string   `envconfig:"UNIX_SOCKET"`
+	UnixSocketMode     string   `envconfig:"UNIX_SOCKET_MODE" default:"0660"`
+	SystemdSocket      bool     `envconfig:"SYSTEMD_SOCKET" default:"false"`
+	TLSCertFile        string   `envconfig:"TLS_CERT_FILE"`
+	TLSKeyFile         string   `envconfig:"TLS_KEY_FILE"`
+	HTTP2Cleartext     bool     `envconfig:"HTTP2_CLEARTEXT" default:"false"`
+	HTTP3              bool     `envconfig:"HTTP3" default:"false"`
+	WebOverrideDir     string   `envconfig:"WEB_OVERRIDE_DIR"`
+	CORSOrigins        []string `envconfig:"CORS_ORIGINS"`
+	TrustedProxies     []string `envconfig:"TRUSTED_PROXIES"`
+	FrameAncestors     []string `envconfig:"FRAME_ANCESTORS"`
+	EmbedOrigins       []string `envconfig:"EMBED_ORIGINS"`
+	OpenInPlayers      []string `envconfig:"OPEN_IN_PLAYERS" default:"vlc,mpv,iina,android"`
+	FileNameTemplate   string   `envconfig:"FILE_NAME_TEMPLATE"`
+	CategoryRules      string   `envconfig:"CATEGORY_RULES"`
+	TMDBAPIKey         string   `envconfig:"TMDB_API_KEY"`
+	MusicBrainz        bool     `envconfig:"MUSICBRAINZ_ENABLED" default:"false"`
+	OIDCIssuer         string   `envconfig:"OIDC_ISSUER_URL"`
+	OIDCClientID       string   `envconfig:"OIDC_CLIENT_ID"`
+	OIDCSecret         string   `envconfig:"OIDC_CLIENT_SECRET"`
+	DashboardAdmins    []string `envconfig:"DASHBOARD_ADMINS"`
+	SaveDir            string   `envconfig:"SAVE_DIR"`
+	SaveNameTemplate   string   `envconfig:"SAVE_NAME_TEMPLATE" default:"{kind}/{name}"`
+	SaveMinFree        byteSize `envconfig:"SAVE_MIN_FREE" default:"1GB"`
+	SaveWorkers        int      `envconfig:"SAVE_WORKERS" default:"2"`
+	DiskMinFree        byteSize `envconfig:"DISK_MIN_FREE" default:"2GB"`
+	JobRetentionDays   int      `envconfig:"JOB_RETENTION_DAYS" default:"7"`
+	ContentPolicy      string   `envconfig:"CONTENT_SECURITY_POLICY"`
+	ReferrerPolicy     string   `envconfig:"REFERRER_POLICY" default:"same-origin"`
+	AppName            string   `envconfig:"APP_NAME" default:"File Stream Bot"`
+	AppShortName       string   `envconfig:"APP_SHORT_NAME" default:"FSB"`
+	AppThemeColor      string   `envconfig:"APP_THEME_COLOR" default:"#1e88e5"`
+	AppBackgroundColor string   `envconfig:"APP_BACKGROUND_COLOR" default:"#111111"`
+	MenuButton         string   `envconfig:"MENU_BUTTON"`
+	AllowedUsers       []int64  `envconfig:"ALLOWED_USERS"`
+	PrivateMode        bool     `envconfig:"PRIVATE_MODE" default:"false"`
+	PublicMode         bool     `envconfig:"PUBLIC_MODE" default:"false"`
+	PublicIndex        bool     `envconfig:"PUBLIC_INDEX" default:"false"`
+	MirrorMode         bool     `envconfig:"MIRROR_MODE" default:"false"`
+	GroupMode          bool     `envconfig:"GROUP_MODE" default:"false"`
+	ReactionActions    []string `envconfig:"REACTION_ACTIONS" default:"👍:bump,👎:revoke"`
+	SpeechToTextURL    string   `envconfig:"SPEECH_TO_TEXT_URL"`
+	SpeechToTextKey    string   `envconfig:"SPEECH_TO_TEXT_KEY"`
+	SpeechToTextModel  string   `envconfig:"SPEECH_TO_TEXT_MODEL" default:"whisper-1"`
+	Admins             []int64  `envconfig:"ADMINS"`
+	AdminChatID        int64    `envconfig:"ADMIN_CHAT"`
+	InviteMonthlyLimit int      `envconfig:"INVITE_MONTHLY_LIMIT" default:"3"`
+	UserRetentionDays  int      `envconfig:"USER_RETENTION_DAYS" default:"30"`
+	ProcessedRetention int      `envconfig:"PROCESSED_RETENTION_HOURS" default:"48"`
+	RecoveryHours      int      `envconfig:"UPDATE_RECOVERY_HOURS" default:"24"`
+	OnboardingSteps    []string `envconfig:"ONBOARDING_STEPS" default:"file,player,settings"`
+	ForceSubChannel    string   `envconfig:"FORCE_SUB_CHANNEL"`
+	Dev                bool     `envconfig:"DEV" default:"false"`
+	HashLength         int      `envconfig:"HASH_LENGTH" default:"6"`
+	UseSessionFile     bool     `envconfig:"USE_SESSION_FILE" default:"true"`
+	UserSession        string   `envconfig:"USER_SESSION"`
+	PremiumSession     bool     `envconfig:"PREMIUM_SESSION" default:"false"`
+	PremiumMinSize     byteSize `envconfig:"PREMIUM_MIN_SIZE" default:"2GB"`
+	UsePublicIP        bool     `envconfig:"USE_PUBLIC_IP" default:"false"`
+	ReplyStatsInterval int      `envconfig:"REPLY_STATS_INTERVAL" default:"0"`
+	LinkRateLimit      int      `envconfig:"LINK_RATE_LIMIT" default:"0"`
+	LinkTTLHours       int      `envconfig:"LINK_TTL_HOURS" default:"0"`
+	CommandCooldowns   []string `envconfig:"COMMAND_COOLDOWNS"`
+	MessagesPerSecond  int      `envconfig:"MESSAGES_PER_SECOND" default:"25"`
+	ExportAPIToken     string   `envconfig:"EXPORT_API_TOKEN"`
+	FlagLinksPerHour   int      `envconfig:"FLAG_LINKS_PER_HOUR" default:"50"`
+	FlagDistinctIPs    int      `envconfig:"FLAG_DISTINCT_IPS" default:"20"`
+	FlagRateLimitHits  int      `envconfig:"FLAG_RATE_LIMIT_HITS" default:"5"`
+	FlagCountries      int      `envconfig:"FLAG_DISTINCT_COUNTRIES" default:"5"`
+	CountryHeader      string   `envconfig:"COUNTRY_HEADER"`
+	LockoutAttempts    int      `envconfig:"LOCKOUT_ATTEMPTS" default:"10"`
+	MaxFileSize        byteSize `envconfig:"MAX_FILE_SIZE"`
+	StreamReadAhead    int      `envconfig:"STREAM_READ_AHEAD" default:"2"`
+	SlowClientRate     byteSize `envconfig:"SLOW_CLIENT_RATE" default:"256KB"`
+	MemoryBudget       byteSize `envconfig:"MEMORY_BUDGET"`
+	AllowedMimeTypes   []string `envconfig:"ALLOWED_MIME_TYPES"`
+	BlockedExtensions  []string `envconfig:"BLOCKED_EXTENSIONS"`
+	PolicyAdminBypass  bool     `envconfig:"POLICY_ADMIN_BYPASS" default:"true"`
+	ClamAVAddress      string   `envconfig:"CLAMAV_ADDRESS"`
+	ClamAVMaxSize      byteSize `envconfig:"CLAMAV_MAX_SIZE" default:"25MB"`
+	FFmpegEnabled      bool     `envconfig:"FFMPEG_ENABLED" default:"false"`
+	FFmpegPath         string   `envconfig:"FFMPEG_PATH" default:"ffmpeg"`
+	FFprobePath        string   `envconfig:"FFPROBE_PATH" default:"ffprobe"`
+	FFmpegMaxJobs      int      `envconfig:"FFMPEG_MAX_JOBS" default:"2"`
+	TranscodeMaxJobs   int      `envconfig:"TRANSCODE_MAX_JOBS" default:"1"`
+	TranscodeMaxHeight int      `envconfig:"TRANSCODE_MAX_HEIGHT" default:"1080"`
+	PreviewDuration    int      `envconfig:"PREVIEW_DURATION" default:"15"`
+	ClipMaxDuration    int      `envconfig:"CLIP_MAX_DURATION" default:"600"`
+	SubscriptionPlans  []string `envconfig:"SUBSCRIPTION_PLANS"`
+	PaymentCurrency    string   `envconfig:"PAYMENT_CURRENCY" default:"XTR"`
+	PaymentProvider    string   `envconfig:"PAYMENT_PROVIDER_TOKEN"`
+	Features           []string `envconfig:"FEATURES"`
+	UpdateCheck        bool     `envconfig:"UPDATE_CHECK" default:"false"`
+	UpdateFeed         string   `envconfig:"UPDATE_FEED" default:"https://api.github.com/repos/EverythingSuckz/TG-FileStreamBot/releases/latest"`
+	OTLPEndpoint       string   `envconfig:"OTLP_ENDPOINT"`
+	OTLPHeaders        []string `envconfig:"OTLP_HEADERS"`
+	TraceSampleRate    float64  `envconfig:"TRACE_SAMPLE_RATE" default:"1"`
+	SentryDSN          string   `envconfig:"SENTRY_DSN"`
+	DebugToken         string   `envconfig:"DEBUG_TOKEN"`
+	SLOStreamErrors    float64  `envconfig:"SLO_STREAM_ERROR_RATE" default:"0"`
+	SLOTelegramErrors  float64  `envconfig:"SLO_TELEGRAM_ERROR_RATE" default:"0"`
+	SLOFirstByteMs     int      `envconfig:"SLO_FIRST_BYTE_P95_MS" default:"0"`
+	SLOWindowMinutes   int      `envconfig:"SLO_WINDOW_MINUTES" default:"5"`
+	SLOMinSamples      int      `envconfig:"SLO_MIN_SAMPLES" default:"20"`
+	SLOCooldownMinutes int      `envconfig:"SLO_ALERT_COOLDOWN_MINUTES" default:"30"`
+	DBMaxOpenConns     int      `envconfig:"DB_MAX_OPEN_CONNS" default:"10"`
+	DBMaxIdleConns     int      `envconfig:"DB_MAX_IDLE_CONNS" default:"5"`
+	DBBusyTimeout      int      `envconfig:"DB_BUSY_TIMEOUT" default:"5000"`
+	EncryptionKeys     []string `envconfig:"ENCRYPTION_KEYS"`
+	MultiTokens        []string
 }
 
 var botTokenRegex = regexp.MustCompile(`MULTI\_TOKEN\d+=(.*)`)
@@ -140,6 +292,12 @@ func (c *config) loadConfigFromArgs(log *zap.Logger, cmd *cobra.Command) {
 func (c *config) setupEnvVars(log *zap.Logger, cmd *cobra.Command) {
 	c.loadFromEnvFile(log)
 	c.loadConfigFromArgs(log, cmd)
+	if err := loadSecrets(log); err != nil {
+		log.Fatal("Error while loading secrets", zap.Error(err))
+	}
+	if err := applyPreset(log); err != nil {
+		log.Fatal("Error while applying the preset", zap.Error(err))
+	}
 	err := envconfig.Process("", c)
 	if err != nil {
 		log.Fatal("Error while parsing env variables", zap.Error(err))
@@ -176,6 +334,11 @@ func Load(log *zap.Logger, cmd *cobra.Command) {
 	defer log.Info("Loaded config")
 	ValueOf.setupEnvVars(log, cmd)
 	ValueOf.LogChannelID = int64(stripInt(log, int(ValueOf.LogChannelID)))
+	ValueOf.Host = strings.TrimSuffix(ValueOf.Host, "/")
+	ValueOf.BasePath = normalizeBasePath(ValueOf.BasePath)
+	if ValueOf.AdminChatID != 0 {
+		ValueOf.AdminChatID = int64(stripInt(log, int(ValueOf.AdminChatID)))
+	}
 	if ValueOf.HashLength == 0 {
 		log.Sugar().Info("HASH_LENGTH can't be 0, defaulting to 6")
 		ValueOf.HashLength = 6
@@ -188,6 +351,24 @@ func Load(log *zap.Logger, cmd *cobra.Command) {
 		log.Sugar().Info("HASH_LENGTH can't be less than 5, defaulting to 6")
 		ValueOf.HashLength = 6
 	}
+	if _, err := template.New("name").Parse(ValueOf.FileNameTemplate); err != nil {
+		log.Fatal("Invalid FILE_NAME_TEMPLATE", zap.Error(err))
+	}
+	for _, entry := range ValueOf.DashboardAdmins {
+		if username, hash, ok := strings.Cut(strings.TrimSpace(entry), ":"); !ok || username == "" || !strings.HasPrefix(hash, "$2") {
+			log.Fatal("Invalid DASHBOARD_ADMINS entry, use username:bcrypt-hash from `fsb dashboard-admin <username> --hash`")
+		}
+	}
+	Runtime.reset(ValueOf)
+}
+
+// normalizeBasePath turns values like "webbridge/" into "/webbridge", and "/" into ""
+func normalizeBasePath(basePath string) string {
+	basePath = strings.Trim(basePath, "/")
+	if basePath == "" {
+		return ""
+	}
+	return "/" + basePath
 }
 
 func getIP(public bool) (string, error) {
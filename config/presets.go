@@ -0,0 +1,74 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// presets bundle the defaults of common deployments, selected with PRESET. Variables set
+// in the environment or in fsb.env take precedence over the preset.
+var presets = map[string]map[string]string{
+	// a few trusted users on a home server: no limits, links work forever and streams buffer more
+	"home-lab": {
+		"PRIVATE_MODE":        "true",
+		"LINK_RATE_LIMIT":     "0",
+		"LINK_TTL_HOURS":      "0",
+		"STREAM_READ_AHEAD":   "4",
+		"USER_RETENTION_DAYS": "0",
+	},
+	// many untrusted viewers: links play for anyone but expire, and the users generating them are limited
+	"public-community": {
+		"PUBLIC_MODE":         "true",
+		"LINK_RATE_LIMIT":     "5",
+		"LINK_TTL_HOURS":      "168",
+		"COMMAND_COOLDOWNS":   "preview:3/1h,frames:10/1h",
+		"FLAG_LINKS_PER_HOUR": "30",
+		"FLAG_DISTINCT_IPS":   "50",
+		"STREAM_READ_AHEAD":   "1",
+		"MEMORY_BUDGET":       "512MB",
+	},
+	// paying users: only authorized or subscribed users, private links that last a month
+	"paid-service": {
+		"PRIVATE_MODE":      "true",
+		"LINK_RATE_LIMIT":   "20",
+		"LINK_TTL_HOURS":    "720",
+		"COMMAND_COOLDOWNS": "preview:10/1h,frames:30/1h",
+		"STREAM_READ_AHEAD": "3",
+	},
+}
+
+// applyPreset sets the variables of the preset in PRESET that aren't set yet
+func applyPreset(log *zap.Logger) error {
+	name := strings.TrimSpace(os.Getenv("PRESET"))
+	if name == "" {
+		return nil
+	}
+	preset, ok := presets[name]
+	if !ok {
+		return fmt.Errorf("unknown PRESET %q, use one of %s", name, strings.Join(presetNames(), ", "))
+	}
+	var overridden []string
+	for key, value := range preset {
+		if _, set := os.LookupEnv(key); set {
+			overridden = append(overridden, key)
+			continue
+		}
+		os.Setenv(key, value)
+	}
+	sort.Strings(overridden)
+	log.Info("Using preset", zap.String("preset", name), zap.Strings("overridden", overridden))
+	return nil
+}
+
+func presetNames() []string {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
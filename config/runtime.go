@@ -0,0 +1,137 @@
+package config
+
+import (
+	"sync/atomic"
+)
+
+// Runtime holds the values that runtime settings, changed with /setup or /set, override while
+// the bot serves requests. They start as the values of ValueOf, which keeps the static config,
+// and are only read and written through their accessors so that changes never race with the
+// streams and handlers reading them.
+var Runtime = &runtimeConfig{}
+
+type runtimeConfig struct {
+	host             atomic.Pointer[string]
+	logChannelID     atomic.Int64
+	privateMode      atomic.Bool
+	linkRateLimit    atomic.Int64
+	linkTTLHours     atomic.Int64
+	maxFileSize      atomic.Int64
+	flagLinksPerHour atomic.Int64
+	// admins is replaced as a whole, the slices it held are never modified
+	admins atomic.Pointer[[]int64]
+}
+
+// reset sets the runtime values to the ones of the static config
+func (r *runtimeConfig) reset(c *config) {
+	r.SetHost(c.Host)
+	r.SetLogChannelID(c.LogChannelID)
+	r.SetPrivateMode(c.PrivateMode)
+	r.SetLinkRateLimit(c.LinkRateLimit)
+	r.SetLinkTTLHours(c.LinkTTLHours)
+	r.SetMaxFileSize(int64(c.MaxFileSize))
+	r.SetFlagLinksPerHour(c.FlagLinksPerHour)
+	admins := append([]int64{}, c.Admins...)
+	r.admins.Store(&admins)
+}
+
+// Host returns the public URL of the bot, HOST
+func (r *runtimeConfig) Host() string {
+	if host := r.host.Load(); host != nil {
+		return *host
+	}
+	return ""
+}
+
+// SetHost changes the public URL of the bot
+func (r *runtimeConfig) SetHost(host string) {
+	r.host.Store(&host)
+}
+
+// LogChannelID returns the channel the files of the default tenant are stored in, LOG_CHANNEL
+func (r *runtimeConfig) LogChannelID() int64 {
+	return r.logChannelID.Load()
+}
+
+// SetLogChannelID changes the channel the files of the default tenant are stored in
+func (r *runtimeConfig) SetLogChannelID(id int64) {
+	r.logChannelID.Store(id)
+}
+
+// PrivateMode reports whether only allowed users can use the bot, PRIVATE_MODE
+func (r *runtimeConfig) PrivateMode() bool {
+	return r.privateMode.Load()
+}
+
+// SetPrivateMode changes whether only allowed users can use the bot
+func (r *runtimeConfig) SetPrivateMode(private bool) {
+	r.privateMode.Store(private)
+}
+
+// LinkRateLimit returns the links a user can generate per minute, LINK_RATE_LIMIT
+func (r *runtimeConfig) LinkRateLimit() int {
+	return int(r.linkRateLimit.Load())
+}
+
+// SetLinkRateLimit changes the links a user can generate per minute
+func (r *runtimeConfig) SetLinkRateLimit(limit int) {
+	r.linkRateLimit.Store(int64(limit))
+}
+
+// LinkTTLHours returns the hours a link works after it was generated, LINK_TTL_HOURS
+func (r *runtimeConfig) LinkTTLHours() int {
+	return int(r.linkTTLHours.Load())
+}
+
+// SetLinkTTLHours changes the hours a link works after it was generated
+func (r *runtimeConfig) SetLinkTTLHours(hours int) {
+	r.linkTTLHours.Store(int64(hours))
+}
+
+// MaxFileSize returns the size of the largest file links are generated for, MAX_FILE_SIZE
+func (r *runtimeConfig) MaxFileSize() int64 {
+	return r.maxFileSize.Load()
+}
+
+// SetMaxFileSize changes the size of the largest file links are generated for
+func (r *runtimeConfig) SetMaxFileSize(size int64) {
+	r.maxFileSize.Store(size)
+}
+
+// FlagLinksPerHour returns the links per hour after which a user is flagged, FLAG_LINKS_PER_HOUR
+func (r *runtimeConfig) FlagLinksPerHour() int {
+	return int(r.flagLinksPerHour.Load())
+}
+
+// SetFlagLinksPerHour changes the links per hour after which a user is flagged
+func (r *runtimeConfig) SetFlagLinksPerHour(threshold int) {
+	r.flagLinksPerHour.Store(int64(threshold))
+}
+
+// Admins returns the IDs of the bot admins, ADMINS and the owner. The slice must not be modified.
+func (r *runtimeConfig) Admins() []int64 {
+	if admins := r.admins.Load(); admins != nil {
+		return *admins
+	}
+	return nil
+}
+
+// AddAdmin makes the user a bot admin, unless they are one already
+func (r *runtimeConfig) AddAdmin(id int64) {
+	for {
+		current := r.admins.Load()
+		var admins []int64
+		if current != nil {
+			for _, admin := range *current {
+				if admin == id {
+					return
+				}
+			}
+			admins = append(admins, *current...)
+		}
+		admins = append(admins, id)
+		if r.admins.CompareAndSwap(current, &admins) {
+			return
+		}
+	}
+}
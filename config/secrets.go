@@ -0,0 +1,129 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// secretVars can be read from files with <VAR>_FILE, like Docker and Kubernetes secrets,
+// or from Vault, so that they don't have to be in the environment or in fsb.env
+var secretVars = []string{
+	"API_HASH",
+	"BOT_TOKEN",
+	"USER_SESSION",
+	"EXPORT_API_TOKEN",
+	"DEBUG_TOKEN",
+	"SPEECH_TO_TEXT_KEY",
+	"PAYMENT_PROVIDER_TOKEN",
+	"SENTRY_DSN",
+	"ENCRYPTION_KEYS",
+	"TMDB_API_KEY",
+	"OIDC_CLIENT_SECRET",
+	"DASHBOARD_ADMINS",
+}
+
+var (
+	multiTokenRegex     = regexp.MustCompile(`^MULTI_TOKEN\d+$`)
+	multiTokenFileRegex = regexp.MustCompile(`^(MULTI_TOKEN\d+)_FILE=(.*)$`)
+)
+
+// vaultTimeout is how long loading the secrets from Vault may take
+const vaultTimeout = 10 * time.Second
+
+// loadSecrets sets the secrets that aren't set in the environment from their files, then
+// from the Vault secret at VAULT_SECRET_PATH. Values set in the environment take precedence.
+func loadSecrets(log *zap.Logger) error {
+	names := append([]string{}, secretVars...)
+	for _, env := range os.Environ() {
+		if match := multiTokenFileRegex.FindStringSubmatch(env); match != nil {
+			names = append(names, match[1])
+		}
+	}
+	for _, name := range names {
+		path := os.Getenv(name + "_FILE")
+		if path == "" {
+			continue
+		}
+		if _, set := os.LookupEnv(name); set {
+			log.Sugar().Warnf("Both %s and %s_FILE are set, using %s", name, name, name)
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("reading %s_FILE: %w", name, err)
+		}
+		os.Setenv(name, strings.TrimRight(string(data), "\r\n"))
+	}
+	return loadVaultSecrets(log, names)
+}
+
+// loadVaultSecrets reads the secret at VAULT_SECRET_PATH with VAULT_TOKEN, or the token in
+// VAULT_TOKEN_FILE, from the Vault at VAULT_ADDR. Both KV version 1 and 2 are supported, its
+// keys are the names of the variables.
+func loadVaultSecrets(log *zap.Logger, names []string) error {
+	address := strings.TrimSuffix(os.Getenv("VAULT_ADDR"), "/")
+	secretPath := strings.Trim(os.Getenv("VAULT_SECRET_PATH"), "/")
+	if address == "" || secretPath == "" {
+		return nil
+	}
+	token := os.Getenv("VAULT_TOKEN")
+	if path := os.Getenv("VAULT_TOKEN_FILE"); token == "" && path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("reading VAULT_TOKEN_FILE: %w", err)
+		}
+		token = strings.TrimSpace(string(data))
+	}
+	req, err := http.NewRequest(http.MethodGet, address+"/v1/"+secretPath, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	res, err := (&http.Client{Timeout: vaultTimeout}).Do(req)
+	if err != nil {
+		return fmt.Errorf("reading secrets from Vault: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("reading secrets from Vault: %s", res.Status)
+	}
+	var secret struct {
+		Data map[string]any `json:"data"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&secret); err != nil {
+		return fmt.Errorf("reading secrets from Vault: %w", err)
+	}
+	values := secret.Data
+	// KV version 2 nests the values in data.data
+	if nested, ok := values["data"].(map[string]any); ok {
+		if _, versioned := values["metadata"]; versioned {
+			values = nested
+		}
+	}
+	for name := range values {
+		if multiTokenRegex.MatchString(name) {
+			names = append(names, name)
+		}
+	}
+	var loaded []string
+	for _, name := range names {
+		value, ok := values[name].(string)
+		if !ok {
+			continue
+		}
+		if _, set := os.LookupEnv(name); set {
+			continue
+		}
+		os.Setenv(name, value)
+		loaded = append(loaded, name)
+	}
+	log.Info("Loaded secrets from Vault", zap.String("path", secretPath), zap.Strings("secrets", loaded))
+	return nil
+}
@@ -0,0 +1,162 @@
+package abuse
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/crash"
+	"EverythingSuckz/fsb/internal/database"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+)
+
+// Detector rate limits link generation and flags users whose behaviour looks like abuse
+type Detector struct {
+	log      *zap.Logger
+	mu       sync.Mutex
+	limit    int // the links per minute the limiters were made for
+	limiters map[int64]*rate.Limiter
+	hits     map[int64][]time.Time
+}
+
+var detector *Detector
+
+func InitDetector(log *zap.Logger) {
+	log = log.Named("abuse")
+	defer log.Sugar().Info("Initialized abuse detector")
+	detector = &Detector{
+		log:      log,
+		limiters: make(map[int64]*rate.Limiter),
+		hits:     make(map[int64][]time.Time),
+	}
+}
+
+func GetDetector() *Detector {
+	return detector
+}
+
+// AllowLink reports whether the user may generate another link right now.
+// Hitting the limit too often within an hour flags the user.
+func (d *Detector) AllowLink(userID int64) bool {
+	limit := config.Runtime.LinkRateLimit()
+	if limit <= 0 {
+		return true
+	}
+	d.mu.Lock()
+	// the limit changed with /set, the limiters start over with the new one
+	if limit != d.limit {
+		d.limit = limit
+		d.limiters = make(map[int64]*rate.Limiter)
+	}
+	limiter, ok := d.limiters[userID]
+	if !ok {
+		limiter = rate.NewLimiter(rate.Every(time.Minute/time.Duration(limit)), limit)
+		d.limiters[userID] = limiter
+	}
+	if limiter.Allow() {
+		d.mu.Unlock()
+		return true
+	}
+	now := time.Now()
+	recent := []time.Time{now}
+	for _, hit := range d.hits[userID] {
+		if now.Sub(hit) < time.Hour {
+			recent = append(recent, hit)
+		}
+	}
+	d.hits[userID] = recent
+	d.mu.Unlock()
+	threshold := config.ValueOf.FlagRateLimitHits
+	if threshold > 0 && len(recent) >= threshold {
+		d.flag(userID, fmt.Sprintf("hit the link rate limit %d times in an hour", len(recent)))
+	}
+	return false
+}
+
+// CheckLinks flags the user if they generated too many links within the last hour
+func (d *Detector) CheckLinks(userID int64) {
+	defer crash.Recover("abuse")
+	threshold := config.Runtime.FlagLinksPerHour()
+	linkRepository := database.GetLinkRepository()
+	if threshold <= 0 || linkRepository == nil {
+		return
+	}
+	count, err := linkRepository.CountSince(userID, time.Now().Add(-time.Hour))
+	if err != nil {
+		d.log.Error("Failed to count links", zap.Int64("userID", userID), zap.Error(err))
+		return
+	}
+	if count >= int64(threshold) {
+		d.flag(userID, fmt.Sprintf("generated %d links in an hour", count))
+	}
+}
+
+// CheckAccess records the IP that accessed a link and its country, if known, and flags the link
+// owner if their links are accessed from too many distinct IPs or countries within a day.
+func (d *Detector) CheckAccess(tenantID uint, messageID int, ip string, country string) {
+	defer crash.Recover("abuse")
+	linkRepository := database.GetLinkRepository()
+	if linkRepository == nil {
+		return
+	}
+	if err := linkRepository.RecordAccess(tenantID, messageID, ip, country); err != nil {
+		d.log.Error("Failed to record link access", zap.Int("messageID", messageID), zap.Error(err))
+		return
+	}
+	ipThreshold := config.ValueOf.FlagDistinctIPs
+	countryThreshold := config.ValueOf.FlagCountries
+	if country == "" {
+		// nothing changed for the countries
+		countryThreshold = 0
+	}
+	if ipThreshold <= 0 && countryThreshold <= 0 {
+		return
+	}
+	link, err := linkRepository.Get(tenantID, messageID)
+	if err != nil {
+		// links generated before links were stored have no owner
+		return
+	}
+	since := time.Now().Add(-24 * time.Hour)
+	if ipThreshold > 0 {
+		count, err := linkRepository.CountDistinctIPsSince(link.UserID, since)
+		if err != nil {
+			d.log.Error("Failed to count link accesses", zap.Int64("userID", link.UserID), zap.Error(err))
+		} else if count >= int64(ipThreshold) {
+			d.flag(link.UserID, fmt.Sprintf("links accessed from %d distinct IPs in a day", count))
+			return
+		}
+	}
+	if countryThreshold > 0 {
+		count, err := linkRepository.CountDistinctCountriesSince(link.UserID, since)
+		if err != nil {
+			d.log.Error("Failed to count link access countries", zap.Int64("userID", link.UserID), zap.Error(err))
+		} else if count >= int64(countryThreshold) {
+			d.flag(link.UserID, fmt.Sprintf("links accessed from %d countries in a day", count))
+		}
+	}
+}
+
+func (d *Detector) flag(userID int64, reason string) {
+	userRepository := database.GetUserRepository()
+	if userRepository == nil {
+		return
+	}
+	flagged, err := userRepository.Flag(userID, reason)
+	if err != nil {
+		d.log.Error("Failed to flag user", zap.Int64("userID", userID), zap.Error(err))
+		return
+	}
+	if !flagged {
+		return
+	}
+	d.log.Warn("Flagged suspicious user", zap.Int64("userID", userID), zap.String("reason", reason))
+	inviterID, err := userRepository.RevokeInviter(userID)
+	if err != nil {
+		d.log.Error("Failed to revoke invites", zap.Int64("userID", userID), zap.Error(err))
+	} else if inviterID != 0 {
+		d.log.Warn("Revoked invites of the inviter of a flagged user", zap.Int64("userID", userID), zap.Int64("inviterID", inviterID))
+	}
+}
@@ -0,0 +1,32 @@
+package abuse
+
+import (
+	"EverythingSuckz/fsb/config"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestAllowLinkFollowsTheRateLimitSetting(t *testing.T) {
+	InitDetector(zap.NewNop())
+	config.ValueOf.FlagRateLimitHits = 0
+	t.Cleanup(func() { config.Runtime.SetLinkRateLimit(0) })
+
+	config.Runtime.SetLinkRateLimit(1)
+	if !detector.AllowLink(7) {
+		t.Fatal("the first link of the minute was refused")
+	}
+	if detector.AllowLink(7) {
+		t.Fatal("a second link was allowed with a limit of 1 a minute")
+	}
+
+	config.Runtime.SetLinkRateLimit(3)
+	for i := 0; i < 3; i++ {
+		if !detector.AllowLink(7) {
+			t.Fatalf("link %d was refused after raising the limit to 3 a minute", i+1)
+		}
+	}
+	if detector.AllowLink(7) {
+		t.Error("a fourth link was allowed with a limit of 3 a minute")
+	}
+}
@@ -0,0 +1,122 @@
+package access
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/scanner"
+	"EverythingSuckz/fsb/internal/tenant"
+	"EverythingSuckz/fsb/internal/types"
+	"EverythingSuckz/fsb/internal/utils"
+	"context"
+	"time"
+
+	"github.com/gotd/td/tg"
+	"go.uber.org/zap"
+)
+
+// Allowed reports whether the user may use the bot, with the bot commands or the API. Bot admins
+// are always allowed, users authorized with /authorize, members of a tenant and users invited by
+// another user are allowed unless they are suspended or removed.
+func Allowed(ctx context.Context, userID int64, admin bool) bool {
+	if admin {
+		return true
+	}
+	if userRepository := database.GetUserRepository().WithContext(ctx); userRepository != nil {
+		if removed, err := userRepository.IsRemoved(userID); err != nil {
+			utils.Logger.Error("Failed to check if user is removed", zap.Error(err), zap.Int64("userID", userID))
+		} else if removed {
+			return false
+		}
+		user, err := userRepository.Get(userID)
+		if err != nil {
+			utils.Logger.Error("Failed to check if user is suspended", zap.Error(err), zap.Int64("userID", userID))
+		} else if user != nil && user.Suspended {
+			return false
+		} else if user != nil && (user.InvitedBy != 0 || Authorized(user)) {
+			return true
+		}
+	}
+	if Tenant(userID) != nil {
+		return true
+	}
+	if len(config.ValueOf.AllowedUsers) == 0 && !config.Runtime.PrivateMode() && !config.ValueOf.PublicMode {
+		return true
+	}
+	return utils.Contains(config.ValueOf.AllowedUsers, userID)
+}
+
+// Authorized reports whether the user has an authorization that didn't expire yet
+func Authorized(user *types.User) bool {
+	return user.Authorized && (user.AuthorizedTill == nil || user.AuthorizedTill.After(time.Now()))
+}
+
+// Tenant returns the tenant the user belongs to, or the one they administer, or nil if there's none
+func Tenant(userID int64) *types.Tenant {
+	if userRepository := database.GetUserRepository(); userRepository != nil {
+		user, err := userRepository.Get(userID)
+		if err != nil {
+			utils.Logger.Error("Failed to get user", zap.Error(err), zap.Int64("userID", userID))
+		} else if user != nil && user.TenantID != 0 {
+			if t := tenant.Get(user.TenantID); t != nil {
+				return t
+			}
+		}
+	}
+	return tenant.AdminOf(userID)
+}
+
+// QuotaExceeded reports whether the user generated the daily link quota of the tenant already,
+// counting the links of the files sent to the bot and of the API uploads
+func QuotaExceeded(workspace *types.Tenant, userID int64) bool {
+	linkRepository := database.GetLinkRepository()
+	if workspace.DailyLinkQuota <= 0 || linkRepository == nil {
+		return false
+	}
+	count, err := linkRepository.CountTenantSince(workspace.ID, userID, time.Now().Add(-24*time.Hour))
+	if err != nil {
+		utils.Logger.Error("Failed to count links", zap.Error(err), zap.Int64("userID", userID))
+		return false
+	}
+	return count >= int64(workspace.DailyLinkQuota)
+}
+
+// Infected scans the file with clamd and quarantines it if a signature matched. The source
+// tells the admins where the file came from, eg. telegram. Files larger than CLAMAV_MAX_SIZE
+// and scanner failures are let through.
+func Infected(ctx context.Context, client *tg.Client, userID int64, file *types.File, source string) bool {
+	if file.FileSize == 0 || file.FileSize > int64(config.ValueOf.ClamAVMaxSize) {
+		return false
+	}
+	reader, err := utils.NewTelegramReader(ctx, client, file.Location, 0, file.FileSize-1, file.FileSize)
+	if err != nil {
+		utils.Logger.Error("Failed to read file for scanning", zap.Error(err))
+		return false
+	}
+	defer reader.Close()
+	result, err := scanner.Scan(reader)
+	if err != nil {
+		utils.Logger.Error("Failed to scan file", zap.Error(err), zap.String("fileName", file.FileName))
+		return false
+	}
+	if !result.Infected {
+		return false
+	}
+	utils.Logger.Warn("Blocked infected file",
+		zap.Int64("userID", userID),
+		zap.String("fileName", file.FileName),
+		zap.String("signature", result.Signature))
+	if quarantineRepository := database.GetQuarantineRepository(); quarantineRepository != nil {
+		err := quarantineRepository.Add(&types.Quarantine{
+			UserID:    userID,
+			FileName:  file.FileName,
+			FileSize:  file.FileSize,
+			MimeType:  file.MimeType,
+			Signature: result.Signature,
+			Source:    source,
+		})
+		if err != nil {
+			utils.Logger.Error("Failed to store quarantine record", zap.Error(err))
+		}
+	}
+	return true
+}
@@ -0,0 +1,79 @@
+// Package activity records when users last interacted with the bot: a command, a connection of the
+// player or a stream of one of their links. The interactions are buffered in memory and written to
+// the last_seen column of the users every flushInterval, so requests don't wait for the database.
+package activity
+
+import (
+	"EverythingSuckz/fsb/internal/crash"
+	"EverythingSuckz/fsb/internal/database"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// flushInterval is how often the buffered interactions are stored
+const flushInterval = 30 * time.Second
+
+type linkKey struct {
+	tenantID  uint
+	messageID int
+}
+
+var (
+	mu    sync.Mutex
+	users = make(map[int64]time.Time)
+	links = make(map[linkKey]time.Time)
+)
+
+// Seen records an interaction of the user
+func Seen(userID int64) {
+	if userID == 0 {
+		return
+	}
+	mu.Lock()
+	users[userID] = time.Now()
+	mu.Unlock()
+}
+
+// SeenLink records an access of a link as an interaction of the user who generated it
+func SeenLink(tenantID uint, messageID int) {
+	mu.Lock()
+	links[linkKey{tenantID, messageID}] = time.Now()
+	mu.Unlock()
+}
+
+// Start periodically stores the recorded interactions
+func Start(log *zap.Logger) {
+	log = log.Named("activity")
+	go func() {
+		ticker := time.NewTicker(flushInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			flush(log)
+		}
+	}()
+}
+
+func flush(log *zap.Logger) {
+	defer crash.Recover("activity")
+	userRepository := database.GetUserRepository()
+	if userRepository == nil {
+		return
+	}
+	mu.Lock()
+	seenUsers, seenLinks := users, links
+	users = make(map[int64]time.Time)
+	links = make(map[linkKey]time.Time)
+	mu.Unlock()
+	if len(seenUsers) > 0 {
+		if err := userRepository.MarkSeen(seenUsers); err != nil {
+			log.Error("Failed to store the last seen users", zap.Error(err), zap.Int("users", len(seenUsers)))
+		}
+	}
+	for key, at := range seenLinks {
+		if err := userRepository.MarkLinkOwnerSeen(key.tenantID, key.messageID, at); err != nil {
+			log.Error("Failed to store the last seen link owner", zap.Error(err), zap.Int("messageID", key.messageID))
+		}
+	}
+}
@@ -0,0 +1,270 @@
+// Package alerts checks the service level objectives of the bot: the error rate of the streams,
+// the failure rate of the Telegram API calls and the 95th percentile of the time to the first
+// byte of the streams. They're measured over the last SLO_WINDOW_MINUTES, and ADMINS get a
+// message in Telegram when one of them is breached, at most once per SLO_ALERT_COOLDOWN_MINUTES,
+// and another one when it's met again.
+package alerts
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/crash"
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gotd/td/bin"
+	"github.com/gotd/td/telegram"
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+	"go.uber.org/zap"
+)
+
+// checkInterval is how often the objectives are checked
+const checkInterval = time.Minute
+
+// maxLatencies is the number of first byte latencies kept per minute, enough for the percentile
+const maxLatencies = 500
+
+// minute holds what was measured during a minute
+type minute struct {
+	start        time.Time
+	streams      int
+	streamErrors int
+	calls        int
+	callErrors   int
+	latencies    []time.Duration
+}
+
+// objective is a service level objective and whether it's breached
+type objective struct {
+	name     string
+	breached bool
+	alerted  time.Time
+}
+
+var (
+	mu       sync.Mutex
+	minutes  []*minute
+	log      = zap.NewNop()
+	notifier crash.Notifier
+
+	streamErrors  = &objective{name: "stream error rate"}
+	telegramCalls = &objective{name: "Telegram API failure rate"}
+	firstByte     = &objective{name: "p95 time to first byte"}
+)
+
+func init() {
+	expvar.Publish("slo", expvar.Func(func() any {
+		s := measure()
+		return map[string]any{
+			"streams":                 s.streams,
+			"stream_error_rate":       s.streamErrorRate(),
+			"telegram_calls":          s.calls,
+			"telegram_failure_rate":   s.callFailureRate(),
+			"first_byte_p95_ms":       s.p95().Milliseconds(),
+			"first_byte_measurements": len(s.latencies),
+		}
+	}))
+}
+
+// Enabled reports whether any objective is set
+func Enabled() bool {
+	return config.ValueOf.SLOStreamErrors > 0 || config.ValueOf.SLOTelegramErrors > 0 || config.ValueOf.SLOFirstByteMs > 0
+}
+
+// Start sets the notifier the alerts are sent with and checks the objectives every minute
+func Start(l *zap.Logger, notify crash.Notifier) {
+	log = l.Named("alerts")
+	notifier = notify
+	if !Enabled() {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(checkInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			check()
+		}
+	}()
+	log.Info("Checking service level objectives",
+		zap.Float64("streamErrorRate", config.ValueOf.SLOStreamErrors),
+		zap.Float64("telegramFailureRate", config.ValueOf.SLOTelegramErrors),
+		zap.Int("firstByteP95Ms", config.ValueOf.SLOFirstByteMs))
+}
+
+// current returns the measurements of this minute and drops the ones older than the window.
+// mu must be held.
+func current() *minute {
+	now := time.Now().Truncate(time.Minute)
+	if len(minutes) > 0 && minutes[len(minutes)-1].start.Equal(now) {
+		return minutes[len(minutes)-1]
+	}
+	window := time.Duration(max(config.ValueOf.SLOWindowMinutes, 1)) * time.Minute
+	minutes = slices.DeleteFunc(minutes, func(m *minute) bool {
+		return now.Sub(m.start) >= window
+	})
+	m := &minute{start: now}
+	minutes = append(minutes, m)
+	return m
+}
+
+// Stream records a finished stream, failed if the server couldn't send it
+func Stream(failed bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	m := current()
+	m.streams++
+	if failed {
+		m.streamErrors++
+	}
+}
+
+// FirstByte records how long a stream took to send its first byte
+func FirstByte(latency time.Duration) {
+	mu.Lock()
+	defer mu.Unlock()
+	if m := current(); len(m.latencies) < maxLatencies {
+		m.latencies = append(m.latencies, latency)
+	}
+}
+
+// Middleware counts the Telegram API calls and their failures: network errors and internal
+// errors of Telegram. Errors of the requests themselves, like a deleted message, aren't failures.
+func Middleware() telegram.Middleware {
+	return telegram.MiddlewareFunc(func(next tg.Invoker) telegram.InvokeFunc {
+		return func(ctx context.Context, input bin.Encoder, output bin.Decoder) error {
+			err := next.Invoke(ctx, input, output)
+			if errors.Is(err, context.Canceled) {
+				return err
+			}
+			failed := err != nil
+			if rpcErr, ok := tgerr.As(err); ok {
+				failed = rpcErr.Code >= 500
+			}
+			mu.Lock()
+			m := current()
+			m.calls++
+			if failed {
+				m.callErrors++
+			}
+			mu.Unlock()
+			return err
+		}
+	})
+}
+
+// summary adds up the measurements of the window
+type summary struct {
+	streams      int
+	streamErrors int
+	calls        int
+	callErrors   int
+	latencies    []time.Duration
+}
+
+func measure() summary {
+	mu.Lock()
+	defer mu.Unlock()
+	current()
+	var s summary
+	for _, m := range minutes {
+		s.streams += m.streams
+		s.streamErrors += m.streamErrors
+		s.calls += m.calls
+		s.callErrors += m.callErrors
+		s.latencies = append(s.latencies, m.latencies...)
+	}
+	return s
+}
+
+// streamErrorRate returns the percentage of failed streams
+func (s summary) streamErrorRate() float64 {
+	if s.streams == 0 {
+		return 0
+	}
+	return float64(s.streamErrors) * 100 / float64(s.streams)
+}
+
+// callFailureRate returns the percentage of failed Telegram API calls
+func (s summary) callFailureRate() float64 {
+	if s.calls == 0 {
+		return 0
+	}
+	return float64(s.callErrors) * 100 / float64(s.calls)
+}
+
+// p95 returns the 95th percentile of the time to the first byte
+func (s summary) p95() time.Duration {
+	if len(s.latencies) == 0 {
+		return 0
+	}
+	sorted := slices.Clone(s.latencies)
+	slices.Sort(sorted)
+	return sorted[(len(sorted)*95-1)/100]
+}
+
+// check compares the measurements of the window against the objectives. Objectives with fewer
+// than SLO_MIN_SAMPLES measurements aren't checked, a single failure of a quiet bot isn't an outage.
+func check() {
+	defer crash.Recover("alerts")
+	s := measure()
+	minSamples := config.ValueOf.SLOMinSamples
+	window := max(config.ValueOf.SLOWindowMinutes, 1)
+	if limit := config.ValueOf.SLOStreamErrors; limit > 0 && s.streams >= minSamples {
+		rate := s.streamErrorRate()
+		evaluate(streamErrors, rate > limit,
+			fmt.Sprintf("%.1f%% of %d streams failed in the last %d minutes, the objective is at most %.1f%%.", rate, s.streams, window, limit))
+	}
+	if limit := config.ValueOf.SLOTelegramErrors; limit > 0 && s.calls >= minSamples {
+		rate := s.callFailureRate()
+		evaluate(telegramCalls, rate > limit,
+			fmt.Sprintf("%.1f%% of %d Telegram API calls failed in the last %d minutes, the objective is at most %.1f%%.", rate, s.calls, window, limit))
+	}
+	if limit := time.Duration(config.ValueOf.SLOFirstByteMs) * time.Millisecond; limit > 0 && len(s.latencies) >= minSamples {
+		p95 := s.p95()
+		evaluate(firstByte, p95 > limit,
+			fmt.Sprintf("95%% of %d streams sent their first byte within %s in the last %d minutes, the objective is %s.", len(s.latencies), p95.Round(time.Millisecond), window, limit))
+	}
+}
+
+// evaluate alerts ADMINS when the objective becomes breached, again after the cooldown while
+// it stays breached, and once it's met again
+func evaluate(o *objective, breached bool, details string) {
+	cooldown := time.Duration(config.ValueOf.SLOCooldownMinutes) * time.Minute
+	var message string
+	switch {
+	case breached && (!o.breached || time.Since(o.alerted) >= cooldown):
+		message = fmt.Sprintf("🚨 The %s is above its objective.\n\n%s", o.name, details)
+		o.alerted = time.Now()
+		log.Warn("Service level objective breached", zap.String("objective", o.name), zap.String("details", details))
+	case !breached && o.breached:
+		message = fmt.Sprintf("✅ The %s is back within its objective.\n\n%s", o.name, details)
+		log.Info("Service level objective met again", zap.String("objective", o.name))
+	}
+	o.breached = breached
+	if message != "" {
+		Admins(message)
+	}
+}
+
+// Admins sends the alert to ADMINS, or the owner of the bot if there are none
+func Admins(message string) {
+	if notifier == nil {
+		return
+	}
+	admins := config.Runtime.Admins()
+	if len(admins) == 0 {
+		if owner := crash.Owner(); owner != 0 {
+			admins = []int64{owner}
+		}
+	}
+	for _, admin := range admins {
+		if err := notifier(admin, message); err != nil {
+			log.Warn("Failed to send alert", zap.Error(err), zap.Int64("admin", admin))
+		}
+	}
+}
@@ -0,0 +1,163 @@
+// Package bench measures the streaming path against the in-memory Telegram of tgfake, which answers like
+// Telegram, so that the throughput and allocations of the streamer can be compared between
+// versions and used to size servers, without a bot or a network.
+package bench
+
+import (
+	"EverythingSuckz/fsb/internal/tgfake"
+	"EverythingSuckz/fsb/internal/utils"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Options describe the simulated load
+type Options struct {
+	Readers    int           // concurrent ranged readers
+	FileSize   int64         // size of the synthetic file
+	RangeSize  int64         // bytes every reader requests
+	Latency    time.Duration // delay of every upstream chunk request
+	ClientRate int64         // bytes per second every reader consumes, 0 for as fast as possible
+}
+
+// Result is the outcome of a benchmark run
+type Result struct {
+	Readers        int     `json:"readers"`
+	Failed         int     `json:"failed"`
+	Bytes          int64   `json:"bytes"`
+	Seconds        float64 `json:"seconds"`
+	MBPerSecond    float64 `json:"mb_per_second"`
+	ChunkRequests  int64   `json:"chunk_requests"`
+	AllocatedBytes uint64  `json:"allocated_bytes"`
+	Allocations    uint64  `json:"allocations"`
+	BytesPerMB     float64 `json:"allocated_bytes_per_mb"` // allocated bytes per streamed MB
+	PeakHeapBytes  uint64  `json:"peak_heap_bytes"`
+	GCs            uint32  `json:"gcs"`
+}
+
+// String formats the result for operators
+func (r Result) String() string {
+	return fmt.Sprintf("%d readers (%d failed) streamed %s in %.2fs\n"+
+		"Throughput:   %.1f MB/s\n"+
+		"Upstream:     %d chunk requests\n"+
+		"Allocations:  %s in %d allocations, %s per streamed MB\n"+
+		"Peak heap:    %s\n"+
+		"GC cycles:    %d",
+		r.Readers, r.Failed, utils.FormatFileSize(r.Bytes), r.Seconds,
+		r.MBPerSecond,
+		r.ChunkRequests,
+		utils.FormatFileSize(int64(r.AllocatedBytes)), r.Allocations, utils.FormatFileSize(int64(r.BytesPerMB)),
+		utils.FormatFileSize(int64(r.PeakHeapBytes)),
+		r.GCs)
+}
+
+// Run streams random ranges of the synthetic file with the readers at once and measures the run
+func Run(ctx context.Context, options Options) (Result, error) {
+	if options.Readers <= 0 || options.FileSize <= 0 || options.RangeSize <= 0 {
+		return Result{}, errors.New("readers, file size and range size must be positive")
+	}
+	rangeSize := min(options.RangeSize, options.FileSize)
+	source := tgfake.New()
+	source.Latency = options.Latency
+	file := source.AddFile(0, 1, "bench.bin", "application/octet-stream", options.FileSize, tgfake.Pattern(options.FileSize))
+	client := source.API()
+
+	var peakHeap atomic.Uint64
+	sampled := make(chan struct{})
+	stopSampling := make(chan struct{})
+	go func() {
+		defer close(sampled)
+		ticker := time.NewTicker(50 * time.Millisecond)
+		defer ticker.Stop()
+		var stats runtime.MemStats
+		for {
+			runtime.ReadMemStats(&stats)
+			if stats.HeapInuse > peakHeap.Load() {
+				peakHeap.Store(stats.HeapInuse)
+			}
+			select {
+			case <-ticker.C:
+			case <-stopSampling:
+				return
+			}
+		}
+	}()
+
+	runtime.GC()
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	started := time.Now()
+	var streamed atomic.Int64
+	var failed atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < options.Readers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := rand.Int63n(options.FileSize - rangeSize + 1)
+			end := start + rangeSize - 1
+			reader, err := utils.NewStreamReader(ctx, client, file.Location, start, end, rangeSize)
+			if err != nil {
+				failed.Add(1)
+				return
+			}
+			defer reader.Close()
+			n, err := io.CopyN(throttled(io.Discard, options.ClientRate), reader, rangeSize)
+			streamed.Add(n)
+			if err != nil {
+				failed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	elapsed := time.Since(started)
+	runtime.ReadMemStats(&after)
+	close(stopSampling)
+	<-sampled
+
+	result := Result{
+		Readers:        options.Readers,
+		Failed:         int(failed.Load()),
+		Bytes:          streamed.Load(),
+		Seconds:        elapsed.Seconds(),
+		ChunkRequests:  source.Requests(),
+		AllocatedBytes: after.TotalAlloc - before.TotalAlloc,
+		Allocations:    after.Mallocs - before.Mallocs,
+		PeakHeapBytes:  peakHeap.Load(),
+		GCs:            after.NumGC - before.NumGC,
+	}
+	if megabytes := float64(result.Bytes) / (1024 * 1024); megabytes > 0 {
+		result.MBPerSecond = megabytes / elapsed.Seconds()
+		result.BytesPerMB = float64(result.AllocatedBytes) / megabytes
+	}
+	return result, nil
+}
+
+// throttledWriter limits how fast a simulated client consumes the stream
+type throttledWriter struct {
+	w       io.Writer
+	rate    int64
+	started time.Time
+	written int64
+}
+
+func throttled(w io.Writer, rate int64) io.Writer {
+	if rate <= 0 {
+		return w
+	}
+	return &throttledWriter{w: w, rate: rate, started: time.Now()}
+}
+
+func (t *throttledWriter) Write(p []byte) (int, error) {
+	n, err := t.w.Write(p)
+	t.written += int64(n)
+	due := t.started.Add(time.Duration(float64(t.written) / float64(t.rate) * float64(time.Second)))
+	time.Sleep(time.Until(due))
+	return n, err
+}
@@ -0,0 +1,161 @@
+// Package billing sells time-limited access to the bot with Telegram payments,
+// paid in Telegram Stars unless another currency and a payment provider are configured.
+package billing
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/types"
+	"EverythingSuckz/fsb/internal/utils"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gotd/td/tg"
+	"go.uber.org/zap"
+)
+
+// starsCurrency is the currency code of Telegram Stars
+const starsCurrency = "XTR"
+
+// Plan is a subscription plan from SUBSCRIPTION_PLANS
+type Plan struct {
+	Name   string
+	Period time.Duration
+	Price  int64 // in the smallest units of PAYMENT_CURRENCY
+}
+
+var plans []Plan
+
+// Load parses SUBSCRIPTION_PLANS, whose entries look like monthly:30d:100
+func Load(log *zap.Logger) {
+	log = log.Named("billing")
+	plans = nil
+	for _, entry := range config.ValueOf.SubscriptionPlans {
+		fields := strings.Split(strings.TrimSpace(entry), ":")
+		if len(fields) != 3 {
+			log.Sugar().Warnf("Ignoring invalid subscription plan %q, use name:period:price", entry)
+			continue
+		}
+		period, err := utils.ParsePeriod(fields[1])
+		if err != nil {
+			log.Sugar().Warnf("Ignoring subscription plan %q: %s", entry, err)
+			continue
+		}
+		price, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil || price <= 0 {
+			log.Sugar().Warnf("Ignoring subscription plan %q: invalid price", entry)
+			continue
+		}
+		plans = append(plans, Plan{Name: fields[0], Period: period, Price: price})
+	}
+	if len(plans) > 0 {
+		log.Sugar().Infof("Loaded %d subscription plans", len(plans))
+	}
+}
+
+// Enabled reports whether any subscription plans are configured
+func Enabled() bool {
+	return len(plans) > 0
+}
+
+// Plans returns the subscription plans
+func Plans() []Plan {
+	return plans
+}
+
+// Find returns the plan with the given name, or nil if there's none
+func Find(name string) *Plan {
+	for i := range plans {
+		if strings.EqualFold(plans[i].Name, name) {
+			return &plans[i]
+		}
+	}
+	return nil
+}
+
+// FormatPrice formats an amount of PAYMENT_CURRENCY for humans
+func FormatPrice(amount int64) string {
+	currency := config.ValueOf.PaymentCurrency
+	if currency == starsCurrency {
+		return fmt.Sprintf("%d ⭐", amount)
+	}
+	return fmt.Sprintf("%.2f %s", float64(amount)/100, currency)
+}
+
+// Invoice returns the invoice of the plan for the user
+func Invoice(plan *Plan, userID int64) *tg.InputMediaInvoice {
+	return &tg.InputMediaInvoice{
+		Title:       fmt.Sprintf("%s subscription", config.ValueOf.AppName),
+		Description: fmt.Sprintf("Access to %s for %s (%s plan)", config.ValueOf.AppName, utils.TimeFormat(uint64(plan.Period.Seconds())), plan.Name),
+		Invoice: tg.Invoice{
+			Currency: config.ValueOf.PaymentCurrency,
+			Prices:   []tg.LabeledPrice{{Label: plan.Name, Amount: plan.Price}},
+		},
+		Payload:      []byte(fmt.Sprintf("%s:%d", plan.Name, userID)),
+		Provider:     config.ValueOf.PaymentProvider,
+		ProviderData: tg.DataJSON{Data: "{}"},
+	}
+}
+
+// Check validates a payment before Telegram charges the user
+func Check(payload []byte, currency string, amount int64) (*Plan, int64, error) {
+	name, user, ok := strings.Cut(string(payload), ":")
+	userID, err := strconv.ParseInt(user, 10, 64)
+	if !ok || err != nil {
+		return nil, 0, errors.New("invalid payload")
+	}
+	plan := Find(name)
+	if plan == nil {
+		return nil, 0, errors.New("this plan is not available anymore")
+	}
+	if currency != config.ValueOf.PaymentCurrency || amount != plan.Price {
+		return nil, 0, errors.New("the price of this plan has changed")
+	}
+	return plan, userID, nil
+}
+
+// Apply records a successful payment of the user and extends their authorization by the
+// period of the plan. Users that are authorized forever stay authorized forever.
+func Apply(userID int64, action *tg.MessageActionPaymentSentMe) (*types.Payment, error) {
+	userRepository := database.GetUserRepository()
+	paymentRepository := database.GetPaymentRepository()
+	if userRepository == nil || paymentRepository == nil {
+		return nil, errors.New("database is not available")
+	}
+	plan, payer, err := Check(action.Payload, action.Currency, action.TotalAmount)
+	if err != nil {
+		return nil, err
+	}
+	if payer != userID {
+		return nil, errors.New("the invoice was issued to another user")
+	}
+	user, err := userRepository.Get(userID)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	forever := user != nil && user.Authorized && user.AuthorizedTill == nil
+	if user != nil && user.Authorized && user.AuthorizedTill != nil && user.AuthorizedTill.After(start) {
+		start = *user.AuthorizedTill
+	}
+	payment := &types.Payment{
+		UserID:    userID,
+		Plan:      plan.Name,
+		Currency:  action.Currency,
+		Amount:    action.TotalAmount,
+		ChargeID:  action.Charge.ID,
+		PaidUntil: start.Add(plan.Period),
+	}
+	if err := paymentRepository.Create(payment); err != nil {
+		return nil, err
+	}
+	if !forever {
+		if err := userRepository.Authorize(userID, &payment.PaidUntil); err != nil {
+			return nil, err
+		}
+	}
+	return payment, nil
+}
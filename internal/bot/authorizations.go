@@ -0,0 +1,48 @@
+package bot
+
+import (
+	"EverythingSuckz/fsb/internal/crash"
+	"EverythingSuckz/fsb/internal/database"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// authorizationCheckInterval is how often expired authorizations are revoked
+const authorizationCheckInterval = time.Minute
+
+// StartAuthorizationExpiry periodically revokes the authorizations given with
+// /authorize <id> <period> once their period lapsed and notifies the users.
+func StartAuthorizationExpiry(log *zap.Logger) {
+	log = log.Named("AuthorizationExpiry")
+	go func() {
+		ticker := time.NewTicker(authorizationCheckInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			expireAuthorizations(log)
+		}
+	}()
+}
+
+func expireAuthorizations(log *zap.Logger) {
+	defer crash.Recover("AuthorizationExpiry")
+	userRepository := database.GetUserRepository()
+	if userRepository == nil || Bot == nil {
+		return
+	}
+	users, err := userRepository.ListExpired(time.Now(), 50)
+	if err != nil {
+		log.Error("Failed to get expired authorizations", zap.Error(err))
+		return
+	}
+	for _, user := range users {
+		if err := userRepository.Deauthorize(user.ID); err != nil {
+			log.Error("Failed to revoke authorization", zap.Int64("userID", user.ID), zap.Error(err))
+			continue
+		}
+		log.Info("Authorization expired", zap.Int64("userID", user.ID))
+		if err := Notify(user.ID, "⌛ Your access to this bot has expired. Please contact an admin to extend it.", nil); err != nil {
+			log.Debug("Failed to notify user", zap.Int64("userID", user.ID), zap.Error(err))
+		}
+	}
+}
@@ -2,19 +2,33 @@ package bot
 
 import (
 	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/alerts"
 	"EverythingSuckz/fsb/internal/commands"
+	"EverythingSuckz/fsb/internal/crash"
+	"EverythingSuckz/fsb/internal/outbox"
+	"EverythingSuckz/fsb/internal/tracing"
 	"context"
+	"strings"
 	"time"
 
 	"go.uber.org/zap"
 
 	"github.com/celestix/gotgproto"
+	"github.com/celestix/gotgproto/ext"
 	"github.com/celestix/gotgproto/sessionMaker"
 	"github.com/glebarez/sqlite"
+	"github.com/gotd/contrib/middleware/floodwait"
+	"github.com/gotd/td/telegram"
 )
 
 var Bot *gotgproto.Client
 
+// reportPanic reports the panics recovered by the dispatcher, whose stack starts with the panic value
+func reportPanic(_ *ext.Context, _ *ext.Update, stack string) {
+	value, trace, _ := strings.Cut(stack, "\n")
+	crash.Report("bot", value, trace)
+}
+
 func StartClient(log *zap.Logger) (*gotgproto.Client, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
 	defer cancel()
@@ -32,6 +46,13 @@ func StartClient(log *zap.Logger) (*gotgproto.Client, error) {
 					sqlite.Open("fsb.session"),
 				),
 				DisableCopyright: true,
+				PanicHandler:     reportPanic,
+				Middlewares: []telegram.Middleware{
+					floodwait.NewSimpleWaiter().WithMaxRetries(10),
+					outbox.Middleware(log, config.ValueOf.MessagesPerSecond),
+					tracing.TelegramMiddleware(),
+					alerts.Middleware(),
+				},
 			},
 		)
 		resultChan <- struct {
@@ -47,7 +68,7 @@ func StartClient(log *zap.Logger) (*gotgproto.Client, error) {
 		if result.err != nil {
 			return nil, result.err
 		}
-		commands.Load(log, result.client.Dispatcher)
+		commands.Load(log, tracing.Dispatcher(result.client.Dispatcher))
 		log.Info("Client started", zap.String("username", result.client.Self.Username))
 		Bot = result.client
 		return result.client, nil
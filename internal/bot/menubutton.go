@@ -0,0 +1,32 @@
+package bot
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/utils"
+	"strings"
+
+	"github.com/gotd/td/tg"
+	"go.uber.org/zap"
+)
+
+// SetMenuButton sets the menu button of the bot to open the web app as a Mini App, if MENU_BUTTON is set
+func SetMenuButton(log *zap.Logger) {
+	if config.ValueOf.MenuButton == "" || Bot == nil {
+		return
+	}
+	log = log.Named("MenuButton")
+	url := utils.PublicURL("/app")
+	if !strings.HasPrefix(url, "https://") {
+		log.Warn("Not setting the menu button, Telegram only opens web apps over https", zap.String("url", url))
+		return
+	}
+	_, err := Bot.API().BotsSetBotMenuButton(Bot.CreateContext(), &tg.BotsSetBotMenuButtonRequest{
+		UserID: &tg.InputUserEmpty{},
+		Button: &tg.BotMenuButton{Text: config.ValueOf.MenuButton, URL: url},
+	})
+	if err != nil {
+		log.Error("Failed to set the menu button", zap.Error(err))
+		return
+	}
+	log.Info("Set the menu button", zap.String("url", url))
+}
@@ -1,6 +1,7 @@
 package bot
 
 import (
+	"EverythingSuckz/fsb/internal/alerts"
 	"time"
 
 	"github.com/gotd/contrib/middleware/floodwait"
@@ -16,5 +17,6 @@ func GetFloodMiddleware(log *zap.Logger) []telegram.Middleware {
 	return []telegram.Middleware{
 		waiter,
 		ratelimiter,
+		alerts.Middleware(),
 	}
 }
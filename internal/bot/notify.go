@@ -0,0 +1,28 @@
+package bot
+
+import (
+	"EverythingSuckz/fsb/internal/outbox"
+	"errors"
+	"math/rand"
+
+	"github.com/gotd/td/tg"
+)
+
+// Notify sends a message to a user who talked to the bot before. It's queued behind the replies to users.
+func Notify(userID int64, message string, markup tg.ReplyMarkupClass) error {
+	if Bot == nil {
+		return errors.New("bot is not started")
+	}
+	peer := Bot.PeerStorage.GetInputPeerById(userID)
+	if peer.Zero() {
+		return errors.New("user is not known to the bot")
+	}
+	ctx := Bot.CreateContext()
+	_, err := Bot.API().MessagesSendMessage(outbox.Low(ctx), &tg.MessagesSendMessageRequest{
+		Peer:        peer,
+		Message:     message,
+		ReplyMarkup: markup,
+		RandomID:    rand.Int63(),
+	})
+	return err
+}
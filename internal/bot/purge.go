@@ -0,0 +1,80 @@
+package bot
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/crash"
+	"EverythingSuckz/fsb/internal/database"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// purgeInterval is how often removed users are checked for purging
+const purgeInterval = time.Hour
+
+// StartUserPurge periodically deletes the users removed with /removeuser more than
+// USER_RETENTION_DAYS ago, with their data. Nothing is purged if the retention is 0.
+func StartUserPurge(log *zap.Logger) {
+	if config.ValueOf.UserRetentionDays <= 0 {
+		return
+	}
+	log = log.Named("UserPurge")
+	go func() {
+		ticker := time.NewTicker(purgeInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			purgeRemovedUsers(log)
+		}
+	}()
+}
+
+func purgeRemovedUsers(log *zap.Logger) {
+	defer crash.Recover("UserPurge")
+	userRepository := database.GetUserRepository()
+	if userRepository == nil {
+		return
+	}
+	before := time.Now().AddDate(0, 0, -config.ValueOf.UserRetentionDays)
+	purged, err := userRepository.PurgeRemoved(before)
+	if err != nil {
+		log.Error("Failed to purge removed users", zap.Error(err))
+		return
+	}
+	if purged > 0 {
+		log.Sugar().Infof("Purged %d removed users", purged)
+	}
+}
+
+// StartProcessedPurge periodically forgets the messages handled more than
+// PROCESSED_RETENTION_HOURS ago, Telegram doesn't deliver updates that old twice.
+// Nothing is forgotten if the retention is 0.
+func StartProcessedPurge(log *zap.Logger) {
+	if config.ValueOf.ProcessedRetention <= 0 {
+		return
+	}
+	log = log.Named("ProcessedPurge")
+	go func() {
+		ticker := time.NewTicker(purgeInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			purgeProcessed(log)
+		}
+	}()
+}
+
+func purgeProcessed(log *zap.Logger) {
+	defer crash.Recover("ProcessedPurge")
+	processedRepository := database.GetProcessedRepository()
+	if processedRepository == nil {
+		return
+	}
+	before := time.Now().Add(-time.Duration(config.ValueOf.ProcessedRetention) * time.Hour)
+	purged, err := processedRepository.Purge(before)
+	if err != nil {
+		log.Error("Failed to purge processed messages", zap.Error(err))
+		return
+	}
+	if purged > 0 {
+		log.Sugar().Debugf("Forgot %d processed messages", purged)
+	}
+}
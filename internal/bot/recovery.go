@@ -0,0 +1,169 @@
+package bot
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/crash"
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/settings"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gotd/td/tg"
+	"go.uber.org/zap"
+)
+
+// updateStateInterval is how often the update state of the bot is stored
+const updateStateInterval = time.Minute
+
+// StartUpdateRecovery handles the media messages sent to the bot while it was offline, newer
+// than UPDATE_RECOVERY_HOURS, and tells the owner how many got a link. The update state
+// is stored every minute, the messages handled between the last time and the bot stopping
+// are recognized as processed and not handled again.
+func StartUpdateRecovery(log *zap.Logger) {
+	if config.ValueOf.RecoveryHours <= 0 {
+		return
+	}
+	log = log.Named("UpdateRecovery")
+	go func() {
+		recoverUpdates(log)
+		ticker := time.NewTicker(updateStateInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			storeUpdateState(log)
+		}
+	}()
+}
+
+// storedUpdateState returns the update state stored before the bot stopped
+func storedUpdateState() (tg.UpdatesState, bool) {
+	var state tg.UpdatesState
+	value, ok := settings.Get(settings.UpdateState)
+	if !ok {
+		return state, false
+	}
+	_, err := fmt.Sscanf(value, "%d:%d:%d:%d", &state.Pts, &state.Qts, &state.Date, &state.Seq)
+	return state, err == nil
+}
+
+// storeUpdateState stores the current update state of the bot
+func storeUpdateState(log *zap.Logger) {
+	defer crash.Recover("UpdateRecovery")
+	state, err := Bot.API().UpdatesGetState(Bot.CreateContext())
+	if err != nil {
+		log.Warn("Failed to get update state", zap.Error(err))
+		return
+	}
+	value := fmt.Sprintf("%d:%d:%d:%d", state.Pts, state.Qts, state.Date, state.Seq)
+	if stored, _ := settings.Get(settings.UpdateState); stored == value {
+		return
+	}
+	if err := settings.Set(settings.UpdateState, value, 0); err != nil {
+		log.Warn("Failed to store update state", zap.Error(err))
+	}
+}
+
+func recoverUpdates(log *zap.Logger) {
+	defer crash.Recover("UpdateRecovery")
+	state, ok := storedUpdateState()
+	if !ok {
+		storeUpdateState(log)
+		return
+	}
+	ctx := Bot.CreateContext()
+	since := time.Now().Add(-time.Duration(config.ValueOf.RecoveryHours) * time.Hour)
+	var dispatched, recovered, expired int
+	for {
+		difference, err := Bot.API().UpdatesGetDifference(ctx, &tg.UpdatesGetDifferenceRequest{
+			Pts:  state.Pts,
+			Qts:  state.Qts,
+			Date: state.Date,
+		})
+		if err != nil {
+			log.Warn("Failed to get the updates missed while offline", zap.Error(err))
+			break
+		}
+		var final bool
+		switch difference := difference.(type) {
+		case *tg.UpdatesDifference:
+			handled, linked, old := handleMissed(ctx, difference.NewMessages, difference.Users, difference.Chats, since)
+			dispatched, recovered, expired = dispatched+handled, recovered+linked, expired+old
+			final = true
+		case *tg.UpdatesDifferenceSlice:
+			handled, linked, old := handleMissed(ctx, difference.NewMessages, difference.Users, difference.Chats, since)
+			dispatched, recovered, expired = dispatched+handled, recovered+linked, expired+old
+			state = difference.IntermediateState
+		case *tg.UpdatesDifferenceTooLong:
+			log.Warn("Too many updates were missed while offline to recover them", zap.Int("pts", difference.Pts))
+			final = true
+		default:
+			final = true
+		}
+		if final {
+			break
+		}
+	}
+	storeUpdateState(log)
+	if dispatched == 0 && expired == 0 {
+		return
+	}
+	log.Sugar().Infof("Handled %d media messages sent while offline, %d got a link, skipped %d older ones", dispatched, recovered, expired)
+	owner := crash.Owner()
+	if owner == 0 {
+		return
+	}
+	message := fmt.Sprintf("📥 Generated links for %d of %d media messages sent while the bot was offline.", recovered, dispatched)
+	if dispatched > recovered {
+		message += " The others were refused or failed, their senders got the reason."
+	}
+	if expired > 0 {
+		message += fmt.Sprintf(" %d sent more than UPDATE_RECOVERY_HOURS (%d) ago were skipped.", expired, config.ValueOf.RecoveryHours)
+	}
+	if err := Notify(owner, message, nil); err != nil {
+		log.Warn("Failed to tell the owner about the recovered messages", zap.Error(err))
+	}
+}
+
+// handleMissed handles the media messages users sent to the bot since the time and returns how
+// many were handled, how many of them got a link and how many were older. Messages that were
+// handled before are left out.
+func handleMissed(ctx context.Context, messages []tg.MessageClass, users []tg.UserClass, chats []tg.ChatClass, since time.Time) (int, int, int) {
+	processedRepository := database.GetProcessedRepository()
+	var missed []tg.UpdateClass
+	var expired int
+	for _, message := range messages {
+		msg, ok := message.(*tg.Message)
+		if !ok || msg.Out || msg.Media == nil {
+			continue
+		}
+		peer, ok := msg.PeerID.(*tg.PeerUser)
+		if !ok {
+			continue
+		}
+		if time.Unix(int64(msg.Date), 0).Before(since) {
+			expired++
+			continue
+		}
+		if processedRepository != nil {
+			if processed, err := processedRepository.Processed(peer.UserID, msg.ID); err == nil && processed {
+				continue
+			}
+		}
+		missed = append(missed, &tg.UpdateNewMessage{Message: msg})
+	}
+	if len(missed) == 0 {
+		return 0, 0, expired
+	}
+	// the dispatcher handles the updates before it returns, so their links are stored by then
+	Bot.Dispatcher.Handle(ctx, &tg.Updates{Updates: missed, Users: users, Chats: chats, Date: int(time.Now().Unix())})
+	var linked int
+	if linkRepository := database.GetLinkRepository(); linkRepository != nil {
+		for _, update := range missed {
+			msg := update.(*tg.UpdateNewMessage).Message.(*tg.Message)
+			if _, err := linkRepository.FindByUserMessage(msg.PeerID.(*tg.PeerUser).UserID, msg.ID); err == nil {
+				linked++
+			}
+		}
+	}
+	return len(missed), linked, expired
+}
@@ -0,0 +1,65 @@
+package bot
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/crash"
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/outbox"
+	"EverythingSuckz/fsb/internal/utils"
+	"time"
+
+	"github.com/gotd/td/tg"
+	"go.uber.org/zap"
+)
+
+// StartReplyUpdater periodically edits the bot replies of accessed links
+// so that they show the current view count and the last access time.
+func StartReplyUpdater(log *zap.Logger) {
+	log = log.Named("ReplyUpdater")
+	if config.ValueOf.ReplyStatsInterval <= 0 {
+		log.Sugar().Info("REPLY_STATS_INTERVAL not set, skipping reply updates")
+		return
+	}
+	interval := time.Duration(config.ValueOf.ReplyStatsInterval) * time.Second
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			updateReplies(log)
+		}
+	}()
+	log.Sugar().Infof("Updating link replies every %s", interval)
+}
+
+func updateReplies(log *zap.Logger) {
+	defer crash.Recover("ReplyUpdater")
+	linkRepository := database.GetLinkRepository()
+	if linkRepository == nil || Bot == nil {
+		return
+	}
+	links, err := linkRepository.GetStale(50)
+	if err != nil {
+		log.Error("Failed to get links to update", zap.Error(err))
+		return
+	}
+	ctx := Bot.CreateContext()
+	for _, link := range links {
+		peer := Bot.PeerStorage.GetInputPeerById(link.UserID)
+		if !peer.Zero() {
+			message, markup := utils.LinkReply(&link)
+			_, err := Bot.API().MessagesEditMessage(outbox.Low(ctx), &tg.MessagesEditMessageRequest{
+				Peer:        peer,
+				ID:          link.ReplyID,
+				Message:     message,
+				ReplyMarkup: markup,
+			})
+			if err != nil {
+				// the reply might have been deleted by the user, don't retry it forever
+				log.Debug("Failed to edit link reply", zap.Int("messageID", link.MessageID), zap.Error(err))
+			}
+		}
+		if err := linkRepository.MarkEdited(link.TenantID, link.MessageID, link.Views); err != nil {
+			log.Error("Failed to mark link reply as edited", zap.Error(err))
+		}
+	}
+}
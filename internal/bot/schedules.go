@@ -0,0 +1,113 @@
+package bot
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/crash"
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/jobs"
+	"EverythingSuckz/fsb/internal/types"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// scheduleInterval is how often the scheduled messages are checked
+const scheduleInterval = time.Minute
+
+// broadcastKind is the kind of the jobs that send the scheduled messages
+const broadcastKind = "broadcast"
+
+// broadcast is the payload of a job that sends a scheduled message
+type broadcast struct {
+	ScheduleID uint
+	Message    string
+	Audience   string
+}
+
+// StartScheduler queues the messages scheduled with /schedule to be sent when they are due
+func StartScheduler(log *zap.Logger) {
+	log = log.Named("Scheduler")
+	jobs.Register(broadcastKind, jobs.Policy{Workers: 1, MaxAttempts: 3, Backoff: time.Minute, MaxBackoff: 10 * time.Minute},
+		func(ctx context.Context, job *types.Job) error {
+			var b broadcast
+			if err := jobs.Decode(job, &b); err != nil {
+				return jobs.Permanent(err)
+			}
+			return sendScheduled(log, &b)
+		})
+	go func() {
+		ticker := time.NewTicker(scheduleInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			runSchedules(log)
+		}
+	}()
+}
+
+func runSchedules(log *zap.Logger) {
+	defer crash.Recover("Scheduler")
+	scheduleRepository := database.GetScheduleRepository()
+	if scheduleRepository == nil || Bot == nil {
+		return
+	}
+	now := time.Now()
+	schedules, err := scheduleRepository.ListDue(now)
+	if err != nil {
+		log.Error("Failed to get due scheduled messages", zap.Error(err))
+		return
+	}
+	for i := range schedules {
+		schedule := &schedules[i]
+		// the run is recorded first, so a slow broadcast isn't started again by the next check
+		if err := scheduleRepository.Advance(schedule, now); err != nil {
+			log.Error("Failed to advance scheduled message", zap.Uint("id", schedule.ID), zap.Error(err))
+			continue
+		}
+		_, err := jobs.Enqueue(broadcastKind, &broadcast{ScheduleID: schedule.ID, Message: schedule.Message, Audience: schedule.Audience})
+		if err != nil {
+			log.Error("Failed to queue scheduled message", zap.Uint("id", schedule.ID), zap.Error(err))
+		}
+	}
+}
+
+// sendScheduled sends the message to its audience. The messages are queued with
+// a low priority, so that large audiences don't delay the replies to users. Only
+// failing to list the recipients fails the job, retrying it after some messages
+// were sent would send them twice.
+func sendScheduled(log *zap.Logger, b *broadcast) error {
+	if Bot == nil {
+		return errors.New("bot is not started")
+	}
+	recipients, err := scheduleRecipients(b.Audience)
+	if err != nil {
+		return fmt.Errorf("listing the recipients: %w", err)
+	}
+	sent := 0
+	for _, userID := range recipients {
+		if err := Notify(userID, b.Message, nil); err != nil {
+			log.Debug("Failed to send scheduled message", zap.Uint("id", b.ScheduleID), zap.Int64("userID", userID), zap.Error(err))
+			continue
+		}
+		sent++
+	}
+	log.Info("Sent scheduled message",
+		zap.Uint("id", b.ScheduleID),
+		zap.String("audience", b.Audience),
+		zap.Int("sent", sent),
+		zap.Int("failed", len(recipients)-sent))
+	return nil
+}
+
+func scheduleRecipients(audience string) ([]int64, error) {
+	if audience == types.AudienceAdmins {
+		return config.Runtime.Admins(), nil
+	}
+	userRepository := database.GetUserRepository()
+	if userRepository == nil {
+		return nil, nil
+	}
+	return userRepository.ListRecipients(audience)
+}
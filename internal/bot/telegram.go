@@ -0,0 +1,91 @@
+package bot
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/types"
+	"EverythingSuckz/fsb/internal/utils"
+	"context"
+	"errors"
+	"io"
+
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+	"go.uber.org/zap"
+)
+
+// FileSource finds the files of log channel messages and the API to download them with
+type FileSource interface {
+	File(ctx context.Context, channelID int64, messageID int) (*types.File, *tg.Client, error)
+}
+
+// Messenger sends messages to the users of the bot
+type Messenger interface {
+	Notify(userID int64, message string, markup tg.ReplyMarkupClass) error
+}
+
+// Uploader uploads files to log channels, for the files uploaded to the web server
+type Uploader interface {
+	Upload(ctx context.Context, channelID int64, fileName string, mimeType string, content io.Reader) (int, *types.File, error)
+	DeleteUpload(ctx context.Context, channelID int64, messageID int) error
+}
+
+// Admins tells the bot admins apart, for the API that authorizes users like the bot does
+type Admins interface {
+	IsAdmin(ctx context.Context, userID int64) bool
+}
+
+// Telegram is what the web server needs from Telegram. The routes use Live unless
+// they're given another one, like the in-memory fake of the tgfake package.
+type Telegram interface {
+	FileSource
+	Messenger
+	Uploader
+	Admins
+}
+
+// Live is the Telegram of the running bot and its workers
+var Live Telegram = live{}
+
+type live struct{}
+
+// File fetches the file with the next worker, so that downloads are spread over them. With
+// PREMIUM_SESSION, files of PREMIUM_MIN_SIZE or more and the ones the bots can't access are
+// fetched with the user session instead.
+func (live) File(ctx context.Context, channelID int64, messageID int) (*types.File, *tg.Client, error) {
+	worker := GetNextWorker()
+	file, err := utils.FileFromMessage(ctx, worker.Client, channelID, messageID)
+	if premium := UserBot.Premium(); premium != nil && needsPremium(file, err) {
+		premiumFile, premiumErr := utils.FileFromMessage(ctx, premium, channelID, messageID)
+		if premiumErr == nil {
+			return premiumFile, premium.API(), nil
+		}
+		UserBot.log.Warn("Failed to fetch file with the user session", zap.Error(premiumErr), zap.Int("messageID", messageID))
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return file, worker.Client.API(), nil
+}
+
+// needsPremium reports whether the file, fetched by a worker, is streamed with the user session:
+// it's large, or the bots can't access its message or channel, or see the message as deleted
+func needsPremium(file *types.File, err error) bool {
+	if err != nil {
+		return errors.Is(err, utils.ErrMessageDeleted) || errors.Is(err, utils.ErrChannelUnresolved) ||
+			tgerr.Is(err, "CHANNEL_PRIVATE", "CHANNEL_INVALID", "CHAT_FORWARDS_RESTRICTED", "MSG_ID_INVALID")
+	}
+	minSize := int64(config.ValueOf.PremiumMinSize)
+	return minSize > 0 && file.FileSize >= minSize
+}
+
+// IsAdmin reports whether the user is listed in ADMINS or is an admin of ADMIN_CHAT
+func (live) IsAdmin(ctx context.Context, userID int64) bool {
+	if Bot == nil {
+		return utils.Contains(config.Runtime.Admins(), userID)
+	}
+	return utils.IsAdmin(ctx, Bot.API(), Bot.PeerStorage, userID)
+}
+
+func (live) Notify(userID int64, message string, markup tg.ReplyMarkupClass) error {
+	return Notify(userID, message, markup)
+}
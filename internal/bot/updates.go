@@ -0,0 +1,59 @@
+package bot
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/crash"
+	"EverythingSuckz/fsb/internal/settings"
+	"EverythingSuckz/fsb/internal/updates"
+	"EverythingSuckz/fsb/internal/version"
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// updateCheckInterval is how often the release feed is checked
+const updateCheckInterval = 24 * time.Hour
+
+// StartUpdateCheck periodically checks the release feed if UPDATE_CHECK is enabled and
+// tells the admins about every new version once
+func StartUpdateCheck(log *zap.Logger) {
+	if !config.ValueOf.UpdateCheck {
+		return
+	}
+	log = log.Named("UpdateCheck")
+	go func() {
+		checkForUpdate(log)
+		ticker := time.NewTicker(updateCheckInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			checkForUpdate(log)
+		}
+	}()
+}
+
+func checkForUpdate(log *zap.Logger) {
+	defer crash.Recover("UpdateCheck")
+	release, err := updates.Check(context.Background())
+	if err != nil {
+		log.Warn("Failed to check for updates", zap.Error(err))
+		return
+	}
+	if !release.Newer() {
+		return
+	}
+	if notified, _ := settings.Get(settings.NotifiedVersion); notified == release.Tag {
+		return
+	}
+	log.Sugar().Infof("Version %s is available, running %s", release.Tag, version.Version)
+	message := fmt.Sprintf("🆕 %s is available, this bot runs %s.\n\n%s\n\n%s", release.Tag, version.Version, release.Excerpt(), release.URL)
+	for _, admin := range config.Runtime.Admins() {
+		if err := Notify(admin, message, nil); err != nil {
+			log.Warn("Failed to notify admin about the update", zap.Int64("userID", admin), zap.Error(err))
+		}
+	}
+	if err := settings.Set(settings.NotifiedVersion, release.Tag, 0); err != nil {
+		log.Warn("Failed to remember the notified version", zap.Error(err))
+	}
+}
@@ -0,0 +1,70 @@
+package bot
+
+import (
+	"EverythingSuckz/fsb/internal/types"
+	"EverythingSuckz/fsb/internal/utils"
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+
+	"github.com/gotd/td/telegram/uploader"
+	"github.com/gotd/td/tg"
+)
+
+// Upload uploads the content as a document to the log channel and returns its message and file
+func (live) Upload(ctx context.Context, channelID int64, fileName string, mimeType string, content io.Reader) (int, *types.File, error) {
+	if Bot == nil {
+		return 0, nil, errors.New("bot is not started")
+	}
+	api := Bot.API()
+	channel, err := utils.GetChannelPeer(ctx, api, Bot.PeerStorage, channelID)
+	if err != nil {
+		return 0, nil, err
+	}
+	uploaded, err := uploader.NewUploader(api).FromReader(ctx, fileName, content)
+	if err != nil {
+		return 0, nil, err
+	}
+	updates, err := api.MessagesSendMedia(ctx, &tg.MessagesSendMediaRequest{
+		Peer: &tg.InputPeerChannel{ChannelID: channel.ChannelID, AccessHash: channel.AccessHash},
+		Media: &tg.InputMediaUploadedDocument{
+			File:       uploaded,
+			MimeType:   mimeType,
+			ForceFile:  true,
+			Attributes: []tg.DocumentAttributeClass{&tg.DocumentAttributeFilename{FileName: fileName}},
+		},
+		RandomID: rand.Int63(),
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	result, ok := updates.(*tg.Updates)
+	if !ok {
+		return 0, nil, errors.New("unexpected response to the upload")
+	}
+	for _, update := range result.Updates {
+		newMessage, ok := update.(*tg.UpdateNewChannelMessage)
+		if !ok {
+			continue
+		}
+		message, ok := newMessage.Message.(*tg.Message)
+		if !ok {
+			continue
+		}
+		file, err := utils.FileFromMedia(message.Media)
+		if err != nil {
+			return 0, nil, err
+		}
+		return message.ID, file, nil
+	}
+	return 0, nil, errors.New("the uploaded message is missing from the response")
+}
+
+// DeleteUpload deletes an uploaded message from the log channel, eg. when the virus scanner blocked its file
+func (live) DeleteUpload(ctx context.Context, channelID int64, messageID int) error {
+	if Bot == nil {
+		return errors.New("bot is not started")
+	}
+	return Bot.CreateContext().DeleteMessages(channelID, []int{messageID})
+}
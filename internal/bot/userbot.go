@@ -21,6 +21,9 @@ func StartUserBot(l *zap.Logger) {
 	log := l.Named("USERBOT")
 	if config.ValueOf.UserSession == "" {
 		log.Warn("User session is empty")
+		if config.ValueOf.PremiumSession {
+			log.Warn("PREMIUM_SESSION is enabled without USER_SESSION, all files are streamed by the bots")
+		}
 		return
 	}
 	log.Sugar().Infoln("Starting userbot")
@@ -40,16 +43,30 @@ func StartUserBot(l *zap.Logger) {
 	UserBot.log = log
 	UserBot.client = client
 	log.Info("Userbot started", zap.String("username", client.Self.Username), zap.String("FirstName", client.Self.FirstName), zap.String("LastName", client.Self.LastName))
+	if config.ValueOf.PremiumSession {
+		if !client.Self.Premium {
+			log.Warn("PREMIUM_SESSION is enabled but the user doesn't have Telegram Premium, its downloads may be slower")
+		}
+		log.Info("Streaming large and restricted files with the user session", zap.Int64("minSize", int64(config.ValueOf.PremiumMinSize)))
+	}
 	if err := UserBot.AddBotsAsAdmins(); err != nil {
 		log.Error("Failed to add bots as admins", zap.Error(err))
 		return
 	}
 }
 
+// Premium returns the client of the user session if PREMIUM_SESSION is enabled, or nil
+func (u *UserBotStruct) Premium() *gotgproto.Client {
+	if !config.ValueOf.PremiumSession {
+		return nil
+	}
+	return u.client
+}
+
 func (u *UserBotStruct) AddBotsAsAdmins() error {
 	u.log.Info("Preparing to add bots as admins")
 	ctx := u.client.CreateContext()
-	channel := config.ValueOf.LogChannelID
+	channel := config.Runtime.LogChannelID()
 	channelInfos, err := u.client.API().ChannelsGetChannels(
 		ctx,
 		[]tg.InputChannelClass{
@@ -0,0 +1,67 @@
+// Package budget shares MEMORY_BUDGET between the buffers of the streams and the transcode jobs,
+// so that small servers queue or turn away new work instead of running out of memory
+package budget
+
+import (
+	"EverythingSuckz/fsb/config"
+	"context"
+	"errors"
+	"expvar"
+	"sync"
+	"time"
+)
+
+// QueueTimeout is how long new work waits for memory before it's turned away
+const QueueTimeout = 10 * time.Second
+
+// ErrExhausted is returned when the memory didn't free up within QueueTimeout
+var ErrExhausted = errors.New("memory budget exhausted")
+
+var (
+	mu   sync.Mutex
+	used int64
+	// freed is closed and replaced whenever memory is released, waking up the queued work
+	freed = make(chan struct{})
+	// usedBytes is published at /debug/vars
+	usedBytes = expvar.NewInt("memory_budget_used")
+)
+
+// Reserve waits until size bytes of the budget are free, at most QueueTimeout, and returns a
+// func that releases them. Without MEMORY_BUDGET it returns right away.
+func Reserve(ctx context.Context, size int64) (func(), error) {
+	limit := int64(config.ValueOf.MemoryBudget)
+	if limit <= 0 {
+		return func() {}, nil
+	}
+	timer := time.NewTimer(QueueTimeout)
+	defer timer.Stop()
+	for {
+		mu.Lock()
+		// work larger than the whole budget can still run alone
+		if used+size <= limit || used == 0 {
+			used += size
+			usedBytes.Set(used)
+			mu.Unlock()
+			var once sync.Once
+			return func() { once.Do(func() { release(size) }) }, nil
+		}
+		wait := freed
+		mu.Unlock()
+		select {
+		case <-wait:
+		case <-timer.C:
+			return nil, ErrExhausted
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func release(size int64) {
+	mu.Lock()
+	defer mu.Unlock()
+	used -= size
+	usedBytes.Set(used)
+	close(freed)
+	freed = make(chan struct{})
+}
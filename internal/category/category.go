@@ -0,0 +1,220 @@
+// Package category sorts the files users send into categories like Music, Movies or Voice
+// from their MIME type, extension, duration and Telegram attributes when their links are
+// generated, so that /history, the web app and podcast feeds can be filtered by them. The
+// rules of CATEGORY_RULES are checked before the built-in ones.
+package category
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/types"
+	"fmt"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// The built-in categories
+const (
+	Music     = "Music"
+	Movies    = "Movies"
+	Videos    = "Videos"
+	Voice     = "Voice"
+	GIFs      = "GIFs"
+	Photos    = "Photos"
+	Documents = "Documents"
+)
+
+// movieDuration is the duration in seconds from which videos are movies rather than clips
+const movieDuration = 40 * 60
+
+// backfillBatch is the number of links categorized at once after an upgrade
+const backfillBatch = 500
+
+// rule assigns its category to the files matching all of its conditions
+type rule struct {
+	category    string
+	mimeTypes   []string // eg. audio/mpeg or audio/*
+	extensions  []string // with their dot
+	minDuration int      // seconds
+	maxDuration int      // seconds, 0 for no maximum
+	name        string   // part of the file name, lower case
+	voice       bool
+	animated    bool
+}
+
+var builtinRules = []rule{
+	{category: Voice, voice: true},
+	{category: GIFs, animated: true},
+	{category: GIFs, mimeTypes: []string{"image/gif"}},
+	{category: Music, mimeTypes: []string{"audio/*"}},
+	{category: Movies, mimeTypes: []string{"video/*"}, minDuration: movieDuration},
+	{category: Videos, mimeTypes: []string{"video/*"}},
+	{category: Photos, mimeTypes: []string{"image/*"}},
+	{category: Documents},
+}
+
+var rules = builtinRules
+
+// Load parses CATEGORY_RULES and categorizes the links generated before categories were
+// assigned in the background
+func Load(log *zap.Logger) error {
+	log = log.Named("category")
+	custom, err := parseRules(config.ValueOf.CategoryRules)
+	if err != nil {
+		return err
+	}
+	rules = append(custom, builtinRules...)
+	if len(custom) > 0 {
+		log.Sugar().Infof("Loaded %d category rules", len(custom))
+	}
+	go backfill(log)
+	return nil
+}
+
+// parseRules parses rules like "Podcasts=mime:audio/* min:20m; Books=ext:epub,pdf", rules
+// are separated by semicolons and their conditions by spaces
+func parseRules(value string) ([]rule, error) {
+	var parsed []rule
+	for _, entry := range strings.Split(value, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, conditions, ok := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid category rule %q, use Category=condition condition", entry)
+		}
+		r := rule{category: name}
+		for _, condition := range strings.Fields(conditions) {
+			key, value, _ := strings.Cut(condition, ":")
+			var err error
+			switch strings.ToLower(key) {
+			case "mime":
+				r.mimeTypes = strings.Split(strings.ToLower(value), ",")
+			case "ext":
+				for _, ext := range strings.Split(strings.ToLower(value), ",") {
+					r.extensions = append(r.extensions, "."+strings.TrimPrefix(ext, "."))
+				}
+			case "min":
+				r.minDuration, err = parseDuration(value)
+			case "max":
+				r.maxDuration, err = parseDuration(value)
+			case "name":
+				r.name = strings.ToLower(value)
+			case "voice":
+				r.voice = true
+			case "animated":
+				r.animated = true
+			default:
+				err = fmt.Errorf("unknown condition %q, use mime, ext, min, max, name, voice or animated", condition)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("category rule %s: %w", name, err)
+			}
+		}
+		parsed = append(parsed, r)
+	}
+	return parsed, nil
+}
+
+// parseDuration parses seconds, or durations like 20m or 1h30m
+func parseDuration(value string) (int, error) {
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return seconds, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+	return int(duration.Seconds()), nil
+}
+
+func (r *rule) matches(file *types.File) bool {
+	if r.voice && !file.Voice || r.animated && !file.Animated {
+		return false
+	}
+	mimeType := strings.ToLower(file.MimeType)
+	if len(r.mimeTypes) > 0 && !slices.ContainsFunc(r.mimeTypes, func(pattern string) bool {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			return strings.HasPrefix(mimeType, prefix)
+		}
+		return mimeType == pattern
+	}) {
+		return false
+	}
+	if len(r.extensions) > 0 && !slices.Contains(r.extensions, strings.ToLower(path.Ext(file.FileName))) {
+		return false
+	}
+	if file.Duration < r.minDuration || r.maxDuration > 0 && file.Duration > r.maxDuration {
+		return false
+	}
+	return r.name == "" || strings.Contains(strings.ToLower(file.FileName), r.name)
+}
+
+// Of returns the category of the file, the one of the first rule it matches
+func Of(file *types.File) string {
+	for i := range rules {
+		if rules[i].matches(file) {
+			return rules[i].category
+		}
+	}
+	return Documents
+}
+
+// Names returns the categories files can be assigned to, the ones of CATEGORY_RULES first
+func Names() []string {
+	var names []string
+	for _, r := range rules {
+		if !slices.Contains(names, r.category) {
+			names = append(names, r.category)
+		}
+	}
+	return names
+}
+
+// Find returns the category with the name regardless of its case, for filters typed by users
+func Find(name string) (string, bool) {
+	for _, category := range Names() {
+		if strings.EqualFold(category, name) {
+			return category, true
+		}
+	}
+	return "", false
+}
+
+// backfill categorizes the links generated before categories were assigned. Their voice and
+// GIF attributes weren't stored, so they're categorized by their name, MIME type and duration.
+func backfill(log *zap.Logger) {
+	linkRepository := database.GetLinkRepository()
+	if linkRepository == nil {
+		return
+	}
+	total := 0
+	for {
+		links, err := linkRepository.ListUncategorized(backfillBatch)
+		if err != nil {
+			log.Error("Failed to list uncategorized links", zap.Error(err))
+			return
+		}
+		for _, link := range links {
+			category := Of(&types.File{FileName: link.FileName, MimeType: link.MimeType, Duration: link.Duration})
+			if err := linkRepository.SetCategory(link.TenantID, link.MessageID, category); err != nil {
+				log.Error("Failed to categorize link", zap.Error(err), zap.String("link", link.StorageKey()))
+				return
+			}
+		}
+		total += len(links)
+		if len(links) < backfillBatch {
+			break
+		}
+	}
+	if total > 0 {
+		log.Sugar().Infof("Categorized %d links", total)
+	}
+}
@@ -0,0 +1,154 @@
+package commands
+
+import (
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/types"
+	"EverythingSuckz/fsb/internal/utils"
+	"EverythingSuckz/fsb/internal/webauth"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/dispatcher/handlers"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/celestix/gotgproto/storage"
+	"go.uber.org/zap"
+)
+
+// maxAPITokens is the number of API tokens a user can have
+const maxAPITokens = 10
+
+var apiTokenUsage = `Usage:
+/apitoken - list your API tokens
+/apitoken create <name> [scopes] - create a token for your scripts, the scopes are separated by commas: ` + strings.Join(webauth.Scopes, ", ") + ` (default: read-history)
+/apitoken revoke <id> - revoke a token, or all of them with /apitoken revoke all`
+
+func (m *command) LoadAPIToken(dispatcher dispatcher.Dispatcher) {
+	log := m.log.Named("apitoken")
+	defer log.Sugar().Info("Loaded")
+	dispatcher.AddHandler(handlers.NewCommand("apitoken", apiToken))
+}
+
+// apiToken lists, creates and revokes the personal access tokens of the user
+func apiToken(ctx *ext.Context, u *ext.Update) error {
+	chatId := u.EffectiveChat().GetID()
+	peerChatId := ctx.PeerStorage.GetPeerById(chatId)
+	if peerChatId.Type != int(storage.TypeUser) {
+		return dispatcher.EndGroups
+	}
+	trackUser(u)
+	if !isAllowed(ctx, chatId) {
+		ctx.Reply(u, "You are not allowed to use this bot.", nil)
+		return dispatcher.EndGroups
+	}
+	apiTokenRepository := database.GetAPITokenRepository()
+	if apiTokenRepository == nil {
+		ctx.Reply(u, "API tokens need the database, which is not available.", nil)
+		return dispatcher.EndGroups
+	}
+	args := u.Args()
+	if len(args) < 2 {
+		ctx.Reply(u, apiTokenList(apiTokenRepository, chatId), nil)
+		return dispatcher.EndGroups
+	}
+	switch strings.ToLower(args[1]) {
+	case "create":
+		if len(args) < 3 || len(args) > 4 {
+			ctx.Reply(u, apiTokenUsage, nil)
+			return dispatcher.EndGroups
+		}
+		scopes := []string{webauth.ScopeHistory}
+		if len(args) == 4 {
+			scopes = strings.Split(strings.ToLower(args[3]), ",")
+			for _, scope := range scopes {
+				if !slices.Contains(webauth.Scopes, scope) {
+					ctx.Reply(u, fmt.Sprintf("Unknown scope %s.\n\n%s", scope, apiTokenUsage), nil)
+					return dispatcher.EndGroups
+				}
+			}
+			slices.Sort(scopes)
+			scopes = slices.Compact(scopes)
+		}
+		tokens, err := apiTokenRepository.List(chatId)
+		if err != nil {
+			ctx.Reply(u, fmt.Sprintf("Error - %s", err.Error()), nil)
+			return dispatcher.EndGroups
+		}
+		if len(tokens) >= maxAPITokens {
+			ctx.Reply(u, fmt.Sprintf("You have %d API tokens already, revoke one first.", len(tokens)), nil)
+			return dispatcher.EndGroups
+		}
+		token, digest, err := webauth.NewAPIToken()
+		if err != nil {
+			ctx.Reply(u, fmt.Sprintf("Error - %s", err.Error()), nil)
+			return dispatcher.EndGroups
+		}
+		stored := &types.APIToken{UserID: chatId, Name: args[2], Digest: digest, Scopes: strings.Join(scopes, ",")}
+		if err := apiTokenRepository.Create(stored); err != nil {
+			utils.Logger.Error("Failed to create API token", zap.Error(err), zap.Int64("userID", chatId))
+			ctx.Reply(u, fmt.Sprintf("Error - %s", err.Error()), nil)
+			return dispatcher.EndGroups
+		}
+		ctx.Reply(u, fmt.Sprintf("🔑 API token %d (%s) with the scopes %s:\n\n%s\n\n"+
+			"It's only shown now, keep it somewhere safe. Send it as Authorization: Bearer <token>, or in the api_token query param, to %s and the other endpoints. "+
+			"/apitoken revoke %d turns it off.",
+			stored.ID, stored.Name, stored.Scopes, token, utils.PublicURL("/api/history"), stored.ID), nil)
+	case "revoke":
+		if len(args) != 3 {
+			ctx.Reply(u, apiTokenUsage, nil)
+			return dispatcher.EndGroups
+		}
+		if strings.EqualFold(args[2], "all") {
+			confirm(ctx, u, "Revoke all your API tokens? The scripts using them stop working.", func(ctx *ext.Context) string {
+				revoked, err := apiTokenRepository.DeleteAll(chatId)
+				if err != nil {
+					return fmt.Sprintf("Error - %s", err.Error())
+				}
+				return fmt.Sprintf("🗑 Revoked %d API tokens.", revoked)
+			})
+			return dispatcher.EndGroups
+		}
+		id, err := strconv.ParseUint(args[2], 10, 0)
+		if err != nil {
+			ctx.Reply(u, apiTokenUsage, nil)
+			return dispatcher.EndGroups
+		}
+		revoked, err := apiTokenRepository.Delete(chatId, uint(id))
+		if err != nil {
+			ctx.Reply(u, fmt.Sprintf("Error - %s", err.Error()), nil)
+			return dispatcher.EndGroups
+		}
+		if !revoked {
+			ctx.Reply(u, fmt.Sprintf("You have no API token %d, see /apitoken.", id), nil)
+			return dispatcher.EndGroups
+		}
+		ctx.Reply(u, fmt.Sprintf("🗑 Revoked API token %d.", id), nil)
+	default:
+		ctx.Reply(u, apiTokenUsage, nil)
+	}
+	return dispatcher.EndGroups
+}
+
+// apiTokenList lists the tokens of the user with their scopes and when they were last used
+func apiTokenList(apiTokenRepository *database.APITokenRepository, userID int64) string {
+	tokens, err := apiTokenRepository.List(userID)
+	if err != nil {
+		return fmt.Sprintf("Error - %s", err.Error())
+	}
+	if len(tokens) == 0 {
+		return "You have no API tokens.\n\n" + apiTokenUsage
+	}
+	var sb strings.Builder
+	sb.WriteString("🔑 Your API tokens:\n\n")
+	for _, token := range tokens {
+		lastUsed := "never used"
+		if token.LastUsedAt != nil {
+			lastUsed = "last used " + token.LastUsedAt.Format("2006-01-02 15:04")
+		}
+		sb.WriteString(fmt.Sprintf("%d. %s - %s, %s\n", token.ID, token.Name, token.Scopes, lastUsed))
+	}
+	sb.WriteString("\n" + apiTokenUsage)
+	return sb.String()
+}
@@ -0,0 +1,135 @@
+package commands
+
+import (
+	"EverythingSuckz/fsb/internal/access"
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/utils"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/dispatcher/handlers"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/celestix/gotgproto/storage"
+	"go.uber.org/zap"
+)
+
+func (m *command) LoadAuthorize(dispatcher dispatcher.Dispatcher) {
+	log := m.log.Named("authorize")
+	defer log.Sugar().Info("Loaded")
+	dispatcher.AddHandler(handlers.NewCommand("authorize", authorize))
+	dispatcher.AddHandler(handlers.NewCommand("extend", authorize))
+	dispatcher.AddHandler(handlers.NewCommand("deauthorize", deauthorize))
+}
+
+// authorize handles /authorize <user_id> [period], which authorizes the user for the
+// period or forever, and /extend <user_id> <period>, which adds the period to the
+// current authorization of the user.
+func authorize(ctx *ext.Context, u *ext.Update) error {
+	chatId := u.EffectiveChat().GetID()
+	peerChatId := ctx.PeerStorage.GetPeerById(chatId)
+	if peerChatId.Type != int(storage.TypeUser) {
+		return dispatcher.EndGroups
+	}
+	if !utils.IsAdmin(ctx, ctx.Raw, ctx.PeerStorage, chatId) {
+		ctx.Reply(u, "This command is only available to admins.", nil)
+		return dispatcher.EndGroups
+	}
+	args := u.Args()
+	extend := strings.HasPrefix(args[0], "/extend")
+	if len(args) < 2 || (extend && len(args) < 3) {
+		ctx.Reply(u, "Usage:\n/authorize <user_id> [period]\n/extend <user_id> <period>\n\nPeriods look like 12h, 30d or 2w.", nil)
+		return dispatcher.EndGroups
+	}
+	userID, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		ctx.Reply(u, "Invalid user ID.", nil)
+		return dispatcher.EndGroups
+	}
+	var period time.Duration
+	if len(args) > 2 {
+		if period, err = utils.ParsePeriod(args[2]); err != nil {
+			ctx.Reply(u, "Invalid period, use something like 12h, 30d or 2w.", nil)
+			return dispatcher.EndGroups
+		}
+	}
+	userRepository := database.GetUserRepository().WithContext(ctx)
+	if userRepository == nil {
+		ctx.Reply(u, "❌ User database is not available at the moment.", nil)
+		return dispatcher.EndGroups
+	}
+	var until *time.Time
+	if period > 0 {
+		start := time.Now()
+		if extend {
+			user, err := userRepository.Get(userID)
+			if err != nil {
+				ctx.Reply(u, fmt.Sprintf("Error - %s", err.Error()), nil)
+				return dispatcher.EndGroups
+			}
+			if user != nil && access.Authorized(user) {
+				if user.AuthorizedTill == nil {
+					ctx.Reply(u, fmt.Sprintf("User %d is already authorized forever.", userID), nil)
+					return dispatcher.EndGroups
+				}
+				start = *user.AuthorizedTill
+			}
+		}
+		end := start.Add(period)
+		until = &end
+	}
+	if err := userRepository.Authorize(userID, until); err != nil {
+		utils.Logger.Error("Failed to authorize user", zap.Error(err), zap.Int64("userID", userID))
+		ctx.Reply(u, fmt.Sprintf("Error - %s", err.Error()), nil)
+		return dispatcher.EndGroups
+	}
+	if until == nil {
+		ctx.Reply(u, fmt.Sprintf("✅ User %d is authorized.", userID), nil)
+	} else {
+		ctx.Reply(u, fmt.Sprintf("✅ User %d is authorized until %s.", userID, until.Format("2006-01-02 15:04")), nil)
+	}
+	if !extend {
+		beginOnboarding(ctx, userID)
+	}
+	return dispatcher.EndGroups
+}
+
+func deauthorize(ctx *ext.Context, u *ext.Update) error {
+	chatId := u.EffectiveChat().GetID()
+	peerChatId := ctx.PeerStorage.GetPeerById(chatId)
+	if peerChatId.Type != int(storage.TypeUser) {
+		return dispatcher.EndGroups
+	}
+	if !utils.IsAdmin(ctx, ctx.Raw, ctx.PeerStorage, chatId) {
+		ctx.Reply(u, "This command is only available to admins.", nil)
+		return dispatcher.EndGroups
+	}
+	args := u.Args()
+	if len(args) < 2 {
+		ctx.Reply(u, "Usage: /deauthorize <user_id>", nil)
+		return dispatcher.EndGroups
+	}
+	userID, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		ctx.Reply(u, "Invalid user ID.", nil)
+		return dispatcher.EndGroups
+	}
+	userRepository := database.GetUserRepository().WithContext(ctx)
+	if userRepository == nil {
+		ctx.Reply(u, "❌ User database is not available at the moment.", nil)
+		return dispatcher.EndGroups
+	}
+	confirm(ctx, u, fmt.Sprintf("🚫 Deauthorize user %s?", describeUserID(userID)), func(ctx *ext.Context) string {
+		userRepository := database.GetUserRepository().WithContext(ctx)
+		if userRepository == nil {
+			return "❌ User database is not available at the moment."
+		}
+		if err := userRepository.Deauthorize(userID); err != nil {
+			return fmt.Sprintf("Error - %s", err.Error())
+		}
+		return fmt.Sprintf("✅ User %d is no longer authorized.", userID)
+	})
+	return dispatcher.EndGroups
+}
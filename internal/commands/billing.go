@@ -0,0 +1,159 @@
+package commands
+
+import (
+	"EverythingSuckz/fsb/internal/access"
+	"EverythingSuckz/fsb/internal/billing"
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/utils"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/dispatcher/handlers"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/celestix/gotgproto/storage"
+	"github.com/gotd/td/tg"
+	"go.uber.org/zap"
+)
+
+func (m *command) LoadBilling(dispatcher dispatcher.Dispatcher) {
+	log := m.log.Named("billing")
+	defer log.Sugar().Info("Loaded")
+	dispatcher.AddHandler(handlers.NewCommand("subscribe", subscribe))
+	dispatcher.AddHandler(handlers.NewCommand("billing", billingStatus))
+	dispatcher.AddHandler(handlers.NewAnyUpdate(paymentUpdate))
+}
+
+// subscribe lists the plans, or sends the invoice of the plan given with /subscribe <plan>
+func subscribe(ctx *ext.Context, u *ext.Update) error {
+	chatId := u.EffectiveChat().GetID()
+	peerChatId := ctx.PeerStorage.GetPeerById(chatId)
+	if peerChatId.Type != int(storage.TypeUser) {
+		return dispatcher.EndGroups
+	}
+	trackUser(u)
+	if !billing.Enabled() {
+		ctx.Reply(u, "Subscriptions are not available.", nil)
+		return dispatcher.EndGroups
+	}
+	if userRepository := database.GetUserRepository().WithContext(ctx); userRepository != nil {
+		if suspended, err := userRepository.IsSuspended(chatId); err == nil && suspended {
+			ctx.Reply(u, "You are not allowed to use this bot.", nil)
+			return dispatcher.EndGroups
+		}
+	}
+	args := u.Args()
+	if len(args) < 2 {
+		var sb strings.Builder
+		sb.WriteString("💳 Plans\n")
+		for _, plan := range billing.Plans() {
+			sb.WriteString(fmt.Sprintf("\n• %s - %s for %s\n  /subscribe %s", plan.Name, billing.FormatPrice(plan.Price), utils.TimeFormat(uint64(plan.Period.Seconds())), plan.Name))
+		}
+		ctx.Reply(u, sb.String(), nil)
+		return dispatcher.EndGroups
+	}
+	plan := billing.Find(args[1])
+	if plan == nil {
+		ctx.Reply(u, "Unknown plan, send /subscribe to see the plans.", nil)
+		return dispatcher.EndGroups
+	}
+	_, err := ctx.SendMedia(chatId, &tg.MessagesSendMediaRequest{Media: billing.Invoice(plan, chatId)})
+	if err != nil {
+		utils.Logger.Error("Failed to send invoice", zap.Error(err), zap.Int64("userID", chatId))
+		ctx.Reply(u, "❌ Failed to send the invoice. Please try again later.", nil)
+	}
+	return dispatcher.EndGroups
+}
+
+// billingStatus shows the authorization of the user and their recent payments
+func billingStatus(ctx *ext.Context, u *ext.Update) error {
+	chatId := u.EffectiveChat().GetID()
+	peerChatId := ctx.PeerStorage.GetPeerById(chatId)
+	if peerChatId.Type != int(storage.TypeUser) {
+		return dispatcher.EndGroups
+	}
+	trackUser(u)
+	userRepository := database.GetUserRepository().WithContext(ctx)
+	paymentRepository := database.GetPaymentRepository()
+	if userRepository == nil || paymentRepository == nil {
+		ctx.Reply(u, "❌ Billing is not available at the moment.", nil)
+		return dispatcher.EndGroups
+	}
+	user, err := userRepository.Get(chatId)
+	if err != nil {
+		ctx.Reply(u, fmt.Sprintf("Error - %s", err.Error()), nil)
+		return dispatcher.EndGroups
+	}
+	payments, err := paymentRepository.ListByUser(chatId, 5)
+	if err != nil {
+		ctx.Reply(u, fmt.Sprintf("Error - %s", err.Error()), nil)
+		return dispatcher.EndGroups
+	}
+	var sb strings.Builder
+	sb.WriteString("💳 Billing\n\n")
+	switch {
+	case user != nil && access.Authorized(user) && user.AuthorizedTill == nil:
+		sb.WriteString("Status: authorized, doesn't expire")
+	case user != nil && access.Authorized(user):
+		sb.WriteString(fmt.Sprintf("Status: active until %s (%s left)", user.AuthorizedTill.Format("2006-01-02 15:04"),
+			utils.TimeFormat(uint64(time.Until(*user.AuthorizedTill).Seconds()))))
+	default:
+		sb.WriteString("Status: no active subscription")
+	}
+	if len(payments) > 0 {
+		sb.WriteString("\n\nRecent payments:")
+		for _, payment := range payments {
+			sb.WriteString(fmt.Sprintf("\n• %s - %s plan, %s", payment.CreatedAt.Format("2006-01-02"), payment.Plan, billing.FormatPrice(payment.Amount)))
+		}
+	}
+	if billing.Enabled() {
+		sb.WriteString("\n\nSend /subscribe to buy or extend a subscription.")
+	}
+	ctx.Reply(u, sb.String(), nil)
+	return dispatcher.EndGroups
+}
+
+// paymentUpdate answers pre-checkout queries and applies successful payments.
+// Other updates are passed on to the next handlers.
+func paymentUpdate(ctx *ext.Context, u *ext.Update) error {
+	switch update := u.UpdateClass.(type) {
+	case *tg.UpdateBotPrecheckoutQuery:
+		request := &tg.MessagesSetBotPrecheckoutResultsRequest{QueryID: update.QueryID, Success: true}
+		if _, userID, err := billing.Check(update.Payload, update.Currency, update.TotalAmount); err != nil || userID != update.UserID {
+			request.Success = false
+			request.Error = "This plan is not available anymore, please send /subscribe again."
+		}
+		if _, err := ctx.Raw.MessagesSetBotPrecheckoutResults(ctx, request); err != nil {
+			utils.Logger.Error("Failed to answer pre-checkout query", zap.Error(err))
+		}
+		return dispatcher.EndGroups
+	case *tg.UpdateNewMessage:
+		service, ok := update.Message.(*tg.MessageService)
+		if !ok {
+			return nil
+		}
+		action, ok := service.Action.(*tg.MessageActionPaymentSentMe)
+		if !ok {
+			return nil
+		}
+		peer, ok := service.PeerID.(*tg.PeerUser)
+		if !ok {
+			return dispatcher.EndGroups
+		}
+		payment, err := billing.Apply(peer.UserID, action)
+		if errors.Is(err, database.ErrDuplicatePayment) {
+			return dispatcher.EndGroups
+		}
+		if err != nil {
+			utils.Logger.Error("Failed to apply payment", zap.Error(err), zap.Int64("userID", peer.UserID), zap.String("chargeID", action.Charge.ID))
+			ctx.Reply(u, "❌ Your payment was received but couldn't be applied. Please contact an admin.", nil)
+			return dispatcher.EndGroups
+		}
+		ctx.Reply(u, fmt.Sprintf("✅ Thank you! You can use the bot until %s.", payment.PaidUntil.Format("2006-01-02 15:04")), nil)
+		beginOnboarding(ctx, peer.UserID)
+		return dispatcher.EndGroups
+	}
+	return nil
+}
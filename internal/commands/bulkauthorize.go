@@ -0,0 +1,310 @@
+package commands
+
+import (
+	"EverythingSuckz/fsb/internal/crash"
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/utils"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/dispatcher/handlers"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/gotd/td/tg"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	// bulkMaxFileSize is the largest list /bulkauthorize reads
+	bulkMaxFileSize = 2 * 1024 * 1024
+	// bulkProgressInterval is the minimum time between two updates of the progress reply
+	bulkProgressInterval = 3 * time.Second
+	// bulkReportedFailures is the number of failed lines listed in the final report
+	bulkReportedFailures = 20
+)
+
+const bulkUsage = "Usage: send a CSV or text file with /bulkauthorize as caption, reply /bulkauthorize to one, or list the users after the command.\n\n" +
+	"/bulkauthorize --dry-run shows what would change without changing anything.\n\n" +
+	"One user per line: user_id[,role][,period]\n" +
+	"Roles: user (authorize, default), suspended (suspend), none (deauthorize)\n" +
+	"Periods look like 12h, 30d or 2w, users are authorized forever without one."
+
+// bulkEntry is a line of a /bulkauthorize list
+type bulkEntry struct {
+	line   int
+	userID int64
+	role   string
+	period time.Duration
+}
+
+func (m *command) LoadBulkAuthorize(dispatcher dispatcher.Dispatcher) {
+	log := m.log.Named("bulkauthorize")
+	defer log.Sugar().Info("Loaded")
+	dispatcher.AddHandler(handlers.NewCommand("bulkauthorize", bulkAuthorize))
+}
+
+// bulkAuthorize authorizes, suspends or deauthorizes the users of a list in the background,
+// reporting the progress in its reply, to migrate large communities at once
+func bulkAuthorize(ctx *ext.Context, u *ext.Update) error {
+	_, userRepository, ok := userAdminCommand(ctx, u, "")
+	if !ok {
+		return dispatcher.EndGroups
+	}
+	text, dryRun := cutDryRun(u.EffectiveMessage.Text)
+	text, err := bulkList(ctx, u, text)
+	if err != nil {
+		ctx.Reply(u, fmt.Sprintf("Error - %s", err.Error()), nil)
+		return dispatcher.EndGroups
+	}
+	entries, failures := parseBulkList(text)
+	if len(entries) == 0 && len(failures) == 0 {
+		ctx.Reply(u, bulkUsage, nil)
+		return dispatcher.EndGroups
+	}
+	chatId := u.EffectiveChat().GetID()
+	if dryRun {
+		go func() {
+			defer crash.Recover("bulkauthorize")
+			summary, lines := bulkDryRun(userRepository, entries, failures)
+			if err := replyDryRun(ctx, chatId, u.EffectiveMessage.ID, summary, lines, "bulkauthorize-dry-run.txt"); err != nil {
+				utils.Logger.Error("Failed to send dry run report", zap.Error(err))
+			}
+		}()
+		return dispatcher.EndGroups
+	}
+	demotions := 0
+	for _, entry := range entries {
+		if entry.role != "user" {
+			demotions++
+		}
+	}
+	if demotions == 0 {
+		runBulkAuthorize(ctx, u, userRepository, entries, failures)
+		return dispatcher.EndGroups
+	}
+	question := fmt.Sprintf("🚫 Suspend or deauthorize %d of the %d users in the list?", demotions, len(entries))
+	confirm(ctx, u, question, func(ctx *ext.Context) string {
+		userRepository := database.GetUserRepository().WithContext(ctx)
+		if userRepository == nil {
+			return "❌ User database is not available at the moment."
+		}
+		runBulkAuthorize(ctx, u, userRepository, entries, failures)
+		return fmt.Sprintf("✅ Confirmed, processing %d users.", len(entries))
+	})
+	return dispatcher.EndGroups
+}
+
+// runBulkAuthorize applies the entries in the background, reporting the progress in a reply to the command
+func runBulkAuthorize(ctx *ext.Context, u *ext.Update, userRepository *database.UserRepository, entries []bulkEntry, failures []string) {
+	chatId := u.EffectiveChat().GetID()
+	status, err := ctx.Reply(u, fmt.Sprintf("⏳ Processing %d users...", len(entries)), &ext.ReplyOpts{ReplyToMessageId: u.EffectiveMessage.ID})
+	if err != nil {
+		return
+	}
+	go func() {
+		defer crash.Recover("bulkauthorize")
+		succeeded := 0
+		lastProgress := time.Now()
+		for i, entry := range entries {
+			if err := applyBulkEntry(userRepository, entry); err != nil {
+				failures = append(failures, fmt.Sprintf("line %d (%d): %s", entry.line, entry.userID, err))
+			} else {
+				succeeded++
+			}
+			if time.Since(lastProgress) >= bulkProgressInterval {
+				lastProgress = time.Now()
+				ctx.EditMessage(chatId, &tg.MessagesEditMessageRequest{
+					ID:      status.ID,
+					Message: fmt.Sprintf("⏳ Processed %d of %d users...", i+1, len(entries)),
+				})
+			}
+		}
+		utils.Logger.Info("Bulk authorization done",
+			zap.Int64("adminID", chatId),
+			zap.Int("succeeded", succeeded),
+			zap.Int("failed", len(failures)))
+		ctx.EditMessage(chatId, &tg.MessagesEditMessageRequest{
+			ID:      status.ID,
+			Message: bulkReport(succeeded, failures),
+		})
+	}()
+}
+
+// bulkList returns the text of the file attached to or replied with the command, or the lines after it
+func bulkList(ctx *ext.Context, u *ext.Update, text string) (string, error) {
+	if u.EffectiveMessage.Media != nil {
+		return readBulkFile(ctx, u.EffectiveMessage.Media)
+	}
+	if header, ok := u.EffectiveMessage.ReplyTo.(*tg.MessageReplyHeader); ok && header.ReplyToMsgID != 0 {
+		messages, err := ctx.GetMessages(u.EffectiveChat().GetID(), []tg.InputMessageClass{&tg.InputMessageID{ID: header.ReplyToMsgID}})
+		if err != nil {
+			return "", err
+		}
+		if len(messages) > 0 {
+			if message, ok := messages[0].(*tg.Message); ok && message.Media != nil {
+				return readBulkFile(ctx, message.Media)
+			}
+		}
+	}
+	if i := strings.IndexAny(text, " \n"); i >= 0 {
+		return text[i+1:], nil
+	}
+	return "", nil
+}
+
+func readBulkFile(ctx *ext.Context, media tg.MessageMediaClass) (string, error) {
+	file, err := utils.FileFromMedia(media)
+	if err != nil {
+		return "", err
+	}
+	if file.FileSize == 0 {
+		return "", errors.New("please send the list as a CSV or text file")
+	}
+	if file.FileSize > bulkMaxFileSize {
+		return "", fmt.Errorf("the list is too large, the maximum is %s", utils.FormatFileSizeShort(bulkMaxFileSize))
+	}
+	reader, err := utils.NewTelegramReader(ctx, ctx.Raw, file.Location, 0, file.FileSize-1, file.FileSize)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// parseBulkList parses the lines of a list, skipping empty lines and a header row.
+// It returns the entries and the lines that couldn't be parsed.
+func parseBulkList(text string) ([]bulkEntry, []string) {
+	var entries []bulkEntry
+	var failures []string
+	header := true
+	for i, line := range strings.Split(text, "\n") {
+		fields := strings.FieldsFunc(line, func(r rune) bool {
+			return r == ',' || r == ';' || r == '\t' || r == ' ' || r == '\r'
+		})
+		if len(fields) == 0 {
+			continue
+		}
+		userID, err := strconv.ParseInt(fields[0], 10, 64)
+		first := header
+		header = false
+		if err != nil {
+			if first {
+				continue
+			}
+			failures = append(failures, fmt.Sprintf("line %d: invalid user ID %q", i+1, fields[0]))
+			continue
+		}
+		entry := bulkEntry{line: i + 1, userID: userID, role: "user"}
+		if err := parseBulkFields(&entry, fields[1:]); err != nil {
+			failures = append(failures, fmt.Sprintf("line %d: %s", i+1, err))
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, failures
+}
+
+// parseBulkFields sets the role and period of the entry from the fields after the user ID, in any order
+func parseBulkFields(entry *bulkEntry, fields []string) error {
+	for _, field := range fields {
+		switch role := strings.ToLower(field); role {
+		case "user", "suspended", "none":
+			entry.role = role
+		default:
+			period, err := utils.ParsePeriod(field)
+			if err != nil {
+				return fmt.Errorf("invalid role or period %q", field)
+			}
+			entry.period = period
+		}
+	}
+	return nil
+}
+
+func applyBulkEntry(userRepository *database.UserRepository, entry bulkEntry) error {
+	switch entry.role {
+	case "suspended":
+		err := userRepository.SetSuspended(entry.userID, true)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("user not found")
+		}
+		return err
+	case "none":
+		return userRepository.Deauthorize(entry.userID)
+	}
+	var until *time.Time
+	if entry.period > 0 {
+		end := time.Now().Add(entry.period)
+		until = &end
+	}
+	return userRepository.Authorize(entry.userID, until)
+}
+
+// bulkDryRun describes what applying the entries would do to every user, without changing them
+func bulkDryRun(userRepository *database.UserRepository, entries []bulkEntry, failures []string) (string, []string) {
+	counts := make(map[string]int)
+	lines := make([]string, 0, len(entries)+len(failures))
+	for _, entry := range entries {
+		user, err := userRepository.Get(entry.userID)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("line %d (%d): %s", entry.line, entry.userID, err))
+			continue
+		}
+		name, current := fmt.Sprintf("[%d]", entry.userID), "unknown"
+		if user != nil {
+			name, current = formatUser(*user), userStatus(*user)
+			if strings.HasPrefix(current, "· ") {
+				current = "no access"
+			}
+		}
+		var action string
+		switch entry.role {
+		case "suspended":
+			if user == nil {
+				failures = append(failures, fmt.Sprintf("line %d (%d): user not found", entry.line, entry.userID))
+				continue
+			}
+			action = "suspend"
+		case "none":
+			action = "deauthorize"
+		default:
+			action = "authorize"
+			if entry.period > 0 {
+				action += " for " + formatWait(entry.period)
+			}
+		}
+		counts[entry.role]++
+		lines = append(lines, fmt.Sprintf("line %d: %s, %s → %s", entry.line, name, current, action))
+	}
+	for _, failure := range failures {
+		lines = append(lines, "❌ "+failure)
+	}
+	summary := fmt.Sprintf("Would authorize %d, suspend %d and deauthorize %d users, %d lines would fail.",
+		counts["user"], counts["suspended"], counts["none"], len(failures))
+	return summary, lines
+}
+
+func bulkReport(succeeded int, failures []string) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("✅ Bulk authorization done: %d succeeded, %d failed.", succeeded, len(failures)))
+	if len(failures) > 0 {
+		sb.WriteString("\n\nFailures:\n")
+		for i, failure := range failures {
+			if i == bulkReportedFailures {
+				sb.WriteString(fmt.Sprintf("…and %d more\n", len(failures)-bulkReportedFailures))
+				break
+			}
+			sb.WriteString("• " + failure + "\n")
+		}
+	}
+	return sb.String()
+}
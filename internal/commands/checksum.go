@@ -0,0 +1,66 @@
+package commands
+
+import (
+	"EverythingSuckz/fsb/internal/downloads"
+	"EverythingSuckz/fsb/internal/utils"
+	"fmt"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/dispatcher/handlers"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/celestix/gotgproto/storage"
+	"github.com/gotd/td/tg"
+)
+
+func (m *command) LoadChecksum(dispatcher dispatcher.Dispatcher) {
+	log := m.log.Named("checksum")
+	defer log.Sugar().Info("Loaded")
+	dispatcher.AddHandler(handlers.NewCommand("checksum", checksum))
+}
+
+// checksum replies with the SHA-256 of the replied file. Unknown checksums are computed by
+// downloading the file, the reply is edited once it's done.
+func checksum(ctx *ext.Context, u *ext.Update) error {
+	chatId := u.EffectiveChat().GetID()
+	peerChatId := ctx.PeerStorage.GetPeerById(chatId)
+	if peerChatId.Type != int(storage.TypeUser) {
+		return dispatcher.EndGroups
+	}
+	trackUser(u)
+	if !isAllowed(ctx, chatId) {
+		ctx.Reply(u, "You are not allowed to use this bot.", nil)
+		return dispatcher.EndGroups
+	}
+	link, err := repliedLink(u)
+	if err != nil {
+		ctx.Reply(u, fmt.Sprintf("Error - %s", err.Error()), nil)
+		return dispatcher.EndGroups
+	}
+	if link.FileSize == 0 {
+		ctx.Reply(u, "Photos have no checksum, send them as files.", nil)
+		return dispatcher.EndGroups
+	}
+	if link.Checksum != "" {
+		ctx.Reply(u, checksumText(link.FileName, link.FileSize, link.Checksum), nil)
+		return dispatcher.EndGroups
+	}
+	reply, err := ctx.Reply(u, fmt.Sprintf("⏳ Computing the SHA-256 of %s, this downloads the whole file.", link.FileName),
+		&ext.ReplyOpts{ReplyToMessageId: u.EffectiveMessage.ID})
+	if err != nil {
+		return dispatcher.EndGroups
+	}
+	fileName, fileSize := link.FileName, link.FileSize
+	downloads.Checksum(link, func(sum string, err error) {
+		text := checksumText(fileName, fileSize, sum)
+		if err != nil {
+			text = fmt.Sprintf("❌ Failed to compute the checksum of %s: %s", fileName, err.Error())
+		}
+		ctx.EditMessage(chatId, &tg.MessagesEditMessageRequest{ID: reply.ID, Message: text})
+	})
+	return dispatcher.EndGroups
+}
+
+func checksumText(fileName string, fileSize int64, sum string) string {
+	return fmt.Sprintf("🔐 %s (%s)\n\nSHA-256: %s\n\nCompare it with `sha256sum` or `Get-FileHash` on the downloaded file.",
+		fileName, utils.FormatFileSizeShort(fileSize), sum)
+}
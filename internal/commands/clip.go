@@ -0,0 +1,91 @@
+package commands
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/budget"
+	"EverythingSuckz/fsb/internal/crash"
+	"EverythingSuckz/fsb/internal/media"
+	"EverythingSuckz/fsb/internal/utils"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/dispatcher/handlers"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/celestix/gotgproto/storage"
+	"go.uber.org/zap"
+)
+
+func (m *command) LoadClip(dispatcher dispatcher.Dispatcher) {
+	log := m.log.Named("clip")
+	defer log.Sugar().Info("Loaded")
+	dispatcher.AddHandler(handlers.NewCommand("clip", clip))
+}
+
+func clip(ctx *ext.Context, u *ext.Update) error {
+	chatId := u.EffectiveChat().GetID()
+	peerChatId := ctx.PeerStorage.GetPeerById(chatId)
+	if peerChatId.Type != int(storage.TypeUser) {
+		return dispatcher.EndGroups
+	}
+	trackUser(u)
+	if !isAllowed(ctx, chatId) {
+		ctx.Reply(u, "You are not allowed to use this bot.", nil)
+		return dispatcher.EndGroups
+	}
+	if !media.Enabled() {
+		ctx.Reply(u, "Clips are not enabled on this bot.", nil)
+		return dispatcher.EndGroups
+	}
+	args := u.Args()
+	if len(args) < 3 {
+		ctx.Reply(u, "Usage: /clip <start> <end>, eg. /clip 1:30 2:45", nil)
+		return dispatcher.EndGroups
+	}
+	start, errStart := utils.ParseTimestamp(args[1])
+	end, errEnd := utils.ParseTimestamp(args[2])
+	if errStart != nil || errEnd != nil || end <= start {
+		ctx.Reply(u, "Usage: /clip <start> <end>, eg. /clip 1:30 2:45. The end must be after the start.", nil)
+		return dispatcher.EndGroups
+	}
+	if err := media.CheckClipRange(start, end); err != nil {
+		ctx.Reply(u, fmt.Sprintf("Clips can be at most %s long.", strings.TrimSuffix(utils.TimeFormat(uint64(config.ValueOf.ClipMaxDuration)), ", ")), nil)
+		return dispatcher.EndGroups
+	}
+	link, err := repliedLink(u)
+	if err != nil {
+		ctx.Reply(u, fmt.Sprintf("Error - %s", err.Error()), nil)
+		return dispatcher.EndGroups
+	}
+	if !strings.Contains(link.MimeType, "video") && !strings.Contains(link.MimeType, "audio") {
+		ctx.Reply(u, "Clips can only be created from videos and audio files.", nil)
+		return dispatcher.EndGroups
+	}
+	status, err := ctx.Reply(u, "⏳ Creating clip...", &ext.ReplyOpts{ReplyToMessageId: u.EffectiveMessage.ID})
+	if err != nil {
+		return dispatcher.EndGroups
+	}
+	go func() {
+		defer crash.Recover("clip")
+		defer ctx.DeleteMessages(chatId, []int{status.ID})
+		jobCtx, cancel := context.WithTimeout(ctx, 3*time.Minute)
+		defer cancel()
+		_, err := media.Clip(jobCtx, utils.InternalStreamURL(link.TenantID, link.MessageID, link.Hash), link.StorageKey(), start, end)
+		if errors.Is(err, budget.ErrExhausted) {
+			ctx.Reply(u, "❌ The server is busy, try again in a moment.", nil)
+			return
+		}
+		if err != nil {
+			utils.Logger.Error("Failed to create clip", zap.Error(err), zap.Int("messageID", link.MessageID))
+			ctx.Reply(u, "❌ Failed to create the clip.", nil)
+			return
+		}
+		message := fmt.Sprintf("✂️ Clip of %s (%s - %s):\n%s\n\n⏳ Link validity is 24 hours",
+			link.FileName, args[1], args[2], media.ClipURL(link.TenantID, link.MessageID, link.Hash, start, end))
+		ctx.Reply(u, message, &ext.ReplyOpts{ReplyToMessageId: u.EffectiveMessage.ID})
+	}()
+	return dispatcher.EndGroups
+}
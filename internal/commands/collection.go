@@ -0,0 +1,210 @@
+package commands
+
+import (
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/library"
+	"EverythingSuckz/fsb/internal/utils"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/dispatcher/handlers"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/celestix/gotgproto/storage"
+	"go.uber.org/zap"
+)
+
+const collectionUsage = `Usage:
+/collection - list your collections
+/collection create <name> - create a collection, Movies/Action is nested in Movies
+/collection delete <name> - delete a collection and the ones nested in it, the links stay
+/collection share <name> - get a link that opens the collection
+/collection unshare <name> - stop sharing it
+/addto <name> - add the replied file to a collection
+/removefrom <name> - remove the replied file from a collection`
+
+func (m *command) LoadCollection(dispatcher dispatcher.Dispatcher) {
+	log := m.log.Named("collection")
+	defer log.Sugar().Info("Loaded")
+	dispatcher.AddHandler(handlers.NewCommand("collection", collectionCommand))
+	dispatcher.AddHandler(handlers.NewCommand("addto", collectionItem))
+	dispatcher.AddHandler(handlers.NewCommand("removefrom", collectionItem))
+}
+
+// collectionCommand lists, creates, deletes and shares the collections of the user
+func collectionCommand(ctx *ext.Context, u *ext.Update) error {
+	chatId, collectionRepository, ok := collectionUser(ctx, u)
+	if !ok {
+		return dispatcher.EndGroups
+	}
+	args := u.Args()
+	if len(args) < 2 {
+		ctx.Reply(u, collectionList(chatId), nil)
+		return dispatcher.EndGroups
+	}
+	action := strings.ToLower(args[1])
+	if !slices.Contains([]string{"create", "delete", "share", "unshare"}, action) {
+		ctx.Reply(u, collectionUsage, nil)
+		return dispatcher.EndGroups
+	}
+	names, err := library.CollectionNames(strings.TrimSpace(argsAfter(u.EffectiveMessage.Text, 2)))
+	if err != nil {
+		ctx.Reply(u, fmt.Sprintf("Error - %s\n\n%s", err.Error(), collectionUsage), nil)
+		return dispatcher.EndGroups
+	}
+	name := names[len(names)-1]
+	if action == "create" {
+		if err := collectionRepository.Create(chatId, names); err != nil {
+			utils.Logger.Error("Failed to create collection", zap.Error(err), zap.Int64("userID", chatId))
+			ctx.Reply(u, fmt.Sprintf("Error - %s", err.Error()), nil)
+			return dispatcher.EndGroups
+		}
+		ctx.Reply(u, fmt.Sprintf("📁 Created %s, reply to files with /addto %s to add them.", name, name), nil)
+		return dispatcher.EndGroups
+	}
+	collection, err := collectionRepository.Get(chatId, name)
+	if err != nil {
+		ctx.Reply(u, fmt.Sprintf("Error - %s", err.Error()), nil)
+		return dispatcher.EndGroups
+	}
+	if collection == nil {
+		ctx.Reply(u, fmt.Sprintf("You have no collection named %s, see /collection.", name), nil)
+		return dispatcher.EndGroups
+	}
+	switch action {
+	case "delete":
+		confirm(ctx, u, fmt.Sprintf("Delete the collection %s and the ones nested in it? The links keep working.", name), func(ctx *ext.Context) string {
+			if err := collectionRepository.Delete(collection); err != nil {
+				return fmt.Sprintf("Error - %s", err.Error())
+			}
+			return fmt.Sprintf("🗑 Deleted %s.", name)
+		})
+	case "share":
+		token := collection.ShareToken
+		if token == "" {
+			secret := make([]byte, 16)
+			rand.Read(secret)
+			token = hex.EncodeToString(secret)
+			if err := collectionRepository.SetShareToken(collection.ID, token); err != nil {
+				ctx.Reply(u, fmt.Sprintf("Error - %s", err.Error()), nil)
+				return dispatcher.EndGroups
+			}
+		}
+		ctx.Reply(u, fmt.Sprintf("🔗 Anyone with this link can open %s and the collections nested in it:\n%s\n\n"+
+			"Private links only open for you and the users you shared them with. /collection unshare %s turns the link off.",
+			name, utils.PublicURL("/c/"+token), name), nil)
+	case "unshare":
+		if err := collectionRepository.SetShareToken(collection.ID, ""); err != nil {
+			ctx.Reply(u, fmt.Sprintf("Error - %s", err.Error()), nil)
+			return dispatcher.EndGroups
+		}
+		ctx.Reply(u, fmt.Sprintf("The share link of %s doesn't work anymore.", name), nil)
+	}
+	return dispatcher.EndGroups
+}
+
+// collectionItem adds the replied file to a collection with /addto, or removes it with /removefrom
+func collectionItem(ctx *ext.Context, u *ext.Update) error {
+	chatId, collectionRepository, ok := collectionUser(ctx, u)
+	if !ok {
+		return dispatcher.EndGroups
+	}
+	adding := strings.HasPrefix(u.EffectiveMessage.Text, "/addto")
+	link, err := repliedLink(u)
+	if err != nil {
+		ctx.Reply(u, fmt.Sprintf("Error - %s", err.Error()), nil)
+		return dispatcher.EndGroups
+	}
+	names, err := library.CollectionNames(strings.TrimSpace(argsAfter(u.EffectiveMessage.Text, 1)))
+	if err != nil {
+		ctx.Reply(u, fmt.Sprintf("Error - %s\n\n%s", err.Error(), collectionUsage), nil)
+		return dispatcher.EndGroups
+	}
+	name := names[len(names)-1]
+	collection, err := collectionRepository.Get(chatId, name)
+	if err != nil {
+		ctx.Reply(u, fmt.Sprintf("Error - %s", err.Error()), nil)
+		return dispatcher.EndGroups
+	}
+	if collection == nil {
+		ctx.Reply(u, fmt.Sprintf("You have no collection named %s, create it with /collection create %s", name, name), nil)
+		return dispatcher.EndGroups
+	}
+	var changed bool
+	if adding {
+		changed, err = collectionRepository.AddLink(collection.ID, link.TenantID, link.MessageID)
+	} else {
+		changed, err = collectionRepository.RemoveLink(collection.ID, link.TenantID, link.MessageID)
+	}
+	if err != nil {
+		utils.Logger.Error("Failed to change collection", zap.Error(err), zap.Uint("collectionID", collection.ID))
+		ctx.Reply(u, fmt.Sprintf("Error - %s", err.Error()), nil)
+		return dispatcher.EndGroups
+	}
+	switch {
+	case adding && changed:
+		ctx.Reply(u, fmt.Sprintf("📁 Added %s to %s.", link.FileName, name), nil)
+	case adding:
+		ctx.Reply(u, fmt.Sprintf("%s is in %s already.", link.FileName, name), nil)
+	case changed:
+		ctx.Reply(u, fmt.Sprintf("Removed %s from %s.", link.FileName, name), nil)
+	default:
+		ctx.Reply(u, fmt.Sprintf("%s isn't in %s.", link.FileName, name), nil)
+	}
+	return dispatcher.EndGroups
+}
+
+// collectionUser checks that the collection commands were sent by an allowed user in a
+// private chat while the database is available
+func collectionUser(ctx *ext.Context, u *ext.Update) (int64, *database.CollectionRepository, bool) {
+	chatId := u.EffectiveChat().GetID()
+	peerChatId := ctx.PeerStorage.GetPeerById(chatId)
+	if peerChatId.Type != int(storage.TypeUser) {
+		return 0, nil, false
+	}
+	trackUser(u)
+	if !isAllowed(ctx, chatId) {
+		ctx.Reply(u, "You are not allowed to use this bot.", nil)
+		return 0, nil, false
+	}
+	collectionRepository := database.GetCollectionRepository()
+	if collectionRepository == nil {
+		ctx.Reply(u, "❌ Collection database is not available at the moment.", nil)
+		return 0, nil, false
+	}
+	return chatId, collectionRepository, true
+}
+
+// collectionList describes the collections of the user, nested ones indented
+func collectionList(userID int64) string {
+	collections, err := database.GetCollectionRepository().List(userID)
+	if err != nil {
+		return fmt.Sprintf("Error - %s", err.Error())
+	}
+	if len(collections) == 0 {
+		return "You have no collections yet.\n\n" + collectionUsage
+	}
+	ids := make([]uint, 0, len(collections))
+	for _, collection := range collections {
+		ids = append(ids, collection.ID)
+	}
+	counts, err := database.GetCollectionRepository().CountLinks(ids)
+	if err != nil {
+		return fmt.Sprintf("Error - %s", err.Error())
+	}
+	var text strings.Builder
+	text.WriteString("📁 Your collections\n")
+	for _, collection := range collections {
+		depth := strings.Count(collection.Name, "/")
+		baseName := collection.Name[strings.LastIndex(collection.Name, "/")+1:]
+		fmt.Fprintf(&text, "\n%s%s (%d)", strings.Repeat("    ", depth), baseName, counts[collection.ID])
+		if collection.ShareToken != "" {
+			text.WriteString(" 🔗")
+		}
+	}
+	text.WriteString("\n\nThey're in the player and the WebDAV drive too. Send /collection help for the commands.")
+	return text.String()
+}
@@ -1,9 +1,17 @@
 package commands
 
 import (
+	"EverythingSuckz/fsb/internal/access"
+	"EverythingSuckz/fsb/internal/activity"
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/types"
+	"EverythingSuckz/fsb/internal/utils"
+	"errors"
 	"reflect"
 
 	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/gotd/td/tg"
 	"go.uber.org/zap"
 )
 
@@ -20,3 +28,45 @@ func Load(log *zap.Logger, dispatcher dispatcher.Dispatcher) {
 		Type.Method(i).Func.Call([]reflect.Value{Value, reflect.ValueOf(dispatcher)})
 	}
 }
+
+// isAllowed reports whether the user may use the bot, see access.Allowed
+func isAllowed(ctx *ext.Context, userID int64) bool {
+	return access.Allowed(ctx, userID, utils.IsAdmin(ctx, ctx.Raw, ctx.PeerStorage, userID))
+}
+
+// trackUser stores the user responsible for the update so that admins can moderate them
+func trackUser(u *ext.Update) {
+	user := u.EffectiveUser()
+	// in groups the update has the other users the message mentions too
+	if message := u.EffectiveMessage; message != nil && u.Entities != nil {
+		if from, ok := message.FromID.(*tg.PeerUser); ok {
+			user = u.Entities.Users[from.UserID]
+		}
+	}
+	userRepository := database.GetUserRepository()
+	if user == nil || userRepository == nil {
+		return
+	}
+	activity.Seen(user.ID)
+	if err := userRepository.Touch(user.ID, user.Username, user.FirstName); err != nil {
+		utils.Logger.Error("Failed to store user", zap.Error(err), zap.Int64("userID", user.ID))
+	}
+}
+
+// repliedLink returns the link generated for the message the update replies to.
+// Both the user's original media message and the bot's link reply are accepted.
+func repliedLink(u *ext.Update) (*types.Link, error) {
+	header, ok := u.EffectiveMessage.ReplyTo.(*tg.MessageReplyHeader)
+	if !ok || header.ReplyToMsgID == 0 {
+		return nil, errors.New("please reply to a media message you have generated a link for")
+	}
+	linkRepository := database.GetLinkRepository()
+	if linkRepository == nil {
+		return nil, errors.New("link database is not available at the moment")
+	}
+	link, err := linkRepository.FindByUserMessage(u.EffectiveChat().GetID(), header.ReplyToMsgID)
+	if err != nil {
+		return nil, errors.New("no link found for this message, send the file again to generate one")
+	}
+	return link, nil
+}
@@ -0,0 +1,105 @@
+package commands
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/dispatcher/handlers"
+	"github.com/celestix/gotgproto/dispatcher/handlers/filters"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/celestix/gotgproto/functions"
+	"github.com/gotd/td/tg"
+)
+
+// confirmTimeout is how long a destructive action waits for its confirmation
+const confirmTimeout = time.Minute
+
+// pendingAction is a destructive action waiting for the confirmation of the user who requested it
+type pendingAction struct {
+	userID  int64
+	expires time.Time
+	run     func(ctx *ext.Context) string
+}
+
+var pendingActions = struct {
+	sync.Mutex
+	byToken map[string]*pendingAction
+}{byToken: make(map[string]*pendingAction)}
+
+func (m *command) LoadConfirm(dispatcher dispatcher.Dispatcher) {
+	log := m.log.Named("confirm")
+	defer log.Sugar().Info("Loaded")
+	dispatcher.AddHandler(handlers.NewCallbackQuery(filters.CallbackQuery.Prefix("confirm:"), confirmCallback))
+}
+
+// confirm asks the sender to confirm a destructive action, like removing a user, with inline
+// buttons, so that a mistyped ID doesn't hit the wrong user. The action runs if it's confirmed
+// within confirmTimeout and returns the message that replaces the question.
+func confirm(ctx *ext.Context, u *ext.Update, question string, run func(ctx *ext.Context) string) {
+	token := make([]byte, 8)
+	rand.Read(token)
+	key := hex.EncodeToString(token)
+	now := time.Now()
+	pendingActions.Lock()
+	for k, action := range pendingActions.byToken {
+		if now.After(action.expires) {
+			delete(pendingActions.byToken, k)
+		}
+	}
+	pendingActions.byToken[key] = &pendingAction{
+		userID:  u.EffectiveChat().GetID(),
+		expires: now.Add(confirmTimeout),
+		run:     run,
+	}
+	pendingActions.Unlock()
+	ctx.Reply(u, question+"\n\n⚠️ Please confirm within a minute.", &ext.ReplyOpts{Markup: &tg.ReplyInlineMarkup{
+		Rows: []tg.KeyboardButtonRow{{
+			Buttons: []tg.KeyboardButtonClass{
+				&tg.KeyboardButtonCallback{Text: "✅ Confirm", Data: []byte("confirm:yes:" + key)},
+				&tg.KeyboardButtonCallback{Text: "✖️ Cancel", Data: []byte("confirm:no:" + key)},
+			},
+		}},
+	}})
+}
+
+// confirmCallback runs or cancels the action of the pressed button
+func confirmCallback(ctx *ext.Context, u *ext.Update) error {
+	query := u.CallbackQuery
+	fields := strings.Split(string(query.Data), ":")
+	if len(fields) != 3 {
+		return dispatcher.EndGroups
+	}
+	pendingActions.Lock()
+	action, ok := pendingActions.byToken[fields[2]]
+	if ok && action.userID == query.UserID {
+		delete(pendingActions.byToken, fields[2])
+	}
+	pendingActions.Unlock()
+	if ok && action.userID != query.UserID {
+		ctx.AnswerCallback(&tg.MessagesSetBotCallbackAnswerRequest{
+			QueryID: query.QueryID,
+			Message: "Only the user who sent the command can confirm it.",
+			Alert:   true,
+		})
+		return dispatcher.EndGroups
+	}
+	ctx.AnswerCallback(&tg.MessagesSetBotCallbackAnswerRequest{QueryID: query.QueryID})
+	var message string
+	switch {
+	case !ok || time.Now().After(action.expires):
+		message = "⌛ This confirmation has expired, send the command again."
+	case fields[1] == "yes":
+		message = action.run(ctx)
+	default:
+		message = "✖️ Cancelled."
+	}
+	ctx.EditMessage(functions.GetChatIdFromPeer(query.Peer), &tg.MessagesEditMessageRequest{
+		ID:      query.MsgID,
+		Message: message,
+	})
+	return dispatcher.EndGroups
+}
@@ -0,0 +1,151 @@
+package commands
+
+import (
+	"EverythingSuckz/fsb/internal/cooldown"
+	"EverythingSuckz/fsb/internal/utils"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/dispatcher/handlers"
+	"github.com/celestix/gotgproto/dispatcher/handlers/filters"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/celestix/gotgproto/storage"
+	"go.uber.org/zap"
+)
+
+// cooldownGroup runs before the handlers of all commands, which are in the default group 0
+const cooldownGroup = -1
+
+const cooldownsUsage = `Usage:
+/cooldowns
+/cooldowns <user_id>
+/cooldowns reset <user_id>`
+
+func (m *command) LoadCooldowns(dispatcher dispatcher.Dispatcher) {
+	log := m.log.Named("cooldowns")
+	defer log.Sugar().Info("Loaded")
+	dispatcher.AddHandlerToGroup(handlers.NewMessage(filters.Message.Text, enforceCooldown), cooldownGroup)
+	dispatcher.AddHandler(handlers.NewCommand("cooldowns", cooldowns))
+}
+
+// enforceCooldown stops commands the user ran too often before they reach their handler.
+// Admins are never limited.
+func enforceCooldown(ctx *ext.Context, u *ext.Update) error {
+	text := u.EffectiveMessage.Text
+	user := u.EffectiveUser()
+	if !strings.HasPrefix(text, "/") || user == nil {
+		return nil
+	}
+	name, _, _ := strings.Cut(strings.Fields(text)[0], "@")
+	name = strings.ToLower(strings.TrimPrefix(name, "/"))
+	if utils.IsAdmin(ctx, ctx.Raw, ctx.PeerStorage, user.ID) {
+		return nil
+	}
+	wait, err := cooldown.Check(user.ID, name)
+	if err != nil {
+		// don't lock users out because the database failed
+		utils.Logger.Error("Failed to check cooldown", zap.Error(err), zap.Int64("userID", user.ID))
+		return nil
+	}
+	if wait > 0 {
+		ctx.Reply(u, fmt.Sprintf("⏳ You're using /%s too often. Try again in %s.", name, formatWait(wait)), nil)
+		return dispatcher.EndGroups
+	}
+	return nil
+}
+
+// cooldowns lets bot admins inspect and reset the command cooldowns
+func cooldowns(ctx *ext.Context, u *ext.Update) error {
+	chatId := u.EffectiveChat().GetID()
+	peerChatId := ctx.PeerStorage.GetPeerById(chatId)
+	if peerChatId.Type != int(storage.TypeUser) {
+		return dispatcher.EndGroups
+	}
+	if !utils.IsAdmin(ctx, ctx.Raw, ctx.PeerStorage, chatId) {
+		ctx.Reply(u, "This command is only available to admins.", nil)
+		return dispatcher.EndGroups
+	}
+	if len(cooldown.Rules()) == 0 {
+		ctx.Reply(u, "No cooldowns are configured, set COMMAND_COOLDOWNS to add some.", nil)
+		return dispatcher.EndGroups
+	}
+	args := u.Args()
+	var message string
+	var err error
+	switch {
+	case len(args) == 1:
+		message, err = listCooldowns()
+	case len(args) == 2:
+		message, err = userCooldowns(args[1])
+	case len(args) == 3 && args[1] == "reset":
+		message, err = resetCooldowns(args[2])
+	default:
+		message = cooldownsUsage
+	}
+	if err != nil {
+		utils.Logger.Error("Failed to inspect cooldowns", zap.Error(err))
+		ctx.Reply(u, fmt.Sprintf("Error - %s", err.Error()), nil)
+		return dispatcher.EndGroups
+	}
+	ctx.Reply(u, message, nil)
+	return dispatcher.EndGroups
+}
+
+func listCooldowns() (string, error) {
+	var sb strings.Builder
+	sb.WriteString("⏳ Cooldowns\n")
+	for _, rule := range cooldown.Rules() {
+		sb.WriteString(fmt.Sprintf("\n/%s: %d per %s\n", rule.Command, rule.Count, formatWait(rule.Period)))
+		blocked, err := cooldown.ListBlocked(rule)
+		if err != nil {
+			return "", err
+		}
+		if len(blocked) == 0 {
+			sb.WriteString("No users are cooling down.\n")
+		}
+		for _, usage := range blocked {
+			sb.WriteString(fmt.Sprintf("• %d, %d uses, free in %s\n", usage.UserID, usage.Uses, formatWait(usage.Wait)))
+		}
+	}
+	return sb.String(), nil
+}
+
+func userCooldowns(id string) (string, error) {
+	userID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return "Invalid user ID.", nil
+	}
+	usages, err := cooldown.UserStatus(userID)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("⏳ Cooldowns of %d\n\n", userID))
+	for _, usage := range usages {
+		sb.WriteString(fmt.Sprintf("/%s: %d of %d per %s", usage.Rule.Command, usage.Uses, usage.Rule.Count, formatWait(usage.Rule.Period)))
+		if usage.Wait > 0 {
+			sb.WriteString(fmt.Sprintf(", free in %s", formatWait(usage.Wait)))
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String(), nil
+}
+
+func resetCooldowns(id string) (string, error) {
+	userID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return "Invalid user ID.", nil
+	}
+	if err := cooldown.Reset(userID); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("✅ Reset the cooldowns of %d.", userID), nil
+}
+
+// formatWait formats a duration rounded up to the second
+func formatWait(d time.Duration) string {
+	return strings.TrimSuffix(utils.TimeFormat(uint64((d+time.Second-1)/time.Second)), ", ")
+}
@@ -0,0 +1,53 @@
+package commands
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/tenant"
+	"EverythingSuckz/fsb/internal/utils"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/dispatcher/handlers"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/gotd/td/tg"
+	"go.uber.org/zap"
+)
+
+func (m *command) LoadDeleted(dispatcher dispatcher.Dispatcher) {
+	log := m.log.Named("deleted")
+	defer log.Sugar().Info("Loaded")
+	dispatcher.AddHandler(handlers.NewAnyUpdate(deletedMessages))
+}
+
+// deletedMessages marks the links of deleted messages as removed, so that they show why they
+// stopped working. Files deleted from a log channel can't be streamed anymore. Users deleting
+// the message they sent a file in also removes its link, unless MIRROR_MODE keeps a copy for them.
+func deletedMessages(ctx *ext.Context, u *ext.Update) error {
+	linkRepository := database.GetLinkRepository()
+	if linkRepository == nil {
+		return nil
+	}
+	var removed int64
+	var err error
+	switch update := u.UpdateClass.(type) {
+	case *tg.UpdateDeleteChannelMessages:
+		tenantIDs := tenant.WithLogChannel(update.ChannelID)
+		if len(tenantIDs) == 0 {
+			return nil
+		}
+		removed, err = linkRepository.MarkRemoved(tenantIDs, update.Messages)
+	case *tg.UpdateDeleteMessages:
+		if config.ValueOf.MirrorMode {
+			return nil
+		}
+		removed, err = linkRepository.MarkSourceRemoved(update.Messages)
+	default:
+		return nil
+	}
+	if err != nil {
+		utils.Logger.Error("Failed to mark links of deleted messages", zap.Error(err))
+	} else if removed > 0 {
+		utils.Logger.Info("Removed links of deleted messages", zap.Int64("links", removed))
+	}
+	return dispatcher.EndGroups
+}
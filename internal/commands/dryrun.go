@@ -0,0 +1,80 @@
+package commands
+
+import (
+	"EverythingSuckz/fsb/config"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/celestix/gotgproto/ext"
+	"github.com/gotd/td/telegram/uploader"
+	"github.com/gotd/td/tg"
+)
+
+const (
+	// dryRunFlag makes /schedule, /bulkauthorize and /regeneratelinks report what they would do without doing it
+	dryRunFlag = "--dry-run"
+	// dryRunListed is the number of users listed in a dry run reply, the full list is sent as a file
+	dryRunListed = 30
+	// telegramMessagesPerSecond is roughly how many messages Telegram lets a bot send per second
+	telegramMessagesPerSecond = 30
+)
+
+// cutDryRun removes the dry run flag if it's the first argument of the command
+func cutDryRun(text string) (string, bool) {
+	rest := argsAfter(text, 1)
+	command := text[:len(text)-len(rest)]
+	after, ok := strings.CutPrefix(strings.TrimLeft(rest, " \n"), dryRunFlag)
+	if !ok || (after != "" && after[0] != ' ' && after[0] != '\n') {
+		return text, false
+	}
+	return command + after, true
+}
+
+// estimateSendDuration returns how long sending a message to the recipients takes with the outbox limits
+func estimateSendDuration(recipients int) time.Duration {
+	perSecond := config.ValueOf.MessagesPerSecond
+	if perSecond <= 0 || perSecond > telegramMessagesPerSecond {
+		perSecond = telegramMessagesPerSecond
+	}
+	return time.Duration(recipients) * time.Second / time.Duration(perSecond)
+}
+
+// replyDryRun replies with the summary of a dry run and its first lines. Longer reports
+// are sent as a text file too, so that every affected user can be reviewed.
+func replyDryRun(ctx *ext.Context, chatId int64, replyTo int, summary string, lines []string, fileName string) error {
+	var sb strings.Builder
+	sb.WriteString("🧪 Dry run, nothing was changed.\n\n" + summary)
+	if len(lines) > 0 {
+		sb.WriteString("\n\n")
+	}
+	for i, line := range lines {
+		if i == dryRunListed {
+			sb.WriteString(fmt.Sprintf("…and %d more, see the attached file\n", len(lines)-dryRunListed))
+			break
+		}
+		sb.WriteString(line + "\n")
+	}
+	if _, err := ctx.SendMessage(chatId, &tg.MessagesSendMessageRequest{
+		Message: sb.String(),
+		ReplyTo: &tg.InputReplyToMessage{ReplyToMsgID: replyTo},
+	}); err != nil {
+		return err
+	}
+	if len(lines) <= dryRunListed {
+		return nil
+	}
+	file, err := uploader.NewUploader(ctx.Raw).FromBytes(ctx, fileName, []byte(strings.Join(lines, "\n")+"\n"))
+	if err != nil {
+		return err
+	}
+	_, err = ctx.SendMedia(chatId, &tg.MessagesSendMediaRequest{
+		Media: &tg.InputMediaUploadedDocument{
+			File:       file,
+			MimeType:   "text/plain",
+			Attributes: []tg.DocumentAttributeClass{&tg.DocumentAttributeFilename{FileName: fileName}},
+		},
+		ReplyTo: &tg.InputReplyToMessage{ReplyToMsgID: replyTo},
+	})
+	return err
+}
@@ -0,0 +1,104 @@
+package commands
+
+import (
+	"EverythingSuckz/fsb/internal/export"
+	"EverythingSuckz/fsb/internal/utils"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/dispatcher/handlers"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/celestix/gotgproto/storage"
+	"github.com/gotd/td/tg"
+	"go.uber.org/zap"
+)
+
+const exportUsage = `Usage: /export users|history|links [from:YYYY-MM-DD] [to:YYYY-MM-DD] [authorized] [json] [link]
+
+authorized - only users with a valid authorization and their links
+json - export JSON instead of CSV
+link - reply with a one-time download link instead of a file`
+
+func (m *command) LoadExport(dispatcher dispatcher.Dispatcher) {
+	log := m.log.Named("export")
+	defer log.Sugar().Info("Loaded")
+	dispatcher.AddHandler(handlers.NewCommand("export", exportCommand))
+}
+
+// exportCommand sends bot admins the users, the playback history or the links as a file
+func exportCommand(ctx *ext.Context, u *ext.Update) error {
+	chatId := u.EffectiveChat().GetID()
+	peerChatId := ctx.PeerStorage.GetPeerById(chatId)
+	if peerChatId.Type != int(storage.TypeUser) {
+		return dispatcher.EndGroups
+	}
+	if !utils.IsAdmin(ctx, ctx.Raw, ctx.PeerStorage, chatId) {
+		ctx.Reply(u, "This command is only available to admins.", nil)
+		return dispatcher.EndGroups
+	}
+	args := u.Args()
+	if len(args) < 2 {
+		ctx.Reply(u, exportUsage, nil)
+		return dispatcher.EndGroups
+	}
+	var from, to, format string
+	var authorizedOnly, asLink bool
+	for _, arg := range args[2:] {
+		switch {
+		case strings.HasPrefix(arg, "from:"):
+			from = strings.TrimPrefix(arg, "from:")
+		case strings.HasPrefix(arg, "to:"):
+			to = strings.TrimPrefix(arg, "to:")
+		case arg == "authorized":
+			authorizedOnly = true
+		case arg == "json" || arg == "csv":
+			format = arg
+		case arg == "link":
+			asLink = true
+		default:
+			ctx.Reply(u, exportUsage, nil)
+			return dispatcher.EndGroups
+		}
+	}
+	request, err := export.NewRequest(args[1], format, from, to, authorizedOnly)
+	if err != nil {
+		ctx.Reply(u, fmt.Sprintf("❌ %s\n\n%s", err.Error(), exportUsage), nil)
+		return dispatcher.EndGroups
+	}
+	if asLink {
+		token, err := export.NewDownload(request)
+		if err != nil {
+			ctx.Reply(u, fmt.Sprintf("Error - %s", err.Error()), nil)
+			return dispatcher.EndGroups
+		}
+		ctx.Reply(u, fmt.Sprintf("📦 Download the %s export once within an hour:\n%s", request.Kind, utils.PublicURL("/export/"+token)), nil)
+		return dispatcher.EndGroups
+	}
+	if err := sendExport(ctx, chatId, u.EffectiveMessage.ID, request); err != nil {
+		utils.Logger.Error("Failed to export", zap.String("kind", request.Kind), zap.Error(err))
+		ctx.Reply(u, fmt.Sprintf("Error - %s", err.Error()), nil)
+	}
+	return dispatcher.EndGroups
+}
+
+func sendExport(ctx *ext.Context, chatId int64, replyTo int, request *export.Request) error {
+	file, err := os.CreateTemp("", "export-*."+request.Format)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	rows, err := export.Write(file, request)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	attributes := []tg.DocumentAttributeClass{
+		&tg.DocumentAttributeFilename{FileName: request.FileName()},
+	}
+	caption := fmt.Sprintf("📦 Exported %d %s rows", rows, request.Kind)
+	return sendDocument(ctx, chatId, replyTo, file.Name(), request.ContentType(), attributes, caption)
+}
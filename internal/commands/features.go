@@ -0,0 +1,72 @@
+package commands
+
+import (
+	"EverythingSuckz/fsb/internal/features"
+	"EverythingSuckz/fsb/internal/utils"
+	"fmt"
+	"strings"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/dispatcher/handlers"
+	"github.com/celestix/gotgproto/ext"
+	"go.uber.org/zap"
+)
+
+const featuresUsage = `Usage: /features <feature> on|off|admins|<percent>%|default
+
+admins - only the users listed in ADMINS
+<percent>% - a stable share of the users, e.g. 10%
+default - go back to FEATURES`
+
+func (m *command) LoadFeatures(dispatcher dispatcher.Dispatcher) {
+	log := m.log.Named("features")
+	defer log.Sugar().Info("Loaded")
+	dispatcher.AddHandler(handlers.NewCommand("features", featuresCommand))
+}
+
+// featuresCommand shows the state of the feature flags and changes them
+func featuresCommand(ctx *ext.Context, u *ext.Update) error {
+	chatId, ok := settingsAdmin(ctx, u)
+	if !ok {
+		return dispatcher.EndGroups
+	}
+	args := u.Args()
+	switch len(args) {
+	case 1:
+		var sb strings.Builder
+		sb.WriteString("🚩 Features\n")
+		for _, definition := range features.Definitions() {
+			sb.WriteString(fmt.Sprintf("\n%s: %s\n%s", definition.Flag, describeFeature(definition.Flag), definition.Description))
+		}
+		sb.WriteString("\n\n" + featuresUsage)
+		ctx.Reply(u, sb.String(), nil)
+		return dispatcher.EndGroups
+	case 3:
+	default:
+		ctx.Reply(u, featuresUsage, nil)
+		return dispatcher.EndGroups
+	}
+	flag := features.Flag(strings.ToLower(args[1]))
+	var err error
+	if strings.EqualFold(args[2], "default") {
+		err = features.Reset(flag)
+	} else {
+		err = features.Set(flag, args[2], chatId)
+	}
+	if err != nil {
+		utils.Logger.Error("Failed to change feature flag", zap.String("flag", string(flag)), zap.Error(err))
+		ctx.Reply(u, fmt.Sprintf("❌ %s", err.Error()), nil)
+		return dispatcher.EndGroups
+	}
+	ctx.Reply(u, fmt.Sprintf("✅ %s is %s now.", flag, describeFeature(flag)), nil)
+	return dispatcher.EndGroups
+}
+
+// describeFeature returns the state of the flag and where it comes from
+func describeFeature(flag features.Flag) string {
+	state, stored := features.State(flag)
+	if stored {
+		return state + " (set at runtime)"
+	}
+	return state + " (from FEATURES)"
+}
@@ -0,0 +1,214 @@
+package commands
+
+import (
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/types"
+	"EverythingSuckz/fsb/internal/utils"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/dispatcher/handlers"
+	"github.com/celestix/gotgproto/dispatcher/handlers/filters"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/celestix/gotgproto/functions"
+	"github.com/celestix/gotgproto/storage"
+	"github.com/gotd/td/tg"
+	"go.uber.org/zap"
+)
+
+func (m *command) LoadFlagged(dispatcher dispatcher.Dispatcher) {
+	log := m.log.Named("flagged")
+	defer log.Sugar().Info("Loaded")
+	dispatcher.AddHandler(handlers.NewCommand("flagged", flagged))
+	dispatcher.AddHandler(handlers.NewCommand("unsuspend", unsuspend))
+	dispatcher.AddHandler(handlers.NewCallbackQuery(filters.CallbackQuery.Prefix("suspend:"), moderateCallback))
+	dispatcher.AddHandler(handlers.NewCallbackQuery(filters.CallbackQuery.Prefix("unflag:"), moderateCallback))
+}
+
+func flagged(ctx *ext.Context, u *ext.Update) error {
+	chatId := u.EffectiveChat().GetID()
+	peerChatId := ctx.PeerStorage.GetPeerById(chatId)
+	if peerChatId.Type != int(storage.TypeUser) {
+		return dispatcher.EndGroups
+	}
+	scope, ok := moderationScope(ctx, chatId)
+	if !ok {
+		ctx.Reply(u, "This command is only available to admins.", nil)
+		return dispatcher.EndGroups
+	}
+	message, markup, err := flaggedMessage(scope)
+	if err != nil {
+		utils.Logger.Error("Failed to list flagged users", zap.Error(err))
+		ctx.Reply(u, "❌ Failed to retrieve flagged users. Please try again later.", nil)
+		return dispatcher.EndGroups
+	}
+	ctx.Reply(u, message, &ext.ReplyOpts{Markup: markup})
+	return dispatcher.EndGroups
+}
+
+func unsuspend(ctx *ext.Context, u *ext.Update) error {
+	chatId := u.EffectiveChat().GetID()
+	peerChatId := ctx.PeerStorage.GetPeerById(chatId)
+	if peerChatId.Type != int(storage.TypeUser) {
+		return dispatcher.EndGroups
+	}
+	scope, ok := moderationScope(ctx, chatId)
+	if !ok {
+		ctx.Reply(u, "This command is only available to admins.", nil)
+		return dispatcher.EndGroups
+	}
+	args := u.Args()
+	if len(args) < 2 {
+		ctx.Reply(u, "Usage: /unsuspend <user_id>", nil)
+		return dispatcher.EndGroups
+	}
+	userID, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		ctx.Reply(u, "Invalid user ID.", nil)
+		return dispatcher.EndGroups
+	}
+	userRepository := database.GetUserRepository().WithContext(ctx)
+	if userRepository == nil {
+		ctx.Reply(u, "❌ User database is not available at the moment.", nil)
+		return dispatcher.EndGroups
+	}
+	if !inScope(scope, userID) {
+		ctx.Reply(u, "This user is not a member of your tenant.", nil)
+		return dispatcher.EndGroups
+	}
+	if err := userRepository.SetSuspended(userID, false); err != nil {
+		ctx.Reply(u, fmt.Sprintf("Error - %s", err.Error()), nil)
+		return dispatcher.EndGroups
+	}
+	ctx.Reply(u, fmt.Sprintf("✅ User %d is no longer suspended.", userID), nil)
+	return dispatcher.EndGroups
+}
+
+// moderateCallback handles the suspend and dismiss buttons of the /flagged list
+func moderateCallback(ctx *ext.Context, u *ext.Update) error {
+	query := u.CallbackQuery
+	scope, ok := moderationScope(ctx, query.UserID)
+	if !ok {
+		ctx.AnswerCallback(&tg.MessagesSetBotCallbackAnswerRequest{
+			QueryID: query.QueryID,
+			Message: "This action is only available to admins.",
+			Alert:   true,
+		})
+		return dispatcher.EndGroups
+	}
+	action, id, _ := strings.Cut(string(query.Data), ":")
+	userID, err := strconv.ParseInt(id, 10, 64)
+	userRepository := database.GetUserRepository().WithContext(ctx)
+	if err != nil || userRepository == nil || !inScope(scope, userID) {
+		return dispatcher.EndGroups
+	}
+	var answer string
+	switch action {
+	case "suspend":
+		err = userRepository.SetSuspended(userID, true)
+		if err == nil {
+			err = userRepository.Unflag(userID)
+		}
+		if err == nil {
+			_, err = userRepository.RevokeInviter(userID)
+		}
+		answer = fmt.Sprintf("User %d suspended", userID)
+	case "unflag":
+		err = userRepository.Unflag(userID)
+		answer = fmt.Sprintf("Flag of user %d dismissed", userID)
+	}
+	if err != nil {
+		utils.Logger.Error("Failed to moderate user", zap.Error(err), zap.Int64("userID", userID))
+		answer = fmt.Sprintf("Error - %s", err.Error())
+	}
+	ctx.AnswerCallback(&tg.MessagesSetBotCallbackAnswerRequest{
+		QueryID: query.QueryID,
+		Message: answer,
+	})
+	message, markup, err := flaggedMessage(scope)
+	if err != nil {
+		return dispatcher.EndGroups
+	}
+	ctx.EditMessage(functions.GetChatIdFromPeer(query.Peer), &tg.MessagesEditMessageRequest{
+		ID:          query.MsgID,
+		Message:     message,
+		ReplyMarkup: markup,
+	})
+	return dispatcher.EndGroups
+}
+
+// moderationScope reports whether the user may moderate other users. Bot admins moderate
+// everyone and get a nil scope, tenant admins only the members of the returned tenant.
+func moderationScope(ctx *ext.Context, userID int64) (*types.Tenant, bool) {
+	if utils.IsAdmin(ctx, ctx.Raw, ctx.PeerStorage, userID) {
+		return nil, true
+	}
+	if t := adminTenant(userID); t != nil {
+		return t, true
+	}
+	return nil, false
+}
+
+// inScope reports whether the user can be moderated within the scope
+func inScope(scope *types.Tenant, userID int64) bool {
+	if scope == nil {
+		return true
+	}
+	userRepository := database.GetUserRepository()
+	if userRepository == nil {
+		return false
+	}
+	user, err := userRepository.Get(userID)
+	return err == nil && user != nil && user.TenantID == scope.ID
+}
+
+func flaggedMessage(scope *types.Tenant) (string, tg.ReplyMarkupClass, error) {
+	userRepository := database.GetUserRepository()
+	if userRepository == nil {
+		return "", nil, fmt.Errorf("user database is not available")
+	}
+	var users []types.User
+	var err error
+	if scope == nil {
+		users, err = userRepository.ListFlagged(20)
+	} else {
+		users, err = userRepository.ListFlaggedInTenant(scope.ID, 20)
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	markup := &tg.ReplyInlineMarkup{Rows: []tg.KeyboardButtonRow{}}
+	if len(users) == 0 {
+		return "✅ No flagged users.", markup, nil
+	}
+	message := "🚩 Flagged users\n\n"
+	for _, user := range users {
+		message += fmt.Sprintf("• %s - %s (%s)", formatUser(user), user.FlagReason, user.FlaggedAt.Format("2006-01-02 15:04"))
+		if user.InvitedBy != 0 {
+			message += fmt.Sprintf(", invited by %d", user.InvitedBy)
+		}
+		message += "\n"
+		markup.Rows = append(markup.Rows, tg.KeyboardButtonRow{
+			Buttons: []tg.KeyboardButtonClass{
+				&tg.KeyboardButtonCallback{
+					Text: fmt.Sprintf("🚫 Suspend %d", user.ID),
+					Data: []byte(fmt.Sprintf("suspend:%d", user.ID)),
+				},
+				&tg.KeyboardButtonCallback{
+					Text: "✅ Dismiss",
+					Data: []byte(fmt.Sprintf("unflag:%d", user.ID)),
+				},
+			},
+		})
+	}
+	return message, markup, nil
+}
+
+func formatUser(user types.User) string {
+	if user.Username != "" {
+		return fmt.Sprintf("@%s [%d]", user.Username, user.ID)
+	}
+	return fmt.Sprintf("%s [%d]", user.FirstName, user.ID)
+}
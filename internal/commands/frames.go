@@ -0,0 +1,155 @@
+package commands
+
+import (
+	"EverythingSuckz/fsb/internal/crash"
+	"EverythingSuckz/fsb/internal/media"
+	"EverythingSuckz/fsb/internal/types"
+	"EverythingSuckz/fsb/internal/utils"
+	"context"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/dispatcher/handlers"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/celestix/gotgproto/storage"
+	"github.com/gotd/td/telegram/uploader"
+	"github.com/gotd/td/tg"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultFrameCount = 4
+	maxFrameCount     = 10
+)
+
+func (m *command) LoadFrames(dispatcher dispatcher.Dispatcher) {
+	log := m.log.Named("frames")
+	defer log.Sugar().Info("Loaded")
+	dispatcher.AddHandler(handlers.NewCommand("frames", frames))
+}
+
+func frames(ctx *ext.Context, u *ext.Update) error {
+	chatId := u.EffectiveChat().GetID()
+	peerChatId := ctx.PeerStorage.GetPeerById(chatId)
+	if peerChatId.Type != int(storage.TypeUser) {
+		return dispatcher.EndGroups
+	}
+	trackUser(u)
+	if !isAllowed(ctx, chatId) {
+		ctx.Reply(u, "You are not allowed to use this bot.", nil)
+		return dispatcher.EndGroups
+	}
+	if !media.Enabled() {
+		ctx.Reply(u, "Frame extraction is not enabled on this bot.", nil)
+		return dispatcher.EndGroups
+	}
+	link, err := repliedLink(u)
+	if err != nil {
+		ctx.Reply(u, fmt.Sprintf("Error - %s", err.Error()), nil)
+		return dispatcher.EndGroups
+	}
+	if !strings.Contains(link.MimeType, "video") {
+		ctx.Reply(u, "Frames can only be extracted from videos.", nil)
+		return dispatcher.EndGroups
+	}
+	// a plain number is a frame count, anything else is a timestamp
+	count, timestamp := defaultFrameCount, -1.0
+	if args := u.Args(); len(args) > 1 {
+		if n, err := strconv.Atoi(args[1]); err == nil {
+			count = n
+		} else if timestamp, err = utils.ParseTimestamp(args[1]); err != nil {
+			ctx.Reply(u, "Usage: /frames [count|timestamp], eg. /frames 6 or /frames 1:30", nil)
+			return dispatcher.EndGroups
+		}
+	}
+	if count < 1 || count > maxFrameCount {
+		ctx.Reply(u, fmt.Sprintf("The frame count must be between 1 and %d.", maxFrameCount), nil)
+		return dispatcher.EndGroups
+	}
+	status, err := ctx.Reply(u, "⏳ Extracting frames...", &ext.ReplyOpts{ReplyToMessageId: u.EffectiveMessage.ID})
+	if err != nil {
+		return dispatcher.EndGroups
+	}
+	go func() {
+		defer crash.Recover("frames")
+		defer ctx.DeleteMessages(chatId, []int{status.ID})
+		jobCtx, cancel := context.WithTimeout(ctx, 3*time.Minute)
+		defer cancel()
+		url := utils.InternalStreamURL(link.TenantID, link.MessageID, link.Hash)
+		timestamps := []float64{timestamp}
+		if timestamp < 0 {
+			duration, err := media.Duration(jobCtx, url)
+			if err != nil {
+				utils.Logger.Error("Failed to probe duration", zap.Error(err), zap.Int("messageID", link.MessageID))
+				ctx.Reply(u, "❌ Failed to read the video duration.", nil)
+				return
+			}
+			timestamps = media.EvenTimestamps(duration, count)
+		}
+		names, err := media.ExtractFrames(jobCtx, url, link.StorageKey(), timestamps)
+		if len(names) == 0 {
+			utils.Logger.Error("Failed to extract frames", zap.Error(err), zap.Int("messageID", link.MessageID))
+			ctx.Reply(u, "❌ Failed to extract the frames.", nil)
+			return
+		}
+		if err := sendAlbum(ctx, chatId, u.EffectiveMessage.ID, link, names); err != nil {
+			utils.Logger.Error("Failed to send frames", zap.Error(err))
+			ctx.Reply(u, fmt.Sprintf("Error - %s", err.Error()), nil)
+			return
+		}
+		ctx.Reply(u, framesMessage(link, names), &ext.ReplyOpts{ReplyToMessageId: u.EffectiveMessage.ID})
+	}()
+	return dispatcher.EndGroups
+}
+
+// sendAlbum uploads the extracted frames and sends them as a single album
+func sendAlbum(ctx *ext.Context, chatId int64, replyTo int, link *types.Link, names []string) error {
+	peer := ctx.PeerStorage.GetInputPeerById(chatId)
+	album := make([]tg.InputSingleMedia, 0, len(names))
+	for _, name := range names {
+		file, err := uploader.NewUploader(ctx.Raw).FromPath(ctx, media.FramePath(link.StorageKey(), name))
+		if err != nil {
+			return err
+		}
+		// album items must reference media that was already uploaded to Telegram
+		uploaded, err := ctx.Raw.MessagesUploadMedia(ctx, &tg.MessagesUploadMediaRequest{
+			Peer:  peer,
+			Media: &tg.InputMediaUploadedPhoto{File: file},
+		})
+		if err != nil {
+			return err
+		}
+		photo, ok := uploaded.(*tg.MessageMediaPhoto)
+		if !ok {
+			return fmt.Errorf("unexpected media type %T", uploaded)
+		}
+		uploadedPhoto, ok := photo.Photo.AsNotEmpty()
+		if !ok {
+			return fmt.Errorf("uploaded photo is empty")
+		}
+		album = append(album, tg.InputSingleMedia{
+			Media:    &tg.InputMediaPhoto{ID: uploadedPhoto.AsInput()},
+			RandomID: rand.Int63(),
+		})
+	}
+	album[0].Message = fmt.Sprintf("🖼 Frames of %s", link.FileName)
+	_, err := ctx.SendMultiMedia(chatId, &tg.MessagesSendMultiMediaRequest{
+		MultiMedia: album,
+		ReplyTo:    &tg.InputReplyToMessage{ReplyToMsgID: replyTo},
+	})
+	return err
+}
+
+// framesMessage lists the hosted URLs of the extracted frames
+func framesMessage(link *types.Link, names []string) string {
+	var sb strings.Builder
+	sb.WriteString("🔗 Frame links (valid for 24 hours):\n")
+	for _, name := range names {
+		sb.WriteString("\n" + utils.TenantURL(link.TenantID, fmt.Sprintf("/frames/%d/%s?hash=%s", link.MessageID, name, link.Hash)))
+	}
+	return sb.String()
+}
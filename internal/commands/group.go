@@ -0,0 +1,138 @@
+package commands
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/utils"
+	"strings"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/dispatcher/handlers"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/celestix/gotgproto/storage"
+	tgtypes "github.com/celestix/gotgproto/types"
+	"github.com/gotd/td/tg"
+	"go.uber.org/zap"
+)
+
+// generalTopic is the topic of the messages of a forum that weren't sent to another topic
+const generalTopic = 1
+
+func (m *command) LoadGroup(dispatcher dispatcher.Dispatcher) {
+	log := m.log.Named("group")
+	defer log.Sugar().Info("Loaded")
+	dispatcher.AddHandler(handlers.NewCommand("enabletopic", enableTopic))
+}
+
+// enableTopic restricts the links the bot generates in a forum to the topics enabled with it,
+// /enabletopic off removes the topic again
+func enableTopic(ctx *ext.Context, u *ext.Update) error {
+	channel := u.GetChannel()
+	if !config.ValueOf.GroupMode || channel == nil || !channel.Megagroup {
+		return dispatcher.EndGroups
+	}
+	from, ok := u.EffectiveMessage.FromID.(*tg.PeerUser)
+	if !ok || !utils.IsAdmin(ctx, ctx.Raw, ctx.PeerStorage, from.UserID) {
+		reply(ctx, u, "This command is only available to admins.", nil)
+		return dispatcher.EndGroups
+	}
+	if !channel.Forum {
+		reply(ctx, u, "This group has no topics, links are generated for every file sent to it.", nil)
+		return dispatcher.EndGroups
+	}
+	groupTopicRepository := database.GetGroupTopicRepository()
+	if groupTopicRepository == nil {
+		reply(ctx, u, "❌ Topic database is not available at the moment.", nil)
+		return dispatcher.EndGroups
+	}
+	topic := topicOf(u)
+	if args := u.Args(); len(args) > 1 && strings.EqualFold(args[1], "off") {
+		left, err := groupTopicRepository.Disable(channel.ID, topic)
+		if err != nil {
+			utils.Logger.Error("Failed to disable topic", zap.Error(err), zap.Int64("chatID", channel.ID), zap.Int("topicID", topic))
+			reply(ctx, u, "❌ Failed to disable the topic, please try again.", nil)
+		} else if left == 0 {
+			reply(ctx, u, "✅ No topic is enabled anymore, links are generated in all topics of this group again.", nil)
+		} else {
+			reply(ctx, u, "✅ Links are no longer generated in this topic.", nil)
+		}
+		return dispatcher.EndGroups
+	}
+	if err := groupTopicRepository.Enable(channel.ID, topic, from.UserID); err != nil {
+		utils.Logger.Error("Failed to enable topic", zap.Error(err), zap.Int64("chatID", channel.ID), zap.Int("topicID", topic))
+		reply(ctx, u, "❌ Failed to enable the topic, please try again.", nil)
+		return dispatcher.EndGroups
+	}
+	reply(ctx, u, "✅ Links are generated in this topic. Files sent to topics that weren't enabled are ignored now, send /enabletopic off to remove this one.", nil)
+	return dispatcher.EndGroups
+}
+
+// groupSender returns the user who sent the message of the update to a group, if the bot
+// generates a link for it: GROUP_MODE is on, the message is new and its topic is enabled
+func groupSender(u *ext.Update) (int64, bool) {
+	if !config.ValueOf.GroupMode {
+		return 0, false
+	}
+	switch u.UpdateClass.(type) {
+	case *tg.UpdateNewMessage, *tg.UpdateNewChannelMessage:
+	default:
+		// edits in groups get no new link, only the files sent to the bot are replaced
+		return 0, false
+	}
+	if channel := u.GetChannel(); channel != nil && !channel.Megagroup {
+		// posts of channels, such as the log channel
+		return 0, false
+	} else if channel == nil && u.GetChat() == nil {
+		return 0, false
+	}
+	from, ok := u.EffectiveMessage.FromID.(*tg.PeerUser)
+	if !ok || u.EffectiveMessage.Out {
+		return 0, false
+	}
+	if groupTopicRepository := database.GetGroupTopicRepository(); groupTopicRepository != nil {
+		chatID, topic := u.EffectiveChat().GetID(), topicOf(u)
+		enabled, err := groupTopicRepository.Enabled(chatID, topic)
+		if err != nil {
+			utils.Logger.Error("Failed to check topic", zap.Error(err), zap.Int64("chatID", chatID), zap.Int("topicID", topic))
+		} else if !enabled {
+			return 0, false
+		}
+	}
+	return from.UserID, true
+}
+
+// topicOf returns the forum topic the message of the update was sent to, 0 outside forums
+func topicOf(u *ext.Update) int {
+	if header, ok := u.EffectiveMessage.ReplyTo.(*tg.MessageReplyHeader); ok && header.ForumTopic {
+		if header.ReplyToTopID != 0 {
+			return header.ReplyToTopID
+		}
+		return header.ReplyToMsgID
+	}
+	if channel := u.GetChannel(); channel != nil && channel.Forum {
+		return generalTopic
+	}
+	return 0
+}
+
+// reply answers the message of the update like ctx.Reply. In groups it replies to the message
+// in its topic, ctx.Reply would post the answer to the General topic.
+func reply(ctx *ext.Context, u *ext.Update, text string, opts *ext.ReplyOpts) (*tgtypes.Message, error) {
+	chatId := u.EffectiveChat().GetID()
+	if ctx.PeerStorage.GetPeerById(chatId).Type == int(storage.TypeUser) {
+		return ctx.Reply(u, text, opts)
+	}
+	if opts == nil {
+		opts = &ext.ReplyOpts{}
+	}
+	replyTo := &tg.InputReplyToMessage{ReplyToMsgID: u.EffectiveMessage.ID}
+	if topic := topicOf(u); topic > generalTopic && topic != u.EffectiveMessage.ID {
+		replyTo.SetTopMsgID(topic)
+	}
+	return ctx.SendMessage(chatId, &tg.MessagesSendMessageRequest{
+		Message:     text,
+		NoWebpage:   opts.NoWebpage,
+		ReplyMarkup: opts.Markup,
+		ReplyTo:     replyTo,
+	})
+}
@@ -0,0 +1,69 @@
+package commands
+
+import (
+	"EverythingSuckz/fsb/internal/category"
+	"EverythingSuckz/fsb/internal/library"
+	"EverythingSuckz/fsb/internal/utils"
+	"fmt"
+	"strings"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/dispatcher/handlers"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/celestix/gotgproto/storage"
+)
+
+// historySize is the number of links /history lists
+const historySize = 20
+
+func (m *command) LoadHistory(dispatcher dispatcher.Dispatcher) {
+	log := m.log.Named("history")
+	defer log.Sugar().Info("Loaded")
+	dispatcher.AddHandler(handlers.NewCommand("history", history))
+}
+
+// history lists the recent links of the user, only the ones of a category if one is given
+func history(ctx *ext.Context, u *ext.Update) error {
+	chatId := u.EffectiveChat().GetID()
+	peerChatId := ctx.PeerStorage.GetPeerById(chatId)
+	if peerChatId.Type != int(storage.TypeUser) {
+		return dispatcher.EndGroups
+	}
+	trackUser(u)
+	if !isAllowed(ctx, chatId) {
+		ctx.Reply(u, "You are not allowed to use this bot.", nil)
+		return dispatcher.EndGroups
+	}
+	filter := strings.TrimSpace(argsAfter(u.EffectiveMessage.Text, 1))
+	if filter != "" {
+		found, ok := category.Find(filter)
+		if !ok {
+			ctx.Reply(u, fmt.Sprintf("Unknown category %s, use one of %s.", filter, strings.Join(category.Names(), ", ")), nil)
+			return dispatcher.EndGroups
+		}
+		filter = found
+	}
+	links, err := library.RecentIn(chatId, filter, historySize)
+	if err != nil {
+		ctx.Reply(u, fmt.Sprintf("Error - %s", err.Error()), nil)
+		return dispatcher.EndGroups
+	}
+	var text strings.Builder
+	switch {
+	case len(links) == 0 && filter != "":
+		fmt.Fprintf(&text, "You have no links in %s.", filter)
+	case len(links) == 0:
+		text.WriteString("You have no links yet, send me a file.")
+	case filter != "":
+		fmt.Fprintf(&text, "🗂 Your recent links in %s\n", filter)
+	default:
+		text.WriteString("🗂 Your recent links\n")
+	}
+	for _, link := range links {
+		fmt.Fprintf(&text, "\n%s (%s, %s)\n%s\n", link.FileName, utils.FormatFileSizeShort(link.FileSize), link.Category,
+			utils.StreamURL(link.TenantID, link.MessageID, link.Hash))
+	}
+	fmt.Fprintf(&text, "\nFilter them with /history <category>: %s.", strings.Join(category.Names(), ", "))
+	ctx.Reply(u, text.String(), &ext.ReplyOpts{NoWebpage: true})
+	return dispatcher.EndGroups
+}
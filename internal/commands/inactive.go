@@ -0,0 +1,67 @@
+package commands
+
+import (
+	"EverythingSuckz/fsb/internal/utils"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/dispatcher/handlers"
+	"github.com/celestix/gotgproto/ext"
+	"go.uber.org/zap"
+)
+
+// inactiveLimit is the maximum number of users listed by /inactive
+const inactiveLimit = 30
+
+func (m *command) LoadInactive(dispatcher dispatcher.Dispatcher) {
+	log := m.log.Named("inactive")
+	defer log.Sugar().Info("Loaded")
+	dispatcher.AddHandler(handlers.NewCommand("inactive", inactive))
+}
+
+// inactive lists the users who weren't seen within a period, 30 days by default, so they can be removed
+func inactive(ctx *ext.Context, u *ext.Update) error {
+	_, userRepository, ok := userAdminCommand(ctx, u, "")
+	if !ok {
+		return dispatcher.EndGroups
+	}
+	period := 30 * 24 * time.Hour
+	if args := u.Args(); len(args) > 1 {
+		var err error
+		if period, err = utils.ParsePeriod(args[1]); err != nil {
+			ctx.Reply(u, "Invalid period, use e.g. 30d, 8w or 720h.\n\nUsage: /inactive [period]", nil)
+			return dispatcher.EndGroups
+		}
+	}
+	users, count, err := userRepository.ListInactive(time.Now().Add(-period), inactiveLimit)
+	if err != nil {
+		utils.Logger.Error("Failed to list inactive users", zap.Error(err))
+		ctx.Reply(u, fmt.Sprintf("Error - %s", err.Error()), nil)
+		return dispatcher.EndGroups
+	}
+	if count == 0 {
+		ctx.Reply(u, fmt.Sprintf("No users have been inactive for %s.", formatWait(period)), nil)
+		return dispatcher.EndGroups
+	}
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("💤 %d users inactive for %s\n\n", count, formatWait(period)))
+	for _, user := range users {
+		sb.WriteString(fmt.Sprintf("• %d", user.ID))
+		if user.Username != "" {
+			sb.WriteString(" @" + user.Username)
+		}
+		if user.LastSeen != nil {
+			sb.WriteString(fmt.Sprintf(", last seen %s\n", user.LastSeen.Format("2006-01-02")))
+		} else {
+			sb.WriteString(fmt.Sprintf(", never seen, joined %s\n", user.CreatedAt.Format("2006-01-02")))
+		}
+	}
+	if count > int64(len(users)) {
+		sb.WriteString(fmt.Sprintf("…and %d more\n", count-int64(len(users))))
+	}
+	sb.WriteString("\nRemove a user with /removeuser <user_id>")
+	ctx.Reply(u, sb.String(), nil)
+	return dispatcher.EndGroups
+}
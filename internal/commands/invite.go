@@ -0,0 +1,94 @@
+package commands
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/utils"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/dispatcher/handlers"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/celestix/gotgproto/storage"
+	"go.uber.org/zap"
+)
+
+func (m *command) LoadInvite(dispatcher dispatcher.Dispatcher) {
+	log := m.log.Named("invite")
+	defer log.Sugar().Info("Loaded")
+	dispatcher.AddHandler(handlers.NewCommand("invite", invite))
+}
+
+// invite lets an authorized user vouch for a newcomer, who is then allowed to use the bot
+func invite(ctx *ext.Context, u *ext.Update) error {
+	chatId := u.EffectiveChat().GetID()
+	peerChatId := ctx.PeerStorage.GetPeerById(chatId)
+	if peerChatId.Type != int(storage.TypeUser) {
+		return dispatcher.EndGroups
+	}
+	trackUser(u)
+	if !isAllowed(ctx, chatId) {
+		ctx.Reply(u, "You are not allowed to use this bot.", nil)
+		return dispatcher.EndGroups
+	}
+	limit := config.ValueOf.InviteMonthlyLimit
+	isAdmin := utils.IsAdmin(ctx, ctx.Raw, ctx.PeerStorage, chatId)
+	if limit <= 0 && !isAdmin {
+		ctx.Reply(u, "Invites are disabled.", nil)
+		return dispatcher.EndGroups
+	}
+	args := u.Args()
+	if len(args) < 2 {
+		ctx.Reply(u, "Usage: /invite <user_id>\n\nThe user will be allowed to use the bot, and you are responsible for them.", nil)
+		return dispatcher.EndGroups
+	}
+	userID, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		ctx.Reply(u, "Invalid user ID.", nil)
+		return dispatcher.EndGroups
+	}
+	userRepository := database.GetUserRepository().WithContext(ctx)
+	if userRepository == nil {
+		ctx.Reply(u, "❌ User database is not available at the moment.", nil)
+		return dispatcher.EndGroups
+	}
+	inviter, err := userRepository.Get(chatId)
+	if err != nil {
+		utils.Logger.Error("Failed to get user", zap.Error(err), zap.Int64("userID", chatId))
+		ctx.Reply(u, "❌ Failed to send the invite. Please try again later.", nil)
+		return dispatcher.EndGroups
+	}
+	if !isAdmin {
+		if inviter != nil && inviter.InvitesRevoked {
+			ctx.Reply(u, "You can't invite users anymore because a user you invited was flagged for abuse.", nil)
+			return dispatcher.EndGroups
+		}
+		now := time.Now()
+		monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+		count, err := userRepository.CountInvitedSince(chatId, monthStart)
+		if err != nil {
+			utils.Logger.Error("Failed to count invites", zap.Error(err), zap.Int64("userID", chatId))
+			ctx.Reply(u, "❌ Failed to send the invite. Please try again later.", nil)
+			return dispatcher.EndGroups
+		}
+		if count >= int64(limit) {
+			ctx.Reply(u, fmt.Sprintf("You have used all %d invites of this month.", limit), nil)
+			return dispatcher.EndGroups
+		}
+	}
+	invited, err := userRepository.Invite(userID, chatId)
+	if err != nil {
+		utils.Logger.Error("Failed to invite user", zap.Error(err), zap.Int64("userID", userID))
+		ctx.Reply(u, "❌ Failed to send the invite. Please try again later.", nil)
+		return dispatcher.EndGroups
+	}
+	if !invited {
+		ctx.Reply(u, fmt.Sprintf("User %d was already invited.", userID), nil)
+		return dispatcher.EndGroups
+	}
+	ctx.Reply(u, fmt.Sprintf("✅ User %d can use the bot now.", userID), nil)
+	beginOnboarding(ctx, userID)
+	return dispatcher.EndGroups
+}
@@ -0,0 +1,104 @@
+package commands
+
+import (
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/jobs"
+	"EverythingSuckz/fsb/internal/types"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/dispatcher/handlers"
+	"github.com/celestix/gotgproto/ext"
+)
+
+const jobsUsage = "Usage: /jobs lists the background jobs and the dead ones, which failed every attempt.\n" +
+	"/jobs retry <id> runs a dead job again, /jobs remove <id> removes it."
+
+// deadJobsListed is the number of dead jobs /jobs lists
+const deadJobsListed = 20
+
+func (m *command) LoadJobs(dispatcher dispatcher.Dispatcher) {
+	log := m.log.Named("jobs")
+	defer log.Sugar().Info("Loaded")
+	dispatcher.AddHandler(handlers.NewCommand("jobs", listJobs))
+}
+
+// listJobs counts the background jobs by kind and state and lists the dead ones, or retries
+// or removes a dead job
+func listJobs(ctx *ext.Context, u *ext.Update) error {
+	if _, ok := settingsAdmin(ctx, u); !ok {
+		return dispatcher.EndGroups
+	}
+	jobRepository := database.GetJobRepository()
+	if jobRepository == nil {
+		ctx.Reply(u, "❌ Job database is not available at the moment.", nil)
+		return dispatcher.EndGroups
+	}
+	if args := u.Args(); len(args) > 1 {
+		id, err := strconv.ParseUint(args[len(args)-1], 10, 0)
+		if len(args) != 3 || err != nil || args[1] != "retry" && args[1] != "remove" {
+			ctx.Reply(u, jobsUsage, nil)
+			return dispatcher.EndGroups
+		}
+		action, found := jobs.Retry, "🔁 Job #%d was queued again."
+		if args[1] == "remove" {
+			action, found = jobs.Remove, "🗑 Job #%d was removed."
+		}
+		ok, err := action(uint(id))
+		switch {
+		case err != nil:
+			ctx.Reply(u, fmt.Sprintf("Error - %s", err.Error()), nil)
+		case !ok:
+			ctx.Reply(u, fmt.Sprintf("There's no dead job #%d.", id), nil)
+		default:
+			ctx.Reply(u, fmt.Sprintf(found, id), nil)
+		}
+		return dispatcher.EndGroups
+	}
+	counts, err := jobRepository.Counts()
+	if err != nil {
+		ctx.Reply(u, fmt.Sprintf("Error - %s", err.Error()), nil)
+		return dispatcher.EndGroups
+	}
+	dead, err := jobRepository.ListDead(deadJobsListed)
+	if err != nil {
+		ctx.Reply(u, fmt.Sprintf("Error - %s", err.Error()), nil)
+		return dispatcher.EndGroups
+	}
+	ctx.Reply(u, formatJobs(counts, dead), nil)
+	return dispatcher.EndGroups
+}
+
+func formatJobs(counts []types.JobCount, dead []types.Job) string {
+	if len(counts) == 0 {
+		return "🧰 There are no background jobs."
+	}
+	var message strings.Builder
+	message.WriteString("🧰 Background jobs\n\n")
+	for i, count := range counts {
+		if i == 0 || counts[i-1].Kind != count.Kind {
+			if i > 0 {
+				message.WriteString("\n")
+			}
+			message.WriteString(count.Kind + ":")
+		} else {
+			message.WriteString(",")
+		}
+		fmt.Fprintf(&message, " %d %s", count.Count, count.State)
+	}
+	message.WriteString("\n")
+	if len(dead) > 0 {
+		message.WriteString("\n☠️ Dead jobs:\n")
+		for _, job := range dead {
+			fmt.Fprintf(&message, "#%d %s, %d attempts", job.ID, job.Kind, job.Attempts)
+			if job.FinishedAt != nil {
+				message.WriteString(", " + job.FinishedAt.Format("2006-01-02 15:04"))
+			}
+			fmt.Fprintf(&message, ": %s\n", job.LastError)
+		}
+		message.WriteString("\n" + jobsUsage)
+	}
+	return message.String()
+}
@@ -0,0 +1,48 @@
+package commands
+
+import (
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/utils"
+	"EverythingSuckz/fsb/internal/webauth"
+	"fmt"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/dispatcher/handlers"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/celestix/gotgproto/storage"
+)
+
+func (m *command) LoadKodi(dispatcher dispatcher.Dispatcher) {
+	log := m.log.Named("kodi")
+	defer log.Sugar().Info("Loaded")
+	dispatcher.AddHandler(handlers.NewCommand("kodi", kodi))
+}
+
+// kodi sends the address of the Kodi API and the token the add-on signs in with
+func kodi(ctx *ext.Context, u *ext.Update) error {
+	chatId := u.EffectiveChat().GetID()
+	peerChatId := ctx.PeerStorage.GetPeerById(chatId)
+	if peerChatId.Type != int(storage.TypeUser) {
+		return dispatcher.EndGroups
+	}
+	trackUser(u)
+	if !isAllowed(ctx, chatId) {
+		ctx.Reply(u, "You are not allowed to use this bot.", nil)
+		return dispatcher.EndGroups
+	}
+	userRepository := database.GetUserRepository().WithContext(ctx)
+	if userRepository == nil {
+		ctx.Reply(u, "❌ User database is not available at the moment.", nil)
+		return dispatcher.EndGroups
+	}
+	version, err := userRepository.TokenVersion(chatId)
+	if err != nil {
+		ctx.Reply(u, fmt.Sprintf("Error - %s", err.Error()), nil)
+		return dispatcher.EndGroups
+	}
+	ctx.Reply(u, fmt.Sprintf("📺 Enter these in the settings of the Kodi add-on to browse your videos and audio on your TV:\n\n"+
+		"Server: %s\nToken: %s\n\n"+
+		"Don't share the token, whoever has it can play all your files. /revokeall revokes it.",
+		utils.PublicURL("/api/kodi"), webauth.KodiToken(chatId, version)), nil)
+	return dispatcher.EndGroups
+}
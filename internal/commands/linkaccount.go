@@ -0,0 +1,57 @@
+package commands
+
+import (
+	"EverythingSuckz/fsb/internal/profile"
+	"EverythingSuckz/fsb/internal/utils"
+	"errors"
+	"fmt"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/dispatcher/handlers"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/celestix/gotgproto/storage"
+	"go.uber.org/zap"
+)
+
+func (m *command) LoadLinkAccount(dispatcher dispatcher.Dispatcher) {
+	log := m.log.Named("linkaccount")
+	defer log.Sugar().Info("Loaded")
+	dispatcher.AddHandler(handlers.NewCommand("linkaccount", linkAccount))
+}
+
+// linkAccount generates a code with /linkaccount and redeems it with /linkaccount <code>,
+// so that both accounts share one queue in the web player
+func linkAccount(ctx *ext.Context, u *ext.Update) error {
+	chatId := u.EffectiveChat().GetID()
+	peerChatId := ctx.PeerStorage.GetPeerById(chatId)
+	if peerChatId.Type != int(storage.TypeUser) {
+		return dispatcher.EndGroups
+	}
+	trackUser(u)
+	if !isAllowed(ctx, chatId) {
+		ctx.Reply(u, "You are not allowed to use this bot.", nil)
+		return dispatcher.EndGroups
+	}
+	args := u.Args()
+	if len(args) < 2 {
+		code, err := profile.NewCode(chatId)
+		if err != nil {
+			utils.Logger.Error("Failed to generate link code", zap.Error(err))
+			ctx.Reply(u, "❌ Failed to generate a code. Please try again later.", nil)
+			return dispatcher.EndGroups
+		}
+		ctx.Reply(u, fmt.Sprintf("🔗 Send this from your other Telegram account within 10 minutes:\n\n/linkaccount %s\n\nBoth accounts will share one queue in the web player.", code), nil)
+		return dispatcher.EndGroups
+	}
+	_, err := profile.Redeem(args[1], chatId)
+	switch {
+	case errors.Is(err, profile.ErrInvalidCode), errors.Is(err, profile.ErrSameAccount), errors.Is(err, profile.ErrAlreadyLinked):
+		ctx.Reply(u, fmt.Sprintf("❌ Can't link the accounts: %s.", err.Error()), nil)
+	case err != nil:
+		utils.Logger.Error("Failed to link accounts", zap.Error(err), zap.Int64("userID", chatId))
+		ctx.Reply(u, "❌ Failed to link the accounts. Please try again later.", nil)
+	default:
+		ctx.Reply(u, "✅ Accounts linked. The links of both accounts now show up in the same queue of the web player.", nil)
+	}
+	return dispatcher.EndGroups
+}
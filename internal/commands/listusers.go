@@ -0,0 +1,404 @@
+package commands
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/access"
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/types"
+	"EverythingSuckz/fsb/internal/utils"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/dispatcher/handlers"
+	"github.com/celestix/gotgproto/dispatcher/handlers/filters"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/celestix/gotgproto/functions"
+	"github.com/celestix/gotgproto/storage"
+	"github.com/gotd/td/tg"
+	"go.uber.org/zap"
+)
+
+const listUsersUsage = "Usage: /listusers [query]\n\n" +
+	"Filters: auth:yes|no, admin:yes|no, suspended:yes|no, name:<text>, tag:<tag>, " +
+	"joined:>2024-01-01, joined:<30d, seen:>7d, seen:2024-01-31\n" +
+	"Sort with sort:joined, sort:seen, sort:name or sort:id, prefix - for descending order, e.g. sort:-seen\n" +
+	"Other words search the usernames and names."
+
+const (
+	// usersPerPage is the number of users on a page of /listusers
+	usersPerPage = 20
+	// jumpButtons is the number of page buttons around the current page
+	jumpButtons = 5
+)
+
+// userListFilters are the filters of /listusers, in the order of their buttons
+var userListFilters = []struct {
+	name  string
+	label string
+}{
+	{"all", "All"},
+	{"auth", "Authorized"},
+	{"unauth", "Unauthorized"},
+	{"admins", "Admins"},
+}
+
+// maxUserQueries is the number of /listusers queries kept for the buttons of their messages
+const maxUserQueries = 200
+
+// userQueries keeps the queries of the recent /listusers messages. Queries don't fit in the
+// data of the buttons, which is limited to 64 bytes, so the buttons refer to them by ID.
+var userQueries = struct {
+	sync.Mutex
+	byID map[int]string
+	next int
+}{byID: make(map[int]string), next: 1}
+
+func storeUserQuery(query string) int {
+	userQueries.Lock()
+	defer userQueries.Unlock()
+	id := userQueries.next
+	userQueries.next++
+	userQueries.byID[id] = query
+	delete(userQueries.byID, id-maxUserQueries)
+	return id
+}
+
+func loadUserQuery(id int) (string, bool) {
+	userQueries.Lock()
+	defer userQueries.Unlock()
+	query, ok := userQueries.byID[id]
+	return query, ok
+}
+
+// userListPage is the state of a /listusers message, encoded in the data of its buttons
+// as lu:<filter>:<page>:<query ID> so that the same message is edited in place
+type userListPage struct {
+	filter  string
+	page    int
+	queryID int // 0 without a query
+	query   string
+}
+
+func (p userListPage) data() []byte {
+	return []byte(fmt.Sprintf("lu:%s:%d:%d", p.filter, p.page, p.queryID))
+}
+
+func (p userListPage) with(filter string, page int) userListPage {
+	p.filter, p.page = filter, page
+	return p
+}
+
+func parseUserListPage(data string) (userListPage, error) {
+	fields := strings.Split(data, ":")
+	if len(fields) != 4 || fields[0] != "lu" {
+		return userListPage{}, errors.New("invalid button")
+	}
+	page, err := strconv.Atoi(fields[2])
+	if err != nil || page < 0 {
+		return userListPage{}, errors.New("invalid button")
+	}
+	queryID, err := strconv.Atoi(fields[3])
+	if err != nil {
+		return userListPage{}, errors.New("invalid button")
+	}
+	p := userListPage{filter: fields[1], page: page, queryID: queryID}
+	if queryID != 0 {
+		var ok bool
+		if p.query, ok = loadUserQuery(queryID); !ok {
+			return userListPage{}, errors.New("this list has expired, send /listusers again")
+		}
+	}
+	return p, nil
+}
+
+func (m *command) LoadListUsers(dispatcher dispatcher.Dispatcher) {
+	log := m.log.Named("listusers")
+	defer log.Sugar().Info("Loaded")
+	dispatcher.AddHandler(handlers.NewCommand("listusers", listUsers))
+	dispatcher.AddHandler(handlers.NewCallbackQuery(filters.CallbackQuery.Prefix("lu:"), listUsersCallback))
+}
+
+// listUsers lists the users of the bot, or of the tenant for tenant admins, with buttons
+// to filter them and to move between the pages
+func listUsers(ctx *ext.Context, u *ext.Update) error {
+	chatId := u.EffectiveChat().GetID()
+	peerChatId := ctx.PeerStorage.GetPeerById(chatId)
+	if peerChatId.Type != int(storage.TypeUser) {
+		return dispatcher.EndGroups
+	}
+	scope, ok := moderationScope(ctx, chatId)
+	if !ok {
+		ctx.Reply(u, "This command is only available to admins.", nil)
+		return dispatcher.EndGroups
+	}
+	page := userListPage{filter: "all"}
+	if _, query, ok := strings.Cut(u.EffectiveMessage.Text, " "); ok && strings.TrimSpace(query) != "" {
+		page.query = strings.TrimSpace(query)
+		if _, err := parseUserQuery(page.query, time.Now()); err != nil {
+			ctx.Reply(u, fmt.Sprintf("Error - %s\n\n%s", err.Error(), listUsersUsage), nil)
+			return dispatcher.EndGroups
+		}
+		page.queryID = storeUserQuery(page.query)
+	}
+	message, markup, err := userListMessage(scope, page)
+	if err != nil {
+		utils.Logger.Error("Failed to list users", zap.Error(err))
+		ctx.Reply(u, "❌ Failed to retrieve users. Please try again later.", nil)
+		return dispatcher.EndGroups
+	}
+	ctx.Reply(u, message, &ext.ReplyOpts{Markup: markup})
+	return dispatcher.EndGroups
+}
+
+// listUsersCallback shows the page of the button that was pressed
+func listUsersCallback(ctx *ext.Context, u *ext.Update) error {
+	query := u.CallbackQuery
+	scope, ok := moderationScope(ctx, query.UserID)
+	if !ok {
+		ctx.AnswerCallback(&tg.MessagesSetBotCallbackAnswerRequest{
+			QueryID: query.QueryID,
+			Message: "This action is only available to admins.",
+			Alert:   true,
+		})
+		return dispatcher.EndGroups
+	}
+	page, err := parseUserListPage(string(query.Data))
+	if err != nil {
+		ctx.AnswerCallback(&tg.MessagesSetBotCallbackAnswerRequest{
+			QueryID: query.QueryID,
+			Message: err.Error(),
+			Alert:   true,
+		})
+		return dispatcher.EndGroups
+	}
+	ctx.AnswerCallback(&tg.MessagesSetBotCallbackAnswerRequest{QueryID: query.QueryID})
+	message, markup, err := userListMessage(scope, page)
+	if err != nil {
+		utils.Logger.Error("Failed to list users", zap.Error(err))
+		return dispatcher.EndGroups
+	}
+	ctx.EditMessage(functions.GetChatIdFromPeer(query.Peer), &tg.MessagesEditMessageRequest{
+		ID:          query.MsgID,
+		Message:     message,
+		ReplyMarkup: markup,
+	})
+	return dispatcher.EndGroups
+}
+
+// userListFilter returns the database filter of the query and the filter button within the scope
+func userListFilter(scope *types.Tenant, page userListPage) (database.UserFilter, error) {
+	filter, err := parseUserQuery(page.query, time.Now())
+	if err != nil {
+		return filter, err
+	}
+	if scope != nil {
+		filter.TenantID = &scope.ID
+	}
+	switch page.filter {
+	case "auth", "unauth":
+		authorized := page.filter == "auth"
+		filter.Authorized = &authorized
+	case "admins":
+		filter.IDs = append([]int64{}, config.Runtime.Admins()...)
+	}
+	return filter, nil
+}
+
+// parseUserQuery parses the query of /listusers, see listUsersUsage. Words without
+// a key search the names.
+func parseUserQuery(query string, now time.Time) (database.UserFilter, error) {
+	var filter database.UserFilter
+	var names []string
+	for _, term := range strings.Fields(query) {
+		key, value, ok := strings.Cut(term, ":")
+		if !ok {
+			names = append(names, term)
+			continue
+		}
+		var err error
+		switch strings.ToLower(key) {
+		case "auth":
+			filter.Authorized, err = parseYesNo(value)
+		case "suspended":
+			filter.Suspended, err = parseYesNo(value)
+		case "admin":
+			var admin *bool
+			if admin, err = parseYesNo(value); err == nil && *admin {
+				filter.IDs = append([]int64{}, config.Runtime.Admins()...)
+			} else if err == nil {
+				filter.ExcludeIDs = config.Runtime.Admins()
+			}
+		case "joined":
+			err = parseDateFilter(value, now, &filter.JoinedAfter, &filter.JoinedBefore)
+		case "seen":
+			err = parseDateFilter(value, now, &filter.SeenAfter, &filter.SeenBefore)
+		case "name":
+			names = append(names, value)
+		case "tag":
+			filter.Tag = normalizeTag(value)
+		case "sort":
+			if !database.ValidSort(value) {
+				err = fmt.Errorf("unknown sort %q, use joined, seen, name or id", value)
+			}
+			filter.Sort = value
+		default:
+			err = fmt.Errorf("unknown filter %q", key)
+		}
+		if err != nil {
+			return filter, err
+		}
+	}
+	filter.Name = strings.Join(names, " ")
+	return filter, nil
+}
+
+func parseYesNo(value string) (*bool, error) {
+	switch strings.ToLower(value) {
+	case "yes", "y", "true", "1":
+		yes := true
+		return &yes, nil
+	case "no", "n", "false", "0":
+		no := false
+		return &no, nil
+	}
+	return nil, fmt.Errorf("invalid value %q, use yes or no", value)
+}
+
+// parseDateFilter parses >date, <date or date, where date is like 2024-01-31 or a
+// period before now like 30d, into the bounds of a time range
+func parseDateFilter(value string, now time.Time, after **time.Time, before **time.Time) error {
+	op := value[:min(len(value), 1)]
+	if op == ">" || op == "<" {
+		value = value[1:]
+	}
+	start, err := time.ParseInLocation("2006-01-02", value, now.Location())
+	if err != nil {
+		period, periodErr := utils.ParsePeriod(value)
+		if periodErr != nil {
+			return fmt.Errorf("invalid date %q, use 2024-01-31 or a period like 30d", value)
+		}
+		start = now.Add(-period)
+	}
+	end := start.AddDate(0, 0, 1)
+	switch op {
+	case ">":
+		*after = &start
+	case "<":
+		*before = &start
+	default:
+		*after, *before = &start, &end
+	}
+	return nil
+}
+
+func userListMessage(scope *types.Tenant, page userListPage) (string, tg.ReplyMarkupClass, error) {
+	userRepository := database.GetUserRepository()
+	if userRepository == nil {
+		return "", nil, fmt.Errorf("user database is not available")
+	}
+	filter, err := userListFilter(scope, page)
+	if err != nil {
+		return "", nil, err
+	}
+	users, count, err := userRepository.ListUsers(filter, page.page*usersPerPage, usersPerPage)
+	if err != nil {
+		return "", nil, err
+	}
+	pages := int((count + usersPerPage - 1) / usersPerPage)
+	if pages == 0 {
+		pages = 1
+	}
+	if page.page >= pages {
+		page.page = pages - 1
+		users, count, err = userRepository.ListUsers(filter, page.page*usersPerPage, usersPerPage)
+		if err != nil {
+			return "", nil, err
+		}
+	}
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("👥 Users (%d), page %d of %d\n", count, page.page+1, pages))
+	if page.query != "" {
+		sb.WriteString("🔎 " + page.query + "\n")
+	}
+	sb.WriteString("\n")
+	if len(users) == 0 {
+		sb.WriteString("No users match this filter.\n")
+	}
+	for _, user := range users {
+		sb.WriteString("• " + formatUser(user) + " " + userStatus(user) + "\n")
+	}
+	markup := &tg.ReplyInlineMarkup{Rows: []tg.KeyboardButtonRow{userListFilterRow(page)}}
+	if pages > 1 {
+		markup.Rows = append(markup.Rows, userListNavRow(page, pages), userListJumpRow(page, pages))
+	}
+	return sb.String(), markup, nil
+}
+
+// userStatus summarizes the access of the user
+func userStatus(user types.User) string {
+	switch {
+	case user.Suspended:
+		return "🚫 suspended"
+	case access.Authorized(&user) && user.AuthorizedTill != nil:
+		return "✅ until " + user.AuthorizedTill.Format("2006-01-02")
+	case access.Authorized(&user):
+		return "✅"
+	case user.Authorized:
+		return "⌛ expired"
+	}
+	return fmt.Sprintf("· joined %s", user.CreatedAt.Format("2006-01-02"))
+}
+
+func userListFilterRow(page userListPage) tg.KeyboardButtonRow {
+	var row tg.KeyboardButtonRow
+	for _, filter := range userListFilters {
+		label := filter.label
+		if filter.name == page.filter {
+			label = "• " + label
+		}
+		row.Buttons = append(row.Buttons, &tg.KeyboardButtonCallback{
+			Text: label,
+			Data: page.with(filter.name, 0).data(),
+		})
+	}
+	return row
+}
+
+func userListNavRow(page userListPage, pages int) tg.KeyboardButtonRow {
+	prev := page.with(page.filter, max(page.page-1, 0))
+	next := page.with(page.filter, min(page.page+1, pages-1))
+	return tg.KeyboardButtonRow{Buttons: []tg.KeyboardButtonClass{
+		&tg.KeyboardButtonCallback{Text: "‹ Prev", Data: prev.data()},
+		&tg.KeyboardButtonCallback{Text: fmt.Sprintf("%d / %d", page.page+1, pages), Data: page.data()},
+		&tg.KeyboardButtonCallback{Text: "Next ›", Data: next.data()},
+	}}
+}
+
+// userListJumpRow has buttons for the first and last page and the pages around the current one
+func userListJumpRow(page userListPage, pages int) tg.KeyboardButtonRow {
+	first := max(min(page.page-jumpButtons/2, pages-jumpButtons), 0)
+	last := min(first+jumpButtons, pages)
+	var row tg.KeyboardButtonRow
+	if first > 0 {
+		row.Buttons = append(row.Buttons, &tg.KeyboardButtonCallback{Text: "« 1", Data: page.with(page.filter, 0).data()})
+	}
+	for i := first; i < last; i++ {
+		label := strconv.Itoa(i + 1)
+		if i == page.page {
+			label = "[" + label + "]"
+		}
+		row.Buttons = append(row.Buttons, &tg.KeyboardButtonCallback{Text: label, Data: page.with(page.filter, i).data()})
+	}
+	if last < pages {
+		row.Buttons = append(row.Buttons, &tg.KeyboardButtonCallback{
+			Text: fmt.Sprintf("%d »", pages),
+			Data: page.with(page.filter, pages-1).data(),
+		})
+	}
+	return row
+}
@@ -0,0 +1,100 @@
+package commands
+
+import (
+	"EverythingSuckz/fsb/internal/crash"
+	"EverythingSuckz/fsb/internal/features"
+	"EverythingSuckz/fsb/internal/media"
+	"EverythingSuckz/fsb/internal/utils"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/dispatcher/handlers"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/celestix/gotgproto/storage"
+	"go.uber.org/zap"
+)
+
+func (m *command) LoadMediaInfo(dispatcher dispatcher.Dispatcher) {
+	log := m.log.Named("mediainfo")
+	defer log.Sugar().Info("Loaded")
+	dispatcher.AddHandler(handlers.NewCommand("mediainfo", mediaInfo))
+}
+
+func mediaInfo(ctx *ext.Context, u *ext.Update) error {
+	chatId := u.EffectiveChat().GetID()
+	peerChatId := ctx.PeerStorage.GetPeerById(chatId)
+	if peerChatId.Type != int(storage.TypeUser) {
+		return dispatcher.EndGroups
+	}
+	trackUser(u)
+	if !isAllowed(ctx, chatId) {
+		ctx.Reply(u, "You are not allowed to use this bot.", nil)
+		return dispatcher.EndGroups
+	}
+	if !media.Enabled() {
+		ctx.Reply(u, "Media info is not enabled on this bot.", nil)
+		return dispatcher.EndGroups
+	}
+	link, err := repliedLink(u)
+	if err != nil {
+		ctx.Reply(u, fmt.Sprintf("Error - %s", err.Error()), nil)
+		return dispatcher.EndGroups
+	}
+	go func() {
+		defer crash.Recover("mediainfo")
+		jobCtx, cancel := context.WithTimeout(ctx, time.Minute)
+		defer cancel()
+		info, err := media.Probe(jobCtx, utils.InternalStreamURL(link.TenantID, link.MessageID, link.Hash))
+		if err != nil {
+			utils.Logger.Error("Failed to probe media", zap.Error(err), zap.Int("messageID", link.MessageID))
+			ctx.Reply(u, "❌ Failed to analyse the file.", nil)
+			return
+		}
+		message := mediaInfoMessage(link.FileName, info)
+		if len(info.Video) > 0 && features.Enabled(features.Transcoding, link.UserID) {
+			message += "\n\n📡 Adaptive stream (HLS):\n" + utils.TenantURL(link.TenantID, fmt.Sprintf("/hls/%d/master.m3u8?hash=%s", link.MessageID, link.Hash))
+		}
+		ctx.Reply(u, message, &ext.ReplyOpts{ReplyToMessageId: u.EffectiveMessage.ID})
+	}()
+	return dispatcher.EndGroups
+}
+
+func mediaInfoMessage(fileName string, info *media.Info) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("ℹ️ %s\n\n", fileName))
+	sb.WriteString(fmt.Sprintf("Container: %s\n", info.Container))
+	sb.WriteString(fmt.Sprintf("Duration: %s\n", utils.TimeFormat(uint64(info.Duration))))
+	if info.BitRate > 0 {
+		sb.WriteString(fmt.Sprintf("Bitrate: %d kb/s\n", info.BitRate/1000))
+	}
+	for _, v := range info.Video {
+		sb.WriteString(fmt.Sprintf("\n🎞 Video #%d: %s %s, %dx%d, %.3g fps", v.Index, v.Codec, v.Profile, v.Width, v.Height, v.FrameRate))
+	}
+	for _, a := range info.Audio {
+		sb.WriteString(fmt.Sprintf("\n🔊 Audio #%d: %s, %d ch, %d Hz", a.Index, a.Codec, a.Channels, a.SampleRate))
+		if a.Language != "" {
+			sb.WriteString(" [" + a.Language + "]")
+		}
+		if a.Default {
+			sb.WriteString(" (default)")
+		}
+	}
+	for _, s := range info.Subtitles {
+		sb.WriteString(fmt.Sprintf("\n💬 Subtitle #%d: %s", s.Index, s.Codec))
+		if s.Language != "" {
+			sb.WriteString(" [" + s.Language + "]")
+		}
+	}
+	if len(info.Chapters) > 0 {
+		sb.WriteString(fmt.Sprintf("\n📑 Chapters: %d", len(info.Chapters)))
+	}
+	if info.DirectPlayable() {
+		sb.WriteString("\n\n✅ Playable in browsers without transcoding")
+	} else {
+		sb.WriteString("\n\n⚠️ May need transcoding to play in browsers")
+	}
+	return sb.String()
+}
@@ -0,0 +1,141 @@
+package commands
+
+import (
+	"EverythingSuckz/fsb/internal/access"
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/types"
+	"EverythingSuckz/fsb/internal/utils"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/dispatcher/handlers"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/celestix/gotgproto/storage"
+	"github.com/gotd/td/tg"
+	"go.uber.org/zap"
+)
+
+// maxMetadataLength bounds the names, titles and performers set with the metadata commands
+const maxMetadataLength = 200
+
+// metadataField is a field of a link the metadata commands change
+type metadataField struct {
+	command string
+	name    string
+	set     func(link *types.Link, value string)
+}
+
+var metadataFields = []metadataField{
+	{"rename", "file name", func(link *types.Link, value string) { link.FileName = utils.SanitizeFileName(value, link.MimeType) }},
+	{"settitle", "title", func(link *types.Link, value string) { link.Title = value }},
+	{"setperformer", "performer", func(link *types.Link, value string) { link.Performer = value }},
+}
+
+func (m *command) LoadMetadata(dispatcher dispatcher.Dispatcher) {
+	log := m.log.Named("metadata")
+	defer log.Sugar().Info("Loaded")
+	for _, field := range metadataFields {
+		dispatcher.AddHandler(handlers.NewCommand(field.command, editMetadata(field)))
+	}
+}
+
+// editMetadata changes the field of the replied link, or of the link with the message ID
+// given before the value, since the names and audio tags of sent files are often junk
+func editMetadata(field metadataField) func(ctx *ext.Context, u *ext.Update) error {
+	usage := fmt.Sprintf("Usage: reply to a file or its link with /%s <%s>, or send /%s <link id> <%s>. "+
+		"The link id is the number in the link.", field.command, field.name, field.command, field.name)
+	if field.command != "rename" {
+		usage += fmt.Sprintf(" /%s - removes the %s.", field.command, field.name)
+	}
+	return func(ctx *ext.Context, u *ext.Update) error {
+		chatId := u.EffectiveChat().GetID()
+		peerChatId := ctx.PeerStorage.GetPeerById(chatId)
+		if peerChatId.Type != int(storage.TypeUser) {
+			return dispatcher.EndGroups
+		}
+		trackUser(u)
+		if !isAllowed(ctx, chatId) {
+			ctx.Reply(u, "You are not allowed to use this bot.", nil)
+			return dispatcher.EndGroups
+		}
+		link, value, err := metadataTarget(u, chatId)
+		if err != nil {
+			ctx.Reply(u, fmt.Sprintf("Error - %s", err.Error()), nil)
+			return dispatcher.EndGroups
+		}
+		if value == "" || (value == "-" && field.command == "rename") {
+			ctx.Reply(u, usage, nil)
+			return dispatcher.EndGroups
+		}
+		if len(value) > maxMetadataLength {
+			ctx.Reply(u, fmt.Sprintf("The %s can be %d characters at most.", field.name, maxMetadataLength), nil)
+			return dispatcher.EndGroups
+		}
+		if value == "-" {
+			value = ""
+		}
+		field.set(link, value)
+		if link.FileName == "" {
+			ctx.Reply(u, "This file name has nothing left once cleaned up, choose another one.", nil)
+			return dispatcher.EndGroups
+		}
+		if err := database.GetLinkRepository().UpdateMetadata(link); err != nil {
+			utils.Logger.Error("Failed to update metadata", zap.Error(err), zap.String("link", link.StorageKey()))
+			ctx.Reply(u, fmt.Sprintf("Error - %s", err.Error()), nil)
+			return dispatcher.EndGroups
+		}
+		if link.ReplyID != 0 {
+			message, markup := utils.LinkReply(link)
+			_, err := ctx.EditMessage(chatId, &tg.MessagesEditMessageRequest{
+				ID:          link.ReplyID,
+				Message:     message,
+				ReplyMarkup: markup,
+			})
+			if err != nil {
+				utils.Logger.Debug("Failed to edit link reply", zap.Error(err), zap.String("link", link.StorageKey()))
+			}
+		}
+		ctx.Reply(u, fmt.Sprintf("✅ Updated the %s of %s.\n\nTitle: %s\nPerformer: %s", field.name, link.FileName,
+			orNone(link.Title), orNone(link.Performer)), nil)
+		return dispatcher.EndGroups
+	}
+}
+
+// metadataTarget returns the link a metadata command changes and the new value
+func metadataTarget(u *ext.Update, userID int64) (*types.Link, string, error) {
+	if _, ok := u.EffectiveMessage.ReplyTo.(*tg.MessageReplyHeader); ok {
+		link, err := repliedLink(u)
+		return link, strings.TrimSpace(argsAfter(u.EffectiveMessage.Text, 1)), err
+	}
+	args := u.Args()
+	if len(args) < 2 {
+		return nil, "", errors.New("please reply to a media message you have generated a link for, or give the id of its link")
+	}
+	messageID, err := strconv.Atoi(args[1])
+	if err != nil {
+		return nil, "", errors.New("the link id must be a number")
+	}
+	linkRepository := database.GetLinkRepository()
+	if linkRepository == nil {
+		return nil, "", errors.New("link database is not available at the moment")
+	}
+	var tenantID uint
+	if workspace := access.Tenant(userID); workspace != nil {
+		tenantID = workspace.ID
+	}
+	link, err := linkRepository.Get(tenantID, messageID)
+	if err != nil || link.UserID != userID {
+		return nil, "", errors.New("you have no link with this id")
+	}
+	return link, strings.TrimSpace(argsAfter(u.EffectiveMessage.Text, 2)), nil
+}
+
+func orNone(value string) string {
+	if value == "" {
+		return "none"
+	}
+	return value
+}
@@ -0,0 +1,200 @@
+package commands
+
+import (
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/types"
+	"EverythingSuckz/fsb/internal/utils"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/dispatcher/handlers"
+	"github.com/celestix/gotgproto/ext"
+	"go.uber.org/zap"
+)
+
+// tagPattern is the format of the tags of users
+var tagPattern = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+
+func (m *command) LoadNotes(dispatcher dispatcher.Dispatcher) {
+	log := m.log.Named("notes")
+	defer log.Sugar().Info("Loaded")
+	dispatcher.AddHandler(handlers.NewCommand("note", note))
+	dispatcher.AddHandler(handlers.NewCommand("delnote", deleteNote))
+	dispatcher.AddHandler(handlers.NewCommand("tag", tag))
+	dispatcher.AddHandler(handlers.NewCommand("untag", tag))
+	dispatcher.AddHandler(handlers.NewCommand("userinfo", userInfo))
+}
+
+// note stores a free-form note about a user, like "friend of X" or "trial until May"
+func note(ctx *ext.Context, u *ext.Update) error {
+	userID, _, ok := userAdminCommand(ctx, u, "Usage: /note <user_id> <text>")
+	if !ok {
+		return dispatcher.EndGroups
+	}
+	noteRepository := database.GetNoteRepository()
+	if noteRepository == nil {
+		ctx.Reply(u, "❌ Note database is not available at the moment.", nil)
+		return dispatcher.EndGroups
+	}
+	text := strings.TrimSpace(argsAfter(u.EffectiveMessage.Text, 2))
+	if text == "" {
+		ctx.Reply(u, "Usage: /note <user_id> <text>", nil)
+		return dispatcher.EndGroups
+	}
+	entry := &types.UserNote{UserID: userID, Text: text, CreatedBy: u.EffectiveChat().GetID()}
+	if err := noteRepository.AddNote(entry); err != nil {
+		utils.Logger.Error("Failed to store note", zap.Error(err), zap.Int64("userID", userID))
+		ctx.Reply(u, fmt.Sprintf("Error - %s", err.Error()), nil)
+		return dispatcher.EndGroups
+	}
+	ctx.Reply(u, fmt.Sprintf("📝 Added note #%d to user %d, see /userinfo %d", entry.ID, userID, userID), nil)
+	return dispatcher.EndGroups
+}
+
+func deleteNote(ctx *ext.Context, u *ext.Update) error {
+	id, _, ok := userAdminCommand(ctx, u, "Usage: /delnote <note_id>")
+	if !ok {
+		return dispatcher.EndGroups
+	}
+	noteRepository := database.GetNoteRepository()
+	if noteRepository == nil {
+		ctx.Reply(u, "❌ Note database is not available at the moment.", nil)
+		return dispatcher.EndGroups
+	}
+	deleted, err := noteRepository.DeleteNote(uint(id))
+	if err != nil {
+		ctx.Reply(u, fmt.Sprintf("Error - %s", err.Error()), nil)
+		return dispatcher.EndGroups
+	}
+	if !deleted {
+		ctx.Reply(u, "Note not found.", nil)
+		return dispatcher.EndGroups
+	}
+	ctx.Reply(u, fmt.Sprintf("✅ Deleted note #%d.", id), nil)
+	return dispatcher.EndGroups
+}
+
+// tag handles /tag <user_id> <tag> and /untag <user_id> <tag>
+func tag(ctx *ext.Context, u *ext.Update) error {
+	args := u.Args()
+	remove := strings.HasPrefix(args[0], "/untag")
+	usage := "Usage: /tag <user_id> <tag>"
+	if remove {
+		usage = "Usage: /untag <user_id> <tag>"
+	}
+	userID, _, ok := userAdminCommand(ctx, u, usage)
+	if !ok {
+		return dispatcher.EndGroups
+	}
+	if len(args) < 3 {
+		ctx.Reply(u, usage, nil)
+		return dispatcher.EndGroups
+	}
+	name := normalizeTag(args[2])
+	if !tagPattern.MatchString(name) {
+		ctx.Reply(u, "Tags can only contain letters, digits, _ and -, up to 32 characters.", nil)
+		return dispatcher.EndGroups
+	}
+	noteRepository := database.GetNoteRepository()
+	if noteRepository == nil {
+		ctx.Reply(u, "❌ Note database is not available at the moment.", nil)
+		return dispatcher.EndGroups
+	}
+	if remove {
+		removed, err := noteRepository.RemoveTag(userID, name)
+		if err != nil {
+			ctx.Reply(u, fmt.Sprintf("Error - %s", err.Error()), nil)
+			return dispatcher.EndGroups
+		}
+		if !removed {
+			ctx.Reply(u, fmt.Sprintf("User %d isn't tagged #%s.", userID, name), nil)
+			return dispatcher.EndGroups
+		}
+		ctx.Reply(u, fmt.Sprintf("✅ Removed #%s from user %d.", name, userID), nil)
+		return dispatcher.EndGroups
+	}
+	if err := noteRepository.AddTag(userID, name, u.EffectiveChat().GetID()); err != nil {
+		utils.Logger.Error("Failed to tag user", zap.Error(err), zap.Int64("userID", userID))
+		ctx.Reply(u, fmt.Sprintf("Error - %s", err.Error()), nil)
+		return dispatcher.EndGroups
+	}
+	ctx.Reply(u, fmt.Sprintf("🏷 Tagged user %d #%s. Find the tagged users with /listusers tag:%s", userID, name, name), nil)
+	return dispatcher.EndGroups
+}
+
+// userInfo shows what is known about a user, with the notes and tags of the admins
+func userInfo(ctx *ext.Context, u *ext.Update) error {
+	userID, userRepository, ok := userAdminCommand(ctx, u, "Usage: /userinfo <user_id>")
+	if !ok {
+		return dispatcher.EndGroups
+	}
+	user, err := userRepository.Get(userID)
+	if err != nil {
+		ctx.Reply(u, fmt.Sprintf("Error - %s", err.Error()), nil)
+		return dispatcher.EndGroups
+	}
+	if user == nil {
+		ctx.Reply(u, "User not found.", nil)
+		return dispatcher.EndGroups
+	}
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("👤 %s\n\n", formatUser(*user)))
+	if user.FirstName != "" {
+		sb.WriteString(fmt.Sprintf("Name: %s\n", user.FirstName))
+	}
+	status := userStatus(*user)
+	if strings.HasPrefix(status, "· ") {
+		status = "no access"
+	}
+	sb.WriteString(fmt.Sprintf("Status: %s\n", status))
+	sb.WriteString(fmt.Sprintf("Joined: %s\n", user.CreatedAt.Format("2006-01-02 15:04")))
+	if user.LastSeen != nil {
+		sb.WriteString(fmt.Sprintf("Last seen: %s\n", user.LastSeen.Format("2006-01-02 15:04")))
+	}
+	if user.TenantID != 0 {
+		sb.WriteString(fmt.Sprintf("Tenant: %d\n", user.TenantID))
+	}
+	if user.InvitedBy != 0 {
+		sb.WriteString(fmt.Sprintf("Invited by: %d\n", user.InvitedBy))
+	}
+	if user.Referral != "" {
+		sb.WriteString(fmt.Sprintf("Referral: %s\n", user.Referral))
+	}
+	if user.Flagged {
+		sb.WriteString(fmt.Sprintf("🚩 Flagged: %s\n", user.FlagReason))
+	}
+	if noteRepository := database.GetNoteRepository(); noteRepository != nil {
+		if tags, err := noteRepository.ListTags(userID); err == nil && len(tags) > 0 {
+			sb.WriteString("Tags: #" + strings.Join(tags, " #") + "\n")
+		}
+		if notes, err := noteRepository.ListNotes(userID); err == nil && len(notes) > 0 {
+			sb.WriteString("\n📝 Notes\n")
+			for _, entry := range notes {
+				sb.WriteString(fmt.Sprintf("#%d %s by %d: %s\n", entry.ID, entry.CreatedAt.Format("2006-01-02"), entry.CreatedBy, entry.Text))
+			}
+			sb.WriteString("\nDelete a note with /delnote <note_id>")
+		}
+	}
+	ctx.Reply(u, sb.String(), nil)
+	return dispatcher.EndGroups
+}
+
+// normalizeTag lowercases a tag and strips its # prefix
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimPrefix(tag, "#"))
+}
+
+// argsAfter returns the text after the first n words, keeping its line breaks
+func argsAfter(text string, n int) string {
+	for i := 0; i < n; i++ {
+		text = strings.TrimLeft(text, " \n")
+		j := strings.IndexAny(text, " \n")
+		if j < 0 {
+			return ""
+		}
+		text = text[j:]
+	}
+	return text
+}
@@ -0,0 +1,70 @@
+package commands
+
+import (
+	"EverythingSuckz/fsb/internal/library"
+	"EverythingSuckz/fsb/internal/utils"
+	"fmt"
+	"slices"
+	"strconv"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/dispatcher/handlers"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/celestix/gotgproto/storage"
+	"go.uber.org/zap"
+)
+
+// playlistSize is the number of recent files in a playlist by default, like the queue of the player
+const playlistSize = 20
+
+const playlistUsage = `Usage: /playlist [video|audio] [count]
+
+Sends your recent files as an M3U playlist for VLC, Kodi or your car, the last 20 by default.`
+
+func (m *command) LoadPlaylist(dispatcher dispatcher.Dispatcher) {
+	log := m.log.Named("playlist")
+	defer log.Sugar().Info("Loaded")
+	dispatcher.AddHandler(handlers.NewCommand("playlist", playlist))
+}
+
+// playlist sends the recent videos and audio files of the user as an M3U playlist
+func playlist(ctx *ext.Context, u *ext.Update) error {
+	chatId := u.EffectiveChat().GetID()
+	peerChatId := ctx.PeerStorage.GetPeerById(chatId)
+	if peerChatId.Type != int(storage.TypeUser) {
+		return dispatcher.EndGroups
+	}
+	trackUser(u)
+	if !isAllowed(ctx, chatId) {
+		ctx.Reply(u, "You are not allowed to use this bot.", nil)
+		return dispatcher.EndGroups
+	}
+	kind, count := "", playlistSize
+	for _, arg := range u.Args()[1:] {
+		if slices.Contains(library.PlaylistKinds, arg) {
+			kind = arg
+		} else if n, err := strconv.Atoi(arg); err == nil && n > 0 && n <= library.MaxItems {
+			count = n
+		} else {
+			ctx.Reply(u, playlistUsage, nil)
+			return dispatcher.EndGroups
+		}
+	}
+	links, err := library.Recent(chatId, count)
+	if err != nil {
+		ctx.Reply(u, fmt.Sprintf("Error - %s", err.Error()), nil)
+		return dispatcher.EndGroups
+	}
+	content, entries := library.Playlist(links, kind)
+	if entries == 0 {
+		ctx.Reply(u, "You haven't sent any videos or audio files yet.", nil)
+		return dispatcher.EndGroups
+	}
+	caption := fmt.Sprintf("🎶 %d files, open the playlist in VLC, Kodi or another player.\n\n"+
+		"Signed in with /weblogin, %s downloads it too.", entries, utils.PublicURL("/api/playlist.m3u8"))
+	if err := sendTextFile(ctx, chatId, u.EffectiveMessage.ID, "playlist.m3u8", "audio/x-mpegurl", content, caption); err != nil {
+		utils.Logger.Error("Failed to send playlist", zap.Error(err))
+		ctx.Reply(u, "❌ Failed to create the playlist.", nil)
+	}
+	return dispatcher.EndGroups
+}
@@ -0,0 +1,47 @@
+package commands
+
+import (
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/utils"
+	"EverythingSuckz/fsb/internal/webauth"
+	"fmt"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/dispatcher/handlers"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/celestix/gotgproto/storage"
+)
+
+func (m *command) LoadPodcast(dispatcher dispatcher.Dispatcher) {
+	log := m.log.Named("podcast")
+	defer log.Sugar().Info("Loaded")
+	dispatcher.AddHandler(handlers.NewCommand("podcast", podcast))
+}
+
+// podcast sends the URL of the podcast feed with the audio files of the user
+func podcast(ctx *ext.Context, u *ext.Update) error {
+	chatId := u.EffectiveChat().GetID()
+	peerChatId := ctx.PeerStorage.GetPeerById(chatId)
+	if peerChatId.Type != int(storage.TypeUser) {
+		return dispatcher.EndGroups
+	}
+	trackUser(u)
+	if !isAllowed(ctx, chatId) {
+		ctx.Reply(u, "You are not allowed to use this bot.", nil)
+		return dispatcher.EndGroups
+	}
+	userRepository := database.GetUserRepository().WithContext(ctx)
+	if userRepository == nil {
+		ctx.Reply(u, "❌ User database is not available at the moment.", nil)
+		return dispatcher.EndGroups
+	}
+	version, err := userRepository.TokenVersion(chatId)
+	if err != nil {
+		ctx.Reply(u, fmt.Sprintf("Error - %s", err.Error()), nil)
+		return dispatcher.EndGroups
+	}
+	ctx.Reply(u, fmt.Sprintf("🎧 Subscribe to this feed in a podcast app to get the audio files you send here as episodes:\n%s\n\n"+
+		"Don't share it, whoever has it can listen to your audio files. /revokeall revokes it.",
+		utils.PublicURL("/feed/"+webauth.FeedToken(chatId, version)+".xml")), nil)
+	return dispatcher.EndGroups
+}
@@ -0,0 +1,128 @@
+package commands
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/crash"
+	"EverythingSuckz/fsb/internal/media"
+	"EverythingSuckz/fsb/internal/utils"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/dispatcher/handlers"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/celestix/gotgproto/storage"
+	"github.com/gotd/td/telegram/uploader"
+	"github.com/gotd/td/tg"
+	"go.uber.org/zap"
+)
+
+func (m *command) LoadPreview(dispatcher dispatcher.Dispatcher) {
+	log := m.log.Named("preview")
+	defer log.Sugar().Info("Loaded")
+	dispatcher.AddHandler(handlers.NewCommand("preview", preview))
+}
+
+func preview(ctx *ext.Context, u *ext.Update) error {
+	chatId := u.EffectiveChat().GetID()
+	peerChatId := ctx.PeerStorage.GetPeerById(chatId)
+	if peerChatId.Type != int(storage.TypeUser) {
+		return dispatcher.EndGroups
+	}
+	trackUser(u)
+	if !isAllowed(ctx, chatId) {
+		ctx.Reply(u, "You are not allowed to use this bot.", nil)
+		return dispatcher.EndGroups
+	}
+	if !media.Enabled() {
+		ctx.Reply(u, "Previews are not enabled on this bot.", nil)
+		return dispatcher.EndGroups
+	}
+	link, err := repliedLink(u)
+	if err != nil {
+		ctx.Reply(u, fmt.Sprintf("Error - %s", err.Error()), nil)
+		return dispatcher.EndGroups
+	}
+	if !strings.Contains(link.MimeType, "video") {
+		ctx.Reply(u, "Previews can only be generated for videos.", nil)
+		return dispatcher.EndGroups
+	}
+	var start float64
+	if args := u.Args(); len(args) > 1 {
+		start, err = utils.ParseTimestamp(args[1])
+		if err != nil {
+			ctx.Reply(u, "Usage: /preview [start], eg. /preview 1:30", nil)
+			return dispatcher.EndGroups
+		}
+	}
+	status, err := ctx.Reply(u, "⏳ Generating preview...", &ext.ReplyOpts{ReplyToMessageId: u.EffectiveMessage.ID})
+	if err != nil {
+		return dispatcher.EndGroups
+	}
+	go func() {
+		defer crash.Recover("preview")
+		defer ctx.DeleteMessages(chatId, []int{status.ID})
+		jobCtx, cancel := context.WithTimeout(ctx, 3*time.Minute)
+		defer cancel()
+		duration := float64(config.ValueOf.PreviewDuration)
+		path, err := media.Preview(jobCtx, utils.InternalStreamURL(link.TenantID, link.MessageID, link.Hash), start, duration)
+		if err != nil {
+			utils.Logger.Error("Failed to generate preview", zap.Error(err), zap.Int("messageID", link.MessageID))
+			ctx.Reply(u, "❌ Failed to generate the preview.", nil)
+			return
+		}
+		defer os.Remove(path)
+		attributes := []tg.DocumentAttributeClass{
+			&tg.DocumentAttributeVideo{SupportsStreaming: true, Duration: duration},
+			&tg.DocumentAttributeFilename{FileName: "preview_" + strings.TrimSuffix(link.FileName, filepath.Ext(link.FileName)) + ".mp4"},
+		}
+		caption := fmt.Sprintf("🎬 Preview of %s", link.FileName)
+		if err := sendDocument(ctx, chatId, u.EffectiveMessage.ID, path, "video/mp4", attributes, caption); err != nil {
+			utils.Logger.Error("Failed to send preview", zap.Error(err))
+			ctx.Reply(u, fmt.Sprintf("Error - %s", err.Error()), nil)
+		}
+	}()
+	return dispatcher.EndGroups
+}
+
+// sendDocument uploads the file at path and sends it as a reply to the given message
+func sendDocument(ctx *ext.Context, chatId int64, replyTo int, path string, mimeType string, attributes []tg.DocumentAttributeClass, caption string) error {
+	file, err := uploader.NewUploader(ctx.Raw).FromPath(ctx, path)
+	if err != nil {
+		return err
+	}
+	_, err = ctx.SendMedia(chatId, &tg.MessagesSendMediaRequest{
+		Media: &tg.InputMediaUploadedDocument{
+			File:       file,
+			MimeType:   mimeType,
+			Attributes: attributes,
+		},
+		Message: caption,
+		ReplyTo: &tg.InputReplyToMessage{ReplyToMsgID: replyTo},
+	})
+	return err
+}
+
+// sendTextFile sends the content as a document with the file name
+func sendTextFile(ctx *ext.Context, chatId int64, replyTo int, name string, mimeType string, content string, caption string) error {
+	file, err := os.CreateTemp("", "*"+filepath.Ext(name))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	_, err = file.WriteString(content)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	attributes := []tg.DocumentAttributeClass{
+		&tg.DocumentAttributeFilename{FileName: name},
+	}
+	return sendDocument(ctx, chatId, replyTo, file.Name(), mimeType, attributes, caption)
+}
@@ -0,0 +1,114 @@
+package commands
+
+import (
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/utils"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/dispatcher/handlers"
+	"github.com/celestix/gotgproto/dispatcher/handlers/filters"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/celestix/gotgproto/functions"
+	"github.com/celestix/gotgproto/storage"
+	"github.com/gotd/td/tg"
+	"go.uber.org/zap"
+)
+
+func (m *command) LoadQuarantine(dispatcher dispatcher.Dispatcher) {
+	log := m.log.Named("quarantine")
+	defer log.Sugar().Info("Loaded")
+	dispatcher.AddHandler(handlers.NewCommand("quarantine", quarantine))
+	dispatcher.AddHandler(handlers.NewCallbackQuery(filters.CallbackQuery.Prefix("qreview:"), quarantineCallback))
+}
+
+func quarantine(ctx *ext.Context, u *ext.Update) error {
+	chatId := u.EffectiveChat().GetID()
+	peerChatId := ctx.PeerStorage.GetPeerById(chatId)
+	if peerChatId.Type != int(storage.TypeUser) {
+		return dispatcher.EndGroups
+	}
+	if !utils.IsAdmin(ctx, ctx.Raw, ctx.PeerStorage, chatId) {
+		ctx.Reply(u, "This command is only available to admins.", nil)
+		return dispatcher.EndGroups
+	}
+	message, markup, err := quarantineMessage()
+	if err != nil {
+		utils.Logger.Error("Failed to list quarantined files", zap.Error(err))
+		ctx.Reply(u, "❌ Failed to retrieve quarantined files. Please try again later.", nil)
+		return dispatcher.EndGroups
+	}
+	ctx.Reply(u, message, &ext.ReplyOpts{Markup: markup})
+	return dispatcher.EndGroups
+}
+
+func quarantineCallback(ctx *ext.Context, u *ext.Update) error {
+	query := u.CallbackQuery
+	if !utils.IsAdmin(ctx, ctx.Raw, ctx.PeerStorage, query.UserID) {
+		ctx.AnswerCallback(&tg.MessagesSetBotCallbackAnswerRequest{
+			QueryID: query.QueryID,
+			Message: "This action is only available to admins.",
+			Alert:   true,
+		})
+		return dispatcher.EndGroups
+	}
+	id, err := strconv.ParseUint(strings.TrimPrefix(string(query.Data), "qreview:"), 10, 64)
+	quarantineRepository := database.GetQuarantineRepository()
+	if err != nil || quarantineRepository == nil {
+		return dispatcher.EndGroups
+	}
+	answer := "Marked as reviewed"
+	if err := quarantineRepository.MarkReviewed(uint(id)); err != nil {
+		answer = fmt.Sprintf("Error - %s", err.Error())
+	}
+	ctx.AnswerCallback(&tg.MessagesSetBotCallbackAnswerRequest{
+		QueryID: query.QueryID,
+		Message: answer,
+	})
+	message, markup, err := quarantineMessage()
+	if err != nil {
+		return dispatcher.EndGroups
+	}
+	ctx.EditMessage(functions.GetChatIdFromPeer(query.Peer), &tg.MessagesEditMessageRequest{
+		ID:          query.MsgID,
+		Message:     message,
+		ReplyMarkup: markup,
+	})
+	return dispatcher.EndGroups
+}
+
+func quarantineMessage() (string, tg.ReplyMarkupClass, error) {
+	quarantineRepository := database.GetQuarantineRepository()
+	if quarantineRepository == nil {
+		return "", nil, fmt.Errorf("quarantine database is not available")
+	}
+	items, err := quarantineRepository.ListPending(20)
+	if err != nil {
+		return "", nil, err
+	}
+	markup := &tg.ReplyInlineMarkup{Rows: []tg.KeyboardButtonRow{}}
+	if len(items) == 0 {
+		return "✅ No quarantined files to review.", markup, nil
+	}
+	message := "🦠 Quarantined files\n\n"
+	for _, item := range items {
+		message += fmt.Sprintf("#%d %s (%s) from [%d]\n%s - %s\n\n",
+			item.ID,
+			item.FileName,
+			utils.FormatFileSizeShort(item.FileSize),
+			item.UserID,
+			item.Signature,
+			item.CreatedAt.Format("2006-01-02 15:04"))
+		markup.Rows = append(markup.Rows, tg.KeyboardButtonRow{
+			Buttons: []tg.KeyboardButtonClass{
+				&tg.KeyboardButtonCallback{
+					Text: fmt.Sprintf("✅ Reviewed #%d", item.ID),
+					Data: []byte(fmt.Sprintf("qreview:%d", item.ID)),
+				},
+			},
+		})
+	}
+	return message, markup, nil
+}
@@ -0,0 +1,122 @@
+package commands
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/profile"
+	"EverythingSuckz/fsb/internal/types"
+	"EverythingSuckz/fsb/internal/utils"
+	"fmt"
+	"strings"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/dispatcher/handlers"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/gotd/td/tg"
+	"go.uber.org/zap"
+)
+
+// Actions that reactions to link replies can run
+const (
+	reactionBump   = "bump"   // move the link to the top of the player queue
+	reactionRevoke = "revoke" // revoke the link
+)
+
+// reactionActions maps the emojis of REACTION_ACTIONS to their action
+var reactionActions = make(map[string]string)
+
+func (m *command) LoadReactions(dispatcher dispatcher.Dispatcher) {
+	log := m.log.Named("reactions")
+	defer log.Sugar().Info("Loaded")
+	for _, entry := range config.ValueOf.ReactionActions {
+		if strings.TrimSpace(entry) == "none" {
+			continue
+		}
+		emoji, action, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok || (action != reactionBump && action != reactionRevoke) {
+			log.Sugar().Warnf("Ignoring invalid reaction action %q, use emoji:bump or emoji:revoke", entry)
+			continue
+		}
+		reactionActions[normalizeEmoji(emoji)] = action
+	}
+	dispatcher.AddHandler(handlers.NewAnyUpdate(reactionUpdate))
+}
+
+// reactionUpdate runs the action of the reactions the owner of a link added to its reply
+func reactionUpdate(ctx *ext.Context, u *ext.Update) error {
+	update, ok := u.UpdateClass.(*tg.UpdateBotMessageReaction)
+	if !ok {
+		return nil
+	}
+	peer, ok := update.Peer.(*tg.PeerUser)
+	if !ok || len(reactionActions) == 0 {
+		return dispatcher.EndGroups
+	}
+	if actor, ok := update.Actor.(*tg.PeerUser); !ok || actor.UserID != peer.UserID {
+		return dispatcher.EndGroups
+	}
+	linkRepository := database.GetLinkRepository()
+	if linkRepository == nil {
+		return dispatcher.EndGroups
+	}
+	link, err := linkRepository.FindByUserMessage(peer.UserID, update.MsgID)
+	if err != nil || link.ReplyID != update.MsgID {
+		return dispatcher.EndGroups
+	}
+	for _, action := range addedReactionActions(update.OldReactions, update.NewReactions) {
+		runReactionAction(ctx, link, action)
+	}
+	return dispatcher.EndGroups
+}
+
+// addedReactionActions returns the actions of the reactions that weren't there before
+func addedReactionActions(old []tg.ReactionClass, new []tg.ReactionClass) []string {
+	previous := make(map[string]bool, len(old))
+	for _, reaction := range old {
+		if emoji, ok := reaction.(*tg.ReactionEmoji); ok {
+			previous[normalizeEmoji(emoji.Emoticon)] = true
+		}
+	}
+	var actions []string
+	for _, reaction := range new {
+		emoji, ok := reaction.(*tg.ReactionEmoji)
+		if !ok || previous[normalizeEmoji(emoji.Emoticon)] {
+			continue
+		}
+		if action, ok := reactionActions[normalizeEmoji(emoji.Emoticon)]; ok {
+			actions = append(actions, action)
+		}
+	}
+	return actions
+}
+
+func runReactionAction(ctx *ext.Context, link *types.Link, action string) {
+	linkRepository := database.GetLinkRepository()
+	switch action {
+	case reactionBump:
+		if err := linkRepository.Bump(link.TenantID, link.MessageID); err != nil {
+			utils.Logger.Error("Failed to bump link", zap.Error(err), zap.Int("messageID", link.MessageID))
+			return
+		}
+		profile.Notify(profile.Of(link.UserID))
+	case reactionRevoke:
+		revoked, err := linkRepository.Revoke(link.TenantID, link.MessageID)
+		if err != nil {
+			utils.Logger.Error("Failed to revoke link", zap.Error(err), zap.Int("messageID", link.MessageID))
+			return
+		}
+		if !revoked {
+			return
+		}
+		profile.Notify(profile.Of(link.UserID))
+		ctx.EditMessage(link.UserID, &tg.MessagesEditMessageRequest{
+			ID:      link.ReplyID,
+			Message: fmt.Sprintf("📄 File Name: %s\n\n🗑 This link was revoked, send the file again to get a new one.", link.FileName),
+		})
+	}
+}
+
+// normalizeEmoji strips the variation selectors, which Telegram leaves out of reactions
+func normalizeEmoji(emoji string) string {
+	return strings.ReplaceAll(strings.TrimSpace(emoji), "\uFE0F", "")
+}
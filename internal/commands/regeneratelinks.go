@@ -0,0 +1,137 @@
+package commands
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/crash"
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/tenant"
+	"EverythingSuckz/fsb/internal/types"
+	"EverythingSuckz/fsb/internal/utils"
+	"fmt"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/dispatcher/handlers"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/celestix/gotgproto/storage"
+	"github.com/gotd/td/tg"
+	"go.uber.org/zap"
+)
+
+func (m *command) LoadRegenerateLinks(dispatcher dispatcher.Dispatcher) {
+	log := m.log.Named("regeneratelinks")
+	defer log.Sugar().Info("Loaded")
+	dispatcher.AddHandler(handlers.NewCommand("regeneratelinks", regenerateLinks))
+}
+
+// regenerateLinks gives the links generated with another HASH_LENGTH a hash of the current
+// length and updates their bot replies. Links sent before keep working with their old hash.
+func regenerateLinks(ctx *ext.Context, u *ext.Update) error {
+	chatId := u.EffectiveChat().GetID()
+	peerChatId := ctx.PeerStorage.GetPeerById(chatId)
+	if peerChatId.Type != int(storage.TypeUser) {
+		return dispatcher.EndGroups
+	}
+	if !utils.IsAdmin(ctx, ctx.Raw, ctx.PeerStorage, chatId) {
+		ctx.Reply(u, "This command is only available to admins.", nil)
+		return dispatcher.EndGroups
+	}
+	linkRepository := database.GetLinkRepository()
+	if linkRepository == nil {
+		ctx.Reply(u, "❌ Link database is not available at the moment.", nil)
+		return dispatcher.EndGroups
+	}
+	_, dryRun := cutDryRun(u.EffectiveMessage.Text)
+	links, err := linkRepository.ListOutdatedHashes(config.ValueOf.HashLength)
+	if err != nil {
+		ctx.Reply(u, fmt.Sprintf("Error - %s", err.Error()), nil)
+		return dispatcher.EndGroups
+	}
+	if len(links) == 0 {
+		ctx.Reply(u, fmt.Sprintf("✅ All links have hashes of %d characters already.", config.ValueOf.HashLength), nil)
+		return dispatcher.EndGroups
+	}
+	if dryRun {
+		lines := make([]string, len(links))
+		for i, link := range links {
+			lines[i] = fmt.Sprintf("%s (%d) %s of %d", link.StorageKey(), len(link.Hash), link.FileName, link.UserID)
+		}
+		summary := fmt.Sprintf("%d links would get hashes of %d characters.", len(links), config.ValueOf.HashLength)
+		if err := replyDryRun(ctx, chatId, u.EffectiveMessage.ID, summary, lines, "regeneratelinks-dry-run.txt"); err != nil {
+			utils.Logger.Error("Failed to send dry run report", zap.Error(err))
+		}
+		return dispatcher.EndGroups
+	}
+	question := fmt.Sprintf("🔁 Regenerate %d links with hashes of %d characters? Their bot replies show the new links, the old ones keep working.", len(links), config.ValueOf.HashLength)
+	confirm(ctx, u, question, func(ctx *ext.Context) string {
+		go func() {
+			defer crash.Recover("regeneratelinks")
+			regenerated, failed := 0, 0
+			for i := range links {
+				if err := regenerateLink(ctx, &links[i]); err != nil {
+					utils.Logger.Error("Failed to regenerate link", zap.Error(err), zap.String("link", links[i].StorageKey()))
+					failed++
+					continue
+				}
+				regenerated++
+			}
+			utils.Logger.Info("Regenerated links", zap.Int("links", regenerated), zap.Int("failed", failed), zap.Int64("by", chatId))
+			ctx.SendMessage(chatId, &tg.MessagesSendMessageRequest{
+				Message: fmt.Sprintf("🔁 Regenerated %d links, %d failed.", regenerated, failed),
+			})
+		}()
+		return fmt.Sprintf("⏳ Regenerating %d links, this takes about %s.", len(links), formatWait(estimateSendDuration(len(links))))
+	})
+	return dispatcher.EndGroups
+}
+
+// regenerateLink stores a hash of HASH_LENGTH for the link and edits its bot reply. Shorter hashes
+// are cut from the current one, longer ones need the file of the link from the log channel.
+func regenerateLink(ctx *ext.Context, link *types.Link) error {
+	hash := link.Hash
+	if len(hash) >= config.ValueOf.HashLength {
+		hash = hash[:config.ValueOf.HashLength]
+	} else {
+		channel, err := utils.GetChannelPeer(ctx, ctx.Raw, ctx.PeerStorage, tenant.LogChannel(link.TenantID))
+		if err != nil {
+			return err
+		}
+		res, err := ctx.Raw.ChannelsGetMessages(ctx, &tg.ChannelsGetMessagesRequest{
+			Channel: channel,
+			ID:      []tg.InputMessageClass{&tg.InputMessageID{ID: link.MessageID}},
+		})
+		if err != nil {
+			return err
+		}
+		messages, ok := res.(*tg.MessagesChannelMessages)
+		if !ok || len(messages.Messages) == 0 {
+			return fmt.Errorf("unexpected response %T", res)
+		}
+		message, ok := messages.Messages[0].(*tg.Message)
+		if !ok {
+			return utils.ErrMessageDeleted
+		}
+		file, err := utils.FileFromMedia(message.Media)
+		if err != nil {
+			return err
+		}
+		hash = utils.GetShortHash(utils.PackFile(file.FileName, file.FileSize, file.MimeType, file.ID))
+	}
+	if err := database.GetLinkRepository().Regenerate(link.TenantID, link.MessageID, hash); err != nil {
+		return err
+	}
+	link.OldHash, link.Hash = link.Hash, hash
+	if link.ReplyID == 0 {
+		return nil
+	}
+	message, markup := utils.LinkReply(link)
+	_, err := ctx.EditMessage(link.UserID, &tg.MessagesEditMessageRequest{
+		ID:          link.ReplyID,
+		Message:     message,
+		ReplyMarkup: markup,
+	})
+	if err != nil {
+		// the user may have deleted the reply or blocked the bot, the link works anyway
+		utils.Logger.Debug("Failed to edit regenerated link reply", zap.Error(err), zap.String("link", link.StorageKey()))
+	}
+	return nil
+}
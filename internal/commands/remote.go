@@ -0,0 +1,94 @@
+package commands
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/sessions"
+	"fmt"
+	"strings"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/dispatcher/handlers"
+	"github.com/celestix/gotgproto/dispatcher/handlers/filters"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/celestix/gotgproto/storage"
+	"github.com/gotd/td/tg"
+)
+
+// remoteActions are the remote control actions the web player understands
+var remoteActions = map[string]bool{
+	"play":        true,
+	"pause":       true,
+	"toggle":      true,
+	"back":        true,
+	"forward":     true,
+	"volume_down": true,
+	"volume_up":   true,
+	"next":        true,
+	"latest":      true,
+}
+
+func (m *command) LoadRemote(dispatcher dispatcher.Dispatcher) {
+	log := m.log.Named("remote")
+	defer log.Sugar().Info("Loaded")
+	dispatcher.AddHandler(handlers.NewCommand("remote", remote))
+	dispatcher.AddHandler(handlers.NewCallbackQuery(filters.CallbackQuery.Prefix("remote:"), remoteCallback))
+}
+
+// remote sends a keyboard that remote controls the web players the sender has open
+func remote(ctx *ext.Context, u *ext.Update) error {
+	chatId := u.EffectiveChat().GetID()
+	peerChatId := ctx.PeerStorage.GetPeerById(chatId)
+	if peerChatId.Type != int(storage.TypeUser) {
+		return dispatcher.EndGroups
+	}
+	trackUser(u)
+	if !isAllowed(ctx, chatId) {
+		ctx.Reply(u, "You are not allowed to use this bot.", nil)
+		return dispatcher.EndGroups
+	}
+	if config.ValueOf.PublicMode {
+		ctx.Reply(u, "The web players of this bot can't be remote controlled, all links are public.", nil)
+		return dispatcher.EndGroups
+	}
+	ctx.Reply(u, "🎮 Remote control of the web players of your links.\n\nOpen a Player link, then use the buttons.", &ext.ReplyOpts{Markup: remoteMarkup()})
+	return dispatcher.EndGroups
+}
+
+// remoteCallback sends the action of remote:<action> to the web players of the user
+func remoteCallback(ctx *ext.Context, u *ext.Update) error {
+	query := u.CallbackQuery
+	action := strings.TrimPrefix(string(query.Data), "remote:")
+	answer := "This button doesn't work anymore"
+	if remoteActions[action] && !config.ValueOf.PublicMode {
+		answer = controlPlayers(query.UserID, action)
+	}
+	ctx.AnswerCallback(&tg.MessagesSetBotCallbackAnswerRequest{
+		QueryID: query.QueryID,
+		Message: answer,
+	})
+	return dispatcher.EndGroups
+}
+
+// controlPlayers sends the action to the web players of the user and describes how many got it
+func controlPlayers(userID int64, action string) string {
+	switch sent := sessions.Control(userID, action); sent {
+	case 0:
+		return "No web player of your links is open"
+	case 1:
+		return "Sent to your player"
+	default:
+		return fmt.Sprintf("Sent to %d players", sent)
+	}
+}
+
+func remoteMarkup() tg.ReplyMarkupClass {
+	button := func(text string, action string) tg.KeyboardButtonClass {
+		return &tg.KeyboardButtonCallback{Text: text, Data: []byte("remote:" + action)}
+	}
+	return &tg.ReplyInlineMarkup{
+		Rows: []tg.KeyboardButtonRow{
+			{Buttons: []tg.KeyboardButtonClass{button("⏪ 30s", "back"), button("⏯", "toggle"), button("30s ⏩", "forward")}},
+			{Buttons: []tg.KeyboardButtonClass{button("🔉", "volume_down"), button("🔊", "volume_up"), button("⏭ Next", "next")}},
+		},
+	}
+}
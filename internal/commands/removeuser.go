@@ -0,0 +1,162 @@
+package commands
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/utils"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/dispatcher/handlers"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/celestix/gotgproto/storage"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+func (m *command) LoadRemoveUser(dispatcher dispatcher.Dispatcher) {
+	log := m.log.Named("removeuser")
+	defer log.Sugar().Info("Loaded")
+	dispatcher.AddHandler(handlers.NewCommand("removeuser", removeUser))
+	dispatcher.AddHandler(handlers.NewCommand("restoreuser", restoreUser))
+}
+
+// removeUser soft-deletes a user, who can be restored with /restoreuser until the retention window ends
+func removeUser(ctx *ext.Context, u *ext.Update) error {
+	userID, _, ok := userAdminCommand(ctx, u, "Usage: /removeuser <user_id>")
+	if !ok {
+		return dispatcher.EndGroups
+	}
+	if utils.IsAdmin(ctx, ctx.Raw, ctx.PeerStorage, userID) {
+		ctx.Reply(u, "❌ Admins can't be removed.", nil)
+		return dispatcher.EndGroups
+	}
+	confirm(ctx, u, fmt.Sprintf("🗑 Remove user %s? They won't be able to use the bot anymore.", describeUserID(userID)), func(ctx *ext.Context) string {
+		userRepository := database.GetUserRepository().WithContext(ctx)
+		if userRepository == nil {
+			return "❌ User database is not available at the moment."
+		}
+		if err := userRepository.Remove(userID); errors.Is(err, gorm.ErrRecordNotFound) {
+			return "User not found."
+		} else if err != nil {
+			utils.Logger.Error("Failed to remove user", zap.Error(err), zap.Int64("userID", userID))
+			return fmt.Sprintf("Error - %s", err.Error())
+		}
+		message := fmt.Sprintf("🗑 Removed user %d. They can't use the bot anymore.", userID)
+		if days := config.ValueOf.UserRetentionDays; days > 0 {
+			message += fmt.Sprintf("\n\nUse /restoreuser %d within %d days to undo it, their data is deleted afterwards.", userID, days)
+		} else {
+			message += fmt.Sprintf("\n\nUse /restoreuser %d to undo it.", userID)
+		}
+		return message
+	})
+	return dispatcher.EndGroups
+}
+
+// restoreUser undoes /removeuser, or lists the removed users without arguments
+func restoreUser(ctx *ext.Context, u *ext.Update) error {
+	if len(u.Args()) < 2 {
+		return listRemoved(ctx, u)
+	}
+	userID, userRepository, ok := userAdminCommand(ctx, u, "Usage: /restoreuser <user_id>")
+	if !ok {
+		return dispatcher.EndGroups
+	}
+	restored, err := userRepository.Restore(userID, retentionStart())
+	if err != nil {
+		utils.Logger.Error("Failed to restore user", zap.Error(err), zap.Int64("userID", userID))
+		ctx.Reply(u, fmt.Sprintf("Error - %s", err.Error()), nil)
+		return dispatcher.EndGroups
+	}
+	if !restored {
+		ctx.Reply(u, "This user isn't removed, or was removed too long ago to be restored.", nil)
+		return dispatcher.EndGroups
+	}
+	ctx.Reply(u, fmt.Sprintf("✅ Restored user %d.", userID), nil)
+	return dispatcher.EndGroups
+}
+
+func listRemoved(ctx *ext.Context, u *ext.Update) error {
+	_, userRepository, ok := userAdminCommand(ctx, u, "")
+	if !ok {
+		return dispatcher.EndGroups
+	}
+	users, err := userRepository.ListRemoved(retentionStart(), 20)
+	if err != nil {
+		utils.Logger.Error("Failed to list removed users", zap.Error(err))
+		ctx.Reply(u, fmt.Sprintf("Error - %s", err.Error()), nil)
+		return dispatcher.EndGroups
+	}
+	if len(users) == 0 {
+		ctx.Reply(u, "No removed users to restore.\n\nUsage: /restoreuser <user_id>", nil)
+		return dispatcher.EndGroups
+	}
+	var sb strings.Builder
+	sb.WriteString("🗑 Removed users\n\n")
+	for _, user := range users {
+		sb.WriteString(fmt.Sprintf("• %d", user.ID))
+		if user.Username != "" {
+			sb.WriteString(" @" + user.Username)
+		}
+		sb.WriteString(fmt.Sprintf(", removed %s\n", user.DeletedAt.Time.Format("2006-01-02 15:04")))
+	}
+	sb.WriteString("\nUsage: /restoreuser <user_id>")
+	ctx.Reply(u, sb.String(), nil)
+	return dispatcher.EndGroups
+}
+
+// userAdminCommand checks that a bot admin sent the command in a private chat and parses the
+// user ID argument, unless usage is empty. It replies and returns false if the command can't run.
+func userAdminCommand(ctx *ext.Context, u *ext.Update, usage string) (int64, *database.UserRepository, bool) {
+	chatId := u.EffectiveChat().GetID()
+	peerChatId := ctx.PeerStorage.GetPeerById(chatId)
+	if peerChatId.Type != int(storage.TypeUser) {
+		return 0, nil, false
+	}
+	if !utils.IsAdmin(ctx, ctx.Raw, ctx.PeerStorage, chatId) {
+		ctx.Reply(u, "This command is only available to admins.", nil)
+		return 0, nil, false
+	}
+	userRepository := database.GetUserRepository().WithContext(ctx)
+	if userRepository == nil {
+		ctx.Reply(u, "❌ User database is not available at the moment.", nil)
+		return 0, nil, false
+	}
+	if usage == "" {
+		return 0, userRepository, true
+	}
+	args := u.Args()
+	if len(args) < 2 {
+		ctx.Reply(u, usage, nil)
+		return 0, nil, false
+	}
+	userID, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		ctx.Reply(u, "Invalid user ID.", nil)
+		return 0, nil, false
+	}
+	return userID, userRepository, true
+}
+
+// describeUserID returns the user ID with the username or name of the user if they are known,
+// so that admins can check they typed the right ID before confirming
+func describeUserID(userID int64) string {
+	if userRepository := database.GetUserRepository(); userRepository != nil {
+		if user, err := userRepository.Get(userID); err == nil && user != nil {
+			return formatUser(*user)
+		}
+	}
+	return fmt.Sprintf("[%d]", userID)
+}
+
+// retentionStart returns the time before which removed users can't be restored anymore
+func retentionStart() time.Time {
+	if days := config.ValueOf.UserRetentionDays; days > 0 {
+		return time.Now().AddDate(0, 0, -days)
+	}
+	return time.Time{}
+}
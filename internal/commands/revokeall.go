@@ -0,0 +1,88 @@
+package commands
+
+import (
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/sessions"
+	"EverythingSuckz/fsb/internal/utils"
+	"fmt"
+	"strconv"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/dispatcher/handlers"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/celestix/gotgproto/storage"
+	"go.uber.org/zap"
+)
+
+func (m *command) LoadRevokeAll(dispatcher dispatcher.Dispatcher) {
+	log := m.log.Named("revokeall")
+	defer log.Sugar().Info("Loaded")
+	dispatcher.AddHandler(handlers.NewCommand("revokeall", revokeAll))
+}
+
+// revokeAll revokes every link, API token, WebDAV password, podcast feed and Kodi token of the
+// sender, or of the given user for admins, and terminates the streams and player connections
+// of these links
+func revokeAll(ctx *ext.Context, u *ext.Update) error {
+	chatId := u.EffectiveChat().GetID()
+	peerChatId := ctx.PeerStorage.GetPeerById(chatId)
+	if peerChatId.Type != int(storage.TypeUser) {
+		return dispatcher.EndGroups
+	}
+	trackUser(u)
+	userID := chatId
+	if args := u.Args(); len(args) > 1 {
+		if !utils.IsAdmin(ctx, ctx.Raw, ctx.PeerStorage, chatId) {
+			ctx.Reply(u, "Only admins can revoke the links of other users, send /revokeall alone to revoke yours.", nil)
+			return dispatcher.EndGroups
+		}
+		id, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			ctx.Reply(u, "Invalid user ID.", nil)
+			return dispatcher.EndGroups
+		}
+		userID = id
+	}
+	if database.GetLinkRepository() == nil {
+		ctx.Reply(u, "❌ Link database is not available at the moment.", nil)
+		return dispatcher.EndGroups
+	}
+	question := "🧹 Revoke all your links, API tokens and passwords? Every link you generated stops working."
+	if userID != chatId {
+		question = fmt.Sprintf("🧹 Revoke all links, API tokens and passwords of %s? Every link they generated stops working.", describeUserID(userID))
+	}
+	confirm(ctx, u, question, func(ctx *ext.Context) string {
+		revoked, err := database.GetLinkRepository().RevokeAll(userID)
+		if err != nil {
+			utils.Logger.Error("Failed to revoke links", zap.Error(err), zap.Int64("userID", userID))
+			return fmt.Sprintf("Error - %s", err.Error())
+		}
+		var tokens int64
+		if apiTokenRepository := database.GetAPITokenRepository(); apiTokenRepository != nil {
+			tokens, err = apiTokenRepository.DeleteAll(userID)
+			if err != nil {
+				utils.Logger.Error("Failed to revoke API tokens", zap.Error(err), zap.Int64("userID", userID))
+				return fmt.Sprintf("Error - %s", err.Error())
+			}
+		}
+		if userRepository := database.GetUserRepository(); userRepository != nil {
+			if err := userRepository.RevokeTokens(userID); err != nil {
+				utils.Logger.Error("Failed to revoke signed tokens", zap.Error(err), zap.Int64("userID", userID))
+				return fmt.Sprintf("Error - %s", err.Error())
+			}
+		}
+		terminated := sessions.TerminateAll(userID)
+		utils.Logger.Info("Revoked all links",
+			zap.Int64("userID", userID),
+			zap.Int64("by", chatId),
+			zap.Int64("links", revoked),
+			zap.Int64("apiTokens", tokens),
+			zap.Int("sessions", terminated))
+		owner := "your"
+		if userID != chatId {
+			owner = fmt.Sprintf("user %d's", userID)
+		}
+		return fmt.Sprintf("🧹 Revoked %d of %s links, %d API tokens, the WebDAV password, the podcast feed and the Kodi token, and stopped %d active streams and players. Send the files again to get new links.", revoked, owner, tokens, terminated)
+	})
+	return dispatcher.EndGroups
+}
@@ -0,0 +1,94 @@
+package commands
+
+import (
+	"EverythingSuckz/fsb/internal/downloads"
+	"EverythingSuckz/fsb/internal/utils"
+	"fmt"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/dispatcher/handlers"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/celestix/gotgproto/storage"
+	"github.com/gotd/td/tg"
+	"go.uber.org/zap"
+)
+
+func (m *command) LoadSave(dispatcher dispatcher.Dispatcher) {
+	log := m.log.Named("save")
+	defer log.Sugar().Info("Loaded")
+	dispatcher.AddHandler(handlers.NewCommand("save", save))
+}
+
+// save queues the replied file to be saved in SAVE_DIR and edits its reply with the progress
+func save(ctx *ext.Context, u *ext.Update) error {
+	chatId := u.EffectiveChat().GetID()
+	peerChatId := ctx.PeerStorage.GetPeerById(chatId)
+	if peerChatId.Type != int(storage.TypeUser) {
+		return dispatcher.EndGroups
+	}
+	if !utils.IsAdmin(ctx, ctx.Raw, ctx.PeerStorage, chatId) {
+		ctx.Reply(u, "This command is only available to admins.", nil)
+		return dispatcher.EndGroups
+	}
+	if !downloads.Enabled() {
+		ctx.Reply(u, "Saving files on the server is not enabled, set SAVE_DIR first.", nil)
+		return dispatcher.EndGroups
+	}
+	link, err := repliedLink(u)
+	if err != nil {
+		ctx.Reply(u, fmt.Sprintf("Error - %s", err.Error()), nil)
+		return dispatcher.EndGroups
+	}
+	queued := fmt.Sprintf("⏳ Queued %s", link.FileName)
+	if waiting := downloads.Waiting(); waiting > 0 {
+		queued += fmt.Sprintf(", %d files before it", waiting)
+	}
+	reply, err := ctx.Reply(u, queued, &ext.ReplyOpts{ReplyToMessageId: u.EffectiveMessage.ID})
+	if err != nil {
+		return dispatcher.EndGroups
+	}
+	err = downloads.Enqueue(&downloads.Job{
+		TenantID:  link.TenantID,
+		MessageID: link.MessageID,
+		UserID:    chatId,
+		Report: func(status downloads.Status) {
+			ctx.EditMessage(chatId, &tg.MessagesEditMessageRequest{
+				ID:      reply.ID,
+				Message: saveStatus(link.FileName, status),
+			})
+		},
+	})
+	if err != nil {
+		utils.Logger.Warn("Failed to queue file", zap.Error(err))
+		ctx.EditMessage(chatId, &tg.MessagesEditMessageRequest{ID: reply.ID, Message: fmt.Sprintf("❌ %s", err.Error())})
+	}
+	return dispatcher.EndGroups
+}
+
+// saveStatus describes the status of a file being saved
+func saveStatus(fileName string, status downloads.Status) string {
+	switch status.State {
+	case downloads.Downloading:
+		percent := 0
+		if status.Total > 0 {
+			percent = int(status.Written * 100 / status.Total)
+		}
+		return fmt.Sprintf("⬇️ Saving %s\n%d%%, %s of %s", fileName, percent,
+			utils.FormatFileSizeShort(status.Written), utils.FormatFileSizeShort(status.Total))
+	case downloads.Verifying:
+		return fmt.Sprintf("🔎 Verifying %s", fileName)
+	case downloads.Done:
+		verified := "⚠️ Telegram has no hashes of this file to verify it with"
+		if status.Verified {
+			verified = "✔️ Verified with the hashes of Telegram"
+		}
+		return fmt.Sprintf("✅ Saved %s to %s (%s)\n\nSHA-256: %s\n%s", fileName, status.Path,
+			utils.FormatFileSizeShort(status.Total), status.SHA256, verified)
+	case downloads.Failed:
+		return fmt.Sprintf("❌ Failed to save %s: %s", fileName, status.Err.Error())
+	}
+	if status.Err != nil {
+		return fmt.Sprintf("🔁 Failed to save %s, trying again later: %s", fileName, status.Err.Error())
+	}
+	return fmt.Sprintf("⏳ Queued %s", fileName)
+}
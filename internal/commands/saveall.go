@@ -0,0 +1,189 @@
+package commands
+
+import (
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/downloads"
+	"EverythingSuckz/fsb/internal/utils"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/dispatcher/handlers"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/celestix/gotgproto/storage"
+	"github.com/gotd/td/tg"
+	"go.uber.org/zap"
+)
+
+// saveAllLimit is the number of files /saveall queues at most
+const saveAllLimit = 200
+
+const saveAllUsage = `Usage: reply to a file of an album with /saveall, or /saveall <chat id> <first id>-<last id>
+
+Reply to a file you sent in an album to save all the files of the album. With a chat ID, the messages in the range of a channel or group the bot is a member of are saved, up to 200.`
+
+func (m *command) LoadSaveAll(dispatcher dispatcher.Dispatcher) {
+	log := m.log.Named("saveall")
+	defer log.Sugar().Info("Loaded")
+	dispatcher.AddHandler(handlers.NewCommand("saveall", saveAll))
+}
+
+// saveAll queues the files of an album or a range of messages to be saved in SAVE_DIR and edits one reply with the progress of all of them
+func saveAll(ctx *ext.Context, u *ext.Update) error {
+	chatId := u.EffectiveChat().GetID()
+	peerChatId := ctx.PeerStorage.GetPeerById(chatId)
+	if peerChatId.Type != int(storage.TypeUser) {
+		return dispatcher.EndGroups
+	}
+	if !utils.IsAdmin(ctx, ctx.Raw, ctx.PeerStorage, chatId) {
+		ctx.Reply(u, "This command is only available to admins.", nil)
+		return dispatcher.EndGroups
+	}
+	if !downloads.Enabled() {
+		ctx.Reply(u, "Saving files on the server is not enabled, set SAVE_DIR first.", nil)
+		return dispatcher.EndGroups
+	}
+	var jobs []*downloads.Job
+	var labels []string
+	var err error
+	if args := u.Args(); len(args) == 3 {
+		jobs, labels, err = rangeJobs(chatId, args[1], args[2])
+	} else if len(args) == 1 {
+		jobs, labels, err = albumJobs(u)
+	} else {
+		err = errors.New(saveAllUsage)
+	}
+	if err != nil {
+		ctx.Reply(u, fmt.Sprintf("Error - %s", err.Error()), nil)
+		return dispatcher.EndGroups
+	}
+	reply, err := ctx.Reply(u, fmt.Sprintf("⏳ Queued %d files", len(jobs)), &ext.ReplyOpts{ReplyToMessageId: u.EffectiveMessage.ID})
+	if err != nil {
+		return dispatcher.EndGroups
+	}
+	batch := &saveBatch{labels: labels, statuses: make([]downloads.Status, len(jobs)), edit: func(message string) {
+		ctx.EditMessage(chatId, &tg.MessagesEditMessageRequest{ID: reply.ID, Message: message})
+	}}
+	for i, job := range jobs {
+		i := i
+		job.Report = func(status downloads.Status) { batch.report(i, status) }
+		if err := downloads.Enqueue(job); err != nil {
+			utils.Logger.Warn("Failed to queue file", zap.Error(err))
+			batch.report(i, downloads.Status{State: downloads.Failed, Err: err})
+		}
+	}
+	return dispatcher.EndGroups
+}
+
+// albumJobs returns the jobs of the files of the album the replied file was sent in, with
+// the names of the files
+func albumJobs(u *ext.Update) ([]*downloads.Job, []string, error) {
+	first, err := repliedLink(u)
+	if err != nil {
+		return nil, nil, err
+	}
+	if first.GroupedID == 0 {
+		return nil, nil, errors.New("this file wasn't sent in an album, save it with /save")
+	}
+	links, err := database.GetLinkRepository().ListAlbum(first.UserID, first.GroupedID, saveAllLimit)
+	if err != nil {
+		return nil, nil, err
+	}
+	jobs := make([]*downloads.Job, 0, len(links))
+	labels := make([]string, 0, len(links))
+	for _, link := range links {
+		jobs = append(jobs, &downloads.Job{TenantID: link.TenantID, MessageID: link.MessageID, UserID: first.UserID})
+		labels = append(labels, link.FileName)
+	}
+	return jobs, labels, nil
+}
+
+// rangeJobs returns the jobs of the messages in the range of the channel or supergroup, with
+// the IDs of the messages
+func rangeJobs(userID int64, chat string, messages string) ([]*downloads.Job, []string, error) {
+	channelID, err := strconv.ParseInt(strings.TrimPrefix(chat, "-100"), 10, 64)
+	if err != nil || channelID <= 0 {
+		return nil, nil, errors.New("invalid chat ID, use the ID of a channel or group like -1001234567890")
+	}
+	from, to, ok := strings.Cut(messages, "-")
+	first, firstErr := strconv.Atoi(from)
+	last, lastErr := strconv.Atoi(to)
+	if !ok || firstErr != nil || lastErr != nil || first <= 0 || last < first {
+		return nil, nil, errors.New("invalid message range, use <first id>-<last id>")
+	}
+	if last-first+1 > saveAllLimit {
+		return nil, nil, fmt.Errorf("the range has %d messages, the maximum is %d", last-first+1, saveAllLimit)
+	}
+	jobs := make([]*downloads.Job, 0, last-first+1)
+	labels := make([]string, 0, last-first+1)
+	for id := first; id <= last; id++ {
+		jobs = append(jobs, &downloads.Job{ChannelID: channelID, MessageID: id, UserID: userID})
+		labels = append(labels, fmt.Sprintf("message %d", id))
+	}
+	return jobs, labels, nil
+}
+
+// saveBatch tracks the files of a /saveall and edits its reply with their progress, at most
+// every few seconds until all of them are done
+type saveBatch struct {
+	mu       sync.Mutex
+	labels   []string
+	statuses []downloads.Status
+	edited   time.Time
+	edit     func(message string)
+}
+
+func (b *saveBatch) report(i int, status downloads.Status) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.statuses[i] = status
+	finished := 0
+	for _, status := range b.statuses {
+		if status.State == downloads.Done || status.State == downloads.Failed {
+			finished++
+		}
+	}
+	if finished < len(b.statuses) && time.Since(b.edited) < 5*time.Second {
+		return
+	}
+	b.edited = time.Now()
+	b.edit(b.summary())
+}
+
+// summary counts the files by state, with the progress of the ones being saved and why the others failed
+func (b *saveBatch) summary() string {
+	counts := make(map[downloads.State]int)
+	var written, total int64
+	var failures []string
+	for i, status := range b.statuses {
+		counts[status.State]++
+		switch status.State {
+		case downloads.Downloading:
+			written += status.Written
+			total += status.Total
+		case downloads.Failed:
+			failures = append(failures, fmt.Sprintf("%s: %s", b.labels[i], status.Err.Error()))
+		}
+	}
+	var message string
+	if counts[downloads.Done]+counts[downloads.Failed] == len(b.statuses) {
+		message = fmt.Sprintf("✅ Saved %d of %d files", counts[downloads.Done], len(b.statuses))
+	} else {
+		message = fmt.Sprintf("⬇️ Saving %d files: %d saved, %d saving, %d queued",
+			len(b.statuses), counts[downloads.Done], counts[downloads.Downloading]+counts[downloads.Verifying], counts[downloads.Queued])
+		if total > 0 {
+			message += fmt.Sprintf("\n%s of %s of the current files", utils.FormatFileSizeShort(written), utils.FormatFileSizeShort(total))
+		}
+	}
+	if len(failures) > 0 {
+		message += fmt.Sprintf("\n\n❌ %d failed:\n%s", len(failures), strings.Join(failures[:min(len(failures), 20)], "\n"))
+		if len(failures) > 20 {
+			message += fmt.Sprintf("\n... and %d more", len(failures)-20)
+		}
+	}
+	return message
+}
@@ -0,0 +1,273 @@
+package commands
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/crash"
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/types"
+	"EverythingSuckz/fsb/internal/utils"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/dispatcher/handlers"
+	"github.com/celestix/gotgproto/ext"
+	"go.uber.org/zap"
+)
+
+const scheduleUsage = "Usage: /schedule <time> [every:<period>] [to:<audience>] <message>\n\n" +
+	"Times look like 2h (from now), 18:00 (next occurrence) or 2024-12-24T18:00.\n" +
+	"every:1d repeats the message every day, periods look like 12h, 1d or 1w.\n" +
+	"Audiences are all (default), authorized, unauthorized and admins.\n\n" +
+	"/schedule --dry-run <time> ... shows the recipients and how long sending takes, without scheduling.\n" +
+	"/schedule lists the scheduled messages, /unschedule <id> cancels one."
+
+// scheduleLayouts are the accepted formats of absolute times, in the server's time zone
+var scheduleLayouts = []string{"2006-01-02T15:04", "2006-01-02"}
+
+func (m *command) LoadSchedule(dispatcher dispatcher.Dispatcher) {
+	log := m.log.Named("schedule")
+	defer log.Sugar().Info("Loaded")
+	dispatcher.AddHandler(handlers.NewCommand("schedule", schedule))
+	dispatcher.AddHandler(handlers.NewCommand("unschedule", unschedule))
+}
+
+// schedule stores an announcement that is sent to the users once or repeatedly,
+// or lists the pending ones without arguments
+func schedule(ctx *ext.Context, u *ext.Update) error {
+	adminID, ok := settingsAdmin(ctx, u)
+	if !ok {
+		return dispatcher.EndGroups
+	}
+	scheduleRepository := database.GetScheduleRepository()
+	if scheduleRepository == nil {
+		ctx.Reply(u, "❌ Schedule database is not available at the moment.", nil)
+		return dispatcher.EndGroups
+	}
+	text, dryRun := cutDryRun(u.EffectiveMessage.Text)
+	if strings.TrimSpace(argsAfter(text, 1)) == "" {
+		return listSchedules(ctx, u, scheduleRepository)
+	}
+	entry, err := parseSchedule(text, time.Now())
+	if err != nil {
+		ctx.Reply(u, fmt.Sprintf("Error - %s\n\n%s", err.Error(), scheduleUsage), nil)
+		return dispatcher.EndGroups
+	}
+	entry.CreatedBy = adminID
+	if dryRun {
+		go func() {
+			defer crash.Recover("schedule")
+			summary, lines, err := scheduleDryRun(entry)
+			if err == nil {
+				err = replyDryRun(ctx, adminID, u.EffectiveMessage.ID, summary, lines, "schedule-dry-run.txt")
+			}
+			if err != nil {
+				ctx.Reply(u, fmt.Sprintf("Error - %s", err.Error()), nil)
+			}
+		}()
+		return dispatcher.EndGroups
+	}
+	if entry.Audience == types.AudienceAdmins {
+		ctx.Reply(u, storeSchedule(entry), nil)
+		return dispatcher.EndGroups
+	}
+	question := fmt.Sprintf("📣 Send this message to %s users on %s?", entry.Audience, entry.RunAt.Format("2006-01-02 15:04"))
+	if userRepository := database.GetUserRepository(); userRepository != nil {
+		if recipients, err := userRepository.ListRecipients(entry.Audience); err == nil {
+			question = fmt.Sprintf("📣 Send this message to %d %s users on %s?", len(recipients), entry.Audience, entry.RunAt.Format("2006-01-02 15:04"))
+		}
+	}
+	confirm(ctx, u, question+"\n\n"+entry.Message, func(ctx *ext.Context) string {
+		return storeSchedule(entry)
+	})
+	return dispatcher.EndGroups
+}
+
+// storeSchedule stores a scheduled message and returns the reply to the admin
+func storeSchedule(entry *types.Schedule) string {
+	scheduleRepository := database.GetScheduleRepository()
+	if scheduleRepository == nil {
+		return "❌ Schedule database is not available at the moment."
+	}
+	if err := scheduleRepository.Create(entry); err != nil {
+		utils.Logger.Error("Failed to store scheduled message", zap.Error(err))
+		return fmt.Sprintf("Error - %s", err.Error())
+	}
+	message := fmt.Sprintf("🗓 Scheduled message #%d for %s to %s users", entry.ID, entry.RunAt.Format("2006-01-02 15:04"), entry.Audience)
+	if entry.Interval > 0 {
+		message += fmt.Sprintf(", repeated every %s", formatWait(entry.Interval))
+	}
+	return message + ".\n\nCancel it with /unschedule " + strconv.FormatUint(uint64(entry.ID), 10)
+}
+
+// scheduleDryRun lists the users who would receive the scheduled message if it was sent now,
+// and how long sending it would take
+func scheduleDryRun(entry *types.Schedule) (string, []string, error) {
+	userRepository := database.GetUserRepository()
+	if userRepository == nil {
+		return "", nil, fmt.Errorf("user database is not available at the moment")
+	}
+	recipients := config.Runtime.Admins()
+	if entry.Audience != types.AudienceAdmins {
+		var err error
+		if recipients, err = userRepository.ListRecipients(entry.Audience); err != nil {
+			return "", nil, err
+		}
+	}
+	summary := fmt.Sprintf("Would send the message to %d %s users on %s, taking about %s.",
+		len(recipients), entry.Audience, entry.RunAt.Format("2006-01-02 15:04"), formatWait(estimateSendDuration(len(recipients))))
+	if entry.Interval > 0 {
+		summary += fmt.Sprintf(" It would repeat every %s, to the users of the audience at the time.", formatWait(entry.Interval))
+	}
+	names := make(map[int64]string, dryRunListed)
+	if len(recipients) > 0 {
+		listed := recipients[:min(len(recipients), dryRunListed)]
+		users, _, err := userRepository.ListUsers(database.UserFilter{IDs: listed}, 0, len(listed))
+		if err != nil {
+			return "", nil, err
+		}
+		for _, user := range users {
+			names[user.ID] = formatUser(user)
+		}
+	}
+	lines := make([]string, len(recipients))
+	for i, userID := range recipients {
+		if name, ok := names[userID]; ok {
+			lines[i] = name
+		} else {
+			lines[i] = fmt.Sprintf("[%d]", userID)
+		}
+	}
+	return summary, lines, nil
+}
+
+func unschedule(ctx *ext.Context, u *ext.Update) error {
+	if _, ok := settingsAdmin(ctx, u); !ok {
+		return dispatcher.EndGroups
+	}
+	args := u.Args()
+	if len(args) < 2 {
+		ctx.Reply(u, "Usage: /unschedule <id>, /schedule lists the scheduled messages.", nil)
+		return dispatcher.EndGroups
+	}
+	id, err := strconv.ParseUint(strings.TrimPrefix(args[1], "#"), 10, 64)
+	if err != nil {
+		ctx.Reply(u, "Invalid ID.", nil)
+		return dispatcher.EndGroups
+	}
+	scheduleRepository := database.GetScheduleRepository()
+	if scheduleRepository == nil {
+		ctx.Reply(u, "❌ Schedule database is not available at the moment.", nil)
+		return dispatcher.EndGroups
+	}
+	deleted, err := scheduleRepository.Delete(uint(id))
+	if err != nil {
+		ctx.Reply(u, fmt.Sprintf("Error - %s", err.Error()), nil)
+		return dispatcher.EndGroups
+	}
+	if !deleted {
+		ctx.Reply(u, "No pending scheduled message with this ID.", nil)
+		return dispatcher.EndGroups
+	}
+	ctx.Reply(u, fmt.Sprintf("✅ Cancelled scheduled message #%d.", id), nil)
+	return dispatcher.EndGroups
+}
+
+func listSchedules(ctx *ext.Context, u *ext.Update, scheduleRepository *database.ScheduleRepository) error {
+	schedules, err := scheduleRepository.ListPending(20)
+	if err != nil {
+		ctx.Reply(u, fmt.Sprintf("Error - %s", err.Error()), nil)
+		return dispatcher.EndGroups
+	}
+	if len(schedules) == 0 {
+		ctx.Reply(u, "No scheduled messages.\n\n"+scheduleUsage, nil)
+		return dispatcher.EndGroups
+	}
+	var sb strings.Builder
+	sb.WriteString("🗓 Scheduled messages\n\n")
+	for _, entry := range schedules {
+		sb.WriteString(fmt.Sprintf("#%d %s, %s users", entry.ID, entry.RunAt.Format("2006-01-02 15:04"), entry.Audience))
+		if entry.Interval > 0 {
+			sb.WriteString(fmt.Sprintf(", every %s", formatWait(entry.Interval)))
+		}
+		preview := []rune(strings.ReplaceAll(entry.Message, "\n", " "))
+		if len(preview) > 60 {
+			preview = append(preview[:60], '…')
+		}
+		sb.WriteString("\n" + string(preview) + "\n\n")
+	}
+	sb.WriteString("Cancel one with /unschedule <id>")
+	ctx.Reply(u, sb.String(), nil)
+	return dispatcher.EndGroups
+}
+
+// parseSchedule parses the text of a /schedule command. The message is the rest of the
+// text after the time and the options, so it keeps its line breaks.
+func parseSchedule(text string, now time.Time) (*types.Schedule, error) {
+	words := func() (string, string) {
+		text = strings.TrimLeft(text, " \n")
+		if i := strings.IndexAny(text, " \n"); i >= 0 {
+			return text[:i], text[i:]
+		}
+		return text, ""
+	}
+	_, text = words() // the command
+	var when string
+	when, text = words()
+	runAt, err := parseScheduleTime(when, now)
+	if err != nil {
+		return nil, err
+	}
+	entry := &types.Schedule{RunAt: runAt, Audience: types.AudienceAll}
+	for {
+		word, rest := words()
+		if period, ok := strings.CutPrefix(word, "every:"); ok {
+			if entry.Interval, err = utils.ParsePeriod(period); err != nil {
+				return nil, err
+			}
+			if entry.Interval < time.Hour {
+				return nil, fmt.Errorf("messages can't repeat more often than every hour")
+			}
+		} else if audience, ok := strings.CutPrefix(word, "to:"); ok {
+			switch audience {
+			case types.AudienceAll, types.AudienceAuthorized, types.AudienceUnauthorized, types.AudienceAdmins:
+				entry.Audience = audience
+			default:
+				return nil, fmt.Errorf("unknown audience: %s", audience)
+			}
+		} else {
+			break
+		}
+		text = rest
+	}
+	entry.Message = strings.TrimSpace(text)
+	if entry.Message == "" {
+		return nil, fmt.Errorf("the message is empty")
+	}
+	return entry, nil
+}
+
+// parseScheduleTime parses a time relative to now, a time of day or a date with an optional time
+func parseScheduleTime(value string, now time.Time) (time.Time, error) {
+	if period, err := utils.ParsePeriod(value); err == nil {
+		return now.Add(period), nil
+	}
+	if clock, err := time.ParseInLocation("15:04", value, now.Location()); err == nil {
+		at := time.Date(now.Year(), now.Month(), now.Day(), clock.Hour(), clock.Minute(), 0, 0, now.Location())
+		if !at.After(now) {
+			at = at.AddDate(0, 0, 1)
+		}
+		return at, nil
+	}
+	for _, layout := range scheduleLayouts {
+		if at, err := time.ParseInLocation(layout, value, now.Location()); err == nil {
+			if !at.After(now) {
+				return at, fmt.Errorf("%s is in the past", value)
+			}
+			return at, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time: %s", value)
+}
@@ -0,0 +1,138 @@
+package commands
+
+import (
+	"EverythingSuckz/fsb/internal/sessions"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/dispatcher/handlers"
+	"github.com/celestix/gotgproto/dispatcher/handlers/filters"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/celestix/gotgproto/functions"
+	"github.com/celestix/gotgproto/storage"
+	"github.com/gotd/td/tg"
+)
+
+// browserNames and systemNames are recognized in user agents, in the order they are checked
+var (
+	browserNames = [][2]string{
+		{"Edg/", "Edge"}, {"OPR/", "Opera"}, {"Firefox/", "Firefox"}, {"Chrome/", "Chrome"},
+		{"Safari/", "Safari"}, {"VLC", "VLC"}, {"mpv", "mpv"}, {"Kodi", "Kodi"},
+	}
+	systemNames = [][2]string{
+		{"Android", "Android"}, {"iPhone", "iOS"}, {"iPad", "iPadOS"}, {"Windows", "Windows"},
+		{"Mac OS X", "macOS"}, {"CrOS", "ChromeOS"}, {"Linux", "Linux"},
+	}
+)
+
+func (m *command) LoadSessions(dispatcher dispatcher.Dispatcher) {
+	log := m.log.Named("sessions")
+	defer log.Sugar().Info("Loaded")
+	dispatcher.AddHandler(handlers.NewCommand("sessions", listSessions))
+	dispatcher.AddHandler(handlers.NewCallbackQuery(filters.CallbackQuery.Prefix("sess:"), sessionsCallback))
+}
+
+// listSessions shows the web players and streams of the sender's links with buttons to terminate them
+func listSessions(ctx *ext.Context, u *ext.Update) error {
+	chatId := u.EffectiveChat().GetID()
+	peerChatId := ctx.PeerStorage.GetPeerById(chatId)
+	if peerChatId.Type != int(storage.TypeUser) {
+		return dispatcher.EndGroups
+	}
+	trackUser(u)
+	if !isAllowed(ctx, chatId) {
+		ctx.Reply(u, "You are not allowed to use this bot.", nil)
+		return dispatcher.EndGroups
+	}
+	message, markup := sessionsMessage(chatId)
+	ctx.Reply(u, message, &ext.ReplyOpts{Markup: markup})
+	return dispatcher.EndGroups
+}
+
+// sessionsCallback terminates one session with sess:<id>, or all of them with sess:all
+func sessionsCallback(ctx *ext.Context, u *ext.Update) error {
+	query := u.CallbackQuery
+	target := strings.TrimPrefix(string(query.Data), "sess:")
+	answer := "Session terminated"
+	if target == "all" {
+		answer = fmt.Sprintf("Terminated %d sessions", sessions.TerminateAll(query.UserID))
+	} else if id, err := strconv.ParseUint(target, 10, 64); err != nil || !sessions.Terminate(query.UserID, id) {
+		answer = "This session has already ended"
+	}
+	ctx.AnswerCallback(&tg.MessagesSetBotCallbackAnswerRequest{
+		QueryID: query.QueryID,
+		Message: answer,
+	})
+	message, markup := sessionsMessage(query.UserID)
+	ctx.EditMessage(functions.GetChatIdFromPeer(query.Peer), &tg.MessagesEditMessageRequest{
+		ID:          query.MsgID,
+		Message:     message,
+		ReplyMarkup: markup,
+	})
+	return dispatcher.EndGroups
+}
+
+func sessionsMessage(userID int64) (string, tg.ReplyMarkupClass) {
+	list := sessions.List(userID)
+	markup := &tg.ReplyInlineMarkup{Rows: []tg.KeyboardButtonRow{}}
+	if len(list) == 0 {
+		return "No one is watching or downloading your links right now.", markup
+	}
+	var sb strings.Builder
+	sb.WriteString("📺 Active sessions of your links\n\n")
+	streams := 0
+	for _, session := range list {
+		if session.Kind == sessions.KindStream {
+			streams++
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("#%d %s\nIP %s, connected %s ago\n\n",
+			session.ID, describeUserAgent(session.UserAgent), session.IP, formatWait(time.Since(session.Since))))
+		markup.Rows = append(markup.Rows, tg.KeyboardButtonRow{
+			Buttons: []tg.KeyboardButtonClass{
+				&tg.KeyboardButtonCallback{
+					Text: fmt.Sprintf("⛔ Terminate #%d", session.ID),
+					Data: []byte(fmt.Sprintf("sess:%d", session.ID)),
+				},
+			},
+		})
+	}
+	if streams > 0 {
+		sb.WriteString(fmt.Sprintf("%d downloads or streams running\n", streams))
+	}
+	markup.Rows = append(markup.Rows, tg.KeyboardButtonRow{
+		Buttons: []tg.KeyboardButtonClass{
+			&tg.KeyboardButtonCallback{Text: "⛔ Terminate all", Data: []byte("sess:all")},
+		},
+	})
+	return sb.String(), markup
+}
+
+// describeUserAgent returns the browser and system of a user agent, like "Chrome on Android"
+func describeUserAgent(userAgent string) string {
+	browser, system := "", ""
+	for _, name := range browserNames {
+		if strings.Contains(userAgent, name[0]) {
+			browser = name[1]
+			break
+		}
+	}
+	for _, name := range systemNames {
+		if strings.Contains(userAgent, name[0]) {
+			system = name[1]
+			break
+		}
+	}
+	switch {
+	case browser != "" && system != "":
+		return browser + " on " + system
+	case browser != "" || system != "":
+		return browser + system
+	case userAgent != "":
+		return userAgent
+	}
+	return "Unknown device"
+}
@@ -0,0 +1,103 @@
+package commands
+
+import (
+	"EverythingSuckz/fsb/internal/settings"
+	"EverythingSuckz/fsb/internal/utils"
+	"fmt"
+	"strings"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/dispatcher/handlers"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/celestix/gotgproto/storage"
+	"go.uber.org/zap"
+)
+
+const setUsage = `Usage: /set <setting> <value>
+
+Send /set <setting> default to go back to the environment variable, and /get to list the settings.`
+
+func (m *command) LoadSet(dispatcher dispatcher.Dispatcher) {
+	log := m.log.Named("set")
+	defer log.Sugar().Info("Loaded")
+	dispatcher.AddHandler(handlers.NewCommand("set", setCommand))
+	dispatcher.AddHandler(handlers.NewCommand("get", getCommand))
+}
+
+// setCommand changes a setting at runtime, over the value of its environment variable
+func setCommand(ctx *ext.Context, u *ext.Update) error {
+	chatId, ok := settingsAdmin(ctx, u)
+	if !ok {
+		return dispatcher.EndGroups
+	}
+	args := u.Args()
+	if len(args) != 3 {
+		ctx.Reply(u, setUsage, nil)
+		return dispatcher.EndGroups
+	}
+	definition := settings.Lookup(strings.ToLower(args[1]))
+	if definition == nil || !definition.Editable {
+		ctx.Reply(u, fmt.Sprintf("❌ Unknown setting %s, send /get to list the settings.", args[1]), nil)
+		return dispatcher.EndGroups
+	}
+	var err error
+	if strings.EqualFold(args[2], "default") {
+		err = settings.Reset(definition.Key)
+	} else {
+		err = settings.Set(definition.Key, args[2], chatId)
+	}
+	if err != nil {
+		utils.Logger.Error("Failed to change setting", zap.String("key", definition.Key), zap.Error(err))
+		ctx.Reply(u, fmt.Sprintf("❌ %s", err.Error()), nil)
+		return dispatcher.EndGroups
+	}
+	ctx.Reply(u, fmt.Sprintf("✅ %s is %s now.", definition.Key, describeSetting(definition)), nil)
+	return dispatcher.EndGroups
+}
+
+// getCommand lists the settings, or shows one, with the source of their value
+func getCommand(ctx *ext.Context, u *ext.Update) error {
+	if _, ok := settingsAdmin(ctx, u); !ok {
+		return dispatcher.EndGroups
+	}
+	args := u.Args()
+	if len(args) > 1 {
+		definition := settings.Lookup(strings.ToLower(args[1]))
+		if definition == nil || !definition.Editable {
+			ctx.Reply(u, fmt.Sprintf("❌ Unknown setting %s, send /get to list the settings.", args[1]), nil)
+			return dispatcher.EndGroups
+		}
+		ctx.Reply(u, fmt.Sprintf("%s (%s)\n%s\n\nValue: %s", definition.Key, definition.Kind, definition.Description, describeSetting(definition)), nil)
+		return dispatcher.EndGroups
+	}
+	var sb strings.Builder
+	sb.WriteString("⚙️ Settings\n")
+	for _, definition := range settings.Definitions() {
+		sb.WriteString(fmt.Sprintf("\n%s: %s", definition.Key, describeSetting(definition)))
+	}
+	sb.WriteString("\n\n" + setUsage)
+	ctx.Reply(u, sb.String(), nil)
+	return dispatcher.EndGroups
+}
+
+// describeSetting returns the current value of the setting and where it comes from
+func describeSetting(definition *settings.Definition) string {
+	if _, ok := settings.Get(definition.Key); ok {
+		return fmt.Sprintf("%s (set at runtime, %s is %s)", definition.Current(), definition.Env, settings.Format(definition.Kind, settings.Default(definition.Key)))
+	}
+	return fmt.Sprintf("%s (from %s)", definition.Current(), definition.Env)
+}
+
+// settingsAdmin checks that the command was sent by an admin in a private chat
+func settingsAdmin(ctx *ext.Context, u *ext.Update) (int64, bool) {
+	chatId := u.EffectiveChat().GetID()
+	peerChatId := ctx.PeerStorage.GetPeerById(chatId)
+	if peerChatId.Type != int(storage.TypeUser) {
+		return 0, false
+	}
+	if !utils.IsAdmin(ctx, ctx.Raw, ctx.PeerStorage, chatId) {
+		ctx.Reply(u, "This command is only available to admins.", nil)
+		return 0, false
+	}
+	return chatId, true
+}
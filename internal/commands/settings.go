@@ -0,0 +1,162 @@
+package commands
+
+import (
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/onboarding"
+	"EverythingSuckz/fsb/internal/utils"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/dispatcher/handlers"
+	"github.com/celestix/gotgproto/dispatcher/handlers/filters"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/celestix/gotgproto/storage"
+	"github.com/gotd/td/tg"
+	"go.uber.org/zap"
+)
+
+func (m *command) LoadSettings(dispatcher dispatcher.Dispatcher) {
+	log := m.log.Named("settings")
+	defer log.Sugar().Info("Loaded")
+	dispatcher.AddHandler(handlers.NewCommand("settings", settingsCommand))
+	dispatcher.AddHandler(handlers.NewCallbackQuery(filters.CallbackQuery.Prefix(onboarding.SettingsPrefix), settingsCallback))
+}
+
+func settingsCommand(ctx *ext.Context, u *ext.Update) error {
+	chatId := u.EffectiveChat().GetID()
+	peerChatId := ctx.PeerStorage.GetPeerById(chatId)
+	if peerChatId.Type != int(storage.TypeUser) {
+		return dispatcher.EndGroups
+	}
+	trackUser(u)
+	if !isAllowed(ctx, chatId) {
+		ctx.Reply(u, "You are not allowed to use this bot.", nil)
+		return dispatcher.EndGroups
+	}
+	liveViews, reuseLinks := true, true
+	if userRepository := database.GetUserRepository().WithContext(ctx); userRepository != nil {
+		if user, err := userRepository.Get(chatId); err == nil && user != nil {
+			liveViews, reuseLinks = user.LiveViews, user.ReuseLinks
+		}
+	}
+	text := fmt.Sprintf("⚙️ Settings\n\n%s\n\nLive views are %s.\n\n%s\n\nReusing links is %s.",
+		onboarding.SettingsText, onOff(liveViews), reuseLinksText, onOff(reuseLinks))
+	ctx.Reply(u, text, &ext.ReplyOpts{Markup: settingsMarkup()})
+	return dispatcher.EndGroups
+}
+
+// reuseLinksText describes the duplicates setting, which isn't part of the onboarding
+const reuseLinksText = "Should I reply to files you send again with the link you already have, instead of a new one?"
+
+// settingsMarkup returns the buttons of the onboarding settings and the ones of /settings only
+func settingsMarkup() tg.ReplyMarkupClass {
+	markup := onboarding.SettingsMarkup().(*tg.ReplyInlineMarkup)
+	markup.Rows = append(markup.Rows, tg.KeyboardButtonRow{
+		Buttons: []tg.KeyboardButtonClass{
+			&tg.KeyboardButtonCallback{Text: "♻️ Reuse links", Data: []byte(onboarding.SettingsPrefix + "reuse:on")},
+			&tg.KeyboardButtonCallback{Text: "🆕 Always new links", Data: []byte(onboarding.SettingsPrefix + "reuse:off")},
+		},
+	})
+	return markup
+}
+
+func onOff(enabled bool) string {
+	if enabled {
+		return "on"
+	}
+	return "off"
+}
+
+// settingsCallback saves the choice of a settings button, which also completes the settings onboarding step
+func settingsCallback(ctx *ext.Context, u *ext.Update) error {
+	query := u.CallbackQuery
+	userID := query.UserID
+	userRepository := database.GetUserRepository().WithContext(ctx)
+	if userRepository == nil {
+		return dispatcher.EndGroups
+	}
+	var answer string
+	var err error
+	switch strings.TrimPrefix(string(query.Data), onboarding.SettingsPrefix) {
+	case "live:on":
+		err = userRepository.SetLiveViews(userID, true)
+		answer = "Live views are on"
+	case "live:off":
+		err = userRepository.SetLiveViews(userID, false)
+		answer = "Live views are off"
+	case "reuse:on":
+		err = userRepository.SetReuseLinks(userID, true)
+		answer = "Files sent again get their existing link"
+	case "reuse:off":
+		err = userRepository.SetReuseLinks(userID, false)
+		answer = "Files sent again get a new link"
+	default:
+		return dispatcher.EndGroups
+	}
+	if err != nil {
+		utils.Logger.Error("Failed to save settings", zap.Error(err), zap.Int64("userID", userID))
+		answer = fmt.Sprintf("Error - %s", err.Error())
+	}
+	ctx.AnswerCallback(&tg.MessagesSetBotCallbackAnswerRequest{
+		QueryID: query.QueryID,
+		Message: answer,
+	})
+	if err == nil {
+		continueOnboarding(ctx, userID, onboarding.StepSettings)
+	}
+	return dispatcher.EndGroups
+}
+
+// beginOnboarding starts the onboarding of a user who just got access to the bot
+func beginOnboarding(ctx *ext.Context, userID int64) {
+	prompt, err := onboarding.Begin(userID)
+	if err != nil {
+		utils.Logger.Error("Failed to start onboarding", zap.Error(err), zap.Int64("userID", userID))
+	}
+	sendPrompt(ctx, userID, prompt)
+}
+
+// resumeOnboarding shows the current onboarding step, or starts the onboarding of users
+// who never generated a link
+func resumeOnboarding(ctx *ext.Context, userID int64) {
+	prompt, err := onboarding.Current(userID)
+	if err != nil {
+		utils.Logger.Error("Failed to get onboarding step", zap.Error(err), zap.Int64("userID", userID))
+		return
+	}
+	if prompt != nil {
+		sendPrompt(ctx, userID, prompt)
+		return
+	}
+	if linkRepository := database.GetLinkRepository(); linkRepository != nil {
+		if count, err := linkRepository.CountSince(userID, time.Time{}); err != nil || count > 0 {
+			return
+		}
+	}
+	beginOnboarding(ctx, userID)
+}
+
+// continueOnboarding moves the user past the step and tells them what to do next
+func continueOnboarding(ctx *ext.Context, userID int64, step onboarding.Step) {
+	prompt, err := onboarding.Complete(userID, step)
+	if err != nil {
+		utils.Logger.Error("Failed to continue onboarding", zap.Error(err), zap.Int64("userID", userID))
+	}
+	sendPrompt(ctx, userID, prompt)
+}
+
+func sendPrompt(ctx *ext.Context, userID int64, prompt *onboarding.Prompt) {
+	if prompt == nil {
+		return
+	}
+	_, err := ctx.SendMessage(userID, &tg.MessagesSendMessageRequest{
+		Message:     prompt.Text,
+		ReplyMarkup: prompt.Markup,
+	})
+	if err != nil {
+		// the user might not have started the bot yet, /start shows the prompt again
+		utils.Logger.Debug("Failed to send onboarding prompt", zap.Error(err), zap.Int64("userID", userID))
+	}
+}
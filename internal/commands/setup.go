@@ -0,0 +1,295 @@
+package commands
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/crash"
+	"EverythingSuckz/fsb/internal/settings"
+	"EverythingSuckz/fsb/internal/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/dispatcher/handlers"
+	"github.com/celestix/gotgproto/dispatcher/handlers/filters"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/celestix/gotgproto/functions"
+	"github.com/celestix/gotgproto/storage"
+	"github.com/gotd/td/tg"
+	"go.uber.org/zap"
+)
+
+// setupGroup runs before the file handler, so that the forwarded message of the log channel step isn't turned into a link
+const setupGroup = -1
+
+// setupStep is the step of the /setup wizard a user is at
+type setupStep int
+
+const (
+	setupHost setupStep = iota
+	setupLogChannel
+	setupPolicies
+)
+
+// wizards holds the step of every user running /setup
+var wizards = struct {
+	sync.Mutex
+	byUser map[int64]setupStep
+}{byUser: make(map[int64]setupStep)}
+
+func (m *command) LoadSetup(dispatcher dispatcher.Dispatcher) {
+	log := m.log.Named("setup")
+	defer log.Sugar().Info("Loaded")
+	dispatcher.AddHandler(handlers.NewCommand("setup", setup))
+	dispatcher.AddHandlerToGroup(handlers.NewMessage(filters.Message.All, setupInput), setupGroup)
+	dispatcher.AddHandler(handlers.NewCallbackQuery(filters.CallbackQuery.Prefix("setup:"), setupCallback))
+}
+
+// setup starts the wizard that verifies the public URL, binds the log channel and chooses the
+// default policies. On a fresh deployment without admins, the first user to run it becomes the owner.
+func setup(ctx *ext.Context, u *ext.Update) error {
+	chatId := u.EffectiveChat().GetID()
+	peerChatId := ctx.PeerStorage.GetPeerById(chatId)
+	if peerChatId.Type != int(storage.TypeUser) {
+		return dispatcher.EndGroups
+	}
+	trackUser(u)
+	if !utils.IsAdmin(ctx, ctx.Raw, ctx.PeerStorage, chatId) {
+		if !isFreshDeployment() {
+			ctx.Reply(u, "This command is only available to admins.", nil)
+			return dispatcher.EndGroups
+		}
+		if err := settings.Set(settings.Owner, strconv.FormatInt(chatId, 10), chatId); err != nil {
+			utils.Logger.Error("Failed to store the owner", zap.Error(err))
+			ctx.Reply(u, fmt.Sprintf("Error - %s", err.Error()), nil)
+			return dispatcher.EndGroups
+		}
+		ctx.Reply(u, "👑 You are the owner of this bot now.", nil)
+	}
+	setWizardStep(chatId, setupHost)
+	ctx.Reply(u, fmt.Sprintf("🛠 Setup 1/3: public URL\n\nSend the URL where the bot is reachable, or /skip to check the current one:\n%s\n\nSend /cancel to stop the setup.", config.Runtime.Host()), nil)
+	return dispatcher.EndGroups
+}
+
+// isFreshDeployment reports whether nobody administers the bot yet
+func isFreshDeployment() bool {
+	if _, ok := settings.Get(settings.Owner); ok {
+		return false
+	}
+	if _, ok := settings.Get(settings.SetupCompleted); ok {
+		return false
+	}
+	return len(config.Runtime.Admins()) == 0 && config.ValueOf.AdminChatID == 0
+}
+
+// setupInput handles the answers of users running /setup, other messages pass through
+func setupInput(ctx *ext.Context, u *ext.Update) error {
+	user := u.EffectiveUser()
+	if user == nil || u.EffectiveChat().GetID() != user.ID {
+		return nil
+	}
+	step, ok := wizardStep(user.ID)
+	if !ok {
+		return nil
+	}
+	text := strings.TrimSpace(u.EffectiveMessage.Text)
+	switch {
+	case text == "/cancel":
+		endWizard(user.ID)
+		ctx.Reply(u, "Setup cancelled, the steps you completed are saved.", nil)
+		return dispatcher.EndGroups
+	case strings.HasPrefix(text, "/") && text != "/skip":
+		return nil
+	}
+	switch step {
+	case setupHost:
+		host := strings.TrimSuffix(text, "/")
+		if text == "/skip" {
+			host = config.Runtime.Host()
+		}
+		go checkHost(ctx, u, user.ID, host, text == "/skip")
+	case setupLogChannel:
+		bindLogChannel(ctx, u, user.ID, text == "/skip")
+	case setupPolicies:
+		ctx.Reply(u, "Please choose with the buttons above, or send /cancel.", nil)
+	}
+	return dispatcher.EndGroups
+}
+
+// checkHost fetches /healthz through the URL and saves it if it reaches this instance
+func checkHost(ctx *ext.Context, u *ext.Update, userID int64, host string, skipped bool) {
+	defer crash.Recover("setup")
+	ctx.Reply(u, "⏳ Checking "+host+config.ValueOf.BasePath+"/healthz ...", nil)
+	err := fetchHealth(host)
+	if err != nil && !skipped {
+		ctx.Reply(u, fmt.Sprintf("❌ %s\n\nSend another URL or /skip.", err.Error()), nil)
+		return
+	}
+	message := "✅ The bot is reachable at " + host
+	if err != nil {
+		message = fmt.Sprintf("⚠️ %s\n\nKeeping %s, fix HOST later.", err.Error(), host)
+	} else if host != config.Runtime.Host() {
+		if err := settings.Set(settings.Host, host, userID); err != nil {
+			ctx.Reply(u, fmt.Sprintf("❌ %s\n\nSend another URL or /skip.", err.Error()), nil)
+			return
+		}
+	}
+	setWizardStep(userID, setupLogChannel)
+	ctx.Reply(u, fmt.Sprintf("%s\n\n🛠 Setup 2/3: log channel\n\nAdd me to the channel that stores the files as an admin, then forward any message of the channel here. Send /skip to keep the current channel %d.", message, config.Runtime.LogChannelID()), nil)
+}
+
+// fetchHealth checks that the /healthz endpoint under the host answers with the ID of this instance
+func fetchHealth(host string) error {
+	if !strings.HasPrefix(host, "http://") && !strings.HasPrefix(host, "https://") {
+		return errors.New("the URL must start with http:// or https://")
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(host + config.ValueOf.BasePath + "/healthz")
+	if err != nil {
+		return fmt.Errorf("couldn't reach the bot: %w", err)
+	}
+	defer resp.Body.Close()
+	var health struct {
+		Instance string `json:"instance"`
+	}
+	if resp.StatusCode != http.StatusOK || json.NewDecoder(resp.Body).Decode(&health) != nil {
+		return fmt.Errorf("the URL answered with %s instead of the health check", resp.Status)
+	}
+	if health.Instance != utils.InstanceID {
+		return errors.New("the URL reaches another instance of the bot")
+	}
+	return nil
+}
+
+// bindLogChannel saves the channel the message was forwarded from as the log channel
+func bindLogChannel(ctx *ext.Context, u *ext.Update, userID int64, skipped bool) {
+	if !skipped {
+		header, ok := u.EffectiveMessage.GetFwdFrom()
+		channel, isChannel := header.FromID.(*tg.PeerChannel)
+		if !ok || !isChannel {
+			ctx.Reply(u, "Please forward a message from the channel, or send /skip.", nil)
+			return
+		}
+		inputChannel, err := utils.GetChannelPeer(ctx, ctx.Raw, ctx.PeerStorage, channel.ChannelID)
+		if err == nil {
+			_, err = ctx.Raw.MessagesSendMessage(ctx, &tg.MessagesSendMessageRequest{
+				Peer:     &tg.InputPeerChannel{ChannelID: inputChannel.ChannelID, AccessHash: inputChannel.AccessHash},
+				Message:  "✅ This channel stores the files of the bot now.",
+				RandomID: rand.Int63(),
+			})
+		}
+		if err != nil {
+			ctx.Reply(u, fmt.Sprintf("❌ I can't post in this channel, make sure I'm an admin there and forward a message again.\n\nError - %s", err.Error()), nil)
+			return
+		}
+		if err := settings.Set(settings.LogChannel, strconv.FormatInt(channel.ChannelID, 10), userID); err != nil {
+			ctx.Reply(u, fmt.Sprintf("Error - %s", err.Error()), nil)
+			return
+		}
+	}
+	setWizardStep(userID, setupPolicies)
+	ctx.Reply(u, "🛠 Setup 3/3: policies\n\nWho can use the bot?", &ext.ReplyOpts{Markup: &tg.ReplyInlineMarkup{
+		Rows: []tg.KeyboardButtonRow{{
+			Buttons: []tg.KeyboardButtonClass{
+				&tg.KeyboardButtonCallback{Text: "🌍 Everyone", Data: []byte("setup:private:false")},
+				&tg.KeyboardButtonCallback{Text: "🔒 Only allowed users", Data: []byte("setup:private:true")},
+			},
+		}},
+	}})
+}
+
+// setupCallback saves the policies chosen with the buttons of the last step
+func setupCallback(ctx *ext.Context, u *ext.Update) error {
+	query := u.CallbackQuery
+	if step, ok := wizardStep(query.UserID); !ok || step != setupPolicies {
+		ctx.AnswerCallback(&tg.MessagesSetBotCallbackAnswerRequest{
+			QueryID: query.QueryID,
+			Message: "This setup has ended, send /setup to start again.",
+			Alert:   true,
+		})
+		return dispatcher.EndGroups
+	}
+	fields := strings.Split(string(query.Data), ":")
+	if len(fields) != 3 {
+		return dispatcher.EndGroups
+	}
+	chatId := functions.GetChatIdFromPeer(query.Peer)
+	var message string
+	var markup tg.ReplyMarkupClass
+	var err error
+	switch fields[1] {
+	case "private":
+		err = settings.Set(settings.PrivateMode, fields[2], query.UserID)
+		message = "How many links can a user generate per minute?"
+		markup = &tg.ReplyInlineMarkup{
+			Rows: []tg.KeyboardButtonRow{{
+				Buttons: []tg.KeyboardButtonClass{
+					&tg.KeyboardButtonCallback{Text: "Unlimited", Data: []byte("setup:rate:0")},
+					&tg.KeyboardButtonCallback{Text: "10", Data: []byte("setup:rate:10")},
+					&tg.KeyboardButtonCallback{Text: "30", Data: []byte("setup:rate:30")},
+				},
+			}},
+		}
+	case "rate":
+		err = settings.Set(settings.LinkRateLimit, fields[2], query.UserID)
+		if err == nil {
+			err = settings.Set(settings.SetupCompleted, time.Now().Format(time.RFC3339), query.UserID)
+		}
+		if err == nil {
+			endWizard(query.UserID)
+			message = setupSummary()
+		}
+	default:
+		return dispatcher.EndGroups
+	}
+	if err != nil {
+		utils.Logger.Error("Failed to save setup", zap.Error(err))
+		message = fmt.Sprintf("Error - %s", err.Error())
+		markup = nil
+	}
+	ctx.AnswerCallback(&tg.MessagesSetBotCallbackAnswerRequest{QueryID: query.QueryID})
+	ctx.EditMessage(chatId, &tg.MessagesEditMessageRequest{
+		ID:          query.MsgID,
+		Message:     message,
+		ReplyMarkup: markup,
+	})
+	return dispatcher.EndGroups
+}
+
+func setupSummary() string {
+	access := "everyone"
+	if config.Runtime.PrivateMode() {
+		access = "allowed users only"
+	}
+	rate := "unlimited"
+	if config.Runtime.LinkRateLimit() > 0 {
+		rate = fmt.Sprintf("%d per minute", config.Runtime.LinkRateLimit())
+	}
+	return fmt.Sprintf("🎉 Setup complete!\n\nURL: %s\nLog channel: %d\nAccess: %s\nLinks: %s\n\nThese settings override the environment variables. Run /setup again to change them.",
+		utils.PublicURL("/"), config.Runtime.LogChannelID(), access, rate)
+}
+
+func wizardStep(userID int64) (setupStep, bool) {
+	wizards.Lock()
+	defer wizards.Unlock()
+	step, ok := wizards.byUser[userID]
+	return step, ok
+}
+
+func setWizardStep(userID int64, step setupStep) {
+	wizards.Lock()
+	defer wizards.Unlock()
+	wizards.byUser[userID] = step
+}
+
+func endWizard(userID int64) {
+	wizards.Lock()
+	defer wizards.Unlock()
+	delete(wizards.byUser, userID)
+}
@@ -0,0 +1,141 @@
+package commands
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/utils"
+	"EverythingSuckz/fsb/internal/webauth"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/dispatcher/handlers"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/celestix/gotgproto/storage"
+	"github.com/gotd/td/tg"
+	"go.uber.org/zap"
+)
+
+func (m *command) LoadShare(dispatcher dispatcher.Dispatcher) {
+	log := m.log.Named("share")
+	defer log.Sugar().Info("Loaded")
+	dispatcher.AddHandler(handlers.NewCommand("share", share))
+	dispatcher.AddHandler(handlers.NewCommand("unshare", share))
+	dispatcher.AddHandler(handlers.NewCommand("weblogin", webLogin))
+}
+
+// share handles /share <user_id> and /unshare <user_id> in reply to a link. A link shared
+// with someone becomes private, only the owner and these users can open it after signing in
+// with /weblogin. Without a user ID it lists who the link is shared with.
+func share(ctx *ext.Context, u *ext.Update) error {
+	chatId := u.EffectiveChat().GetID()
+	peerChatId := ctx.PeerStorage.GetPeerById(chatId)
+	if peerChatId.Type != int(storage.TypeUser) {
+		return dispatcher.EndGroups
+	}
+	trackUser(u)
+	if !isAllowed(ctx, chatId) {
+		ctx.Reply(u, "You are not allowed to use this bot.", nil)
+		return dispatcher.EndGroups
+	}
+	if config.ValueOf.PublicMode {
+		ctx.Reply(u, "All links of this bot are public, they can't be shared with specific users.", nil)
+		return dispatcher.EndGroups
+	}
+	link, err := repliedLink(u)
+	if err != nil {
+		ctx.Reply(u, fmt.Sprintf("Error - %s", err.Error()), nil)
+		return dispatcher.EndGroups
+	}
+	linkRepository := database.GetLinkRepository()
+	args := u.Args()
+	if len(args) < 2 {
+		ctx.Reply(u, sharesMessage(link.TenantID, link.MessageID), nil)
+		return dispatcher.EndGroups
+	}
+	userID, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		ctx.Reply(u, "Invalid user ID.", nil)
+		return dispatcher.EndGroups
+	}
+	if strings.HasPrefix(args[0], "/unshare") {
+		removed, err := linkRepository.Unshare(link.TenantID, link.MessageID, userID)
+		if err != nil {
+			ctx.Reply(u, fmt.Sprintf("Error - %s", err.Error()), nil)
+			return dispatcher.EndGroups
+		}
+		if !removed {
+			ctx.Reply(u, fmt.Sprintf("This link isn't shared with %d.", userID), nil)
+			return dispatcher.EndGroups
+		}
+		ctx.Reply(u, fmt.Sprintf("✅ %d can't open this link anymore.\n\n%s", userID, sharesMessage(link.TenantID, link.MessageID)), nil)
+		return dispatcher.EndGroups
+	}
+	if userID == chatId {
+		ctx.Reply(u, "You can always open your own links.", nil)
+		return dispatcher.EndGroups
+	}
+	userRepository := database.GetUserRepository().WithContext(ctx)
+	if userRepository == nil {
+		ctx.Reply(u, "❌ User database is not available at the moment.", nil)
+		return dispatcher.EndGroups
+	}
+	// links can only be shared with users of the bot, who can sign in with /weblogin
+	if user, err := userRepository.Get(userID); err != nil || user == nil || !isAllowed(ctx, userID) {
+		ctx.Reply(u, fmt.Sprintf("%d isn't a user of this bot, they have to start it and be allowed to use it first.", userID), nil)
+		return dispatcher.EndGroups
+	}
+	if err := linkRepository.Share(link.TenantID, link.MessageID, userID); err != nil {
+		utils.Logger.Error("Failed to share link", zap.Error(err), zap.Int("messageID", link.MessageID))
+		ctx.Reply(u, fmt.Sprintf("Error - %s", err.Error()), nil)
+		return dispatcher.EndGroups
+	}
+	_, err = ctx.SendMessage(userID, &tg.MessagesSendMessageRequest{
+		Message: fmt.Sprintf("🔒 %d shared %s with you:\n%s\n\nSend /weblogin to open it in your browser.",
+			chatId, link.FileName, utils.StreamURL(link.TenantID, link.MessageID, link.Hash)),
+	})
+	if err != nil {
+		utils.Logger.Debug("Failed to notify user of shared link", zap.Error(err), zap.Int64("userID", userID))
+	}
+	ctx.Reply(u, "🔒 "+sharesMessage(link.TenantID, link.MessageID), nil)
+	return dispatcher.EndGroups
+}
+
+// sharesMessage describes who can open the link
+func sharesMessage(tenantID uint, messageID int) string {
+	shares, err := database.GetLinkRepository().ListShares(tenantID, messageID)
+	if err != nil {
+		return fmt.Sprintf("Error - %s", err.Error())
+	}
+	if len(shares) == 0 {
+		return "Anyone with this link can open it. Reply /share <user_id> to it to make it private."
+	}
+	names := make([]string, len(shares))
+	for i, userID := range shares {
+		names[i] = describeUserID(userID)
+	}
+	return fmt.Sprintf("Only you and %s can open this link, after signing in with /weblogin.\n\n"+
+		"Reply /unshare <user_id> to it to remove someone, the link is public again without anyone.", strings.Join(names, ", "))
+}
+
+// webLogin sends a link that signs in the browser it's opened in as the sender,
+// so that they can open private links shared with them
+func webLogin(ctx *ext.Context, u *ext.Update) error {
+	chatId := u.EffectiveChat().GetID()
+	peerChatId := ctx.PeerStorage.GetPeerById(chatId)
+	if peerChatId.Type != int(storage.TypeUser) {
+		return dispatcher.EndGroups
+	}
+	trackUser(u)
+	if !isAllowed(ctx, chatId) {
+		ctx.Reply(u, "You are not allowed to use this bot.", nil)
+		return dispatcher.EndGroups
+	}
+	loginURL := utils.TenantURL(0, "/login?token="+url.QueryEscape(webauth.LoginToken(chatId)))
+	ctx.Reply(u, fmt.Sprintf("🔑 Open this link within %s to sign in the browser you open it in:\n%s\n\n"+
+		"Don't share it, whoever opens it can open the private links shared with you for %s.",
+		formatWait(webauth.LoginTTL), loginURL, formatWait(webauth.SessionTTL)), nil)
+	return dispatcher.EndGroups
+}
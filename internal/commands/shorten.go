@@ -0,0 +1,105 @@
+package commands
+
+import (
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/types"
+	"EverythingSuckz/fsb/internal/utils"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/dispatcher/handlers"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/celestix/gotgproto/storage"
+	"go.uber.org/zap"
+)
+
+const (
+	aliasAlphabet = "abcdefghijkmnpqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	aliasLength   = 7
+)
+
+// customAlias restricts the aliases users can choose
+var customAlias = regexp.MustCompile(`^[A-Za-z0-9_-]{3,32}$`)
+
+func (m *command) LoadShorten(dispatcher dispatcher.Dispatcher) {
+	log := m.log.Named("shorten")
+	defer log.Sugar().Info("Loaded")
+	dispatcher.AddHandler(handlers.NewCommand("shorten", shorten))
+}
+
+func shorten(ctx *ext.Context, u *ext.Update) error {
+	chatId := u.EffectiveChat().GetID()
+	peerChatId := ctx.PeerStorage.GetPeerById(chatId)
+	if peerChatId.Type != int(storage.TypeUser) {
+		return dispatcher.EndGroups
+	}
+	trackUser(u)
+	if !isAllowed(ctx, chatId) {
+		ctx.Reply(u, "You are not allowed to use this bot.", nil)
+		return dispatcher.EndGroups
+	}
+	shortLinkRepository := database.GetShortLinkRepository()
+	if shortLinkRepository == nil {
+		ctx.Reply(u, "Short links are not available at the moment.", nil)
+		return dispatcher.EndGroups
+	}
+	link, err := repliedLink(u)
+	if err != nil {
+		ctx.Reply(u, fmt.Sprintf("Error - %s", err.Error()), nil)
+		return dispatcher.EndGroups
+	}
+	var shortLink *types.ShortLink
+	if args := u.Args(); len(args) > 1 {
+		if !customAlias.MatchString(args[1]) {
+			ctx.Reply(u, "Aliases must be 3 to 32 letters, digits, dashes or underscores.", nil)
+			return dispatcher.EndGroups
+		}
+		shortLink = &types.ShortLink{Alias: args[1], TenantID: link.TenantID, MessageID: link.MessageID, UserID: chatId, Custom: true}
+		err = shortLinkRepository.Create(shortLink)
+	} else {
+		shortLink, err = generatedShortLink(shortLinkRepository, link, chatId)
+	}
+	if errors.Is(err, database.ErrAliasTaken) {
+		ctx.Reply(u, "This alias is already taken, please choose another one.", nil)
+		return dispatcher.EndGroups
+	}
+	if err != nil {
+		utils.Logger.Error("Failed to create short link", zap.Error(err))
+		ctx.Reply(u, "❌ Failed to create the short link.", nil)
+		return dispatcher.EndGroups
+	}
+	message := fmt.Sprintf("🔗 Short link for %s:\n%s", link.FileName, utils.PublicURL("/s/"+shortLink.Alias))
+	ctx.Reply(u, message, &ext.ReplyOpts{ReplyToMessageId: u.EffectiveMessage.ID})
+	return dispatcher.EndGroups
+}
+
+// generatedShortLink returns the generated short link of the link, creating it if needed
+func generatedShortLink(repository *database.ShortLinkRepository, link *types.Link, userID int64) (*types.ShortLink, error) {
+	existing, err := repository.FindGenerated(link.TenantID, link.MessageID)
+	if err != nil || existing != nil {
+		return existing, err
+	}
+	for attempt := 0; attempt < 5; attempt++ {
+		shortLink := &types.ShortLink{Alias: randomAlias(), TenantID: link.TenantID, MessageID: link.MessageID, UserID: userID}
+		err = repository.Create(shortLink)
+		if !errors.Is(err, database.ErrAliasTaken) {
+			return shortLink, err
+		}
+	}
+	return nil, err
+}
+
+// randomAlias returns a random alias without easily confused characters
+func randomAlias() string {
+	alias := make([]byte, aliasLength)
+	max := big.NewInt(int64(len(aliasAlphabet)))
+	for i := range alias {
+		n, _ := rand.Int(rand.Reader, max)
+		alias[i] = aliasAlphabet[n.Int64()]
+	}
+	return string(alias)
+}
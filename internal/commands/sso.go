@@ -0,0 +1,76 @@
+package commands
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/oidc"
+	"EverythingSuckz/fsb/internal/utils"
+	"EverythingSuckz/fsb/internal/webauth"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/dispatcher/handlers"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/celestix/gotgproto/storage"
+)
+
+func (m *command) LoadSSO(dispatcher dispatcher.Dispatcher) {
+	log := m.log.Named("sso")
+	defer log.Sugar().Info("Loaded")
+	dispatcher.AddHandler(handlers.NewCommand("sso", sso))
+}
+
+// sso sends a link that links the account of the OIDC provider the user signs in with to
+// them, lists the linked accounts, or unlinks them with /sso unlink
+func sso(ctx *ext.Context, u *ext.Update) error {
+	chatId := u.EffectiveChat().GetID()
+	peerChatId := ctx.PeerStorage.GetPeerById(chatId)
+	if peerChatId.Type != int(storage.TypeUser) {
+		return dispatcher.EndGroups
+	}
+	trackUser(u)
+	if !isAllowed(ctx, chatId) {
+		ctx.Reply(u, "You are not allowed to use this bot.", nil)
+		return dispatcher.EndGroups
+	}
+	oidcRepository := database.GetOIDCRepository()
+	if !oidc.Enabled() || oidcRepository == nil {
+		ctx.Reply(u, "Single sign-on is not enabled on this bot.", nil)
+		return dispatcher.EndGroups
+	}
+	args := u.Args()
+	if len(args) > 1 && strings.EqualFold(args[1], "unlink") {
+		confirm(ctx, u, "Unlink your single sign-on accounts? Browsers signed in with them stay signed in until you change BOT_TOKEN or the session expires.", func(ctx *ext.Context) string {
+			unlinked, err := oidcRepository.Unlink(chatId)
+			if err != nil {
+				return fmt.Sprintf("Error - %s", err.Error())
+			}
+			return fmt.Sprintf("🗑 Unlinked %d accounts.", unlinked)
+		})
+		return dispatcher.EndGroups
+	}
+	identities, err := oidcRepository.List(chatId)
+	if err != nil {
+		ctx.Reply(u, fmt.Sprintf("Error - %s", err.Error()), nil)
+		return dispatcher.EndGroups
+	}
+	var sb strings.Builder
+	if len(identities) > 0 {
+		sb.WriteString("🔐 Linked accounts:\n")
+		for _, identity := range identities {
+			name := identity.Email
+			if name == "" {
+				name = identity.Subject
+			}
+			sb.WriteString(fmt.Sprintf("- %s, since %s\n", name, identity.CreatedAt.Format("2006-01-02")))
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString(fmt.Sprintf("Open this link within 10 minutes and sign in to link your single sign-on account to this Telegram account:\n%s\n\n"+
+		"Then sign in to the web app and the API with it at %s. /sso unlink removes the linked accounts.",
+		utils.PublicURL("/oidc/login?link="+url.QueryEscape(webauth.SSOLinkToken(chatId))), utils.PublicURL("/oidc/login?next="+url.QueryEscape(config.ValueOf.BasePath+"/app"))))
+	ctx.Reply(u, sb.String(), nil)
+	return dispatcher.EndGroups
+}
@@ -1,13 +1,15 @@
 package commands
 
 import (
-	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/database"
 	"EverythingSuckz/fsb/internal/utils"
+	"strings"
 
 	"github.com/celestix/gotgproto/dispatcher"
 	"github.com/celestix/gotgproto/dispatcher/handlers"
 	"github.com/celestix/gotgproto/ext"
 	"github.com/celestix/gotgproto/storage"
+	"go.uber.org/zap"
 )
 
 func (m *command) LoadStart(dispatcher dispatcher.Dispatcher) {
@@ -22,11 +24,32 @@ func start(ctx *ext.Context, u *ext.Update) error {
 	if peerChatId.Type != int(storage.TypeUser) {
 		return dispatcher.EndGroups
 	}
-	if len(config.ValueOf.AllowedUsers) != 0 && !utils.Contains(config.ValueOf.AllowedUsers, chatId) {
+	trackUser(u)
+	// deep links (t.me/<bot>?start=<payload>) carry a referral code or an invite code
+	if args := u.Args(); len(args) > 1 {
+		if code, ok := strings.CutPrefix(args[1], "ref_"); ok {
+			recordReferral(chatId, code)
+		} else if joinTenant(ctx, u, chatId, args[1]) {
+			return dispatcher.EndGroups
+		}
+	}
+	if !isAllowed(ctx, chatId) {
 		ctx.Reply(u, "You are not allowed to use this bot.", nil)
 		return dispatcher.EndGroups
 	}
 
 	ctx.Reply(u, "Need a direct streamable link to a file? Send it my way! 🤓\n\nJoin my Update Channel @haris_garage 🗿 for more updates.\n\nLink validity: 24 hours ⏳\n\nPro Tip: Use 1DM Browser for lightning-fast downloads! 🔥\n\n📊 Use /stats to view bot statistics", nil)
+	resumeOnboarding(ctx, chatId)
 	return dispatcher.EndGroups
 }
+
+// recordReferral remembers the referral code the user started the bot with
+func recordReferral(userID int64, code string) {
+	userRepository := database.GetUserRepository()
+	if code == "" || len(code) > 64 || userRepository == nil {
+		return
+	}
+	if err := userRepository.SetReferral(userID, code); err != nil {
+		utils.Logger.Error("Failed to record referral", zap.Error(err), zap.Int64("userID", userID))
+	}
+}
@@ -1,10 +1,12 @@
 package commands
 
 import (
-	"EverythingSuckz/fsb/config"
 	"EverythingSuckz/fsb/internal/cache"
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/downloads"
 	"EverythingSuckz/fsb/internal/types"
 	"EverythingSuckz/fsb/internal/utils"
+	"EverythingSuckz/fsb/internal/watchdog"
 	"fmt"
 	"time"
 
@@ -28,7 +30,7 @@ func stats(ctx *ext.Context, u *ext.Update) error {
 	}
 	
 	// Check if user is allowed (if restrictions are enabled)
-	if len(config.ValueOf.AllowedUsers) != 0 && !utils.Contains(config.ValueOf.AllowedUsers, chatId) {
+	if !isAllowed(ctx, chatId) {
 		ctx.Reply(u, "You are not allowed to use this bot.", nil)
 		return dispatcher.EndGroups
 	}
@@ -48,13 +50,13 @@ func stats(ctx *ext.Context, u *ext.Update) error {
 	}
 
 	// Format the statistics message
-	message := formatStatisticsMessage(stats)
+	message := formatStatisticsMessage(stats, utils.IsAdmin(ctx, ctx.Raw, ctx.PeerStorage, chatId))
 	
 	ctx.Reply(u, message, nil)
 	return dispatcher.EndGroups
 }
 
-func formatStatisticsMessage(stats types.StatisticsResponse) string {
+func formatStatisticsMessage(stats types.StatisticsResponse, admin bool) string {
 	message := "📊 Bot Statistics\n\n"
 	
 	// Today's stats
@@ -77,8 +79,85 @@ func formatStatisticsMessage(stats types.StatisticsResponse) string {
 		stats.Total.FileCount, 
 		utils.FormatFileSizeShort(stats.Total.TotalSize))
 	
+	message += formatPlaybackStats()
+	if admin {
+		message += formatActiveUsers()
+		message += formatReferralStats()
+		message += formatDiskUsage()
+	}
 	message += "🔄 Stats are updated in real-time\n"
 	message += "⏰ Last updated: " + time.Now().Format("2006-01-02 15:04:05") + "."
 	
 	return message
 } 
+
+// formatPlaybackStats summarizes the web player telemetry of the last 7 days
+func formatPlaybackStats() string {
+	telemetryRepository := database.GetTelemetryRepository()
+	if telemetryRepository == nil {
+		return ""
+	}
+	summary, err := telemetryRepository.Summary(time.Now().AddDate(0, 0, -7))
+	if err != nil || summary.Sessions == 0 {
+		return ""
+	}
+	return fmt.Sprintf("▶️ Playback (7 days): %d sessions, avg watch time %s, %.2f stalls per session\n\n",
+		summary.Sessions,
+		utils.TimeFormat(uint64(summary.AvgWatchTime)),
+		summary.StallRate)
+}
+
+// formatActiveUsers counts the users seen in the last day, week and month
+func formatActiveUsers() string {
+	userRepository := database.GetUserRepository()
+	if userRepository == nil {
+		return ""
+	}
+	now := time.Now()
+	var counts [3]int64
+	for i, since := range []time.Time{now.AddDate(0, 0, -1), now.AddDate(0, 0, -7), now.AddDate(0, 0, -30)} {
+		count, err := userRepository.CountActiveSince(since)
+		if err != nil {
+			return ""
+		}
+		counts[i] = count
+	}
+	return fmt.Sprintf("👥 Active users: %d daily, %d weekly, %d monthly\n\n", counts[0], counts[1], counts[2])
+}
+
+// formatReferralStats lists the referral codes that brought the most users in the last 30 days
+func formatReferralStats() string {
+	userRepository := database.GetUserRepository()
+	if userRepository == nil {
+		return ""
+	}
+	referrals, err := userRepository.ReferralStats(time.Now().AddDate(0, 0, -30), 5)
+	if err != nil || len(referrals) == 0 {
+		return ""
+	}
+	message := "📣 Referrals (30 days):\n"
+	for _, referral := range referrals {
+		message += fmt.Sprintf("• %s: %d users, %d active\n", referral.Code, referral.Users, referral.Active)
+	}
+	return message + "\n"
+}
+
+// formatDiskUsage shows the free space of the disks of the generated media and SAVE_DIR
+func formatDiskUsage() string {
+	disks := watchdog.Usage()
+	if len(disks) == 0 {
+		return ""
+	}
+	message := "💾 Disks:\n"
+	for _, disk := range disks {
+		message += fmt.Sprintf("• %s: %s free of %s", disk.Name, utils.FormatFileSizeShort(disk.Free), utils.FormatFileSizeShort(disk.Total))
+		if disk.Low {
+			message += " ⚠️"
+		}
+		message += "\n"
+	}
+	if downloads.Paused() {
+		message += "Saving files is paused until there's enough free space.\n"
+	}
+	return message + "\n"
+}
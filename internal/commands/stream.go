@@ -5,14 +5,26 @@ import (
 	"strings"
 
 	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/abuse"
+	"EverythingSuckz/fsb/internal/access"
 	"EverythingSuckz/fsb/internal/cache"
+	"EverythingSuckz/fsb/internal/category"
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/enrich"
+	"EverythingSuckz/fsb/internal/onboarding"
+	"EverythingSuckz/fsb/internal/policy"
+	"EverythingSuckz/fsb/internal/profile"
+	"EverythingSuckz/fsb/internal/scanner"
+	"EverythingSuckz/fsb/internal/tenant"
+	"EverythingSuckz/fsb/internal/types"
 	"EverythingSuckz/fsb/internal/utils"
 
 	"github.com/celestix/gotgproto/dispatcher"
 	"github.com/celestix/gotgproto/dispatcher/handlers"
+	"github.com/celestix/gotgproto/dispatcher/handlers/filters"
 	"github.com/celestix/gotgproto/ext"
 	"github.com/celestix/gotgproto/storage"
-	"github.com/celestix/gotgproto/types"
+	tgtypes "github.com/celestix/gotgproto/types"
 	"github.com/gotd/td/tg"
 	"go.uber.org/zap"
 )
@@ -21,11 +33,11 @@ func (m *command) LoadStream(dispatcher dispatcher.Dispatcher) {
 	log := m.log.Named("start")
 	defer log.Sugar().Info("Loaded")
 	dispatcher.AddHandler(
-		handlers.NewMessage(nil, sendLink),
+		handlers.NewMessage(filters.Message.Media, sendLink),
 	)
 }
 
-func supportedMediaFilter(m *types.Message) (bool, error) {
+func supportedMediaFilter(m *tgtypes.Message) (bool, error) {
 	if not := m.Media == nil; not {
 		return false, dispatcher.EndGroups
 	}
@@ -44,22 +56,35 @@ func supportedMediaFilter(m *types.Message) (bool, error) {
 func sendLink(ctx *ext.Context, u *ext.Update) error {
 	chatId := u.EffectiveChat().GetID()
 	peerChatId := ctx.PeerStorage.GetPeerById(chatId)
-	if peerChatId.Type != int(storage.TypeUser) {
+	// in private chats the chat is the user, in groups it's the sender of the message
+	userID := chatId
+	group := peerChatId.Type != int(storage.TypeUser)
+	if group {
+		var ok bool
+		if userID, ok = groupSender(u); !ok {
+			return dispatcher.EndGroups
+		}
+	}
+	if alreadyProcessed(chatId, u.EffectiveMessage) {
 		return dispatcher.EndGroups
 	}
-	if len(config.ValueOf.AllowedUsers) != 0 && !utils.Contains(config.ValueOf.AllowedUsers, chatId) {
-		ctx.Reply(u, "You are not allowed to use this bot.", nil)
+	trackUser(u)
+	if !isAllowed(ctx, userID) {
+		// the files of other members of the group aren't answered
+		if !group {
+			reply(ctx, u, "You are not allowed to use this bot.", nil)
+		}
 		return dispatcher.EndGroups
 	}
 
 	// Check if force sub is enabled and user is subscribed
-	if config.ValueOf.ForceSubChannel != "" {
-		isSubscribed, err := utils.IsUserSubscribed(ctx, ctx.Raw, ctx.PeerStorage, chatId)
+	if config.ValueOf.ForceSubChannel != "" && !utils.IsAdmin(ctx, ctx.Raw, ctx.PeerStorage, userID) {
+		isSubscribed, err := utils.IsUserSubscribed(ctx, ctx.Raw, ctx.PeerStorage, userID)
 		if err != nil {
 			// Log the error but don't show it to the user
 			utils.Logger.Error("Error checking subscription status",
 				zap.Error(err),
-				zap.Int64("userID", chatId),
+				zap.Int64("userID", userID),
 				zap.String("channel", config.ValueOf.ForceSubChannel))
 			// Show join channel message instead of error
 			row := tg.KeyboardButtonRow{
@@ -73,7 +98,7 @@ func sendLink(ctx *ext.Context, u *ext.Update) error {
 			markup := &tg.ReplyInlineMarkup{
 				Rows: []tg.KeyboardButtonRow{row},
 			}
-			ctx.Reply(u, "Please join our channel to get stream links.", &ext.ReplyOpts{
+			reply(ctx, u, "Please join our channel to get stream links.", &ext.ReplyOpts{
 				Markup: markup,
 			})
 			return dispatcher.EndGroups
@@ -90,7 +115,7 @@ func sendLink(ctx *ext.Context, u *ext.Update) error {
 			markup := &tg.ReplyInlineMarkup{
 				Rows: []tg.KeyboardButtonRow{row},
 			}
-			ctx.Reply(u, "Please join our channel to get stream links.", &ext.ReplyOpts{
+			reply(ctx, u, "Please join our channel to get stream links.", &ext.ReplyOpts{
 				Markup: markup,
 			})
 			return dispatcher.EndGroups
@@ -99,23 +124,69 @@ func sendLink(ctx *ext.Context, u *ext.Update) error {
 
 	supported, err := supportedMediaFilter(u.EffectiveMessage)
 	if err != nil {
+		releaseProcessed(chatId, u.EffectiveMessage)
 		return err
 	}
 	if !supported {
-		ctx.Reply(u, "Sorry, this message type is unsupported.", nil)
+		reply(ctx, u, "Sorry, this message type is unsupported.", nil)
+		return dispatcher.EndGroups
+	}
+	media, err := utils.FileFromMedia(u.EffectiveMessage.Media)
+	previous, replaced := editedLink(u, media)
+	if !replaced {
+		return dispatcher.EndGroups
+	}
+	if err == nil {
+		bypass := config.ValueOf.PolicyAdminBypass && utils.IsAdmin(ctx, ctx.Raw, ctx.PeerStorage, userID)
+		if err := policy.Check(media); err != nil && !bypass {
+			reply(ctx, u, err.Error(), &ext.ReplyOpts{ReplyToMessageId: u.EffectiveMessage.ID})
+			return dispatcher.EndGroups
+		}
+	}
+	workspace := access.Tenant(userID)
+	var tenantID uint
+	if workspace != nil {
+		tenantID = workspace.ID
+	}
+	if previous == nil && media != nil {
+		if existing := duplicateLink(tenantID, userID, media); existing != nil {
+			message, markup := utils.LinkReply(existing)
+			message = fmt.Sprintf("♻️ You sent this file before, on %s. Here is its link, turn this off in /settings.\n\n%s",
+				existing.CreatedAt.Format("2006-01-02"), message)
+			reply(ctx, u, message, &ext.ReplyOpts{Markup: markup, ReplyToMessageId: u.EffectiveMessage.ID})
+			return dispatcher.EndGroups
+		}
+	}
+	detector := abuse.GetDetector()
+	if detector != nil && !detector.AllowLink(userID) {
+		reply(ctx, u, "You are generating links too fast. Please wait a bit and try again.", nil)
+		return dispatcher.EndGroups
+	}
+	if workspace != nil && access.QuotaExceeded(workspace, userID) {
+		reply(ctx, u, fmt.Sprintf("You have reached the daily quota of %d links of %s. Please try again tomorrow.", workspace.DailyLinkQuota, workspace.Name), nil)
 		return dispatcher.EndGroups
 	}
-	update, err := utils.ForwardMessages(ctx, chatId, config.ValueOf.LogChannelID, u.EffectiveMessage.ID)
+	if media != nil && scanner.Enabled() && access.Infected(ctx, ctx.Raw, userID, media, "telegram") {
+		reply(ctx, u, "⚠️ This file was blocked by the virus scanner. An admin will review it.", &ext.ReplyOpts{ReplyToMessageId: u.EffectiveMessage.ID})
+		return dispatcher.EndGroups
+	}
+	forward := utils.ForwardMessages
+	if config.ValueOf.MirrorMode {
+		forward = utils.CopyMessage
+	}
+	update, err := forward(ctx, chatId, tenant.LogChannel(tenantID), u.EffectiveMessage.ID)
 	if err != nil {
 		utils.Logger.Sugar().Error(err)
-		ctx.Reply(u, fmt.Sprintf("Error - %s", err.Error()), nil)
+		releaseProcessed(chatId, u.EffectiveMessage)
+		reply(ctx, u, fmt.Sprintf("Error - %s", err.Error()), nil)
 		return dispatcher.EndGroups
 	}
 	messageID := update.Updates[0].(*tg.UpdateMessageID).ID
 	doc := update.Updates[1].(*tg.UpdateNewChannelMessage).Message.(*tg.Message).Media
 	file, err := utils.FileFromMedia(doc)
 	if err != nil {
-		ctx.Reply(u, fmt.Sprintf("Error - %s", err.Error()), nil)
+		releaseProcessed(chatId, u.EffectiveMessage)
+		reply(ctx, u, fmt.Sprintf("Error - %s", err.Error()), nil)
 		return dispatcher.EndGroups
 	}
 	fullHash := utils.PackFile(
@@ -125,7 +196,6 @@ func sendLink(ctx *ext.Context, u *ext.Update) error {
 		file.ID,
 	)
 	hash := utils.GetShortHash(fullHash)
-	link := fmt.Sprintf("%s/stream/%d?hash=%s", config.ValueOf.Host, messageID, hash)
 	
 	// Record statistics for this file
 	statsCache := cache.GetStatsCache()
@@ -136,43 +206,176 @@ func sendLink(ctx *ext.Context, u *ext.Update) error {
 		}
 	}
 	
-	// Create formatted message with clickable hyperlink
-	message := fmt.Sprintf("📄 File Name: %s\n\n📥 Download Link:\n%s\n\n⏳ Link validity is 24 hours", file.FileName, link)
-	
-	row := tg.KeyboardButtonRow{
-		Buttons: []tg.KeyboardButtonClass{
-			&tg.KeyboardButtonURL{
-				Text: "Download",
-				URL:  link + "&d=true",
-			},
-		},
-	}
-	// Add Stream button only for video files
-	if strings.Contains(file.MimeType, "video") {
-		streamURL := fmt.Sprintf("https://stream.hariharantelegram.workers.dev/?video=%s", link)
-		row.Buttons = append(row.Buttons, &tg.KeyboardButtonURL{
-			Text: "Stream",
-			URL:  streamURL,
-		})
+	link := &types.Link{
+		TenantID:  tenantID,
+		MessageID: messageID,
+		Hash:      hash,
+		UserID:    userID,
+		FileName:  file.FileName,
+		FileSize:  file.FileSize,
+		MimeType:  file.MimeType,
+		FileID:    file.ID,
+		Duration:  file.Duration,
+		Title:     file.Title,
+		Performer: file.Performer,
+		Category:  category.Of(file),
 	}
-	markup := &tg.ReplyInlineMarkup{
-		Rows: []tg.KeyboardButtonRow{row},
+	// links are found by the message they were generated for in the private chat with the user
+	if !group {
+		link.SourceID = u.EffectiveMessage.ID
+		link.GroupedID = u.EffectiveMessage.GroupedID
 	}
-	if strings.Contains(link, "http://localhost") {
-		_, err = ctx.Reply(u, message, &ext.ReplyOpts{
-			NoWebpage:        false,
-			ReplyToMessageId: u.EffectiveMessage.ID,
+	if err := publishLink(ctx, u, link, previous); err != nil {
+		utils.Logger.Error("Failed to generate link", zap.Error(err), zap.Int64("userID", userID))
+		// the copy in the log channel is removed with the link
+		ctx.DeleteMessages(tenant.LogChannel(tenantID), []int{messageID})
+		releaseProcessed(chatId, u.EffectiveMessage)
+		reply(ctx, u, fmt.Sprintf("Error - %s", err.Error()), nil)
+		return dispatcher.EndGroups
+	}
+	if detector != nil {
+		go detector.CheckLinks(userID)
+	}
+	continueOnboarding(ctx, userID, onboarding.StepFile)
+	return dispatcher.EndGroups
+}
+
+// alreadyProcessed reports whether the message, or its edit, was handled before, when Telegram
+// delivers its update twice. It claims the message, so that a second delivery arriving while
+// it's handled is skipped too, and the claim is released with releaseProcessed when no link
+// could be generated. Messages are handled anyway if the database fails.
+func alreadyProcessed(chatId int64, message *tgtypes.Message) bool {
+	processedRepository := database.GetProcessedRepository()
+	if processedRepository == nil {
+		return false
+	}
+	claimed, err := processedRepository.Claim(chatId, message.ID, message.EditDate)
+	if err != nil {
+		utils.Logger.Error("Failed to record processed message", zap.Error(err), zap.Int64("userID", chatId))
+		return false
+	}
+	if !claimed {
+		utils.Logger.Debug("Skipped message that was processed before", zap.Int64("userID", chatId), zap.Int("messageID", message.ID))
+	}
+	return !claimed
+}
+
+// releaseProcessed releases the claim of alreadyProcessed after handling the message failed
+func releaseProcessed(chatId int64, message *tgtypes.Message) {
+	processedRepository := database.GetProcessedRepository()
+	if processedRepository == nil {
+		return
+	}
+	if err := processedRepository.Release(chatId, message.ID, message.EditDate); err != nil {
+		utils.Logger.Error("Failed to release processed message", zap.Error(err), zap.Int64("userID", chatId))
+	}
+}
+
+// publishLink stores the link, sends its reply, or edits the reply of the link it replaces,
+// and only then makes it work and tells the open players about it. If a step fails, the
+// previous ones are undone, so that the database, the chat and the players agree on the links.
+func publishLink(ctx *ext.Context, u *ext.Update, link *types.Link, previous *types.Link) error {
+	chatId := u.EffectiveChat().GetID()
+	linkRepository := database.GetLinkRepository()
+	if linkRepository != nil {
+		if err := linkRepository.Reserve(link); err != nil {
+			return fmt.Errorf("failed to store the link: %w", err)
+		}
+	}
+	message, markup := utils.LinkReply(link)
+	var replyID int
+	var err error
+	if previous != nil {
+		// the user replaced the file of the message, its reply gets the link of the new file
+		replyID = previous.ReplyID
+		_, err = ctx.EditMessage(chatId, &tg.MessagesEditMessageRequest{
+			ID:          previous.ReplyID,
+			Message:     message,
+			ReplyMarkup: markup,
 		})
 	} else {
-		_, err = ctx.Reply(u, message, &ext.ReplyOpts{
+		var sent *tgtypes.Message
+		sent, err = reply(ctx, u, message, &ext.ReplyOpts{
 			Markup:           markup,
 			NoWebpage:        false,
 			ReplyToMessageId: u.EffectiveMessage.ID,
 		})
+		if err == nil {
+			replyID = sent.ID
+		}
 	}
 	if err != nil {
-		utils.Logger.Sugar().Error(err)
-		ctx.Reply(u, fmt.Sprintf("Error - %s", err.Error()), nil)
+		discardLink(link)
+		return err
 	}
-	return dispatcher.EndGroups
+	// the replies are edited in the private chat with the user, not in groups
+	if chatId == link.UserID {
+		link.ReplyID = replyID
+	}
+	if linkRepository == nil {
+		return nil
+	}
+	if err := linkRepository.Publish(link, previous); err != nil {
+		// the reply would show a link that doesn't work
+		if previous != nil {
+			message, markup := utils.LinkReply(previous)
+			ctx.EditMessage(chatId, &tg.MessagesEditMessageRequest{ID: previous.ReplyID, Message: message, ReplyMarkup: markup})
+		} else {
+			ctx.DeleteMessages(chatId, []int{replyID})
+		}
+		link.ReplyID = 0
+		discardLink(link)
+		return fmt.Errorf("failed to store the link: %w", err)
+	}
+	profile.Notify(profile.Of(link.UserID))
+	enrich.Enqueue(link)
+	return nil
+}
+
+// discardLink deletes a reserved link whose reply failed. Links that can't be deleted stay
+// removed, they don't work.
+func discardLink(link *types.Link) {
+	linkRepository := database.GetLinkRepository()
+	if linkRepository == nil {
+		return
+	}
+	if err := linkRepository.Discard(link); err != nil {
+		utils.Logger.Error("Failed to discard link", zap.Error(err), zap.String("link", link.StorageKey()))
+	}
+}
+
+// editedLink returns the link generated for the message if the update is an edit of it.
+// replaced is false if the edit kept the file, e.g. when only the caption was changed.
+func editedLink(u *ext.Update, file *types.File) (link *types.Link, replaced bool) {
+	linkRepository := database.GetLinkRepository()
+	if _, ok := u.UpdateClass.(*tg.UpdateEditMessage); !ok || linkRepository == nil {
+		return nil, true
+	}
+	link, err := linkRepository.FindByUserMessage(u.EffectiveChat().GetID(), u.EffectiveMessage.ID)
+	if err != nil || link.SourceID != u.EffectiveMessage.ID || link.ReplyID == 0 {
+		return nil, true
+	}
+	// compared with the hash length of the link, HASH_LENGTH may have changed since
+	if file != nil && strings.HasPrefix(utils.PackFile(file.FileName, file.FileSize, file.MimeType, file.ID), link.Hash) {
+		return link, false
+	}
+	return link, true
+}
+
+// duplicateLink returns the link the user already has for the file, unless they turned
+// reusing links off in /settings
+func duplicateLink(tenantID uint, userID int64, file *types.File) *types.Link {
+	linkRepository := database.GetLinkRepository()
+	userRepository := database.GetUserRepository()
+	if linkRepository == nil || userRepository == nil || file.ID == 0 {
+		return nil
+	}
+	if user, err := userRepository.Get(userID); err == nil && user != nil && !user.ReuseLinks {
+		return nil
+	}
+	link, err := linkRepository.FindByFile(tenantID, userID, file.ID)
+	if err != nil {
+		return nil
+	}
+	return link
 }
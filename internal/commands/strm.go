@@ -0,0 +1,51 @@
+package commands
+
+import (
+	"EverythingSuckz/fsb/internal/library"
+	"EverythingSuckz/fsb/internal/utils"
+	"fmt"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/dispatcher/handlers"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/celestix/gotgproto/storage"
+	"go.uber.org/zap"
+)
+
+func (m *command) LoadStrm(dispatcher dispatcher.Dispatcher) {
+	log := m.log.Named("strm")
+	defer log.Sugar().Info("Loaded")
+	dispatcher.AddHandler(handlers.NewCommand("strm", strm))
+}
+
+// strm replies with the .strm file of the replied media, for Jellyfin, Plex or Kodi
+// libraries, and tells where to download the files of all recent media at once
+func strm(ctx *ext.Context, u *ext.Update) error {
+	chatId := u.EffectiveChat().GetID()
+	peerChatId := ctx.PeerStorage.GetPeerById(chatId)
+	if peerChatId.Type != int(storage.TypeUser) {
+		return dispatcher.EndGroups
+	}
+	trackUser(u)
+	if !isAllowed(ctx, chatId) {
+		ctx.Reply(u, "You are not allowed to use this bot.", nil)
+		return dispatcher.EndGroups
+	}
+	link, err := repliedLink(u)
+	if err != nil {
+		ctx.Reply(u, fmt.Sprintf("Error - %s", err.Error()), nil)
+		return dispatcher.EndGroups
+	}
+	if !library.Playable(link) {
+		ctx.Reply(u, "Only videos and audio can be added to a media library.", nil)
+		return dispatcher.EndGroups
+	}
+	caption := fmt.Sprintf("📺 Put this file in a Jellyfin, Plex or Kodi library folder to play it from there.\n\n"+
+		"Sign in with /weblogin and open %s for the files of all your recent media.", utils.PublicURL("/api/library/strm"))
+	err = sendTextFile(ctx, chatId, u.EffectiveMessage.ID, library.FileName(link), "text/plain", library.Content(link), caption)
+	if err != nil {
+		utils.Logger.Error("Failed to send strm file", zap.Error(err))
+		ctx.Reply(u, "❌ Failed to create the .strm file.", nil)
+	}
+	return dispatcher.EndGroups
+}
@@ -0,0 +1,221 @@
+package commands
+
+import (
+	"EverythingSuckz/fsb/internal/access"
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/tenant"
+	"EverythingSuckz/fsb/internal/types"
+	"EverythingSuckz/fsb/internal/utils"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/dispatcher/handlers"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/celestix/gotgproto/storage"
+	"go.uber.org/zap"
+)
+
+const tenantUsage = `Usage:
+/tenant add <path> <log_channel_id> <name>
+/tenant list
+/tenant admin <tenant_id> <user_id>
+/tenant unadmin <tenant_id> <user_id>
+/tenant quota <tenant_id> <links_per_day>`
+
+func (m *command) LoadTenant(dispatcher dispatcher.Dispatcher) {
+	log := m.log.Named("tenant")
+	defer log.Sugar().Info("Loaded")
+	dispatcher.AddHandler(handlers.NewCommand("tenant", tenantCommand))
+}
+
+// tenantCommand lets bot admins manage the tenants
+func tenantCommand(ctx *ext.Context, u *ext.Update) error {
+	chatId := u.EffectiveChat().GetID()
+	peerChatId := ctx.PeerStorage.GetPeerById(chatId)
+	if peerChatId.Type != int(storage.TypeUser) {
+		return dispatcher.EndGroups
+	}
+	if !utils.IsAdmin(ctx, ctx.Raw, ctx.PeerStorage, chatId) {
+		ctx.Reply(u, "This command is only available to admins.", nil)
+		return dispatcher.EndGroups
+	}
+	tenantRepository := database.GetTenantRepository()
+	if tenantRepository == nil {
+		ctx.Reply(u, "❌ Tenant database is not available at the moment.", nil)
+		return dispatcher.EndGroups
+	}
+	args := u.Args()
+	if len(args) < 2 {
+		ctx.Reply(u, tenantUsage, nil)
+		return dispatcher.EndGroups
+	}
+	var message string
+	var err error
+	switch {
+	case args[1] == "add" && len(args) >= 5:
+		message, err = addTenant(tenantRepository, ctx.Self.Username, args[2], args[3], strings.Join(args[4:], " "))
+	case args[1] == "list":
+		message, err = listTenants(tenantRepository, ctx.Self.Username)
+	case args[1] == "admin" && len(args) == 4:
+		message, err = updateTenant(tenantRepository, args[2], args[3], func(t *types.Tenant, userID int64) {
+			if !t.IsAdmin(userID) {
+				t.Admins = strings.Trim(t.Admins+","+strconv.FormatInt(userID, 10), ",")
+			}
+		})
+	case args[1] == "unadmin" && len(args) == 4:
+		id, value := args[2], args[3]
+		confirm(ctx, u, fmt.Sprintf("Remove user %s from the admins of tenant %s?", value, id), func(ctx *ext.Context) string {
+			message, err := updateTenant(tenantRepository, id, value, func(t *types.Tenant, userID int64) {
+				var admins []string
+				for _, admin := range strings.Split(t.Admins, ",") {
+					if admin != "" && admin != strconv.FormatInt(userID, 10) {
+						admins = append(admins, admin)
+					}
+				}
+				t.Admins = strings.Join(admins, ",")
+			})
+			if err != nil {
+				utils.Logger.Error("Failed to manage tenants", zap.Error(err))
+				return fmt.Sprintf("Error - %s", err.Error())
+			}
+			return message
+		})
+		return dispatcher.EndGroups
+	case args[1] == "quota" && len(args) == 4:
+		message, err = updateTenant(tenantRepository, args[2], args[3], func(t *types.Tenant, quota int64) {
+			t.DailyLinkQuota = int(quota)
+		})
+	default:
+		message = tenantUsage
+	}
+	if err != nil {
+		utils.Logger.Error("Failed to manage tenants", zap.Error(err))
+		ctx.Reply(u, fmt.Sprintf("Error - %s", err.Error()), nil)
+		return dispatcher.EndGroups
+	}
+	ctx.Reply(u, message, nil)
+	return dispatcher.EndGroups
+}
+
+func addTenant(repository *database.TenantRepository, botUsername string, path string, logChannel string, name string) (string, error) {
+	path = tenant.NormalizePath(path)
+	if path == "" || strings.Contains(path[1:], "/") {
+		return "The path must be a single non-empty path segment, eg. team-a", nil
+	}
+	if tenant.IsReserved(path) {
+		return fmt.Sprintf("%s is used by the bot's own pages, please choose another path.", path), nil
+	}
+	channelID, err := strconv.ParseInt(strings.TrimPrefix(logChannel, "-100"), 10, 64)
+	if err != nil {
+		return "Invalid log channel ID.", nil
+	}
+	code := make([]byte, 8)
+	if _, err := rand.Read(code); err != nil {
+		return "", err
+	}
+	t := &types.Tenant{
+		Name:         name,
+		Path:         path,
+		InviteCode:   hex.EncodeToString(code),
+		LogChannelID: channelID,
+	}
+	if err := repository.Create(t); err != nil {
+		return "", err
+	}
+	if err := tenant.Reload(); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("✅ Created tenant %d\n\n%s", t.ID, formatTenant(t, botUsername)), nil
+}
+
+func listTenants(repository *database.TenantRepository, botUsername string) (string, error) {
+	tenants, err := repository.List()
+	if err != nil {
+		return "", err
+	}
+	if len(tenants) == 0 {
+		return "No tenants yet, create one with /tenant add", nil
+	}
+	var sb strings.Builder
+	sb.WriteString("🏢 Tenants\n")
+	for i := range tenants {
+		sb.WriteString(fmt.Sprintf("\n[%d] %s", tenants[i].ID, formatTenant(&tenants[i], botUsername)))
+	}
+	return sb.String(), nil
+}
+
+// updateTenant applies change to the tenant with the given ID and saves it
+func updateTenant(repository *database.TenantRepository, id string, value string, change func(*types.Tenant, int64)) (string, error) {
+	tenantID, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		return "Invalid tenant ID.", nil
+	}
+	number, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || number < 0 {
+		return "Invalid number.", nil
+	}
+	current := tenant.Get(uint(tenantID))
+	if current == nil {
+		return "Tenant not found.", nil
+	}
+	t := *current
+	change(&t, number)
+	if err := repository.Update(&t); err != nil {
+		return "", err
+	}
+	if err := tenant.Reload(); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("✅ Updated tenant %d\n\n%s", t.ID, formatTenant(&t, "")), nil
+}
+
+func formatTenant(t *types.Tenant, botUsername string) string {
+	quota := "unlimited"
+	if t.DailyLinkQuota > 0 {
+		quota = fmt.Sprintf("%d links per user a day", t.DailyLinkQuota)
+	}
+	message := fmt.Sprintf("%s\nURL: %s\nLog channel: %d\nAdmins: %s\nQuota: %s",
+		t.Name, utils.TenantURL(t.ID, "/"), t.LogChannelID, t.Admins, quota)
+	if botUsername != "" {
+		message += fmt.Sprintf("\nInvite: https://t.me/%s?start=%s", botUsername, t.InviteCode)
+	}
+	return message + "\n"
+}
+
+// joinTenant moves the user to the tenant with the given invite code.
+// It returns false if the code doesn't belong to a tenant.
+func joinTenant(ctx *ext.Context, u *ext.Update, userID int64, code string) bool {
+	tenantRepository := database.GetTenantRepository()
+	userRepository := database.GetUserRepository().WithContext(ctx)
+	if tenantRepository == nil || userRepository == nil {
+		return false
+	}
+	t, err := tenantRepository.GetByInvite(code)
+	if err != nil {
+		utils.Logger.Error("Failed to look up invite code", zap.Error(err))
+		return false
+	}
+	if t == nil {
+		return false
+	}
+	if err := userRepository.SetTenant(userID, t.ID); err != nil {
+		utils.Logger.Error("Failed to join tenant", zap.Error(err), zap.Int64("userID", userID))
+		ctx.Reply(u, fmt.Sprintf("Error - %s", err.Error()), nil)
+		return true
+	}
+	ctx.Reply(u, fmt.Sprintf("✅ You joined %s. Send me a file to get a link.", t.Name), nil)
+	beginOnboarding(ctx, userID)
+	return true
+}
+
+// adminTenant returns the tenant the user administers, or nil if they administer none
+func adminTenant(userID int64) *types.Tenant {
+	if t := access.Tenant(userID); t != nil && t.IsAdmin(userID) {
+		return t
+	}
+	return nil
+}
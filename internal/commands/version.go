@@ -0,0 +1,44 @@
+package commands
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/updates"
+	"EverythingSuckz/fsb/internal/version"
+	"fmt"
+	"strings"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/dispatcher/handlers"
+	"github.com/celestix/gotgproto/ext"
+)
+
+func (m *command) LoadVersion(dispatcher dispatcher.Dispatcher) {
+	log := m.log.Named("version")
+	defer log.Sugar().Info("Loaded")
+	dispatcher.AddHandler(handlers.NewCommand("version", versionCommand))
+}
+
+// versionCommand reports the build of the bot and, if UPDATE_CHECK is enabled, the latest release
+func versionCommand(ctx *ext.Context, u *ext.Update) error {
+	if _, ok := settingsAdmin(ctx, u); !ok {
+		return dispatcher.EndGroups
+	}
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("🤖 %s %s\n\n", config.ValueOf.AppName, version.Version))
+	sb.WriteString(fmt.Sprintf("Commit: %s\n", version.BuildCommit()))
+	sb.WriteString(fmt.Sprintf("Go: %s\n", version.GoVersion()))
+	sb.WriteString(fmt.Sprintf("Uptime: %s", formatWait(version.Uptime())))
+	if config.ValueOf.UpdateCheck {
+		release, checked := updates.Latest()
+		switch {
+		case release == nil:
+			sb.WriteString("\n\nThe release feed wasn't checked yet.")
+		case release.Newer():
+			sb.WriteString(fmt.Sprintf("\n\n🆕 %s is available:\n%s", release.Tag, release.URL))
+		default:
+			sb.WriteString(fmt.Sprintf("\n\n✅ Up to date, checked %s.", checked.Format("2006-01-02 15:04")))
+		}
+	}
+	ctx.Reply(u, sb.String(), nil)
+	return dispatcher.EndGroups
+}
@@ -0,0 +1,132 @@
+package commands
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/speech"
+	"EverythingSuckz/fsb/internal/utils"
+	"fmt"
+	"strings"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/dispatcher/handlers"
+	"github.com/celestix/gotgproto/dispatcher/handlers/filters"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/celestix/gotgproto/storage"
+	"github.com/gotd/td/tg"
+	"go.uber.org/zap"
+)
+
+// voiceGroup runs before the stream handler, so that voice commands don't get links
+const voiceGroup = -1
+
+const (
+	// maxVoiceCommandDuration is the longest voice note transcribed as a command, in seconds
+	maxVoiceCommandDuration = 15
+	// maxVoiceCommandSize is the largest voice note transcribed as a command, fetched in one request
+	maxVoiceCommandSize = 1024 * 1024
+)
+
+// voicePhrases map the words of a voice command to a remote control action, the first match wins
+var voicePhrases = []struct {
+	words  []string
+	action string
+	reply  string
+}{
+	{[]string{"last", "latest", "newest"}, "latest", "Playing your latest file"},
+	{[]string{"forward", "ahead"}, "forward", "Skipping 30 seconds"},
+	{[]string{"rewind", "back"}, "back", "Going back 30 seconds"},
+	{[]string{"skip", "next"}, "next", "Skipping to the next file"},
+	{[]string{"pause", "stop"}, "pause", "Pausing"},
+	{[]string{"louder", "up"}, "volume_up", "Turning the volume up"},
+	{[]string{"quieter", "down", "lower"}, "volume_down", "Turning the volume down"},
+	{[]string{"play", "resume", "continue"}, "play", "Playing"},
+}
+
+func (m *command) LoadVoice(dispatcher dispatcher.Dispatcher) {
+	log := m.log.Named("voice")
+	defer log.Sugar().Info("Loaded")
+	dispatcher.AddHandlerToGroup(handlers.NewMessage(filters.Message.Media, voiceCommand), voiceGroup)
+}
+
+// voiceCommand transcribes short voice notes with SPEECH_TO_TEXT_URL and sends the action they
+// ask for to the web players of the sender, like the buttons of /remote. Other media and longer
+// voice notes get links as usual.
+func voiceCommand(ctx *ext.Context, u *ext.Update) error {
+	if !speech.Enabled() || config.ValueOf.PublicMode {
+		return nil
+	}
+	chatId := u.EffectiveChat().GetID()
+	peerChatId := ctx.PeerStorage.GetPeerById(chatId)
+	if peerChatId.Type != int(storage.TypeUser) {
+		return nil
+	}
+	document, ok := voiceNote(u.EffectiveMessage.Media)
+	if !ok {
+		return nil
+	}
+	trackUser(u)
+	if !isAllowed(ctx, chatId) {
+		ctx.Reply(u, "You are not allowed to use this bot.", nil)
+		return dispatcher.EndGroups
+	}
+	res, err := ctx.Raw.UploadGetFile(ctx, &tg.UploadGetFileRequest{
+		Location: document.AsInputDocumentFileLocation(),
+		Limit:    maxVoiceCommandSize,
+	})
+	if err != nil {
+		utils.Logger.Error("Failed to download voice note", zap.Error(err), zap.Int64("userID", chatId))
+		ctx.Reply(u, fmt.Sprintf("Error - %s", err.Error()), nil)
+		return dispatcher.EndGroups
+	}
+	file, ok := res.(*tg.UploadFile)
+	if !ok {
+		ctx.Reply(u, "Error - unexpected file location", nil)
+		return dispatcher.EndGroups
+	}
+	text, err := speech.Transcribe(ctx, "voice.ogg", file.Bytes)
+	if err != nil {
+		utils.Logger.Error("Failed to transcribe voice note", zap.Error(err), zap.Int64("userID", chatId))
+		ctx.Reply(u, "❌ Couldn't understand the voice note, the speech to text service failed.", nil)
+		return dispatcher.EndGroups
+	}
+	action, reply := voiceAction(text)
+	if action == "" {
+		ctx.Reply(u, fmt.Sprintf("🎙 \"%s\"\n\nSay play, pause, skip, back, forward, louder, quieter or play the latest video.", text), nil)
+		return dispatcher.EndGroups
+	}
+	ctx.Reply(u, fmt.Sprintf("🎙 \"%s\"\n\n%s. %s.", text, reply, controlPlayers(chatId, action)), nil)
+	return dispatcher.EndGroups
+}
+
+// voiceNote returns the document of the media if it's a voice note short enough to be a command
+func voiceNote(media tg.MessageMediaClass) (*tg.Document, bool) {
+	mediaDocument, ok := media.(*tg.MessageMediaDocument)
+	if !ok {
+		return nil, false
+	}
+	document, ok := mediaDocument.Document.AsNotEmpty()
+	if !ok || document.Size > maxVoiceCommandSize {
+		return nil, false
+	}
+	for _, attribute := range document.Attributes {
+		if audio, ok := attribute.(*tg.DocumentAttributeAudio); ok {
+			return document, audio.Voice && audio.Duration <= maxVoiceCommandDuration
+		}
+	}
+	return nil, false
+}
+
+// voiceAction returns the remote control action of the transcribed voice command
+func voiceAction(text string) (string, string) {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !('a' <= r && r <= 'z')
+	})
+	for _, phrase := range voicePhrases {
+		for _, word := range words {
+			if utils.Contains(phrase.words, word) {
+				return phrase.action, phrase.reply
+			}
+		}
+	}
+	return "", ""
+}
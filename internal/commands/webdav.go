@@ -0,0 +1,48 @@
+package commands
+
+import (
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/utils"
+	"EverythingSuckz/fsb/internal/webauth"
+	"fmt"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/dispatcher/handlers"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/celestix/gotgproto/storage"
+)
+
+func (m *command) LoadWebDAV(dispatcher dispatcher.Dispatcher) {
+	log := m.log.Named("webdav")
+	defer log.Sugar().Info("Loaded")
+	dispatcher.AddHandler(handlers.NewCommand("webdav", webDAV))
+}
+
+// webDAV sends the address and the credentials of the WebDAV drive with the recent files of the user
+func webDAV(ctx *ext.Context, u *ext.Update) error {
+	chatId := u.EffectiveChat().GetID()
+	peerChatId := ctx.PeerStorage.GetPeerById(chatId)
+	if peerChatId.Type != int(storage.TypeUser) {
+		return dispatcher.EndGroups
+	}
+	trackUser(u)
+	if !isAllowed(ctx, chatId) {
+		ctx.Reply(u, "You are not allowed to use this bot.", nil)
+		return dispatcher.EndGroups
+	}
+	userRepository := database.GetUserRepository().WithContext(ctx)
+	if userRepository == nil {
+		ctx.Reply(u, "❌ User database is not available at the moment.", nil)
+		return dispatcher.EndGroups
+	}
+	version, err := userRepository.TokenVersion(chatId)
+	if err != nil {
+		ctx.Reply(u, fmt.Sprintf("Error - %s", err.Error()), nil)
+		return dispatcher.EndGroups
+	}
+	ctx.Reply(u, fmt.Sprintf("🗂 Add your recent files as a read-only network drive in a file manager or media center:\n\n"+
+		"Address: %s\nUser: %d\nPassword: %s\n\n"+
+		"The password works for %s, or until /revokeall. Don't share it, whoever has it can download all your files.",
+		utils.PublicURL("/dav/"), chatId, webauth.WebDAVPassword(chatId, version), formatWait(webauth.WebDAVTTL)), nil)
+	return dispatcher.EndGroups
+}
@@ -0,0 +1,176 @@
+package commands
+
+import (
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/tenant"
+	"EverythingSuckz/fsb/internal/types"
+	"EverythingSuckz/fsb/internal/utils"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/dispatcher/handlers"
+	"github.com/celestix/gotgproto/ext"
+	"go.uber.org/zap"
+)
+
+func (m *command) LoadWhoseLink(dispatcher dispatcher.Dispatcher) {
+	log := m.log.Named("whoselink")
+	defer log.Sugar().Info("Loaded")
+	dispatcher.AddHandler(handlers.NewCommand("whoselink", whoseLink))
+	dispatcher.AddHandler(handlers.NewCommand("transferlink", transferLink))
+}
+
+// whoseLink shows who generated a stream link and from which file, to investigate leaked links
+func whoseLink(ctx *ext.Context, u *ext.Update) error {
+	_, userRepository, ok := userAdminCommand(ctx, u, "")
+	if !ok {
+		return dispatcher.EndGroups
+	}
+	args := u.Args()
+	if len(args) < 2 {
+		ctx.Reply(u, "Usage: /whoselink <link or hash>", nil)
+		return dispatcher.EndGroups
+	}
+	links, err := findLinks(args[1])
+	if err != nil {
+		ctx.Reply(u, fmt.Sprintf("Error - %s", err.Error()), nil)
+		return dispatcher.EndGroups
+	}
+	var sb strings.Builder
+	for _, link := range links {
+		sb.WriteString(fmt.Sprintf("🔗 Message %d", link.MessageID))
+		if link.TenantID != 0 {
+			sb.WriteString(fmt.Sprintf(" of tenant %d", link.TenantID))
+		}
+		sb.WriteString(fmt.Sprintf("\nFile: %s (%s)\n", link.FileName, utils.FormatFileSizeShort(link.FileSize)))
+		owner := fmt.Sprintf("[%d]", link.UserID)
+		if user, err := userRepository.Get(link.UserID); err == nil && user != nil {
+			owner = formatUser(*user)
+		}
+		sb.WriteString(fmt.Sprintf("Owner: %s\n", owner))
+		sb.WriteString(fmt.Sprintf("Generated: %s\n", link.CreatedAt.Format("2006-01-02 15:04")))
+		sb.WriteString(fmt.Sprintf("Views: %d", link.Views))
+		if link.LastAccess != nil {
+			sb.WriteString(fmt.Sprintf(", last %s", link.LastAccess.Format("2006-01-02 15:04")))
+		}
+		sb.WriteString(fmt.Sprintf("\n%s\n\n", utils.StreamURL(link.TenantID, link.MessageID, link.Hash)))
+	}
+	sb.WriteString("Use /userinfo <user_id> for the owner, /removeuser <user_id> to remove them or /transferlink <link> <user_id> to reassign the link.")
+	ctx.Reply(u, sb.String(), nil)
+	return dispatcher.EndGroups
+}
+
+// transferLink makes another user the owner of a link
+func transferLink(ctx *ext.Context, u *ext.Update) error {
+	_, userRepository, ok := userAdminCommand(ctx, u, "")
+	if !ok {
+		return dispatcher.EndGroups
+	}
+	args := u.Args()
+	if len(args) < 3 {
+		ctx.Reply(u, "Usage: /transferlink <link or hash> <user_id>", nil)
+		return dispatcher.EndGroups
+	}
+	userID, err := strconv.ParseInt(args[2], 10, 64)
+	if err != nil {
+		ctx.Reply(u, "Invalid user ID.", nil)
+		return dispatcher.EndGroups
+	}
+	links, err := findLinks(args[1])
+	if err != nil {
+		ctx.Reply(u, fmt.Sprintf("Error - %s", err.Error()), nil)
+		return dispatcher.EndGroups
+	}
+	if len(links) > 1 {
+		ctx.Reply(u, "This hash belongs to several links, use the full link instead.", nil)
+		return dispatcher.EndGroups
+	}
+	link := links[0]
+	if link.UserID == userID {
+		ctx.Reply(u, fmt.Sprintf("User %d already owns this link.", userID), nil)
+		return dispatcher.EndGroups
+	}
+	if user, err := userRepository.Get(userID); err != nil || user == nil {
+		ctx.Reply(u, "User not found.", nil)
+		return dispatcher.EndGroups
+	}
+	linkRepository := database.GetLinkRepository()
+	if linkRepository == nil {
+		ctx.Reply(u, "❌ Link database is not available at the moment.", nil)
+		return dispatcher.EndGroups
+	}
+	if err := linkRepository.Transfer(link.TenantID, link.MessageID, userID); err != nil {
+		utils.Logger.Error("Failed to transfer link", zap.Error(err), zap.Int("messageID", link.MessageID))
+		ctx.Reply(u, fmt.Sprintf("Error - %s", err.Error()), nil)
+		return dispatcher.EndGroups
+	}
+	utils.Logger.Info("Transferred link",
+		zap.Int64("adminID", u.EffectiveChat().GetID()),
+		zap.Int("messageID", link.MessageID),
+		zap.Int64("from", link.UserID),
+		zap.Int64("to", userID))
+	ctx.Reply(u, fmt.Sprintf("✅ Transferred the link of %s from %d to %d.", link.FileName, link.UserID, userID), nil)
+	return dispatcher.EndGroups
+}
+
+// findLinks returns the links a stream link, a short link or a hash refers to
+func findLinks(ref string) ([]types.Link, error) {
+	linkRepository := database.GetLinkRepository()
+	if linkRepository == nil {
+		return nil, errors.New("link database is not available at the moment")
+	}
+	if !strings.Contains(ref, "/") {
+		links, err := linkRepository.FindByHash(ref, 10)
+		if err == nil && len(links) == 0 {
+			err = errors.New("no link with this hash")
+		}
+		return links, err
+	}
+	parsed, err := url.Parse(ref)
+	if err != nil {
+		return nil, errors.New("invalid link")
+	}
+	tenantID, path := tenant.Resolve(parsed.Path)
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i, segment := range segments {
+		if segment == "s" && i+1 < len(segments) {
+			return findShortLink(segments[i+1])
+		}
+		messageID, err := strconv.Atoi(segment)
+		if err != nil {
+			continue
+		}
+		link, err := linkRepository.Get(tenantID, messageID)
+		if err != nil {
+			return nil, errors.New("no link was generated for this message")
+		}
+		return []types.Link{*link}, nil
+	}
+	if hash := parsed.Query().Get("hash"); hash != "" {
+		return findLinks(hash)
+	}
+	return nil, errors.New("this isn't a stream link")
+}
+
+func findShortLink(alias string) ([]types.Link, error) {
+	shortLinkRepository := database.GetShortLinkRepository()
+	if shortLinkRepository == nil {
+		return nil, errors.New("short link database is not available at the moment")
+	}
+	shortLink, err := shortLinkRepository.Get(alias)
+	if err != nil {
+		return nil, err
+	}
+	if shortLink == nil {
+		return nil, errors.New("no short link with this alias")
+	}
+	link, err := database.GetLinkRepository().Get(shortLink.TenantID, shortLink.MessageID)
+	if err != nil {
+		return nil, errors.New("the link of this short link was deleted")
+	}
+	return []types.Link{*link}, nil
+}
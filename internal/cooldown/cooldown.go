@@ -0,0 +1,175 @@
+// Package cooldown limits how often a user can run a command. The uses are stored in the
+// database, so restarting the bot doesn't reset the cooldowns.
+package cooldown
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/crash"
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/utils"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// pruneInterval is how often the uses that no longer count against any cooldown are deleted
+const pruneInterval = time.Hour
+
+// Rule allows Count uses of Command per Period
+type Rule struct {
+	Command string
+	Count   int
+	Period  time.Duration
+}
+
+// Usage is how often a user ran a command within the period of its cooldown
+type Usage struct {
+	Rule   Rule
+	UserID int64
+	Uses   int
+	Wait   time.Duration // until the user can run the command again, 0 if they can now
+}
+
+var rules map[string]Rule
+
+// Load parses COMMAND_COOLDOWNS, whose entries look like broadcast:1/1h or search:10/1m,
+// and starts deleting the uses older than the longest period
+func Load(log *zap.Logger) {
+	log = log.Named("cooldown")
+	rules = make(map[string]Rule)
+	var longest time.Duration
+	for _, entry := range config.ValueOf.CommandCooldowns {
+		command, limit, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		count, period, ok2 := strings.Cut(limit, "/")
+		command = strings.ToLower(strings.TrimPrefix(command, "/"))
+		if !ok || !ok2 || command == "" {
+			log.Sugar().Warnf("Ignoring invalid cooldown %q, use command:count/period", entry)
+			continue
+		}
+		n, err := strconv.Atoi(count)
+		if err != nil || n <= 0 {
+			log.Sugar().Warnf("Ignoring cooldown %q: invalid count", entry)
+			continue
+		}
+		duration, err := utils.ParsePeriod(period)
+		if err != nil {
+			log.Sugar().Warnf("Ignoring cooldown %q: %s", entry, err)
+			continue
+		}
+		rules[command] = Rule{Command: command, Count: n, Period: duration}
+		longest = max(longest, duration)
+	}
+	if len(rules) == 0 {
+		return
+	}
+	log.Sugar().Infof("Loaded %d command cooldowns", len(rules))
+	go prune(log, longest)
+}
+
+// Rules returns the cooldowns sorted by command
+func Rules() []Rule {
+	list := make([]Rule, 0, len(rules))
+	for _, rule := range rules {
+		list = append(list, rule)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Command < list[j].Command })
+	return list
+}
+
+// Check records a use of the command by the user. If the user is still cooling down,
+// nothing is recorded and the time until they can run the command again is returned.
+func Check(userID int64, command string) (time.Duration, error) {
+	rule, ok := rules[command]
+	cooldownRepository := database.GetCooldownRepository()
+	if !ok || cooldownRepository == nil {
+		return 0, nil
+	}
+	wait, _, err := status(cooldownRepository, rule, userID)
+	if err != nil || wait > 0 {
+		return wait, err
+	}
+	return 0, cooldownRepository.Record(userID, command)
+}
+
+// ListBlocked returns the users who are cooling down for the command
+func ListBlocked(rule Rule) ([]Usage, error) {
+	cooldownRepository := database.GetCooldownRepository()
+	if cooldownRepository == nil {
+		return nil, nil
+	}
+	usages, err := cooldownRepository.HeavyUsers(rule.Command, time.Now().Add(-rule.Period), rule.Count)
+	if err != nil {
+		return nil, err
+	}
+	blocked := make([]Usage, 0, len(usages))
+	for _, usage := range usages {
+		wait, uses, err := status(cooldownRepository, rule, usage.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if wait > 0 {
+			blocked = append(blocked, Usage{Rule: rule, UserID: usage.UserID, Uses: uses, Wait: wait})
+		}
+	}
+	return blocked, nil
+}
+
+// UserStatus returns the uses of every command with a cooldown by the user within its period
+func UserStatus(userID int64) ([]Usage, error) {
+	cooldownRepository := database.GetCooldownRepository()
+	if cooldownRepository == nil {
+		return nil, nil
+	}
+	var usages []Usage
+	for _, rule := range Rules() {
+		wait, uses, err := status(cooldownRepository, rule, userID)
+		if err != nil {
+			return nil, err
+		}
+		usages = append(usages, Usage{Rule: rule, UserID: userID, Uses: uses, Wait: wait})
+	}
+	return usages, nil
+}
+
+// Reset lifts all cooldowns of the user
+func Reset(userID int64) error {
+	cooldownRepository := database.GetCooldownRepository()
+	if cooldownRepository == nil {
+		return nil
+	}
+	return cooldownRepository.Reset(userID)
+}
+
+// status returns how long the user has to wait before running the command again
+// and how many times they ran it within the period
+func status(cooldownRepository *database.CooldownRepository, rule Rule, userID int64) (time.Duration, int, error) {
+	now := time.Now()
+	uses, err := cooldownRepository.UsesSince(userID, rule.Command, now.Add(-rule.Period))
+	if err != nil || len(uses) < rule.Count {
+		return 0, len(uses), err
+	}
+	// the user can run the command again once the oldest of the last Count uses leaves the period
+	return uses[len(uses)-rule.Count].Add(rule.Period).Sub(now), len(uses), nil
+}
+
+func prune(log *zap.Logger, longest time.Duration) {
+	ticker := time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		pruneUses(log, longest)
+	}
+}
+
+func pruneUses(log *zap.Logger, longest time.Duration) {
+	defer crash.Recover("cooldowns")
+	cooldownRepository := database.GetCooldownRepository()
+	if cooldownRepository == nil {
+		return
+	}
+	if err := cooldownRepository.Prune(time.Now().Add(-longest)); err != nil {
+		log.Error("Failed to prune command uses", zap.Error(err))
+	}
+}
@@ -0,0 +1,128 @@
+// Package crash recovers panics of the bot handlers, the web server and background goroutines.
+// Every panic is logged with its stack trace and counted in the panics expvar, and the owner
+// of the bot gets a summary in Telegram, at most once per reportInterval for every component.
+package crash
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/settings"
+	"EverythingSuckz/fsb/internal/tracing"
+	"expvar"
+	"fmt"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// reportInterval is the minimum time between two reports of panics of the same component
+const reportInterval = 10 * time.Minute
+
+// stackLines is the number of lines of the stack trace sent to the owner
+const stackLines = 16
+
+// Panics counts the recovered panics by component
+var Panics = expvar.NewMap("panics")
+
+// Notifier sends a message to a user in Telegram
+type Notifier func(userID int64, message string) error
+
+var (
+	log      = zap.NewNop()
+	notifier Notifier
+	reports  = make(map[string]*report)
+	mu       sync.Mutex
+)
+
+type report struct {
+	sent       time.Time
+	suppressed int
+}
+
+// Init sets the logger and the notifier that sends the reports to the owner
+func Init(l *zap.Logger, notify Notifier) {
+	mu.Lock()
+	defer mu.Unlock()
+	log = l.Named("crash")
+	notifier = notify
+}
+
+// Recover recovers a panic of the goroutine it's deferred in and reports it
+func Recover(component string) {
+	if r := recover(); r != nil {
+		Report(component, r, string(debug.Stack()))
+	}
+}
+
+// Report logs and counts a recovered panic and tells the owner about it
+func Report(component string, value interface{}, stack string) {
+	Panics.Add(component, 1)
+	tracing.CapturePanic(component, value, stack)
+	mu.Lock()
+	l, notify := log, notifier
+	state, ok := reports[component]
+	if !ok {
+		state = &report{}
+		reports[component] = state
+	}
+	due := time.Since(state.sent) >= reportInterval
+	suppressed := state.suppressed
+	if due {
+		state.sent = time.Now()
+		state.suppressed = 0
+	} else {
+		state.suppressed++
+	}
+	mu.Unlock()
+	l.Error("Recovered from panic",
+		zap.String("component", component),
+		zap.String("panic", fmt.Sprint(value)),
+		zap.String("stack", stack))
+	if !due || notify == nil {
+		return
+	}
+	owner := Owner()
+	if owner == 0 {
+		return
+	}
+	message := fmt.Sprintf("💥 Panic in %s: %v\n\n%s", component, value, trimStack(stack))
+	if suppressed > 0 {
+		message += fmt.Sprintf("\n\n%d more panics of %s since the last report.", suppressed, component)
+	}
+	go func() {
+		if err := notify(owner, message); err != nil {
+			l.Warn("Failed to report panic", zap.Error(err))
+		}
+	}()
+}
+
+// Owner returns the user who claimed the bot with /setup, or the first of ADMINS, or 0 if there are none
+func Owner() int64 {
+	if value, ok := settings.Get(settings.Owner); ok {
+		if id, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return id
+		}
+	}
+	if admins := config.Runtime.Admins(); len(admins) > 0 {
+		return admins[0]
+	}
+	return 0
+}
+
+// trimStack returns the frames of the stack trace below the panic
+func trimStack(stack string) string {
+	lines := strings.Split(strings.TrimSpace(stack), "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, "panic(") && i+2 < len(lines) {
+			lines = lines[i+2:]
+			break
+		}
+	}
+	if len(lines) > stackLines {
+		lines = lines[:stackLines]
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,127 @@
+// Package crypt encrypts sensitive database columns at rest with AES-256-GCM. The keys come
+// from ENCRYPTION_KEYS as id:key pairs, the first one encrypts new values and the others
+// only decrypt values encrypted before a rotation. Encrypted values carry the ID of their
+// key, `fsb rekey` encrypts them all with the current key, so that old keys can be removed.
+package crypt
+
+import (
+	"EverythingSuckz/fsb/config"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// prefix marks encrypted values, values without it are stored in plain text
+const prefix = "enc:"
+
+// ErrUnknownKey is returned for values encrypted with a key that isn't in ENCRYPTION_KEYS anymore
+var ErrUnknownKey = errors.New("the value was encrypted with a key that isn't in ENCRYPTION_KEYS")
+
+var (
+	currentID string
+	keys      = make(map[string]cipher.AEAD)
+)
+
+// Load parses the keys of ENCRYPTION_KEYS. Without keys values are stored in plain text.
+func Load(log *zap.Logger) error {
+	log = log.Named("crypt")
+	for i, entry := range config.ValueOf.EncryptionKeys {
+		id, encoded, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok || id == "" || strings.Contains(id, ":") {
+			return fmt.Errorf("invalid encryption key %d, use id:base64key", i+1)
+		}
+		secret, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(secret) != 32 {
+			return fmt.Errorf("encryption key %s must be 32 bytes encoded with base64, eg. from `openssl rand -base64 32`", id)
+		}
+		block, err := aes.NewCipher(secret)
+		if err != nil {
+			return err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return err
+		}
+		if _, ok := keys[id]; ok {
+			return fmt.Errorf("encryption key %s is listed twice", id)
+		}
+		keys[id] = aead
+		if i == 0 {
+			currentID = id
+		}
+	}
+	if Enabled() {
+		log.Info("Encrypting sensitive columns", zap.String("key", currentID), zap.Int("keys", len(keys)))
+	}
+	return nil
+}
+
+// Enabled reports whether new values are encrypted
+func Enabled() bool {
+	return currentID != ""
+}
+
+// Encrypt returns the value encrypted with the current key as enc:<key id>:<nonce and ciphertext>,
+// or the value itself if encryption isn't enabled
+func Encrypt(value string) (string, error) {
+	if !Enabled() {
+		return value, nil
+	}
+	aead := keys[currentID]
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(value)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(value), []byte(currentID))
+	return prefix + currentID + ":" + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt returns the plain text of a value returned by Encrypt. Values stored before
+// encryption was enabled are returned as they are.
+func Decrypt(value string) (string, error) {
+	rest, ok := strings.CutPrefix(value, prefix)
+	if !ok {
+		return value, nil
+	}
+	id, encoded, ok := strings.Cut(rest, ":")
+	if !ok {
+		return "", errors.New("malformed encrypted value")
+	}
+	aead, ok := keys[id]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownKey, id)
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", errors.New("malformed encrypted value")
+	}
+	plain, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(id))
+	if err != nil {
+		return "", fmt.Errorf("decrypting with key %s: %w", id, err)
+	}
+	return string(plain), nil
+}
+
+// IsCurrent reports whether the value is stored the way Encrypt would store it now:
+// encrypted with the current key, or in plain text when encryption isn't enabled
+func IsCurrent(value string) bool {
+	if !Enabled() {
+		return !strings.HasPrefix(value, prefix)
+	}
+	return strings.HasPrefix(value, prefix+currentID+":")
+}
+
+// Digest returns a hash of the value to look up encrypted columns by, since the same
+// value encrypts differently every time. Only use it for random values like codes.
+func Digest(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,65 @@
+package database
+
+import (
+	"EverythingSuckz/fsb/internal/types"
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// APITokenRepository stores the personal access tokens of the users
+type APITokenRepository struct {
+	db  *gorm.DB
+	log *zap.Logger
+}
+
+var apiTokenRepository *APITokenRepository
+
+// GetAPITokenRepository returns the API token repository, or nil if the database is not initialized
+func GetAPITokenRepository() *APITokenRepository {
+	return apiTokenRepository
+}
+
+// Create stores the token
+func (r *APITokenRepository) Create(token *types.APIToken) error {
+	return r.db.Create(token).Error
+}
+
+// List returns the tokens of the user, oldest first
+func (r *APITokenRepository) List(userID int64) ([]types.APIToken, error) {
+	var tokens []types.APIToken
+	err := r.db.Where("user_id = ?", userID).Order("id").Find(&tokens).Error
+	return tokens, err
+}
+
+// FindByDigest returns the token with the digest, or nil if there's none
+func (r *APITokenRepository) FindByDigest(digest string) (*types.APIToken, error) {
+	var token types.APIToken
+	err := r.db.Where("digest = ?", digest).First(&token).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// Delete deletes the token of the user with the ID, it returns false if they have none
+func (r *APITokenRepository) Delete(userID int64, id uint) (bool, error) {
+	result := r.db.Where("user_id = ? AND id = ?", userID, id).Delete(&types.APIToken{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// DeleteAll deletes the tokens of the user and returns how many there were
+func (r *APITokenRepository) DeleteAll(userID int64) (int64, error) {
+	result := r.db.Where("user_id = ?", userID).Delete(&types.APIToken{})
+	return result.RowsAffected, result.Error
+}
+
+// Touch stores when the token was last used
+func (r *APITokenRepository) Touch(id uint, usedAt time.Time) error {
+	return r.db.Model(&types.APIToken{}).Where("id = ?", id).Update("last_used_at", usedAt).Error
+}
@@ -0,0 +1,151 @@
+package database
+
+import (
+	"EverythingSuckz/fsb/internal/types"
+	"errors"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CollectionRepository stores the collections of the users and the links in them
+type CollectionRepository struct {
+	db  *gorm.DB
+	log *zap.Logger
+}
+
+var collectionRepository *CollectionRepository
+
+// GetCollectionRepository returns the collection repository, or nil if the database is not initialized
+func GetCollectionRepository() *CollectionRepository {
+	return collectionRepository
+}
+
+// Create stores the collection and the collections it's nested in that don't exist yet
+func (r *CollectionRepository) Create(userID int64, names []string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for _, name := range names {
+			err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+				Create(&types.Collection{UserID: userID, Name: name}).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Get returns the collection of the user with the name, or nil if there's none
+func (r *CollectionRepository) Get(userID int64, name string) (*types.Collection, error) {
+	var collection types.Collection
+	err := r.db.Where("user_id = ? AND name = ?", userID, name).First(&collection).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &collection, nil
+}
+
+// GetShared returns the collection shared with the token, or nil if there's none
+func (r *CollectionRepository) GetShared(token string) (*types.Collection, error) {
+	var collection types.Collection
+	err := r.db.Where("share_token = ?", token).First(&collection).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &collection, nil
+}
+
+// List returns the collections of the user by name, so that collections come before the
+// ones nested in them
+func (r *CollectionRepository) List(userID int64) ([]types.Collection, error) {
+	var collections []types.Collection
+	err := r.db.Where("user_id = ?", userID).Order("name").Find(&collections).Error
+	return collections, err
+}
+
+// ListNested returns the collection and the ones nested in it by name
+func (r *CollectionRepository) ListNested(collection *types.Collection) ([]types.Collection, error) {
+	var collections []types.Collection
+	prefix := collection.Name + "/"
+	// compared with SUBSTR, names may contain the wildcards of LIKE
+	err := r.db.Where("user_id = ? AND (name = ? OR SUBSTR(name, 1, ?) = ?)",
+		collection.UserID, collection.Name, utf8.RuneCountInString(prefix), prefix).
+		Order("name").
+		Find(&collections).Error
+	return collections, err
+}
+
+// Delete removes the collection, the ones nested in it and their items. The links stay.
+func (r *CollectionRepository) Delete(collection *types.Collection) error {
+	nested, err := r.ListNested(collection)
+	if err != nil {
+		return err
+	}
+	ids := make([]uint, 0, len(nested))
+	for _, c := range nested {
+		ids = append(ids, c.ID)
+	}
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("collection_id IN ?", ids).Delete(&types.CollectionItem{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id IN ?", ids).Delete(&types.Collection{}).Error
+	})
+}
+
+// SetShareToken shares the collection with the token, or stops sharing it with an empty one
+func (r *CollectionRepository) SetShareToken(id uint, token string) error {
+	return r.db.Model(&types.Collection{}).Where("id = ?", id).Update("share_token", token).Error
+}
+
+// AddLink adds the link to the collection. It returns false if it was in it already.
+func (r *CollectionRepository) AddLink(collectionID uint, tenantID uint, messageID int) (bool, error) {
+	result := r.db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&types.CollectionItem{CollectionID: collectionID, TenantID: tenantID, MessageID: messageID})
+	return result.RowsAffected > 0, result.Error
+}
+
+// RemoveLink removes the link from the collection. It returns false if it wasn't in it.
+func (r *CollectionRepository) RemoveLink(collectionID uint, tenantID uint, messageID int) (bool, error) {
+	result := r.db.Where("collection_id = ? AND tenant_id = ? AND message_id = ?", collectionID, tenantID, messageID).
+		Delete(&types.CollectionItem{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// Links returns the working links of the collection in the order they were added
+func (r *CollectionRepository) Links(collectionID uint, limit int) ([]types.Link, error) {
+	var links []types.Link
+	err := r.db.Model(&types.Link{}).
+		Joins("JOIN collection_items ON collection_items.tenant_id = links.tenant_id AND collection_items.message_id = links.message_id").
+		Where("collection_items.collection_id = ? AND links.revoked_at IS NULL AND links.removed_at IS NULL", collectionID).
+		Order("collection_items.created_at").
+		Limit(limit).
+		Find(&links).Error
+	return links, err
+}
+
+// CountLinks returns the number of links in each of the collections by ID
+func (r *CollectionRepository) CountLinks(collectionIDs []uint) (map[uint]int64, error) {
+	var rows []struct {
+		CollectionID uint
+		Count        int64
+	}
+	err := r.db.Model(&types.CollectionItem{}).
+		Select("collection_id, COUNT(*) AS count").
+		Where("collection_id IN ?", collectionIDs).
+		Group("collection_id").
+		Scan(&rows).Error
+	counts := make(map[uint]int64, len(rows))
+	for _, row := range rows {
+		counts[row.CollectionID] = row.Count
+	}
+	return counts, err
+}
@@ -0,0 +1,63 @@
+package database
+
+import (
+	"EverythingSuckz/fsb/internal/types"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// CooldownRepository stores the command uses that count against cooldowns
+type CooldownRepository struct {
+	db  *gorm.DB
+	log *zap.Logger
+}
+
+var cooldownRepository *CooldownRepository
+
+// GetCooldownRepository returns the cooldown repository, or nil if the database is not initialized
+func GetCooldownRepository() *CooldownRepository {
+	return cooldownRepository
+}
+
+// Record stores a use of the command by the user
+func (r *CooldownRepository) Record(userID int64, command string) error {
+	return r.db.Create(&types.CommandUse{UserID: userID, Command: command}).Error
+}
+
+// UsesSince returns the times the user used the command since the given time, oldest first
+func (r *CooldownRepository) UsesSince(userID int64, command string, since time.Time) ([]time.Time, error) {
+	var uses []types.CommandUse
+	err := r.db.Where("user_id = ? AND command = ? AND created_at >= ?", userID, command, since).
+		Order("created_at").
+		Find(&uses).Error
+	times := make([]time.Time, len(uses))
+	for i, use := range uses {
+		times[i] = use.CreatedAt
+	}
+	return times, err
+}
+
+// HeavyUsers returns the users who used the command at least min times since the given time
+func (r *CooldownRepository) HeavyUsers(command string, since time.Time, min int) ([]types.CommandUsage, error) {
+	var usages []types.CommandUsage
+	err := r.db.Model(&types.CommandUse{}).
+		Select("user_id, COUNT(*) AS uses").
+		Where("command = ? AND created_at >= ?", command, since).
+		Group("user_id").
+		Having("COUNT(*) >= ?", min).
+		Order("uses DESC").
+		Scan(&usages).Error
+	return usages, err
+}
+
+// Reset forgets the command uses of the user, lifting all their cooldowns
+func (r *CooldownRepository) Reset(userID int64) error {
+	return r.db.Where("user_id = ?", userID).Delete(&types.CommandUse{}).Error
+}
+
+// Prune deletes the command uses older than the given time
+func (r *CooldownRepository) Prune(before time.Time) error {
+	return r.db.Where("created_at < ?", before).Delete(&types.CommandUse{}).Error
+}
@@ -0,0 +1,56 @@
+package database
+
+import (
+	"EverythingSuckz/fsb/internal/types"
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DashboardRepository stores the admin accounts of the web dashboard
+type DashboardRepository struct {
+	db  *gorm.DB
+	log *zap.Logger
+}
+
+var dashboardRepository *DashboardRepository
+
+// GetDashboardRepository returns the dashboard repository, or nil if the database is not initialized
+func GetDashboardRepository() *DashboardRepository {
+	return dashboardRepository
+}
+
+// Get returns the account with the username, or nil if there's none
+func (r *DashboardRepository) Get(username string) (*types.DashboardAccount, error) {
+	var account types.DashboardAccount
+	err := r.db.Where("username = ?", username).First(&account).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// Save creates the account, or changes its password if it exists
+func (r *DashboardRepository) Save(account *types.DashboardAccount) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "username"}},
+		DoUpdates: clause.AssignmentColumns([]string{"password_hash"}),
+	}).Create(account).Error
+}
+
+// Delete deletes the account, it returns false if there's none
+func (r *DashboardRepository) Delete(username string) (bool, error) {
+	result := r.db.Where("username = ?", username).Delete(&types.DashboardAccount{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// Touch stores when the account last signed in
+func (r *DashboardRepository) Touch(username string, at time.Time) error {
+	return r.db.Model(&types.DashboardAccount{}).Where("username = ?", username).Update("last_login_at", at).Error
+}
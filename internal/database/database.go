@@ -1,10 +1,12 @@
 package database
 
 import (
+	"EverythingSuckz/fsb/config"
 	"EverythingSuckz/fsb/internal/types"
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/glebarez/sqlite"
 	"go.uber.org/zap"
@@ -17,7 +19,6 @@ var DB *gorm.DB
 // InitDatabase initializes the SQLite database
 func InitDatabase(log *zap.Logger) error {
 	log = log.Named("database")
-	defer log.Sugar().Info("Initialized database")
 
 	// Create data directory if it doesn't exist
 	dataDir := "data"
@@ -25,9 +26,12 @@ func InitDatabase(log *zap.Logger) error {
 		return fmt.Errorf("failed to create data directory: %w", err)
 	}
 
-	// Database file path
-	dbPath := filepath.Join(dataDir, "fsb_stats.db")
+	return Open(log, filepath.Join(dataDir, "fsb_stats.db"))
+}
 
+// Open opens the SQLite database at the path, migrates it and sets up the repositories.
+// InitDatabase opens the one of the bot, tests open their own.
+func Open(log *zap.Logger, dbPath string) error {
 	// Configure GORM logger
 	gormLogger := logger.New(
 		&GormLogWriter{log: log},
@@ -36,24 +40,115 @@ func InitDatabase(log *zap.Logger) error {
 		},
 	)
 
-	// Open database connection
-	db, err := gorm.Open(sqlite.Open(dbPath), &gorm.Config{
+	// Open database connection. Every connection waits for locks held by the others
+	// instead of failing with "database is locked", and WAL lets reads run alongside a write.
+	dsn := fmt.Sprintf("%s?_pragma=busy_timeout(%d)&_pragma=journal_mode(WAL)&_pragma=synchronous(NORMAL)", dbPath, config.ValueOf.DBBusyTimeout)
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{
 		Logger: gormLogger,
 	})
 	if err != nil {
 		return fmt.Errorf("failed to connect to database: %w", err)
 	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	if n := config.ValueOf.DBMaxOpenConns; n > 0 {
+		sqlDB.SetMaxOpenConns(n)
+	}
+	if n := config.ValueOf.DBMaxIdleConns; n > 0 {
+		sqlDB.SetMaxIdleConns(n)
+	}
+	sqlDB.SetConnMaxIdleTime(5 * time.Minute)
+
+	if err := migrateLinkKeys(db); err != nil {
+		return fmt.Errorf("failed to migrate links: %w", err)
+	}
 
 	// Auto migrate tables
-	err = db.AutoMigrate(&types.Stats{})
+	err = db.AutoMigrate(&types.Stats{}, &types.Link{}, &types.LinkAccess{}, &types.User{}, &types.Quarantine{}, &types.PlaybackEvent{}, &types.ShortLink{}, &types.Tenant{}, &types.Payment{}, &types.CommandUse{}, &types.Setting{}, &types.FeatureFlag{}, &types.Schedule{}, &types.UserNote{}, &types.UserTag{}, &types.LinkShare{}, &types.Collection{}, &types.CollectionItem{}, &types.LinkMetadata{}, &types.APIToken{}, &types.OIDCIdentity{}, &types.DashboardAccount{}, &types.Job{}, &types.ProcessedMessage{}, &types.GroupTopic{})
 	if err != nil {
 		return fmt.Errorf("failed to migrate database: %w", err)
 	}
+	// invite codes are encrypted, they're looked up by digest
+	if db.Migrator().HasIndex(&types.Tenant{}, "idx_tenants_invite_code") {
+		if err := db.Migrator().DropIndex(&types.Tenant{}, "idx_tenants_invite_code"); err != nil {
+			return fmt.Errorf("failed to migrate database: %w", err)
+		}
+	}
+	if err := migrateInviteDigests(db); err != nil {
+		return fmt.Errorf("failed to migrate tenants: %w", err)
+	}
+	// replaced by idx_link_access_tenant
+	if db.Migrator().HasIndex(&types.LinkAccess{}, "idx_link_access") {
+		if err := db.Migrator().DropIndex(&types.LinkAccess{}, "idx_link_access"); err != nil {
+			return fmt.Errorf("failed to migrate database: %w", err)
+		}
+	}
+
+	// accesses recorded before repeat visits were tracked were last seen when created
+	if err := db.Model(&types.LinkAccess{}).
+		Where("accessed_at IS NULL OR accessed_at < created_at").
+		Update("accessed_at", gorm.Expr("created_at")).Error; err != nil {
+		return fmt.Errorf("failed to migrate link accesses: %w", err)
+	}
 
 	DB = db
+	initRepositories(log)
+	log.Sugar().Info("Initialized database")
 	return nil
 }
 
+// initRepositories sets up the repositories backed by the database
+func initRepositories(log *zap.Logger) {
+	linkRepository = &LinkRepository{db: DB, log: log.Named("links")}
+	// the users are read on almost every update, their statements are prepared once per connection
+	userRepository = &UserRepository{db: DB.Session(&gorm.Session{PrepareStmt: true}), log: log.Named("users")}
+	quarantineRepository = &QuarantineRepository{db: DB, log: log.Named("quarantine")}
+	telemetryRepository = &TelemetryRepository{db: DB, log: log.Named("telemetry")}
+	shortLinkRepository = &ShortLinkRepository{db: DB, log: log.Named("shortlinks")}
+	tenantRepository = &TenantRepository{db: DB, log: log.Named("tenants")}
+	paymentRepository = &PaymentRepository{db: DB, log: log.Named("payments")}
+	cooldownRepository = &CooldownRepository{db: DB, log: log.Named("cooldowns")}
+	settingRepository = &SettingRepository{db: DB, log: log.Named("settings")}
+	featureFlagRepository = &FeatureFlagRepository{db: DB, log: log.Named("features")}
+	scheduleRepository = &ScheduleRepository{db: DB, log: log.Named("schedules")}
+	noteRepository = &NoteRepository{db: DB, log: log.Named("notes")}
+	collectionRepository = &CollectionRepository{db: DB, log: log.Named("collections")}
+	metadataRepository = &MetadataRepository{db: DB, log: log.Named("metadata")}
+	apiTokenRepository = &APITokenRepository{db: DB, log: log.Named("apitokens")}
+	oidcRepository = &OIDCRepository{db: DB, log: log.Named("oidc")}
+	dashboardRepository = &DashboardRepository{db: DB, log: log.Named("dashboard")}
+	jobRepository = &JobRepository{db: DB, log: log.Named("jobs")}
+	processedRepository = &ProcessedRepository{db: DB, log: log.Named("processed")}
+	groupTopicRepository = &GroupTopicRepository{db: DB, log: log.Named("grouptopics")}
+}
+
+// migrateLinkKeys rebuilds the links table of databases created before tenants existed,
+// since sqlite can't change the primary key of a table to include the tenant ID
+func migrateLinkKeys(db *gorm.DB) error {
+	migrator := db.Migrator()
+	if !migrator.HasTable(&types.Link{}) || migrator.HasColumn(&types.Link{}, "tenant_id") {
+		return nil
+	}
+	columns := "message_id, hash, user_id, reply_id, source_id, file_name, file_size, mime_type, views, edited_views, last_access, created_at, updated_at"
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("CREATE TABLE links_legacy AS SELECT * FROM links").Error; err != nil {
+			return err
+		}
+		if err := tx.Migrator().DropTable(&types.Link{}); err != nil {
+			return err
+		}
+		if err := tx.AutoMigrate(&types.Link{}); err != nil {
+			return err
+		}
+		if err := tx.Exec("INSERT INTO links (" + columns + ") SELECT " + columns + " FROM links_legacy").Error; err != nil {
+			return err
+		}
+		return tx.Migrator().DropTable("links_legacy")
+	})
+}
+
 // GetDB returns the database instance
 func GetDB() *gorm.DB {
 	return DB
@@ -0,0 +1,22 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// openTest opens a new database in the temporary directory of the test. The repositories are
+// shared by the package, so the tests using it don't run in parallel.
+func openTest(t *testing.T) {
+	t.Helper()
+	if err := Open(zap.NewNop(), filepath.Join(t.TempDir(), "fsb_test.db")); err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := DB.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+}
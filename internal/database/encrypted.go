@@ -0,0 +1,107 @@
+package database
+
+import (
+	"EverythingSuckz/fsb/internal/crypt"
+	"EverythingSuckz/fsb/internal/types"
+	"context"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// encryptedSerializer encrypts string columns tagged with serializer:encrypted when they're
+// written and decrypts them when they're read, see the crypt package
+type encryptedSerializer struct{}
+
+func init() {
+	schema.RegisterSerializer("encrypted", encryptedSerializer{})
+}
+
+func (encryptedSerializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+	var value string
+	switch v := dbValue.(type) {
+	case nil:
+	case string:
+		value = v
+	case []byte:
+		value = string(v)
+	default:
+		return fmt.Errorf("unexpected %T in encrypted column %s", dbValue, field.DBName)
+	}
+	plain, err := crypt.Decrypt(value)
+	if err != nil {
+		return fmt.Errorf("column %s: %w", field.DBName, err)
+	}
+	return field.Set(ctx, dst, plain)
+}
+
+func (encryptedSerializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue interface{}) (interface{}, error) {
+	value, ok := fieldValue.(string)
+	if !ok {
+		return nil, fmt.Errorf("encrypted column %s must be a string, not %T", field.DBName, fieldValue)
+	}
+	return crypt.Encrypt(value)
+}
+
+// encryptedColumns are the columns of the models tagged with serializer:encrypted, by table
+var encryptedColumns = []struct {
+	table  string
+	column string
+}{
+	{"tenants", "invite_code"},
+	{"user_notes", "text"},
+}
+
+// Rekey encrypts the values of the encrypted columns that aren't encrypted with the current
+// key of ENCRYPTION_KEYS yet, including those stored before encryption was enabled. It
+// returns the number of values it changed.
+func Rekey() (int, error) {
+	changed := 0
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		for _, c := range encryptedColumns {
+			var rows []struct {
+				ID    uint
+				Value string
+			}
+			if err := tx.Table(c.table).Select("id, " + c.column + " AS value").Scan(&rows).Error; err != nil {
+				return err
+			}
+			for _, row := range rows {
+				if crypt.IsCurrent(row.Value) {
+					continue
+				}
+				plain, err := crypt.Decrypt(row.Value)
+				if err != nil {
+					return fmt.Errorf("%s %d: %w", c.table, row.ID, err)
+				}
+				value, err := crypt.Encrypt(plain)
+				if err != nil {
+					return err
+				}
+				if err := tx.Table(c.table).Where("id = ?", row.ID).UpdateColumn(c.column, value).Error; err != nil {
+					return err
+				}
+				changed++
+			}
+		}
+		return nil
+	})
+	return changed, err
+}
+
+// migrateInviteDigests stores the digests of the invite codes of tenants created
+// before invite codes were looked up by digest
+func migrateInviteDigests(db *gorm.DB) error {
+	var tenants []types.Tenant
+	if err := db.Where("invite_digest = ? OR invite_digest IS NULL", "").Find(&tenants).Error; err != nil {
+		return err
+	}
+	for _, t := range tenants {
+		if err := db.Model(&t).UpdateColumn("invite_digest", crypt.Digest(t.InviteCode)).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package database
+
+import (
+	"EverythingSuckz/fsb/internal/types"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// FeatureFlagRepository stores the feature flags changed with /features
+type FeatureFlagRepository struct {
+	db  *gorm.DB
+	log *zap.Logger
+}
+
+var featureFlagRepository *FeatureFlagRepository
+
+// GetFeatureFlagRepository returns the feature flag repository, or nil if the database is not initialized
+func GetFeatureFlagRepository() *FeatureFlagRepository {
+	return featureFlagRepository
+}
+
+// List returns all stored feature flags
+func (r *FeatureFlagRepository) List() ([]types.FeatureFlag, error) {
+	var flags []types.FeatureFlag
+	err := r.db.Order("name").Find(&flags).Error
+	return flags, err
+}
+
+// Set stores the state of the feature flag
+func (r *FeatureFlagRepository) Set(name string, state string, updatedBy int64) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"state", "updated_by", "updated_at"}),
+	}).Create(&types.FeatureFlag{Name: name, State: state, UpdatedBy: updatedBy}).Error
+}
+
+// Delete removes the feature flag, so FEATURES applies again
+func (r *FeatureFlagRepository) Delete(name string) error {
+	return r.db.Where("name = ?", name).Delete(&types.FeatureFlag{}).Error
+}
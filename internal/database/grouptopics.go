@@ -0,0 +1,57 @@
+package database
+
+import (
+	"EverythingSuckz/fsb/internal/types"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GroupTopicRepository stores the forum topics the bot is restricted to in groups
+type GroupTopicRepository struct {
+	db  *gorm.DB
+	log *zap.Logger
+}
+
+var groupTopicRepository *GroupTopicRepository
+
+// GetGroupTopicRepository returns the group topic repository, or nil if the database is not initialized
+func GetGroupTopicRepository() *GroupTopicRepository {
+	return groupTopicRepository
+}
+
+// Enable adds the topic to the topics the bot generates links in
+func (r *GroupTopicRepository) Enable(chatID int64, topicID int, userID int64) error {
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&types.GroupTopic{ChatID: chatID, TopicID: topicID, EnabledBy: userID}).Error
+}
+
+// Disable removes the topic from the enabled topics, it returns the number of topics left
+func (r *GroupTopicRepository) Disable(chatID int64, topicID int) (int64, error) {
+	if err := r.db.Where("chat_id = ? AND topic_id = ?", chatID, topicID).Delete(&types.GroupTopic{}).Error; err != nil {
+		return 0, err
+	}
+	var count int64
+	err := r.db.Model(&types.GroupTopic{}).Where("chat_id = ?", chatID).Count(&count).Error
+	return count, err
+}
+
+// Enabled reports whether the bot generates links in the topic, which it does in every topic
+// of groups that didn't enable any
+func (r *GroupTopicRepository) Enabled(chatID int64, topicID int) (bool, error) {
+	var topics []int
+	err := r.db.Model(&types.GroupTopic{}).Where("chat_id = ?", chatID).Pluck("topic_id", &topics).Error
+	if err != nil {
+		return false, err
+	}
+	if len(topics) == 0 {
+		return true, nil
+	}
+	for _, topic := range topics {
+		if topic == topicID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,33 @@
+package database
+
+import "testing"
+
+func TestGroupTopicsRestrictTheGroupOnceEnabled(t *testing.T) {
+	openTest(t)
+	topics := GetGroupTopicRepository()
+	enabled := func(chatID int64, topicID int) bool {
+		t.Helper()
+		ok, err := topics.Enabled(chatID, topicID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ok
+	}
+	if !enabled(2000, 5) {
+		t.Error("a topic of a group without enabled topics is disabled")
+	}
+	for i := 0; i < 2; i++ {
+		if err := topics.Enable(2000, 9, 1); err != nil {
+			t.Fatalf("enabling the topic again failed: %v", err)
+		}
+	}
+	if enabled(2000, 5) || !enabled(2000, 9) || !enabled(3000, 5) {
+		t.Error("enabling a topic didn't restrict only its group to it")
+	}
+	if left, err := topics.Disable(2000, 9); err != nil || left != 0 {
+		t.Fatalf("disabling the topic left %d topics: %v", left, err)
+	}
+	if !enabled(2000, 5) {
+		t.Error("the group is still restricted after disabling its last topic")
+	}
+}
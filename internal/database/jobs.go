@@ -0,0 +1,149 @@
+package database
+
+import (
+	"EverythingSuckz/fsb/internal/types"
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// JobRepository stores the background jobs
+type JobRepository struct {
+	db  *gorm.DB
+	log *zap.Logger
+}
+
+var jobRepository *JobRepository
+
+// GetJobRepository returns the job repository, or nil if the database is not initialized
+func GetJobRepository() *JobRepository {
+	return jobRepository
+}
+
+// Create queues a new job
+func (r *JobRepository) Create(job *types.Job) error {
+	return r.db.Create(job).Error
+}
+
+// Get returns the job with the ID, or nil if there's none
+func (r *JobRepository) Get(id uint) (*types.Job, error) {
+	var job types.Job
+	err := r.db.Where("id = ?", id).First(&job).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// Claim marks the next due job of the kind running and returns it, or nil if no job is due.
+// A job is only claimed by one worker, the others move on to the next one.
+func (r *JobRepository) Claim(kind string, now time.Time) (*types.Job, error) {
+	for {
+		var job types.Job
+		err := r.db.Where("kind = ? AND state = ? AND run_at <= ?", kind, types.JobQueued, now).Order("run_at, id").First(&job).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		result := r.db.Model(&types.Job{}).Where("id = ? AND state = ?", job.ID, types.JobQueued).Updates(map[string]interface{}{
+			"state":    types.JobRunning,
+			"attempts": gorm.Expr("attempts + 1"),
+		})
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected == 1 {
+			job.State = types.JobRunning
+			job.Attempts++
+			return &job, nil
+		}
+	}
+}
+
+// Finish marks the job done
+func (r *JobRepository) Finish(id uint, now time.Time) error {
+	return r.db.Model(&types.Job{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"state":       types.JobDone,
+		"last_error":  "",
+		"finished_at": now,
+	}).Error
+}
+
+// Retry queues the failed job again, to run at runAt
+func (r *JobRepository) Retry(id uint, message string, runAt time.Time) error {
+	return r.db.Model(&types.Job{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"state":      types.JobQueued,
+		"last_error": message,
+		"run_at":     runAt,
+	}).Error
+}
+
+// Bury marks the job dead after it failed its last attempt
+func (r *JobRepository) Bury(id uint, message string, now time.Time) error {
+	return r.db.Model(&types.Job{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"state":       types.JobDead,
+		"last_error":  message,
+		"finished_at": now,
+	}).Error
+}
+
+// Requeue queues a dead job again with its attempts reset and reports whether there was one
+func (r *JobRepository) Requeue(id uint, now time.Time) (bool, error) {
+	result := r.db.Model(&types.Job{}).Where("id = ? AND state = ?", id, types.JobDead).Updates(map[string]interface{}{
+		"state":       types.JobQueued,
+		"attempts":    0,
+		"run_at":      now,
+		"finished_at": nil,
+	})
+	return result.RowsAffected > 0, result.Error
+}
+
+// Delete removes a dead job and reports whether there was one
+func (r *JobRepository) Delete(id uint) (bool, error) {
+	result := r.db.Where("id = ? AND state = ?", id, types.JobDead).Delete(&types.Job{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// RecoverRunning queues the jobs that were running when the bot stopped again, their
+// attempt doesn't count
+func (r *JobRepository) RecoverRunning() (int64, error) {
+	result := r.db.Model(&types.Job{}).Where("state = ?", types.JobRunning).Updates(map[string]interface{}{
+		"state":    types.JobQueued,
+		"attempts": gorm.Expr("MAX(attempts - 1, 0)"),
+	})
+	return result.RowsAffected, result.Error
+}
+
+// ListDead returns the dead jobs, the last to fail first
+func (r *JobRepository) ListDead(limit int) ([]types.Job, error) {
+	var jobs []types.Job
+	err := r.db.Where("state = ?", types.JobDead).Order("finished_at DESC").Limit(limit).Find(&jobs).Error
+	return jobs, err
+}
+
+// Counts returns the number of jobs by kind and state
+func (r *JobRepository) Counts() ([]types.JobCount, error) {
+	var counts []types.JobCount
+	err := r.db.Model(&types.Job{}).Select("kind, state, COUNT(*) AS count").Group("kind, state").Order("kind, state").Scan(&counts).Error
+	return counts, err
+}
+
+// CountWaiting returns the number of queued jobs of the kind
+func (r *JobRepository) CountWaiting(kind string) (int64, error) {
+	var count int64
+	err := r.db.Model(&types.Job{}).Where("kind = ? AND state = ?", kind, types.JobQueued).Count(&count).Error
+	return count, err
+}
+
+// PurgeDone removes the jobs that were done before the time
+func (r *JobRepository) PurgeDone(before time.Time) (int64, error) {
+	result := r.db.Where("state = ? AND finished_at < ?", types.JobDone, before).Delete(&types.Job{})
+	return result.RowsAffected, result.Error
+}
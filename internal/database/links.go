@@ -0,0 +1,393 @@
+package database
+
+import (
+	"EverythingSuckz/fsb/internal/types"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LinkRepository stores generated links and their access statistics
+type LinkRepository struct {
+	db  *gorm.DB
+	log *zap.Logger
+}
+
+var linkRepository *LinkRepository
+
+// GetLinkRepository returns the link repository, or nil if the database is not initialized
+func GetLinkRepository() *LinkRepository {
+	return linkRepository
+}
+
+// Create stores a newly generated link
+func (r *LinkRepository) Create(link *types.Link) error {
+	return r.db.Create(link).Error
+}
+
+// Reserve stores a new link marked removed, so that it isn't listed or streamed until Publish.
+// Links reserved when the bot stopped stay removed.
+func (r *LinkRepository) Reserve(link *types.Link) error {
+	now := time.Now()
+	link.RemovedAt = &now
+	return r.db.Create(link).Error
+}
+
+// Publish stores the reply of a reserved link and makes it work, and marks the link it
+// replaces removed, if any
+func (r *LinkRepository) Publish(link *types.Link, replaced *types.Link) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&types.Link{}).
+			Where("tenant_id = ? AND message_id = ?", link.TenantID, link.MessageID).
+			Updates(map[string]interface{}{"reply_id": link.ReplyID, "removed_at": nil}).Error
+		if err != nil {
+			return err
+		}
+		if replaced != nil {
+			err := tx.Model(&types.Link{}).
+				Where("tenant_id = ? AND message_id = ? AND removed_at IS NULL", replaced.TenantID, replaced.MessageID).
+				Update("removed_at", time.Now()).Error
+			if err != nil {
+				return err
+			}
+		}
+		link.RemovedAt = nil
+		return nil
+	})
+}
+
+// Discard deletes a reserved link whose reply couldn't be sent
+func (r *LinkRepository) Discard(link *types.Link) error {
+	return r.db.Where("tenant_id = ? AND message_id = ? AND reply_id = ?", link.TenantID, link.MessageID, 0).Delete(&types.Link{}).Error
+}
+
+// Get returns the link generated for the given message in the log channel of the tenant
+func (r *LinkRepository) Get(tenantID uint, messageID int) (*types.Link, error) {
+	var link types.Link
+	err := r.db.Where("tenant_id = ? AND message_id = ?", tenantID, messageID).First(&link).Error
+	if err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+// FindByUserMessage returns the link of the user that was generated from, or replied with, the given message
+func (r *LinkRepository) FindByUserMessage(userID int64, messageID int) (*types.Link, error) {
+	var link types.Link
+	err := r.db.Where("user_id = ? AND (reply_id = ? OR source_id = ?)", userID, messageID, messageID).
+		Order("created_at DESC").
+		First(&link).Error
+	if err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+// FindByFile returns the newest working link of the user in the tenant for the Telegram file
+func (r *LinkRepository) FindByFile(tenantID uint, userID int64, fileID int64) (*types.Link, error) {
+	var link types.Link
+	err := r.db.Where("tenant_id = ? AND user_id = ? AND file_id = ? AND revoked_at IS NULL AND removed_at IS NULL", tenantID, userID, fileID).
+		Order("created_at DESC").
+		First(&link).Error
+	if err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+// ListAlbum returns the links the user generated from the files of an album, in the order they were sent
+func (r *LinkRepository) ListAlbum(userID int64, groupedID int64, limit int) ([]types.Link, error) {
+	var links []types.Link
+	err := r.db.Where("user_id = ? AND grouped_id = ? AND revoked_at IS NULL AND removed_at IS NULL", userID, groupedID).
+		Order("source_id").
+		Limit(limit).
+		Find(&links).Error
+	return links, err
+}
+
+// ListByUsers returns the most recent links generated by any of the users that weren't revoked
+// or removed, the links bumped with Bump count as generated when they were bumped
+func (r *LinkRepository) ListByUsers(userIDs []int64, limit int) ([]types.Link, error) {
+	var links []types.Link
+	err := r.db.Where("user_id IN ? AND revoked_at IS NULL AND removed_at IS NULL", userIDs).
+		Order("COALESCE(bumped_at, created_at) DESC").
+		Limit(limit).
+		Find(&links).Error
+	return links, err
+}
+
+// ListByCategory returns the links of ListByUsers in the category
+func (r *LinkRepository) ListByCategory(userIDs []int64, category string, limit int) ([]types.Link, error) {
+	var links []types.Link
+	err := r.db.Where("user_id IN ? AND category = ? AND revoked_at IS NULL AND removed_at IS NULL", userIDs, category).
+		Order("COALESCE(bumped_at, created_at) DESC").
+		Limit(limit).
+		Find(&links).Error
+	return links, err
+}
+
+// ListUncategorized returns links generated before categories were assigned
+func (r *LinkRepository) ListUncategorized(limit int) ([]types.Link, error) {
+	var links []types.Link
+	err := r.db.Where("category = '' OR category IS NULL").Limit(limit).Find(&links).Error
+	return links, err
+}
+
+// SetCategory stores the category of the link
+func (r *LinkRepository) SetCategory(tenantID uint, messageID int, category string) error {
+	return r.db.Model(&types.Link{}).
+		Where("tenant_id = ? AND message_id = ?", tenantID, messageID).
+		Update("category", category).Error
+}
+
+// SearchByUsers returns a page of the links of ListByUsers whose MIME type starts with one of
+// the prefixes and whose file name or audio tags contain the query, if it isn't empty
+func (r *LinkRepository) SearchByUsers(userIDs []int64, query string, mimePrefixes []string, offset int, limit int) ([]types.Link, error) {
+	var links []types.Link
+	db := r.db.Where("user_id IN ? AND revoked_at IS NULL AND removed_at IS NULL", userIDs)
+	if query != "" {
+		pattern := "%" + strings.ToLower(query) + "%"
+		db = db.Where("(LOWER(file_name) LIKE ? OR LOWER(title) LIKE ? OR LOWER(performer) LIKE ?)", pattern, pattern, pattern)
+	}
+	if len(mimePrefixes) > 0 {
+		conditions := make([]string, len(mimePrefixes))
+		args := make([]interface{}, len(mimePrefixes))
+		for i, prefix := range mimePrefixes {
+			conditions[i] = "mime_type LIKE ?"
+			args[i] = prefix + "%"
+		}
+		db = db.Where("("+strings.Join(conditions, " OR ")+")", args...)
+	}
+	err := db.Order("COALESCE(bumped_at, created_at) DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&links).Error
+	return links, err
+}
+
+// UpdateMetadata stores the file name, the title and the performer of the link
+func (r *LinkRepository) UpdateMetadata(link *types.Link) error {
+	return r.db.Model(&types.Link{}).
+		Where("tenant_id = ? AND message_id = ?", link.TenantID, link.MessageID).
+		Select("file_name", "title", "performer").
+		Updates(link).Error
+}
+
+// SetChecksum stores the SHA-256 of the file of the link
+func (r *LinkRepository) SetChecksum(tenantID uint, messageID int, checksum string) error {
+	return r.db.Model(&types.Link{}).
+		Where("tenant_id = ? AND message_id = ?", tenantID, messageID).
+		Update("checksum", checksum).Error
+}
+
+// RecordView increments the view counter of a link and updates its last access time
+func (r *LinkRepository) RecordView(tenantID uint, messageID int) error {
+	return r.db.Model(&types.Link{}).
+		Where("tenant_id = ? AND message_id = ?", tenantID, messageID).
+		Updates(map[string]interface{}{
+			"views":       gorm.Expr("views + 1"),
+			"last_access": time.Now(),
+		}).Error
+}
+
+// GetStale returns links whose reply doesn't show the current view count yet,
+// skipping the users who turned live views off
+func (r *LinkRepository) GetStale(limit int) ([]types.Link, error) {
+	var links []types.Link
+	err := r.db.Where("reply_id != 0 AND views != edited_views AND removed_at IS NULL").
+		Where("user_id NOT IN (SELECT id FROM users WHERE live_views = ?)", false).
+		Order("last_access").
+		Limit(limit).
+		Find(&links).Error
+	return links, err
+}
+
+// MarkEdited records the view count currently shown in the reply of a link
+func (r *LinkRepository) MarkEdited(tenantID uint, messageID int, views int64) error {
+	return r.db.Model(&types.Link{}).
+		Where("tenant_id = ? AND message_id = ?", tenantID, messageID).
+		Update("edited_views", views).Error
+}
+
+// RecordAccess remembers that the IP accessed the link from the country, refreshing the time of
+// a repeat visit
+func (r *LinkRepository) RecordAccess(tenantID uint, messageID int, ip string, country string) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "tenant_id"}, {Name: "message_id"}, {Name: "ip"}},
+		DoUpdates: clause.AssignmentColumns([]string{"accessed_at", "country"}),
+	}).Create(&types.LinkAccess{TenantID: tenantID, MessageID: messageID, IP: ip, Country: country, AccessedAt: time.Now()}).Error
+}
+
+// CountSince returns the number of links the user generated since the given time
+func (r *LinkRepository) CountSince(userID int64, since time.Time) (int64, error) {
+	var count int64
+	err := r.db.Model(&types.Link{}).
+		Where("user_id = ? AND created_at >= ?", userID, since).
+		Count(&count).Error
+	return count, err
+}
+
+// CountDistinctIPsSince returns the number of distinct IPs that accessed the user's links since the given time
+func (r *LinkRepository) CountDistinctIPsSince(userID int64, since time.Time) (int64, error) {
+	var count int64
+	err := r.db.Model(&types.LinkAccess{}).
+		Joins("JOIN links ON links.tenant_id = link_accesses.tenant_id AND links.message_id = link_accesses.message_id").
+		Where("links.user_id = ? AND link_accesses.accessed_at >= ?", userID, since).
+		Distinct("link_accesses.ip").
+		Count(&count).Error
+	return count, err
+}
+
+// CountDistinctCountriesSince returns the number of distinct known countries the user's links
+// were accessed from since the given time
+func (r *LinkRepository) CountDistinctCountriesSince(userID int64, since time.Time) (int64, error) {
+	var count int64
+	err := r.db.Model(&types.LinkAccess{}).
+		Joins("JOIN links ON links.tenant_id = link_accesses.tenant_id AND links.message_id = link_accesses.message_id").
+		Where("links.user_id = ? AND link_accesses.accessed_at >= ? AND link_accesses.country <> ''", userID, since).
+		Distinct("link_accesses.country").
+		Count(&count).Error
+	return count, err
+}
+
+// CountTenantSince returns the number of links the user generated in the tenant since the given time
+func (r *LinkRepository) CountTenantSince(tenantID uint, userID int64, since time.Time) (int64, error) {
+	var count int64
+	err := r.db.Model(&types.Link{}).
+		Where("tenant_id = ? AND user_id = ? AND created_at >= ?", tenantID, userID, since).
+		Count(&count).Error
+	return count, err
+}
+
+// ListCreated returns the links generated within the time range, optionally only those of users
+// with an authorization that didn't expire
+func (r *LinkRepository) ListCreated(from time.Time, to time.Time, authorizedOnly bool) ([]types.Link, error) {
+	var links []types.Link
+	query := r.db.Where("links.created_at >= ? AND links.created_at < ?", from, to)
+	if authorizedOnly {
+		query = query.Joins("JOIN users ON users.id = links.user_id").
+			Where("users.authorized = ? AND (users.authorized_till IS NULL OR users.authorized_till > ?)", true, time.Now())
+	}
+	err := query.Order("links.created_at").Find(&links).Error
+	return links, err
+}
+
+// FindByHash returns the links with the given current or old hash, in any tenant
+func (r *LinkRepository) FindByHash(hash string, limit int) ([]types.Link, error) {
+	var links []types.Link
+	err := r.db.Where("hash = ? OR old_hash = ?", hash, hash).Order("created_at DESC").Limit(limit).Find(&links).Error
+	return links, err
+}
+
+// Transfer makes the user the owner of the link and of its short links. The reply in the
+// chat of the previous owner isn't updated anymore.
+func (r *LinkRepository) Transfer(tenantID uint, messageID int, userID int64) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&types.Link{}).
+			Where("tenant_id = ? AND message_id = ?", tenantID, messageID).
+			Updates(map[string]interface{}{"user_id": userID, "reply_id": 0, "source_id": 0}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&types.ShortLink{}).
+			Where("tenant_id = ? AND message_id = ?", tenantID, messageID).
+			Update("user_id", userID).Error
+	})
+}
+
+// RevokeAll revokes all links of the user and returns the number of revoked links
+func (r *LinkRepository) RevokeAll(userID int64) (int64, error) {
+	result := r.db.Model(&types.Link{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now())
+	return result.RowsAffected, result.Error
+}
+
+// Share allows the user to open the link, which makes the link private
+func (r *LinkRepository) Share(tenantID uint, messageID int, userID int64) error {
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&types.LinkShare{TenantID: tenantID, MessageID: messageID, UserID: userID}).Error
+}
+
+// Unshare removes the user from the users allowed to open the link. It returns false if the
+// link wasn't shared with the user. The link is public again once it isn't shared with anyone.
+func (r *LinkRepository) Unshare(tenantID uint, messageID int, userID int64) (bool, error) {
+	result := r.db.Where("tenant_id = ? AND message_id = ? AND user_id = ?", tenantID, messageID, userID).
+		Delete(&types.LinkShare{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// ListShares returns the users the link was shared with, none for public links
+func (r *LinkRepository) ListShares(tenantID uint, messageID int) ([]int64, error) {
+	var userIDs []int64
+	err := r.db.Model(&types.LinkShare{}).
+		Where("tenant_id = ? AND message_id = ?", tenantID, messageID).
+		Order("created_at").
+		Pluck("user_id", &userIDs).Error
+	return userIDs, err
+}
+
+// ListPublic returns the most recent links of the tenant that weren't revoked or shared with
+// specific users, generated after the given time
+func (r *LinkRepository) ListPublic(tenantID uint, after time.Time, limit int) ([]types.Link, error) {
+	var links []types.Link
+	err := r.db.Where("tenant_id = ? AND revoked_at IS NULL AND created_at > ?", tenantID, after).
+		Where("NOT EXISTS (SELECT 1 FROM link_shares WHERE link_shares.tenant_id = links.tenant_id AND link_shares.message_id = links.message_id)").
+		Order("created_at DESC").
+		Limit(limit).
+		Find(&links).Error
+	return links, err
+}
+
+// MarkRemoved marks the links of the messages of the tenants as removed, after the messages were
+// deleted from their log channel. It returns the number of links that were marked.
+func (r *LinkRepository) MarkRemoved(tenantIDs []uint, messageIDs []int) (int64, error) {
+	result := r.db.Model(&types.Link{}).
+		Where("tenant_id IN ? AND message_id IN ? AND removed_at IS NULL", tenantIDs, messageIDs).
+		Update("removed_at", time.Now())
+	return result.RowsAffected, result.Error
+}
+
+// MarkSourceRemoved marks the links generated from the messages as removed, after the users
+// deleted them from their chat with the bot. It returns the number of links that were marked.
+func (r *LinkRepository) MarkSourceRemoved(sourceIDs []int) (int64, error) {
+	result := r.db.Model(&types.Link{}).
+		Where("source_id IN ? AND removed_at IS NULL", sourceIDs).
+		Update("removed_at", time.Now())
+	return result.RowsAffected, result.Error
+}
+
+// Bump moves the link to the top of the player queue of its owner
+func (r *LinkRepository) Bump(tenantID uint, messageID int) error {
+	return r.db.Model(&types.Link{}).
+		Where("tenant_id = ? AND message_id = ?", tenantID, messageID).
+		Update("bumped_at", time.Now()).Error
+}
+
+// Revoke revokes a single link. It returns false if the link was already revoked.
+func (r *LinkRepository) Revoke(tenantID uint, messageID int) (bool, error) {
+	result := r.db.Model(&types.Link{}).
+		Where("tenant_id = ? AND message_id = ? AND revoked_at IS NULL", tenantID, messageID).
+		Update("revoked_at", time.Now())
+	return result.RowsAffected > 0, result.Error
+}
+
+// ListOutdatedHashes returns the working links whose hash doesn't have the given length
+func (r *LinkRepository) ListOutdatedHashes(length int) ([]types.Link, error) {
+	var links []types.Link
+	err := r.db.Where("LENGTH(hash) != ? AND revoked_at IS NULL AND removed_at IS NULL", length).
+		Order("tenant_id, message_id").
+		Find(&links).Error
+	return links, err
+}
+
+// Regenerate replaces the hash of the link and keeps the current one as its old hash,
+// so that the links sent before keep working
+func (r *LinkRepository) Regenerate(tenantID uint, messageID int, hash string) error {
+	return r.db.Model(&types.Link{}).
+		Where("tenant_id = ? AND message_id = ?", tenantID, messageID).
+		Updates(map[string]interface{}{"old_hash": gorm.Expr("hash"), "hash": hash}).Error
+}
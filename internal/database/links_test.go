@@ -0,0 +1,140 @@
+package database
+
+import (
+	"EverythingSuckz/fsb/internal/types"
+	"testing"
+	"time"
+)
+
+func TestRecordAccessRefreshesRepeatVisits(t *testing.T) {
+	openTest(t)
+	links := GetLinkRepository()
+	if err := links.Create(&types.Link{MessageID: 1, Hash: "abcdef", UserID: 7}); err != nil {
+		t.Fatal(err)
+	}
+	if err := links.Create(&types.Link{MessageID: 2, Hash: "ghijkl", UserID: 7}); err != nil {
+		t.Fatal(err)
+	}
+	for _, access := range []struct {
+		messageID int
+		ip        string
+	}{{1, "10.0.0.1"}, {1, "10.0.0.1"}, {2, "10.0.0.1"}, {1, "10.0.0.2"}} {
+		if err := links.RecordAccess(0, access.messageID, access.ip, ""); err != nil {
+			t.Fatalf("access of %d from %s: %v", access.messageID, access.ip, err)
+		}
+	}
+	var rows int64
+	if err := DB.Model(&types.LinkAccess{}).Count(&rows).Error; err != nil {
+		t.Fatal(err)
+	}
+	if rows != 3 {
+		t.Errorf("got %d access rows, expected one per link and IP, 3", rows)
+	}
+	count, err := links.CountDistinctIPsSince(7, time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("got %d distinct IPs, expected 2", count)
+	}
+
+	// a visit refreshes the access, so an IP that came back counts as recent
+	old := time.Now().Add(-48 * time.Hour)
+	if err := DB.Model(&types.LinkAccess{}).Where("ip = ?", "10.0.0.2").Update("accessed_at", old).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count, _ := links.CountDistinctIPsSince(7, time.Now().Add(-24*time.Hour)); count != 1 {
+		t.Errorf("got %d distinct IPs in the last day, expected 1", count)
+	}
+	if err := links.RecordAccess(0, 1, "10.0.0.2", ""); err != nil {
+		t.Fatal(err)
+	}
+	if count, _ := links.CountDistinctIPsSince(7, time.Now().Add(-24*time.Hour)); count != 2 {
+		t.Errorf("got %d distinct IPs in the last day after a repeat visit, expected 2", count)
+	}
+}
+
+func TestReservedLinksWorkOncePublished(t *testing.T) {
+	openTest(t)
+	links := GetLinkRepository()
+	previous := &types.Link{MessageID: 1, Hash: "abcdef", UserID: 7, SourceID: 100}
+	if err := links.Create(previous); err != nil {
+		t.Fatal(err)
+	}
+	link := &types.Link{MessageID: 2, Hash: "ghijkl", UserID: 7, SourceID: 100}
+	if err := links.Reserve(link); err != nil {
+		t.Fatal(err)
+	}
+	if stored, _ := links.Get(0, 2); stored.RemovedAt == nil {
+		t.Error("reserved link works before it's published")
+	}
+	link.ReplyID = 101
+	if err := links.Publish(link, previous); err != nil {
+		t.Fatal(err)
+	}
+	if stored, _ := links.Get(0, 2); stored.RemovedAt != nil || stored.ReplyID != 101 {
+		t.Errorf("published link: removed at %v, reply %d", stored.RemovedAt, stored.ReplyID)
+	}
+	if stored, _ := links.Get(0, 1); stored.RemovedAt == nil {
+		t.Error("the replaced link still works")
+	}
+
+	discarded := &types.Link{MessageID: 3, Hash: "mnopqr", UserID: 7}
+	if err := links.Reserve(discarded); err != nil {
+		t.Fatal(err)
+	}
+	if err := links.Discard(discarded); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := links.Get(0, 3); err == nil {
+		t.Error("discarded link is still stored")
+	}
+}
+
+func TestListAlbum(t *testing.T) {
+	openTest(t)
+	links := GetLinkRepository()
+	for i, groupedID := range []int64{5, 5, 6, 0, 5} {
+		link := &types.Link{MessageID: i + 1, Hash: "abcdef", UserID: 7, SourceID: 100 + i, GroupedID: groupedID}
+		if err := links.Create(link); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := links.Create(&types.Link{MessageID: 10, Hash: "abcdef", UserID: 8, GroupedID: 5}); err != nil {
+		t.Fatal(err)
+	}
+	album, err := links.ListAlbum(7, 5, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(album) != 3 {
+		t.Fatalf("got %d links of the album, expected 3", len(album))
+	}
+	for _, link := range album {
+		if link.GroupedID != 5 || link.UserID != 7 {
+			t.Errorf("link %d of album %d of user %d is listed", link.MessageID, link.GroupedID, link.UserID)
+		}
+	}
+}
+
+func TestCountDistinctCountriesIgnoresUnknownCountries(t *testing.T) {
+	openTest(t)
+	links := GetLinkRepository()
+	if err := links.Create(&types.Link{MessageID: 1, Hash: "abcdef", UserID: 7}); err != nil {
+		t.Fatal(err)
+	}
+	for _, access := range []struct {
+		ip      string
+		country string
+	}{{"10.0.0.1", "DE"}, {"10.0.0.2", "DE"}, {"10.0.0.3", "BR"}, {"10.0.0.4", ""}} {
+		if err := links.RecordAccess(0, 1, access.ip, access.country); err != nil {
+			t.Fatalf("access from %s: %v", access.ip, err)
+		}
+	}
+	if count, _ := links.CountDistinctCountriesSince(7, time.Now().Add(-time.Hour)); count != 2 {
+		t.Errorf("got %d distinct countries, expected 2", count)
+	}
+	if count, _ := links.CountDistinctCountriesSince(8, time.Now().Add(-time.Hour)); count != 0 {
+		t.Errorf("another user got %d distinct countries, expected 0", count)
+	}
+}
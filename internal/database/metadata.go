@@ -0,0 +1,41 @@
+package database
+
+import (
+	"EverythingSuckz/fsb/internal/types"
+	"errors"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MetadataRepository stores what TMDB and MusicBrainz know about the files of links
+type MetadataRepository struct {
+	db  *gorm.DB
+	log *zap.Logger
+}
+
+var metadataRepository *MetadataRepository
+
+// GetMetadataRepository returns the metadata repository, or nil if the database is not initialized
+func GetMetadataRepository() *MetadataRepository {
+	return metadataRepository
+}
+
+// Get returns the metadata of the link, or nil if it wasn't looked up yet
+func (r *MetadataRepository) Get(tenantID uint, messageID int) (*types.LinkMetadata, error) {
+	var metadata types.LinkMetadata
+	err := r.db.Where("tenant_id = ? AND message_id = ?", tenantID, messageID).First(&metadata).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &metadata, nil
+}
+
+// Save stores the metadata of a link, replacing the one stored before
+func (r *MetadataRepository) Save(metadata *types.LinkMetadata) error {
+	return r.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(metadata).Error
+}
@@ -0,0 +1,59 @@
+package database
+
+import (
+	"EverythingSuckz/fsb/internal/types"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// NoteRepository stores the notes and tags admins keep on users
+type NoteRepository struct {
+	db  *gorm.DB
+	log *zap.Logger
+}
+
+var noteRepository *NoteRepository
+
+// GetNoteRepository returns the note repository, or nil if the database is not initialized
+func GetNoteRepository() *NoteRepository {
+	return noteRepository
+}
+
+// AddNote stores a note about a user
+func (r *NoteRepository) AddNote(note *types.UserNote) error {
+	return r.db.Create(note).Error
+}
+
+// ListNotes returns the notes about the user, oldest first
+func (r *NoteRepository) ListNotes(userID int64) ([]types.UserNote, error) {
+	var notes []types.UserNote
+	err := r.db.Where("user_id = ?", userID).Order("created_at").Find(&notes).Error
+	return notes, err
+}
+
+// DeleteNote removes a note and reports whether it existed
+func (r *NoteRepository) DeleteNote(id uint) (bool, error) {
+	result := r.db.Where("id = ?", id).Delete(&types.UserNote{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// AddTag tags the user, tagging a user twice with the same tag does nothing
+func (r *NoteRepository) AddTag(userID int64, tag string, createdBy int64) error {
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&types.UserTag{UserID: userID, Tag: tag, CreatedBy: createdBy}).Error
+}
+
+// RemoveTag removes a tag of the user and reports whether the user had it
+func (r *NoteRepository) RemoveTag(userID int64, tag string) (bool, error) {
+	result := r.db.Where("user_id = ? AND tag = ?", userID, tag).Delete(&types.UserTag{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// ListTags returns the tags of the user in alphabetical order
+func (r *NoteRepository) ListTags(userID int64) ([]string, error) {
+	var tags []string
+	err := r.db.Model(&types.UserTag{}).Where("user_id = ?", userID).Order("tag").Pluck("tag", &tags).Error
+	return tags, err
+}
@@ -0,0 +1,57 @@
+package database
+
+import (
+	"EverythingSuckz/fsb/internal/types"
+	"errors"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// OIDCRepository stores which Telegram users the accounts of the OIDC provider sign in as
+type OIDCRepository struct {
+	db  *gorm.DB
+	log *zap.Logger
+}
+
+var oidcRepository *OIDCRepository
+
+// GetOIDCRepository returns the OIDC repository, or nil if the database is not initialized
+func GetOIDCRepository() *OIDCRepository {
+	return oidcRepository
+}
+
+// Get returns the identity of the account, or nil if it isn't linked to a user
+func (r *OIDCRepository) Get(issuer string, subject string) (*types.OIDCIdentity, error) {
+	var identity types.OIDCIdentity
+	err := r.db.Where("issuer = ? AND subject = ?", issuer, subject).First(&identity).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
+
+// Link maps the account to the user, replacing the user it was linked to before
+func (r *OIDCRepository) Link(identity *types.OIDCIdentity) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "issuer"}, {Name: "subject"}},
+		DoUpdates: clause.AssignmentColumns([]string{"user_id", "email", "created_at"}),
+	}).Create(identity).Error
+}
+
+// List returns the accounts linked to the user
+func (r *OIDCRepository) List(userID int64) ([]types.OIDCIdentity, error) {
+	var identities []types.OIDCIdentity
+	err := r.db.Where("user_id = ?", userID).Order("created_at").Find(&identities).Error
+	return identities, err
+}
+
+// Unlink removes the accounts linked to the user and returns how many there were
+func (r *OIDCRepository) Unlink(userID int64) (int64, error) {
+	result := r.db.Where("user_id = ?", userID).Delete(&types.OIDCIdentity{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,45 @@
+package database
+
+import (
+	"EverythingSuckz/fsb/internal/types"
+	"errors"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrDuplicatePayment is returned when a payment with the same charge ID was recorded already
+var ErrDuplicatePayment = errors.New("payment was already recorded")
+
+// PaymentRepository stores the subscription payments
+type PaymentRepository struct {
+	db  *gorm.DB
+	log *zap.Logger
+}
+
+var paymentRepository *PaymentRepository
+
+// GetPaymentRepository returns the payment repository, or nil if the database is not initialized
+func GetPaymentRepository() *PaymentRepository {
+	return paymentRepository
+}
+
+// Create stores a payment, it returns ErrDuplicatePayment if its charge ID was recorded already
+func (r *PaymentRepository) Create(payment *types.Payment) error {
+	result := r.db.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "charge_id"}}, DoNothing: true}).Create(payment)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrDuplicatePayment
+	}
+	return nil
+}
+
+// ListByUser returns the payments of the user, newest first
+func (r *PaymentRepository) ListByUser(userID int64, limit int) ([]types.Payment, error) {
+	var payments []types.Payment
+	err := r.db.Where("user_id = ?", userID).Order("created_at DESC").Limit(limit).Find(&payments).Error
+	return payments, err
+}
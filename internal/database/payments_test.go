@@ -0,0 +1,25 @@
+package database
+
+import (
+	"EverythingSuckz/fsb/internal/types"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPaymentIsRecordedOnce(t *testing.T) {
+	openTest(t)
+	payments := GetPaymentRepository()
+	payment := types.Payment{UserID: 7, Plan: "monthly", Currency: "XTR", Amount: 100, ChargeID: "charge-1", PaidUntil: time.Now().Add(30 * 24 * time.Hour)}
+	first := payment
+	if err := payments.Create(&first); err != nil {
+		t.Fatal(err)
+	}
+	again := payment
+	if err := payments.Create(&again); !errors.Is(err, ErrDuplicatePayment) {
+		t.Errorf("recording the charge again returned %v, expected ErrDuplicatePayment", err)
+	}
+	if listed, _ := payments.ListByUser(7, 10); len(listed) != 1 {
+		t.Errorf("got %d payments, expected 1", len(listed))
+	}
+}
@@ -0,0 +1,57 @@
+package database
+
+import (
+	"EverythingSuckz/fsb/internal/types"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ProcessedRepository stores the messages with media the bot handled
+type ProcessedRepository struct {
+	db  *gorm.DB
+	log *zap.Logger
+}
+
+var processedRepository *ProcessedRepository
+
+// GetProcessedRepository returns the processed message repository, or nil if the database is not initialized
+func GetProcessedRepository() *ProcessedRepository {
+	return processedRepository
+}
+
+// Claim records that the message, or its edit, is being handled. It returns false if it
+// was handled before.
+func (r *ProcessedRepository) Claim(chatID int64, messageID int, editDate int) (bool, error) {
+	result := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&types.ProcessedMessage{
+		ChatID:      chatID,
+		MessageID:   messageID,
+		EditDate:    editDate,
+		ProcessedAt: time.Now(),
+	})
+	return result.RowsAffected > 0, result.Error
+}
+
+// Release forgets the claim of the message, or its edit, when handling it failed, so that it's
+// handled again when Telegram delivers it again or the missed messages are recovered
+func (r *ProcessedRepository) Release(chatID int64, messageID int, editDate int) error {
+	return r.db.Where("chat_id = ? AND message_id = ? AND edit_date = ?", chatID, messageID, editDate).
+		Delete(&types.ProcessedMessage{}).Error
+}
+
+// Processed reports whether the message was handled before
+func (r *ProcessedRepository) Processed(chatID int64, messageID int) (bool, error) {
+	var count int64
+	err := r.db.Model(&types.ProcessedMessage{}).
+		Where("chat_id = ? AND message_id = ?", chatID, messageID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// Purge removes the messages processed before the time, it returns the number removed
+func (r *ProcessedRepository) Purge(before time.Time) (int64, error) {
+	result := r.db.Where("processed_at < ?", before).Delete(&types.ProcessedMessage{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,25 @@
+package database
+
+import (
+	"testing"
+)
+
+func TestProcessedClaimAndRelease(t *testing.T) {
+	openTest(t)
+	processed := GetProcessedRepository()
+	if claimed, err := processed.Claim(7, 100, 0); err != nil || !claimed {
+		t.Fatalf("first claim: %v, %v", claimed, err)
+	}
+	if claimed, _ := processed.Claim(7, 100, 0); claimed {
+		t.Error("the message was claimed twice")
+	}
+	if claimed, _ := processed.Claim(7, 100, 1234); !claimed {
+		t.Error("the edit of the message wasn't claimed")
+	}
+	if err := processed.Release(7, 100, 0); err != nil {
+		t.Fatal(err)
+	}
+	if claimed, _ := processed.Claim(7, 100, 0); !claimed {
+		t.Error("the released message couldn't be claimed again")
+	}
+}
@@ -0,0 +1,38 @@
+package database
+
+import (
+	"EverythingSuckz/fsb/internal/types"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// QuarantineRepository stores the files blocked by the virus scanner
+type QuarantineRepository struct {
+	db  *gorm.DB
+	log *zap.Logger
+}
+
+var quarantineRepository *QuarantineRepository
+
+// GetQuarantineRepository returns the quarantine repository, or nil if the database is not initialized
+func GetQuarantineRepository() *QuarantineRepository {
+	return quarantineRepository
+}
+
+// Add stores a blocked file
+func (r *QuarantineRepository) Add(item *types.Quarantine) error {
+	return r.db.Create(item).Error
+}
+
+// ListPending returns the blocked files that weren't reviewed yet, newest first
+func (r *QuarantineRepository) ListPending(limit int) ([]types.Quarantine, error) {
+	var items []types.Quarantine
+	err := r.db.Where("reviewed = ?", false).Order("created_at DESC").Limit(limit).Find(&items).Error
+	return items, err
+}
+
+// MarkReviewed marks a blocked file as reviewed by an admin
+func (r *QuarantineRepository) MarkReviewed(id uint) error {
+	return r.db.Model(&types.Quarantine{}).Where("id = ?", id).Update("reviewed", true).Error
+}
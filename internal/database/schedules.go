@@ -0,0 +1,66 @@
+package database
+
+import (
+	"EverythingSuckz/fsb/internal/types"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// ScheduleRepository stores the messages scheduled with /schedule
+type ScheduleRepository struct {
+	db  *gorm.DB
+	log *zap.Logger
+}
+
+var scheduleRepository *ScheduleRepository
+
+// GetScheduleRepository returns the schedule repository, or nil if the database is not initialized
+func GetScheduleRepository() *ScheduleRepository {
+	return scheduleRepository
+}
+
+// Create stores a new scheduled message
+func (r *ScheduleRepository) Create(schedule *types.Schedule) error {
+	return r.db.Create(schedule).Error
+}
+
+// ListPending returns the scheduled messages that weren't sent yet or repeat, next first
+func (r *ScheduleRepository) ListPending(limit int) ([]types.Schedule, error) {
+	var schedules []types.Schedule
+	err := r.db.Where("done = ?", false).Order("run_at").Limit(limit).Find(&schedules).Error
+	return schedules, err
+}
+
+// ListDue returns the scheduled messages whose time has come
+func (r *ScheduleRepository) ListDue(now time.Time) ([]types.Schedule, error) {
+	var schedules []types.Schedule
+	err := r.db.Where("done = ? AND run_at <= ?", false, now).Order("run_at").Find(&schedules).Error
+	return schedules, err
+}
+
+// Advance records a run of the scheduled message and moves it to its next run, or marks
+// one-off messages done. Runs that were missed while the bot was down are skipped.
+func (r *ScheduleRepository) Advance(schedule *types.Schedule, ranAt time.Time) error {
+	updates := map[string]interface{}{
+		"runs":        gorm.Expr("runs + 1"),
+		"last_run_at": ranAt,
+	}
+	if schedule.Interval > 0 {
+		next := schedule.RunAt
+		for !next.After(ranAt) {
+			next = next.Add(schedule.Interval)
+		}
+		updates["run_at"] = next
+	} else {
+		updates["done"] = true
+	}
+	return r.db.Model(&types.Schedule{}).Where("id = ?", schedule.ID).Updates(updates).Error
+}
+
+// Delete removes a pending scheduled message and reports whether there was one
+func (r *ScheduleRepository) Delete(id uint) (bool, error) {
+	result := r.db.Where("id = ? AND done = ?", id, false).Delete(&types.Schedule{})
+	return result.RowsAffected > 0, result.Error
+}
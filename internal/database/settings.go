@@ -0,0 +1,42 @@
+package database
+
+import (
+	"EverythingSuckz/fsb/internal/types"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SettingRepository stores the settings that override the static config
+type SettingRepository struct {
+	db  *gorm.DB
+	log *zap.Logger
+}
+
+var settingRepository *SettingRepository
+
+// GetSettingRepository returns the setting repository, or nil if the database is not initialized
+func GetSettingRepository() *SettingRepository {
+	return settingRepository
+}
+
+// List returns all stored settings
+func (r *SettingRepository) List() ([]types.Setting, error) {
+	var settings []types.Setting
+	err := r.db.Order("key").Find(&settings).Error
+	return settings, err
+}
+
+// Set stores the value of the setting
+func (r *SettingRepository) Set(key string, value string, updatedBy int64) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"value", "updated_by", "updated_at"}),
+	}).Create(&types.Setting{Key: key, Value: value, UpdatedBy: updatedBy}).Error
+}
+
+// Delete removes the setting, so the static config applies again
+func (r *SettingRepository) Delete(key string) error {
+	return r.db.Where("key = ?", key).Delete(&types.Setting{}).Error
+}
@@ -0,0 +1,65 @@
+package database
+
+import (
+	"EverythingSuckz/fsb/internal/types"
+	"errors"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrAliasTaken is returned when a short link alias is already in use
+var ErrAliasTaken = errors.New("alias is already taken")
+
+// ShortLinkRepository stores the short aliases of generated links
+type ShortLinkRepository struct {
+	db  *gorm.DB
+	log *zap.Logger
+}
+
+var shortLinkRepository *ShortLinkRepository
+
+// GetShortLinkRepository returns the short link repository, or nil if the database is not initialized
+func GetShortLinkRepository() *ShortLinkRepository {
+	return shortLinkRepository
+}
+
+// Create stores a short link, it returns ErrAliasTaken if the alias is in use.
+// The alias is claimed by the insert itself, so concurrent requests can't both get it.
+func (r *ShortLinkRepository) Create(shortLink *types.ShortLink) error {
+	result := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(shortLink)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrAliasTaken
+	}
+	return nil
+}
+
+// Get returns the short link with the given alias, or nil if it doesn't exist
+func (r *ShortLinkRepository) Get(alias string) (*types.ShortLink, error) {
+	var shortLink types.ShortLink
+	err := r.db.Where("alias = ?", alias).First(&shortLink).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &shortLink, nil
+}
+
+// FindGenerated returns the generated (not custom) short link of a link, or nil if there's none
+func (r *ShortLinkRepository) FindGenerated(tenantID uint, messageID int) (*types.ShortLink, error) {
+	var shortLink types.ShortLink
+	err := r.db.Where("tenant_id = ? AND message_id = ? AND custom = ?", tenantID, messageID, false).First(&shortLink).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &shortLink, nil
+}
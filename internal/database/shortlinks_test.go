@@ -0,0 +1,42 @@
+package database
+
+import (
+	"EverythingSuckz/fsb/internal/types"
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestShortLinkAliasIsClaimedOnce(t *testing.T) {
+	openTest(t)
+	shortLinks := GetShortLinkRepository()
+	var wg sync.WaitGroup
+	errs := make([]error, 8)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = shortLinks.Create(&types.ShortLink{Alias: "movie", MessageID: i + 1, UserID: int64(i + 1), Custom: true})
+		}(i)
+	}
+	wg.Wait()
+	var created int
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			created++
+		case !errors.Is(err, ErrAliasTaken):
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	if created != 1 {
+		t.Fatalf("the alias was created %d times", created)
+	}
+	shortLink, err := shortLinks.Get("movie")
+	if err != nil || shortLink == nil {
+		t.Fatalf("alias not found: %v", err)
+	}
+	if errs[shortLink.MessageID-1] != nil {
+		t.Errorf("the alias points to message %d, whose request failed", shortLink.MessageID)
+	}
+}
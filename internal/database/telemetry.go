@@ -0,0 +1,75 @@
+package database
+
+import (
+	"EverythingSuckz/fsb/internal/types"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// TelemetryRepository stores playback events reported by the web player
+type TelemetryRepository struct {
+	db  *gorm.DB
+	log *zap.Logger
+}
+
+var telemetryRepository *TelemetryRepository
+
+// GetTelemetryRepository returns the telemetry repository, or nil if the database is not initialized
+func GetTelemetryRepository() *TelemetryRepository {
+	return telemetryRepository
+}
+
+// Record stores a playback event
+func (r *TelemetryRepository) Record(event *types.PlaybackEvent) error {
+	return r.db.Create(event).Error
+}
+
+// sessionsQuery aggregates the events since the given time per player session
+const sessionsQuery = `SELECT tenant_id, message_id, session, MAX(watched) AS watched,
+	SUM(CASE WHEN type = 'seek' THEN 1 ELSE 0 END) AS seeks,
+	SUM(CASE WHEN type = 'stall' THEN 1 ELSE 0 END) AS stalls
+	FROM playback_events WHERE created_at >= ? GROUP BY tenant_id, message_id, session`
+
+// FileStats returns the playback statistics of the most watched files since the given time
+func (r *TelemetryRepository) FileStats(since time.Time, limit int) ([]types.PlaybackStats, error) {
+	var stats []types.PlaybackStats
+	err := r.db.Raw(`SELECT s.tenant_id, s.message_id, COALESCE(l.file_name, '') AS file_name, COUNT(*) AS sessions,
+		AVG(s.watched) AS avg_watch_time, SUM(s.seeks) AS seeks, SUM(s.stalls) AS stalls
+		FROM (`+sessionsQuery+`) s
+		LEFT JOIN links l ON l.tenant_id = s.tenant_id AND l.message_id = s.message_id
+		GROUP BY s.tenant_id, s.message_id ORDER BY sessions DESC LIMIT ?`, since, limit).
+		Scan(&stats).Error
+	for i := range stats {
+		stats[i].StallRate = stallRate(stats[i])
+	}
+	return stats, err
+}
+
+// Summary returns the playback statistics of all files since the given time
+func (r *TelemetryRepository) Summary(since time.Time) (types.PlaybackStats, error) {
+	var stats types.PlaybackStats
+	err := r.db.Raw(`SELECT COUNT(*) AS sessions, COALESCE(AVG(s.watched), 0) AS avg_watch_time,
+		COALESCE(SUM(s.seeks), 0) AS seeks, COALESCE(SUM(s.stalls), 0) AS stalls
+		FROM (`+sessionsQuery+`) s`, since).
+		Scan(&stats).Error
+	stats.StallRate = stallRate(stats)
+	return stats, err
+}
+
+func stallRate(stats types.PlaybackStats) float64 {
+	if stats.Sessions == 0 {
+		return 0
+	}
+	return float64(stats.Stalls) / float64(stats.Sessions)
+}
+
+// ListEvents returns the playback events reported within the time range
+func (r *TelemetryRepository) ListEvents(from time.Time, to time.Time) ([]types.PlaybackEvent, error) {
+	var events []types.PlaybackEvent
+	err := r.db.Where("created_at >= ? AND created_at < ?", from, to).
+		Order("created_at").
+		Find(&events).Error
+	return events, err
+}
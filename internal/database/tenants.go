@@ -0,0 +1,57 @@
+package database
+
+import (
+	"EverythingSuckz/fsb/internal/crypt"
+	"EverythingSuckz/fsb/internal/types"
+	"errors"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// TenantRepository stores the tenants of a multi-tenant deployment
+type TenantRepository struct {
+	db  *gorm.DB
+	log *zap.Logger
+}
+
+var tenantRepository *TenantRepository
+
+// GetTenantRepository returns the tenant repository, or nil if the database is not initialized
+func GetTenantRepository() *TenantRepository {
+	return tenantRepository
+}
+
+// Create stores a new tenant
+func (r *TenantRepository) Create(tenant *types.Tenant) error {
+	tenant.InviteDigest = crypt.Digest(tenant.InviteCode)
+	return r.db.Create(tenant).Error
+}
+
+// List returns all tenants
+func (r *TenantRepository) List() ([]types.Tenant, error) {
+	var tenants []types.Tenant
+	err := r.db.Order("id").Find(&tenants).Error
+	return tenants, err
+}
+
+// GetByInvite returns the tenant with the given invite code, or nil if there's none
+func (r *TenantRepository) GetByInvite(code string) (*types.Tenant, error) {
+	var tenant types.Tenant
+	err := r.db.Where("invite_digest = ?", crypt.Digest(code)).First(&tenant).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &tenant, nil
+}
+
+// Update saves the admins and the quota of a tenant
+func (r *TenantRepository) Update(tenant *types.Tenant) error {
+	return r.db.Model(tenant).Updates(map[string]interface{}{
+		"admins":           tenant.Admins,
+		"daily_link_quota": tenant.DailyLinkQuota,
+	}).Error
+}
@@ -0,0 +1,551 @@
+package database
+
+import (
+	"EverythingSuckz/fsb/internal/types"
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// UserRepository stores the users of the bot and their moderation state
+type UserRepository struct {
+	db  *gorm.DB
+	log *zap.Logger
+}
+
+var userRepository *UserRepository
+
+// GetUserRepository returns the user repository, or nil if the database is not initialized
+func GetUserRepository() *UserRepository {
+	return userRepository
+}
+
+// WithContext returns the repository with queries bound to the context, so they are
+// cancelled with it. It returns nil if the repository is nil.
+func (r *UserRepository) WithContext(ctx context.Context) *UserRepository {
+	if r == nil {
+		return nil
+	}
+	return &UserRepository{db: r.db.WithContext(ctx), log: r.log}
+}
+
+// Touch creates the user if it doesn't exist yet and refreshes its names
+func (r *UserRepository) Touch(id int64, username string, firstName string) error {
+	user := types.User{ID: id, Username: username, FirstName: firstName}
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"username", "first_name", "updated_at"}),
+	}).Create(&user).Error
+}
+
+// Get returns the user with the given ID, or nil if it doesn't exist
+func (r *UserRepository) Get(id int64) (*types.User, error) {
+	var user types.User
+	err := r.db.Where("id = ?", id).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// Flag marks the user for admin review. It returns false if the user was already flagged.
+func (r *UserRepository) Flag(id int64, reason string) (bool, error) {
+	now := time.Now()
+	result := r.db.Model(&types.User{}).
+		Where("id = ? AND flagged = ?", id, false).
+		Updates(map[string]interface{}{
+			"flagged":     true,
+			"flag_reason": reason,
+			"flagged_at":  now,
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// Unflag clears the review flag of the user
+func (r *UserRepository) Unflag(id int64) error {
+	return r.db.Model(&types.User{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"flagged":     false,
+			"flag_reason": "",
+			"flagged_at":  nil,
+		}).Error
+}
+
+// SetSuspended suspends or unsuspends the user
+func (r *UserRepository) SetSuspended(id int64, suspended bool) error {
+	result := r.db.Model(&types.User{}).Where("id = ?", id).Update("suspended", suspended)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// IsSuspended reports whether the user is suspended
+func (r *UserRepository) IsSuspended(id int64) (bool, error) {
+	var count int64
+	err := r.db.Model(&types.User{}).Where("id = ? AND suspended = ?", id, true).Count(&count).Error
+	return count > 0, err
+}
+
+// ListFlagged returns the flagged users, most recently flagged first
+func (r *UserRepository) ListFlagged(limit int) ([]types.User, error) {
+	var users []types.User
+	err := r.db.Where("flagged = ?", true).Order("flagged_at DESC").Limit(limit).Find(&users).Error
+	return users, err
+}
+
+// ListFlaggedInTenant returns the flagged users of a tenant, most recently flagged first
+func (r *UserRepository) ListFlaggedInTenant(tenantID uint, limit int) ([]types.User, error) {
+	var users []types.User
+	err := r.db.Where("flagged = ? AND tenant_id = ?", true, tenantID).Order("flagged_at DESC").Limit(limit).Find(&users).Error
+	return users, err
+}
+
+// SetTenant moves the user to the given tenant
+func (r *UserRepository) SetTenant(id int64, tenantID uint) error {
+	return r.db.Model(&types.User{}).Where("id = ?", id).Update("tenant_id", tenantID).Error
+}
+
+// MergeProfile moves the accounts of the player profile from into the profile to
+func (r *UserRepository) MergeProfile(from int64, to int64) error {
+	return r.db.Model(&types.User{}).
+		Where("id = ? OR profile_id = ?", from, from).
+		Update("profile_id", to).Error
+}
+
+// ProfileAccounts returns the IDs of the accounts sharing the player profile
+func (r *UserRepository) ProfileAccounts(profileID int64) ([]int64, error) {
+	var ids []int64
+	err := r.db.Model(&types.User{}).
+		Where("id = ? OR profile_id = ?", profileID, profileID).
+		Pluck("id", &ids).Error
+	return ids, err
+}
+
+// Invite records that the inviter vouched for the user, creating the user if needed.
+// It returns false if the user was invited by someone already.
+func (r *UserRepository) Invite(id int64, inviterID int64) (bool, error) {
+	now := time.Now()
+	if err := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&types.User{ID: id}).Error; err != nil {
+		return false, err
+	}
+	result := r.db.Model(&types.User{}).
+		Where("id = ? AND invited_by = ?", id, 0).
+		Updates(map[string]interface{}{
+			"invited_by": inviterID,
+			"invited_at": now,
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// CountInvitedSince returns the number of users the inviter vouched for since the given time
+func (r *UserRepository) CountInvitedSince(inviterID int64, since time.Time) (int64, error) {
+	var count int64
+	err := r.db.Model(&types.User{}).
+		Where("invited_by = ? AND invited_at >= ?", inviterID, since).
+		Count(&count).Error
+	return count, err
+}
+
+// RevokeInviter takes the right to invite users away from whoever invited the user.
+// It returns the ID of the inviter, or 0 if the user wasn't invited.
+func (r *UserRepository) RevokeInviter(id int64) (int64, error) {
+	user, err := r.Get(id)
+	if err != nil || user == nil || user.InvitedBy == 0 {
+		return 0, err
+	}
+	err = r.db.Model(&types.User{}).Where("id = ?", user.InvitedBy).Update("invites_revoked", true).Error
+	return user.InvitedBy, err
+}
+
+// Authorize allows the user to use the bot until the given time, or forever if until is nil
+func (r *UserRepository) Authorize(id int64, until *time.Time) error {
+	if err := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&types.User{ID: id}).Error; err != nil {
+		return err
+	}
+	return r.db.Model(&types.User{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"authorized":      true,
+			"authorized_till": until,
+		}).Error
+}
+
+// Import stores a user migrated from another bot and returns true if the user is new.
+// Existing users keep their data, the import only adds names, authorization and suspension.
+// Removed users stay removed.
+func (r *UserRepository) Import(user *types.User) (bool, error) {
+	var existing types.User
+	err := r.db.Unscoped().Where("id = ?", user.ID).First(&existing).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return true, r.db.Create(user).Error
+	}
+	if err != nil {
+		return false, err
+	}
+	updates := map[string]interface{}{}
+	if existing.Username == "" && user.Username != "" {
+		updates["username"] = user.Username
+	}
+	if existing.FirstName == "" && user.FirstName != "" {
+		updates["first_name"] = user.FirstName
+	}
+	if user.Authorized && !isAuthorizedUntil(&existing, user.AuthorizedTill) {
+		updates["authorized"] = true
+		updates["authorized_till"] = user.AuthorizedTill
+	}
+	if user.Suspended {
+		updates["suspended"] = true
+	}
+	if len(updates) == 0 {
+		return false, nil
+	}
+	return false, r.db.Unscoped().Model(&types.User{}).Where("id = ?", user.ID).Updates(updates).Error
+}
+
+// isAuthorizedUntil reports whether the user is already authorized at least until the given time, nil meaning forever
+func isAuthorizedUntil(user *types.User, until *time.Time) bool {
+	if !user.Authorized {
+		return false
+	}
+	return user.AuthorizedTill == nil || (until != nil && !user.AuthorizedTill.Before(*until))
+}
+
+// Deauthorize revokes the authorization of the user, deletes their API tokens and revokes the
+// tokens signed for them
+func (r *UserRepository) Deauthorize(id int64) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&types.User{}).
+			Where("id = ?", id).
+			Updates(map[string]interface{}{
+				"authorized":      false,
+				"authorized_till": nil,
+				"token_version":   gorm.Expr("token_version + 1"),
+			}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", id).Delete(&types.APIToken{}).Error
+	})
+}
+
+// TokenVersion returns the version of the tokens signed for the user, 0 if the user isn't stored
+func (r *UserRepository) TokenVersion(id int64) (int, error) {
+	var versions []int
+	err := r.db.Model(&types.User{}).Where("id = ?", id).Pluck("token_version", &versions).Error
+	if err != nil || len(versions) == 0 {
+		return 0, err
+	}
+	return versions[0], nil
+}
+
+// RevokeTokens bumps the token version of the user, so that the tokens signed for them stop working
+func (r *UserRepository) RevokeTokens(id int64) error {
+	return r.db.Model(&types.User{}).Where("id = ?", id).Update("token_version", gorm.Expr("token_version + 1")).Error
+}
+
+// ListExpired returns the authorized users whose authorization ended before the given time
+func (r *UserRepository) ListExpired(before time.Time, limit int) ([]types.User, error) {
+	var users []types.User
+	err := r.db.Where("authorized = ? AND authorized_till IS NOT NULL AND authorized_till < ?", true, before).
+		Limit(limit).
+		Find(&users).Error
+	return users, err
+}
+
+// SetReferral records the referral code the user came with, unless one was recorded already
+func (r *UserRepository) SetReferral(id int64, code string) error {
+	return r.db.Model(&types.User{}).
+		Where("id = ? AND (referral IS NULL OR referral = '')", id).
+		Update("referral", code).Error
+}
+
+// ReferralStats returns the referral codes that brought the most users since the given time
+func (r *UserRepository) ReferralStats(since time.Time, limit int) ([]types.ReferralStats, error) {
+	var stats []types.ReferralStats
+	err := r.db.Raw(`SELECT referral AS code, COUNT(*) AS users,
+		SUM(CASE WHEN EXISTS (SELECT 1 FROM links WHERE links.user_id = users.id) THEN 1 ELSE 0 END) AS active
+		FROM users WHERE referral != '' AND deleted_at IS NULL AND created_at >= ?
+		GROUP BY referral ORDER BY users DESC LIMIT ?`, since, limit).
+		Scan(&stats).Error
+	return stats, err
+}
+
+// ListCreated returns the users who started the bot within the time range, optionally only those
+// with an authorization that didn't expire
+func (r *UserRepository) ListCreated(from time.Time, to time.Time, authorizedOnly bool) ([]types.User, error) {
+	var users []types.User
+	query := r.db.Where("created_at >= ? AND created_at < ?", from, to)
+	if authorizedOnly {
+		query = query.Where("authorized = ? AND (authorized_till IS NULL OR authorized_till > ?)", true, time.Now())
+	}
+	err := query.Order("created_at").Find(&users).Error
+	return users, err
+}
+
+// Remove soft-deletes the user. Removed users are hidden from all lists and can't use the bot
+// until they are restored or purged.
+func (r *UserRepository) Remove(id int64) error {
+	result := r.db.Where("id = ?", id).Delete(&types.User{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Restore undoes the removal of the user if it happened after the given time.
+// It returns false if the user isn't removed or was removed before.
+func (r *UserRepository) Restore(id int64, removedAfter time.Time) (bool, error) {
+	result := r.db.Unscoped().Model(&types.User{}).
+		Where("id = ? AND deleted_at IS NOT NULL AND deleted_at >= ?", id, removedAfter).
+		Update("deleted_at", nil)
+	return result.RowsAffected > 0, result.Error
+}
+
+// IsRemoved reports whether the user was removed with /removeuser
+func (r *UserRepository) IsRemoved(id int64) (bool, error) {
+	var count int64
+	err := r.db.Unscoped().Model(&types.User{}).Where("id = ? AND deleted_at IS NOT NULL", id).Count(&count).Error
+	return count > 0, err
+}
+
+// ListRemoved returns the users removed after the given time, most recently removed first
+func (r *UserRepository) ListRemoved(after time.Time, limit int) ([]types.User, error) {
+	var users []types.User
+	err := r.db.Unscoped().
+		Where("deleted_at IS NOT NULL AND deleted_at >= ?", after).
+		Order("deleted_at DESC").
+		Limit(limit).
+		Find(&users).Error
+	return users, err
+}
+
+// PurgeRemoved permanently deletes the users removed before the given time, with their links,
+// short links, shares, command uses, notes and tags. It returns the number of purged users.
+func (r *UserRepository) PurgeRemoved(before time.Time) (int64, error) {
+	var ids []int64
+	err := r.db.Unscoped().Model(&types.User{}).
+		Where("deleted_at IS NOT NULL AND deleted_at < ?", before).
+		Pluck("id", &ids).Error
+	if err != nil || len(ids) == 0 {
+		return 0, err
+	}
+	err = r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("user_id IN ? OR EXISTS (SELECT 1 FROM links WHERE links.tenant_id = link_shares.tenant_id AND links.message_id = link_shares.message_id AND links.user_id IN ?)", ids, ids).
+			Delete(&types.LinkShare{}).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Where("user_id IN ?", ids).Delete(&types.Link{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id IN ?", ids).Delete(&types.ShortLink{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id IN ?", ids).Delete(&types.CommandUse{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id IN ?", ids).Delete(&types.UserNote{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id IN ?", ids).Delete(&types.UserTag{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Where("id IN ?", ids).Delete(&types.User{}).Error
+	})
+	return int64(len(ids)), err
+}
+
+// AdvanceOnboarding moves the user from one onboarding step to the next.
+// It returns false if the user isn't at the from step anymore.
+func (r *UserRepository) AdvanceOnboarding(id int64, from string, to string) (bool, error) {
+	result := r.db.Model(&types.User{}).
+		Where("id = ? AND onboarding = ?", id, from).
+		Update("onboarding", to)
+	return result.RowsAffected > 0, result.Error
+}
+
+// SetLiveViews sets whether the view count in the link replies of the user is updated
+func (r *UserRepository) SetLiveViews(id int64, enabled bool) error {
+	return r.db.Model(&types.User{}).Where("id = ?", id).Update("live_views", enabled).Error
+}
+
+// SetReuseLinks sets whether files the user sends again get their existing link
+func (r *UserRepository) SetReuseLinks(id int64, enabled bool) error {
+	return r.db.Model(&types.User{}).Where("id = ?", id).Update("reuse_links", enabled).Error
+}
+
+// MarkSeen stores the last interaction of the users, keeping later times already stored
+func (r *UserRepository) MarkSeen(seen map[int64]time.Time) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for id, at := range seen {
+			err := tx.Model(&types.User{}).
+				Where("id = ? AND (last_seen IS NULL OR last_seen < ?)", id, at).
+				UpdateColumn("last_seen", at).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// MarkLinkOwnerSeen stores the access of a link as the last interaction of the user who generated it
+func (r *UserRepository) MarkLinkOwnerSeen(tenantID uint, messageID int, at time.Time) error {
+	return r.db.Model(&types.User{}).
+		Where("id = (SELECT user_id FROM links WHERE tenant_id = ? AND message_id = ?)", tenantID, messageID).
+		Where("(last_seen IS NULL OR last_seen < ?)", at).
+		UpdateColumn("last_seen", at).Error
+}
+
+// CountActiveSince counts the users seen since the given time
+func (r *UserRepository) CountActiveSince(since time.Time) (int64, error) {
+	var count int64
+	err := r.db.Model(&types.User{}).Where("last_seen >= ?", since).Count(&count).Error
+	return count, err
+}
+
+// ListInactive returns the users not seen since the given time, least recently seen first, and
+// their total count. Users who were never seen count from when they first used the bot.
+func (r *UserRepository) ListInactive(before time.Time, limit int) ([]types.User, int64, error) {
+	var count int64
+	query := r.db.Model(&types.User{}).Where("COALESCE(last_seen, created_at) < ?", before)
+	if err := query.Count(&count).Error; err != nil {
+		return nil, 0, err
+	}
+	var users []types.User
+	err := r.db.Where("COALESCE(last_seen, created_at) < ?", before).
+		Order("COALESCE(last_seen, created_at)").
+		Limit(limit).
+		Find(&users).Error
+	return users, count, err
+}
+
+// ListRecipients returns the IDs of the users who aren't suspended, all of them or only
+// the authorized or unauthorized ones, for messages sent to every user
+func (r *UserRepository) ListRecipients(audience string) ([]int64, error) {
+	query := r.db.Model(&types.User{}).Where("suspended = ?", false)
+	switch audience {
+	case types.AudienceAuthorized:
+		query = query.Where("authorized = ?", true)
+	case types.AudienceUnauthorized:
+		query = query.Where("authorized = ?", false)
+	}
+	var ids []int64
+	err := query.Pluck("id", &ids).Error
+	return ids, err
+}
+
+// userSortColumns are the columns users can be sorted by in ListUsers
+var userSortColumns = map[string]string{
+	"joined": "created_at",
+	"seen":   "last_seen",
+	"name":   "LOWER(COALESCE(NULLIF(username, ''), first_name))",
+	"id":     "id",
+}
+
+// UserFilter selects the users listed with /listusers
+type UserFilter struct {
+	TenantID     *uint   // only members of the tenant
+	Authorized   *bool   // only users with or without a current authorization
+	Suspended    *bool   // only suspended or not suspended users
+	IDs          []int64 // only these users, e.g. the admins
+	ExcludeIDs   []int64 // not these users
+	JoinedAfter  *time.Time
+	JoinedBefore *time.Time
+	SeenAfter    *time.Time
+	SeenBefore   *time.Time // users who were never seen match too
+	Name         string     // part of the username or first name
+	Tag          string     // only users tagged with /tag
+	Sort         string     // joined, seen, name or id, with a - prefix for descending order; newest first if empty
+}
+
+// ValidSort reports whether users can be sorted by the key
+func ValidSort(sort string) bool {
+	_, ok := userSortColumns[strings.TrimPrefix(sort, "-")]
+	return ok
+}
+
+func (f UserFilter) apply(query *gorm.DB) *gorm.DB {
+	if f.TenantID != nil {
+		query = query.Where("tenant_id = ?", *f.TenantID)
+	}
+	if f.Authorized != nil {
+		authorized := "(authorized = ? AND (authorized_till IS NULL OR authorized_till > ?))"
+		if !*f.Authorized {
+			authorized = "NOT " + authorized
+		}
+		query = query.Where(authorized, true, time.Now())
+	}
+	if f.Suspended != nil {
+		query = query.Where("suspended = ?", *f.Suspended)
+	}
+	if f.IDs != nil {
+		query = query.Where("id IN ?", f.IDs)
+	}
+	if len(f.ExcludeIDs) > 0 {
+		query = query.Where("id NOT IN ?", f.ExcludeIDs)
+	}
+	if f.JoinedAfter != nil {
+		query = query.Where("created_at > ?", *f.JoinedAfter)
+	}
+	if f.JoinedBefore != nil {
+		query = query.Where("created_at < ?", *f.JoinedBefore)
+	}
+	if f.SeenAfter != nil {
+		query = query.Where("last_seen > ?", *f.SeenAfter)
+	}
+	if f.SeenBefore != nil {
+		query = query.Where("(last_seen IS NULL OR last_seen < ?)", *f.SeenBefore)
+	}
+	if f.Tag != "" {
+		query = query.Where("id IN (SELECT user_id FROM user_tags WHERE tag = ?)", f.Tag)
+	}
+	if f.Name != "" {
+		name := "%" + strings.ToLower(f.Name) + "%"
+		query = query.Where("(LOWER(username) LIKE ? OR LOWER(first_name) LIKE ?)", name, name)
+	}
+	return query
+}
+
+func (f UserFilter) order() string {
+	column, ok := userSortColumns[strings.TrimPrefix(f.Sort, "-")]
+	if !ok {
+		return "created_at DESC"
+	}
+	if strings.HasPrefix(f.Sort, "-") {
+		return column + " DESC"
+	}
+	return column
+}
+
+// ListUsers returns a page of the users matching the filter and their total count
+func (r *UserRepository) ListUsers(filter UserFilter, offset int, limit int) ([]types.User, int64, error) {
+	var count int64
+	if err := filter.apply(r.db.Model(&types.User{})).Count(&count).Error; err != nil {
+		return nil, 0, err
+	}
+	var users []types.User
+	err := filter.apply(r.db).
+		Order(filter.order()).
+		Order("id").
+		Offset(offset).
+		Limit(limit).
+		Find(&users).Error
+	return users, count, err
+}
@@ -0,0 +1,42 @@
+package database
+
+import (
+	"EverythingSuckz/fsb/internal/types"
+	"testing"
+	"time"
+)
+
+func TestDeauthorizeRevokesAPITokens(t *testing.T) {
+	openTest(t)
+	users := GetUserRepository()
+	if err := users.Touch(7, "someone", "Some"); err != nil {
+		t.Fatal(err)
+	}
+	until := time.Now().Add(time.Hour)
+	if err := users.Authorize(7, &until); err != nil {
+		t.Fatal(err)
+	}
+	tokens := GetAPITokenRepository()
+	for i, userID := range []int64{7, 7, 8} {
+		token := &types.APIToken{UserID: userID, Name: "script", Digest: string(rune('a' + i)), Scopes: "upload"}
+		if err := tokens.Create(token); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := users.Deauthorize(7); err != nil {
+		t.Fatal(err)
+	}
+	user, err := users.Get(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Authorized || user.AuthorizedTill != nil {
+		t.Errorf("user is still authorized till %v", user.AuthorizedTill)
+	}
+	if left, _ := tokens.List(7); len(left) != 0 {
+		t.Errorf("deauthorized user has %d API tokens left", len(left))
+	}
+	if left, _ := tokens.List(8); len(left) != 1 {
+		t.Errorf("another user has %d API tokens left, expected 1", len(left))
+	}
+}
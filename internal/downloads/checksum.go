@@ -0,0 +1,100 @@
+package downloads
+
+import (
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/tenant"
+	"EverythingSuckz/fsb/internal/types"
+	"EverythingSuckz/fsb/internal/utils"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+var (
+	checksumMu sync.Mutex
+	// computing holds the callbacks waiting for the checksums being computed, by link
+	computing = make(map[string][]func(string, error))
+	// checksumSlot lets one checksum be computed at a time, they download the whole file
+	checksumSlot = make(chan struct{}, 1)
+)
+
+// RecordChecksum stores the SHA-256 of the file of the link, after it was fetched completely
+// by /save, a stream or a checksum computation
+func RecordChecksum(tenantID uint, messageID int, sum string) {
+	linkRepository := database.GetLinkRepository()
+	if linkRepository == nil {
+		return
+	}
+	if err := linkRepository.SetChecksum(tenantID, messageID, sum); err != nil {
+		log.Error("Failed to store checksum", zap.Error(err), zap.Int("messageID", messageID))
+	}
+}
+
+// Checksum returns the SHA-256 of the file of the link if it's known. Otherwise it computes it
+// in the background, stores it and calls done with it, if done isn't nil.
+func Checksum(link *types.Link, done func(sum string, err error)) (string, bool) {
+	if link.Checksum != "" {
+		return link.Checksum, true
+	}
+	key := link.StorageKey()
+	checksumMu.Lock()
+	defer checksumMu.Unlock()
+	waiting, running := computing[key]
+	if done != nil {
+		waiting = append(waiting, done)
+	}
+	computing[key] = waiting
+	if !running {
+		go computeChecksum(link.TenantID, link.MessageID, key)
+	}
+	return "", false
+}
+
+func computeChecksum(tenantID uint, messageID int, key string) {
+	checksumSlot <- struct{}{}
+	sum, err := hashFile(tenantID, messageID)
+	<-checksumSlot
+	if err == nil {
+		RecordChecksum(tenantID, messageID, sum)
+	} else {
+		log.Warn("Failed to compute checksum", zap.Error(err), zap.Int("messageID", messageID))
+	}
+	checksumMu.Lock()
+	waiting := computing[key]
+	delete(computing, key)
+	checksumMu.Unlock()
+	for _, done := range waiting {
+		done(sum, err)
+	}
+}
+
+// hashFile downloads the file of the link and returns its SHA-256
+func hashFile(tenantID uint, messageID int) (string, error) {
+	if source == nil {
+		return "", errors.New("the bot isn't running")
+	}
+	ctx := context.Background()
+	file, api, err := source.File(ctx, tenant.LogChannel(tenantID), messageID)
+	if err != nil {
+		return "", err
+	}
+	if file.FileSize == 0 {
+		return "", errors.New("photos have no checksum, send them as files")
+	}
+	reader, err := utils.NewTelegramReader(ctx, api, file.Location, 0, file.FileSize-1, file.FileSize)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+	hasher := sha256.New()
+	if n, err := io.CopyN(hasher, reader, file.FileSize); err != nil {
+		return "", fmt.Errorf("read %d of %d bytes: %w", n, file.FileSize, err)
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
@@ -0,0 +1,373 @@
+// Package downloads saves files from the log channels to SAVE_DIR on the server, for /save.
+// Files are queued as jobs, which SAVE_WORKERS workers run and retry when they fail. They're
+// written next to their final path with a .part suffix, hashed while writing and verified
+// against the SHA-256 hashes Telegram keeps of the file before renaming them. It also keeps the
+// SHA-256 of the files of links, see Checksum.
+package downloads
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/jobs"
+	"EverythingSuckz/fsb/internal/tenant"
+	"EverythingSuckz/fsb/internal/types"
+	"EverythingSuckz/fsb/internal/utils"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gotd/td/tg"
+	"go.uber.org/zap"
+)
+
+// queueSize is the number of files that can wait for a worker
+const queueSize = 1000
+
+// jobKind is the kind of the jobs that save files
+const jobKind = "save"
+
+// maxAttempts is how often saving a file is tried, it's retried after a minute, then 2 minutes
+const maxAttempts = 3
+
+// progressInterval is how often the progress of a download is reported
+const progressInterval = 5 * time.Second
+
+// pauseInterval is how often paused workers check whether they may go on
+const pauseInterval = 30 * time.Second
+
+var (
+	// ErrDisabled is returned when SAVE_DIR isn't set
+	ErrDisabled = errors.New("saving files on the server is not enabled")
+	// ErrQueueFull is returned when too many files are waiting
+	ErrQueueFull = errors.New("too many files are waiting to be saved, try again later")
+	// ErrNoSpace is returned when saving the file would leave less than SAVE_MIN_FREE on the disk
+	ErrNoSpace = errors.New("not enough free disk space")
+	// ErrMismatch is returned when the saved file doesn't match the hashes of Telegram
+	ErrMismatch = errors.New("the saved file doesn't match the file on Telegram")
+
+	errPhoto = errors.New("photos can't be saved, send them as files")
+)
+
+// FileSource finds the files of log channel messages and the API to download them with, and
+// tells users about the files that were saved after a restart, like bot.Live
+type FileSource interface {
+	File(ctx context.Context, channelID int64, messageID int) (*types.File, *tg.Client, error)
+	Notify(userID int64, message string, markup tg.ReplyMarkupClass) error
+}
+
+// State is the stage a job is in
+type State int
+
+const (
+	Queued State = iota
+	Downloading
+	Verifying
+	Done
+	Failed
+)
+
+// Status is reported to the job while it runs
+type Status struct {
+	State    State
+	Written  int64 // bytes saved so far
+	Total    int64
+	Path     string // path of the saved file, relative to SAVE_DIR
+	SHA256   string
+	Verified bool // the file matched the hashes of Telegram, which doesn't have them for every file
+	Err      error
+}
+
+// Job is a file to save
+type Job struct {
+	TenantID  uint
+	ChannelID int64 // channel or supergroup of the message, the log channel of the tenant if 0
+	MessageID int
+	UserID    int64
+	// Report is called from the worker when the status of the job changes and every few
+	// seconds while it downloads, the worker waits for it. It's lost when the bot restarts,
+	// the user is then told about the result with a message.
+	Report func(Status) `json:"-"`
+}
+
+var (
+	log     *zap.Logger
+	source  FileSource
+	enabled bool
+	paused  atomic.Bool
+
+	reportersMu sync.Mutex
+	reporters   = make(map[uint]func(Status))
+)
+
+// Start registers the workers if SAVE_DIR is set
+func Start(l *zap.Logger, s FileSource) {
+	log = l.Named("downloads")
+	source = s
+	if config.ValueOf.SaveDir == "" {
+		return
+	}
+	if err := os.MkdirAll(config.ValueOf.SaveDir, 0o755); err != nil {
+		log.Error("Failed to create SAVE_DIR, saving files is disabled", zap.Error(err))
+		return
+	}
+	workers := max(config.ValueOf.SaveWorkers, 1)
+	jobs.Register(jobKind, jobs.Policy{Workers: workers, MaxAttempts: maxAttempts, Backoff: time.Minute, MaxBackoff: 10 * time.Minute}, work)
+	enabled = true
+	log.Info("Saving files", zap.String("dir", config.ValueOf.SaveDir), zap.Int("workers", workers))
+}
+
+// Enabled reports whether files can be saved
+func Enabled() bool {
+	return enabled
+}
+
+// Waiting returns the number of files waiting for a worker
+func Waiting() int {
+	return jobs.Waiting(jobKind)
+}
+
+// Enqueue queues the job
+func Enqueue(job *Job) error {
+	if !Enabled() {
+		return ErrDisabled
+	}
+	if Waiting() >= queueSize {
+		return ErrQueueFull
+	}
+	// the reporter is set before the job can run
+	reportersMu.Lock()
+	defer reportersMu.Unlock()
+	id, err := jobs.Enqueue(jobKind, job)
+	if err != nil {
+		return err
+	}
+	if job.Report != nil {
+		reporters[id] = job.Report
+	}
+	return nil
+}
+
+// reporter returns the Report of the job, or one that tells the user about the result if it
+// was lost with a restart
+func reporter(id uint, job *Job) func(Status) {
+	reportersMu.Lock()
+	defer reportersMu.Unlock()
+	if report, ok := reporters[id]; ok {
+		return report
+	}
+	return func(status Status) {
+		var message string
+		switch status.State {
+		case Done:
+			message = fmt.Sprintf("✅ Saved message %d to %s (%s)\n\nSHA-256: %s", job.MessageID, status.Path, utils.FormatFileSizeShort(status.Total), status.SHA256)
+		case Failed:
+			message = fmt.Sprintf("❌ Failed to save message %d: %s", job.MessageID, status.Err.Error())
+		default:
+			return
+		}
+		if err := source.Notify(job.UserID, message, nil); err != nil {
+			log.Warn("Failed to tell user about saved file", zap.Error(err))
+		}
+	}
+}
+
+// Pause stops the workers from starting to save files, the ones being saved are finished
+func Pause() {
+	paused.Store(true)
+}
+
+// Resume lets the workers save the files that waited while they were paused
+func Resume() {
+	paused.Store(false)
+}
+
+// Paused reports whether the workers are paused
+func Paused() bool {
+	return paused.Load()
+}
+
+// work saves the file of a job. Failures are reported once the job failed its last attempt.
+func work(_ context.Context, queued *types.Job) error {
+	var job Job
+	if err := jobs.Decode(queued, &job); err != nil {
+		return jobs.Permanent(err)
+	}
+	job.Report = reporter(queued.ID, &job)
+	for paused.Load() {
+		time.Sleep(pauseInterval)
+	}
+	status := save(&job)
+	if status.Err != nil {
+		log.Warn("Failed to save file", zap.Int("messageID", job.MessageID), zap.Int("attempt", queued.Attempts), zap.Error(status.Err))
+		if !jobs.LastAttempt(queued) && !errors.Is(status.Err, errPhoto) {
+			status.State = Queued
+			job.Report(status)
+			return status.Err
+		}
+		status.State = Failed
+		job.Report(status)
+		forget(queued.ID)
+		return jobs.Permanent(status.Err)
+	}
+	status.State = Done
+	log.Info("Saved file", zap.String("path", status.Path), zap.Int64("size", status.Total), zap.Bool("verified", status.Verified))
+	job.Report(status)
+	forget(queued.ID)
+	return nil
+}
+
+// forget drops the Report of a finished job
+func forget(id uint) {
+	reportersMu.Lock()
+	defer reportersMu.Unlock()
+	delete(reporters, id)
+}
+
+// save downloads the file of the job into SAVE_DIR
+func save(job *Job) Status {
+	ctx := context.Background()
+	channelID := job.ChannelID
+	if channelID == 0 {
+		channelID = tenant.LogChannel(job.TenantID)
+	}
+	file, api, err := source.File(ctx, channelID, job.MessageID)
+	if err != nil {
+		return Status{Err: err}
+	}
+	status := Status{State: Downloading, Total: file.FileSize}
+	if file.FileSize == 0 {
+		status.Err = errPhoto
+		return status
+	}
+	if free, _, ok := utils.DiskSpace(config.ValueOf.SaveDir); ok && free-file.FileSize < int64(config.ValueOf.SaveMinFree) {
+		status.Err = fmt.Errorf("%w: %s free, %s needed", ErrNoSpace, utils.FormatFileSizeShort(free), utils.FormatFileSizeShort(file.FileSize+int64(config.ValueOf.SaveMinFree)))
+		return status
+	}
+	relative, err := fileName(job, file)
+	if err != nil {
+		status.Err = err
+		return status
+	}
+	path, err := reserve(relative)
+	if err != nil {
+		status.Err = err
+		return status
+	}
+	status.Path, _ = filepath.Rel(config.ValueOf.SaveDir, path)
+	job.Report(status)
+	sum, err := download(ctx, job, api, file, path+".part", &status)
+	if err != nil {
+		os.Remove(path + ".part")
+		os.Remove(path)
+		status.Err = err
+		return status
+	}
+	status.SHA256 = sum
+	status.State = Verifying
+	job.Report(status)
+	status.Verified, err = verify(ctx, api, file, path+".part")
+	if err != nil {
+		os.Remove(path + ".part")
+		os.Remove(path)
+		status.Err = err
+		return status
+	}
+	if err := os.Rename(path+".part", path); err != nil {
+		os.Remove(path + ".part")
+		os.Remove(path)
+		status.Err = err
+		return status
+	}
+	if job.ChannelID == 0 {
+		RecordChecksum(job.TenantID, job.MessageID, status.SHA256)
+	}
+	return status
+}
+
+// download writes the file to the path and returns its SHA-256
+func download(ctx context.Context, job *Job, api *tg.Client, file *types.File, path string, status *Status) (string, error) {
+	out, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err != nil {
+		return "", err
+	}
+	defer out.Close()
+	reader, err := utils.NewTelegramReader(ctx, api, file.Location, 0, file.FileSize-1, file.FileSize)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+	hasher := sha256.New()
+	progress := &progressWriter{job: job, status: status, last: time.Now()}
+	if _, err := io.CopyN(io.MultiWriter(out, hasher, progress), reader, file.FileSize); err != nil {
+		return "", err
+	}
+	if err := out.Close(); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// progressWriter reports the bytes written every progressInterval
+type progressWriter struct {
+	job    *Job
+	status *Status
+	last   time.Time
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	w.status.Written += int64(len(p))
+	if time.Since(w.last) >= progressInterval {
+		w.last = time.Now()
+		w.job.Report(*w.status)
+	}
+	return len(p), nil
+}
+
+// verify compares the saved file with the SHA-256 hashes Telegram keeps of its parts. It
+// returns false without an error if Telegram has no hashes for the file.
+func verify(ctx context.Context, api *tg.Client, file *types.File, path string) (bool, error) {
+	saved, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer saved.Close()
+	var buf []byte
+	hasher := sha256.New()
+	for offset := int64(0); offset < file.FileSize; {
+		hashes, err := api.UploadGetFileHashes(ctx, &tg.UploadGetFileHashesRequest{Location: file.Location, Offset: offset})
+		if err != nil || len(hashes) == 0 {
+			if offset == 0 {
+				log.Debug("No file hashes from Telegram", zap.Error(err))
+				return false, nil
+			}
+			return false, err
+		}
+		for _, part := range hashes {
+			if part.Offset != offset || part.Limit <= 0 {
+				return false, fmt.Errorf("unexpected file hash at %d", part.Offset)
+			}
+			if cap(buf) < part.Limit {
+				buf = make([]byte, part.Limit)
+			}
+			n, err := saved.ReadAt(buf[:part.Limit], part.Offset)
+			if err != nil && !errors.Is(err, io.EOF) {
+				return false, err
+			}
+			hasher.Reset()
+			hasher.Write(buf[:n])
+			if !bytes.Equal(hasher.Sum(nil), part.Hash) {
+				return false, fmt.Errorf("%w at byte %d", ErrMismatch, part.Offset)
+			}
+			offset += int64(part.Limit)
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,91 @@
+package downloads
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/types"
+	"EverythingSuckz/fsb/internal/utils"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fileName returns the path of the file relative to SAVE_DIR from SAVE_NAME_TEMPLATE. The
+// placeholders are {name} (named by FILE_NAME_TEMPLATE), {base} (the name without extension), {ext}, {kind} (video, audio,
+// image or document), {id} (the message ID), {user} and {date}. Slashes separate folders.
+func fileName(job *Job, file *types.File) (string, error) {
+	name := utils.FileName(file, job.MessageID)
+	ext := path.Ext(name)
+	kind, _, _ := strings.Cut(file.MimeType, "/")
+	if kind != "video" && kind != "audio" && kind != "image" {
+		kind = "document"
+	}
+	replacer := strings.NewReplacer(
+		"{name}", name,
+		"{base}", strings.TrimSuffix(name, ext),
+		"{ext}", strings.TrimPrefix(ext, "."),
+		"{kind}", kind,
+		"{id}", strconv.Itoa(job.MessageID),
+		"{user}", strconv.FormatInt(job.UserID, 10),
+		"{date}", time.Now().Format("2006-01-02"),
+	)
+	var parts []string
+	for _, part := range strings.Split(replacer.Replace(config.ValueOf.SaveNameTemplate), "/") {
+		// names come from the senders, they can't leave SAVE_DIR
+		if part = sanitize(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	if len(parts) == 0 {
+		return "", errors.New("SAVE_NAME_TEMPLATE gives an empty file name")
+	}
+	return filepath.Join(parts...), nil
+}
+
+func sanitize(part string) string {
+	part = strings.Map(func(r rune) rune {
+		switch r {
+		case '\\', ':', '*', '?', '"', '<', '>', '|':
+			return '_'
+		}
+		if r < 32 {
+			return -1
+		}
+		return r
+	}, part)
+	part = strings.Trim(part, " ")
+	if strings.Trim(part, ".") == "" {
+		return ""
+	}
+	return part
+}
+
+// reserve creates an empty file at the path in SAVE_DIR, or at the path with a number added
+// if a file is already there, so that saving a file never replaces another one. It returns
+// the absolute path of the created file.
+func reserve(relative string) (string, error) {
+	full := filepath.Join(config.ValueOf.SaveDir, relative)
+	if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+		return "", err
+	}
+	ext := filepath.Ext(full)
+	for i := 1; i < 1000; i++ {
+		candidate := full
+		if i > 1 {
+			candidate = fmt.Sprintf("%s (%d)%s", strings.TrimSuffix(full, ext), i, ext)
+		}
+		file, err := os.OpenFile(candidate, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		return candidate, file.Close()
+	}
+	return "", fmt.Errorf("too many files named %s", relative)
+}
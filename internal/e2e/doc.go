@@ -0,0 +1,10 @@
+// Package e2e checks the paths users take across the bot and the web server, from /start and
+// authorization to generating a link and streaming it. The bot's command handlers run on the
+// gotgproto dispatcher and the web server on a local port, both against the in-memory Telegram
+// of tgfake, so nothing connects to Telegram. They don't run against Telegram's test DCs, which
+// need an api_id and a signed in test account in CI, nor replay recorded MTProto traffic, which
+// is encrypted with the auth key of the session it was recorded with. The MTProto layer below
+// the tg.Client is left to gotd. The tests are only built with the e2e build tag:
+//
+//	go test -tags e2e ./internal/e2e
+package e2e
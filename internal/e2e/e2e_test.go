@@ -0,0 +1,353 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/commands"
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/routes"
+	"EverythingSuckz/fsb/internal/testenv"
+	"EverythingSuckz/fsb/internal/tgfake"
+	"EverythingSuckz/fsb/internal/utils"
+	"EverythingSuckz/fsb/internal/webauth"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/celestix/gotgproto/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/gotd/td/tg"
+	"go.uber.org/zap"
+)
+
+const (
+	logChannelID = testenv.LogChannelID
+	botID        = 100
+	adminID      = 1
+	userID       = 7
+	groupID      = 2000
+	fileSize     = 8*1024*1024 + 123 // not a multiple of the chunk size, so that the last chunk is partial
+)
+
+// harness is the bot and the web server under test, started by testenv.Start, and the fake
+// Telegram they talk to. They share the config and the repositories of the packages, so the
+// tests don't run in parallel.
+type harness struct {
+	*testenv.Server
+	bot *dispatcher.NativeDispatcher
+}
+
+func TestMain(m *testing.M) {
+	utils.Logger = zap.NewNop()
+	config.Runtime.AddAdmin(adminID)
+	os.Exit(m.Run())
+}
+
+func start(t *testing.T) *harness {
+	t.Helper()
+	server := testenv.Start(t, func(router *gin.Engine, telegram *tgfake.Telegram) {
+		routes.Load(zap.NewNop(), router, telegram)
+	})
+	bot := dispatcher.NewNativeDispatcher(false, false, nil, func(_ *ext.Context, _ *ext.Update, stack string) {
+		t.Errorf("a handler panicked: %s", stack)
+	}, storage.NewPeerStorage(nil, true))
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	bot.Initialize(ctx, cancel, server.Telegram.Client(), &tg.User{ID: botID, Bot: true, Username: "fsb_e2e_bot"})
+	commands.Load(zap.NewNop(), bot)
+	return &harness{Server: server, bot: bot}
+}
+
+// send hands the update to the bot and returns the messages it sent while handling it
+func (h *harness) send(t *testing.T, update *tg.Updates) []tgfake.Message {
+	t.Helper()
+	before := len(h.Telegram.Sent())
+	if err := h.bot.Handle(context.Background(), update); err != nil && !errors.Is(err, dispatcher.EndGroups) {
+		t.Fatalf("failed to handle the update: %v", err)
+	}
+	return h.Telegram.Sent()[before:]
+}
+
+// command sends the text as the user and returns the first reply of the bot
+func (h *harness) command(t *testing.T, from int64, text string) tgfake.Message {
+	t.Helper()
+	replies := h.send(t, h.Telegram.SendText(from, text))
+	if len(replies) == 0 {
+		t.Fatalf("%s of user %d got no reply", text, from)
+	}
+	return replies[0]
+}
+
+// confirm presses the confirm button of the question and returns the message it was edited to
+func (h *harness) confirm(t *testing.T, from int64, question tgfake.Message) tgfake.Message {
+	t.Helper()
+	markup, ok := question.Markup.(*tg.ReplyInlineMarkup)
+	if !ok || len(markup.Rows) == 0 {
+		t.Fatalf("%q has no buttons", question.Text)
+	}
+	button, ok := markup.Rows[0].Buttons[0].(*tg.KeyboardButtonCallback)
+	if !ok || !strings.HasPrefix(string(button.Data), "confirm:yes:") {
+		t.Fatalf("%q has no confirm button", question.Text)
+	}
+	h.send(t, h.Telegram.PressButton(from, question, button.Data))
+	for _, message := range h.Telegram.Sent() {
+		if message.UserID == question.UserID && message.ID == question.ID {
+			return message
+		}
+	}
+	t.Fatalf("the question %d disappeared", question.ID)
+	return tgfake.Message{}
+}
+
+// link sends the file as the user and returns the URL in the reply of the bot
+func (h *harness) link(t *testing.T, from int64, fileName string, size int64) string {
+	t.Helper()
+	replies := h.send(t, h.Telegram.SendFile(from, fileName, "application/octet-stream", size, tgfake.Pattern(size)))
+	for _, reply := range replies {
+		for _, line := range strings.Split(reply.Text, "\n") {
+			if strings.HasPrefix(line, h.URL+"/stream/") {
+				return line
+			}
+		}
+	}
+	t.Fatalf("the file of user %d got no link: %v", from, replies)
+	return ""
+}
+
+// groupReplies hands the update of a group message to the bot and returns its replies in the
+// group, checking they were sent to the topic of the message
+func (h *harness) groupReplies(t *testing.T, update *tg.Updates, topicID int) []tgfake.Message {
+	t.Helper()
+	replies := h.send(t, update)
+	message := update.Updates[0].(*tg.UpdateNewChannelMessage).Message.(*tg.Message)
+	for _, reply := range replies {
+		if reply.ChatID != groupID || reply.ReplyTo != message.ID {
+			t.Errorf("%q wasn't a reply to message %d in the group", reply.Text, message.ID)
+		}
+		if topicID > 1 && reply.TopicID != topicID {
+			t.Errorf("%q was sent to topic %d, expected topic %d", reply.Text, reply.TopicID, topicID)
+		}
+	}
+	return replies
+}
+
+// get requests the URL with the headers, given as name and value pairs, and returns the
+// response with its body read
+func get(t *testing.T, client *http.Client, rawURL string, header ...string) (*http.Response, []byte) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res, body
+}
+
+// expected returns the bytes of a synthetic file of the size from start to end
+func expected(size, start, end int64) []byte {
+	data := make([]byte, end-start+1)
+	tgfake.Pattern(size).ReadAt(data, start)
+	return data
+}
+
+func expectReply(t *testing.T, reply tgfake.Message, text string) {
+	t.Helper()
+	if !strings.Contains(reply.Text, text) {
+		t.Fatalf("got reply %q, expected it to contain %q", reply.Text, text)
+	}
+}
+
+func expectStatus(t *testing.T, res *http.Response, status int) {
+	t.Helper()
+	if res.StatusCode != status {
+		t.Fatalf("%s: status %d, expected %d", res.Request.URL.Path, res.StatusCode, status)
+	}
+}
+
+func TestStart(t *testing.T) {
+	h := start(t)
+	expectReply(t, h.command(t, userID, "/start"), "Send it my way")
+
+	config.Runtime.SetPrivateMode(true)
+	expectReply(t, h.command(t, userID, "/start"), "You are not allowed to use this bot.")
+	expectReply(t, h.command(t, adminID, "/start"), "Send it my way")
+}
+
+func TestAuthorization(t *testing.T) {
+	h := start(t)
+	config.Runtime.SetPrivateMode(true)
+	expectReply(t, h.command(t, userID, "/authorize 8"), "only available to admins")
+
+	expectReply(t, h.command(t, adminID, fmt.Sprintf("/authorize %d", userID)), fmt.Sprintf("User %d is authorized", userID))
+	expectReply(t, h.command(t, userID, "/start"), "Send it my way")
+
+	question := h.command(t, adminID, fmt.Sprintf("/deauthorize %d", userID))
+	expectReply(t, question, "Deauthorize user")
+	expectReply(t, h.command(t, userID, "/start"), "Send it my way")
+	expectReply(t, h.confirm(t, adminID, question), fmt.Sprintf("User %d is no longer authorized", userID))
+	expectReply(t, h.command(t, userID, "/start"), "You are not allowed to use this bot.")
+}
+
+func TestLinkGeneration(t *testing.T) {
+	h := start(t)
+	streamURL := h.link(t, userID, "e2e.bin", fileSize)
+
+	res, body := get(t, http.DefaultClient, streamURL)
+	expectStatus(t, res, http.StatusOK)
+	if !bytes.Equal(body, expected(fileSize, 0, fileSize-1)) {
+		t.Errorf("got %d bytes that differ from the file", len(body))
+	}
+	for _, r := range [][2]int64{{0, 0}, {1000, 1024*1024 + 5}, {fileSize - 200, fileSize - 1}} {
+		res, body := get(t, http.DefaultClient, streamURL, "Range", fmt.Sprintf("bytes=%d-%d", r[0], r[1]))
+		expectStatus(t, res, http.StatusPartialContent)
+		if contentRange := fmt.Sprintf("bytes %d-%d/%d", r[0], r[1], fileSize); res.Header.Get("Content-Range") != contentRange {
+			t.Errorf("Content-Range %q, expected %q", res.Header.Get("Content-Range"), contentRange)
+		}
+		if !bytes.Equal(body, expected(fileSize, r[0], r[1])) {
+			t.Errorf("range %d-%d: got %d bytes that differ from the file", r[0], r[1], len(body))
+		}
+	}
+
+	links, err := database.GetLinkRepository().ListByUsers([]int64{userID}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(links) != 1 || links[0].FileName != "e2e.bin" || links[0].FileSize != fileSize {
+		t.Errorf("stored %d links, expected the one of e2e.bin", len(links))
+	}
+}
+
+func TestLinkGenerationInPrivateMode(t *testing.T) {
+	h := start(t)
+	config.Runtime.SetPrivateMode(true)
+	replies := h.send(t, h.Telegram.SendFile(userID, "e2e.bin", "application/octet-stream", 1024, tgfake.Pattern(1024)))
+	if len(replies) != 1 {
+		t.Fatalf("got %d replies, expected the refusal", len(replies))
+	}
+	expectReply(t, replies[0], "You are not allowed to use this bot.")
+
+	h.command(t, adminID, fmt.Sprintf("/authorize %d", userID))
+	h.link(t, userID, "e2e.bin", 1024)
+}
+
+func TestInvalidHash(t *testing.T) {
+	h := start(t)
+	streamURL := h.link(t, userID, "e2e.bin", 1024)
+	parsed, err := url.Parse(streamURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := parsed.Query()
+	query.Set("hash", "000000")
+	parsed.RawQuery = query.Encode()
+	res, _ := get(t, http.DefaultClient, parsed.String())
+	expectStatus(t, res, http.StatusBadRequest)
+}
+
+func TestDeletedSource(t *testing.T) {
+	h := start(t)
+	streamURL := h.link(t, userID, "e2e.bin", 1024)
+	parsed, err := url.Parse(streamURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	messageID, err := strconv.Atoi(strings.TrimPrefix(parsed.Path, "/stream/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Telegram.DeleteMessage(logChannelID, messageID)
+	res, _ := get(t, http.DefaultClient, streamURL)
+	expectStatus(t, res, http.StatusGone)
+}
+
+func TestWebSignIn(t *testing.T) {
+	h := start(t)
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Jar: jar}
+	res, _ := get(t, client, h.URL+"/login?token=invalid")
+	expectStatus(t, res, http.StatusUnauthorized)
+
+	res, body := get(t, client, h.URL+"/login?token="+url.QueryEscape(webauth.LoginToken(userID)))
+	expectStatus(t, res, http.StatusOK)
+	if !bytes.Contains(body, []byte(strconv.Itoa(userID))) {
+		t.Errorf("the page after signing in doesn't show the user: %q", body)
+	}
+	base, _ := url.Parse(h.URL)
+	for _, cookie := range jar.Cookies(base) {
+		if cookie.Name == webauth.CookieName {
+			return
+		}
+	}
+	t.Errorf("signing in didn't set the %s cookie", webauth.CookieName)
+}
+
+// topics 500 and 900 were created by messages older than the ones the test sends
+func TestGroupTopics(t *testing.T) {
+	h := start(t)
+	sendFile := func(topicID int) []tgfake.Message {
+		return h.groupReplies(t, h.Telegram.SendGroupFile(groupID, topicID, userID, "e2e.bin", "application/octet-stream", 1024, tgfake.Pattern(1024)), topicID)
+	}
+	if replies := sendFile(500); len(replies) != 0 {
+		t.Fatalf("got %d replies without GROUP_MODE", len(replies))
+	}
+	config.ValueOf.GroupMode = true
+	t.Cleanup(func() { config.ValueOf.GroupMode = false })
+
+	replies := sendFile(500)
+	if len(replies) != 1 {
+		t.Fatalf("got %d replies to a file in a topic, expected its link", len(replies))
+	}
+	expectReply(t, replies[0], h.URL+"/stream/")
+	links, err := database.GetLinkRepository().ListByUsers([]int64{userID}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(links) != 1 || links[0].ReplyID != 0 || links[0].SourceID != 0 {
+		t.Errorf("stored %d links, expected one of the sender without messages of their private chat", len(links))
+	}
+
+	expectReply(t, h.groupReplies(t, h.Telegram.SendGroupText(groupID, 900, userID, "/enabletopic"), 900)[0], "only available to admins")
+	expectReply(t, h.groupReplies(t, h.Telegram.SendGroupText(groupID, 900, adminID, "/enabletopic"), 900)[0], "Links are generated in this topic")
+	if replies := sendFile(500); len(replies) != 0 {
+		t.Errorf("got %d replies in a topic that isn't enabled", len(replies))
+	}
+	if replies := sendFile(900); len(replies) != 1 {
+		t.Errorf("got %d replies in the enabled topic, expected the link", len(replies))
+	}
+
+	expectReply(t, h.groupReplies(t, h.Telegram.SendGroupText(groupID, 900, adminID, "/enabletopic off"), 900)[0], "all topics")
+	if replies := sendFile(1); len(replies) != 1 {
+		t.Errorf("got %d replies in the General topic, expected the link", len(replies))
+	}
+
+	config.Runtime.SetPrivateMode(true)
+	if replies := sendFile(500); len(replies) != 0 {
+		t.Errorf("got %d replies to a member who may not use the bot", len(replies))
+	}
+}
@@ -0,0 +1,218 @@
+// Package enrich looks up the files of links on TMDB and MusicBrainz for posters, synopses
+// and proper titles, which the player and the link previews show instead of file names.
+// Movies and shows are searched on TMDB with TMDB_API_KEY, music on MusicBrainz with
+// MUSICBRAINZ_ENABLED. Lookups run one at a time in the background and are stored, files
+// that weren't found too, so that every file is only looked up once.
+package enrich
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/category"
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/types"
+	"EverythingSuckz/fsb/internal/version"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// queueSize is the number of links waiting for a lookup, links enqueued when it's full
+// are looked up the next time they're played
+const queueSize = 100
+
+// lookupInterval is the pause between lookups, MusicBrainz allows one request per second
+const lookupInterval = time.Second
+
+// minVideoDuration is the duration in seconds from which videos are looked up as movies or
+// episodes rather than clips, videos sent as files have no duration and are looked up too
+const minVideoDuration = 15 * 60
+
+var (
+	log     *zap.Logger
+	queue   chan types.Link
+	pending = struct {
+		sync.Mutex
+		keys map[string]bool
+	}{keys: make(map[string]bool)}
+	client = &http.Client{Timeout: 15 * time.Second}
+)
+
+// Enabled reports whether TMDB or MusicBrainz lookups are configured
+func Enabled() bool {
+	return config.ValueOf.TMDBAPIKey != "" || config.ValueOf.MusicBrainz
+}
+
+// Start starts looking up the links that are enqueued
+func Start(l *zap.Logger) {
+	log = l.Named("enrich")
+	if !Enabled() || database.GetMetadataRepository() == nil {
+		return
+	}
+	queue = make(chan types.Link, queueSize)
+	go work()
+	log.Info("Looking up files", zap.Bool("tmdb", config.ValueOf.TMDBAPIKey != ""), zap.Bool("musicbrainz", config.ValueOf.MusicBrainz))
+}
+
+// Enqueue looks up the file of the link in the background, unless it isn't a movie, show
+// or song or it's already being looked up
+func Enqueue(link *types.Link) {
+	if queue == nil || kind(link) == "" {
+		return
+	}
+	key := link.StorageKey()
+	pending.Lock()
+	defer pending.Unlock()
+	if pending.keys[key] {
+		return
+	}
+	select {
+	case queue <- *link:
+		pending.keys[key] = true
+	default:
+	}
+}
+
+// Get returns what was found about the file of the link, nil if it wasn't looked up yet
+// or nothing was found
+func Get(tenantID uint, messageID int) *types.LinkMetadata {
+	metadataRepository := database.GetMetadataRepository()
+	if metadataRepository == nil {
+		return nil
+	}
+	metadata, err := metadataRepository.Get(tenantID, messageID)
+	if err != nil || metadata == nil || metadata.Source == "" {
+		return nil
+	}
+	return metadata
+}
+
+// Missing reports whether the file of the link wasn't looked up yet
+func Missing(link *types.Link) bool {
+	metadataRepository := database.GetMetadataRepository()
+	if metadataRepository == nil {
+		return false
+	}
+	metadata, err := metadataRepository.Get(link.TenantID, link.MessageID)
+	return err == nil && metadata == nil
+}
+
+// kind returns tmdb or musicbrainz for the files looked up there, or an empty string
+func kind(link *types.Link) string {
+	mimeType, _, _ := strings.Cut(link.MimeType, "/")
+	switch {
+	case mimeType == "video" && config.ValueOf.TMDBAPIKey != "" &&
+		(link.Duration == 0 || link.Duration >= minVideoDuration):
+		return "tmdb"
+	case mimeType == "audio" && config.ValueOf.MusicBrainz && link.Category != category.Voice:
+		return "musicbrainz"
+	}
+	return ""
+}
+
+func work() {
+	metadataRepository := database.GetMetadataRepository()
+	for link := range queue {
+		if existing, err := metadataRepository.Get(link.TenantID, link.MessageID); err == nil && existing == nil {
+			lookup(metadataRepository, &link)
+			time.Sleep(lookupInterval)
+		}
+		pending.Lock()
+		delete(pending.keys, link.StorageKey())
+		pending.Unlock()
+	}
+}
+
+func lookup(metadataRepository *database.MetadataRepository, link *types.Link) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	var metadata *types.LinkMetadata
+	var err error
+	switch kind(link) {
+	case "tmdb":
+		metadata, err = searchTMDB(ctx, link)
+	case "musicbrainz":
+		metadata, err = searchMusicBrainz(ctx, link)
+	}
+	if err != nil {
+		// failed lookups aren't stored, they're retried the next time the link is played
+		log.Warn("Failed to look up file", zap.Error(err), zap.String("link", link.StorageKey()))
+		return
+	}
+	if metadata == nil {
+		metadata = &types.LinkMetadata{}
+	}
+	metadata.TenantID, metadata.MessageID = link.TenantID, link.MessageID
+	if err := metadataRepository.Save(metadata); err != nil {
+		log.Error("Failed to store metadata", zap.Error(err), zap.String("link", link.StorageKey()))
+		return
+	}
+	if metadata.Source == "musicbrainz" && link.Title == "" && link.Performer == "" {
+		// songs without tags get the title and artist that was found, like /settitle
+		link.Title, link.Performer = metadata.Title, metadata.Artist
+		if linkRepository := database.GetLinkRepository(); linkRepository != nil {
+			if err := linkRepository.UpdateMetadata(link); err != nil {
+				log.Error("Failed to update link", zap.Error(err), zap.String("link", link.StorageKey()))
+			}
+		}
+	}
+}
+
+// getJSON decodes the response to a GET request
+func getJSON(ctx context.Context, url string, header http.Header, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "fsb/"+version.Version+" ( "+config.ValueOf.AppName+" )")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s answered with %s", req.URL.Host, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+var (
+	yearRegex      = regexp.MustCompile(`\b(19\d\d|20\d\d)\b`)
+	episodeRegex   = regexp.MustCompile(`(?i)\bS\d{1,2}\s?E\d{1,3}\b|\b\d{1,2}x\d{2}\b`)
+	releaseRegex   = regexp.MustCompile(`(?i)\b(480p|576p|720p|1080p|1080i|2160p|4k|uhd|hdr|bluray|blu ray|brrip|bdrip|webrip|web dl|webdl|web|hdtv|dvdrip|x264|x265|h264|h265|hevc|aac|dts|remux|proper|repack|extended|unrated)\b`)
+	bracketRegex   = regexp.MustCompile(`\[[^\]]*\]|\{[^}]*\}`)
+	separatorRegex = regexp.MustCompile(`[._]+`)
+	spaceRegex     = regexp.MustCompile(`\s+`)
+)
+
+// parseName returns the title and year in a file name like The.Matrix.1999.1080p.BluRay.mkv,
+// cut at the year, episode number or the first release tag
+func parseName(name string) (title string, year int) {
+	name = strings.TrimSuffix(name, path.Ext(name))
+	name = bracketRegex.ReplaceAllString(name, " ")
+	name = separatorRegex.ReplaceAllString(name, " ")
+	cut := len(name)
+	if match := yearRegex.FindStringSubmatchIndex(name); match != nil && match[0] > 0 {
+		cut = match[0]
+		year, _ = strconv.Atoi(name[match[2]:match[3]])
+	}
+	for _, regex := range []*regexp.Regexp{episodeRegex, releaseRegex} {
+		if match := regex.FindStringIndex(name); match != nil && match[0] > 0 && match[0] < cut {
+			cut = match[0]
+		}
+	}
+	title = strings.Trim(name[:cut], " -([")
+	return spaceRegex.ReplaceAllString(title, " "), year
+}
@@ -0,0 +1,110 @@
+package enrich
+
+import (
+	"EverythingSuckz/fsb/internal/types"
+	"context"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+)
+
+const (
+	musicBrainzAPI = "https://musicbrainz.org/ws/2"
+	coverArtURL    = "https://coverartarchive.org/release/"
+)
+
+// minScore is the score from 0 to 100 recordings need to be taken as the song of a file
+const minScore = 90
+
+// maxCovers is the number of releases of a recording checked for a cover
+const maxCovers = 3
+
+type recording struct {
+	ID           string `json:"id"`
+	Score        int    `json:"score"`
+	Title        string `json:"title"`
+	ReleaseDate  string `json:"first-release-date"`
+	ArtistCredit []struct {
+		Name       string `json:"name"`
+		JoinPhrase string `json:"joinphrase"`
+	} `json:"artist-credit"`
+	Releases []struct {
+		ID string `json:"id"`
+	} `json:"releases"`
+}
+
+// searchMusicBrainz searches the recordings on MusicBrainz for the title and artist tags of the
+// link, or the ones in a file name like Artist - Title.mp3
+func searchMusicBrainz(ctx context.Context, link *types.Link) (*types.LinkMetadata, error) {
+	title, artist := link.Title, link.Performer
+	if title == "" {
+		name := strings.TrimSuffix(link.FileName, path.Ext(link.FileName))
+		name = strings.TrimSpace(separatorRegex.ReplaceAllString(name, " "))
+		if before, after, ok := strings.Cut(name, " - "); ok {
+			artist, title = strings.TrimSpace(before), strings.TrimSpace(after)
+		} else {
+			title = name
+		}
+	}
+	if title == "" {
+		return nil, nil
+	}
+	query := "recording:" + quote(title)
+	if artist != "" {
+		query += " AND artist:" + quote(artist)
+	}
+	var response struct {
+		Recordings []recording `json:"recordings"`
+	}
+	if err := getJSON(ctx, musicBrainzAPI+"/recording?fmt=json&limit=5&query="+url.QueryEscape(query), nil, &response); err != nil {
+		return nil, err
+	}
+	if len(response.Recordings) == 0 || response.Recordings[0].Score < minScore {
+		return nil, nil
+	}
+	found := response.Recordings[0]
+	metadata := &types.LinkMetadata{
+		Source:     "musicbrainz",
+		ExternalID: found.ID,
+		Title:      found.Title,
+	}
+	for _, credit := range found.ArtistCredit {
+		metadata.Artist += credit.Name + credit.JoinPhrase
+	}
+	metadata.Year, _ = strconv.Atoi(strings.SplitN(found.ReleaseDate, "-", 2)[0])
+	for i, release := range found.Releases {
+		if i == maxCovers {
+			break
+		}
+		if cover := coverArtURL + release.ID + "/front-250"; hasCoverArt(ctx, cover) {
+			metadata.PosterURL = cover
+			break
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return metadata, nil
+}
+
+// quote quotes a phrase for the Lucene query syntax of MusicBrainz searches
+func quote(phrase string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(phrase) + `"`
+}
+
+// hasCoverArt reports whether the Cover Art Archive has the front cover of a release, most
+// releases have none
+func hasCoverArt(ctx context.Context, cover string) bool {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, cover, nil)
+	if err != nil {
+		return false
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode == http.StatusOK
+}
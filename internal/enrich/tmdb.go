@@ -0,0 +1,95 @@
+package enrich
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/types"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	tmdbAPI    = "https://api.themoviedb.org/3"
+	tmdbPoster = "https://image.tmdb.org/t/p/w500"
+)
+
+type tmdbResult struct {
+	ID           int    `json:"id"`
+	MediaType    string `json:"media_type"`
+	Title        string `json:"title"`
+	Name         string `json:"name"`
+	ReleaseDate  string `json:"release_date"`
+	FirstAirDate string `json:"first_air_date"`
+	Overview     string `json:"overview"`
+	PosterPath   string `json:"poster_path"`
+}
+
+// year returns the year the movie was released or the show first aired, 0 if unknown
+func (r *tmdbResult) year() int {
+	date := r.ReleaseDate
+	if date == "" {
+		date = r.FirstAirDate
+	}
+	year, _ := strconv.Atoi(strings.SplitN(date, "-", 2)[0])
+	return year
+}
+
+// searchTMDB searches the movies and shows on TMDB for the title of the link or the one in its
+// file name, preferring results of the year in the file name
+func searchTMDB(ctx context.Context, link *types.Link) (*types.LinkMetadata, error) {
+	title, year := parseName(link.FileName)
+	if link.Title != "" {
+		title = link.Title
+	}
+	if title == "" {
+		return nil, nil
+	}
+	query := url.Values{"query": {title}, "include_adult": {"false"}}
+	header := http.Header{}
+	// API read access tokens are JWTs, older API keys are passed as a parameter
+	if key := config.ValueOf.TMDBAPIKey; strings.HasPrefix(key, "eyJ") {
+		header.Set("Authorization", "Bearer "+key)
+	} else {
+		query.Set("api_key", key)
+	}
+	var response struct {
+		Results []tmdbResult `json:"results"`
+	}
+	if err := getJSON(ctx, tmdbAPI+"/search/multi?"+query.Encode(), header, &response); err != nil {
+		return nil, err
+	}
+	var found *tmdbResult
+	for i := range response.Results {
+		result := &response.Results[i]
+		if result.MediaType != "movie" && result.MediaType != "tv" {
+			continue
+		}
+		if found == nil {
+			found = result
+		}
+		if year != 0 && result.year() == year {
+			found = result
+			break
+		}
+	}
+	if found == nil {
+		return nil, nil
+	}
+	metadata := &types.LinkMetadata{
+		Source:     "tmdb",
+		ExternalID: fmt.Sprintf("%s/%d", found.MediaType, found.ID),
+		Title:      found.Title,
+		Year:       found.year(),
+		Overview:   found.Overview,
+	}
+	if metadata.Title == "" {
+		metadata.Title = found.Name
+	}
+	if found.PosterPath != "" {
+		metadata.PosterURL = tmdbPoster + found.PosterPath
+	}
+	return metadata, nil
+}
@@ -0,0 +1,230 @@
+// Package export writes the users, the playback history and the generated links as CSV or JSON
+// for /export and the /api/export endpoints.
+package export
+
+import (
+	"EverythingSuckz/fsb/internal/database"
+	"crypto/rand"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// downloadTTL is how long a one-time download link stays valid
+const downloadTTL = time.Hour
+
+// dateLayout is the format of the from and to filters
+const dateLayout = "2006-01-02"
+
+// Kinds are the exportable data sets
+var Kinds = []string{"users", "history", "links"}
+
+// ErrUnavailable is returned when the database isn't initialized
+var ErrUnavailable = errors.New("database is not available at the moment")
+
+// Request selects what to export
+type Request struct {
+	Kind           string
+	Format         string // csv or json
+	From           time.Time
+	To             time.Time
+	AuthorizedOnly bool // only users with an authorization that didn't expire, and their links
+}
+
+// NewRequest validates the kind and the format and parses the from and to dates, which are optional.
+// The to date is inclusive.
+func NewRequest(kind string, format string, from string, to string, authorizedOnly bool) (*Request, error) {
+	request := &Request{Kind: strings.ToLower(kind), Format: strings.ToLower(format), AuthorizedOnly: authorizedOnly}
+	if !isKind(request.Kind) {
+		return nil, fmt.Errorf("unknown export %q, use %s", kind, strings.Join(Kinds, ", "))
+	}
+	if request.Format == "" {
+		request.Format = "csv"
+	}
+	if request.Format != "csv" && request.Format != "json" {
+		return nil, fmt.Errorf("unknown format %q, use csv or json", format)
+	}
+	request.To = time.Now().Add(time.Minute)
+	if from != "" {
+		t, err := time.ParseInLocation(dateLayout, from, time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("invalid from date %q, use YYYY-MM-DD", from)
+		}
+		request.From = t
+	}
+	if to != "" {
+		t, err := time.ParseInLocation(dateLayout, to, time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("invalid to date %q, use YYYY-MM-DD", to)
+		}
+		request.To = t.AddDate(0, 0, 1)
+	}
+	return request, nil
+}
+
+// FileName returns the name of the exported file
+func (r *Request) FileName() string {
+	return fmt.Sprintf("%s-%s.%s", r.Kind, time.Now().Format("20060102-150405"), r.Format)
+}
+
+// ContentType returns the MIME type of the exported file
+func (r *Request) ContentType() string {
+	if r.Format == "json" {
+		return "application/json"
+	}
+	return "text/csv"
+}
+
+// Write writes the export to w and returns the number of exported rows
+func Write(w io.Writer, r *Request) (int, error) {
+	header, rows, err := table(r)
+	if err != nil {
+		return 0, err
+	}
+	if r.Format == "json" {
+		objects := make([]map[string]interface{}, len(rows))
+		for i, row := range rows {
+			objects[i] = make(map[string]interface{}, len(header))
+			for j, column := range header {
+				objects[i][column] = row[j]
+			}
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return len(rows), encoder.Encode(objects)
+	}
+	writer := csv.NewWriter(w)
+	writer.Write(header)
+	for _, row := range rows {
+		record := make([]string, len(row))
+		for i, value := range row {
+			record[i] = formatValue(value)
+		}
+		writer.Write(record)
+	}
+	writer.Flush()
+	return len(rows), writer.Error()
+}
+
+func table(r *Request) ([]string, [][]interface{}, error) {
+	var rows [][]interface{}
+	switch r.Kind {
+	case "users":
+		userRepository := database.GetUserRepository()
+		if userRepository == nil {
+			return nil, nil, ErrUnavailable
+		}
+		users, err := userRepository.ListCreated(r.From, r.To, r.AuthorizedOnly)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, u := range users {
+			rows = append(rows, []interface{}{u.ID, u.Username, u.FirstName, u.TenantID, u.Authorized, u.AuthorizedTill,
+				u.Suspended, u.Flagged, u.InvitedBy, u.Referral, u.CreatedAt})
+		}
+		return []string{"id", "username", "first_name", "tenant_id", "authorized", "authorized_till",
+			"suspended", "flagged", "invited_by", "referral", "created_at"}, rows, nil
+	case "links":
+		linkRepository := database.GetLinkRepository()
+		if linkRepository == nil {
+			return nil, nil, ErrUnavailable
+		}
+		links, err := linkRepository.ListCreated(r.From, r.To, r.AuthorizedOnly)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, l := range links {
+			rows = append(rows, []interface{}{l.TenantID, l.MessageID, l.UserID, l.FileName, l.FileSize, l.MimeType,
+				l.Views, l.LastAccess, l.CreatedAt})
+		}
+		return []string{"tenant_id", "message_id", "user_id", "file_name", "file_size", "mime_type",
+			"views", "last_access", "created_at"}, rows, nil
+	case "history":
+		telemetryRepository := database.GetTelemetryRepository()
+		if telemetryRepository == nil {
+			return nil, nil, ErrUnavailable
+		}
+		events, err := telemetryRepository.ListEvents(r.From, r.To)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, e := range events {
+			rows = append(rows, []interface{}{e.TenantID, e.MessageID, e.Session, e.Type, e.Position, e.Watched, e.CreatedAt})
+		}
+		return []string{"tenant_id", "message_id", "session", "type", "position", "watched", "created_at"}, rows, nil
+	}
+	return nil, nil, fmt.Errorf("unknown export %q", r.Kind)
+}
+
+// formatValue formats a value for CSV, times as RFC 3339 and nil times as empty cells
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case time.Time:
+		return v.Format(time.RFC3339)
+	case *time.Time:
+		if v == nil {
+			return ""
+		}
+		return v.Format(time.RFC3339)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return fmt.Sprint(value)
+}
+
+func isKind(kind string) bool {
+	for _, k := range Kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+type download struct {
+	request *Request
+	expires time.Time
+}
+
+var downloads = struct {
+	sync.Mutex
+	byToken map[string]download
+}{byToken: make(map[string]download)}
+
+// NewDownload returns a token for a one-time download of the export, valid for an hour
+func NewDownload(r *Request) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+	downloads.Lock()
+	defer downloads.Unlock()
+	now := time.Now()
+	for t, d := range downloads.byToken {
+		if now.After(d.expires) {
+			delete(downloads.byToken, t)
+		}
+	}
+	downloads.byToken[token] = download{request: r, expires: now.Add(downloadTTL)}
+	return token, nil
+}
+
+// TakeDownload returns the export of the token and invalidates it, or nil if the token is unknown or expired
+func TakeDownload(token string) *Request {
+	downloads.Lock()
+	defer downloads.Unlock()
+	d, ok := downloads.byToken[token]
+	delete(downloads.byToken, token)
+	if !ok || time.Now().After(d.expires) {
+		return nil
+	}
+	return d.request
+}
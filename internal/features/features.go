@@ -0,0 +1,205 @@
+// Package features gates experimental subsystems behind flags. A flag is on, off, on for
+// admins only, or on for a percentage of users, so a feature can be rolled out gradually.
+// The state changed with /features is stored in the database and takes precedence over FEATURES.
+package features
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/utils"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// Flag is the name of a feature flag
+type Flag string
+
+const (
+	// Transcoding gates adaptive streaming (HLS) of videos, which also needs FFMPEG_ENABLED
+	Transcoding Flag = "transcoding"
+	// Proxy gates streaming through an outgoing proxy
+	Proxy Flag = "proxy"
+	// Casting gates casting from the web player to other devices
+	Casting Flag = "casting"
+	// Inline gates inline mode
+	Inline Flag = "inline"
+)
+
+const (
+	StateOn     = "on"
+	StateOff    = "off"
+	StateAdmins = "admins"
+)
+
+// Definition describes a feature flag and its state when FEATURES doesn't mention it
+type Definition struct {
+	Flag        Flag
+	Description string
+	Default     string
+}
+
+var definitions = []Definition{
+	{Transcoding, "Adaptive streaming (HLS) of videos", StateOn},
+	{Proxy, "Streaming through an outgoing proxy", StateOff},
+	{Casting, "Casting from the web player", StateOff},
+	{Inline, "Inline mode", StateOff},
+}
+
+var flags = struct {
+	sync.RWMutex
+	configured map[Flag]string // from FEATURES or the default
+	stored     map[Flag]string // from the database
+}{configured: make(map[Flag]string), stored: make(map[Flag]string)}
+
+// Load parses FEATURES, whose entries look like transcoding=on or inline=10%, and reads
+// the states changed at runtime from the database
+func Load(log *zap.Logger) error {
+	log = log.Named("features")
+	flags.Lock()
+	defer flags.Unlock()
+	for _, definition := range definitions {
+		flags.configured[definition.Flag] = definition.Default
+	}
+	for _, entry := range config.ValueOf.Features {
+		name, state, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		flag := Flag(strings.ToLower(strings.TrimSpace(name)))
+		if !ok || Lookup(flag) == nil {
+			log.Sugar().Warnf("Ignoring invalid feature flag %q", entry)
+			continue
+		}
+		state, err := ParseState(state)
+		if err != nil {
+			log.Sugar().Warnf("Ignoring feature flag %q: %s", entry, err)
+			continue
+		}
+		flags.configured[flag] = state
+	}
+	featureFlagRepository := database.GetFeatureFlagRepository()
+	if featureFlagRepository == nil {
+		return nil
+	}
+	stored, err := featureFlagRepository.List()
+	if err != nil {
+		return err
+	}
+	for _, flag := range stored {
+		if Lookup(Flag(flag.Name)) == nil {
+			log.Sugar().Warnf("Ignoring unknown feature flag %q", flag.Name)
+			continue
+		}
+		flags.stored[Flag(flag.Name)] = flag.State
+	}
+	return nil
+}
+
+// Definitions returns all feature flags
+func Definitions() []Definition {
+	return definitions
+}
+
+// Lookup returns the definition of the flag, or nil if there is none
+func Lookup(flag Flag) *Definition {
+	for i := range definitions {
+		if definitions[i].Flag == flag {
+			return &definitions[i]
+		}
+	}
+	return nil
+}
+
+// ParseState validates a state and returns it normalized
+func ParseState(state string) (string, error) {
+	state = strings.ToLower(strings.TrimSpace(state))
+	switch state {
+	case StateOn, StateOff, StateAdmins:
+		return state, nil
+	}
+	percent, err := strconv.Atoi(strings.TrimSuffix(state, "%"))
+	if err != nil || !strings.HasSuffix(state, "%") || percent < 0 || percent > 100 {
+		return "", fmt.Errorf("invalid state %q, use on, off, admins or a percentage like 10%%", state)
+	}
+	return fmt.Sprintf("%d%%", percent), nil
+}
+
+// State returns the state of the flag and whether it was changed at runtime
+func State(flag Flag) (string, bool) {
+	flags.RLock()
+	defer flags.RUnlock()
+	if state, ok := flags.stored[flag]; ok {
+		return state, true
+	}
+	return flags.configured[flag], false
+}
+
+// Enabled reports whether the feature is enabled for the user. Percentages select the
+// same users for as long as they don't change. Pass 0 if the user isn't known.
+func Enabled(flag Flag, userID int64) bool {
+	state, _ := State(flag)
+	switch state {
+	case StateOn:
+		return true
+	case StateOff, "":
+		return false
+	case StateAdmins:
+		return utils.Contains(config.Runtime.Admins(), userID)
+	}
+	percent, _ := strconv.Atoi(strings.TrimSuffix(state, "%"))
+	if userID == 0 {
+		return percent == 100
+	}
+	return bucket(flag, userID) < percent
+}
+
+// bucket places the user in one of 100 buckets, differently for every flag so that
+// the same users don't get every experimental feature first
+func bucket(flag Flag, userID int64) int {
+	h := fnv.New32a()
+	h.Write([]byte(string(flag) + ":" + strconv.FormatInt(userID, 10)))
+	return int(h.Sum32() % 100)
+}
+
+// Set changes the state of the flag at runtime and stores it
+func Set(flag Flag, state string, updatedBy int64) error {
+	if Lookup(flag) == nil {
+		return fmt.Errorf("unknown feature: %s", flag)
+	}
+	state, err := ParseState(state)
+	if err != nil {
+		return err
+	}
+	featureFlagRepository := database.GetFeatureFlagRepository()
+	if featureFlagRepository == nil {
+		return errors.New("feature flag database is not available at the moment")
+	}
+	flags.Lock()
+	defer flags.Unlock()
+	if err := featureFlagRepository.Set(string(flag), state, updatedBy); err != nil {
+		return err
+	}
+	flags.stored[flag] = state
+	return nil
+}
+
+// Reset removes the state changed at runtime, so FEATURES applies again
+func Reset(flag Flag) error {
+	if Lookup(flag) == nil {
+		return fmt.Errorf("unknown feature: %s", flag)
+	}
+	featureFlagRepository := database.GetFeatureFlagRepository()
+	if featureFlagRepository == nil {
+		return errors.New("feature flag database is not available at the moment")
+	}
+	flags.Lock()
+	defer flags.Unlock()
+	if err := featureFlagRepository.Delete(string(flag)); err != nil {
+		return err
+	}
+	delete(flags.stored, flag)
+	return nil
+}
@@ -0,0 +1,247 @@
+// Package importer reads the users of other stream bots from CSV or JSON exports
+// and SQLite databases, so that operators can switch without losing their users.
+package importer
+
+import (
+	"EverythingSuckz/fsb/internal/types"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Record is a row of an export, with lowercase column names
+type Record map[string]string
+
+// columns lists the column names other bots use for each user field, in order of preference
+var columns = map[string][]string{
+	"id":         {"id", "user_id", "userid", "chat_id", "telegram_id", "tg_id", "_id"},
+	"username":   {"username", "user_name"},
+	"first_name": {"first_name", "firstname", "name", "full_name"},
+	"authorized": {"authorized", "is_authorized", "allowed", "is_allowed", "premium", "is_premium", "verified", "is_verified"},
+	"expires":    {"authorized_till", "expires_at", "expiry", "expiry_date", "expire_date", "premium_until", "valid_till", "plan_expiry"},
+	"suspended":  {"suspended", "banned", "is_banned", "blocked", "is_blocked"},
+}
+
+// timeLayouts are the formats accepted for dates, besides unix timestamps
+var timeLayouts = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02"}
+
+// Read returns the records of the file. format is csv, json or sqlite, or empty to guess
+// it from the extension. table names the table to read from SQLite databases.
+func Read(path string, format string, table string) ([]Record, error) {
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	}
+	switch format {
+	case "csv":
+		return readCSV(path)
+	case "json", "jsonl", "ndjson":
+		return readJSON(path)
+	case "sqlite", "sqlite3", "db":
+		return readSQLite(path, table)
+	}
+	return nil, fmt.Errorf("unknown format %q, use csv, json or sqlite", format)
+}
+
+func readCSV(path string) ([]Record, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the header: %w", err)
+	}
+	var records []Record
+	for {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		record := make(Record, len(header))
+		for i, value := range row {
+			if i < len(header) {
+				record[strings.ToLower(strings.TrimSpace(header[i]))] = strings.TrimSpace(value)
+			}
+		}
+		records = append(records, record)
+	}
+}
+
+// readJSON reads an array of objects, an object with a users array, or one object per line
+// like mongoexport writes
+func readJSON(path string) ([]Record, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	decoder := json.NewDecoder(file)
+	decoder.UseNumber()
+	var records []Record
+	for {
+		var value interface{}
+		err := decoder.Decode(&value)
+		if errors.Is(err, io.EOF) {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if object, ok := value.(map[string]interface{}); ok {
+			if users, ok := object["users"].([]interface{}); ok {
+				value = users
+			}
+		}
+		items, ok := value.([]interface{})
+		if !ok {
+			items = []interface{}{value}
+		}
+		for _, item := range items {
+			object, ok := item.(map[string]interface{})
+			if !ok {
+				return nil, errors.New("expected JSON objects")
+			}
+			records = append(records, toRecord(object))
+		}
+	}
+}
+
+func readSQLite(path string, table string) ([]Record, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	db, err := gorm.Open(sqlite.Open(path), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		return nil, err
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		defer sqlDB.Close()
+	}
+	var rows []map[string]interface{}
+	if err := db.Table(table).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	records := make([]Record, 0, len(rows))
+	for _, row := range rows {
+		records = append(records, toRecord(row))
+	}
+	return records, nil
+}
+
+func toRecord(object map[string]interface{}) Record {
+	record := make(Record, len(object))
+	for key, value := range object {
+		record[strings.ToLower(key)] = stringify(value)
+	}
+	return record
+}
+
+// stringify formats a decoded value, unwrapping the {"$numberLong": ...} and {"$date": ...}
+// objects of MongoDB extended JSON
+func stringify(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return strings.TrimSpace(v)
+	case []byte:
+		return strings.TrimSpace(string(v))
+	case time.Time:
+		return v.Format(time.RFC3339)
+	case map[string]interface{}:
+		for _, key := range []string{"$numberLong", "$numberInt", "$date"} {
+			if inner, ok := v[key]; ok {
+				return stringify(inner)
+			}
+		}
+		return ""
+	}
+	return fmt.Sprint(value)
+}
+
+// User maps a record to a user. Users whose authorization already expired are imported
+// without it, so they can be authorized again with /authorize.
+func User(record Record, now time.Time) (*types.User, error) {
+	idValue := record.get("id")
+	id, err := strconv.ParseInt(strings.TrimPrefix(idValue, "+"), 10, 64)
+	if err != nil || id <= 0 {
+		return nil, fmt.Errorf("invalid user ID %q", idValue)
+	}
+	user := &types.User{
+		ID:        id,
+		Username:  strings.TrimPrefix(record.get("username"), "@"),
+		FirstName: record.get("first_name"),
+		Suspended: parseBool(record.get("suspended")),
+	}
+	authorized := parseBool(record.get("authorized"))
+	if expires := record.get("expires"); expires != "" {
+		till, err := parseTime(expires)
+		if err != nil {
+			return nil, err
+		}
+		if till != nil {
+			authorized = till.After(now)
+			user.AuthorizedTill = till
+		}
+	}
+	user.Authorized = authorized
+	if !authorized {
+		user.AuthorizedTill = nil
+	}
+	return user, nil
+}
+
+// get returns the value of the first known column of the field that the record has
+func (r Record) get(field string) string {
+	for _, column := range columns[field] {
+		if value, ok := r[column]; ok && value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+func parseBool(value string) bool {
+	switch strings.ToLower(value) {
+	case "1", "true", "yes", "y", "t":
+		return true
+	}
+	return false
+}
+
+// parseTime parses dates and unix timestamps, in seconds or milliseconds. Zero timestamps are nil.
+func parseTime(value string) (*time.Time, error) {
+	if number, err := strconv.ParseFloat(value, 64); err == nil {
+		if number <= 0 {
+			return nil, nil
+		}
+		if number > 1e12 {
+			number /= 1000
+		}
+		t := time.Unix(int64(number), 0)
+		return &t, nil
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return &t, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid date %q", value)
+}
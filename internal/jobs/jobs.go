@@ -0,0 +1,276 @@
+// Package jobs runs the background work of the bot, like saving files with /save and sending
+// scheduled broadcasts, on a pool of workers per kind of job. Jobs are stored in the database,
+// so that they survive restarts, and retried with a growing delay when they fail. Jobs that
+// failed every attempt are dead, /jobs and the dashboard list them to be retried or removed.
+package jobs
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/crash"
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/types"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// pollInterval is how often idle workers look for jobs whose retry is due
+const pollInterval = 15 * time.Second
+
+// purgeInterval is how often the jobs done before JOB_RETENTION_DAYS are removed
+const purgeInterval = time.Hour
+
+// ErrUnavailable is returned when the jobs can't be stored
+var ErrUnavailable = errors.New("the job database is not available")
+
+// Handler runs a job, returning an error queues it to be retried
+type Handler func(ctx context.Context, job *types.Job) error
+
+// Policy is how a kind of job is run
+type Policy struct {
+	Workers     int           // jobs of the kind run at the same time
+	MaxAttempts int           // attempts before the job is dead
+	Backoff     time.Duration // delay of the first retry, doubled for every further one
+	MaxBackoff  time.Duration
+}
+
+type queue struct {
+	kind    string
+	policy  Policy
+	handler Handler
+	wake    chan struct{}
+}
+
+// permanentError is an error retrying won't fix
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks an error retrying the job won't fix, its job is dead right away
+func Permanent(err error) error {
+	return &permanentError{err}
+}
+
+var (
+	log     = zap.NewNop()
+	mu      sync.Mutex
+	queues  = make(map[string]*queue)
+	started bool
+)
+
+// Register sets the handler and the policy of a kind of job. Its workers start with Start,
+// or right away if the jobs were started already.
+func Register(kind string, policy Policy, handler Handler) {
+	policy.Workers = max(policy.Workers, 1)
+	policy.MaxAttempts = max(policy.MaxAttempts, 1)
+	if policy.Backoff <= 0 {
+		policy.Backoff = time.Minute
+	}
+	if policy.MaxBackoff < policy.Backoff {
+		policy.MaxBackoff = policy.Backoff
+	}
+	q := &queue{kind: kind, policy: policy, handler: handler, wake: make(chan struct{}, 1)}
+	mu.Lock()
+	defer mu.Unlock()
+	queues[kind] = q
+	if started {
+		q.start()
+	}
+}
+
+// Start queues the jobs that were running when the bot stopped again and starts the workers
+func Start(l *zap.Logger) {
+	log = l.Named("jobs")
+	jobRepository := database.GetJobRepository()
+	if jobRepository == nil {
+		return
+	}
+	if recovered, err := jobRepository.RecoverRunning(); err != nil {
+		log.Error("Failed to recover running jobs", zap.Error(err))
+	} else if recovered > 0 {
+		log.Sugar().Infof("Queued %d jobs that were running when the bot stopped again", recovered)
+	}
+	mu.Lock()
+	started = true
+	for _, q := range queues {
+		q.start()
+	}
+	mu.Unlock()
+	go purge(jobRepository)
+}
+
+func (q *queue) start() {
+	for i := 0; i < q.policy.Workers; i++ {
+		go q.work()
+	}
+	log.Info("Started workers", zap.String("kind", q.kind), zap.Int("workers", q.policy.Workers))
+}
+
+// Enqueue stores a job of the kind with the payload, encoded as JSON, and returns its ID
+func Enqueue(kind string, payload any) (uint, error) {
+	jobRepository := database.GetJobRepository()
+	if jobRepository == nil {
+		return 0, ErrUnavailable
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return 0, err
+	}
+	job := &types.Job{Kind: kind, State: types.JobQueued, RunAt: time.Now(), Payload: string(data)}
+	if err := jobRepository.Create(job); err != nil {
+		return 0, err
+	}
+	wake(kind)
+	return job.ID, nil
+}
+
+// Decode decodes the payload of the job into v
+func Decode(job *types.Job, v any) error {
+	return json.Unmarshal([]byte(job.Payload), v)
+}
+
+// Waiting returns the number of queued jobs of the kind
+func Waiting(kind string) int {
+	jobRepository := database.GetJobRepository()
+	if jobRepository == nil {
+		return 0
+	}
+	count, err := jobRepository.CountWaiting(kind)
+	if err != nil {
+		return 0
+	}
+	return int(count)
+}
+
+// LastAttempt reports whether a failure of the running job makes it dead
+func LastAttempt(job *types.Job) bool {
+	mu.Lock()
+	q, ok := queues[job.Kind]
+	mu.Unlock()
+	return !ok || job.Attempts >= q.policy.MaxAttempts
+}
+
+// Retry queues a dead job again and reports whether there was one
+func Retry(id uint) (bool, error) {
+	jobRepository := database.GetJobRepository()
+	if jobRepository == nil {
+		return false, ErrUnavailable
+	}
+	job, err := jobRepository.Get(id)
+	if err != nil || job == nil {
+		return false, err
+	}
+	retried, err := jobRepository.Requeue(id, time.Now())
+	if retried {
+		wake(job.Kind)
+	}
+	return retried, err
+}
+
+// Remove removes a dead job and reports whether there was one
+func Remove(id uint) (bool, error) {
+	jobRepository := database.GetJobRepository()
+	if jobRepository == nil {
+		return false, ErrUnavailable
+	}
+	return jobRepository.Delete(id)
+}
+
+// wake tells an idle worker of the kind that there's a job
+func wake(kind string) {
+	mu.Lock()
+	q, ok := queues[kind]
+	mu.Unlock()
+	if !ok {
+		return
+	}
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (q *queue) work() {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		job, err := database.GetJobRepository().Claim(q.kind, time.Now())
+		if err != nil {
+			log.Error("Failed to claim job", zap.String("kind", q.kind), zap.Error(err))
+		}
+		if job == nil {
+			select {
+			case <-q.wake:
+			case <-ticker.C:
+			}
+			continue
+		}
+		q.run(job)
+	}
+}
+
+// run runs the job and records how it went
+func (q *queue) run(job *types.Job) {
+	err := q.call(job)
+	jobRepository := database.GetJobRepository()
+	now := time.Now()
+	if err == nil {
+		if err := jobRepository.Finish(job.ID, now); err != nil {
+			log.Error("Failed to finish job", zap.Uint("id", job.ID), zap.Error(err))
+		}
+		return
+	}
+	var permanent *permanentError
+	if errors.As(err, &permanent) || job.Attempts >= q.policy.MaxAttempts {
+		log.Warn("Job failed its last attempt", zap.String("kind", q.kind), zap.Uint("id", job.ID), zap.Int("attempts", job.Attempts), zap.Error(err))
+		if err := jobRepository.Bury(job.ID, err.Error(), now); err != nil {
+			log.Error("Failed to bury job", zap.Uint("id", job.ID), zap.Error(err))
+		}
+		return
+	}
+	delay := q.policy.Backoff << min(job.Attempts-1, 16)
+	if delay > q.policy.MaxBackoff || delay <= 0 {
+		delay = q.policy.MaxBackoff
+	}
+	log.Info("Job failed, retrying", zap.String("kind", q.kind), zap.Uint("id", job.ID), zap.Int("attempts", job.Attempts), zap.Duration("delay", delay), zap.Error(err))
+	if err := jobRepository.Retry(job.ID, err.Error(), now.Add(delay)); err != nil {
+		log.Error("Failed to retry job", zap.Uint("id", job.ID), zap.Error(err))
+	}
+}
+
+// call runs the handler, a panic fails the attempt
+func (q *queue) call(job *types.Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			crash.Report("job "+q.kind, r, string(debug.Stack()))
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return q.handler(context.Background(), job)
+}
+
+// purge removes the jobs done before JOB_RETENTION_DAYS, the dead ones are kept
+func purge(jobRepository *database.JobRepository) {
+	ticker := time.NewTicker(purgeInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		days := config.ValueOf.JobRetentionDays
+		if days <= 0 {
+			continue
+		}
+		if removed, err := jobRepository.PurgeDone(time.Now().AddDate(0, 0, -days)); err != nil {
+			log.Error("Failed to remove finished jobs", zap.Error(err))
+		} else if removed > 0 {
+			log.Sugar().Debugf("Removed %d finished jobs", removed)
+		}
+	}
+}
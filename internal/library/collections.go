@@ -0,0 +1,105 @@
+package library
+
+import (
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/types"
+	"EverythingSuckz/fsb/internal/utils"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	// maxCollectionDepth bounds how deep collections can be nested
+	maxCollectionDepth = 5
+	// maxCollectionName bounds the length of the names of collections, with their parents
+	maxCollectionName = 200
+)
+
+// Collection is a collection with its working links
+type Collection struct {
+	types.Collection
+	Links []types.Link
+}
+
+// CollectionNames cleans up the name of a collection, the names of the collections it's nested
+// in and its own separated by slashes, like file names. It returns the names of the collections
+// it's nested in and its own, parents first.
+func CollectionNames(name string) ([]string, error) {
+	var parts []string
+	for _, part := range strings.Split(name, "/") {
+		if part = utils.SanitizeFileName(part, ""); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	if len(parts) == 0 {
+		return nil, errors.New("the name of the collection is empty")
+	}
+	if len(parts) > maxCollectionDepth {
+		return nil, fmt.Errorf("collections can be nested %d deep at most", maxCollectionDepth)
+	}
+	if len(strings.Join(parts, "/")) > maxCollectionName {
+		return nil, fmt.Errorf("the name of the collection can be %d characters at most", maxCollectionName)
+	}
+	names := make([]string, len(parts))
+	for i := range parts {
+		names[i] = strings.Join(parts[:i+1], "/")
+	}
+	return names, nil
+}
+
+// Parent returns the name of the collection the collection is nested in, empty if it isn't
+func Parent(name string) string {
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		return name[:i]
+	}
+	return ""
+}
+
+// Collections returns the collections of the user with their links, the collections nested
+// in others after them
+func Collections(userID int64) ([]Collection, error) {
+	collectionRepository := database.GetCollectionRepository()
+	if collectionRepository == nil {
+		return nil, ErrUnavailable
+	}
+	collections, err := collectionRepository.List(userID)
+	if err != nil {
+		return nil, err
+	}
+	return withLinks(collections)
+}
+
+// Shared returns the collection shared with the token and the ones nested in it with their
+// links, or nil if no collection is shared with the token
+func Shared(token string) ([]Collection, error) {
+	collectionRepository := database.GetCollectionRepository()
+	if collectionRepository == nil {
+		return nil, ErrUnavailable
+	}
+	if token == "" {
+		return nil, nil
+	}
+	shared, err := collectionRepository.GetShared(token)
+	if err != nil || shared == nil {
+		return nil, err
+	}
+	collections, err := collectionRepository.ListNested(shared)
+	if err != nil {
+		return nil, err
+	}
+	return withLinks(collections)
+}
+
+func withLinks(collections []types.Collection) ([]Collection, error) {
+	collectionRepository := database.GetCollectionRepository()
+	result := make([]Collection, 0, len(collections))
+	for _, collection := range collections {
+		links, err := collectionRepository.Links(collection.ID, MaxItems)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, Collection{Collection: collection, Links: links})
+	}
+	return result, nil
+}
@@ -0,0 +1,75 @@
+// Package library exports the recent files of users for media servers and players: .strm
+// files, which Jellyfin, Plex and Kodi read as items that play from the URL inside, and M3U
+// playlists. Placed in a library folder, .strm files make the files sent to the bot show up
+// next to the local media, streamed from Telegram when they're played. It also lists the
+// collections users organize their files in.
+package library
+
+import (
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/profile"
+	"EverythingSuckz/fsb/internal/types"
+	"errors"
+	"slices"
+	"strings"
+)
+
+// MaxItems is the number of links exported at most, the most recent first
+const MaxItems = 1000
+
+// ErrUnavailable is returned when the database isn't initialized
+var ErrUnavailable = errors.New("the link database is not available at the moment")
+
+// Playable reports whether media servers can play the file of the link
+func Playable(link *types.Link) bool {
+	return strings.HasPrefix(link.MimeType, "video/") || strings.HasPrefix(link.MimeType, "audio/")
+}
+
+// Search returns a page of the recent videos and audio files of the profile of the user whose
+// file name or audio tags contain the query, only one of them if kind is video or audio
+func Search(userID int64, query string, kind string, offset int, limit int) ([]types.Link, error) {
+	linkRepository := database.GetLinkRepository()
+	if linkRepository == nil {
+		return nil, ErrUnavailable
+	}
+	accounts, err := profile.Accounts(profile.Of(userID))
+	if err != nil {
+		return nil, err
+	}
+	mimePrefixes := []string{"video/", "audio/"}
+	if kind != "" {
+		mimePrefixes = []string{kind + "/"}
+	}
+	return linkRepository.SearchByUsers(accounts, query, mimePrefixes, offset, limit)
+}
+
+// Owns reports whether the link was generated by an account of the profile of the user
+func Owns(userID int64, link *types.Link) (bool, error) {
+	accounts, err := profile.Accounts(profile.Of(userID))
+	if err != nil {
+		return false, err
+	}
+	return slices.Contains(accounts, link.UserID), nil
+}
+
+// Recent returns the most recent links of all accounts of the profile of the user that
+// weren't revoked or removed
+func Recent(userID int64, limit int) ([]types.Link, error) {
+	return RecentIn(userID, "", limit)
+}
+
+// RecentIn returns the links of Recent in the category, all of them if it's empty
+func RecentIn(userID int64, category string, limit int) ([]types.Link, error) {
+	linkRepository := database.GetLinkRepository()
+	if linkRepository == nil {
+		return nil, ErrUnavailable
+	}
+	accounts, err := profile.Accounts(profile.Of(userID))
+	if err != nil {
+		return nil, err
+	}
+	if category != "" {
+		return linkRepository.ListByCategory(accounts, category, limit)
+	}
+	return linkRepository.ListByUsers(accounts, limit)
+}
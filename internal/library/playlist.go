@@ -0,0 +1,48 @@
+package library
+
+import (
+	"EverythingSuckz/fsb/internal/types"
+	"EverythingSuckz/fsb/internal/utils"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// PlaylistKinds are the kinds of files a playlist can be limited to
+var PlaylistKinds = []string{"video", "audio"}
+
+// Playlist returns an extended M3U playlist of the playable links, only of the kind if it
+// isn't empty, with their duration and title. It's UTF-8, which players expect from .m3u8
+// files and most read from .m3u files too. It returns the number of entries.
+func Playlist(links []types.Link, kind string) (string, int) {
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n")
+	entries := 0
+	for i := range links {
+		link := &links[i]
+		if !Playable(link) || (kind != "" && !strings.HasPrefix(link.MimeType, kind+"/")) {
+			continue
+		}
+		duration := link.Duration
+		if duration == 0 {
+			duration = -1
+		}
+		fmt.Fprintf(&b, "#EXTINF:%d,%s\n", duration, playlistTitle(link))
+		b.WriteString(utils.StreamURL(link.TenantID, link.MessageID, link.Hash) + "\n")
+		entries++
+	}
+	return b.String(), entries
+}
+
+// playlistTitle returns "performer - title" from the audio tags, or the file name
+func playlistTitle(link *types.Link) string {
+	title := link.Title
+	if title == "" {
+		title = strings.TrimSuffix(link.FileName, path.Ext(link.FileName))
+	}
+	if link.Performer != "" {
+		title = link.Performer + " - " + title
+	}
+	// a line break would end the entry
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(title)
+}
@@ -0,0 +1,66 @@
+package library
+
+import (
+	"EverythingSuckz/fsb/internal/types"
+	"EverythingSuckz/fsb/internal/utils"
+	"archive/zip"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+// Content returns the content of the .strm file of the link, its stream URL
+func Content(link *types.Link) string {
+	return utils.StreamURL(link.TenantID, link.MessageID, link.Hash) + "\n"
+}
+
+// FileName returns the name of the .strm file of the link, the name of the file with the
+// .strm extension, so that media servers match it the same way as the file itself
+func FileName(link *types.Link) string {
+	name := utils.LinkFileName(link)
+	return strings.TrimSuffix(name, path.Ext(name)) + ".strm"
+}
+
+// WriteArchive writes a zip with the .strm files of the playable links, videos in Videos and
+// audio in Music, to be extracted into the folders of the libraries. It returns the number of
+// files written.
+func WriteArchive(w io.Writer, links []types.Link) (int, error) {
+	archive := zip.NewWriter(w)
+	used := make(map[string]bool)
+	written := 0
+	for i := range links {
+		link := &links[i]
+		if !Playable(link) {
+			continue
+		}
+		folder := "Videos"
+		if strings.HasPrefix(link.MimeType, "audio/") {
+			folder = "Music"
+		}
+		name := path.Join(folder, FileName(link))
+		// files sent twice with the same name get the message ID in the name of the later one
+		if used[strings.ToLower(name)] {
+			name = path.Join(folder, fmt.Sprintf("%s (%d).strm", strings.TrimSuffix(FileName(link), ".strm"), link.MessageID))
+		}
+		used[strings.ToLower(name)] = true
+		file, err := archive.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified(link)})
+		if err != nil {
+			return written, err
+		}
+		if _, err := io.WriteString(file, Content(link)); err != nil {
+			return written, err
+		}
+		written++
+	}
+	return written, archive.Close()
+}
+
+// modified dates the .strm file when the link was generated, media servers sort recently added items by it
+func modified(link *types.Link) time.Time {
+	if link.CreatedAt.IsZero() {
+		return time.Now()
+	}
+	return link.CreatedAt
+}
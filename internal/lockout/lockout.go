@@ -0,0 +1,131 @@
+// Package lockout slows down guessing secrets like the hashes of links and the tokens of
+// the debug and export endpoints. Failed attempts are counted per key, like an IP or a
+// link. Once a key failed LOCKOUT_ATTEMPTS times in a row it's locked out for 30 seconds,
+// twice as long after every further failure, up to an hour. A key is forgotten a day
+// after its last failure. Keys can also be trusted after a success, so that the lockout
+// of a target doesn't apply to the clients that got it right before.
+package lockout
+
+import (
+	"EverythingSuckz/fsb/config"
+	"expvar"
+	"sync"
+	"time"
+)
+
+const (
+	baseLockout = 30 * time.Second
+	maxLockout  = time.Hour
+	forgetAfter = 24 * time.Hour
+	// AlertFailures is the number of failures of a key that admins are told about, once
+	AlertFailures = 50
+)
+
+// Failures counts the failed attempts by limiter, it's published at /debug/vars
+var Failures = expvar.NewMap("lockout_failures")
+
+type entry struct {
+	failures    int
+	lockedUntil time.Time
+	lastFailure time.Time
+}
+
+// Limiter counts the failed attempts of one kind of secret
+type Limiter struct {
+	name    string
+	mu      sync.Mutex
+	entries map[string]*entry
+	trusted map[string]time.Time
+	swept   time.Time
+}
+
+func New(name string) *Limiter {
+	return &Limiter{name: name, entries: make(map[string]*entry), trusted: make(map[string]time.Time)}
+}
+
+// Locked returns how long the key stays locked out, 0 if it isn't
+func (l *Limiter) Locked(key string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e, ok := l.entries[key]
+	if !ok {
+		return 0
+	}
+	return max(time.Until(e.lockedUntil), 0)
+}
+
+// Failed reports whether the key failed before and wasn't forgotten yet
+func (l *Limiter) Failed(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, ok := l.entries[key]
+	return ok
+}
+
+// Fail counts a failed attempt of the key and returns its failures in a row. Without
+// LOCKOUT_ATTEMPTS the attempts are counted, but keys aren't locked out.
+func (l *Limiter) Fail(key string) int {
+	Failures.Add(l.name, 1)
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.Sub(l.swept) > time.Hour {
+		l.sweep(now)
+	}
+	e, ok := l.entries[key]
+	if !ok {
+		e = &entry{}
+		l.entries[key] = e
+	}
+	e.failures++
+	e.lastFailure = now
+	attempts := config.ValueOf.LockoutAttempts
+	if attempts > 0 && e.failures >= attempts {
+		lockout := baseLockout
+		for i := attempts; i < e.failures && lockout < maxLockout; i++ {
+			lockout *= 2
+		}
+		e.lockedUntil = now.Add(min(lockout, maxLockout))
+	}
+	return e.failures
+}
+
+// Succeed forgets the failures of the key
+func (l *Limiter) Succeed(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.entries, key)
+}
+
+// Trust remembers that the key succeeded, for a day after its last success
+func (l *Limiter) Trust(key string) {
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.Sub(l.swept) > time.Hour {
+		l.sweep(now)
+	}
+	l.trusted[key] = now
+}
+
+// Trusted reports whether the key succeeded within the last day
+func (l *Limiter) Trusted(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	last, ok := l.trusted[key]
+	return ok && time.Since(last) <= forgetAfter
+}
+
+func (l *Limiter) sweep(now time.Time) {
+	l.swept = now
+	for key, e := range l.entries {
+		if now.Sub(e.lastFailure) > forgetAfter {
+			delete(l.entries, key)
+		}
+	}
+	for key, last := range l.trusted {
+		if now.Sub(last) > forgetAfter {
+			delete(l.trusted, key)
+		}
+	}
+}
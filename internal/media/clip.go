@@ -0,0 +1,116 @@
+package media
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/budget"
+	"EverythingSuckz/fsb/internal/utils"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// clipsDir is where remuxed clips are cached
+var clipsDir = filepath.Join(Dir, "clips")
+
+// ErrClipTooLong is returned for time ranges longer than CLIP_MAX_DURATION
+var ErrClipTooLong = errors.New("clip is too long")
+
+// ClipName returns the file name of the clip of the given time range
+func ClipName(start float64, end float64) string {
+	return fmt.Sprintf("%d-%d.mp4", int64(start*1000), int64(end*1000))
+}
+
+// ParseClipName parses a name returned by ClipName back into the time range
+func ParseClipName(name string) (float64, float64, error) {
+	startMs, endMs, ok := strings.Cut(strings.TrimSuffix(name, ".mp4"), "-")
+	if !ok || !strings.HasSuffix(name, ".mp4") {
+		return 0, 0, fmt.Errorf("invalid clip name %q", name)
+	}
+	start, err := strconv.ParseInt(startMs, 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	end, err := strconv.ParseInt(endMs, 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	if end <= start {
+		return 0, 0, fmt.Errorf("clip end must be after its start")
+	}
+	return float64(start) / 1000, float64(end) / 1000, nil
+}
+
+// CheckClipRange returns ErrClipTooLong if the time range is longer than CLIP_MAX_DURATION
+func CheckClipRange(start float64, end float64) error {
+	if limit := config.ValueOf.ClipMaxDuration; limit > 0 && end-start > float64(limit) {
+		return ErrClipTooLong
+	}
+	return nil
+}
+
+// CachedClip returns the path of the clip of the given time range if it was already remuxed
+func CachedClip(key string, start float64, end float64) (string, bool) {
+	out := filepath.Join(clipsDir, filepath.Base(key), ClipName(start, end))
+	if _, err := os.Stat(out); err != nil {
+		return "", false
+	}
+	return out, true
+}
+
+// Clip remuxes the given time range of the media at url without re-encoding it and
+// returns the path of the clip. Clips are cached, so calling it again is cheap.
+// Remuxing waits for the memory of the stream it reads in MEMORY_BUDGET first and
+// returns budget.ErrExhausted if it doesn't free up.
+func Clip(ctx context.Context, url string, key string, start float64, end float64) (string, error) {
+	if out, ok := CachedClip(key, start, end); ok {
+		return out, nil
+	}
+	if err := CheckClipRange(start, end); err != nil {
+		return "", err
+	}
+	// internal streams don't reserve memory, the clip does it for the stream it reads
+	release, err := budget.Reserve(ctx, utils.StreamMemory())
+	if err != nil {
+		return "", err
+	}
+	defer release()
+	dir := filepath.Join(clipsDir, filepath.Base(key))
+	out := filepath.Join(dir, ClipName(start, end))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	// write into a temporary file first, so incomplete clips are never served
+	tmp, err := os.CreateTemp(dir, "clip-*.tmp")
+	if err != nil {
+		return "", err
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+	args := []string{"-hide_banner", "-loglevel", "error", "-y", "-ss", formatSeconds(start)}
+	args = append(args, inputArgs(url)...)
+	args = append(args,
+		"-t", formatSeconds(end-start),
+		"-map", "0:v?", "-map", "0:a?",
+		"-c", "copy",
+		"-avoid_negative_ts", "make_zero",
+		"-movflags", "+faststart",
+		"-f", "mp4",
+		tmp.Name(),
+	)
+	if _, err := run(ctx, config.ValueOf.FFmpegPath, args...); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), out); err != nil {
+		return "", err
+	}
+	return out, nil
+}
+
+// ClipURL returns the public link of the clip of the given time range
+func ClipURL(tenantID uint, messageID int, hash string, start float64, end float64) string {
+	return utils.TenantURL(tenantID, fmt.Sprintf("/clip/%d/%s?hash=%s", messageID, ClipName(start, end), hash))
+}
@@ -0,0 +1,127 @@
+package media
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/utils"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrDisabled is returned when ffmpeg based features are disabled in the config
+var ErrDisabled = errors.New("ffmpeg features are disabled")
+
+// limiter caps the number of processes of a kind running at the same time
+type limiter struct {
+	once  sync.Once
+	size  func() int
+	slots chan struct{}
+}
+
+var (
+	ffmpegJobs    = &limiter{size: func() int { return config.ValueOf.FFmpegMaxJobs }}
+	transcodeJobs = &limiter{size: func() int { return config.ValueOf.TranscodeMaxJobs }}
+)
+
+// Enabled reports whether ffmpeg based features are enabled
+func Enabled() bool {
+	return config.ValueOf.FFmpegEnabled
+}
+
+// acquire waits for a free slot and returns a func releasing it
+func (l *limiter) acquire(ctx context.Context) (func(), error) {
+	l.once.Do(func() {
+		size := l.size()
+		if size <= 0 {
+			size = 1
+		}
+		l.slots = make(chan struct{}, size)
+	})
+	select {
+	case l.slots <- struct{}{}:
+		return func() { <-l.slots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// run executes a binary with the given arguments, limited to FFMPEG_MAX_JOBS concurrent processes
+func run(ctx context.Context, binary string, args ...string) ([]byte, error) {
+	return runLimited(ctx, ffmpegJobs, binary, args...)
+}
+
+// runLimited executes a binary with the given arguments once the limiter has a free slot
+func runLimited(ctx context.Context, jobs *limiter, binary string, args ...string) ([]byte, error) {
+	if !Enabled() {
+		return nil, ErrDisabled
+	}
+	release, err := jobs.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, binary, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		message := strings.TrimSpace(stderr.String())
+		if len(message) > 500 {
+			message = message[len(message)-500:]
+		}
+		return nil, fmt.Errorf("%s failed: %w: %s", binary, err, message)
+	}
+	return stdout.Bytes(), nil
+}
+
+// inputArgs returns the ffmpeg/ffprobe arguments reading the given internal stream URL
+func inputArgs(url string) []string {
+	return []string{
+		"-headers", fmt.Sprintf("%s: %s\r\n", utils.InternalHeader, utils.InternalToken),
+		"-i", url,
+	}
+}
+
+func formatSeconds(seconds float64) string {
+	return strconv.FormatFloat(seconds, 'f', 3, 64)
+}
+
+// tempFile returns the path of a new empty temporary file with the given pattern
+func tempFile(pattern string) (string, error) {
+	f, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return "", err
+	}
+	f.Close()
+	return f.Name(), nil
+}
+
+// Preview renders a short, downscaled clip of the video at url starting at start.
+// The caller is responsible for removing the returned file.
+func Preview(ctx context.Context, url string, start float64, duration float64) (string, error) {
+	out, err := tempFile("fsb-preview-*.mp4")
+	if err != nil {
+		return "", err
+	}
+	args := []string{"-hide_banner", "-loglevel", "error", "-y", "-ss", formatSeconds(start)}
+	args = append(args, inputArgs(url)...)
+	args = append(args,
+		"-t", formatSeconds(duration),
+		"-vf", "scale='min(854,iw)':-2",
+		"-c:v", "libx264", "-preset", "veryfast", "-crf", "28",
+		"-c:a", "aac", "-b:a", "96k",
+		"-movflags", "+faststart",
+		out,
+	)
+	if _, err := run(ctx, config.ValueOf.FFmpegPath, args...); err != nil {
+		os.Remove(out)
+		return "", err
+	}
+	return out, nil
+}
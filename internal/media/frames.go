@@ -0,0 +1,167 @@
+package media
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/crash"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Dir holds the frames, transcoded renditions and clips generated from the files
+const Dir = "data"
+
+// framesDir is where extracted frames are kept so they can be served over HTTP
+var framesDir = filepath.Join(Dir, "frames")
+
+// mediaMaxAge is how long generated media files are kept
+const mediaMaxAge = 24 * time.Hour
+
+// FramePath returns the path of a frame extracted from the link with the given storage key
+func FramePath(key string, name string) string {
+	return filepath.Join(framesDir, filepath.Base(key), filepath.Base(name))
+}
+
+// ExtractFrames extracts a JPEG frame of the video at url for every timestamp and
+// returns the file names of the frames, relative to the message's frame directory.
+func ExtractFrames(ctx context.Context, url string, key string, timestamps []float64) ([]string, error) {
+	dir := filepath.Join(framesDir, filepath.Base(key))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(timestamps))
+	for _, timestamp := range timestamps {
+		name := fmt.Sprintf("%d.jpg", int64(timestamp*1000))
+		args := []string{"-hide_banner", "-loglevel", "error", "-y", "-ss", formatSeconds(timestamp)}
+		args = append(args, inputArgs(url)...)
+		args = append(args, "-frames:v", "1", "-q:v", "3", filepath.Join(dir, name))
+		if _, err := run(ctx, config.ValueOf.FFmpegPath, args...); err != nil {
+			return names, err
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// EvenTimestamps returns count timestamps spread evenly over a video of the given duration
+func EvenTimestamps(duration float64, count int) []float64 {
+	timestamps := make([]float64, count)
+	for i := range timestamps {
+		timestamps[i] = duration * float64(i+1) / float64(count+1)
+	}
+	return timestamps
+}
+
+// StartJanitor periodically removes extracted frames, transcoded renditions and clips older than a day
+func StartJanitor(log *zap.Logger) {
+	log = log.Named("media")
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for range ticker.C {
+			removeOld(log, framesDir, mediaMaxAge)
+			removeOld(log, hlsDir, mediaMaxAge)
+			removeOld(log, clipsDir, mediaMaxAge)
+		}
+	}()
+}
+
+// removeOld removes the sub directories of dir that weren't modified within maxAge
+func removeOld(log *zap.Logger, dir string, maxAge time.Duration) {
+	defer crash.Recover("janitor")
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || time.Since(info.ModTime()) < maxAge {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(dir, entry.Name())); err != nil {
+			log.Error("Failed to remove old media files", zap.String("path", entry.Name()), zap.Error(err))
+		}
+	}
+}
+
+// evictGrace is how recently media files must have been used to be kept while the disk is low,
+// they're likely still being played
+const evictGrace = 10 * time.Minute
+
+// Evict removes the frames, renditions and clips that were used the longest time ago, until
+// enough reports that there's enough free space again. It returns the bytes it removed.
+func Evict(log *zap.Logger, enough func() bool) int64 {
+	defer crash.Recover("janitor")
+	type entry struct {
+		path     string
+		modified time.Time
+	}
+	var entries []entry
+	for _, dir := range []string{framesDir, hlsDir, clipsDir} {
+		children, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, child := range children {
+			if info, err := child.Info(); err == nil && time.Since(info.ModTime()) >= evictGrace {
+				entries = append(entries, entry{filepath.Join(dir, child.Name()), info.ModTime()})
+			}
+		}
+	}
+	slices.SortFunc(entries, func(a, b entry) int {
+		return a.modified.Compare(b.modified)
+	})
+	var removed int64
+	for _, e := range entries {
+		if enough() {
+			break
+		}
+		size := dirSize(e.path)
+		if err := os.RemoveAll(e.path); err != nil {
+			log.Error("Failed to evict media files", zap.String("path", e.path), zap.Error(err))
+			continue
+		}
+		removed += size
+	}
+	return removed
+}
+
+// dirSize returns the bytes of the files in the directory
+func dirSize(dir string) int64 {
+	var size int64
+	filepath.WalkDir(dir, func(_ string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			if info, err := d.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	return size
+}
+
+// Thumbnail returns the path of a thumbnail of the video at url, a frame taken
+// near its start. The thumbnail is extracted once and kept with the other frames.
+func Thumbnail(ctx context.Context, url string, key string) (string, error) {
+	thumbnail := FramePath(key, "thumb.jpg")
+	if _, err := os.Stat(thumbnail); err == nil {
+		return thumbnail, nil
+	}
+	at := 10.0
+	if duration, err := Duration(ctx, url); err == nil && duration*0.1 < at {
+		at = duration * 0.1
+	}
+	names, err := ExtractFrames(ctx, url, key, []float64{at})
+	if err != nil {
+		return "", err
+	}
+	if err := os.Rename(FramePath(key, names[0]), thumbnail); err != nil {
+		return "", err
+	}
+	return thumbnail, nil
+}
@@ -0,0 +1,195 @@
+package media
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/budget"
+	"EverythingSuckz/fsb/internal/crash"
+	"EverythingSuckz/fsb/internal/utils"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// hlsDir is where transcoded renditions are cached
+var hlsDir = filepath.Join(Dir, "hls")
+
+// Rendition is one quality level of the adaptive stream
+type Rendition struct {
+	Height  int
+	BitRate int // video bitrate in kb/s
+}
+
+// ladder lists the renditions that may be generated, from lowest to highest quality
+var ladder = []Rendition{
+	{Height: 360, BitRate: 800},
+	{Height: 480, BitRate: 1400},
+	{Height: 720, BitRate: 2800},
+	{Height: 1080, BitRate: 5000},
+	{Height: 1440, BitRate: 9000},
+	{Height: 2160, BitRate: 16000},
+}
+
+const audioBitRate = 128
+
+// transcodeMemory is the memory reserved from MEMORY_BUDGET for a transcode job, ffmpeg
+// and the stream it reads
+const transcodeMemory = 256 * 1024 * 1024
+
+var (
+	// running holds the rendition directories currently being transcoded
+	running   = make(map[string]bool)
+	failed    = make(map[string]error)
+	runningMu sync.Mutex
+)
+
+// Renditions returns the renditions generated for the video, never upscaling it
+// and never exceeding TRANSCODE_MAX_HEIGHT.
+func Renditions(info *Info) []Rendition {
+	if len(info.Video) == 0 {
+		return nil
+	}
+	maxHeight := info.Video[0].Height
+	if limit := config.ValueOf.TranscodeMaxHeight; limit > 0 && limit < maxHeight {
+		maxHeight = limit
+	}
+	var renditions []Rendition
+	for _, rendition := range ladder {
+		if rendition.Height <= maxHeight {
+			renditions = append(renditions, rendition)
+		}
+	}
+	if len(renditions) == 0 {
+		// smaller than the lowest rendition, keep the source height
+		renditions = append(renditions, Rendition{Height: maxHeight &^ 1, BitRate: ladder[0].BitRate})
+	}
+	return renditions
+}
+
+// MasterPlaylist returns the HLS master playlist of the video. query is appended
+// to the URI of each rendition playlist.
+func MasterPlaylist(info *Info, query string) string {
+	var sb strings.Builder
+	sb.WriteString("#EXTM3U\n#EXT-X-VERSION:3\n")
+	source := info.Video[0]
+	for _, rendition := range Renditions(info) {
+		width := rendition.Height
+		if source.Height > 0 {
+			width = source.Width * rendition.Height / source.Height
+		}
+		sb.WriteString(fmt.Sprintf("#EXT-X-STREAM-INF:BANDWIDTH=%d,RESOLUTION=%dx%d\n",
+			(rendition.BitRate+audioBitRate)*1000, width&^1, rendition.Height))
+		sb.WriteString(fmt.Sprintf("%dp/index.m3u8%s\n", rendition.Height, query))
+	}
+	return sb.String()
+}
+
+// HLSFile returns the path of a file of a cached rendition
+func HLSFile(key string, height int, name string) string {
+	return filepath.Join(hlsDir, filepath.Base(key), fmt.Sprintf("%dp", height), filepath.Base(name))
+}
+
+// RenditionPlaylist starts transcoding the rendition if it isn't cached yet and
+// waits until its playlist is available. The returned path points to the playlist,
+// which keeps growing while the transcode is running.
+func RenditionPlaylist(ctx context.Context, url string, key string, rendition Rendition) (string, error) {
+	playlist := HLSFile(key, rendition.Height, "index.m3u8")
+	dir := filepath.Dir(playlist)
+	if _, err := os.Stat(filepath.Join(dir, "complete")); err == nil {
+		return playlist, nil
+	}
+	if err := startTranscode(ctx, url, dir, rendition); err != nil {
+		return "", err
+	}
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		if _, err := os.Stat(playlist); err == nil {
+			return playlist, nil
+		}
+		runningMu.Lock()
+		isRunning, err := running[dir], failed[dir]
+		runningMu.Unlock()
+		if !isRunning {
+			if err == nil {
+				err = errors.New("transcode finished without a playlist")
+			}
+			return "", err
+		}
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// startTranscode transcodes the rendition into dir in the background, unless it's already running.
+// It waits for its share of MEMORY_BUDGET first and returns budget.ErrExhausted if it doesn't free up.
+func startTranscode(ctx context.Context, url string, dir string, rendition Rendition) error {
+	runningMu.Lock()
+	isRunning := running[dir]
+	runningMu.Unlock()
+	if isRunning {
+		return nil
+	}
+	release, err := budget.Reserve(ctx, transcodeMemory)
+	if err != nil {
+		return err
+	}
+	runningMu.Lock()
+	defer runningMu.Unlock()
+	if running[dir] {
+		release()
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		release()
+		return err
+	}
+	running[dir] = true
+	delete(failed, dir)
+	go func() {
+		defer crash.Recover("transcoder")
+		defer release()
+		ctx, cancel := context.WithTimeout(context.Background(), 6*time.Hour)
+		defer cancel()
+		args := []string{"-hide_banner", "-loglevel", "error", "-y"}
+		args = append(args, inputArgs(url)...)
+		args = append(args,
+			"-map", "0:v:0", "-map", "0:a:0?",
+			"-vf", fmt.Sprintf("scale=-2:%d", rendition.Height),
+			"-c:v", "libx264", "-preset", "veryfast", "-profile:v", "high",
+			"-b:v", fmt.Sprintf("%dk", rendition.BitRate),
+			"-maxrate", fmt.Sprintf("%dk", rendition.BitRate*3/2),
+			"-bufsize", fmt.Sprintf("%dk", rendition.BitRate*2),
+			"-force_key_frames", "expr:gte(t,n_forced*6)",
+			"-c:a", "aac", "-b:a", fmt.Sprintf("%dk", audioBitRate), "-ac", "2",
+			"-f", "hls",
+			"-hls_time", "6",
+			"-hls_playlist_type", "event",
+			"-hls_flags", "temp_file",
+			"-hls_segment_filename", filepath.Join(dir, "seg_%05d.ts"),
+			filepath.Join(dir, "index.m3u8"),
+		)
+		_, err := runLimited(ctx, transcodeJobs, config.ValueOf.FFmpegPath, args...)
+		if err == nil {
+			err = os.WriteFile(filepath.Join(dir, "complete"), nil, 0644)
+		}
+		runningMu.Lock()
+		defer runningMu.Unlock()
+		delete(running, dir)
+		if err != nil {
+			utils.Logger.Error("Failed to transcode rendition", zap.String("dir", dir), zap.Error(err))
+			failed[dir] = err
+			os.RemoveAll(dir)
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,246 @@
+package media
+
+import (
+	"EverythingSuckz/fsb/config"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/coocood/freecache"
+)
+
+// Info describes the container and the streams of a media file
+type Info struct {
+	Container string           `json:"container"`
+	Duration  float64          `json:"duration"`
+	BitRate   int64            `json:"bit_rate"`
+	Video     []VideoStream    `json:"video"`
+	Audio     []AudioStream    `json:"audio"`
+	Subtitles []SubtitleStream `json:"subtitles"`
+	Chapters  []Chapter        `json:"chapters"`
+}
+
+// VideoStream describes a video stream of a media file
+type VideoStream struct {
+	Index     int     `json:"index"`
+	Codec     string  `json:"codec"`
+	Profile   string  `json:"profile,omitempty"`
+	Width     int     `json:"width"`
+	Height    int     `json:"height"`
+	FrameRate float64 `json:"frame_rate"`
+	BitRate   int64   `json:"bit_rate,omitempty"`
+}
+
+// AudioStream describes an audio track of a media file
+type AudioStream struct {
+	Index      int    `json:"index"`
+	Codec      string `json:"codec"`
+	Channels   int    `json:"channels"`
+	Layout     string `json:"channel_layout,omitempty"`
+	SampleRate int    `json:"sample_rate"`
+	BitRate    int64  `json:"bit_rate,omitempty"`
+	Language   string `json:"language,omitempty"`
+	Title      string `json:"title,omitempty"`
+	Default    bool   `json:"default"`
+}
+
+// SubtitleStream describes a subtitle track of a media file
+type SubtitleStream struct {
+	Index    int    `json:"index"`
+	Codec    string `json:"codec"`
+	Language string `json:"language,omitempty"`
+	Title    string `json:"title,omitempty"`
+}
+
+// Chapter is a chapter marker from the container metadata
+type Chapter struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Title string  `json:"title"`
+}
+
+// browser playable containers and codecs, used to decide if a file can be played directly
+var (
+	directContainers  = []string{"mp4", "mov", "webm", "matroska"}
+	directVideoCodecs = []string{"h264", "vp8", "vp9", "av1"}
+	directAudioCodecs = []string{"aac", "mp3", "opus", "vorbis", "flac"}
+)
+
+// DirectPlayable reports whether browsers can usually play the file without transcoding
+func (i *Info) DirectPlayable() bool {
+	if !containsAny(i.Container, directContainers) {
+		return false
+	}
+	for _, v := range i.Video {
+		if !containsAny(v.Codec, directVideoCodecs) {
+			return false
+		}
+	}
+	for _, a := range i.Audio {
+		if a.Default && !containsAny(a.Codec, directAudioCodecs) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsAny(value string, list []string) bool {
+	for _, item := range list {
+		if strings.Contains(value, item) {
+			return true
+		}
+	}
+	return false
+}
+
+// ffprobeOutput is the subset of the ffprobe JSON output we care about
+type ffprobeOutput struct {
+	Format struct {
+		FormatName string `json:"format_name"`
+		Duration   string `json:"duration"`
+		BitRate    string `json:"bit_rate"`
+	} `json:"format"`
+	Streams []struct {
+		Index         int    `json:"index"`
+		CodecType     string `json:"codec_type"`
+		CodecName     string `json:"codec_name"`
+		Profile       string `json:"profile"`
+		Width         int    `json:"width"`
+		Height        int    `json:"height"`
+		AvgFrameRate  string `json:"avg_frame_rate"`
+		BitRate       string `json:"bit_rate"`
+		SampleRate    string `json:"sample_rate"`
+		Channels      int    `json:"channels"`
+		ChannelLayout string `json:"channel_layout"`
+		Disposition   struct {
+			Default int `json:"default"`
+		} `json:"disposition"`
+		Tags struct {
+			Language string `json:"language"`
+			Title    string `json:"title"`
+		} `json:"tags"`
+	} `json:"streams"`
+	Chapters []struct {
+		StartTime string `json:"start_time"`
+		EndTime   string `json:"end_time"`
+		Tags      struct {
+			Title string `json:"title"`
+		} `json:"tags"`
+	} `json:"chapters"`
+}
+
+// probeCacheTTL is how long the result of probing a URL is reused
+const probeCacheTTL = time.Hour
+
+// probeCache holds the JSON encoded results of recent probes, evicting the
+// oldest ones once it's full
+var probeCache = freecache.NewCache(4 * 1024 * 1024)
+
+// Probe analyses the media at url with ffprobe. Only the parts of the file
+// ffprobe needs are fetched, and results are cached per URL.
+func Probe(ctx context.Context, url string) (*Info, error) {
+	if data, err := probeCache.Get([]byte(url)); err == nil {
+		var info Info
+		if err := json.Unmarshal(data, &info); err == nil {
+			return &info, nil
+		}
+	}
+	args := []string{"-v", "error", "-show_format", "-show_streams", "-show_chapters", "-of", "json"}
+	args = append(args, inputArgs(url)...)
+	out, err := run(ctx, config.ValueOf.FFprobePath, args...)
+	if err != nil {
+		return nil, err
+	}
+	var result ffprobeOutput
+	if err := json.Unmarshal(out, &result); err != nil {
+		return nil, err
+	}
+	info := &Info{
+		Container: result.Format.FormatName,
+		Duration:  parseFloat(result.Format.Duration),
+		BitRate:   parseInt(result.Format.BitRate),
+	}
+	for _, s := range result.Streams {
+		switch s.CodecType {
+		case "video":
+			info.Video = append(info.Video, VideoStream{
+				Index:     s.Index,
+				Codec:     s.CodecName,
+				Profile:   s.Profile,
+				Width:     s.Width,
+				Height:    s.Height,
+				FrameRate: parseRate(s.AvgFrameRate),
+				BitRate:   parseInt(s.BitRate),
+			})
+		case "audio":
+			info.Audio = append(info.Audio, AudioStream{
+				Index:      s.Index,
+				Codec:      s.CodecName,
+				Channels:   s.Channels,
+				Layout:     s.ChannelLayout,
+				SampleRate: int(parseInt(s.SampleRate)),
+				BitRate:    parseInt(s.BitRate),
+				Language:   s.Tags.Language,
+				Title:      s.Tags.Title,
+				Default:    s.Disposition.Default == 1,
+			})
+		case "subtitle":
+			info.Subtitles = append(info.Subtitles, SubtitleStream{
+				Index:    s.Index,
+				Codec:    s.CodecName,
+				Language: s.Tags.Language,
+				Title:    s.Tags.Title,
+			})
+		}
+	}
+	for i, c := range result.Chapters {
+		title := c.Tags.Title
+		if title == "" {
+			title = fmt.Sprintf("Chapter %d", i+1)
+		}
+		info.Chapters = append(info.Chapters, Chapter{
+			Start: parseFloat(c.StartTime),
+			End:   parseFloat(c.EndTime),
+			Title: title,
+		})
+	}
+	if data, err := json.Marshal(info); err == nil {
+		probeCache.Set([]byte(url), data, int(probeCacheTTL.Seconds()))
+	}
+	return info, nil
+}
+
+// Duration returns the duration of the media at url in seconds
+func Duration(ctx context.Context, url string) (float64, error) {
+	info, err := Probe(ctx, url)
+	if err != nil {
+		return 0, err
+	}
+	return info.Duration, nil
+}
+
+func parseFloat(value string) float64 {
+	f, _ := strconv.ParseFloat(value, 64)
+	return f
+}
+
+func parseInt(value string) int64 {
+	i, _ := strconv.ParseInt(value, 10, 64)
+	return i
+}
+
+// parseRate parses ffprobe frame rates like "24000/1001"
+func parseRate(value string) float64 {
+	num, den, ok := strings.Cut(value, "/")
+	if !ok {
+		return parseFloat(value)
+	}
+	d := parseFloat(den)
+	if d == 0 {
+		return 0
+	}
+	return parseFloat(num) / d
+}
@@ -0,0 +1,331 @@
+// Package oidc signs users in to the web app and the API with an external OpenID Connect
+// provider, like Keycloak, Okta, Google or Azure AD, for organizations that require single
+// sign-on. Accounts of the provider are mapped to Telegram users once, with the login link
+// of /sso, and sign in as them afterwards. It implements the authorization code flow with
+// PKCE, and the state is bound to the browser that started the sign in with a cookie. ID
+// tokens come straight from the token endpoint over TLS, which validates their issuer in
+// place of their signature, as the OpenID Connect spec allows. That's why the issuer and
+// its endpoints must use https.
+package oidc
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/version"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// loginTTL is how long users have to sign in at the provider
+const loginTTL = 10 * time.Minute
+
+// StateCookieName is the cookie that binds a sign in to the browser that started it
+const StateCookieName = "fsb_oidc_state"
+
+// discoveryTTL is how long the configuration of the provider is cached
+const discoveryTTL = 24 * time.Hour
+
+var (
+	ErrUnknownState = errors.New("the sign in expired or was started in another browser, try again")
+	ErrInvalidToken = errors.New("the provider returned an invalid ID token")
+)
+
+// Identity is the account of the provider a user signed in with
+type Identity struct {
+	Issuer  string
+	Subject string
+	Email   string
+	Name    string
+}
+
+// Login is a sign in that was started and waits for the provider to redirect back
+type Login struct {
+	LinkUserID int64  // the Telegram user the account is linked to, 0 to sign in with a linked account
+	Next       string // the path to open once signed in
+	nonce      string
+	verifier   string
+	expires    time.Time
+}
+
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+}
+
+var (
+	log    *zap.Logger
+	client = &http.Client{Timeout: 15 * time.Second}
+
+	provider = struct {
+		sync.Mutex
+		discovery *discovery
+		fetched   time.Time
+	}{}
+
+	logins = struct {
+		sync.Mutex
+		pending map[string]*Login
+	}{pending: make(map[string]*Login)}
+)
+
+// Enabled reports whether OIDC_ISSUER_URL and OIDC_CLIENT_ID are set
+func Enabled() bool {
+	return config.ValueOf.OIDCIssuer != "" && config.ValueOf.OIDCClientID != ""
+}
+
+// Load checks the configuration of the provider
+func Load(l *zap.Logger) error {
+	log = l.Named("oidc")
+	if config.ValueOf.OIDCIssuer == "" && config.ValueOf.OIDCClientID == "" {
+		return nil
+	}
+	if !Enabled() {
+		return errors.New("OIDC_ISSUER_URL and OIDC_CLIENT_ID must be set together")
+	}
+	if !isHTTPS(config.ValueOf.OIDCIssuer) {
+		return errors.New("OIDC_ISSUER_URL must be an https URL, ID tokens are trusted because they come from the provider over TLS")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	found, err := fetchDiscovery(ctx)
+	if err != nil {
+		// the provider may be down for a moment, the configuration is fetched again on sign in
+		log.Warn("Failed to fetch the configuration of the OIDC provider", zap.Error(err))
+		return nil
+	}
+	log.Info("Signing in with OIDC", zap.String("issuer", found.Issuer))
+	return nil
+}
+
+// fetchDiscovery returns the endpoints of the provider from its discovery document
+func fetchDiscovery(ctx context.Context) (*discovery, error) {
+	provider.Lock()
+	defer provider.Unlock()
+	if provider.discovery != nil && time.Since(provider.fetched) < discoveryTTL {
+		return provider.discovery, nil
+	}
+	issuer := strings.TrimSuffix(config.ValueOf.OIDCIssuer, "/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "fsb/"+version.Version)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("discovery document answered with %s", resp.Status)
+	}
+	var found discovery
+	if err := json.NewDecoder(resp.Body).Decode(&found); err != nil {
+		return nil, fmt.Errorf("invalid discovery document: %w", err)
+	}
+	if strings.TrimSuffix(found.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("the discovery document is of the issuer %s, not %s", found.Issuer, config.ValueOf.OIDCIssuer)
+	}
+	if found.AuthorizationEndpoint == "" || found.TokenEndpoint == "" {
+		return nil, errors.New("the discovery document has no authorization or token endpoint")
+	}
+	if !isHTTPS(found.AuthorizationEndpoint) || !isHTTPS(found.TokenEndpoint) {
+		return nil, errors.New("the authorization and token endpoints of the provider must use https")
+	}
+	provider.discovery, provider.fetched = &found, time.Now()
+	return &found, nil
+}
+
+// isHTTPS reports whether the URL uses https
+func isHTTPS(raw string) bool {
+	parsed, err := url.Parse(raw)
+	return err == nil && parsed.Scheme == "https" && parsed.Host != ""
+}
+
+// Start starts a sign in and returns the URL of the provider to redirect the browser to. The
+// state is set in the state cookie of the browser, the provider redirects back to redirectURI
+// with it and Finish compares them.
+func Start(ctx context.Context, w http.ResponseWriter, login *Login, redirectURI string) (string, error) {
+	found, err := fetchDiscovery(ctx)
+	if err != nil {
+		return "", err
+	}
+	state := random()
+	login.nonce, login.verifier = random(), random()
+	login.expires = time.Now().Add(loginTTL)
+	logins.Lock()
+	for key, pending := range logins.pending {
+		if time.Now().After(pending.expires) {
+			delete(logins.pending, key)
+		}
+	}
+	logins.pending[state] = login
+	logins.Unlock()
+	http.SetCookie(w, &http.Cookie{
+		Name:     StateCookieName,
+		Value:    state,
+		Path:     cookiePath(),
+		MaxAge:   int(loginTTL / time.Second),
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(config.Runtime.Host(), "https://"),
+		// the provider redirects back with a cross-site navigation, which strict cookies miss
+		SameSite: http.SameSiteLaxMode,
+	})
+	challenge := sha256.Sum256([]byte(login.verifier))
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {config.ValueOf.OIDCClientID},
+		"redirect_uri":          {redirectURI},
+		"scope":                 {"openid email profile"},
+		"state":                 {state},
+		"nonce":                 {login.nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	separator := "?"
+	if strings.Contains(found.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return found.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// cookiePath is the path of the state cookie, the routes of the sign in under BASE_PATH
+func cookiePath() string {
+	return config.ValueOf.BasePath + "/oidc/"
+}
+
+// Finish exchanges the code the provider redirected back with for the identity of the
+// account, and returns the sign in it finishes. The state must be the one in the state
+// cookie of the browser, so that a sign in can't be finished in another browser.
+func Finish(ctx context.Context, w http.ResponseWriter, r *http.Request, redirectURI string) (*Login, *Identity, error) {
+	state, code := r.URL.Query().Get("state"), r.URL.Query().Get("code")
+	cookie, err := r.Cookie(StateCookieName)
+	if err != nil || state == "" || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) != 1 {
+		return nil, nil, ErrUnknownState
+	}
+	http.SetCookie(w, &http.Cookie{Name: StateCookieName, Value: "", Path: cookiePath(), MaxAge: -1})
+	logins.Lock()
+	login, ok := logins.pending[state]
+	delete(logins.pending, state)
+	logins.Unlock()
+	if !ok || time.Now().After(login.expires) {
+		return nil, nil, ErrUnknownState
+	}
+	found, err := fetchDiscovery(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {redirectURI},
+		"code_verifier": {login.verifier},
+	}
+	// public clients without a secret authenticate with PKCE alone
+	if config.ValueOf.OIDCSecret == "" {
+		form.Set("client_id", config.ValueOf.OIDCClientID)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, found.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "fsb/"+version.Version)
+	if config.ValueOf.OIDCSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(config.ValueOf.OIDCClientID), url.QueryEscape(config.ValueOf.OIDCSecret))
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	var tokens struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokens); err != nil {
+		return nil, nil, fmt.Errorf("invalid token response: %w", err)
+	}
+	if tokens.Error != "" {
+		return nil, nil, fmt.Errorf("the provider refused the sign in: %s %s", tokens.Error, tokens.ErrorDescription)
+	}
+	if resp.StatusCode != http.StatusOK || tokens.IDToken == "" {
+		return nil, nil, fmt.Errorf("token endpoint answered with %s", resp.Status)
+	}
+	identity, err := parseIDToken(tokens.IDToken, found.Issuer, login.nonce)
+	if err != nil {
+		return nil, nil, err
+	}
+	return login, identity, nil
+}
+
+// audience is the aud claim, a string or a list of them
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = []string{single}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(a))
+}
+
+// parseIDToken returns the identity in the claims of the ID token after checking its
+// issuer, audience, expiry and nonce
+func parseIDToken(token string, issuer string, nonce string) (*Identity, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	var claims struct {
+		Issuer   string   `json:"iss"`
+		Subject  string   `json:"sub"`
+		Audience audience `json:"aud"`
+		Expires  int64    `json:"exp"`
+		Nonce    string   `json:"nonce"`
+		Email    string   `json:"email"`
+		Name     string   `json:"name"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+	switch {
+	case claims.Issuer != issuer:
+		return nil, fmt.Errorf("%w: it was issued by %s", ErrInvalidToken, claims.Issuer)
+	case !slices.Contains(claims.Audience, config.ValueOf.OIDCClientID):
+		return nil, fmt.Errorf("%w: it's for another client", ErrInvalidToken)
+	case time.Now().Unix() > claims.Expires:
+		return nil, fmt.Errorf("%w: it expired", ErrInvalidToken)
+	case claims.Nonce != nonce:
+		return nil, fmt.Errorf("%w: its nonce doesn't match", ErrInvalidToken)
+	case claims.Subject == "":
+		return nil, fmt.Errorf("%w: it has no subject", ErrInvalidToken)
+	}
+	return &Identity{Issuer: claims.Issuer, Subject: claims.Subject, Email: claims.Email, Name: claims.Name}, nil
+}
+
+func random() string {
+	secret := make([]byte, 32)
+	rand.Read(secret)
+	return base64.RawURLEncoding.EncodeToString(secret)
+}
@@ -0,0 +1,93 @@
+package oidc
+
+import (
+	"EverythingSuckz/fsb/config"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// fakeProvider answers the discovery and token requests like an OIDC provider, the ID tokens
+// it issues have the nonce of the last sign in started
+type fakeProvider struct {
+	*httptest.Server
+	nonce string
+}
+
+func newFakeProvider(t *testing.T) *fakeProvider {
+	p := &fakeProvider{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(discovery{Issuer: p.URL, AuthorizationEndpoint: p.URL + "/authorize", TokenEndpoint: p.URL + "/token"})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		claims, _ := json.Marshal(map[string]any{
+			"iss": p.URL, "sub": "account", "aud": config.ValueOf.OIDCClientID,
+			"exp": time.Now().Add(time.Minute).Unix(), "nonce": p.nonce,
+		})
+		json.NewEncoder(w).Encode(map[string]string{"id_token": "e30." + base64.RawURLEncoding.EncodeToString(claims) + ".sig"})
+	})
+	p.Server = httptest.NewTLSServer(mux)
+	t.Cleanup(p.Close)
+	return p
+}
+
+func TestStateCookieIsSentToTheCallback(t *testing.T) {
+	log = zap.NewNop()
+	for _, basePath := range []string{"", "/fsb"} {
+		t.Run("BASE_PATH="+basePath, func(t *testing.T) {
+			p := newFakeProvider(t)
+			client = p.Client()
+			provider.discovery = nil
+			config.ValueOf.OIDCIssuer, config.ValueOf.OIDCClientID = p.URL, "fsb"
+			config.ValueOf.BasePath = basePath
+			t.Cleanup(func() { config.ValueOf.BasePath = "" })
+			base := "https://fsb.example" + basePath
+			redirectURI := base + "/oidc/callback"
+
+			started := httptest.NewRecorder()
+			authorization, err := Start(context.Background(), started, &Login{Next: "/app"}, redirectURI)
+			if err != nil {
+				t.Fatal(err)
+			}
+			query := must(url.Parse(authorization)).Query()
+			p.nonce = query.Get("nonce")
+			// the browser keeps the cookie of the login route and sends it where its path allows
+			jar, _ := cookiejar.New(nil)
+			jar.SetCookies(must(url.Parse(base+"/oidc/login")), started.Result().Cookies())
+
+			callback := httptest.NewRequest(http.MethodGet, redirectURI+"?code=code&state="+url.QueryEscape(query.Get("state")), nil)
+			for _, cookie := range jar.Cookies(callback.URL) {
+				callback.AddCookie(cookie)
+			}
+			finished := httptest.NewRecorder()
+			login, identity, err := Finish(context.Background(), finished, callback, redirectURI)
+			if err != nil {
+				t.Fatalf("failed to finish the sign in: %v", err)
+			}
+			if login.Next != "/app" || identity.Subject != "account" {
+				t.Errorf("finished the sign in to %s of %s", login.Next, identity.Subject)
+			}
+			for _, cookie := range finished.Result().Cookies() {
+				if cookie.Name == StateCookieName && (cookie.Path != basePath+"/oidc/" || cookie.MaxAge >= 0) {
+					t.Errorf("the state cookie is cleared at %s", cookie.Path)
+				}
+			}
+		})
+	}
+}
+
+func must[T any](value T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+	return value
+}
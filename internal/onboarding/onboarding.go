@@ -0,0 +1,152 @@
+// Package onboarding walks new users through their first steps with the bot: sending a test
+// file, opening it in the web player and choosing their settings. The current step of every
+// user is stored in the database, so the walkthrough continues where it stopped after restarts.
+package onboarding
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/database"
+	"fmt"
+	"strings"
+
+	"github.com/gotd/td/tg"
+	"go.uber.org/zap"
+)
+
+// Step is a step of the onboarding
+type Step string
+
+const (
+	// StepFile waits for the first file of the user
+	StepFile Step = "file"
+	// StepPlayer waits for the first connection of the web player, so it comes after StepFile
+	StepPlayer Step = "player"
+	// StepSettings waits for the user to choose their settings
+	StepSettings Step = "settings"
+	// StepDone marks finished onboardings
+	StepDone Step = "done"
+)
+
+// SettingsPrefix prefixes the callback data of the settings buttons
+const SettingsPrefix = "settings:"
+
+// Prompt is a message that tells the user what to do next
+type Prompt struct {
+	Text   string
+	Markup tg.ReplyMarkupClass
+}
+
+var steps []Step
+
+// Load parses ONBOARDING_STEPS. The onboarding is disabled if no steps are configured.
+func Load(log *zap.Logger) {
+	log = log.Named("onboarding")
+	steps = nil
+	for _, name := range config.ValueOf.OnboardingSteps {
+		step := Step(strings.ToLower(strings.TrimSpace(name)))
+		switch step {
+		case StepFile, StepPlayer, StepSettings:
+			steps = append(steps, step)
+		case "", "none":
+		default:
+			log.Sugar().Warnf("Ignoring unknown onboarding step %q, use file, player or settings", name)
+		}
+	}
+	if len(steps) > 0 {
+		log.Sugar().Infof("Onboarding users in %d steps", len(steps))
+	}
+}
+
+// Begin starts the onboarding of a user who just got access to the bot and returns the
+// first prompt. It returns nil if the user started onboarding before or it's disabled.
+func Begin(userID int64) (*Prompt, error) {
+	userRepository := database.GetUserRepository()
+	if len(steps) == 0 || userRepository == nil {
+		return nil, nil
+	}
+	started, err := userRepository.AdvanceOnboarding(userID, "", string(steps[0]))
+	if err != nil || !started {
+		return nil, err
+	}
+	prompt := prompt(steps[0], 1)
+	prompt.Text = fmt.Sprintf("👋 Welcome! Let's get you set up in %d quick %s.\n\n%s", len(steps), plural(len(steps), "step"), prompt.Text)
+	return prompt, nil
+}
+
+// Current returns the prompt of the step the user is at, or nil if they aren't onboarding
+func Current(userID int64) (*Prompt, error) {
+	userRepository := database.GetUserRepository()
+	if userRepository == nil {
+		return nil, nil
+	}
+	user, err := userRepository.Get(userID)
+	if err != nil || user == nil {
+		return nil, err
+	}
+	for i, step := range steps {
+		if Step(user.Onboarding) == step {
+			return prompt(step, i+1), nil
+		}
+	}
+	return nil, nil
+}
+
+// Complete moves the user past the step if they are at it and returns the prompt of the next
+// step, or the closing message after the last one. It returns nil if the user isn't at the step.
+func Complete(userID int64, step Step) (*Prompt, error) {
+	userRepository := database.GetUserRepository()
+	if userRepository == nil {
+		return nil, nil
+	}
+	for i := range steps {
+		if steps[i] != step {
+			continue
+		}
+		next := StepDone
+		if i+1 < len(steps) {
+			next = steps[i+1]
+		}
+		advanced, err := userRepository.AdvanceOnboarding(userID, string(step), string(next))
+		if err != nil || !advanced {
+			return nil, err
+		}
+		if next == StepDone {
+			return &Prompt{Text: "🎉 You're all set! Send me a file whenever you need a link."}, nil
+		}
+		return prompt(next, i+2), nil
+	}
+	return nil, nil
+}
+
+// SettingsMarkup returns the buttons of the settings
+func SettingsMarkup() tg.ReplyMarkupClass {
+	return &tg.ReplyInlineMarkup{
+		Rows: []tg.KeyboardButtonRow{{
+			Buttons: []tg.KeyboardButtonClass{
+				&tg.KeyboardButtonCallback{Text: "👁 Live views", Data: []byte(SettingsPrefix + "live:on")},
+				&tg.KeyboardButtonCallback{Text: "🔕 Quiet replies", Data: []byte(SettingsPrefix + "live:off")},
+			},
+		}},
+	}
+}
+
+// SettingsText describes the settings
+const SettingsText = "Should I keep the view count under your links up to date as people open them? Choose 🔕 Quiet replies if you'd rather I didn't edit my replies."
+
+func prompt(step Step, number int) *Prompt {
+	switch step {
+	case StepFile:
+		return &Prompt{Text: fmt.Sprintf("%d️⃣ Send me any file, a short video works best, and I'll reply with a link to stream or download it.", number)}
+	case StepPlayer:
+		return &Prompt{Text: fmt.Sprintf("%d️⃣ Open the Player button under a video or audio link. The web player streams your files right in the browser and keeps a queue of your recent links.", number)}
+	default:
+		return &Prompt{Text: fmt.Sprintf("%d️⃣ %s You can change this later with /settings.", number, SettingsText), Markup: SettingsMarkup()}
+	}
+}
+
+func plural(n int, word string) string {
+	if n == 1 {
+		return word
+	}
+	return word + "s"
+}
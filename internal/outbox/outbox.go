@@ -0,0 +1,266 @@
+// Package outbox queues the messages sent by the bot so that it stays within the rate limits
+// of Telegram, about one message a second per chat, 20 a minute per group or supergroup and
+// 30 a second overall.
+// Replies to users are sent before notifications and other background messages.
+package outbox
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gotd/td/bin"
+	"github.com/gotd/td/telegram"
+	"github.com/gotd/td/tg"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+)
+
+// Priority orders the queued messages, lower values are sent first
+type Priority int
+
+const (
+	// PriorityHigh is used for replies to users, the default
+	PriorityHigh Priority = iota
+	// PriorityLow is used for notifications and broadcasts
+	PriorityLow
+	priorityCount
+)
+
+// idleChat is how long a chat must be silent before its limiter is dropped
+const idleChat = 10 * time.Minute
+
+type priorityKey struct{}
+
+// WithPriority returns a context whose messages are queued with the given priority
+func WithPriority(ctx context.Context, priority Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, priority)
+}
+
+// Low returns a context whose messages wait for the replies to users
+func Low(ctx context.Context) context.Context {
+	return WithPriority(ctx, PriorityLow)
+}
+
+type chatLimiter struct {
+	limiter  *rate.Limiter
+	group    bool
+	lastUsed time.Time
+}
+
+type queue struct {
+	log     *zap.Logger
+	global  *rate.Limiter
+	mu      sync.Mutex
+	waiting [priorityCount][]chan struct{}
+	signal  chan struct{}
+	chats   map[int64]*chatLimiter
+	// megagroups holds the channels that turned out to be supergroups, they get the group limit
+	megagroups map[int64]bool
+}
+
+// Middleware returns a middleware that delays the messages the client sends until they can be
+// sent without hitting a flood wait. perSecond limits the messages sent to all chats together,
+// the queue is disabled if it's 0.
+func Middleware(log *zap.Logger, perSecond int) telegram.Middleware {
+	if perSecond <= 0 {
+		return telegram.MiddlewareFunc(func(next tg.Invoker) telegram.InvokeFunc {
+			return next.Invoke
+		})
+	}
+	q := &queue{
+		log:        log.Named("outbox"),
+		global:     rate.NewLimiter(rate.Limit(perSecond), 1),
+		signal:     make(chan struct{}, 1),
+		chats:      make(map[int64]*chatLimiter),
+		megagroups: make(map[int64]bool),
+	}
+	go q.run()
+	return telegram.MiddlewareFunc(func(next tg.Invoker) telegram.InvokeFunc {
+		return func(ctx context.Context, input bin.Encoder, output bin.Decoder) error {
+			peer := destination(input)
+			if peer == nil {
+				return next.Invoke(ctx, input, output)
+			}
+			if err := q.wait(ctx, peer); err != nil {
+				return err
+			}
+			if err := next.Invoke(ctx, input, output); err != nil {
+				return err
+			}
+			q.learnMegagroups(output)
+			return nil
+		}
+	})
+}
+
+// destination returns the chat a request sends or edits a message in, or nil for other requests
+func destination(input bin.Encoder) tg.InputPeerClass {
+	switch request := input.(type) {
+	case *tg.MessagesSendMessageRequest:
+		return request.Peer
+	case *tg.MessagesSendMediaRequest:
+		return request.Peer
+	case *tg.MessagesSendMultiMediaRequest:
+		return request.Peer
+	case *tg.MessagesForwardMessagesRequest:
+		return request.ToPeer
+	case *tg.MessagesEditMessageRequest:
+		return request.Peer
+	}
+	return nil
+}
+
+// wait blocks until the chat is below its own limit and the message had its turn in the global queue
+func (q *queue) wait(ctx context.Context, peer tg.InputPeerClass) error {
+	if err := q.chatLimiter(peer).Wait(ctx); err != nil {
+		return err
+	}
+	priority, ok := ctx.Value(priorityKey{}).(Priority)
+	if !ok || priority < 0 || priority >= priorityCount {
+		priority = PriorityHigh
+	}
+	turn := make(chan struct{})
+	q.mu.Lock()
+	q.waiting[priority] = append(q.waiting[priority], turn)
+	q.mu.Unlock()
+	select {
+	case q.signal <- struct{}{}:
+	default:
+	}
+	select {
+	case <-turn:
+		return nil
+	case <-ctx.Done():
+		q.cancel(priority, turn)
+		return ctx.Err()
+	}
+}
+
+// cancel removes a turn that's no longer waited for from the queue, so it doesn't use up a global token
+func (q *queue) cancel(priority Priority, turn chan struct{}) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i, waiting := range q.waiting[priority] {
+		if waiting == turn {
+			q.waiting[priority] = append(q.waiting[priority][:i], q.waiting[priority][i+1:]...)
+			return
+		}
+	}
+}
+
+// chatLimiter returns the limiter of the chat. Groups and supergroups get the stricter limit
+// of 20 messages a minute. Channels are only known to be supergroups once a message was sent
+// to them, see learnMegagroups.
+func (q *queue) chatLimiter(peer tg.InputPeerClass) *rate.Limiter {
+	var id int64
+	group := false
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	switch p := peer.(type) {
+	case *tg.InputPeerUser:
+		id = p.UserID
+	case *tg.InputPeerChat:
+		id, group = -p.ChatID, true
+	case *tg.InputPeerChannel:
+		id, group = -1000000000000-p.ChannelID, q.megagroups[p.ChannelID]
+	}
+	chat, ok := q.chats[id]
+	if !ok || chat.group != group {
+		limit := rate.Every(time.Second)
+		if group {
+			limit = rate.Every(3 * time.Second)
+		}
+		chat = &chatLimiter{limiter: rate.NewLimiter(limit, 3), group: group}
+		q.chats[id] = chat
+	}
+	chat.lastUsed = time.Now()
+	return chat.limiter
+}
+
+// learnMegagroups remembers the supergroups among the chats in the response to a sent message
+func (q *queue) learnMegagroups(output bin.Decoder) {
+	box, ok := output.(*tg.UpdatesBox)
+	if !ok {
+		return
+	}
+	var chats []tg.ChatClass
+	switch updates := box.Updates.(type) {
+	case *tg.Updates:
+		chats = updates.Chats
+	case *tg.UpdatesCombined:
+		chats = updates.Chats
+	}
+	for _, chat := range chats {
+		if channel, ok := chat.(*tg.Channel); ok && channel.Megagroup {
+			q.mu.Lock()
+			q.megagroups[channel.ID] = true
+			q.mu.Unlock()
+		}
+	}
+}
+
+// run hands out the turns of the global limiter, always to the waiting message with the highest priority
+func (q *queue) run() {
+	cleanup := time.NewTicker(idleChat)
+	defer cleanup.Stop()
+	for {
+		select {
+		case <-cleanup.C:
+			q.forgetIdleChats()
+		default:
+		}
+		if !q.hasWaiting() {
+			select {
+			case <-q.signal:
+			case <-cleanup.C:
+				q.forgetIdleChats()
+			}
+			continue
+		}
+		// the turn is picked once the token is available, so cancelled messages don't use it up
+		reservation := q.global.Reserve()
+		time.Sleep(reservation.Delay())
+		turn := q.next()
+		if turn == nil {
+			reservation.Cancel()
+			continue
+		}
+		close(turn)
+	}
+}
+
+func (q *queue) hasWaiting() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for priority := range q.waiting {
+		if len(q.waiting[priority]) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (q *queue) next() chan struct{} {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for priority := range q.waiting {
+		if len(q.waiting[priority]) > 0 {
+			turn := q.waiting[priority][0]
+			q.waiting[priority] = q.waiting[priority][1:]
+			return turn
+		}
+	}
+	return nil
+}
+
+func (q *queue) forgetIdleChats() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for id, chat := range q.chats {
+		if time.Since(chat.lastUsed) > idleChat {
+			delete(q.chats, id)
+		}
+	}
+	q.log.Debug("Forgot idle chats", zap.Int("remaining", len(q.chats)))
+}
@@ -0,0 +1,63 @@
+package policy
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/types"
+	"EverythingSuckz/fsb/internal/utils"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Violation is returned when a file doesn't satisfy the content policy.
+// Its message is meant to be shown to the user.
+type Violation struct {
+	Message string
+}
+
+func (v *Violation) Error() string {
+	return v.Message
+}
+
+// Check validates the file against the configured content policy
+func Check(file *types.File) error {
+	maxSize := config.Runtime.MaxFileSize()
+	if maxSize > 0 && file.FileSize > maxSize {
+		return &Violation{fmt.Sprintf("❌ This file is too large. The maximum allowed size is %s.", utils.FormatFileSizeShort(maxSize))}
+	}
+	ext := strings.ToLower(filepath.Ext(file.FileName))
+	if ext != "" {
+		for _, blocked := range config.ValueOf.BlockedExtensions {
+			if ext == normalizeExtension(blocked) {
+				return &Violation{fmt.Sprintf("❌ Files with the %s extension are not allowed.", ext)}
+			}
+		}
+	}
+	if len(config.ValueOf.AllowedMimeTypes) != 0 && !mimeTypeAllowed(file.MimeType) {
+		mimeType := file.MimeType
+		if mimeType == "" {
+			mimeType = "unknown"
+		}
+		return &Violation{fmt.Sprintf("❌ Files of type %s are not allowed.", mimeType)}
+	}
+	return nil
+}
+
+func mimeTypeAllowed(mimeType string) bool {
+	mimeType = strings.ToLower(mimeType)
+	for _, pattern := range config.ValueOf.AllowedMimeTypes {
+		if ok, _ := path.Match(strings.ToLower(strings.TrimSpace(pattern)), mimeType); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeExtension(ext string) string {
+	ext = strings.ToLower(strings.TrimSpace(ext))
+	if !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	return ext
+}
@@ -0,0 +1,141 @@
+// Package profile groups linked Telegram accounts into one player profile, so that
+// their links show up in the same queue of the web player.
+package profile
+
+import (
+	"EverythingSuckz/fsb/internal/database"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+)
+
+// codeTTL is how long a code generated by /linkaccount can be redeemed
+const codeTTL = 10 * time.Minute
+
+var (
+	ErrInvalidCode   = errors.New("invalid or expired code")
+	ErrSameAccount   = errors.New("the code was generated by this account")
+	ErrAlreadyLinked = errors.New("the accounts are already linked")
+)
+
+type pendingCode struct {
+	userID    int64
+	expiresAt time.Time
+}
+
+var (
+	codesMu sync.Mutex
+	codes   = make(map[string]pendingCode)
+
+	// rooms holds the subscribers of every profile, they are notified when the queue changes
+	roomsMu sync.Mutex
+	rooms   = make(map[int64]map[chan struct{}]struct{})
+)
+
+// Of returns the ID of the player profile of the user, the ID of the account
+// the user was linked to or the user's own ID
+func Of(userID int64) int64 {
+	userRepository := database.GetUserRepository()
+	if userRepository == nil {
+		return userID
+	}
+	user, err := userRepository.Get(userID)
+	if err != nil || user == nil || user.ProfileID == 0 {
+		return userID
+	}
+	return user.ProfileID
+}
+
+// Accounts returns the IDs of the accounts sharing the player profile
+func Accounts(profileID int64) ([]int64, error) {
+	userRepository := database.GetUserRepository()
+	if userRepository == nil {
+		return []int64{profileID}, nil
+	}
+	return userRepository.ProfileAccounts(profileID)
+}
+
+// NewCode returns a code another account of the user can redeem to join the user's profile
+func NewCode(userID int64) (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(100000000))
+	if err != nil {
+		return "", err
+	}
+	code := fmt.Sprintf("%08d", n.Int64())
+	now := time.Now()
+	codesMu.Lock()
+	defer codesMu.Unlock()
+	for c, pending := range codes {
+		if now.After(pending.expiresAt) {
+			delete(codes, c)
+		}
+	}
+	codes[code] = pendingCode{userID: userID, expiresAt: now.Add(codeTTL)}
+	return code, nil
+}
+
+// Redeem links the user, with all accounts already linked to it, to the profile of the
+// account that generated the code. It returns the ID of the merged profile.
+func Redeem(code string, userID int64) (int64, error) {
+	codesMu.Lock()
+	pending, ok := codes[code]
+	if ok {
+		delete(codes, code)
+	}
+	codesMu.Unlock()
+	if !ok || time.Now().After(pending.expiresAt) {
+		return 0, ErrInvalidCode
+	}
+	if pending.userID == userID {
+		return 0, ErrSameAccount
+	}
+	userRepository := database.GetUserRepository()
+	if userRepository == nil {
+		return 0, errors.New("user database is not available")
+	}
+	from, to := Of(userID), Of(pending.userID)
+	if from == to {
+		return 0, ErrAlreadyLinked
+	}
+	if err := userRepository.MergeProfile(from, to); err != nil {
+		return 0, err
+	}
+	Notify(from)
+	Notify(to)
+	return to, nil
+}
+
+// Subscribe returns a channel that receives a value whenever the queue of the profile
+// changes, and a function that cancels the subscription.
+func Subscribe(profileID int64) (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+	roomsMu.Lock()
+	if rooms[profileID] == nil {
+		rooms[profileID] = make(map[chan struct{}]struct{})
+	}
+	rooms[profileID][ch] = struct{}{}
+	roomsMu.Unlock()
+	return ch, func() {
+		roomsMu.Lock()
+		delete(rooms[profileID], ch)
+		if len(rooms[profileID]) == 0 {
+			delete(rooms, profileID)
+		}
+		roomsMu.Unlock()
+	}
+}
+
+// Notify tells the subscribers of the profile that its queue changed
+func Notify(profileID int64) {
+	roomsMu.Lock()
+	defer roomsMu.Unlock()
+	for ch := range rooms[profileID] {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
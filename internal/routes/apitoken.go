@@ -0,0 +1,44 @@
+package routes
+
+import (
+	"EverythingSuckz/fsb/internal/webauth"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// apiUser returns the user signed in to the browser, or the user of the API token of the
+// request if it has the scope. Otherwise it responds with the error. Wrong tokens lock the
+// IP out after LOCKOUT_ATTEMPTS.
+func (r *allRoutes) apiUser(c *gin.Context, scope string) (int64, bool) {
+	if userID, ok := webauth.UserID(c.Request); ok {
+		return userID, true
+	}
+	token := webauth.BearerToken(c.Request)
+	if token == "" {
+		http.Error(c.Writer, "not signed in, send /weblogin to the bot and open the login link in this browser first, or pass a token of /apitoken", http.StatusUnauthorized)
+		return 0, false
+	}
+	if r.lockedOut(c, tokenAttempts, "") {
+		return 0, false
+	}
+	userID, err := webauth.APITokenUser(token, scope)
+	switch {
+	case errors.Is(err, webauth.ErrInvalidAPIToken):
+		r.failedAttempt(c, tokenAttempts, "API tokens", "")
+		http.Error(c.Writer, err.Error(), http.StatusUnauthorized)
+		return 0, false
+	case errors.Is(err, webauth.ErrMissingScope):
+		r.succeededAttempt(c, tokenAttempts, "")
+		http.Error(c.Writer, "the API token doesn't have the "+scope+" scope", http.StatusForbidden)
+		return 0, false
+	case err != nil:
+		r.log.Error("Failed to check API token", zap.Error(err))
+		http.Error(c.Writer, "failed to check the API token", http.StatusServiceUnavailable)
+		return 0, false
+	}
+	r.succeededAttempt(c, tokenAttempts, "")
+	return userID, true
+}
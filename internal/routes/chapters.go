@@ -0,0 +1,47 @@
+package routes
+
+import (
+	"EverythingSuckz/fsb/internal/media"
+	"EverythingSuckz/fsb/internal/utils"
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+func (r *allRoutes) LoadChaptersAPI(route *Route) {
+	route.Engine.GET("/api/chapters/:messageID", r.getChapters)
+}
+
+func (r *allRoutes) getChapters(c *gin.Context) {
+	if !media.Enabled() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": "Chapters are not available",
+		})
+		return
+	}
+	link := r.authorizedLink(c)
+	if link == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), time.Minute)
+	defer cancel()
+	info, err := media.Probe(ctx, utils.InternalStreamURL(link.TenantID, link.MessageID, link.Hash))
+	if err != nil {
+		r.log.Error("Failed to probe media", zap.Error(err), zap.Int("messageID", link.MessageID))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to probe media",
+		})
+		return
+	}
+	chapters := info.Chapters
+	if chapters == nil {
+		chapters = []media.Chapter{}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    chapters,
+	})
+}
@@ -0,0 +1,39 @@
+package routes
+
+import (
+	"EverythingSuckz/fsb/internal/downloads"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// checksumRetry is the Retry-After of checksums that are being computed, in seconds
+const checksumRetry = "30"
+
+func (r *allRoutes) LoadChecksum(route *Route) {
+	route.Engine.GET("/api/checksum/:messageID", r.getChecksum)
+}
+
+// getChecksum returns the SHA-256 of the file of the link. Checksums that aren't known yet
+// are computed in the background, the response is 202 until they are.
+func (r *allRoutes) getChecksum(c *gin.Context) {
+	link := r.authorizedLink(c)
+	if link == nil {
+		return
+	}
+	if link.FileSize == 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "photos have no checksum, send them as files"})
+		return
+	}
+	sum, ok := downloads.Checksum(link, nil)
+	if !ok {
+		c.Header("Retry-After", checksumRetry)
+		c.JSON(http.StatusAccepted, gin.H{"status": "computing"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"file_name": link.FileName,
+		"file_size": link.FileSize,
+		"sha256":    sum,
+	})
+}
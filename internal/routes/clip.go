@@ -0,0 +1,130 @@
+package routes
+
+import (
+	"EverythingSuckz/fsb/internal/budget"
+	"EverythingSuckz/fsb/internal/media"
+	"EverythingSuckz/fsb/internal/types"
+	"EverythingSuckz/fsb/internal/utils"
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// clipping holds the IPs remuxing a clip, each IP remuxes one clip at a time
+var (
+	clipping   = make(map[string]bool)
+	clippingMu sync.Mutex
+)
+
+// startClipping claims the remuxing slot of the IP and returns a func releasing it,
+// or false if the IP is already remuxing a clip
+func startClipping(ip string) (func(), bool) {
+	clippingMu.Lock()
+	defer clippingMu.Unlock()
+	if clipping[ip] {
+		return nil, false
+	}
+	clipping[ip] = true
+	return func() {
+		clippingMu.Lock()
+		delete(clipping, ip)
+		clippingMu.Unlock()
+	}, true
+}
+
+// remuxClip returns the clip of the link, remuxing it first if it isn't cached.
+// Failures are returned with the HTTP status and the message to respond with.
+func (r *allRoutes) remuxClip(c *gin.Context, link *types.Link, start float64, end float64) (string, int, string) {
+	if path, ok := media.CachedClip(link.StorageKey(), start, end); ok {
+		return path, http.StatusOK, ""
+	}
+	if err := media.CheckClipRange(start, end); err != nil {
+		return "", http.StatusBadRequest, "the clip is longer than the server allows"
+	}
+	done, ok := startClipping(c.ClientIP())
+	if !ok {
+		return "", http.StatusTooManyRequests, "a clip is already being created, try again when it's done"
+	}
+	defer done()
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Minute)
+	defer cancel()
+	path, err := media.Clip(ctx, utils.InternalStreamURL(link.TenantID, link.MessageID, link.Hash), link.StorageKey(), start, end)
+	if errors.Is(err, budget.ErrExhausted) {
+		c.Header("Retry-After", strconv.Itoa(int(budget.QueueTimeout.Seconds())))
+		return "", http.StatusServiceUnavailable, "the server is busy, try again in a moment"
+	}
+	if err != nil {
+		r.log.Error("Failed to create clip", zap.Error(err), zap.Int("messageID", link.MessageID))
+		return "", http.StatusInternalServerError, "failed to create clip"
+	}
+	return path, http.StatusOK, ""
+}
+
+func (r *allRoutes) LoadClip(route *Route) {
+	route.Engine.GET("/clip/:messageID/:file", r.getClip)
+	route.Engine.GET("/api/clip/:messageID", r.createClip)
+}
+
+// getClip serves a clip, remuxing it first if it isn't cached
+func (r *allRoutes) getClip(c *gin.Context) {
+	if !media.Enabled() {
+		http.Error(c.Writer, "clips are not enabled", http.StatusServiceUnavailable)
+		return
+	}
+	link := r.authorizedLink(c)
+	if link == nil {
+		return
+	}
+	start, end, err := media.ParseClipName(c.Param("file"))
+	if err != nil {
+		http.Error(c.Writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+	path, status, message := r.remuxClip(c, link, start, end)
+	if status != http.StatusOK {
+		http.Error(c.Writer, message, status)
+		return
+	}
+	c.Header("Content-Type", "video/mp4")
+	c.File(path)
+}
+
+// createClip remuxes the clip between the start and end query params and returns its link
+func (r *allRoutes) createClip(c *gin.Context) {
+	if !media.Enabled() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": "Clips are not available",
+		})
+		return
+	}
+	link := r.authorizedLink(c)
+	if link == nil {
+		return
+	}
+	start, errStart := utils.ParseTimestamp(c.Query("start"))
+	end, errEnd := utils.ParseTimestamp(c.Query("end"))
+	if errStart != nil || errEnd != nil || end <= start {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "start and end must be timestamps and end must be after start",
+		})
+		return
+	}
+	if _, status, message := r.remuxClip(c, link, start, end); status != http.StatusOK {
+		c.JSON(status, gin.H{
+			"error": message,
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"url": media.ClipURL(link.TenantID, link.MessageID, link.Hash, start, end),
+		},
+	})
+}
@@ -0,0 +1,98 @@
+package routes
+
+import (
+	"EverythingSuckz/fsb/internal/library"
+	"EverythingSuckz/fsb/internal/web"
+	"EverythingSuckz/fsb/internal/webauth"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+func (r *allRoutes) LoadCollection(route *Route) {
+	route.Engine.GET("/c/:token", r.getSharedCollection)
+	route.Engine.GET("/webapp/collections", r.getWebAppCollections)
+	route.Engine.GET("/api/collections", r.getWebAppCollections)
+}
+
+// collectionJSON is a collection listed on the home page of the web app
+type collectionJSON struct {
+	Name   string      `json:"name"`   // with the names of the collections it's nested in, separated by slashes
+	Parent string      `json:"parent"` // the collection it's nested in, empty if it isn't
+	Items  []queueItem `json:"items"`
+}
+
+// getSharedCollection lists the files of the collection shared with the token and of the
+// collections nested in it
+func (r *allRoutes) getSharedCollection(c *gin.Context) {
+	if r.lockedOut(c, tokenAttempts, "") {
+		return
+	}
+	collections, err := library.Shared(c.Param("token"))
+	if err != nil {
+		r.log.Error("Failed to list shared collection", zap.Error(err))
+		http.Error(c.Writer, "failed to list the collection", http.StatusInternalServerError)
+		return
+	}
+	if len(collections) == 0 {
+		r.failedAttempt(c, tokenAttempts, "collection share links", "")
+		http.Error(c.Writer, "this collection isn't shared anymore", http.StatusNotFound)
+		return
+	}
+	r.succeededAttempt(c, tokenAttempts, "")
+	root := collections[0].Name
+	data := web.CollectionData{Name: path.Base(root)}
+	for _, collection := range collections {
+		section := web.CollectionSection{Name: strings.TrimPrefix(strings.TrimPrefix(collection.Name, root), "/")}
+		for i := range collection.Links {
+			link := &collection.Links[i]
+			if linkGone(link) != "" {
+				continue
+			}
+			section.Items = append(section.Items, indexItem(link))
+		}
+		data.Sections = append(data.Sections, section)
+	}
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Header("Cache-Control", "no-store")
+	if err := web.Collection.Execute(c.Writer, data); err != nil {
+		r.log.Error("Failed to render collection page", zap.Error(err))
+	}
+}
+
+// getWebAppCollections lists the collections of the signed in user for the home page of the web
+// app, and for scripts with an API token at /api/collections
+func (r *allRoutes) getWebAppCollections(c *gin.Context) {
+	userID, ok := r.apiUser(c, webauth.ScopeHistory)
+	if !ok {
+		return
+	}
+	collections, err := library.Collections(userID)
+	if err != nil {
+		r.log.Error("Failed to list collections", zap.Error(err), zap.Int64("userID", userID))
+		http.Error(c.Writer, "failed to list your collections", http.StatusInternalServerError)
+		return
+	}
+	result := make([]collectionJSON, 0, len(collections))
+	for _, collection := range collections {
+		entry := collectionJSON{Name: collection.Name, Parent: library.Parent(collection.Name), Items: []queueItem{}}
+		for i := range collection.Links {
+			link := &collection.Links[i]
+			if linkGone(link) != "" {
+				continue
+			}
+			entry.Items = append(entry.Items, queueItem{
+				FileName:  link.FileName,
+				URL:       indexItem(link).URL,
+				Category:  link.Category,
+				CreatedAt: link.CreatedAt,
+			})
+		}
+		result = append(result, entry)
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, result)
+}
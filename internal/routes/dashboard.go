@@ -0,0 +1,353 @@
+package routes
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/downloads"
+	"EverythingSuckz/fsb/internal/jobs"
+	"EverythingSuckz/fsb/internal/profile"
+	"EverythingSuckz/fsb/internal/sessions"
+	"EverythingSuckz/fsb/internal/types"
+	"EverythingSuckz/fsb/internal/utils"
+	"EverythingSuckz/fsb/internal/watchdog"
+	"EverythingSuckz/fsb/internal/web"
+	"EverythingSuckz/fsb/internal/webauth"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	// dashboardUsers is the number of users listed on the dashboard
+	dashboardUsers = 50
+	// dashboardLinks is the number of recent links of a user listed on the dashboard
+	dashboardLinks = 50
+	// dashboardDeadJobs is the number of dead jobs listed on the dashboard
+	dashboardDeadJobs = 50
+)
+
+func (r *allRoutes) LoadDashboard(route *Route) {
+	route.Engine.GET("/dashboard", r.getDashboard)
+	route.Engine.POST("/dashboard/login", r.postDashboardLogin)
+	route.Engine.POST("/dashboard/logout", r.postDashboardLogout)
+	route.Engine.POST("/dashboard/users/:userID/:action", r.postDashboardUser)
+	route.Engine.POST("/dashboard/links/:tenantID/:messageID/revoke", r.postDashboardRevokeLink)
+	route.Engine.POST("/dashboard/jobs/:jobID/:action", r.postDashboardJob)
+}
+
+// dashboardAdmin returns the admin signed in to the dashboard, with an account of DASHBOARD_ADMINS
+// or `fsb dashboard-admin`, or the ADMINS signed in to the browser with /weblogin or single
+// sign-on, which needs Telegram
+func dashboardAdmin(c *gin.Context) (string, bool) {
+	if username, ok := webauth.DashboardUser(c.Request); ok {
+		return username, true
+	}
+	if userID, ok := webauth.UserID(c.Request); ok && utils.Contains(config.Runtime.Admins(), userID) {
+		return strconv.FormatInt(userID, 10), true
+	}
+	return "", false
+}
+
+// dashboardForm returns the admin of a form submitted on the dashboard. Forms from other sites
+// are refused, the session of Telegram admins is sent along with them in the web app.
+func (r *allRoutes) dashboardForm(c *gin.Context) (string, bool) {
+	if origin := c.GetHeader("Origin"); origin != "" && origin != publicOrigin() {
+		http.Error(c.Writer, "forms of the dashboard can only be sent from the dashboard", http.StatusForbidden)
+		return "", false
+	}
+	admin, ok := dashboardAdmin(c)
+	if !ok {
+		c.Redirect(http.StatusSeeOther, config.ValueOf.BasePath+"/dashboard")
+	}
+	return admin, ok
+}
+
+// publicOrigin returns the scheme and host of HOST
+func publicOrigin() string {
+	host, err := url.Parse(config.Runtime.Host())
+	if err != nil {
+		return config.Runtime.Host()
+	}
+	return host.Scheme + "://" + host.Host
+}
+
+// renderDashboard renders the dashboard, or its sign in form when data has no admin
+func (r *allRoutes) renderDashboard(c *gin.Context, status int, data web.DashboardData) {
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Header("Cache-Control", "no-store")
+	c.Status(status)
+	if err := web.Dashboard.Execute(c.Writer, data); err != nil {
+		r.log.Error("Failed to render dashboard", zap.Error(err))
+	}
+}
+
+// getDashboard lists the users matching the q query param, and the recent links of the user of
+// the user query param, to suspend and remove users and revoke links in the browser, also
+// while Telegram is unreachable
+func (r *allRoutes) getDashboard(c *gin.Context) {
+	admin, ok := dashboardAdmin(c)
+	if !ok {
+		r.renderDashboard(c, http.StatusOK, web.DashboardData{})
+		return
+	}
+	data := web.DashboardData{Admin: admin, Message: c.Query("done"), Query: strings.TrimSpace(c.Query("q")), Paused: downloads.Paused()}
+	for _, disk := range watchdog.Usage() {
+		data.Disks = append(data.Disks, web.DashboardDisk{
+			Name:  disk.Name,
+			Path:  disk.Path,
+			Free:  utils.FormatFileSizeShort(disk.Free),
+			Total: utils.FormatFileSizeShort(disk.Total),
+			Low:   disk.Low,
+		})
+	}
+	userRepository := database.GetUserRepository()
+	linkRepository := database.GetLinkRepository()
+	if userRepository == nil || linkRepository == nil {
+		data.Error = "The database is not available."
+		r.renderDashboard(c, http.StatusServiceUnavailable, data)
+		return
+	}
+	r.dashboardJobs(&data)
+	filter := database.UserFilter{}
+	if data.Query != "" {
+		if id, err := strconv.ParseInt(data.Query, 10, 64); err == nil {
+			filter.IDs = []int64{id}
+		} else {
+			filter.Name = data.Query
+		}
+	}
+	users, total, err := userRepository.ListUsers(filter, 0, dashboardUsers)
+	if err != nil {
+		r.log.Error("Failed to list users for the dashboard", zap.Error(err))
+		data.Error = "Failed to list the users."
+	}
+	data.Total = total
+	for i := range users {
+		data.Users = append(data.Users, dashboardUser(&users[i]))
+	}
+	if userID, err := strconv.ParseInt(c.Query("user"), 10, 64); err == nil {
+		user, err := userRepository.Get(userID)
+		if err != nil {
+			r.log.Error("Failed to get user for the dashboard", zap.Error(err), zap.Int64("userID", userID))
+		}
+		selected := web.DashboardUser{ID: userID}
+		if user != nil {
+			selected = dashboardUser(user)
+		}
+		data.User = &selected
+		links, err := linkRepository.ListByUsers([]int64{userID}, dashboardLinks)
+		if err != nil {
+			r.log.Error("Failed to list links for the dashboard", zap.Error(err), zap.Int64("userID", userID))
+			data.Error = "Failed to list the links."
+		}
+		for _, link := range links {
+			data.Links = append(data.Links, web.DashboardLink{
+				TenantID:  link.TenantID,
+				MessageID: link.MessageID,
+				FileName:  link.FileName,
+				FileSize:  utils.FormatFileSize(link.FileSize),
+				CreatedAt: link.CreatedAt.Format("2006-01-02 15:04"),
+				URL:       utils.StreamURL(link.TenantID, link.MessageID, link.Hash),
+			})
+		}
+	}
+	r.renderDashboard(c, http.StatusOK, data)
+}
+
+// dashboardJobs counts the background jobs and lists the dead ones
+func (r *allRoutes) dashboardJobs(data *web.DashboardData) {
+	jobRepository := database.GetJobRepository()
+	if jobRepository == nil {
+		return
+	}
+	counts, err := jobRepository.Counts()
+	if err != nil {
+		r.log.Error("Failed to count jobs for the dashboard", zap.Error(err))
+		return
+	}
+	for _, count := range counts {
+		data.Jobs = append(data.Jobs, web.DashboardJobCount{Kind: count.Kind, State: count.State, Count: count.Count})
+	}
+	dead, err := jobRepository.ListDead(dashboardDeadJobs)
+	if err != nil {
+		r.log.Error("Failed to list dead jobs for the dashboard", zap.Error(err))
+		return
+	}
+	for _, job := range dead {
+		listed := web.DashboardJob{ID: job.ID, Kind: job.Kind, Attempts: job.Attempts, Error: job.LastError}
+		if job.FinishedAt != nil {
+			listed.FinishedAt = job.FinishedAt.Format("2006-01-02 15:04")
+		}
+		data.Dead = append(data.Dead, listed)
+	}
+}
+
+func dashboardUser(user *types.User) web.DashboardUser {
+	name := user.FirstName
+	if user.Username != "" {
+		name = strings.TrimSpace(name + " @" + user.Username)
+	}
+	listed := web.DashboardUser{ID: user.ID, Name: name, Joined: user.CreatedAt.Format("2006-01-02"), Suspended: user.Suspended}
+	if user.LastSeen != nil {
+		listed.LastSeen = user.LastSeen.Format("2006-01-02 15:04")
+	}
+	return listed
+}
+
+// postDashboardLogin signs in with the username and password of a dashboard account. Wrong
+// passwords lock the IP and the account out after LOCKOUT_ATTEMPTS.
+func (r *allRoutes) postDashboardLogin(c *gin.Context) {
+	username := strings.TrimSpace(c.PostForm("username"))
+	target := "dashboard:" + username
+	if r.lockedOut(c, tokenAttempts, target) {
+		return
+	}
+	ok, err := webauth.CheckDashboardPassword(username, c.PostForm("password"))
+	if err != nil {
+		r.log.Error("Failed to check dashboard password", zap.Error(err))
+		r.renderDashboard(c, http.StatusServiceUnavailable, web.DashboardData{Error: "The accounts are not available, try again later."})
+		return
+	}
+	if !ok {
+		r.failedAttempt(c, tokenAttempts, "dashboard passwords", target)
+		r.renderDashboard(c, http.StatusUnauthorized, web.DashboardData{Error: "Wrong username or password."})
+		return
+	}
+	r.succeededAttempt(c, tokenAttempts, target)
+	if err := webauth.StartDashboardSession(c.Writer, username); err != nil {
+		r.log.Error("Failed to start dashboard session", zap.Error(err))
+		r.renderDashboard(c, http.StatusServiceUnavailable, web.DashboardData{Error: "Failed to sign in, try again later."})
+		return
+	}
+	r.log.Info("Signed in to the dashboard", zap.String("admin", username), zap.String("ip", c.ClientIP()))
+	c.Redirect(http.StatusSeeOther, config.ValueOf.BasePath+"/dashboard")
+}
+
+// postDashboardLogout signs the browser out of the dashboard
+func (r *allRoutes) postDashboardLogout(c *gin.Context) {
+	webauth.EndDashboardSession(c.Writer)
+	c.Redirect(http.StatusSeeOther, config.ValueOf.BasePath+"/dashboard")
+}
+
+// postDashboardUser suspends, unsuspends or removes the user, or revokes all their links
+func (r *allRoutes) postDashboardUser(c *gin.Context) {
+	admin, ok := r.dashboardForm(c)
+	if !ok {
+		return
+	}
+	userID, err := strconv.ParseInt(c.Param("userID"), 10, 64)
+	if err != nil {
+		http.Error(c.Writer, "invalid user ID", http.StatusBadRequest)
+		return
+	}
+	userRepository := database.GetUserRepository()
+	linkRepository := database.GetLinkRepository()
+	if userRepository == nil || linkRepository == nil {
+		http.Error(c.Writer, "the database is not available", http.StatusServiceUnavailable)
+		return
+	}
+	action := c.Param("action")
+	var message string
+	switch action {
+	case "suspend", "unsuspend":
+		err = userRepository.SetSuspended(userID, action == "suspend")
+		message = fmt.Sprintf("User %d was %sed.", userID, action)
+	case "remove":
+		err = userRepository.Remove(userID)
+		message = fmt.Sprintf("User %d was removed, they can't use the bot anymore.", userID)
+	case "revoke":
+		var revoked int64
+		revoked, err = linkRepository.RevokeAll(userID)
+		terminated := sessions.TerminateAll(userID)
+		message = fmt.Sprintf("Revoked %d links of user %d and stopped %d streams and players.", revoked, userID, terminated)
+	default:
+		http.Error(c.Writer, "unknown action", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		r.log.Error("Failed dashboard action", zap.Error(err), zap.String("action", action), zap.Int64("userID", userID))
+		message = fmt.Sprintf("Failed to %s user %d: %s", action, userID, err.Error())
+	} else {
+		r.log.Info("Dashboard action", zap.String("admin", admin), zap.String("action", action), zap.Int64("userID", userID))
+	}
+	c.Redirect(http.StatusSeeOther, fmt.Sprintf("%s/dashboard?user=%d&done=%s", config.ValueOf.BasePath, userID, url.QueryEscape(message)))
+}
+
+// postDashboardRevokeLink revokes a link
+func (r *allRoutes) postDashboardRevokeLink(c *gin.Context) {
+	admin, ok := r.dashboardForm(c)
+	if !ok {
+		return
+	}
+	tenantID, err := strconv.ParseUint(c.Param("tenantID"), 10, 0)
+	if err != nil {
+		http.Error(c.Writer, "invalid tenant ID", http.StatusBadRequest)
+		return
+	}
+	messageID, err := strconv.Atoi(c.Param("messageID"))
+	if err != nil {
+		http.Error(c.Writer, "invalid message ID", http.StatusBadRequest)
+		return
+	}
+	linkRepository := database.GetLinkRepository()
+	if linkRepository == nil {
+		http.Error(c.Writer, "the database is not available", http.StatusServiceUnavailable)
+		return
+	}
+	link, err := linkRepository.Get(uint(tenantID), messageID)
+	if err != nil {
+		http.Error(c.Writer, "unknown link", http.StatusNotFound)
+		return
+	}
+	message := fmt.Sprintf("Revoked the link of %s.", link.FileName)
+	if _, err := linkRepository.Revoke(link.TenantID, link.MessageID); err != nil {
+		r.log.Error("Failed to revoke link", zap.Error(err), zap.Int("messageID", link.MessageID))
+		message = fmt.Sprintf("Failed to revoke the link of %s: %s", link.FileName, err.Error())
+	} else {
+		r.log.Info("Dashboard action", zap.String("admin", admin), zap.String("action", "revoke link"), zap.String("link", link.StorageKey()))
+		profile.Notify(profile.Of(link.UserID))
+	}
+	c.Redirect(http.StatusSeeOther, fmt.Sprintf("%s/dashboard?user=%d&done=%s", config.ValueOf.BasePath, link.UserID, url.QueryEscape(message)))
+}
+
+// postDashboardJob retries or removes a dead job
+func (r *allRoutes) postDashboardJob(c *gin.Context) {
+	admin, ok := r.dashboardForm(c)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseUint(c.Param("jobID"), 10, 0)
+	if err != nil {
+		http.Error(c.Writer, "invalid job ID", http.StatusBadRequest)
+		return
+	}
+	action := c.Param("action")
+	var found bool
+	var message string
+	switch action {
+	case "retry":
+		found, err = jobs.Retry(uint(id))
+		message = fmt.Sprintf("Job %d was queued again.", id)
+	case "remove":
+		found, err = jobs.Remove(uint(id))
+		message = fmt.Sprintf("Job %d was removed.", id)
+	default:
+		http.Error(c.Writer, "unknown action", http.StatusNotFound)
+		return
+	}
+	switch {
+	case err != nil:
+		r.log.Error("Failed dashboard action", zap.Error(err), zap.String("action", action), zap.Uint64("jobID", id))
+		message = fmt.Sprintf("Failed to %s job %d: %s", action, id, err.Error())
+	case !found:
+		message = fmt.Sprintf("There's no dead job %d.", id)
+	default:
+		r.log.Info("Dashboard action", zap.String("admin", admin), zap.String("action", action+" job"), zap.Uint64("jobID", id))
+	}
+	c.Redirect(http.StatusSeeOther, fmt.Sprintf("%s/dashboard?done=%s", config.ValueOf.BasePath, url.QueryEscape(message)))
+}
@@ -0,0 +1,80 @@
+package routes
+
+import (
+	"EverythingSuckz/fsb/config"
+	"crypto/subtle"
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	rpprof "runtime/pprof"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (r *allRoutes) LoadDebug(route *Route) {
+	debug := route.Engine.Group("/debug", r.debugAuth)
+	debug.GET("/pprof/", gin.WrapF(pprof.Index))
+	debug.GET("/pprof/:name", r.getProfile)
+	debug.POST("/pprof/symbol", gin.WrapF(pprof.Symbol))
+	debug.GET("/vars", gin.WrapH(expvar.Handler()))
+	debug.GET("/goroutines", r.getGoroutines)
+}
+
+// debugAuth only lets requests with DEBUG_TOKEN through, as the header
+// Authorization: Bearer <token> or the query parameter token
+func (r *allRoutes) debugAuth(c *gin.Context) {
+	token := config.ValueOf.DebugToken
+	if token == "" {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+			"error": "Debug endpoints are disabled",
+		})
+		return
+	}
+	if r.lockedOut(c, tokenAttempts, "") {
+		return
+	}
+	given := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if given == "" {
+		given = c.Query("token")
+	}
+	if subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+		r.failedAttempt(c, tokenAttempts, "the debug endpoints", "")
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+			"error": "Invalid debug token",
+		})
+		return
+	}
+	r.succeededAttempt(c, tokenAttempts, "")
+	c.Header("Cache-Control", "no-store")
+	c.Next()
+}
+
+// getProfile serves the profile named in the path, like heap, allocs, profile?seconds=30 or trace?seconds=5
+func (r *allRoutes) getProfile(c *gin.Context) {
+	switch name := c.Param("name"); name {
+	case "cmdline":
+		pprof.Cmdline(c.Writer, c.Request)
+	case "profile":
+		pprof.Profile(c.Writer, c.Request)
+	case "symbol":
+		pprof.Symbol(c.Writer, c.Request)
+	case "trace":
+		pprof.Trace(c.Writer, c.Request)
+	default:
+		if rpprof.Lookup(name) == nil {
+			c.String(http.StatusNotFound, "Unknown profile")
+			return
+		}
+		pprof.Handler(name).ServeHTTP(c.Writer, c.Request)
+	}
+}
+
+// getGoroutines dumps the stacks of all goroutines as text
+func (r *allRoutes) getGoroutines(c *gin.Context) {
+	c.Header("Content-Type", "text/plain; charset=utf-8")
+	c.Header("X-Goroutines", strconv.Itoa(runtime.NumGoroutine()))
+	rpprof.Lookup("goroutine").WriteTo(c.Writer, 2)
+}
@@ -0,0 +1,88 @@
+package routes
+
+import (
+	"EverythingSuckz/fsb/internal/utils"
+	"EverythingSuckz/fsb/internal/web"
+	"fmt"
+	"html"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// maxEmbedSize bounds the width and height of embed snippets
+const maxEmbedSize = 4096
+
+func (r *allRoutes) LoadEmbed(route *Route) {
+	route.Engine.GET("/embed/:messageID/:hash", r.getEmbed)
+	route.Engine.GET("/api/embedcode/:messageID", r.getEmbedCode)
+}
+
+// getEmbed serves the player without anything around it, other sites in EMBED_ORIGINS
+// can put it in an iframe
+func (r *allRoutes) getEmbed(c *gin.Context) {
+	link := r.authorizedLinkHash(c, c.Param("hash"))
+	if link == nil {
+		return
+	}
+	if kind := mediaKind(link.MimeType); kind != "video" && kind != "audio" {
+		http.Error(c.Writer, "only videos and audio can be embedded", http.StatusUnsupportedMediaType)
+		return
+	}
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	err := web.Embed.Execute(c.Writer, web.EmbedData{
+		FileName:  link.FileName,
+		MessageID: link.MessageID,
+		StreamURL: utils.StreamURL(link.TenantID, link.MessageID, link.Hash),
+	})
+	if err != nil {
+		r.log.Error("Failed to render embedded player", zap.Error(err))
+		c.Status(http.StatusInternalServerError)
+	}
+}
+
+// getEmbedCode returns the iframe snippet that embeds the player of the link,
+// sized with the width and height query params
+func (r *allRoutes) getEmbedCode(c *gin.Context) {
+	link := r.authorizedLink(c)
+	if link == nil {
+		return
+	}
+	if kind := mediaKind(link.MimeType); kind != "video" && kind != "audio" {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "only videos and audio can be embedded"})
+		return
+	}
+	width, err := embedSize(c.DefaultQuery("width", "640"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid width"})
+		return
+	}
+	height, err := embedSize(c.DefaultQuery("height", "360"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid height"})
+		return
+	}
+	embedURL := utils.EmbedURL(link.TenantID, link.MessageID, link.Hash)
+	c.JSON(http.StatusOK, gin.H{
+		"url":    embedURL,
+		"width":  width,
+		"height": height,
+		"html":   embedSnippet(embedURL, width, height),
+	})
+}
+
+func embedSize(value string) (int, error) {
+	size, err := strconv.Atoi(value)
+	if err != nil || size <= 0 || size > maxEmbedSize {
+		return 0, fmt.Errorf("invalid size %q", value)
+	}
+	return size, nil
+}
+
+// embedSnippet returns the iframe that embeds the player at the URL
+func embedSnippet(embedURL string, width int, height int) string {
+	return fmt.Sprintf(`<iframe src="%s" width="%d" height="%d" frameborder="0" allow="autoplay; fullscreen; picture-in-picture" allowfullscreen></iframe>`,
+		html.EscapeString(embedURL), width, height)
+}
@@ -0,0 +1,89 @@
+package routes
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/export"
+	"EverythingSuckz/fsb/internal/utils"
+	"EverythingSuckz/fsb/internal/webauth"
+	"bytes"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+func (r *allRoutes) LoadExport(route *Route) {
+	route.Engine.GET("/api/export/:kind", r.exportAPI)
+	route.Engine.GET("/export/:token", r.exportDownload)
+}
+
+// exportAPI exports users, history or links for scripts authenticated with EXPORT_API_TOKEN,
+// and for the ADMINS signed in to the browser, like with single sign-on
+func (r *allRoutes) exportAPI(c *gin.Context) {
+	if userID, ok := webauth.UserID(c.Request); ok && utils.Contains(config.Runtime.Admins(), userID) {
+		r.exportRequest(c)
+		return
+	}
+	token := config.ValueOf.ExportAPIToken
+	if token == "" {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Export API is disabled",
+		})
+		return
+	}
+	if r.lockedOut(c, tokenAttempts, "") {
+		return
+	}
+	given := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if given == "" {
+		given = c.Query("token")
+	}
+	if subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+		r.failedAttempt(c, tokenAttempts, "the export API", "")
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Invalid export token",
+		})
+		return
+	}
+	r.succeededAttempt(c, tokenAttempts, "")
+	r.exportRequest(c)
+}
+
+// exportRequest writes the export of the params of the request
+func (r *allRoutes) exportRequest(c *gin.Context) {
+	request, err := export.NewRequest(c.Param("kind"), c.Query("format"), c.Query("from"), c.Query("to"), c.Query("authorized") == "true")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	r.writeExport(c, request)
+}
+
+// exportDownload serves an export requested with /export ... link, once
+func (r *allRoutes) exportDownload(c *gin.Context) {
+	request := export.TakeDownload(c.Param("token"))
+	if request == nil {
+		c.String(http.StatusNotFound, "This download link has expired or was already used")
+		return
+	}
+	r.writeExport(c, request)
+}
+
+func (r *allRoutes) writeExport(c *gin.Context, request *export.Request) {
+	var buf bytes.Buffer
+	if _, err := export.Write(&buf, request); err != nil {
+		r.log.Error("Failed to export", zap.String("kind", request.Kind), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to export",
+		})
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", request.FileName()))
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, request.ContentType(), buf.Bytes())
+}
@@ -0,0 +1,154 @@
+package routes
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/library"
+	"EverythingSuckz/fsb/internal/media"
+	"EverythingSuckz/fsb/internal/types"
+	"EverythingSuckz/fsb/internal/utils"
+	"EverythingSuckz/fsb/internal/web"
+	"EverythingSuckz/fsb/internal/webauth"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// feedSize is the number of episodes in a podcast feed, the most recent first
+const feedSize = 200
+
+func (r *allRoutes) LoadFeed(route *Route) {
+	route.Engine.GET("/feed/:token", r.getFeed)
+}
+
+// rssFeed is an RSS 2.0 feed with the iTunes tags podcast apps read
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	ITunes  string     `xml:"xmlns:itunes,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title       string    `xml:"title"`
+	Link        string    `xml:"link"`
+	Description string    `xml:"description"`
+	Image       *rssImage `xml:"itunes:image,omitempty"`
+	Explicit    string    `xml:"itunes:explicit"`
+	Items       []rssItem `xml:"item"`
+}
+
+type rssImage struct {
+	Href string `xml:"href,attr"`
+}
+
+type rssItem struct {
+	Title     string       `xml:"title"`
+	Author    string       `xml:"itunes:author,omitempty"`
+	GUID      rssGUID      `xml:"guid"`
+	PubDate   string       `xml:"pubDate"`
+	Enclosure rssEnclosure `xml:"enclosure"`
+	Duration  int          `xml:"itunes:duration,omitempty"`
+	Image     *rssImage    `xml:"itunes:image,omitempty"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Length int64  `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+// getFeed serves the audio files of the user of the token of /podcast as a podcast feed,
+// only the ones in the category query param if it's given
+func (r *allRoutes) getFeed(c *gin.Context) {
+	if r.lockedOut(c, tokenAttempts, "") {
+		return
+	}
+	userID, version, ok := webauth.FeedUser(strings.TrimSuffix(c.Param("token"), ".xml"))
+	if !ok {
+		r.failedAttempt(c, tokenAttempts, "feed tokens", "")
+		http.Error(c.Writer, "this feed doesn't exist, send /podcast to the bot for yours", http.StatusNotFound)
+		return
+	}
+	r.succeededAttempt(c, tokenAttempts, "")
+	if !r.userTokenAllowed(c, userID, version) {
+		http.Error(c.Writer, "this feed was revoked, send /podcast to the bot for a new one", http.StatusNotFound)
+		return
+	}
+	filter, ok := categoryFilter(c)
+	if !ok {
+		return
+	}
+	links, ok := r.recentLinks(c, userID, library.MaxItems)
+	if !ok {
+		return
+	}
+	app := web.GetApp()
+	feed := rssFeed{
+		Version: "2.0",
+		ITunes:  "http://www.itunes.com/dtds/podcast-1.0.dtd",
+		Channel: rssChannel{
+			Title:       app.Name,
+			Link:        utils.PublicURL("/app"),
+			Description: fmt.Sprintf("Audio files sent to %s", app.Name),
+			Explicit:    "false",
+			Items:       []rssItem{},
+		},
+	}
+	if icon, ok := web.IconURLs()[512]; ok {
+		feed.Channel.Image = &rssImage{Href: config.Runtime.Host() + icon}
+	}
+	for i := range links {
+		link := &links[i]
+		if !strings.HasPrefix(link.MimeType, "audio/") || linkGone(link) != "" || filter != "" && link.Category != filter {
+			continue
+		}
+		feed.Channel.Items = append(feed.Channel.Items, feedItem(link))
+		if len(feed.Channel.Items) == feedSize {
+			break
+		}
+	}
+	body, err := xml.Marshal(feed)
+	if err != nil {
+		r.log.Error("Failed to write the feed", zap.Error(err))
+		http.Error(c.Writer, "failed to write the feed", http.StatusInternalServerError)
+		return
+	}
+	c.Header("Cache-Control", "private, max-age=300")
+	c.Data(http.StatusOK, "application/rss+xml; charset=utf-8", append([]byte(xml.Header), body...))
+}
+
+// feedItem returns the episode of an audio link, titled with its audio tags if it has them
+func feedItem(link *types.Link) rssItem {
+	title := link.Title
+	if title == "" {
+		title = strings.TrimSuffix(link.FileName, path.Ext(link.FileName))
+	}
+	item := rssItem{
+		Title:   title,
+		Author:  link.Performer,
+		GUID:    rssGUID{Value: fmt.Sprintf("%d-%d", link.TenantID, link.MessageID)},
+		PubDate: link.CreatedAt.Format(time.RFC1123Z),
+		Enclosure: rssEnclosure{
+			URL:    utils.StreamURL(link.TenantID, link.MessageID, link.Hash),
+			Length: link.FileSize,
+			Type:   link.MimeType,
+		},
+		Duration: link.Duration,
+	}
+	// the thumbnail is the cover art embedded in the file
+	if media.Enabled() {
+		item.Image = &rssImage{Href: utils.TenantURL(link.TenantID, fmt.Sprintf("/thumb/%d?hash=%s", link.MessageID, link.Hash))}
+	}
+	return item
+}
@@ -0,0 +1,26 @@
+package routes
+
+import (
+	"EverythingSuckz/fsb/internal/media"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (r *allRoutes) LoadFrames(route *Route) {
+	route.Engine.GET("/frames/:messageID/:file", r.getFrame)
+}
+
+func (r *allRoutes) getFrame(ctx *gin.Context) {
+	link := r.authorizedLink(ctx)
+	if link == nil {
+		return
+	}
+	path := media.FramePath(link.StorageKey(), ctx.Param("file"))
+	if _, err := os.Stat(path); err != nil {
+		http.Error(ctx.Writer, "frame not found", http.StatusNotFound)
+		return
+	}
+	ctx.File(path)
+}
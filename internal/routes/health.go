@@ -0,0 +1,20 @@
+package routes
+
+import (
+	"EverythingSuckz/fsb/internal/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (r *allRoutes) LoadHealth(route *Route) {
+	route.Engine.GET("/healthz", r.getHealth)
+}
+
+// getHealth reports that the server is up, with the ID of this instance
+func (r *allRoutes) getHealth(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"ok":       true,
+		"instance": utils.InstanceID,
+	})
+}
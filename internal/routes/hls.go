@@ -0,0 +1,139 @@
+package routes
+
+import (
+	"EverythingSuckz/fsb/internal/budget"
+	"EverythingSuckz/fsb/internal/features"
+	"EverythingSuckz/fsb/internal/media"
+	"EverythingSuckz/fsb/internal/types"
+	"EverythingSuckz/fsb/internal/utils"
+	"bufio"
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+func (r *allRoutes) LoadHLS(route *Route) {
+	route.Engine.GET("/hls/:messageID/*path", r.getHLS)
+}
+
+// getHLS serves the master playlist at /hls/:messageID/master.m3u8 and the
+// rendition playlists and segments at /hls/:messageID/<height>p/<file>
+func (r *allRoutes) getHLS(c *gin.Context) {
+	if !media.Enabled() {
+		http.Error(c.Writer, "adaptive streaming is not enabled", http.StatusServiceUnavailable)
+		return
+	}
+	link := r.authorizedLink(c)
+	if link == nil {
+		return
+	}
+	if !features.Enabled(features.Transcoding, link.UserID) {
+		http.Error(c.Writer, "adaptive streaming is not enabled", http.StatusServiceUnavailable)
+		return
+	}
+	query := "?hash=" + link.Hash
+	rendition, file := path.Split(strings.TrimPrefix(c.Param("path"), "/"))
+	if rendition == "" && file == "master.m3u8" {
+		info, ok := r.probeVideo(c, link)
+		if !ok {
+			return
+		}
+		c.Data(http.StatusOK, "application/vnd.apple.mpegurl", []byte(media.MasterPlaylist(info, query)))
+		return
+	}
+	height, err := strconv.Atoi(strings.TrimSuffix(rendition, "p/"))
+	if err != nil {
+		http.Error(c.Writer, "not found", http.StatusNotFound)
+		return
+	}
+	if file != "index.m3u8" {
+		segment := media.HLSFile(link.StorageKey(), height, file)
+		if _, err := os.Stat(segment); err != nil {
+			http.Error(c.Writer, "segment not found", http.StatusNotFound)
+			return
+		}
+		c.Header("Content-Type", "video/mp2t")
+		c.File(segment)
+		return
+	}
+	info, ok := r.probeVideo(c, link)
+	if !ok {
+		return
+	}
+	var selected *media.Rendition
+	for _, candidate := range media.Renditions(info) {
+		if candidate.Height == height {
+			selected = &candidate
+			break
+		}
+	}
+	if selected == nil {
+		http.Error(c.Writer, "rendition not available", http.StatusNotFound)
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), time.Minute)
+	defer cancel()
+	playlist, err := media.RenditionPlaylist(ctx, utils.InternalStreamURL(link.TenantID, link.MessageID, link.Hash), link.StorageKey(), *selected)
+	if errors.Is(err, budget.ErrExhausted) {
+		c.Header("Retry-After", strconv.Itoa(int(budget.QueueTimeout.Seconds())))
+		http.Error(c.Writer, "the server is busy, try again in a moment", http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		r.log.Error("Failed to prepare rendition", zap.Error(err), zap.Int("messageID", link.MessageID), zap.Int("height", height))
+		http.Error(c.Writer, "failed to prepare rendition", http.StatusInternalServerError)
+		return
+	}
+	content, err := withQuery(playlist, query)
+	if err != nil {
+		http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	c.Header("Cache-Control", "no-cache")
+	c.Data(http.StatusOK, "application/vnd.apple.mpegurl", content)
+}
+
+// probeVideo probes the stored file and makes sure it has a video stream
+func (r *allRoutes) probeVideo(c *gin.Context, link *types.Link) (*media.Info, bool) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), time.Minute)
+	defer cancel()
+	info, err := media.Probe(ctx, utils.InternalStreamURL(link.TenantID, link.MessageID, link.Hash))
+	if err != nil {
+		r.log.Error("Failed to probe media", zap.Error(err), zap.Int("messageID", link.MessageID))
+		http.Error(c.Writer, "failed to probe media", http.StatusInternalServerError)
+		return nil, false
+	}
+	if len(info.Video) == 0 {
+		http.Error(c.Writer, "file has no video stream", http.StatusBadRequest)
+		return nil, false
+	}
+	return info, true
+}
+
+// withQuery reads a playlist and appends query to the URI of every segment,
+// so the segment requests carry the link hash too
+func withQuery(playlist string, query string) ([]byte, error) {
+	f, err := os.Open(playlist)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var sb strings.Builder
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line != "" && !strings.HasPrefix(line, "#") {
+			line += query
+		}
+		sb.WriteString(line + "\n")
+	}
+	return []byte(sb.String()), scanner.Err()
+}
@@ -0,0 +1,71 @@
+package routes
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/tenant"
+	"EverythingSuckz/fsb/internal/types"
+	"EverythingSuckz/fsb/internal/utils"
+	"EverythingSuckz/fsb/internal/web"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// indexSize is the number of files listed on the public index
+const indexSize = 50
+
+func (r *allRoutes) LoadIndex(route *Route) {
+	route.Engine.GET("/index", r.getIndex)
+}
+
+// getIndex lists the recently shared files of the tenant, for community deployments
+// that run with PUBLIC_MODE and PUBLIC_INDEX
+func (r *allRoutes) getIndex(c *gin.Context) {
+	if !config.ValueOf.PublicMode || !config.ValueOf.PublicIndex {
+		http.Error(c.Writer, "not found", http.StatusNotFound)
+		return
+	}
+	linkRepository := database.GetLinkRepository()
+	if linkRepository == nil {
+		http.Error(c.Writer, "link storage is not available", http.StatusServiceUnavailable)
+		return
+	}
+	var after time.Time
+	if ttl := time.Duration(config.Runtime.LinkTTLHours()) * time.Hour; ttl > 0 {
+		after = time.Now().Add(-ttl)
+	}
+	links, err := linkRepository.ListPublic(tenant.FromContext(c.Request.Context()), after, indexSize)
+	if err != nil {
+		r.log.Error("Failed to list public links", zap.Error(err))
+		http.Error(c.Writer, "failed to list the files", http.StatusInternalServerError)
+		return
+	}
+	items := make([]web.IndexItem, 0, len(links))
+	for i := range links {
+		items = append(items, indexItem(&links[i]))
+	}
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Header("Cache-Control", "public, max-age=60")
+	if err := web.Index.Execute(c.Writer, items); err != nil {
+		r.log.Error("Failed to render index page", zap.Error(err))
+	}
+}
+
+// indexItem lists the link on a page, linking to the player for videos and audio
+func indexItem(link *types.Link) web.IndexItem {
+	item := web.IndexItem{
+		FileName:  link.FileName,
+		FileSize:  utils.FormatFileSize(link.FileSize),
+		Kind:      mediaKind(link.MimeType),
+		URL:       utils.StreamURL(link.TenantID, link.MessageID, link.Hash),
+		CreatedAt: link.CreatedAt.Format("2006-01-02 15:04"),
+	}
+	if item.Kind == "video" || item.Kind == "audio" {
+		item.URL = utils.TenantURL(link.TenantID, fmt.Sprintf("/player/%d?hash=%s", link.MessageID, link.Hash))
+	}
+	return item
+}
@@ -0,0 +1,246 @@
+package routes
+
+import (
+	"EverythingSuckz/fsb/internal/library"
+	"EverythingSuckz/fsb/internal/media"
+	"EverythingSuckz/fsb/internal/types"
+	"EverythingSuckz/fsb/internal/utils"
+	"EverythingSuckz/fsb/internal/version"
+	"EverythingSuckz/fsb/internal/web"
+	"EverythingSuckz/fsb/internal/webauth"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	// kodiPageSize is the number of items of a page of the Kodi API by default
+	kodiPageSize = 50
+	// kodiMaxPageSize is the number of items of a page of the Kodi API at most
+	kodiMaxPageSize = 200
+)
+
+// JSON-RPC 2.0 error codes of the Kodi API
+const (
+	rpcParseError     = -32700
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcServerError    = -32000
+)
+
+func (r *allRoutes) LoadKodi(route *Route) {
+	route.Engine.POST("/api/kodi", r.postKodi)
+}
+
+// kodiRequest is a JSON-RPC 2.0 request to the Kodi API
+type kodiRequest struct {
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	ID     any             `json:"id"`
+}
+
+type kodiError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type kodiResponse struct {
+	JSONRPC string     `json:"jsonrpc"`
+	Result  any        `json:"result,omitempty"`
+	Error   *kodiError `json:"error,omitempty"`
+	ID      any        `json:"id"`
+}
+
+// kodiListParams are the params of recent and search
+type kodiListParams struct {
+	Query   string `json:"query"`
+	Kind    string `json:"kind"` // video or audio, both if empty
+	Page    int    `json:"page"` // from 1
+	PerPage int    `json:"per_page"`
+}
+
+// kodiItem is a file listed by the Kodi API, its ID resolves to the stream URL
+type kodiItem struct {
+	ID        string    `json:"id"`
+	Title     string    `json:"title"`
+	Artist    string    `json:"artist,omitempty"`
+	FileName  string    `json:"file_name"`
+	MimeType  string    `json:"mime_type"`
+	Kind      string    `json:"kind"`
+	Size      int64     `json:"size"`
+	Duration  int       `json:"duration,omitempty"`
+	Thumbnail string    `json:"thumbnail,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type kodiPage struct {
+	Items   []kodiItem `json:"items"`
+	Page    int        `json:"page"`
+	PerPage int        `json:"per_page"`
+	HasMore bool       `json:"has_more"`
+}
+
+// postKodi answers the JSON-RPC requests of the Kodi add-on, signed in with the token of /kodi
+// or an API token with the read-history scope as a bearer token. The methods are info, recent
+// and search, which list the videos and audio files of the user a page at a time, and
+// resolve, which returns the stream URL of an item.
+func (r *allRoutes) postKodi(c *gin.Context) {
+	if r.lockedOut(c, tokenAttempts, "") {
+		return
+	}
+	token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	userID, version, ok := webauth.KodiUser(token)
+	apiToken := false
+	if !ok && strings.HasPrefix(token, webauth.APITokenPrefix) {
+		var err error
+		userID, err = webauth.APITokenUser(token, webauth.ScopeHistory)
+		ok = err == nil
+		apiToken = ok
+	}
+	if !ok {
+		r.failedAttempt(c, tokenAttempts, "Kodi tokens", "")
+		c.JSON(http.StatusUnauthorized, kodiResponse{JSONRPC: "2.0", Error: &kodiError{Code: rpcServerError, Message: "invalid token, send /kodi to the bot for yours"}})
+		return
+	}
+	r.succeededAttempt(c, tokenAttempts, "")
+	// API tokens are deleted when they're revoked, they don't have a version
+	if apiToken && !r.userAllowed(c, userID) || !apiToken && !r.userTokenAllowed(c, userID, version) {
+		c.JSON(http.StatusUnauthorized, kodiResponse{JSONRPC: "2.0", Error: &kodiError{Code: rpcServerError, Message: "this token was revoked, send /kodi to the bot for a new one"}})
+		return
+	}
+	var request kodiRequest
+	if err := json.NewDecoder(c.Request.Body).Decode(&request); err != nil {
+		c.JSON(http.StatusOK, kodiResponse{JSONRPC: "2.0", Error: &kodiError{Code: rpcParseError, Message: "invalid JSON"}})
+		return
+	}
+	result, rpcErr := r.kodiCall(userID, request)
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, kodiResponse{JSONRPC: "2.0", Result: result, Error: rpcErr, ID: request.ID})
+}
+
+func (r *allRoutes) kodiCall(userID int64, request kodiRequest) (any, *kodiError) {
+	switch request.Method {
+	case "info":
+		return gin.H{"name": web.GetApp().Name, "version": version.Version, "user_id": userID}, nil
+	case "recent", "search":
+		var params kodiListParams
+		if err := decodeKodiParams(request.Params, &params); err != nil {
+			return nil, err
+		}
+		if request.Method == "search" && strings.TrimSpace(params.Query) == "" {
+			return nil, &kodiError{Code: rpcInvalidParams, Message: "query is required"}
+		}
+		if request.Method == "recent" {
+			params.Query = ""
+		}
+		return r.kodiList(userID, params)
+	case "resolve":
+		var params struct {
+			ID string `json:"id"`
+		}
+		if err := decodeKodiParams(request.Params, &params); err != nil {
+			return nil, err
+		}
+		return r.kodiResolve(userID, params.ID)
+	}
+	return nil, &kodiError{Code: rpcMethodNotFound, Message: fmt.Sprintf("unknown method %q, use info, recent, search or resolve", request.Method)}
+}
+
+func decodeKodiParams(raw json.RawMessage, params any) *kodiError {
+	if len(raw) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(raw, params); err != nil {
+		return &kodiError{Code: rpcInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+func (r *allRoutes) kodiList(userID int64, params kodiListParams) (any, *kodiError) {
+	if params.Kind != "" && !slices.Contains(library.PlaylistKinds, params.Kind) {
+		return nil, &kodiError{Code: rpcInvalidParams, Message: "kind must be video or audio"}
+	}
+	if params.Page < 1 {
+		params.Page = 1
+	}
+	if params.PerPage < 1 {
+		params.PerPage = kodiPageSize
+	}
+	params.PerPage = min(params.PerPage, kodiMaxPageSize)
+	// one more than the page to know if there's another one
+	links, err := library.Search(userID, strings.TrimSpace(params.Query), params.Kind, (params.Page-1)*params.PerPage, params.PerPage+1)
+	if err != nil {
+		r.log.Error("Failed to list the files of the Kodi API", zap.Error(err), zap.Int64("userID", userID))
+		return nil, &kodiError{Code: rpcServerError, Message: "failed to list your files"}
+	}
+	page := kodiPage{Items: []kodiItem{}, Page: params.Page, PerPage: params.PerPage, HasMore: len(links) > params.PerPage}
+	for i := range links[:min(len(links), params.PerPage)] {
+		link := &links[i]
+		if linkGone(link) != "" {
+			continue
+		}
+		page.Items = append(page.Items, newKodiItem(link))
+	}
+	return page, nil
+}
+
+func newKodiItem(link *types.Link) kodiItem {
+	title := link.Title
+	if title == "" {
+		title = strings.TrimSuffix(link.FileName, path.Ext(link.FileName))
+	}
+	item := kodiItem{
+		ID:        fmt.Sprintf("%d:%d", link.TenantID, link.MessageID),
+		Title:     title,
+		Artist:    link.Performer,
+		FileName:  link.FileName,
+		MimeType:  link.MimeType,
+		Kind:      mediaKind(link.MimeType),
+		Size:      link.FileSize,
+		Duration:  link.Duration,
+		CreatedAt: link.CreatedAt,
+	}
+	if media.Enabled() && item.Kind == "video" {
+		item.Thumbnail = utils.TenantURL(link.TenantID, fmt.Sprintf("/thumb/%d?hash=%s", link.MessageID, link.Hash))
+	}
+	return item
+}
+
+// kodiResolve returns the stream URL of an item of the user
+func (r *allRoutes) kodiResolve(userID int64, id string) (any, *kodiError) {
+	tenantPart, messagePart, _ := strings.Cut(id, ":")
+	tenantID, tenantErr := strconv.ParseUint(tenantPart, 10, 0)
+	messageID, messageErr := strconv.Atoi(messagePart)
+	if tenantErr != nil || messageErr != nil {
+		return nil, &kodiError{Code: rpcInvalidParams, Message: "invalid id"}
+	}
+	link := storedLink(uint(tenantID), messageID)
+	notFound := &kodiError{Code: rpcInvalidParams, Message: "no such file"}
+	if link == nil {
+		return nil, notFound
+	}
+	owned, err := library.Owns(userID, link)
+	if err != nil {
+		r.log.Error("Failed to check the owner of a Kodi item", zap.Error(err), zap.Int64("userID", userID))
+		return nil, &kodiError{Code: rpcServerError, Message: "failed to resolve the file"}
+	}
+	if !owned {
+		return nil, notFound
+	}
+	if reason := linkGone(link); reason != "" {
+		return nil, &kodiError{Code: rpcInvalidParams, Message: reason}
+	}
+	return gin.H{
+		"url":       utils.StreamURL(link.TenantID, link.MessageID, link.Hash),
+		"mime_type": link.MimeType,
+		"item":      newKodiItem(link),
+	}, nil
+}
@@ -0,0 +1,174 @@
+package routes
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/enrich"
+	"EverythingSuckz/fsb/internal/media"
+	"EverythingSuckz/fsb/internal/tenant"
+	"EverythingSuckz/fsb/internal/types"
+	"EverythingSuckz/fsb/internal/utils"
+	"EverythingSuckz/fsb/internal/web"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+func (r *allRoutes) LoadLanding(route *Route) {
+	route.Engine.GET("/thumb/:messageID", r.getThumbnail)
+	route.Engine.GET("/oembed", r.getOEmbed)
+}
+
+// mediaKind returns video, audio or image for media types that get a landing page
+func mediaKind(mimeType string) string {
+	kind, _, _ := strings.Cut(mimeType, "/")
+	switch kind {
+	case "video", "audio", "image":
+		return kind
+	}
+	return ""
+}
+
+// wantsLanding reports whether the stream request comes from a browser or a link
+// preview crawler navigating to the link. Players, downloads and range requests
+// never ask for HTML, so they keep getting the media itself.
+func wantsLanding(r *http.Request, mimeType string) bool {
+	return r.Method == http.MethodGet &&
+		r.Header.Get("Range") == "" &&
+		r.URL.Query().Get("d") != "true" &&
+		strings.Contains(r.Header.Get("Accept"), "text/html") &&
+		mediaKind(mimeType) != "" &&
+		!utils.IsInternalRequest(r)
+}
+
+// renderLanding renders the HTML page of a link with its Open Graph and oEmbed metadata
+func renderLanding(c *gin.Context, tenantID uint, messageID int, hash string, file *types.File) {
+	streamURL := utils.StreamURL(tenantID, messageID, hash)
+	data := web.LandingData{
+		FileName:    file.FileName,
+		Title:       file.FileName,
+		Description: utils.FormatFileSize(file.FileSize) + " · " + file.MimeType,
+		FileSize:    utils.FormatFileSize(file.FileSize),
+		MimeType:    file.MimeType,
+		Kind:        mediaKind(file.MimeType),
+		URL:         streamURL,
+		DownloadURL: streamURL + "&d=true",
+		PlayerURL:   utils.TenantURL(tenantID, fmt.Sprintf("/player/%d?hash=%s", messageID, hash)),
+		OEmbedURL:   utils.PublicURL("/oembed?format=json&url=" + url.QueryEscape(streamURL)),
+	}
+	switch data.Kind {
+	case "image":
+		data.ThumbnailURL = streamURL
+	case "video", "audio":
+		if media.Enabled() {
+			ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+			defer cancel()
+			if info, err := media.Probe(ctx, utils.InternalStreamURL(tenantID, messageID, hash)); err == nil {
+				data.Duration = int(info.Duration)
+				if len(info.Video) > 0 {
+					data.Width, data.Height = info.Video[0].Width, info.Video[0].Height
+					data.ThumbnailURL = utils.TenantURL(tenantID, fmt.Sprintf("/thumb/%d?hash=%s", messageID, hash))
+				}
+			}
+		}
+	}
+	if metadata := enrich.Get(tenantID, messageID); metadata != nil {
+		data.Title = metadataTitle(metadata)
+		if metadata.Overview != "" {
+			data.Description = metadata.Overview
+		}
+		// posters make better previews than a frame of the video
+		if metadata.PosterURL != "" {
+			data.ThumbnailURL = metadata.PosterURL
+		}
+	}
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Header("Vary", "Accept")
+	if err := web.Landing.Execute(c.Writer, data); err != nil {
+		log.Error("Failed to render landing page", zap.Error(err))
+	}
+}
+
+// getThumbnail serves the thumbnail of a video link
+func (r *allRoutes) getThumbnail(c *gin.Context) {
+	if !media.Enabled() {
+		http.Error(c.Writer, "thumbnails are not enabled", http.StatusServiceUnavailable)
+		return
+	}
+	link := r.authorizedLink(c)
+	if link == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), time.Minute)
+	defer cancel()
+	path, err := media.Thumbnail(ctx, utils.InternalStreamURL(link.TenantID, link.MessageID, link.Hash), link.StorageKey())
+	if err != nil {
+		r.log.Error("Failed to create thumbnail", zap.Error(err), zap.Int("messageID", link.MessageID))
+		http.Error(c.Writer, "failed to create thumbnail", http.StatusInternalServerError)
+		return
+	}
+	c.Header("Cache-Control", "public, max-age=86400")
+	c.File(path)
+}
+
+// streamPath matches the path of stream links, after BASE_PATH and the tenant path
+var streamPath = regexp.MustCompile(`/stream/(\d+)$`)
+
+// getOEmbed implements the oEmbed endpoint for stream links
+func (r *allRoutes) getOEmbed(c *gin.Context) {
+	if format := c.DefaultQuery("format", "json"); format != "json" {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "only the json format is supported"})
+		return
+	}
+	target, err := url.Parse(c.Query("url"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid url"})
+		return
+	}
+	tenantID, path := tenant.Resolve(target.Path)
+	match := streamPath.FindStringSubmatch(path)
+	if match == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "unknown url"})
+		return
+	}
+	messageID, err := strconv.Atoi(match[1])
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "unknown url"})
+		return
+	}
+	link := r.authorizedMessage(c, tenantID, messageID, target.Query().Get("hash"))
+	if link == nil {
+		return
+	}
+	embedURL := utils.EmbedURL(link.TenantID, link.MessageID, link.Hash)
+	width, height := 640, 360
+	response := gin.H{
+		"version":       "1.0",
+		"title":         link.FileName,
+		"provider_name": config.ValueOf.AppName,
+		"provider_url":  utils.PublicURL("/app"),
+	}
+	switch mediaKind(link.MimeType) {
+	case "image":
+		response["type"] = "photo"
+		response["url"] = utils.StreamURL(link.TenantID, link.MessageID, link.Hash)
+		response["width"], response["height"] = width, height
+	case "video", "audio":
+		response["type"] = "video"
+		response["html"] = embedSnippet(embedURL, width, height)
+		response["width"], response["height"] = width, height
+		if media.Enabled() && mediaKind(link.MimeType) == "video" {
+			response["thumbnail_url"] = utils.TenantURL(link.TenantID, fmt.Sprintf("/thumb/%d?hash=%s", link.MessageID, link.Hash))
+		}
+	default:
+		response["type"] = "link"
+	}
+	c.JSON(http.StatusOK, response)
+}
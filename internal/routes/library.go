@@ -0,0 +1,54 @@
+package routes
+
+import (
+	"EverythingSuckz/fsb/internal/library"
+	"EverythingSuckz/fsb/internal/types"
+	"EverythingSuckz/fsb/internal/webauth"
+	"bytes"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+func (r *allRoutes) LoadLibrary(route *Route) {
+	route.Engine.GET("/api/library/strm", r.getLibraryStrm)
+}
+
+// getLibraryStrm downloads the .strm files of the recent videos and audio of the signed in
+// user as a zip, to add them to a Jellyfin, Plex or Kodi library
+func (r *allRoutes) getLibraryStrm(c *gin.Context) {
+	userID, ok := r.apiUser(c, webauth.ScopeHistory)
+	if !ok {
+		return
+	}
+	links, ok := r.recentLinks(c, userID, library.MaxItems)
+	if !ok {
+		return
+	}
+	var buf bytes.Buffer
+	if _, err := library.WriteArchive(&buf, links); err != nil {
+		r.log.Error("Failed to write the library", zap.Error(err), zap.Int64("userID", userID))
+		http.Error(c.Writer, "failed to export your library", http.StatusInternalServerError)
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="fsb-library.zip"`)
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, "application/zip", buf.Bytes())
+}
+
+// recentLinks returns the recent links of the profile of the user, or responds with the error
+func (r *allRoutes) recentLinks(c *gin.Context, userID int64, limit int) ([]types.Link, bool) {
+	links, err := library.Recent(userID, limit)
+	if errors.Is(err, library.ErrUnavailable) {
+		http.Error(c.Writer, err.Error(), http.StatusServiceUnavailable)
+		return nil, false
+	}
+	if err != nil {
+		r.log.Error("Failed to list the recent links", zap.Error(err), zap.Int64("userID", userID))
+		http.Error(c.Writer, "failed to list your files", http.StatusInternalServerError)
+		return nil, false
+	}
+	return links, true
+}
@@ -0,0 +1,88 @@
+package routes
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/tenant"
+	"EverythingSuckz/fsb/internal/types"
+	"EverythingSuckz/fsb/internal/utils"
+	"EverythingSuckz/fsb/internal/webauth"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// authorizedLink returns the stored link of the :messageID param in the tenant of the
+// request if the hash query param matches it. Otherwise it writes the error response and returns nil.
+// Wrong hashes lock the IP out after LOCKOUT_ATTEMPTS, like for streams.
+func (r *allRoutes) authorizedLink(ctx *gin.Context) *types.Link {
+	return r.authorizedLinkHash(ctx, ctx.Query("hash"))
+}
+
+// authorizedLinkHash is authorizedLink for routes with the hash in another place, like the path
+func (r *allRoutes) authorizedLinkHash(ctx *gin.Context, hash string) *types.Link {
+	messageID, err := strconv.Atoi(ctx.Param("messageID"))
+	if err != nil {
+		http.Error(ctx.Writer, err.Error(), http.StatusBadRequest)
+		return nil
+	}
+	return r.authorizedMessage(ctx, tenant.FromContext(ctx.Request.Context()), messageID, hash)
+}
+
+// authorizedMessage is authorizedLink for routes that take the link from elsewhere, like the
+// URL of an oEmbed request. The IP is locked out first, then the hash is checked, then
+// whether the link is gone, like for streams.
+func (r *allRoutes) authorizedMessage(ctx *gin.Context, tenantID uint, messageID int, hash string) *types.Link {
+	linkRepository := database.GetLinkRepository()
+	if linkRepository == nil {
+		http.Error(ctx.Writer, "link storage is not available", http.StatusServiceUnavailable)
+		return nil
+	}
+	if r.lockedOut(ctx, hashAttempts, linkTarget(tenantID, messageID)) {
+		return nil
+	}
+	link, err := linkRepository.Get(tenantID, messageID)
+	if err != nil || !link.MatchesHash(hash) {
+		r.failedAttempt(ctx, hashAttempts, "link hashes", linkTarget(tenantID, messageID))
+		http.Error(ctx.Writer, "invalid hash", http.StatusBadRequest)
+		return nil
+	}
+	r.succeededAttempt(ctx, hashAttempts, linkTarget(tenantID, messageID))
+	if reason := linkGone(link); reason != "" {
+		writeGone(ctx, reason)
+		return nil
+	}
+	if status, reason := linkForbidden(ctx.Request, link); status != 0 {
+		http.Error(ctx.Writer, reason, status)
+		return nil
+	}
+	return link
+}
+
+// linkForbidden returns the status and the error for a request of a private link by someone who
+// isn't allowed to open it, or 0 if the link can be opened. Private links can be opened by their
+// owner and the users they were shared with, once they signed in with /weblogin or with an API
+// token with the stream scope. There are no private links in PUBLIC_MODE.
+func linkForbidden(r *http.Request, link *types.Link) (int, string) {
+	linkRepository := database.GetLinkRepository()
+	if link == nil || linkRepository == nil || config.ValueOf.PublicMode || utils.IsInternalRequest(r) {
+		return 0, ""
+	}
+	shares, err := linkRepository.ListShares(link.TenantID, link.MessageID)
+	if err != nil {
+		return http.StatusServiceUnavailable, "link storage is not available"
+	}
+	if len(shares) == 0 {
+		return 0, ""
+	}
+	userID, ok := webauth.RequestUser(r, webauth.ScopeStream)
+	if !ok {
+		return http.StatusUnauthorized, "this link is private, send /weblogin to the bot and open the login link in this browser first"
+	}
+	if userID == link.UserID || slices.Contains(shares, userID) {
+		return 0, ""
+	}
+	return http.StatusForbidden, "this link wasn't shared with you"
+}
@@ -0,0 +1,50 @@
+package routes
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/database"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func qrPath(messageID int, hash string) string {
+	return fmt.Sprintf("/qr/%d/%s.png", messageID, hash)
+}
+
+func (s *testServer) oEmbedPath(messageID int, hash string) string {
+	return "/oembed?url=" + url.QueryEscape(s.URL+streamURLPath(messageID, hash))
+}
+
+func TestLinkEndpointsLockOutWrongHashes(t *testing.T) {
+	s := newTestServer(t)
+	config.ValueOf.LockoutAttempts = 3
+	t.Cleanup(func() { config.ValueOf.LockoutAttempts = 0 })
+	link := s.addLink(t, 1, 1024, testUser)
+
+	for _, path := range []string{qrPath(link.MessageID, "000000"), s.oEmbedPath(link.MessageID, "000000"), qrPath(link.MessageID, "111111")} {
+		res, body := s.request(t, http.MethodGet, path, nil)
+		expectStatus(t, res, body, http.StatusBadRequest)
+	}
+	for _, path := range []string{qrPath(link.MessageID, link.Hash), s.oEmbedPath(link.MessageID, link.Hash), streamURLPath(link.MessageID, link.Hash)} {
+		res, body := s.request(t, http.MethodGet, path, nil)
+		expectStatus(t, res, body, http.StatusTooManyRequests)
+	}
+}
+
+func TestLinkEndpointsOfRevokedLinks(t *testing.T) {
+	s := newTestServer(t)
+	link := s.addLink(t, 1, 1024, testUser)
+	for _, path := range []string{qrPath(link.MessageID, link.Hash), s.oEmbedPath(link.MessageID, link.Hash)} {
+		res, body := s.request(t, http.MethodGet, path, nil)
+		expectStatus(t, res, body, http.StatusOK)
+	}
+	if _, err := database.GetLinkRepository().Revoke(0, link.MessageID); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{qrPath(link.MessageID, link.Hash), s.oEmbedPath(link.MessageID, link.Hash)} {
+		res, body := s.request(t, http.MethodGet, path, nil)
+		expectStatus(t, res, body, http.StatusGone)
+	}
+}
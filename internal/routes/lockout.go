@@ -0,0 +1,102 @@
+package routes
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/crash"
+	"EverythingSuckz/fsb/internal/lockout"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+var (
+	// hashAttempts counts wrong link hashes by IP and by link
+	hashAttempts = lockout.New("hash")
+	// tokenAttempts counts wrong debug, export and login tokens by IP
+	tokenAttempts = lockout.New("token")
+)
+
+// linkTarget is the lockout key of a link
+func linkTarget(tenantID uint, messageID int) string {
+	return fmt.Sprintf("link:%d:%d", tenantID, messageID)
+}
+
+// lockedOut responds with 429 Too Many Requests if the IP or the target, like a link or a
+// dashboard account, is locked out. A locked out target stays available to the IPs that
+// succeeded at it before, so that guessing it doesn't lock out the people who use it.
+func (r *allRoutes) lockedOut(c *gin.Context, limiter *lockout.Limiter, target string) bool {
+	ip := c.ClientIP()
+	wait := limiter.Locked("ip:" + ip)
+	if target != "" && !limiter.Trusted(trustKey(target, ip)) {
+		wait = max(wait, limiter.Locked(target))
+	}
+	if wait <= 0 {
+		return false
+	}
+	c.Header("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+	http.Error(c.Writer, "too many failed attempts, try again later", http.StatusTooManyRequests)
+	c.Abort()
+	return true
+}
+
+// succeededAttempt resets the failures in a row of the IP and trusts it at the target. The
+// failures of the target are kept, they may come from someone else still guessing it.
+func (r *allRoutes) succeededAttempt(c *gin.Context, limiter *lockout.Limiter, target string) {
+	ip := c.ClientIP()
+	if limiter.Failed("ip:" + ip) {
+		limiter.Succeed("ip:" + ip)
+	}
+	if target != "" {
+		limiter.Trust(trustKey(target, ip))
+	}
+}
+
+// trustKey is the key of an IP that succeeded at a target
+func trustKey(target string, ip string) string {
+	return target + "@" + ip
+}
+
+// failedAttempt counts a failed attempt of the IP at the target. It logs when they get
+// locked out and tells the admins once they keep failing.
+func (r *allRoutes) failedAttempt(c *gin.Context, limiter *lockout.Limiter, what string, target string) {
+	ip := c.ClientIP()
+	failures := limiter.Fail("ip:" + ip)
+	if target != "" {
+		failures = max(failures, limiter.Fail(target))
+	}
+	if failures == config.ValueOf.LockoutAttempts {
+		r.log.Warn("Locked out after failed attempts", zap.String("attempt", what), zap.String("ip", ip), zap.String("target", target), zap.Int("failures", failures))
+	}
+	if failures != lockout.AlertFailures {
+		return
+	}
+	message := fmt.Sprintf("🔐 %d failed attempts at %s, the last one from %s", failures, what, ip)
+	if target != "" {
+		message += fmt.Sprintf(" (%s)", target)
+	}
+	message += ". Attempts are locked out for up to an hour, but someone keeps trying."
+	go func() {
+		for _, userID := range alertRecipients() {
+			if err := r.telegram.Notify(userID, message, nil); err != nil {
+				r.log.Debug("Failed to send lockout alert", zap.Error(err), zap.Int64("userID", userID))
+			}
+		}
+	}()
+}
+
+// alertRecipients returns the owner and the admins of the bot
+func alertRecipients() []int64 {
+	recipients := []int64{}
+	if owner := crash.Owner(); owner != 0 {
+		recipients = append(recipients, owner)
+	}
+	for _, id := range config.Runtime.Admins() {
+		if id != crash.Owner() {
+			recipients = append(recipients, id)
+		}
+	}
+	return recipients
+}
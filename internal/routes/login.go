@@ -0,0 +1,39 @@
+package routes
+
+import (
+	"EverythingSuckz/fsb/internal/webauth"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (r *allRoutes) LoadLogin(route *Route) {
+	route.Engine.GET("/login", r.getLogin)
+}
+
+// getLogin signs the browser in with a login link of /weblogin, then redirects to the next
+// query param if it's a path on this server
+func (r *allRoutes) getLogin(c *gin.Context) {
+	if r.lockedOut(c, tokenAttempts, "") {
+		return
+	}
+	userID, ok := webauth.Login(c.Writer, c.Request, c.Query("token"))
+	if !ok {
+		r.failedAttempt(c, tokenAttempts, "web login links", "")
+		http.Error(c.Writer, "this login link is invalid or has expired, send /weblogin to the bot for a new one", http.StatusUnauthorized)
+		return
+	}
+	r.succeededAttempt(c, tokenAttempts, "")
+	if next := c.Query("next"); isLocalPath(next) {
+		c.Redirect(http.StatusFound, next)
+		return
+	}
+	c.String(http.StatusOK, fmt.Sprintf("Signed in as %d. The links shared with you can be opened in this browser now.", userID))
+}
+
+// isLocalPath reports whether the redirect target is a path on this server
+func isLocalPath(next string) bool {
+	return strings.HasPrefix(next, "/") && !strings.HasPrefix(next, "//") && !strings.HasPrefix(next, "/\\")
+}
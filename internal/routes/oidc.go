@@ -0,0 +1,137 @@
+package routes
+
+import (
+	"EverythingSuckz/fsb/internal/access"
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/oidc"
+	"EverythingSuckz/fsb/internal/types"
+	"EverythingSuckz/fsb/internal/utils"
+	"EverythingSuckz/fsb/internal/webauth"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+func (r *allRoutes) LoadOIDC(route *Route) {
+	route.Engine.GET("/oidc/login", r.getOIDCLogin)
+	route.Engine.GET("/oidc/callback", r.getOIDCCallback)
+}
+
+// oidcRedirectURI is the callback the provider redirects back to, it must be allowed in the
+// settings of the client at the provider
+func oidcRedirectURI() string {
+	return utils.PublicURL("/oidc/callback")
+}
+
+// getOIDCLogin redirects to the OIDC provider to sign in. With the link query param of /sso it
+// links the account to the Telegram user instead, afterwards it opens the next query param.
+func (r *allRoutes) getOIDCLogin(c *gin.Context) {
+	if !oidc.Enabled() || database.GetOIDCRepository() == nil {
+		http.Error(c.Writer, "single sign-on is not enabled", http.StatusNotFound)
+		return
+	}
+	login := &oidc.Login{Next: c.Query("next")}
+	if !isLocalPath(login.Next) {
+		login.Next = ""
+	}
+	if token := c.Query("link"); token != "" {
+		if r.lockedOut(c, tokenAttempts, "") {
+			return
+		}
+		userID, ok := webauth.SSOLinkUser(token)
+		if !ok {
+			r.failedAttempt(c, tokenAttempts, "SSO link tokens", "")
+			http.Error(c.Writer, "this link is invalid or has expired, send /sso to the bot for a new one", http.StatusUnauthorized)
+			return
+		}
+		r.succeededAttempt(c, tokenAttempts, "")
+		login.LinkUserID = userID
+	}
+	target, err := oidc.Start(c.Request.Context(), c.Writer, login, oidcRedirectURI())
+	if err != nil {
+		r.log.Error("Failed to start OIDC sign in", zap.Error(err))
+		http.Error(c.Writer, "the sign in provider is not available", http.StatusBadGateway)
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.Redirect(http.StatusFound, target)
+}
+
+// getOIDCCallback finishes the sign in at the provider. Accounts linked to a Telegram user
+// sign in as them, like with a login link of /weblogin.
+func (r *allRoutes) getOIDCCallback(c *gin.Context) {
+	oidcRepository := database.GetOIDCRepository()
+	if !oidc.Enabled() || oidcRepository == nil {
+		http.Error(c.Writer, "single sign-on is not enabled", http.StatusNotFound)
+		return
+	}
+	if reason := c.Query("error"); reason != "" {
+		http.Error(c.Writer, fmt.Sprintf("the sign in failed: %s %s", reason, c.Query("error_description")), http.StatusUnauthorized)
+		return
+	}
+	login, identity, err := oidc.Finish(c.Request.Context(), c.Writer, c.Request, oidcRedirectURI())
+	if errors.Is(err, oidc.ErrUnknownState) {
+		http.Error(c.Writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		r.log.Warn("Failed to finish OIDC sign in", zap.Error(err))
+		http.Error(c.Writer, "the sign in failed, try again", http.StatusUnauthorized)
+		return
+	}
+	userID := login.LinkUserID
+	if userID != 0 {
+		if !r.oidcAllowed(c, userID) {
+			return
+		}
+		err := oidcRepository.Link(&types.OIDCIdentity{Issuer: identity.Issuer, Subject: identity.Subject, UserID: userID, Email: identity.Email})
+		if err != nil {
+			r.log.Error("Failed to link OIDC account", zap.Error(err), zap.Int64("userID", userID))
+			http.Error(c.Writer, "failed to link your account", http.StatusInternalServerError)
+			return
+		}
+		r.log.Info("Linked OIDC account", zap.Int64("userID", userID), zap.String("subject", identity.Subject))
+	} else {
+		linked, err := oidcRepository.Get(identity.Issuer, identity.Subject)
+		if err != nil {
+			r.log.Error("Failed to get OIDC account", zap.Error(err))
+			http.Error(c.Writer, "failed to sign in", http.StatusServiceUnavailable)
+			return
+		}
+		if linked == nil {
+			http.Error(c.Writer, "this account isn't linked to a Telegram user yet, send /sso to the bot and open its link first", http.StatusForbidden)
+			return
+		}
+		userID = linked.UserID
+		if !r.oidcAllowed(c, userID) {
+			return
+		}
+	}
+	webauth.StartSession(c.Writer, userID)
+	if login.Next != "" {
+		c.Redirect(http.StatusFound, login.Next)
+		return
+	}
+	name := identity.Email
+	if name == "" {
+		name = identity.Name
+	}
+	if login.LinkUserID != 0 {
+		c.String(http.StatusOK, fmt.Sprintf("Linked %s to your Telegram account %d, sign in with it at %s from now on.", name, userID, utils.PublicURL("/oidc/login")))
+		return
+	}
+	c.String(http.StatusOK, fmt.Sprintf("Signed in as %d with %s. The links shared with you can be opened in this browser now.", userID, name))
+}
+
+// oidcAllowed answers with an error unless the user may use the bot, so that suspended and
+// removed users can't sign in or link accounts
+func (r *allRoutes) oidcAllowed(c *gin.Context, userID int64) bool {
+	if !access.Allowed(c.Request.Context(), userID, r.telegram.IsAdmin(c.Request.Context(), userID)) {
+		http.Error(c.Writer, "you are not allowed to use this bot", http.StatusForbidden)
+		return false
+	}
+	return true
+}
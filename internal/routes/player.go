@@ -0,0 +1,92 @@
+package routes
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/enrich"
+	"EverythingSuckz/fsb/internal/tenant"
+	"EverythingSuckz/fsb/internal/types"
+	"EverythingSuckz/fsb/internal/utils"
+	"EverythingSuckz/fsb/internal/web"
+	"fmt"
+	"html/template"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+func (r *allRoutes) LoadPlayer(route *Route) {
+	route.Engine.GET("/player/:messageID", r.getPlayer)
+}
+
+func (r *allRoutes) getPlayer(c *gin.Context) {
+	link := r.authorizedLink(c)
+	if link == nil {
+		return
+	}
+	data := web.PlayerData{
+		FileName:  link.FileName,
+		Title:     link.FileName,
+		MimeType:  link.MimeType,
+		StreamURL: utils.StreamURL(link.TenantID, link.MessageID, link.Hash),
+		SocketURL: fmt.Sprintf("%s%s/ws/%d?hash=%s", config.ValueOf.BasePath, tenant.Path(link.TenantID), link.MessageID, link.Hash),
+		OpenIn:    openInLinks(link),
+	}
+	if metadata := enrich.Get(link.TenantID, link.MessageID); metadata != nil {
+		data.Title, data.Overview, data.PosterURL = metadataTitle(metadata), metadata.Overview, metadata.PosterURL
+	} else if enrich.Missing(link) {
+		// links generated before the lookups were enabled are looked up once they're played
+		enrich.Enqueue(link)
+	}
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	err := web.Player.Execute(c.Writer, data)
+	if err != nil {
+		r.log.Error("Failed to render player", zap.Error(err))
+		c.Status(http.StatusInternalServerError)
+	}
+}
+
+func (r *allRoutes) LoadOpenIn(route *Route) {
+	route.Engine.GET("/open-in/:messageID", r.getOpenIn)
+}
+
+// getOpenIn serves the page of the Open in app button of link replies, with the links that
+// open the stream in native players. Telegram only opens http links from buttons.
+func (r *allRoutes) getOpenIn(c *gin.Context) {
+	link := r.authorizedLink(c)
+	if link == nil {
+		return
+	}
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	err := web.OpenIn.Execute(c.Writer, web.OpenInData{
+		FileName:  link.FileName,
+		StreamURL: utils.StreamURL(link.TenantID, link.MessageID, link.Hash),
+		Links:     openInLinks(link),
+	})
+	if err != nil {
+		r.log.Error("Failed to render open in page", zap.Error(err))
+		c.Status(http.StatusInternalServerError)
+	}
+}
+
+// metadataTitle returns the title found for a file with its artist and year, like
+// The Matrix (1999) or Artist – Title
+func metadataTitle(metadata *types.LinkMetadata) string {
+	title := metadata.Title
+	if metadata.Artist != "" {
+		title = metadata.Artist + " – " + title
+	}
+	if metadata.Year != 0 {
+		title = fmt.Sprintf("%s (%d)", title, metadata.Year)
+	}
+	return title
+}
+
+// openInLinks returns the links that open the stream of the link in the players of OPEN_IN_PLAYERS
+func openInLinks(link *types.Link) []web.OpenInLink {
+	links := []web.OpenInLink{}
+	for _, player := range utils.OpenInLinks(utils.StreamURL(link.TenantID, link.MessageID, link.Hash), link.MimeType) {
+		links = append(links, web.OpenInLink{Name: player.Name, URL: template.URL(player.URL)})
+	}
+	return links
+}
@@ -0,0 +1,46 @@
+package routes
+
+import (
+	"EverythingSuckz/fsb/internal/library"
+	"EverythingSuckz/fsb/internal/webauth"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (r *allRoutes) LoadPlaylist(route *Route) {
+	route.Engine.GET("/api/playlist.m3u", r.getPlaylist)
+	route.Engine.GET("/api/playlist.m3u8", r.getPlaylist)
+}
+
+// getPlaylist downloads the queue of the player of the signed in user, or the user of the API
+// token, as an M3U playlist. The
+// kind query param limits it to video or audio files and limit takes more of their history.
+func (r *allRoutes) getPlaylist(c *gin.Context) {
+	userID, ok := r.apiUser(c, webauth.ScopeHistory)
+	if !ok {
+		return
+	}
+	kind := c.Query("kind")
+	if kind != "" && !slices.Contains(library.PlaylistKinds, kind) {
+		http.Error(c.Writer, "kind must be "+strings.Join(library.PlaylistKinds, " or "), http.StatusBadRequest)
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(queueSize)))
+	if err != nil || limit < 1 || limit > library.MaxItems {
+		http.Error(c.Writer, "limit must be between 1 and "+strconv.Itoa(library.MaxItems), http.StatusBadRequest)
+		return
+	}
+	links, ok := r.recentLinks(c, userID, limit)
+	if !ok {
+		return
+	}
+	playlist, _ := library.Playlist(links, kind)
+	name := "playlist" + c.Request.URL.Path[strings.LastIndex(c.Request.URL.Path, "."):]
+	c.Header("Content-Disposition", `attachment; filename="`+name+`"`)
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, "audio/x-mpegurl; charset=utf-8", []byte(playlist))
+}
@@ -0,0 +1,87 @@
+package routes
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/web"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type manifestIcon struct {
+	Src     string `json:"src"`
+	Sizes   string `json:"sizes"`
+	Type    string `json:"type"`
+	Purpose string `json:"purpose"`
+}
+
+type manifest struct {
+	Name            string         `json:"name"`
+	ShortName       string         `json:"short_name"`
+	StartURL        string         `json:"start_url"`
+	Scope           string         `json:"scope"`
+	Display         string         `json:"display"`
+	ThemeColor      string         `json:"theme_color"`
+	BackgroundColor string         `json:"background_color"`
+	Icons           []manifestIcon `json:"icons"`
+}
+
+func (r *allRoutes) LoadPWA(route *Route) {
+	route.Engine.GET("/app", r.getHome)
+	route.Engine.GET("/manifest.webmanifest", getManifest)
+	route.Engine.GET("/sw.js", r.getServiceWorker)
+}
+
+// getHome serves the start page of the installed web app
+func (r *allRoutes) getHome(c *gin.Context) {
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	if err := web.Home.Execute(c.Writer, nil); err != nil {
+		r.log.Error("Failed to render home page", zap.Error(err))
+		c.Status(http.StatusInternalServerError)
+	}
+}
+
+// getManifest serves the web app manifest, scoped to BASE_PATH
+func getManifest(c *gin.Context) {
+	app := web.GetApp()
+	m := manifest{
+		Name:            app.Name,
+		ShortName:       app.ShortName,
+		StartURL:        config.ValueOf.BasePath + "/app",
+		Scope:           config.ValueOf.BasePath + "/",
+		Display:         "standalone",
+		ThemeColor:      app.ThemeColor,
+		BackgroundColor: app.BackgroundColor,
+	}
+	icons := web.IconURLs()
+	sizes := make([]int, 0, len(icons))
+	for size := range icons {
+		sizes = append(sizes, size)
+	}
+	sort.Ints(sizes)
+	for _, size := range sizes {
+		url := icons[size]
+		m.Icons = append(m.Icons, manifestIcon{
+			Src:     url,
+			Sizes:   fmt.Sprintf("%dx%d", size, size),
+			Type:    "image/png",
+			Purpose: "any maskable",
+		})
+	}
+	c.Header("Content-Type", "application/manifest+json")
+	c.JSON(http.StatusOK, m)
+}
+
+// getServiceWorker serves the service worker. It's not cached by the browser
+// for long, so new versions of the app are picked up quickly.
+func (r *allRoutes) getServiceWorker(c *gin.Context) {
+	c.Header("Content-Type", "text/javascript; charset=utf-8")
+	c.Header("Cache-Control", "no-cache")
+	if err := web.ServiceWorker.Execute(c.Writer, web.ServiceWorkerInfo()); err != nil {
+		r.log.Error("Failed to render service worker", zap.Error(err))
+		c.Status(http.StatusInternalServerError)
+	}
+}
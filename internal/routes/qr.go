@@ -0,0 +1,37 @@
+package routes
+
+import (
+	"EverythingSuckz/fsb/internal/utils"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"rsc.io/qr"
+)
+
+func (r *allRoutes) LoadQRCode(route *Route) {
+	route.Engine.GET("/qr/:messageID/:file", r.getQRCode)
+}
+
+// getQRCode renders a QR code of the stream link at /qr/:messageID/<hash>.png
+func (r *allRoutes) getQRCode(c *gin.Context) {
+	hash, ok := strings.CutSuffix(c.Param("file"), ".png")
+	if !ok {
+		http.Error(c.Writer, "not found", http.StatusNotFound)
+		return
+	}
+	link := r.authorizedLinkHash(c, hash)
+	if link == nil {
+		return
+	}
+	code, err := qr.Encode(utils.StreamURL(link.TenantID, link.MessageID, link.Hash), qr.M)
+	if err != nil {
+		r.log.Error("Failed to encode QR code", zap.Error(err))
+		http.Error(c.Writer, "failed to encode QR code", http.StatusInternalServerError)
+		return
+	}
+	code.Scale = 8
+	c.Header("Cache-Control", "public, max-age=86400")
+	c.Data(http.StatusOK, "image/png", code.PNG())
+}
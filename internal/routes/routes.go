@@ -1,6 +1,8 @@
 package routes
 
 import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/bot"
 	"reflect"
 
 	"github.com/gin-gonic/gin"
@@ -9,24 +11,28 @@ import (
 
 type Route struct {
 	Name   string
-	Engine *gin.Engine
+	Engine *gin.RouterGroup
 }
 
+// Init registers the routes under BASE_PATH
 func (r *Route) Init(engine *gin.Engine) {
-	r.Engine = engine
+	r.Engine = engine.Group(config.ValueOf.BasePath)
 }
 
 type allRoutes struct {
-	log *zap.Logger
+	log      *zap.Logger
+	telegram bot.Telegram
 }
 
-func Load(log *zap.Logger, r *gin.Engine) {
+// Load registers every route. The routes reach Telegram through telegram, which is
+// bot.Live when running the bot.
+func Load(log *zap.Logger, r *gin.Engine, telegram bot.Telegram) {
 	log = log.Named("routes")
 	defer log.Sugar().Info("Loaded all API Routes")
-	route := &Route{Name: "/", Engine: r}
+	route := &Route{Name: "/"}
 	route.Init(r)
-	Type := reflect.TypeOf(&allRoutes{log})
-	Value := reflect.ValueOf(&allRoutes{log})
+	Type := reflect.TypeOf(&allRoutes{log, telegram})
+	Value := reflect.ValueOf(&allRoutes{log, telegram})
 	for i := 0; i < Type.NumMethod(); i++ {
 		Type.Method(i).Func.Call([]reflect.Value{Value, reflect.ValueOf(route)})
 	}
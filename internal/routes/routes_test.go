@@ -0,0 +1,102 @@
+package routes
+
+import (
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/lockout"
+	"EverythingSuckz/fsb/internal/testenv"
+	"EverythingSuckz/fsb/internal/tgfake"
+	"EverythingSuckz/fsb/internal/types"
+	"EverythingSuckz/fsb/internal/utils"
+	"EverythingSuckz/fsb/internal/webauth"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	testLogChannel = testenv.LogChannelID
+	testUser       = 7
+)
+
+// testServer is the web server under test, see testenv.Start
+type testServer struct {
+	*testenv.Server
+}
+
+func TestMain(m *testing.M) {
+	utils.Logger = zap.NewNop()
+	os.Exit(m.Run())
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	hashAttempts, tokenAttempts = lockout.New("hash"), lockout.New("token")
+	return &testServer{testenv.Start(t, func(router *gin.Engine, telegram *tgfake.Telegram) {
+		Load(zap.NewNop(), router, telegram)
+	})}
+}
+
+// addFile posts a file with generated content to the log channel and returns its link hash
+func (s *testServer) addFile(messageID int, size int64) string {
+	file := s.Telegram.AddFile(testLogChannel, messageID, "test.bin", "application/octet-stream", size, tgfake.Pattern(size))
+	return utils.GetShortHash(utils.PackFile(file.FileName, file.FileSize, file.MimeType, file.ID))
+}
+
+// addLink adds a file like addFile and stores its link, generated by the user
+func (s *testServer) addLink(t *testing.T, messageID int, size int64, userID int64) *types.Link {
+	t.Helper()
+	link := &types.Link{MessageID: messageID, Hash: s.addFile(messageID, size), UserID: userID, FileName: "test.bin", FileSize: size}
+	if err := database.GetLinkRepository().Create(link); err != nil {
+		t.Fatal(err)
+	}
+	return link
+}
+
+// request sends the request with the headers, given as name and value pairs, and returns
+// the response with its body read
+func (s *testServer) request(t *testing.T, method string, path string, body io.Reader, header ...string) (*http.Response, []byte) {
+	t.Helper()
+	req, err := http.NewRequest(method, s.URL+path, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	res, err := s.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res, data
+}
+
+// sessionCookie returns the session cookie of the user, like the one of a login link
+func sessionCookie(t *testing.T, userID int64) string {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	webauth.StartSession(recorder, userID)
+	for _, cookie := range recorder.Result().Cookies() {
+		return cookie.Name + "=" + cookie.Value
+	}
+	t.Fatal("no session cookie was set")
+	return ""
+}
+
+// expectStatus fails the test unless the response has the status
+func expectStatus(t *testing.T, res *http.Response, body []byte, status int) {
+	t.Helper()
+	if res.StatusCode != status {
+		t.Fatalf("%s %s: status %d, expected %d: %s", res.Request.Method, res.Request.URL.Path, res.StatusCode, status, strings.TrimSpace(string(body)))
+	}
+}
@@ -0,0 +1,47 @@
+package routes
+
+import (
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+func (r *allRoutes) LoadShortLinks(route *Route) {
+	route.Engine.GET("/s/:alias", r.getShortLink)
+}
+
+// getShortLink redirects a short link to the stream link it stands for,
+// keeping query params like d=true
+func (r *allRoutes) getShortLink(c *gin.Context) {
+	shortLinkRepository := database.GetShortLinkRepository()
+	linkRepository := database.GetLinkRepository()
+	if shortLinkRepository == nil || linkRepository == nil {
+		http.Error(c.Writer, "short links are not available", http.StatusServiceUnavailable)
+		return
+	}
+	shortLink, err := shortLinkRepository.Get(c.Param("alias"))
+	if err != nil {
+		r.log.Error("Failed to get short link", zap.Error(err))
+		http.Error(c.Writer, "failed to resolve short link", http.StatusInternalServerError)
+		return
+	}
+	if shortLink == nil {
+		http.Error(c.Writer, "short link not found", http.StatusNotFound)
+		return
+	}
+	link, err := linkRepository.Get(shortLink.TenantID, shortLink.MessageID)
+	if err != nil {
+		http.Error(c.Writer, "short link not found", http.StatusNotFound)
+		return
+	}
+	target := utils.StreamURL(link.TenantID, link.MessageID, link.Hash)
+	query := c.Request.URL.Query()
+	query.Del("hash")
+	if len(query) > 0 {
+		target += "&" + query.Encode()
+	}
+	c.Redirect(http.StatusFound, target)
+}
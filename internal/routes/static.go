@@ -0,0 +1,24 @@
+package routes
+
+import (
+	"EverythingSuckz/fsb/internal/web"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (r *allRoutes) LoadStatic(route *Route) {
+	route.Engine.GET("/static/:file", getStatic)
+}
+
+// getStatic serves the web assets. Their names contain a hash of the content,
+// so they can be cached forever.
+func getStatic(c *gin.Context) {
+	asset, ok := web.GetAsset(c.Param("file"))
+	if !ok {
+		http.Error(c.Writer, "not found", http.StatusNotFound)
+		return
+	}
+	c.Header("Cache-Control", "public, max-age=31536000, immutable")
+	c.Data(http.StatusOK, asset.ContentType, asset.Content)
+}
@@ -2,7 +2,10 @@ package routes
 
 import (
 	"EverythingSuckz/fsb/internal/cache"
+	"EverythingSuckz/fsb/internal/database"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -10,6 +13,7 @@ import (
 
 func (r *allRoutes) LoadStatsAPI(route *Route) {
 	route.Engine.GET("/api/stats", r.getStats)
+	route.Engine.GET("/api/stats/playback", r.getPlaybackStats)
 }
 
 func (r *allRoutes) getStats(c *gin.Context) {
@@ -34,4 +38,48 @@ func (r *allRoutes) getStats(c *gin.Context) {
 		"success": true,
 		"data":    stats,
 	})
-} 
+}
+
+// getPlaybackStats returns the web player telemetry aggregated per file
+func (r *allRoutes) getPlaybackStats(c *gin.Context) {
+	telemetryRepository := database.GetTelemetryRepository()
+	if telemetryRepository == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": "Playback statistics are not available",
+		})
+		return
+	}
+	days, err := strconv.Atoi(c.DefaultQuery("days", "7"))
+	if err != nil || days <= 0 {
+		days = 7
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	since := time.Now().AddDate(0, 0, -days)
+	summary, err := telemetryRepository.Summary(since)
+	if err != nil {
+		r.log.Error("Failed to get playback statistics", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to retrieve playback statistics",
+		})
+		return
+	}
+	files, err := telemetryRepository.FileStats(since, limit)
+	if err != nil {
+		r.log.Error("Failed to get playback statistics", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to retrieve playback statistics",
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"days":    days,
+			"summary": summary,
+			"files":   files,
+		},
+	})
+}
@@ -1,12 +1,32 @@
 package routes
 
 import (
-	"EverythingSuckz/fsb/internal/bot"
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/abuse"
+	"EverythingSuckz/fsb/internal/activity"
+	"EverythingSuckz/fsb/internal/alerts"
+	"EverythingSuckz/fsb/internal/budget"
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/downloads"
+	"EverythingSuckz/fsb/internal/sessions"
+	"EverythingSuckz/fsb/internal/tenant"
+	"EverythingSuckz/fsb/internal/tracing"
+	"EverythingSuckz/fsb/internal/types"
 	"EverythingSuckz/fsb/internal/utils"
+	"EverythingSuckz/fsb/internal/web"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"expvar"
 	"fmt"
+	"hash"
 	"io"
+	"mime"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gotd/td/tg"
 	range_parser "github.com/quantumsheep/range-parser"
@@ -17,15 +37,73 @@ import (
 
 var log *zap.Logger
 
+// activeStreams counts the streams being sent, it's published at /debug/vars
+var activeStreams = expvar.NewInt("active_streams")
+
 func (e *allRoutes) LoadHome(r *Route) {
 	log = e.log.Named("Stream")
 	defer log.Info("Loaded stream route")
-	r.Engine.GET("/stream/:messageID", getStreamRoute)
+	r.Engine.GET("/stream/:messageID", e.getStreamRoute)
+}
+
+// storedLink returns the record of the link, or nil if there is none, e.g. for links
+// generated before links were stored. Links without a record never expire.
+func storedLink(tenantID uint, messageID int) *types.Link {
+	linkRepository := database.GetLinkRepository()
+	if linkRepository == nil {
+		return nil
+	}
+	link, err := linkRepository.Get(tenantID, messageID)
+	if err != nil {
+		return nil
+	}
+	return link
+}
+
+// linkGone returns why the link doesn't work anymore, or an empty string if it still works:
+// it was revoked with /revokeall, its file was deleted or it was generated more than
+// LINK_TTL_HOURS ago
+func linkGone(link *types.Link) string {
+	if link == nil {
+		return ""
+	}
+	if link.RevokedAt != nil {
+		return "this link was revoked"
+	}
+	if link.RemovedAt != nil {
+		return "the source of this link was removed"
+	}
+	ttl := time.Duration(config.Runtime.LinkTTLHours()) * time.Hour
+	if ttl > 0 && time.Since(link.CreatedAt) > ttl {
+		return "this link has expired"
+	}
+	return ""
+}
+
+// writeGone responds that the link doesn't work anymore, with a page for browsers
+func writeGone(ctx *gin.Context, reason string) {
+	if !strings.Contains(ctx.Request.Header.Get("Accept"), "text/html") {
+		http.Error(ctx.Writer, reason, http.StatusGone)
+		return
+	}
+	ctx.Header("Content-Type", "text/html; charset=utf-8")
+	ctx.Status(http.StatusGone)
+	if err := web.Gone.Execute(ctx.Writer, strings.ToUpper(reason[:1])+reason[1:]); err != nil {
+		log.Error("Failed to render gone page", zap.Error(err))
+	}
 }
 
-func getStreamRoute(ctx *gin.Context) {
+func (e *allRoutes) getStreamRoute(ctx *gin.Context) {
 	w := ctx.Writer
 	r := ctx.Request
+	started := time.Now()
+	// streams that couldn't be sent because of the server or Telegram count against SLO_STREAM_ERROR_RATE
+	failed := false
+	defer func() {
+		if r.Method != "HEAD" && !utils.IsInternalRequest(r) {
+			alerts.Stream(failed || w.Status() >= http.StatusInternalServerError)
+		}
+	}()
 
 	messageIDParm := ctx.Param("messageID")
 	messageID, err := strconv.Atoi(messageIDParm)
@@ -40,10 +118,31 @@ func getStreamRoute(ctx *gin.Context) {
 		return
 	}
 
-	worker := bot.GetNextWorker()
+	tenantID := tenant.FromContext(r.Context())
+	if e.lockedOut(ctx, hashAttempts, linkTarget(tenantID, messageID)) {
+		return
+	}
+
+	// checked first, files of removed links can't be fetched anymore
+	link := storedLink(tenantID, messageID)
+	if link != nil && link.MatchesHash(authHash) {
+		if reason := linkGone(link); reason != "" {
+			writeGone(ctx, reason)
+			return
+		}
+	}
 
-	file, err := utils.FileFromMessage(ctx, worker.Client, messageID)
+	file, api, err := e.telegram.File(ctx, tenant.LogChannel(tenantID), messageID)
+	if errors.Is(err, utils.ErrMessageDeleted) && link != nil && link.MatchesHash(authHash) {
+		// deleted while the bot wasn't running
+		if _, err := database.GetLinkRepository().MarkRemoved([]uint{tenantID}, []int{messageID}); err != nil {
+			log.Error("Failed to mark link as removed", zap.Error(err))
+		}
+		writeGone(ctx, "the source of this link was removed")
+		return
+	}
 	if err != nil {
+		failed = !errors.Is(err, utils.ErrMessageDeleted)
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
@@ -54,14 +153,44 @@ func getStreamRoute(ctx *gin.Context) {
 		file.MimeType,
 		file.ID,
 	)
-	if !utils.CheckHash(authHash, expectedHash) {
+	// links generated with another HASH_LENGTH are checked against the length they were generated with
+	compatible := link != nil && link.MatchesHash(authHash) && strings.HasPrefix(expectedHash, authHash)
+	if !utils.CheckHash(authHash, expectedHash) && !compatible {
+		e.failedAttempt(ctx, hashAttempts, "link hashes", linkTarget(tenantID, messageID))
 		http.Error(w, "invalid hash", http.StatusBadRequest)
 		return
 	}
+	e.succeededAttempt(ctx, hashAttempts, linkTarget(tenantID, messageID))
+	if reason := linkGone(link); reason != "" {
+		writeGone(ctx, reason)
+		return
+	}
+	if status, reason := linkForbidden(r, link); status != 0 {
+		http.Error(w, reason, status)
+		return
+	}
+
+	ctx.Header("Vary", "Accept")
+	if wantsLanding(r, file.MimeType) {
+		renderLanding(ctx, tenantID, messageID, authHash, file)
+		return
+	}
+
+	if r.Method != "HEAD" && isNewView(r) && !utils.IsInternalRequest(r) {
+		activity.SeenLink(tenantID, messageID)
+		if linkRepository := database.GetLinkRepository(); linkRepository != nil {
+			if err := linkRepository.RecordView(tenantID, messageID); err != nil {
+				log.Error("Failed to record view", zap.Error(err))
+			}
+		}
+		if detector := abuse.GetDetector(); detector != nil {
+			go detector.CheckAccess(tenantID, messageID, ctx.ClientIP(), utils.ClientCountry(r))
+		}
+	}
 
 	// for photo messages
 	if file.FileSize == 0 {
-		res, err := worker.Client.API().UploadGetFile(ctx, &tg.UploadGetFileRequest{
+		res, err := api.UploadGetFile(ctx, &tg.UploadGetFileRequest{
 			Location: file.Location,
 			Offset:   0,
 			Limit:    1024 * 1024,
@@ -76,13 +205,26 @@ func getStreamRoute(ctx *gin.Context) {
 			return
 		}
 		fileBytes := result.GetBytes()
-		ctx.Header("Content-Disposition", fmt.Sprintf("inline; filename=\"%s\"", file.FileName))
+		ctx.Header("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": servedName(link, file, messageID)}))
 		if r.Method != "HEAD" {
 			ctx.Data(http.StatusOK, file.MimeType, fileBytes)
 		}
 		return
 	}
 
+	if r.Method != "HEAD" && !utils.IsInternalRequest(r) {
+		// the transcode jobs reserved the memory of their own streams
+		release, err := budget.Reserve(r.Context(), utils.StreamMemory())
+		if errors.Is(err, budget.ErrExhausted) {
+			ctx.Header("Retry-After", strconv.Itoa(int(budget.QueueTimeout.Seconds())))
+			http.Error(w, "the server is busy, try again in a moment", http.StatusServiceUnavailable)
+			return
+		} else if err != nil {
+			return
+		}
+		defer release()
+	}
+
 	ctx.Header("Accept-Ranges", "bytes")
 	var start, end int64
 	rangeHeader := r.Header.Get("Range")
@@ -105,6 +247,11 @@ func getStreamRoute(ctx *gin.Context) {
 	}
 
 	contentLength := end - start + 1
+	span := tracing.FromContext(r.Context())
+	span.SetAttribute("fsb.message_id", messageID)
+	span.SetAttribute("fsb.file_size", file.FileSize)
+	span.SetAttribute("fsb.range_start", start)
+	span.SetAttribute("fsb.range_end", end)
 	mimeType := file.MimeType
 
 	if mimeType == "" {
@@ -120,12 +267,63 @@ func getStreamRoute(ctx *gin.Context) {
 		disposition = "attachment"
 	}
 
-	ctx.Header("Content-Disposition", fmt.Sprintf("%s; filename=\"%s\"", disposition, file.FileName))
+	ctx.Header("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": servedName(link, file, messageID)}))
 
 	if r.Method != "HEAD" {
-		lr, _ := utils.NewTelegramReader(ctx, worker.Client, file.Location, start, end, contentLength)
-		if _, err := io.CopyN(w, lr, contentLength); err != nil {
+		activeStreams.Add(1)
+		defer activeStreams.Add(-1)
+		streamCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		if link != nil {
+			session := sessions.Start(link.UserID, sessions.KindStream, ctx.ClientIP(), r.UserAgent(), cancel)
+			defer session.End()
+		}
+		lr, _ := utils.NewStreamReader(streamCtx, api, file.Location, start, end, contentLength)
+		defer lr.Close()
+		// the whole file passes through anyway, hash it for /checksum
+		var hasher hash.Hash
+		var out io.Writer = &firstByteWriter{Writer: w, started: started}
+		if link != nil && link.Checksum == "" && contentLength == file.FileSize {
+			hasher = sha256.New()
+			out = io.MultiWriter(out, hasher)
+		}
+		if _, err := io.CopyN(out, lr, contentLength); err != nil {
+			// streams the client stopped aren't failures
+			failed = r.Context().Err() == nil
 			log.Error("Error while copying stream", zap.Error(err))
+		} else if hasher != nil {
+			downloads.RecordChecksum(tenantID, messageID, hex.EncodeToString(hasher.Sum(nil)))
 		}
 	}
 }
+
+// firstByteWriter records how long the stream took to send its first byte for SLO_FIRST_BYTE_P95_MS
+type firstByteWriter struct {
+	io.Writer
+	started time.Time
+	written bool
+}
+
+func (w *firstByteWriter) Write(p []byte) (int, error) {
+	if !w.written && len(p) > 0 {
+		w.written = true
+		alerts.FirstByte(time.Since(w.started))
+	}
+	return w.Writer.Write(p)
+}
+
+// servedName returns the file name of the Content-Disposition header, the one of the link
+// if it's stored, so that its audio tags can be used by FILE_NAME_TEMPLATE
+func servedName(link *types.Link, file *types.File, messageID int) string {
+	if link != nil {
+		return utils.LinkFileName(link)
+	}
+	return utils.FileName(file, messageID)
+}
+
+// isNewView reports whether the request starts a new playback or download,
+// so that the following range requests of the same player aren't counted as views.
+func isNewView(r *http.Request) bool {
+	rangeHeader := r.Header.Get("Range")
+	return rangeHeader == "" || strings.HasPrefix(rangeHeader, "bytes=0-")
+}
@@ -0,0 +1,141 @@
+package routes
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/tgfake"
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// testFileSize isn't a multiple of the chunk size, so that the last chunk is partial
+const testFileSize = 8*1024*1024 + 123
+
+// content returns the bytes of a file added to the fake from start to end
+func content(size, start, end int64) []byte {
+	data := make([]byte, end-start+1)
+	tgfake.Pattern(size).ReadAt(data, start)
+	return data
+}
+
+func streamURLPath(messageID int, hash string) string {
+	return fmt.Sprintf("/stream/%d?hash=%s", messageID, hash)
+}
+
+func TestStreamWholeFile(t *testing.T) {
+	s := newTestServer(t)
+	hash := s.addFile(42, testFileSize)
+	res, body := s.request(t, http.MethodGet, streamURLPath(42, hash), nil)
+	expectStatus(t, res, body, http.StatusOK)
+	if res.Header.Get("Content-Length") != strconv.Itoa(testFileSize) {
+		t.Errorf("Content-Length %s, expected %d", res.Header.Get("Content-Length"), testFileSize)
+	}
+	if !bytes.Equal(body, content(testFileSize, 0, testFileSize-1)) {
+		t.Errorf("got %d bytes that differ from the file", len(body))
+	}
+}
+
+func TestStreamRanges(t *testing.T) {
+	s := newTestServer(t)
+	hash := s.addFile(42, testFileSize)
+	for _, tc := range []struct {
+		header     string
+		start, end int64
+	}{
+		{"bytes=0-0", 0, 0},
+		{"bytes=1000-1048581", 1000, 1048581},
+		{"bytes=1048576-2097151", 1048576, 2097151},
+		{"bytes=8388000-", 8388000, testFileSize - 1},
+		{"bytes=-200", testFileSize - 200, testFileSize - 1},
+	} {
+		t.Run(tc.header, func(t *testing.T) {
+			res, body := s.request(t, http.MethodGet, streamURLPath(42, hash), nil, "Range", tc.header)
+			expectStatus(t, res, body, http.StatusPartialContent)
+			if contentRange := fmt.Sprintf("bytes %d-%d/%d", tc.start, tc.end, testFileSize); res.Header.Get("Content-Range") != contentRange {
+				t.Errorf("Content-Range %q, expected %q", res.Header.Get("Content-Range"), contentRange)
+			}
+			if !bytes.Equal(body, content(testFileSize, tc.start, tc.end)) {
+				t.Errorf("got %d bytes that differ from the range", len(body))
+			}
+		})
+	}
+}
+
+func TestStreamRejectsInvalidRequests(t *testing.T) {
+	s := newTestServer(t)
+	hash := s.addFile(42, testFileSize)
+	for _, tc := range []struct {
+		name   string
+		path   string
+		header []string
+	}{
+		{"wrong hash", streamURLPath(42, "000000"), nil},
+		{"missing hash", "/stream/42", nil},
+		{"invalid message ID", streamURLPath(0x7fffffff+1, hash), nil},
+		{"unknown message", streamURLPath(43, hash), nil},
+		{"unsatisfiable range", streamURLPath(42, hash), []string{"Range", fmt.Sprintf("bytes=%d-", testFileSize)}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			res, body := s.request(t, http.MethodGet, tc.path, nil, tc.header...)
+			expectStatus(t, res, body, http.StatusBadRequest)
+		})
+	}
+}
+
+func TestStreamLinksThatStoppedWorking(t *testing.T) {
+	s := newTestServer(t)
+	links := database.GetLinkRepository()
+
+	revoked := s.addLink(t, 1, 1024, testUser)
+	if _, err := links.Revoke(0, revoked.MessageID); err != nil {
+		t.Fatal(err)
+	}
+	res, body := s.request(t, http.MethodGet, streamURLPath(revoked.MessageID, revoked.Hash), nil)
+	expectStatus(t, res, body, http.StatusGone)
+
+	expired := s.addLink(t, 2, 1024, testUser)
+	if err := database.DB.Model(expired).Update("created_at", time.Now().Add(-3*time.Hour)).Error; err != nil {
+		t.Fatal(err)
+	}
+	config.Runtime.SetLinkTTLHours(2)
+	res, body = s.request(t, http.MethodGet, streamURLPath(expired.MessageID, expired.Hash), nil)
+	expectStatus(t, res, body, http.StatusGone)
+	config.Runtime.SetLinkTTLHours(0)
+	res, body = s.request(t, http.MethodGet, streamURLPath(expired.MessageID, expired.Hash), nil)
+	expectStatus(t, res, body, http.StatusOK)
+
+	// deleted while the bot wasn't running, the link is marked removed on the first request
+	deleted := s.addLink(t, 3, 1024, testUser)
+	s.Telegram.DeleteMessage(testLogChannel, deleted.MessageID)
+	res, body = s.request(t, http.MethodGet, streamURLPath(deleted.MessageID, deleted.Hash), nil)
+	expectStatus(t, res, body, http.StatusGone)
+	if stored, err := links.Get(0, deleted.MessageID); err != nil || stored.RemovedAt == nil {
+		t.Errorf("the link of the deleted message wasn't marked removed: %v", err)
+	}
+}
+
+func TestStreamPrivateLinks(t *testing.T) {
+	s := newTestServer(t)
+	const friend, stranger = 8, 9
+	link := s.addLink(t, 1, 1024, testUser)
+	if err := database.GetLinkRepository().Share(0, link.MessageID, friend); err != nil {
+		t.Fatal(err)
+	}
+	path := streamURLPath(link.MessageID, link.Hash)
+
+	res, body := s.request(t, http.MethodGet, path, nil)
+	expectStatus(t, res, body, http.StatusUnauthorized)
+	res, body = s.request(t, http.MethodGet, path, nil, "Cookie", sessionCookie(t, stranger))
+	expectStatus(t, res, body, http.StatusForbidden)
+	for _, userID := range []int64{testUser, friend} {
+		res, body = s.request(t, http.MethodGet, path, nil, "Cookie", sessionCookie(t, userID))
+		expectStatus(t, res, body, http.StatusOK)
+		if !bytes.Equal(body, content(1024, 0, 1023)) {
+			t.Errorf("user %d got %d bytes that differ from the file", userID, len(body))
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package routes
+
+import (
+	"EverythingSuckz/fsb/internal/abuse"
+	"EverythingSuckz/fsb/internal/access"
+	"EverythingSuckz/fsb/internal/cache"
+	"EverythingSuckz/fsb/internal/category"
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/enrich"
+	"EverythingSuckz/fsb/internal/policy"
+	"EverythingSuckz/fsb/internal/profile"
+	"EverythingSuckz/fsb/internal/scanner"
+	"EverythingSuckz/fsb/internal/tenant"
+	"EverythingSuckz/fsb/internal/types"
+	"EverythingSuckz/fsb/internal/utils"
+	"EverythingSuckz/fsb/internal/webauth"
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// maxUploadSize is the size of the largest file bots can upload to Telegram
+const maxUploadSize = 2000 << 20
+
+func (r *allRoutes) LoadUpload(route *Route) {
+	route.Engine.POST("/api/upload", r.postUpload)
+}
+
+// postUpload uploads the body of the request to the log channel and generates its link, like
+// sending the file to the bot. The file name is taken from the name query param or the
+// Content-Disposition header, its type from Content-Type, eg.
+// curl -H "Authorization: Bearer fsb_..." --data-binary @movie.mkv "<host>/api/upload?name=movie.mkv"
+func (r *allRoutes) postUpload(c *gin.Context) {
+	userID, ok := r.apiUser(c, webauth.ScopeUpload)
+	if !ok {
+		return
+	}
+	if !access.Allowed(c.Request.Context(), userID, r.telegram.IsAdmin(c.Request.Context(), userID)) {
+		http.Error(c.Writer, "you are not allowed to use this bot", http.StatusForbidden)
+		return
+	}
+	size := c.Request.ContentLength
+	if size <= 0 {
+		http.Error(c.Writer, "the Content-Length of the file is required", http.StatusLengthRequired)
+		return
+	}
+	if size > maxUploadSize {
+		http.Error(c.Writer, "files can be up to 2000 MiB", http.StatusRequestEntityTooLarge)
+		return
+	}
+	name := c.Query("name")
+	if _, params, err := mime.ParseMediaType(c.GetHeader("Content-Disposition")); name == "" && err == nil {
+		name = params["filename"]
+	}
+	mimeType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	if mimeType == "" || mimeType == "application/x-www-form-urlencoded" {
+		mimeType = "application/octet-stream"
+	}
+	if name == "" {
+		http.Error(c.Writer, "the name query param is required", http.StatusBadRequest)
+		return
+	}
+	name = utils.SanitizeFileName(name, mimeType)
+	if err := policy.Check(&types.File{FileName: name, FileSize: size, MimeType: mimeType}); err != nil {
+		http.Error(c.Writer, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if detector := abuse.GetDetector(); detector != nil && !detector.AllowLink(userID) {
+		http.Error(c.Writer, "you are generating links too fast, please wait a bit and try again", http.StatusTooManyRequests)
+		return
+	}
+	workspace := access.Tenant(userID)
+	if workspace != nil && access.QuotaExceeded(workspace, userID) {
+		http.Error(c.Writer, fmt.Sprintf("you have reached the daily quota of %d links of %s, please try again tomorrow", workspace.DailyLinkQuota, workspace.Name), http.StatusTooManyRequests)
+		return
+	}
+	var tenantID uint
+	if workspace != nil {
+		tenantID = workspace.ID
+	}
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, size)
+	messageID, file, err := r.telegram.Upload(c.Request.Context(), tenant.LogChannel(tenantID), name, mimeType, body)
+	if err != nil {
+		r.log.Error("Failed to upload file", zap.Error(err), zap.Int64("userID", userID))
+		http.Error(c.Writer, "failed to upload the file", http.StatusBadGateway)
+		return
+	}
+	if scanner.Enabled() && r.infected(c.Request.Context(), userID, tenant.LogChannel(tenantID), messageID) {
+		if err := r.telegram.DeleteUpload(c.Request.Context(), tenant.LogChannel(tenantID), messageID); err != nil {
+			r.log.Error("Failed to delete infected upload", zap.Error(err), zap.Int("messageID", messageID))
+		}
+		http.Error(c.Writer, "this file was blocked by the virus scanner, an admin will review it", http.StatusUnprocessableEntity)
+		return
+	}
+	if statsCache := cache.GetStatsCache(); statsCache != nil {
+		if err := statsCache.RecordFileProcessed(file.FileSize); err != nil {
+			r.log.Error("Failed to record file statistics", zap.Error(err))
+		}
+	}
+	link := &types.Link{
+		TenantID:  tenantID,
+		MessageID: messageID,
+		Hash:      utils.GetShortHash(utils.PackFile(file.FileName, file.FileSize, file.MimeType, file.ID)),
+		UserID:    userID,
+		FileName:  file.FileName,
+		FileSize:  file.FileSize,
+		MimeType:  file.MimeType,
+		FileID:    file.ID,
+		Duration:  file.Duration,
+		Category:  category.Of(file),
+	}
+	// the link is only sent to the user and the players once it's stored, like the links of
+	// the files sent to the bot
+	if linkRepository := database.GetLinkRepository(); linkRepository != nil {
+		if err := linkRepository.Create(link); err != nil {
+			r.log.Error("Failed to store link", zap.Error(err))
+			http.Error(c.Writer, "failed to store the link", http.StatusInternalServerError)
+			return
+		}
+	}
+	message, markup := utils.LinkReply(link)
+	if err := r.telegram.Notify(userID, message, markup); err != nil {
+		r.log.Warn("Failed to send the link of an upload", zap.Error(err), zap.Int64("userID", userID))
+	}
+	profile.Notify(profile.Of(userID))
+	enrich.Enqueue(link)
+	c.JSON(http.StatusCreated, gin.H{
+		"message_id": link.MessageID,
+		"file_name":  link.FileName,
+		"file_size":  link.FileSize,
+		"mime_type":  link.MimeType,
+		"url":        utils.StreamURL(link.TenantID, link.MessageID, link.Hash),
+		"player_url": utils.TenantURL(link.TenantID, fmt.Sprintf("/player/%d?hash=%s", link.MessageID, link.Hash)),
+	})
+}
+
+// infected scans the uploaded file like the files sent to the bot, see access.Infected
+func (r *allRoutes) infected(ctx context.Context, userID int64, channelID int64, messageID int) bool {
+	file, client, err := r.telegram.File(ctx, channelID, messageID)
+	if err != nil {
+		r.log.Error("Failed to fetch upload for scanning", zap.Error(err), zap.Int("messageID", messageID))
+		return false
+	}
+	return access.Infected(ctx, client, userID, file, "upload")
+}
@@ -0,0 +1,144 @@
+package routes
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/access"
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/tenant"
+	"EverythingSuckz/fsb/internal/types"
+	"EverythingSuckz/fsb/internal/webauth"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+// apiToken stores an API token of the user with the scope and returns it
+func apiToken(t *testing.T, userID int64, scope string) string {
+	t.Helper()
+	token, digest, err := webauth.NewAPIToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = database.GetAPITokenRepository().Create(&types.APIToken{UserID: userID, Name: "test", Digest: digest, Scopes: scope})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func (s *testServer) upload(t *testing.T, token string, data []byte) (*http.Response, []byte) {
+	t.Helper()
+	return s.request(t, http.MethodPost, "/api/upload?name=movie.mp4", bytes.NewReader(data),
+		"Authorization", "Bearer "+token, "Content-Type", "video/mp4")
+}
+
+func TestUploadGeneratesLink(t *testing.T) {
+	s := newTestServer(t)
+	token := apiToken(t, testUser, webauth.ScopeUpload)
+	data := content(4096, 0, 4095)
+	res, body := s.upload(t, token, data)
+	expectStatus(t, res, body, http.StatusCreated)
+	var uploaded struct {
+		MessageID int    `json:"message_id"`
+		URL       string `json:"url"`
+	}
+	if err := json.Unmarshal(body, &uploaded); err != nil {
+		t.Fatal(err)
+	}
+	link, err := database.GetLinkRepository().Get(0, uploaded.MessageID)
+	if err != nil {
+		t.Fatalf("the link of the upload wasn't stored: %v", err)
+	}
+	if link.UserID != testUser || link.FileSize != int64(len(data)) {
+		t.Errorf("stored link of user %d with %d bytes", link.UserID, link.FileSize)
+	}
+	if sent := s.Telegram.Sent(); len(sent) != 1 || sent[0].UserID != testUser {
+		t.Errorf("the link was sent in %d messages, expected one to the user", len(sent))
+	}
+
+	res, body = s.request(t, http.MethodGet, streamURLPath(link.MessageID, link.Hash), nil)
+	expectStatus(t, res, body, http.StatusOK)
+	if !bytes.Equal(body, data) {
+		t.Errorf("streamed %d bytes that differ from the upload", len(body))
+	}
+}
+
+func TestUploadIsAuthorizedLikeTheBot(t *testing.T) {
+	s := newTestServer(t)
+	users := database.GetUserRepository()
+	if err := users.Touch(testUser, "someone", "Some"); err != nil {
+		t.Fatal(err)
+	}
+	data := content(1024, 0, 1023)
+
+	res, body := s.upload(t, apiToken(t, testUser, webauth.ScopeStream), data)
+	expectStatus(t, res, body, http.StatusForbidden)
+
+	token := apiToken(t, testUser, webauth.ScopeUpload)
+	config.Runtime.SetPrivateMode(true)
+	res, body = s.upload(t, token, data)
+	expectStatus(t, res, body, http.StatusForbidden)
+
+	if err := users.Authorize(testUser, nil); err != nil {
+		t.Fatal(err)
+	}
+	res, body = s.upload(t, token, data)
+	expectStatus(t, res, body, http.StatusCreated)
+
+	if err := users.SetSuspended(testUser, true); err != nil {
+		t.Fatal(err)
+	}
+	res, body = s.upload(t, token, data)
+	expectStatus(t, res, body, http.StatusForbidden)
+
+	if err := users.SetSuspended(testUser, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := users.Deauthorize(testUser); err != nil {
+		t.Fatal(err)
+	}
+	config.Runtime.SetPrivateMode(false)
+	res, body = s.upload(t, token, data)
+	expectStatus(t, res, body, http.StatusUnauthorized)
+
+	if sent := s.Telegram.Sent(); len(sent) != 1 {
+		t.Errorf("%d links were sent, expected only the one of the authorized upload", len(sent))
+	}
+}
+
+func TestUploadCountsTowardTheTenantQuota(t *testing.T) {
+	s := newTestServer(t)
+	workspace := &types.Tenant{Name: "Team", Path: "/team", InviteCode: "invite", DailyLinkQuota: 2}
+	if err := database.GetTenantRepository().Create(workspace); err != nil {
+		t.Fatal(err)
+	}
+	if err := tenant.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	users := database.GetUserRepository()
+	if err := users.Touch(testUser, "someone", "Some"); err != nil {
+		t.Fatal(err)
+	}
+	if err := users.SetTenant(testUser, workspace.ID); err != nil {
+		t.Fatal(err)
+	}
+	// a link of a file sent to the bot, the upload takes the last one of the quota
+	link := &types.Link{TenantID: workspace.ID, MessageID: 1, Hash: s.addFile(1, 1024), UserID: testUser, FileName: "test.bin", FileSize: 1024}
+	if err := database.GetLinkRepository().Create(link); err != nil {
+		t.Fatal(err)
+	}
+	token := apiToken(t, testUser, webauth.ScopeUpload)
+	data := content(1024, 0, 1023)
+	res, body := s.upload(t, token, data)
+	expectStatus(t, res, body, http.StatusCreated)
+
+	res, body = s.upload(t, token, data)
+	expectStatus(t, res, body, http.StatusTooManyRequests)
+	if !access.QuotaExceeded(workspace, testUser) {
+		t.Errorf("the upload didn't count toward the quota of the bot")
+	}
+	if sent := s.Telegram.Sent(); len(sent) != 1 {
+		t.Errorf("%d links were sent, expected only the one within the quota", len(sent))
+	}
+}
@@ -0,0 +1,31 @@
+package routes
+
+import (
+	"EverythingSuckz/fsb/internal/access"
+	"EverythingSuckz/fsb/internal/database"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// userTokenAllowed reports whether a token the bot signed for the user, like a WebDAV password,
+// still works: its version wasn't revoked by /revokeall or a deauthorization, and the user may
+// still use the bot, they weren't removed or suspended since
+func (r *allRoutes) userTokenAllowed(c *gin.Context, userID int64, version int) bool {
+	if userRepository := database.GetUserRepository().WithContext(c.Request.Context()); userRepository != nil {
+		current, err := userRepository.TokenVersion(userID)
+		if err != nil {
+			r.log.Error("Failed to get the token version", zap.Error(err), zap.Int64("userID", userID))
+			return false
+		}
+		if version != current {
+			return false
+		}
+	}
+	return r.userAllowed(c, userID)
+}
+
+// userAllowed reports whether the user of the request may use the bot, see access.Allowed
+func (r *allRoutes) userAllowed(c *gin.Context, userID int64) bool {
+	return access.Allowed(c.Request.Context(), userID, r.telegram.IsAdmin(c.Request.Context(), userID))
+}
@@ -0,0 +1,89 @@
+package routes
+
+import (
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/webauth"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// revocations are the ways the tokens signed for testUser stop working, in the order the tests apply them
+var revocations = []struct {
+	name   string
+	revoke func(t *testing.T)
+	// restore lets the user use the bot again, the revoked token stays revoked
+	restore func(t *testing.T)
+}{
+	{"revoked", func(t *testing.T) { must(t, database.GetUserRepository().RevokeTokens(testUser)) }, nil},
+	{"deauthorized", func(t *testing.T) { must(t, database.GetUserRepository().Deauthorize(testUser)) }, nil},
+	{"suspended", func(t *testing.T) { must(t, database.GetUserRepository().SetSuspended(testUser, true)) },
+		func(t *testing.T) { must(t, database.GetUserRepository().SetSuspended(testUser, false)) }},
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// tokenVersion returns the current token version of testUser
+func tokenVersion(t *testing.T) int {
+	t.Helper()
+	version, err := database.GetUserRepository().TokenVersion(testUser)
+	must(t, err)
+	return version
+}
+
+// testUserTokens checks that a token of testUser works until each revocation. request sends a
+// request with a token signed for the version and returns its status.
+func testUserTokens(t *testing.T, ok int, revokedStatus int, request func(version int) int) {
+	must(t, database.GetUserRepository().Touch(testUser, "someone", "Some"))
+	for _, revocation := range revocations {
+		version := tokenVersion(t)
+		if status := request(version); status != ok {
+			t.Fatalf("before being %s: status %d, expected %d", revocation.name, status, ok)
+		}
+		revocation.revoke(t)
+		if status := request(version); status != revokedStatus {
+			t.Errorf("%s: status %d, expected %d", revocation.name, status, revokedStatus)
+		}
+		if revocation.restore != nil {
+			revocation.restore(t)
+			if status := request(version); status != ok {
+				t.Errorf("after being %s: status %d, expected %d", revocation.name, status, ok)
+			}
+		}
+	}
+}
+
+func TestWebDAVPasswordsAreRevocable(t *testing.T) {
+	s := newTestServer(t)
+	s.addLink(t, 1, 1024, testUser)
+	testUserTokens(t, http.StatusMultiStatus, http.StatusUnauthorized, func(version int) int {
+		credentials := fmt.Sprintf("%d:%s", testUser, webauth.WebDAVPassword(testUser, version))
+		res, _ := s.request(t, "PROPFIND", "/dav/", nil,
+			"Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(credentials)), "Depth", "1")
+		return res.StatusCode
+	})
+}
+
+func TestFeedTokensAreRevocable(t *testing.T) {
+	s := newTestServer(t)
+	testUserTokens(t, http.StatusOK, http.StatusNotFound, func(version int) int {
+		res, _ := s.request(t, http.MethodGet, "/feed/"+webauth.FeedToken(testUser, version)+".xml", nil)
+		return res.StatusCode
+	})
+}
+
+func TestKodiTokensAreRevocable(t *testing.T) {
+	s := newTestServer(t)
+	testUserTokens(t, http.StatusOK, http.StatusUnauthorized, func(version int) int {
+		res, _ := s.request(t, http.MethodPost, "/api/kodi", strings.NewReader(`{"jsonrpc": "2.0", "method": "info", "id": 1}`),
+			"Authorization", "Bearer "+webauth.KodiToken(testUser, version))
+		return res.StatusCode
+	})
+}
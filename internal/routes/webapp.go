@@ -0,0 +1,96 @@
+package routes
+
+import (
+	"EverythingSuckz/fsb/internal/category"
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/profile"
+	"EverythingSuckz/fsb/internal/web"
+	"EverythingSuckz/fsb/internal/webauth"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+func (r *allRoutes) LoadWebApp(route *Route) {
+	route.Engine.GET("/webapp/open", r.getWebAppOpen)
+	route.Engine.POST("/webapp/login", r.postWebAppLogin)
+	route.Engine.GET("/webapp/links", r.getWebAppLinks)
+	route.Engine.GET("/api/history", r.getWebAppLinks)
+}
+
+// getWebAppOpen serves the page the Mini App buttons of the bot open. Inside Telegram it signs in
+// with the init data first, so that private links play, then it opens the next query param.
+func (r *allRoutes) getWebAppOpen(c *gin.Context) {
+	next := c.Query("next")
+	if !isLocalPath(next) {
+		http.Error(c.Writer, "invalid next page", http.StatusBadRequest)
+		return
+	}
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	if err := web.WebApp.Execute(c.Writer, next); err != nil {
+		r.log.Error("Failed to render web app page", zap.Error(err))
+		c.Status(http.StatusInternalServerError)
+	}
+}
+
+// postWebAppLogin signs in the user who opened the web app from the menu button of the bot,
+// with the init data Telegram passed to it
+func (r *allRoutes) postWebAppLogin(c *gin.Context) {
+	userID, err := webauth.ValidateInitData(c.PostForm("init_data"))
+	if errors.Is(err, webauth.ErrExpiredInitData) {
+		http.Error(c.Writer, "the web app was opened too long ago, open it again from the bot", http.StatusUnauthorized)
+		return
+	} else if err != nil {
+		http.Error(c.Writer, "invalid init data", http.StatusUnauthorized)
+		return
+	}
+	if userRepository := database.GetUserRepository().WithContext(c.Request.Context()); userRepository != nil {
+		if removed, err := userRepository.IsRemoved(userID); err != nil {
+			r.log.Error("Failed to check if user is removed", zap.Error(err), zap.Int64("userID", userID))
+		} else if removed {
+			http.Error(c.Writer, "you are not allowed to use this bot", http.StatusForbidden)
+			return
+		}
+	}
+	webauth.StartWebAppSession(c.Writer, userID)
+	c.JSON(http.StatusOK, gin.H{"user_id": userID})
+}
+
+// getWebAppLinks lists the recent links of the signed in user for the home page of the web
+// app, only the ones in the category query param if it's given. Scripts list them with an
+// API token at /api/history.
+func (r *allRoutes) getWebAppLinks(c *gin.Context) {
+	userID, ok := r.apiUser(c, webauth.ScopeHistory)
+	if !ok {
+		return
+	}
+	filter, ok := categoryFilter(c)
+	if !ok {
+		return
+	}
+	queue, err := r.playerQueue(profile.Of(userID), nil, filter)
+	if err != nil {
+		r.log.Error("Failed to list the links of the web app", zap.Error(err), zap.Int64("userID", userID))
+		http.Error(c.Writer, "failed to list your links", http.StatusInternalServerError)
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, queue.Items)
+}
+
+// categoryFilter returns the category of the category query param, empty without it. It
+// responds with an error and returns false for unknown categories.
+func categoryFilter(c *gin.Context) (string, bool) {
+	name := c.Query("category")
+	if name == "" {
+		return "", true
+	}
+	found, ok := category.Find(name)
+	if !ok {
+		http.Error(c.Writer, "unknown category, use one of "+strings.Join(category.Names(), ", "), http.StatusBadRequest)
+	}
+	return found, ok
+}
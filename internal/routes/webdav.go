@@ -0,0 +1,321 @@
+package routes
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/budget"
+	"EverythingSuckz/fsb/internal/library"
+	"EverythingSuckz/fsb/internal/sessions"
+	"EverythingSuckz/fsb/internal/tenant"
+	"EverythingSuckz/fsb/internal/types"
+	"EverythingSuckz/fsb/internal/utils"
+	"EverythingSuckz/fsb/internal/webauth"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	range_parser "github.com/quantumsheep/range-parser"
+	"go.uber.org/zap"
+)
+
+// webDAVMethods are the methods of the read-only WebDAV drive
+const webDAVMethods = "OPTIONS, PROPFIND, GET, HEAD"
+
+func (r *allRoutes) LoadWebDAV(route *Route) {
+	for _, method := range strings.Split(webDAVMethods, ", ") {
+		route.Engine.Handle(method, "/dav", r.webDAV)
+		route.Engine.Handle(method, "/dav/*name", r.webDAV)
+	}
+}
+
+// davMultistatus is the response to PROPFIND, see RFC 4918
+type davMultistatus struct {
+	XMLName   xml.Name      `xml:"D:multistatus"`
+	Namespace string        `xml:"xmlns:D,attr"`
+	Responses []davResponse `xml:"D:response"`
+}
+
+type davResponse struct {
+	Href     string      `xml:"D:href"`
+	Propstat davPropstat `xml:"D:propstat"`
+}
+
+type davPropstat struct {
+	Prop   davProp `xml:"D:prop"`
+	Status string  `xml:"D:status"`
+}
+
+type davProp struct {
+	DisplayName   string          `xml:"D:displayname"`
+	ResourceType  davResourceType `xml:"D:resourcetype"`
+	ContentLength *int64          `xml:"D:getcontentlength,omitempty"`
+	ContentType   string          `xml:"D:getcontenttype,omitempty"`
+	LastModified  string          `xml:"D:getlastmodified,omitempty"`
+	CreationDate  string          `xml:"D:creationdate,omitempty"`
+	ETag          string          `xml:"D:getetag,omitempty"`
+}
+
+type davResourceType struct {
+	Collection *struct{} `xml:"D:collection,omitempty"`
+}
+
+// collectionsFolder holds the folders of the collections of the user in the WebDAV drive
+const collectionsFolder = "Collections"
+
+// davTree holds the files of the WebDAV drive of a user by their path, and its folders
+type davTree struct {
+	files   map[string]*types.Link
+	folders map[string]bool
+}
+
+// webDAV serves the recent files of the user signed in with the credentials of /webdav
+// as a read-only WebDAV drive, a folder with one file per link and a folder per collection
+func (r *allRoutes) webDAV(c *gin.Context) {
+	if c.Request.Method == http.MethodOptions {
+		c.Header("DAV", "1")
+		c.Header("Allow", webDAVMethods)
+		c.Status(http.StatusOK)
+		return
+	}
+	if r.lockedOut(c, tokenAttempts, "") {
+		return
+	}
+	userID, version, ok := webauth.WebDAVUser(c.Request)
+	if !ok {
+		if _, _, sent := c.Request.BasicAuth(); sent {
+			r.failedAttempt(c, tokenAttempts, "WebDAV passwords", "")
+		}
+		c.Header("WWW-Authenticate", `Basic realm="fsb", charset="UTF-8"`)
+		http.Error(c.Writer, "send /webdav to the bot for your credentials", http.StatusUnauthorized)
+		return
+	}
+	r.succeededAttempt(c, tokenAttempts, "")
+	if !r.userTokenAllowed(c, userID, version) {
+		c.Header("WWW-Authenticate", `Basic realm="fsb", charset="UTF-8"`)
+		http.Error(c.Writer, "this password was revoked, send /webdav to the bot for a new one", http.StatusUnauthorized)
+		return
+	}
+	tree, err := r.webDAVFiles(c.Request.Context(), userID)
+	if err != nil {
+		r.log.Error("Failed to list the WebDAV files", zap.Error(err), zap.Int64("userID", userID))
+		http.Error(c.Writer, "failed to list your files", http.StatusInternalServerError)
+		return
+	}
+	name := strings.Trim(c.Param("name"), "/")
+	if name == "" || tree.folders[name] {
+		if c.Request.Method == "PROPFIND" {
+			r.writePropfind(c, tree, name)
+			return
+		}
+		http.Error(c.Writer, "open this address in a WebDAV client", http.StatusMethodNotAllowed)
+		return
+	}
+	link, ok := tree.files[name]
+	if !ok {
+		http.Error(c.Writer, "file not found", http.StatusNotFound)
+		return
+	}
+	if c.Request.Method == "PROPFIND" {
+		writeMultistatus(c, []davResponse{fileResponse(name, link)})
+		return
+	}
+	r.serveWebDAVFile(c, link)
+}
+
+// webDAVFiles returns the recent links of the profile of the user that still work by their
+// file name, and the links of the collections of the user in their folders in Collections.
+// Files sent twice with the same name get the message ID in the name of the later one.
+func (r *allRoutes) webDAVFiles(ctx context.Context, userID int64) (*davTree, error) {
+	tree := &davTree{files: make(map[string]*types.Link), folders: make(map[string]bool)}
+	links, err := library.Recent(userID, library.MaxItems)
+	if errors.Is(err, library.ErrUnavailable) {
+		return tree, nil
+	} else if err != nil {
+		return nil, err
+	}
+	tree.add("", links)
+	collections, err := library.Collections(userID)
+	if errors.Is(err, library.ErrUnavailable) {
+		return tree, nil
+	} else if err != nil {
+		return nil, err
+	}
+	for _, collection := range collections {
+		folder := path.Join(collectionsFolder, collection.Name)
+		tree.folders[collectionsFolder] = true
+		tree.folders[folder] = true
+		tree.add(folder, collection.Links)
+	}
+	return tree, nil
+}
+
+// add puts the links that still work in the folder
+func (t *davTree) add(folder string, links []types.Link) {
+	for i := range links {
+		link := &links[i]
+		if link.FileSize == 0 || linkGone(link) != "" {
+			continue
+		}
+		name := path.Join(folder, utils.LinkFileName(link))
+		if _, taken := t.files[name]; taken || t.folders[name] {
+			ext := path.Ext(name)
+			name = fmt.Sprintf("%s (%d)%s", strings.TrimSuffix(name, ext), link.MessageID, ext)
+		}
+		t.files[name] = link
+	}
+}
+
+// writePropfind lists the folder, and its folders and files unless the client asked for the
+// folder only
+func (r *allRoutes) writePropfind(c *gin.Context, tree *davTree, folder string) {
+	responses := []davResponse{folderResponse(folder)}
+	if c.GetHeader("Depth") != "0" {
+		for name := range tree.folders {
+			if davParent(name) == folder {
+				responses = append(responses, folderResponse(name))
+			}
+		}
+		for name, link := range tree.files {
+			if davParent(name) == folder {
+				responses = append(responses, fileResponse(name, link))
+			}
+		}
+	}
+	writeMultistatus(c, responses)
+}
+
+// davParent returns the folder of the path, empty for the root of the drive
+func davParent(name string) string {
+	if parent := path.Dir(name); parent != "." {
+		return parent
+	}
+	return ""
+}
+
+// davHref returns the escaped URL of the path in the drive
+func davHref(name string) string {
+	segments := strings.Split(name, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return config.ValueOf.BasePath + "/dav/" + strings.Join(segments, "/")
+}
+
+func folderResponse(name string) davResponse {
+	displayName := path.Base(name)
+	href := davHref(name) + "/"
+	if name == "" {
+		displayName = "fsb"
+		href = config.ValueOf.BasePath + "/dav/"
+	}
+	return davResponse{
+		Href: href,
+		Propstat: davPropstat{
+			Prop: davProp{
+				DisplayName:  displayName,
+				ResourceType: davResourceType{Collection: &struct{}{}},
+			},
+			Status: "HTTP/1.1 200 OK",
+		},
+	}
+}
+
+func fileResponse(name string, link *types.Link) davResponse {
+	size := link.FileSize
+	mimeType := link.MimeType
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	return davResponse{
+		Href: davHref(name),
+		Propstat: davPropstat{
+			Prop: davProp{
+				DisplayName:   path.Base(name),
+				ContentLength: &size,
+				ContentType:   mimeType,
+				LastModified:  link.CreatedAt.UTC().Format(http.TimeFormat),
+				CreationDate:  link.CreatedAt.UTC().Format(time.RFC3339),
+				ETag:          fmt.Sprintf(`"%d-%d-%s"`, link.TenantID, link.MessageID, link.Hash),
+			},
+			Status: "HTTP/1.1 200 OK",
+		},
+	}
+}
+
+func writeMultistatus(c *gin.Context, responses []davResponse) {
+	body, err := xml.Marshal(davMultistatus{Namespace: "DAV:", Responses: responses})
+	if err != nil {
+		http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusMultiStatus, "application/xml; charset=utf-8", append([]byte(xml.Header), body...))
+}
+
+// serveWebDAVFile streams the file of the link from Telegram, like the stream route
+func (r *allRoutes) serveWebDAVFile(c *gin.Context, link *types.Link) {
+	w := c.Writer
+	file, api, err := r.telegram.File(c, tenant.LogChannel(link.TenantID), link.MessageID)
+	if errors.Is(err, utils.ErrMessageDeleted) {
+		writeGone(c, "the source of this link was removed")
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	mimeType := file.MimeType
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	c.Header("Accept-Ranges", "bytes")
+	c.Header("Content-Type", mimeType)
+	c.Header("Last-Modified", link.CreatedAt.UTC().Format(http.TimeFormat))
+	start, end := int64(0), file.FileSize-1
+	status := http.StatusOK
+	if rangeHeader := c.GetHeader("Range"); rangeHeader != "" {
+		ranges, err := range_parser.Parse(file.FileSize, rangeHeader)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+		start, end = ranges[0].Start, ranges[0].End
+		c.Header("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, file.FileSize))
+		status = http.StatusPartialContent
+	}
+	contentLength := end - start + 1
+	c.Header("Content-Length", strconv.FormatInt(contentLength, 10))
+	if c.Request.Method == http.MethodHead {
+		w.WriteHeader(status)
+		return
+	}
+	release, err := budget.Reserve(c.Request.Context(), utils.StreamMemory())
+	if errors.Is(err, budget.ErrExhausted) {
+		c.Header("Retry-After", strconv.Itoa(int(budget.QueueTimeout.Seconds())))
+		http.Error(w, "the server is busy, try again in a moment", http.StatusServiceUnavailable)
+		return
+	} else if err != nil {
+		return
+	}
+	defer release()
+	w.WriteHeader(status)
+	activeStreams.Add(1)
+	defer activeStreams.Add(-1)
+	streamCtx, cancel := context.WithCancel(c)
+	defer cancel()
+	session := sessions.Start(link.UserID, sessions.KindStream, c.ClientIP(), c.Request.UserAgent(), cancel)
+	defer session.End()
+	lr, _ := utils.NewStreamReader(streamCtx, api, file.Location, start, end, contentLength)
+	defer lr.Close()
+	if _, err := io.CopyN(w, lr, contentLength); err != nil {
+		r.log.Debug("WebDAV download ended early", zap.Error(err))
+	}
+}
@@ -0,0 +1,281 @@
+package routes
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/activity"
+	"EverythingSuckz/fsb/internal/crash"
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/features"
+	"EverythingSuckz/fsb/internal/media"
+	"EverythingSuckz/fsb/internal/onboarding"
+	"EverythingSuckz/fsb/internal/profile"
+	"EverythingSuckz/fsb/internal/sessions"
+	"EverythingSuckz/fsb/internal/types"
+	"EverythingSuckz/fsb/internal/utils"
+	"EverythingSuckz/fsb/internal/webauth"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"golang.org/x/net/websocket"
+)
+
+// maxPlaybackEvents caps the events stored per player connection
+const maxPlaybackEvents = 2000
+
+// playbackEventTypes lists the telemetry events accepted from the player
+var playbackEventTypes = map[string]bool{
+	"play":     true,
+	"pause":    true,
+	"seek":     true,
+	"stall":    true,
+	"progress": true,
+}
+
+// playerInfo is sent to the player when it connects
+type playerInfo struct {
+	Type       string          `json:"type"`
+	FileName   string          `json:"file_name"`
+	FileSize   int64           `json:"file_size"`
+	MimeType   string          `json:"mime_type"`
+	StreamURL  string          `json:"stream_url"`
+	HLSURL     string          `json:"hls_url,omitempty"`
+	DirectPlay *bool           `json:"direct_play,omitempty"`
+	Chapters   []media.Chapter `json:"chapters"`
+}
+
+// queueSize is the number of recent links shown in the queue of the player
+const queueSize = 20
+
+// playerQueue lists the recent links of the player profile of the link owner
+type playerQueue struct {
+	Type  string      `json:"type"`
+	Items []queueItem `json:"items"`
+}
+
+type queueItem struct {
+	FileName  string    `json:"file_name"`
+	URL       string    `json:"url"`
+	Category  string    `json:"category"`
+	Current   bool      `json:"current"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// playerControl is a remote control action sent with /remote
+type playerControl struct {
+	Type   string `json:"type"`
+	Action string `json:"action"`
+}
+
+// playerEvent is a telemetry event sent by the player
+type playerEvent struct {
+	Type     string  `json:"type"`
+	Position float64 `json:"position"`
+	Watched  float64 `json:"watched"`
+}
+
+func (r *allRoutes) LoadWebSocket(route *Route) {
+	route.Engine.GET("/ws/:messageID", r.getWebSocket)
+}
+
+func (r *allRoutes) getWebSocket(c *gin.Context) {
+	link := r.authorizedLink(c)
+	if link == nil {
+		return
+	}
+	websocket.Handler(func(conn *websocket.Conn) {
+		defer conn.Close()
+		session := sessions.Start(link.UserID, sessions.KindPlayer, c.ClientIP(), c.Request.UserAgent(), func() { conn.Close() })
+		defer session.End()
+		if err := websocket.JSON.Send(conn, r.playerInfo(c.Request.Context(), link)); err != nil {
+			return
+		}
+		activity.Seen(link.UserID)
+		if config.ValueOf.PublicMode {
+			// links are public, viewers don't get the queue of the owner or count as their onboarding
+			r.discardMessages(conn)
+			return
+		}
+		go r.continueOnboarding(link.UserID)
+		done := make(chan struct{})
+		defer close(done)
+		go r.sendQueue(conn, link, done)
+		// the owner can't remote control the players of the users they shared the link with
+		if viewer, ok := webauth.UserID(c.Request); !ok || viewer == link.UserID {
+			go r.sendControls(conn, session.EnableControls(), done)
+		}
+		r.receiveTelemetry(conn, link)
+	}).ServeHTTP(c.Writer, c.Request)
+}
+
+// continueOnboarding completes the player onboarding step of the link owner and sends them the next step
+func (r *allRoutes) continueOnboarding(userID int64) {
+	defer crash.Recover("onboarding")
+	prompt, err := onboarding.Complete(userID, onboarding.StepPlayer)
+	if err != nil {
+		r.log.Error("Failed to continue onboarding", zap.Error(err), zap.Int64("userID", userID))
+	}
+	if prompt == nil {
+		return
+	}
+	if err := r.telegram.Notify(userID, prompt.Text, prompt.Markup); err != nil {
+		r.log.Debug("Failed to send onboarding prompt", zap.Error(err), zap.Int64("userID", userID))
+	}
+}
+
+// playerInfo describes the file for the player, including the probed
+// media details when ffmpeg is available
+func (r *allRoutes) playerInfo(ctx context.Context, link *types.Link) playerInfo {
+	info := playerInfo{
+		Type:      "info",
+		FileName:  link.FileName,
+		FileSize:  link.FileSize,
+		MimeType:  link.MimeType,
+		StreamURL: utils.StreamURL(link.TenantID, link.MessageID, link.Hash),
+		Chapters:  []media.Chapter{},
+	}
+	if !media.Enabled() || !strings.Contains(link.MimeType, "video") {
+		return info
+	}
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	probed, err := media.Probe(ctx, utils.InternalStreamURL(link.TenantID, link.MessageID, link.Hash))
+	if err != nil {
+		r.log.Warn("Failed to probe media for the player", zap.Error(err), zap.Int("messageID", link.MessageID))
+		return info
+	}
+	directPlay := probed.DirectPlayable()
+	info.DirectPlay = &directPlay
+	if probed.Chapters != nil {
+		info.Chapters = probed.Chapters
+	}
+	if len(probed.Video) > 0 && features.Enabled(features.Transcoding, link.UserID) {
+		info.HLSURL = utils.TenantURL(link.TenantID, fmt.Sprintf("/hls/%d/master.m3u8?hash=%s", link.MessageID, link.Hash))
+	}
+	return info
+}
+
+// sendQueue sends the queue of the profile of the link owner to the player and
+// sends it again whenever the queue changes, until done is closed
+func (r *allRoutes) sendQueue(conn *websocket.Conn, link *types.Link, done <-chan struct{}) {
+	defer crash.Recover("player")
+	for {
+		// the owner's profile changes when the account gets linked to another one
+		profileID := profile.Of(link.UserID)
+		updates, cancel := profile.Subscribe(profileID)
+		queue, err := r.playerQueue(profileID, link, "")
+		if err != nil {
+			r.log.Error("Failed to list the queue", zap.Error(err), zap.Int64("profileID", profileID))
+		} else if err := websocket.JSON.Send(conn, queue); err != nil {
+			cancel()
+			return
+		}
+		select {
+		case <-updates:
+			cancel()
+		case <-done:
+			cancel()
+			return
+		}
+	}
+}
+
+// sendControls sends the remote control actions of the link owner to the player until done is closed
+func (r *allRoutes) sendControls(conn *websocket.Conn, controls <-chan string, done <-chan struct{}) {
+	defer crash.Recover("player")
+	for {
+		select {
+		case action := <-controls:
+			if err := websocket.JSON.Send(conn, playerControl{Type: "control", Action: action}); err != nil {
+				return
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+// playerQueue lists the recent links generated by any account of the profile, the current
+// link is marked if there's one
+func (r *allRoutes) playerQueue(profileID int64, current *types.Link, category string) (playerQueue, error) {
+	queue := playerQueue{Type: "queue", Items: []queueItem{}}
+	linkRepository := database.GetLinkRepository()
+	if linkRepository == nil {
+		return queue, nil
+	}
+	accounts, err := profile.Accounts(profileID)
+	if err != nil {
+		return queue, err
+	}
+	var links []types.Link
+	if category != "" {
+		links, err = linkRepository.ListByCategory(accounts, category, queueSize)
+	} else {
+		links, err = linkRepository.ListByUsers(accounts, queueSize)
+	}
+	if err != nil {
+		return queue, err
+	}
+	for _, link := range links {
+		url := utils.StreamURL(link.TenantID, link.MessageID, link.Hash)
+		if strings.Contains(link.MimeType, "video") || strings.Contains(link.MimeType, "audio") {
+			url = utils.TenantURL(link.TenantID, fmt.Sprintf("/player/%d?hash=%s", link.MessageID, link.Hash))
+		}
+		queue.Items = append(queue.Items, queueItem{
+			FileName:  link.FileName,
+			URL:       url,
+			Category:  link.Category,
+			Current:   current != nil && link.TenantID == current.TenantID && link.MessageID == current.MessageID,
+			CreatedAt: link.CreatedAt,
+		})
+	}
+	return queue, nil
+}
+
+// discardMessages reads the messages of the player until it disconnects, without storing them
+func (r *allRoutes) discardMessages(conn *websocket.Conn) {
+	for {
+		var event playerEvent
+		if err := websocket.JSON.Receive(conn, &event); err != nil {
+			return
+		}
+	}
+}
+
+// receiveTelemetry stores the playback events sent by the player until it disconnects
+func (r *allRoutes) receiveTelemetry(conn *websocket.Conn, link *types.Link) {
+	telemetryRepository := database.GetTelemetryRepository()
+	session := newSessionID()
+	for received := 0; ; received++ {
+		var event playerEvent
+		if err := websocket.JSON.Receive(conn, &event); err != nil {
+			return
+		}
+		if telemetryRepository == nil || received >= maxPlaybackEvents || !playbackEventTypes[event.Type] {
+			continue
+		}
+		err := telemetryRepository.Record(&types.PlaybackEvent{
+			TenantID:  link.TenantID,
+			MessageID: link.MessageID,
+			Session:   session,
+			Type:      event.Type,
+			Position:  event.Position,
+			Watched:   event.Watched,
+		})
+		if err != nil {
+			r.log.Error("Failed to record playback event", zap.Error(err))
+		}
+	}
+}
+
+// newSessionID returns a random ID for an anonymous player session
+func newSessionID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,95 @@
+package scanner
+
+import (
+	"EverythingSuckz/fsb/config"
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// chunkSize is the size of the chunks streamed to clamd
+const chunkSize = 64 * 1024
+
+// Result is the verdict of a scan
+type Result struct {
+	Infected  bool
+	Signature string
+}
+
+// Enabled reports whether a clamd address is configured
+func Enabled() bool {
+	return config.ValueOf.ClamAVAddress != ""
+}
+
+// Scan streams the content of r to clamd using the INSTREAM command
+func Scan(r io.Reader) (*Result, error) {
+	conn, err := dial(config.ValueOf.ClamAVAddress)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to clamd: %w", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Minute))
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, chunkSize)
+	size := make([]byte, 4)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			binary.BigEndian.PutUint32(size, uint32(n))
+			if _, err := conn.Write(size); err != nil {
+				return nil, err
+			}
+			if _, err := conn.Write(buf[:n]); err != nil {
+				return nil, err
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	binary.BigEndian.PutUint32(size, 0)
+	if _, err := conn.Write(size); err != nil {
+		return nil, err
+	}
+	reply, err := bufio.NewReader(conn).ReadString('\x00')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return parseReply(strings.TrimRight(reply, "\x00\n"))
+}
+
+// parseReply parses replies like "stream: OK" or "stream: Eicar-Signature FOUND"
+func parseReply(reply string) (*Result, error) {
+	_, verdict, _ := strings.Cut(reply, ": ")
+	switch {
+	case verdict == "OK":
+		return &Result{}, nil
+	case strings.HasSuffix(verdict, " FOUND"):
+		return &Result{Infected: true, Signature: strings.TrimSuffix(verdict, " FOUND")}, nil
+	default:
+		return nil, fmt.Errorf("unexpected clamd reply: %q", reply)
+	}
+}
+
+// dial connects to addresses like unix:/run/clamav/clamd.ctl, tcp:127.0.0.1:3310 or 127.0.0.1:3310
+func dial(address string) (net.Conn, error) {
+	network := "tcp"
+	if strings.HasPrefix(address, "unix:") {
+		network = "unix"
+		address = strings.TrimPrefix(address, "unix:")
+	} else {
+		address = strings.TrimPrefix(address, "tcp:")
+	}
+	return net.DialTimeout(network, address, 10*time.Second)
+}
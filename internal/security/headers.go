@@ -0,0 +1,104 @@
+// Package security sets the security headers of the web server: the Content-Security-Policy
+// of the pages, the frames they may be embedded in, the Referrer-Policy, and the CORS
+// headers that let web players on other origins stream the files.
+package security
+
+import (
+	"EverythingSuckz/fsb/config"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultPolicy allows the scripts the pages load from CDNs, the blob URLs hls.js plays from and
+// the posters and covers of TMDB and the Cover Art Archive. frame-ancestors is added per page.
+const defaultPolicy = "default-src 'self'; " +
+	"script-src 'self' https://telegram.org https://cdn.jsdelivr.net; " +
+	"style-src 'self' 'unsafe-inline'; " +
+	"img-src 'self' data: blob: https://image.tmdb.org https://coverartarchive.org https://archive.org https://*.archive.org; " +
+	"media-src 'self' blob:; " +
+	"worker-src 'self' blob:; " +
+	"connect-src 'self' ws: wss:; " +
+	"object-src 'none'; " +
+	"base-uri 'self'"
+
+// telegramOrigins embed the pages opened as a Mini App in Telegram Web
+var telegramOrigins = []string{"https://web.telegram.org"}
+
+// miniAppPaths are opened as a Mini App from the menu button and the link replies
+var miniAppPaths = []string{"/app", "/webapp/", "/player/"}
+
+// Middleware sets the security headers of every response and answers CORS preflight requests
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.Writer.Header()
+		path := strings.TrimPrefix(c.Request.URL.Path, config.ValueOf.BasePath)
+		header.Set("X-Content-Type-Options", "nosniff")
+		if policy := config.ValueOf.ReferrerPolicy; policy != "" {
+			header.Set("Referrer-Policy", policy)
+		}
+		ancestors := frameAncestors(path)
+		if policy := contentSecurityPolicy(); policy != "" {
+			header.Set("Content-Security-Policy", policy+"; frame-ancestors "+strings.Join(ancestors, " "))
+		}
+		// X-Frame-Options can't list origins, browsers that support CSP ignore it anyway
+		if len(ancestors) == 1 {
+			header.Set("X-Frame-Options", "SAMEORIGIN")
+		}
+		if origin := c.GetHeader("Origin"); origin != "" && allowedOrigin(origin) {
+			header.Add("Vary", "Origin")
+			header.Set("Access-Control-Allow-Origin", origin)
+			header.Set("Access-Control-Expose-Headers", "Content-Length, Content-Range, Accept-Ranges")
+			if c.Request.Method == http.MethodOptions {
+				header.Set("Access-Control-Allow-Methods", "GET, HEAD, OPTIONS")
+				header.Set("Access-Control-Allow-Headers", "Range")
+				header.Set("Access-Control-Max-Age", "86400")
+				c.AbortWithStatus(http.StatusNoContent)
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// contentSecurityPolicy returns CONTENT_SECURITY_POLICY, the default policy if it's empty
+// or an empty string if it's off
+func contentSecurityPolicy() string {
+	policy := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(config.ValueOf.ContentPolicy), ";"))
+	switch policy {
+	case "":
+		return defaultPolicy
+	case "off":
+		return ""
+	}
+	return policy
+}
+
+// frameAncestors returns the origins the page may be embedded by: the server itself, the
+// origins of FRAME_ANCESTORS, Telegram Web for Mini App pages and EMBED_ORIGINS for the
+// embedded player
+func frameAncestors(path string) []string {
+	if strings.HasPrefix(path, "/embed/") {
+		if slices.Contains(config.ValueOf.EmbedOrigins, "*") {
+			return []string{"*"}
+		}
+		return append([]string{"'self'"}, config.ValueOf.EmbedOrigins...)
+	}
+	ancestors := []string{"'self'"}
+	ancestors = append(ancestors, config.ValueOf.FrameAncestors...)
+	for _, prefix := range miniAppPaths {
+		if path == strings.TrimSuffix(prefix, "/") || strings.HasPrefix(path, prefix) {
+			ancestors = append(ancestors, telegramOrigins...)
+			break
+		}
+	}
+	return ancestors
+}
+
+// allowedOrigin reports whether the origin is listed in CORS_ORIGINS
+func allowedOrigin(origin string) bool {
+	origins := config.ValueOf.CORSOrigins
+	return slices.Contains(origins, "*") || slices.Contains(origins, strings.TrimSuffix(origin, "/"))
+}
@@ -0,0 +1,8 @@
+//go:build !windows
+
+package service
+
+import "go.uber.org/zap"
+
+// Attach does nothing outside of Windows, systemd and launchd run the bot like any other process
+func Attach(log *zap.Logger, dir string) {}
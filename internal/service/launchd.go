@@ -0,0 +1,81 @@
+//go:build darwin
+
+package service
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// label identifies the daemon in launchd
+const label = "com.everythingsuckz." + Name
+
+// plistPath is where the launchd daemon is installed
+const plistPath = "/Library/LaunchDaemons/" + label + ".plist"
+
+// Plist returns the launchd property list of the service
+func Plist(s *Service) string {
+	var sb strings.Builder
+	sb.WriteString(xml.Header)
+	sb.WriteString(`<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">` + "\n")
+	sb.WriteString("<plist version=\"1.0\">\n<dict>\n")
+	plistString(&sb, "Label", label)
+	sb.WriteString("\t<key>ProgramArguments</key>\n\t<array>\n")
+	for _, arg := range append([]string{s.Executable}, s.Args...) {
+		sb.WriteString("\t\t<string>" + escapeXML(arg) + "</string>\n")
+	}
+	sb.WriteString("\t</array>\n")
+	plistString(&sb, "WorkingDirectory", s.WorkingDir)
+	if s.User != "" {
+		plistString(&sb, "UserName", s.User)
+	}
+	sb.WriteString("\t<key>RunAtLoad</key>\n\t<true/>\n")
+	sb.WriteString("\t<key>KeepAlive</key>\n\t<dict>\n\t\t<key>SuccessfulExit</key>\n\t\t<false/>\n\t</dict>\n")
+	plistString(&sb, "StandardOutPath", s.WorkingDir+"/fsb.out.log")
+	plistString(&sb, "StandardErrorPath", s.WorkingDir+"/fsb.err.log")
+	sb.WriteString("</dict>\n</plist>\n")
+	return sb.String()
+}
+
+func plistString(sb *strings.Builder, key string, value string) {
+	sb.WriteString("\t<key>" + key + "</key>\n\t<string>" + escapeXML(value) + "</string>\n")
+}
+
+func escapeXML(value string) string {
+	var sb strings.Builder
+	xml.EscapeText(&sb, []byte(value))
+	return sb.String()
+}
+
+// Install writes the launchd daemon, which starts on boot. It returns the path of the property list.
+func Install(s *Service) (string, error) {
+	if _, err := os.Stat(plistPath); err == nil {
+		return "", fmt.Errorf("%s already exists, uninstall the service first", plistPath)
+	}
+	return plistPath, os.WriteFile(plistPath, []byte(Plist(s)), 0644)
+}
+
+// Uninstall stops the daemon and removes its property list
+func Uninstall() error {
+	Stop()
+	return os.Remove(plistPath)
+}
+
+func Start() error {
+	return launchctl("load", "-w", plistPath)
+}
+
+func Stop() error {
+	return launchctl("unload", plistPath)
+}
+
+func launchctl(args ...string) error {
+	output, err := exec.Command("launchctl", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("launchctl %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+// Package service installs the bot as a background service managed by the operating system:
+// a systemd unit on Linux, a launchd daemon on macOS and a service of the Service Control
+// Manager on Windows. The service runs `fsb run` in the directory it was installed from, so
+// that it finds fsb.env and the database there.
+package service
+
+import (
+	"os"
+	"path/filepath"
+)
+
+const (
+	// Name is the name of the service in the service manager
+	Name = "fsb"
+	// Description is shown by the service manager
+	Description = "Telegram File Stream Bot"
+)
+
+// Service describes the installed service
+type Service struct {
+	Executable string   // absolute path of the fsb binary
+	WorkingDir string   // directory with fsb.env
+	Args       []string // arguments of the executable, `run` and its flags
+	User       string   // account the service runs as, empty for the default one of the platform
+}
+
+// Current describes the service running this executable from the working directory
+func Current(args []string, user string) (*Service, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	executable, err = filepath.EvalSymlinks(executable)
+	if err != nil {
+		return nil, err
+	}
+	workingDir, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	return &Service{
+		Executable: executable,
+		WorkingDir: workingDir,
+		Args:       append([]string{"run"}, args...),
+		User:       user,
+	}, nil
+}
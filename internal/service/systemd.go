@@ -0,0 +1,85 @@
+//go:build linux
+
+package service
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// unitPath is where the systemd unit is installed
+const unitPath = "/etc/systemd/system/" + Name + ".service"
+
+// Unit returns the systemd unit of the service
+func Unit(s *Service) string {
+	var sb strings.Builder
+	sb.WriteString("[Unit]\n")
+	sb.WriteString("Description=" + Description + "\n")
+	sb.WriteString("Wants=network-online.target\n")
+	sb.WriteString("After=network-online.target\n\n")
+	sb.WriteString("[Service]\n")
+	sb.WriteString("Type=simple\n")
+	if s.User != "" {
+		sb.WriteString("User=" + s.User + "\n")
+	}
+	sb.WriteString("WorkingDirectory=" + quoteUnit(s.WorkingDir) + "\n")
+	command := []string{quoteUnit(s.Executable)}
+	for _, arg := range s.Args {
+		command = append(command, quoteUnit(arg))
+	}
+	sb.WriteString("ExecStart=" + strings.Join(command, " ") + "\n")
+	sb.WriteString("Restart=on-failure\n")
+	sb.WriteString("RestartSec=5\n\n")
+	sb.WriteString("[Install]\n")
+	sb.WriteString("WantedBy=multi-user.target\n")
+	return sb.String()
+}
+
+// Install writes the systemd unit and enables it, so that it starts on boot. It returns the path of the unit.
+func Install(s *Service) (string, error) {
+	if _, err := os.Stat(unitPath); err == nil {
+		return "", fmt.Errorf("%s already exists, uninstall the service first", unitPath)
+	}
+	if err := os.WriteFile(unitPath, []byte(Unit(s)), 0644); err != nil {
+		return "", err
+	}
+	if err := systemctl("daemon-reload"); err != nil {
+		return "", err
+	}
+	return unitPath, systemctl("enable", Name)
+}
+
+// Uninstall stops and disables the service and removes its unit
+func Uninstall() error {
+	systemctl("disable", "--now", Name)
+	if err := os.Remove(unitPath); err != nil {
+		return err
+	}
+	return systemctl("daemon-reload")
+}
+
+func Start() error {
+	return systemctl("start", Name)
+}
+
+func Stop() error {
+	return systemctl("stop", Name)
+}
+
+func systemctl(args ...string) error {
+	output, err := exec.Command("systemctl", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("systemctl %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// quoteUnit quotes a value of the unit if it has spaces, quotes or backslashes
+func quoteUnit(value string) string {
+	if !strings.ContainsAny(value, " \t\"'\\") {
+		return value
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
@@ -0,0 +1,26 @@
+//go:build !linux && !darwin && !windows
+
+package service
+
+import (
+	"errors"
+	"runtime"
+)
+
+var errUnsupported = errors.New("services are not supported on " + runtime.GOOS)
+
+func Install(s *Service) (string, error) {
+	return "", errUnsupported
+}
+
+func Uninstall() error {
+	return errUnsupported
+}
+
+func Start() error {
+	return errUnsupported
+}
+
+func Stop() error {
+	return errUnsupported
+}
@@ -0,0 +1,121 @@
+//go:build windows
+
+package service
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// Install creates the service in the Service Control Manager, which starts it on boot.
+// It returns the name of the service.
+func Install(s *Service) (string, error) {
+	m, err := mgr.Connect()
+	if err != nil {
+		return "", err
+	}
+	defer m.Disconnect()
+	if existing, err := m.OpenService(Name); err == nil {
+		existing.Close()
+		return "", fmt.Errorf("service %s already exists, uninstall it first", Name)
+	}
+	// the working directory of services is System32, --service-dir tells the service where fsb.env is
+	args := append([]string{}, s.Args...)
+	args = append(args, "--service-dir", s.WorkingDir)
+	service, err := m.CreateService(Name, s.Executable, mgr.Config{
+		DisplayName:      Description,
+		Description:      Description,
+		StartType:        mgr.StartAutomatic,
+		ServiceStartName: s.User,
+	}, args...)
+	if err != nil {
+		return "", err
+	}
+	defer service.Close()
+	err = service.SetRecoveryActions([]mgr.RecoveryAction{
+		{Type: mgr.ServiceRestart, Delay: 5 * time.Second},
+	}, uint32((24 * time.Hour).Seconds()))
+	return Name, err
+}
+
+// Uninstall stops the service and removes it from the Service Control Manager
+func Uninstall() error {
+	Stop()
+	return withService(func(service *mgr.Service) error {
+		return service.Delete()
+	})
+}
+
+func Start() error {
+	return withService(func(service *mgr.Service) error {
+		return service.Start()
+	})
+}
+
+func Stop() error {
+	return withService(func(service *mgr.Service) error {
+		_, err := service.Control(svc.Stop)
+		return err
+	})
+}
+
+func withService(run func(service *mgr.Service) error) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+	service, err := m.OpenService(Name)
+	if err != nil {
+		return fmt.Errorf("service %s is not installed: %w", Name, err)
+	}
+	defer service.Close()
+	return run(service)
+}
+
+// Attach reports to the Service Control Manager when the bot runs as a service, and
+// exits when the service is stopped. It moves to dir first, the directory the service
+// was installed from.
+func Attach(log *zap.Logger, dir string) {
+	isService, err := svc.IsWindowsService()
+	if err != nil || !isService {
+		return
+	}
+	if dir != "" {
+		if err := os.Chdir(dir); err != nil {
+			log.Error("Failed to change to the service directory", zap.Error(err))
+		}
+	} else if executable, err := os.Executable(); err == nil {
+		os.Chdir(filepath.Dir(executable))
+	}
+	go func() {
+		if err := svc.Run(Name, handler{log}); err != nil {
+			log.Error("Failed to run as a service", zap.Error(err))
+		}
+	}()
+}
+
+type handler struct {
+	log *zap.Logger
+}
+
+func (h handler) Execute(args []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	status <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+	for request := range requests {
+		switch request.Cmd {
+		case svc.Interrogate:
+			status <- request.CurrentStatus
+		case svc.Stop, svc.Shutdown:
+			h.log.Info("Stopping the service")
+			status <- svc.Status{State: svc.StopPending}
+			os.Exit(0)
+		}
+	}
+	return false, 0
+}
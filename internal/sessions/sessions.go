@@ -0,0 +1,134 @@
+// Package sessions keeps track of the streams and web player connections of the links of every
+// user, so that they can be listed with /sessions and terminated, e.g. when the user revokes their links.
+// Web players of the user can also be remote controlled from the chat with /remote.
+package sessions
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Kinds of sessions
+const (
+	KindStream = "stream"
+	KindPlayer = "player"
+)
+
+// Session is a stream or a web player connection of a link
+type Session struct {
+	ID        uint64
+	UserID    int64 // owner of the link
+	Kind      string
+	IP        string
+	UserAgent string
+	Since     time.Time
+	cancel    func()
+	controls  chan string // remote control actions, nil unless the session accepts them
+}
+
+// controlBuffer is the number of remote control actions a player can fall behind by
+const controlBuffer = 8
+
+var (
+	mu     sync.Mutex
+	nextID uint64
+	byUser = make(map[int64]map[uint64]*Session)
+)
+
+// Start records a session of a link of the user. cancel is called to terminate it.
+func Start(userID int64, kind string, ip string, userAgent string, cancel func()) *Session {
+	mu.Lock()
+	defer mu.Unlock()
+	nextID++
+	session := &Session{
+		ID:        nextID,
+		UserID:    userID,
+		Kind:      kind,
+		IP:        ip,
+		UserAgent: userAgent,
+		Since:     time.Now(),
+		cancel:    cancel,
+	}
+	if byUser[userID] == nil {
+		byUser[userID] = make(map[uint64]*Session)
+	}
+	byUser[userID][session.ID] = session
+	return session
+}
+
+// End removes the session once it's over
+func (s *Session) End() {
+	mu.Lock()
+	defer mu.Unlock()
+	delete(byUser[s.UserID], s.ID)
+	if len(byUser[s.UserID]) == 0 {
+		delete(byUser, s.UserID)
+	}
+}
+
+// TerminateAll terminates the sessions of all links of the user and returns their number
+func TerminateAll(userID int64) int {
+	mu.Lock()
+	sessions := byUser[userID]
+	delete(byUser, userID)
+	mu.Unlock()
+	for _, session := range sessions {
+		session.cancel()
+	}
+	return len(sessions)
+}
+
+// List returns the sessions of the links of the user, oldest first
+func List(userID int64) []Session {
+	mu.Lock()
+	defer mu.Unlock()
+	list := make([]Session, 0, len(byUser[userID]))
+	for _, session := range byUser[userID] {
+		list = append(list, *session)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+// Terminate terminates a session of the links of the user and reports whether it was running
+func Terminate(userID int64, id uint64) bool {
+	mu.Lock()
+	session, ok := byUser[userID][id]
+	mu.Unlock()
+	if !ok {
+		return false
+	}
+	session.End()
+	session.cancel()
+	return true
+}
+
+// EnableControls lets the user remote control the session and returns the channel of the actions sent to it
+func (s *Session) EnableControls() <-chan string {
+	mu.Lock()
+	defer mu.Unlock()
+	if s.controls == nil {
+		s.controls = make(chan string, controlBuffer)
+	}
+	return s.controls
+}
+
+// Control sends a remote control action to the sessions of the user that accept them and returns
+// how many received it. Sessions that fell behind by too many actions skip it.
+func Control(userID int64, action string) int {
+	mu.Lock()
+	defer mu.Unlock()
+	sent := 0
+	for _, session := range byUser[userID] {
+		if session.controls == nil {
+			continue
+		}
+		select {
+		case session.controls <- action:
+			sent++
+		default:
+		}
+	}
+	return sent
+}
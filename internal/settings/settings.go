@@ -0,0 +1,339 @@
+// Package settings stores values changed at runtime, with /setup or /set, in the database and
+// applies them over the static config through config.Runtime. A stored setting takes precedence over the environment
+// variable it overrides, which takes precedence over the built-in default.
+package settings
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/utils"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// Owner is the user who ran /setup first, they are a bot admin
+	Owner = "owner"
+	// Host overrides HOST
+	Host = "host"
+	// LogChannel overrides LOG_CHANNEL
+	LogChannel = "log_channel"
+	// PrivateMode overrides PRIVATE_MODE
+	PrivateMode = "private_mode"
+	// LinkRateLimit overrides LINK_RATE_LIMIT
+	LinkRateLimit = "link_rate_limit"
+	// LinkTTL overrides LINK_TTL_HOURS
+	LinkTTL = "link_ttl_hours"
+	// MaxFileSize overrides MAX_FILE_SIZE
+	MaxFileSize = "max_file_size"
+	// FlagLinksPerHour overrides FLAG_LINKS_PER_HOUR
+	FlagLinksPerHour = "flag_links_per_hour"
+	// SetupCompleted records when /setup was completed
+	SetupCompleted = "setup_completed"
+	// NotifiedVersion is the last new version the admins were told about
+	NotifiedVersion = "notified_version"
+	// UpdateState is the last update state of the bot, to recover the updates missed while it was offline
+	UpdateState = "update_state"
+)
+
+// Kind is the type of the value of a setting
+type Kind string
+
+const (
+	KindInt    Kind = "integer"
+	KindBool   Kind = "boolean"
+	KindString Kind = "text"
+	KindSize   Kind = "size"
+)
+
+// Definition describes a setting
+type Definition struct {
+	Key         string
+	Env         string // the variable the setting overrides, empty for internal settings
+	Kind        Kind
+	Description string
+	Editable    bool // whether admins can change it with /set
+	apply       func(value string) error
+	current     func() string
+}
+
+// Current returns the value in effect, formatted for humans
+func (d *Definition) Current() string {
+	if d.current == nil {
+		value, _ := Get(d.Key)
+		return value
+	}
+	return Format(d.Kind, d.current())
+}
+
+var definitions = []*Definition{
+	{
+		Key:         Host,
+		Env:         "HOST",
+		Kind:        KindString,
+		Description: "Public URL of the bot",
+		Editable:    true,
+		apply: func(value string) error {
+			if !strings.HasPrefix(value, "http://") && !strings.HasPrefix(value, "https://") {
+				return fmt.Errorf("invalid URL: %s", value)
+			}
+			config.Runtime.SetHost(strings.TrimSuffix(value, "/"))
+			return nil
+		},
+		current: func() string { return config.Runtime.Host() },
+	},
+	{
+		Key:         LogChannel,
+		Env:         "LOG_CHANNEL",
+		Kind:        KindInt,
+		Description: "Channel the files are stored in",
+		Editable:    true,
+		apply: func(value string) error {
+			id, err := strconv.ParseInt(strings.TrimPrefix(value, "-100"), 10, 64)
+			if err != nil || id <= 0 {
+				return fmt.Errorf("invalid channel ID: %s", value)
+			}
+			config.Runtime.SetLogChannelID(id)
+			return nil
+		},
+		current: func() string { return strconv.FormatInt(config.Runtime.LogChannelID(), 10) },
+	},
+	{
+		Key:         PrivateMode,
+		Env:         "PRIVATE_MODE",
+		Kind:        KindBool,
+		Description: "Only allowed users can use the bot",
+		Editable:    true,
+		apply: func(value string) error {
+			private, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("invalid boolean: %s", value)
+			}
+			config.Runtime.SetPrivateMode(private)
+			return nil
+		},
+		current: func() string { return strconv.FormatBool(config.Runtime.PrivateMode()) },
+	},
+	{
+		Key:         LinkRateLimit,
+		Env:         "LINK_RATE_LIMIT",
+		Kind:        KindInt,
+		Description: "Links a user can generate per minute, 0 for unlimited",
+		Editable:    true,
+		apply:       applyInt(config.Runtime.SetLinkRateLimit),
+		current:     func() string { return strconv.Itoa(config.Runtime.LinkRateLimit()) },
+	},
+	{
+		Key:         LinkTTL,
+		Env:         "LINK_TTL_HOURS",
+		Kind:        KindInt,
+		Description: "Hours a link works after it was generated, 0 for forever",
+		Editable:    true,
+		apply:       applyInt(config.Runtime.SetLinkTTLHours),
+		current:     func() string { return strconv.Itoa(config.Runtime.LinkTTLHours()) },
+	},
+	{
+		Key:         MaxFileSize,
+		Env:         "MAX_FILE_SIZE",
+		Kind:        KindSize,
+		Description: "Largest file links are generated for, 0 for unlimited",
+		Editable:    true,
+		apply: func(value string) error {
+			size, err := config.ParseByteSize(value)
+			if err != nil {
+				return fmt.Errorf("invalid size: %s", value)
+			}
+			config.Runtime.SetMaxFileSize(size)
+			return nil
+		},
+		current: func() string { return strconv.FormatInt(config.Runtime.MaxFileSize(), 10) },
+	},
+	{
+		Key:         FlagLinksPerHour,
+		Env:         "FLAG_LINKS_PER_HOUR",
+		Kind:        KindInt,
+		Description: "Links per hour after which a user is flagged, 0 to disable",
+		Editable:    true,
+		apply:       applyInt(config.Runtime.SetFlagLinksPerHour),
+		current:     func() string { return strconv.Itoa(config.Runtime.FlagLinksPerHour()) },
+	},
+	{
+		Key:         Owner,
+		Kind:        KindInt,
+		Description: "User who claimed the bot with /setup",
+		apply: func(value string) error {
+			id, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid user ID: %s", value)
+			}
+			config.Runtime.AddAdmin(id)
+			return nil
+		},
+	},
+	{
+		Key:         SetupCompleted,
+		Kind:        KindString,
+		Description: "When /setup was completed",
+		apply:       func(string) error { return nil },
+	},
+	{
+		Key:         NotifiedVersion,
+		Kind:        KindString,
+		Description: "Last new version the admins were told about",
+		apply:       func(string) error { return nil },
+	},
+	{
+		Key:         UpdateState,
+		Kind:        KindString,
+		Description: "Last update state of the bot",
+		apply:       func(string) error { return nil },
+	},
+}
+
+// applyInt returns an applier of a non-negative integer runtime value
+func applyInt(set func(int)) func(value string) error {
+	return func(value string) error {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid number: %s", value)
+		}
+		set(n)
+		return nil
+	}
+}
+
+// Format formats the value of a setting for humans
+func Format(kind Kind, value string) string {
+	if kind != KindSize {
+		return value
+	}
+	size, err := config.ParseByteSize(value)
+	if err != nil || size == 0 {
+		return value
+	}
+	return utils.FormatFileSizeShort(size)
+}
+
+var values = struct {
+	sync.RWMutex
+	byKey    map[string]string
+	defaults map[string]string // the values of the static config
+}{byKey: make(map[string]string), defaults: make(map[string]string)}
+
+// Load reads the settings from the database and applies them over the config
+func Load(log *zap.Logger) error {
+	log = log.Named("settings")
+	values.Lock()
+	defer values.Unlock()
+	for _, definition := range definitions {
+		if definition.current != nil {
+			values.defaults[definition.Key] = definition.current()
+		}
+	}
+	settingRepository := database.GetSettingRepository()
+	if settingRepository == nil {
+		return nil
+	}
+	list, err := settingRepository.List()
+	if err != nil {
+		return err
+	}
+	for _, setting := range list {
+		definition := Lookup(setting.Key)
+		if definition == nil {
+			log.Sugar().Warnf("Ignoring unknown setting %q", setting.Key)
+			continue
+		}
+		if err := definition.apply(setting.Value); err != nil {
+			log.Sugar().Warnf("Ignoring setting %q: %s", setting.Key, err)
+			continue
+		}
+		values.byKey[setting.Key] = setting.Value
+	}
+	if len(values.byKey) > 0 {
+		log.Sugar().Infof("Applied %d settings over the config", len(values.byKey))
+	}
+	return nil
+}
+
+// Definitions returns the settings admins can change with /set
+func Definitions() []*Definition {
+	var editable []*Definition
+	for _, definition := range definitions {
+		if definition.Editable {
+			editable = append(editable, definition)
+		}
+	}
+	return editable
+}
+
+// Lookup returns the definition of the setting, or nil if there is none
+func Lookup(key string) *Definition {
+	for _, definition := range definitions {
+		if definition.Key == key {
+			return definition
+		}
+	}
+	return nil
+}
+
+// Get returns the stored value of the setting, or false if it's not set
+func Get(key string) (string, bool) {
+	values.RLock()
+	defer values.RUnlock()
+	value, ok := values.byKey[key]
+	return value, ok
+}
+
+// Default returns the value of the setting in the static config
+func Default(key string) string {
+	values.RLock()
+	defer values.RUnlock()
+	return values.defaults[key]
+}
+
+// Set validates and applies the value of the setting and stores it
+func Set(key string, value string, updatedBy int64) error {
+	definition := Lookup(key)
+	if definition == nil {
+		return fmt.Errorf("unknown setting: %s", key)
+	}
+	settingRepository := database.GetSettingRepository()
+	if settingRepository == nil {
+		return errors.New("settings database is not available at the moment")
+	}
+	values.Lock()
+	defer values.Unlock()
+	if err := definition.apply(value); err != nil {
+		return err
+	}
+	if err := settingRepository.Set(key, value, updatedBy); err != nil {
+		return err
+	}
+	values.byKey[key] = value
+	return nil
+}
+
+// Reset removes the stored value of the setting, so the static config applies again
+func Reset(key string) error {
+	definition := Lookup(key)
+	if definition == nil || definition.current == nil {
+		return fmt.Errorf("unknown setting: %s", key)
+	}
+	settingRepository := database.GetSettingRepository()
+	if settingRepository == nil {
+		return errors.New("settings database is not available at the moment")
+	}
+	values.Lock()
+	defer values.Unlock()
+	if err := settingRepository.Delete(key); err != nil {
+		return err
+	}
+	delete(values.byKey, key)
+	return definition.apply(values.defaults[key])
+}
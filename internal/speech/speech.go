@@ -0,0 +1,68 @@
+// Package speech transcribes voice notes with a speech to text API compatible with the
+// OpenAI transcriptions endpoint, like Whisper or a self hosted whisper.cpp server
+package speech
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/version"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Enabled reports whether SPEECH_TO_TEXT_URL is set
+func Enabled() bool {
+	return config.ValueOf.SpeechToTextURL != ""
+}
+
+// Transcribe sends the audio to SPEECH_TO_TEXT_URL and returns the recognized text
+func Transcribe(ctx context.Context, fileName string, audio []byte) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	if err := form.WriteField("model", config.ValueOf.SpeechToTextModel); err != nil {
+		return "", err
+	}
+	part, err := form.CreateFormFile("file", fileName)
+	if err != nil {
+		return "", err
+	}
+	if _, err := part.Write(audio); err != nil {
+		return "", err
+	}
+	if err := form.Close(); err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, config.ValueOf.SpeechToTextURL, &body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("User-Agent", "fsb/"+version.Version)
+	if config.ValueOf.SpeechToTextKey != "" {
+		req.Header.Set("Authorization", "Bearer "+config.ValueOf.SpeechToTextKey)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 200))
+		return "", fmt.Errorf("speech to text answered with %s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+	var transcription struct {
+		Text string `json:"text"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&transcription); err != nil {
+		return "", fmt.Errorf("invalid speech to text response: %w", err)
+	}
+	return strings.TrimSpace(transcription.Text), nil
+}
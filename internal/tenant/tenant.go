@@ -0,0 +1,193 @@
+package tenant
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/types"
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// registry keeps the tenants in memory, they are looked up on every request
+type registry struct {
+	mu     sync.RWMutex
+	byID   map[uint]*types.Tenant
+	byPath map[string]*types.Tenant
+	// reserved holds the first path segments of the global routes, they never select a tenant
+	reserved map[string]bool
+}
+
+var tenants = &registry{
+	byID:     make(map[uint]*types.Tenant),
+	byPath:   make(map[string]*types.Tenant),
+	reserved: make(map[string]bool),
+}
+
+type contextKey struct{}
+
+// Load reads the tenants from the database
+func Load(log *zap.Logger) error {
+	log = log.Named("tenant")
+	if err := Reload(); err != nil {
+		return err
+	}
+	tenants.mu.RLock()
+	defer tenants.mu.RUnlock()
+	if len(tenants.byID) > 0 {
+		log.Sugar().Infof("Loaded %d tenants", len(tenants.byID))
+	}
+	for path, tenant := range tenants.byPath {
+		if tenants.reserved[path] {
+			log.Warn("Tenant path is used by a global route, the tenant can't be reached", zap.Uint("tenantID", tenant.ID), zap.String("path", path))
+		}
+	}
+	return nil
+}
+
+// Reload reads the tenants from the database again, after one was added or changed
+func Reload() error {
+	tenantRepository := database.GetTenantRepository()
+	if tenantRepository == nil {
+		return nil
+	}
+	list, err := tenantRepository.List()
+	if err != nil {
+		return err
+	}
+	byID := make(map[uint]*types.Tenant, len(list))
+	byPath := make(map[string]*types.Tenant, len(list))
+	for i := range list {
+		byID[list[i].ID] = &list[i]
+		byPath[list[i].Path] = &list[i]
+	}
+	tenants.mu.Lock()
+	tenants.byID, tenants.byPath = byID, byPath
+	tenants.mu.Unlock()
+	return nil
+}
+
+// Get returns the tenant with the given ID, or nil for the default tenant
+func Get(id uint) *types.Tenant {
+	tenants.mu.RLock()
+	defer tenants.mu.RUnlock()
+	return tenants.byID[id]
+}
+
+// AdminOf returns a tenant administered by the user, or nil if they administer none
+func AdminOf(userID int64) *types.Tenant {
+	tenants.mu.RLock()
+	defer tenants.mu.RUnlock()
+	for _, tenant := range tenants.byID {
+		if tenant.IsAdmin(userID) {
+			return tenant
+		}
+	}
+	return nil
+}
+
+// Path returns the URL path prefix of the tenant, empty for the default tenant
+func Path(id uint) string {
+	if tenant := Get(id); tenant != nil {
+		return tenant.Path
+	}
+	return ""
+}
+
+// LogChannel returns the log channel the files of the tenant are forwarded to
+func LogChannel(id uint) int64 {
+	if tenant := Get(id); tenant != nil && tenant.LogChannelID != 0 {
+		return tenant.LogChannelID
+	}
+	return config.Runtime.LogChannelID()
+}
+
+// WithLogChannel returns the IDs of the tenants whose files are stored in the channel,
+// including 0 for the default tenant
+func WithLogChannel(channelID int64) []uint {
+	var ids []uint
+	if config.Runtime.LogChannelID() == channelID {
+		ids = append(ids, 0)
+	}
+	tenants.mu.RLock()
+	defer tenants.mu.RUnlock()
+	for id, tenant := range tenants.byID {
+		if tenant.LogChannelID == channelID || (tenant.LogChannelID == 0 && config.Runtime.LogChannelID() == channelID) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// ReserveRoutes reserves the first segments of the given route paths, after BASE_PATH,
+// so that tenants can't shadow the global routes
+func ReserveRoutes(paths []string) {
+	tenants.mu.Lock()
+	defer tenants.mu.Unlock()
+	for _, path := range paths {
+		rest := strings.TrimPrefix(strings.TrimPrefix(path, config.ValueOf.BasePath), "/")
+		segment, _, _ := strings.Cut(rest, "/")
+		if segment == "" || strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			continue
+		}
+		tenants.reserved["/"+segment] = true
+	}
+}
+
+// IsReserved reports whether a normalized tenant path is the first segment of a global route
+func IsReserved(path string) bool {
+	tenants.mu.RLock()
+	defer tenants.mu.RUnlock()
+	return tenants.reserved[path]
+}
+
+// NormalizePath turns a tenant path like "team-a/" into "/team-a"
+func NormalizePath(path string) string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return ""
+	}
+	return "/" + path
+}
+
+// Handler serves the routes of a tenant under its path: the path prefix is removed
+// from the request and the tenant ID is stored in its context, see FromContext.
+func Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, path := Resolve(r.URL.Path)
+		if id != 0 {
+			r = r.Clone(context.WithValue(r.Context(), contextKey{}, id))
+			r.URL.Path = path
+			r.URL.RawPath = ""
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Resolve returns the ID of the tenant whose path the URL path starts with, after
+// BASE_PATH, and the URL path without the tenant path. The ID is 0 if none matches.
+func Resolve(path string) (uint, string) {
+	basePath := config.ValueOf.BasePath
+	rest, ok := strings.CutPrefix(path, basePath+"/")
+	if !ok {
+		return 0, path
+	}
+	segment, rest, _ := strings.Cut(rest, "/")
+	tenants.mu.RLock()
+	tenant := tenants.byPath["/"+segment]
+	isReserved := tenants.reserved["/"+segment]
+	tenants.mu.RUnlock()
+	if tenant == nil || isReserved {
+		return 0, path
+	}
+	return tenant.ID, basePath + "/" + rest
+}
+
+// FromContext returns the ID of the tenant a request was made to, 0 for the default tenant
+func FromContext(ctx context.Context) uint {
+	id, _ := ctx.Value(contextKey{}).(uint)
+	return id
+}
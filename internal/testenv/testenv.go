@@ -0,0 +1,57 @@
+// Package testenv starts the web server against the in-memory Telegram of tgfake, with its own
+// database, for the tests of the routes and the end-to-end tests. The server shares the config
+// and the repositories of the packages, so the tests using it don't run in parallel.
+package testenv
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/tenant"
+	"EverythingSuckz/fsb/internal/tgfake"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// LogChannelID is the log channel the links of the server are generated in
+const LogChannelID = 1000
+
+// Server is the web server under test and the fake Telegram it talks to
+type Server struct {
+	*httptest.Server
+	Telegram *tgfake.Telegram
+}
+
+// Start resets the runtime settings, opens a new database in the temporary directory of the
+// test and serves the routes added by load, usually routes.Load, until the test ends
+func Start(t *testing.T, load func(router *gin.Engine, telegram *tgfake.Telegram)) *Server {
+	t.Helper()
+	config.Runtime.SetLogChannelID(LogChannelID)
+	config.Runtime.SetLinkTTLHours(0)
+	config.Runtime.SetPrivateMode(false)
+	config.ValueOf.HashLength = 6
+	config.ValueOf.StreamReadAhead = 2
+	if err := database.Open(zap.NewNop(), filepath.Join(t.TempDir(), "fsb_test.db")); err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	// the tenants of the previous test are gone with its database
+	if err := tenant.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	telegram := tgfake.New()
+	load(router, telegram)
+	s := &Server{Server: httptest.NewServer(router), Telegram: telegram}
+	config.Runtime.SetHost(s.URL)
+	t.Cleanup(func() {
+		s.Close()
+		if sqlDB, err := database.DB.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return s
+}
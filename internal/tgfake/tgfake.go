@@ -0,0 +1,508 @@
+// Package tgfake is an in-memory Telegram for running the web server, the streaming path and
+// the bot's command handlers without credentials or a network. It serves the files added to it
+// to upload.getFile requests and records the messages sent to users instead of sending them.
+// Pass it to routes.Load in place of bot.Live, and its Client to the dispatcher of the commands.
+package tgfake
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/types"
+	"EverythingSuckz/fsb/internal/utils"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gotd/td/bin"
+	"github.com/gotd/td/telegram"
+	"github.com/gotd/td/tg"
+)
+
+// Message is a message sent through the fake. Messages the bot edited have their last text.
+// Messages sent to a group have its ChatID instead of a UserID, the message they reply to and
+// the forum topic they were sent to, 0 for the General topic.
+type Message struct {
+	UserID  int64
+	ChatID  int64
+	ID      int
+	Text    string
+	Markup  tg.ReplyMarkupClass
+	ReplyTo int
+	TopicID int
+}
+
+// Pattern is the content of a synthetic file of that many bytes, each byte is its offset modulo 256
+type Pattern int64
+
+func (p Pattern) ReadAt(b []byte, off int64) (int, error) {
+	if off >= int64(p) {
+		return 0, io.EOF
+	}
+	n := min(int64(len(b)), int64(p)-off)
+	for i := int64(0); i < n; i++ {
+		b[i] = byte(off + i)
+	}
+	if n < int64(len(b)) {
+		return int(n), io.EOF
+	}
+	return int(n), nil
+}
+
+type messageKey struct {
+	chatID    int64
+	messageID int
+}
+
+type document struct {
+	file    types.File
+	content io.ReaderAt
+}
+
+// Telegram is the fake, the zero value isn't usable, create it with New
+type Telegram struct {
+	// Latency delays every upload.getFile request, like a far away data center
+	Latency time.Duration
+
+	mu        sync.Mutex
+	messages  map[messageKey]int64
+	documents map[int64]*document
+	deleted   map[messageKey]bool
+	sent      []Message
+	nextID    int64
+	// lastMessage is the ID of the last message of the private chat with the user, or of the group
+	lastMessage map[int64]int
+	// userFiles are the documents of the messages users sent to the bot, by chat and message
+	userFiles map[messageKey]int64
+	requests  atomic.Int64
+}
+
+func New() *Telegram {
+	return &Telegram{
+		messages:    make(map[messageKey]int64),
+		documents:   make(map[int64]*document),
+		deleted:     make(map[messageKey]bool),
+		lastMessage: make(map[int64]int),
+		userFiles:   make(map[messageKey]int64),
+	}
+}
+
+// AddFile posts a file with the content to the message of the channel. The size of the
+// file is the size of the content, which can be a bytes.Reader or a Pattern.
+func (t *Telegram) AddFile(channelID int64, messageID int, fileName string, mimeType string, size int64, content io.ReaderAt) *types.File {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.nextID++
+	file := types.File{
+		Location: &tg.InputDocumentFileLocation{ID: t.nextID},
+		FileSize: size,
+		FileName: fileName,
+		MimeType: mimeType,
+		ID:       t.nextID,
+	}
+	key := messageKey{channelID, messageID}
+	t.messages[key] = file.ID
+	t.documents[file.ID] = &document{file: file, content: content}
+	delete(t.deleted, key)
+	return &file
+}
+
+// Upload posts the content as a file to the next message of the channel
+func (t *Telegram) Upload(ctx context.Context, channelID int64, fileName string, mimeType string, content io.Reader) (int, *types.File, error) {
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return 0, nil, err
+	}
+	messageID := t.nextChannelMessage(channelID)
+	return messageID, t.AddFile(channelID, messageID, fileName, mimeType, int64(len(data)), bytes.NewReader(data)), nil
+}
+
+// nextChannelMessage returns the ID of the next message posted to the channel
+func (t *Telegram) nextChannelMessage(channelID int64) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	messageID := 1
+	for key := range t.messages {
+		if key.chatID == channelID && key.messageID >= messageID {
+			messageID = key.messageID + 1
+		}
+	}
+	return messageID
+}
+
+// DeleteMessage deletes the message of the channel, its file can't be fetched anymore
+func (t *Telegram) DeleteMessage(channelID int64, messageID int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.deleted[messageKey{channelID, messageID}] = true
+}
+
+// DeleteUpload deletes the message like DeleteMessage
+func (t *Telegram) DeleteUpload(ctx context.Context, channelID int64, messageID int) error {
+	t.DeleteMessage(channelID, messageID)
+	return nil
+}
+
+// File returns the file of the message, and a client that downloads it from the fake
+func (t *Telegram) File(ctx context.Context, channelID int64, messageID int) (*types.File, *tg.Client, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	key := messageKey{channelID, messageID}
+	if t.deleted[key] {
+		return nil, nil, utils.ErrMessageDeleted
+	}
+	id, ok := t.messages[key]
+	if !ok {
+		return nil, nil, fmt.Errorf("message %d of channel %d has no file", messageID, channelID)
+	}
+	file := t.documents[id].file
+	return &file, t.API(), nil
+}
+
+// Notify records the message instead of sending it
+func (t *Telegram) Notify(userID int64, message string, markup tg.ReplyMarkupClass) error {
+	t.notify(userID, message, markup)
+	return nil
+}
+
+func (t *Telegram) notify(userID int64, message string, markup tg.ReplyMarkupClass) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.lastMessage[userID]++
+	t.sent = append(t.sent, Message{UserID: userID, ID: t.lastMessage[userID], Text: message, Markup: markup})
+	return t.lastMessage[userID]
+}
+
+// IsAdmin reports whether the user is listed in ADMINS, the fake has no ADMIN_CHAT
+func (t *Telegram) IsAdmin(ctx context.Context, userID int64) bool {
+	return utils.Contains(config.Runtime.Admins(), userID)
+}
+
+// Sent returns the messages sent so far, oldest first
+func (t *Telegram) Sent() []Message {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]Message(nil), t.sent...)
+}
+
+// Requests returns the number of upload.getFile requests served
+func (t *Telegram) Requests() int64 {
+	return t.requests.Load()
+}
+
+// API returns a client of the fake
+func (t *Telegram) API() *tg.Client {
+	return tg.NewClient(t)
+}
+
+// Client returns a Telegram client whose requests are answered by the fake, for initializing
+// the dispatcher of the bot. It must not be run, it never connects.
+func (t *Telegram) Client() *telegram.Client {
+	return telegram.NewClient(1, "tgfake", telegram.Options{
+		Middlewares: []telegram.Middleware{
+			telegram.MiddlewareFunc(func(tg.Invoker) telegram.InvokeFunc { return t.Invoke }),
+		},
+	})
+}
+
+// SendText returns the update of the user sending the text to the bot, for the dispatcher
+func (t *Telegram) SendText(userID int64, text string) *tg.Updates {
+	return t.userMessage(userID, &tg.Message{Message: text})
+}
+
+// SendFile returns the update of the user sending a file with the content to the bot, like
+// SendText. The file can be fetched through the fake once the bot forwarded it to a channel.
+func (t *Telegram) SendFile(userID int64, fileName string, mimeType string, size int64, content io.ReaderAt) *tg.Updates {
+	id, media := t.document(fileName, mimeType, size, content)
+	update := t.userMessage(userID, &tg.Message{Media: media})
+	t.addUserFile(userID, update, id)
+	return update
+}
+
+// SendGroupText returns the update of the user sending the text to the forum topic of a group
+// the bot is a member of, topic 1 is the General topic. The group is a forum supergroup.
+func (t *Telegram) SendGroupText(chatID int64, topicID int, userID int64, text string) *tg.Updates {
+	return t.groupMessage(chatID, topicID, userID, &tg.Message{Message: text})
+}
+
+// SendGroupFile returns the update of the user sending a file to the forum topic of a group,
+// like SendGroupText and SendFile
+func (t *Telegram) SendGroupFile(chatID int64, topicID int, userID int64, fileName string, mimeType string, size int64, content io.ReaderAt) *tg.Updates {
+	id, media := t.document(fileName, mimeType, size, content)
+	update := t.groupMessage(chatID, topicID, userID, &tg.Message{Media: media})
+	t.addUserFile(chatID, update, id)
+	return update
+}
+
+// document adds a document with the content, that can be sent in a message
+func (t *Telegram) document(fileName string, mimeType string, size int64, content io.ReaderAt) (int64, *tg.MessageMediaDocument) {
+	t.mu.Lock()
+	t.nextID++
+	id := t.nextID
+	t.documents[id] = &document{
+		file: types.File{
+			Location: &tg.InputDocumentFileLocation{ID: id},
+			FileSize: size,
+			FileName: fileName,
+			MimeType: mimeType,
+			ID:       id,
+		},
+		content: content,
+	}
+	t.mu.Unlock()
+	return id, &tg.MessageMediaDocument{
+		Document: &tg.Document{
+			ID:         id,
+			MimeType:   mimeType,
+			Size:       size,
+			Attributes: []tg.DocumentAttributeClass{&tg.DocumentAttributeFilename{FileName: fileName}},
+		},
+	}
+}
+
+// addUserFile remembers the document of the message of the update, so that it can be forwarded
+func (t *Telegram) addUserFile(chatID int64, update *tg.Updates, id int64) {
+	var messageID int
+	switch u := update.Updates[0].(type) {
+	case *tg.UpdateNewMessage:
+		messageID = u.Message.(*tg.Message).ID
+	case *tg.UpdateNewChannelMessage:
+		messageID = u.Message.(*tg.Message).ID
+	}
+	t.mu.Lock()
+	t.userFiles[messageKey{chatID, messageID}] = id
+	t.mu.Unlock()
+}
+
+// PressButton returns the update of the user pressing the callback button of the message
+func (t *Telegram) PressButton(userID int64, message Message, data []byte) *tg.Updates {
+	t.mu.Lock()
+	t.nextID++
+	queryID := t.nextID
+	t.mu.Unlock()
+	return &tg.Updates{
+		Updates: []tg.UpdateClass{&tg.UpdateBotCallbackQuery{
+			QueryID: queryID,
+			UserID:  userID,
+			Peer:    &tg.PeerUser{UserID: message.UserID},
+			MsgID:   message.ID,
+			Data:    data,
+		}},
+		Users: []tg.UserClass{user(userID)},
+		Date:  int(time.Now().Unix()),
+	}
+}
+
+func (t *Telegram) userMessage(userID int64, message *tg.Message) *tg.Updates {
+	t.mu.Lock()
+	t.lastMessage[userID]++
+	message.ID = t.lastMessage[userID]
+	t.mu.Unlock()
+	message.PeerID = &tg.PeerUser{UserID: userID}
+	message.Date = int(time.Now().Unix())
+	return &tg.Updates{
+		Updates: []tg.UpdateClass{&tg.UpdateNewMessage{Message: message, Pts: message.ID, PtsCount: 1}},
+		Users:   []tg.UserClass{user(userID)},
+		Date:    message.Date,
+	}
+}
+
+func (t *Telegram) groupMessage(chatID int64, topicID int, userID int64, message *tg.Message) *tg.Updates {
+	t.mu.Lock()
+	t.lastMessage[chatID]++
+	message.ID = t.lastMessage[chatID]
+	t.mu.Unlock()
+	message.PeerID = &tg.PeerChannel{ChannelID: chatID}
+	message.FromID = &tg.PeerUser{UserID: userID}
+	message.Date = int(time.Now().Unix())
+	if topicID > 1 {
+		message.ReplyTo = &tg.MessageReplyHeader{ForumTopic: true, ReplyToMsgID: topicID}
+	}
+	return &tg.Updates{
+		Updates: []tg.UpdateClass{&tg.UpdateNewChannelMessage{Message: message, Pts: message.ID, PtsCount: 1}},
+		Users:   []tg.UserClass{user(userID)},
+		Chats: []tg.ChatClass{&tg.Channel{
+			ID:         chatID,
+			AccessHash: chatID,
+			Title:      fmt.Sprintf("Group %d", chatID),
+			Megagroup:  true,
+			Forum:      true,
+			Photo:      &tg.ChatPhotoEmpty{},
+		}},
+		Date: message.Date,
+	}
+}
+
+func user(userID int64) *tg.User {
+	return &tg.User{ID: userID, AccessHash: userID, FirstName: fmt.Sprintf("User %d", userID)}
+}
+
+// Invoke answers the requests of the API clients. Only the requests for downloading files,
+// resolving channels, sending messages to users and groups, editing messages to users,
+// forwarding them to channels and answering callback queries are supported.
+func (t *Telegram) Invoke(ctx context.Context, input bin.Encoder, output bin.Decoder) error {
+	var response bin.Encoder
+	switch req := input.(type) {
+	case *tg.UploadGetFileRequest:
+		file, err := t.getFile(ctx, req)
+		if err != nil {
+			return err
+		}
+		response = file
+	case *tg.MessagesSendMessageRequest:
+		var id int
+		switch peer := req.Peer.(type) {
+		case *tg.InputPeerUser:
+			id = t.notify(peer.UserID, req.Message, req.ReplyMarkup)
+		case *tg.InputPeerChannel:
+			id = t.post(peer.ChannelID, req)
+		default:
+			return fmt.Errorf("can't send messages to %T", req.Peer)
+		}
+		response = &tg.UpdateShortSentMessage{Out: true, ID: id, Date: int(time.Now().Unix())}
+	case *tg.MessagesEditMessageRequest:
+		edited, err := t.editMessage(req)
+		if err != nil {
+			return err
+		}
+		response = edited
+	case *tg.MessagesForwardMessagesRequest:
+		forwarded, err := t.forwardMessages(req)
+		if err != nil {
+			return err
+		}
+		response = forwarded
+	case *tg.ChannelsGetChannelsRequest:
+		chats := &tg.MessagesChats{}
+		for _, channel := range req.ID {
+			if channel, ok := channel.(*tg.InputChannel); ok {
+				chats.Chats = append(chats.Chats, &tg.Channel{ID: channel.ChannelID, AccessHash: channel.ChannelID, Photo: &tg.ChatPhotoEmpty{}})
+			}
+		}
+		response = chats
+	case *tg.MessagesSetBotCallbackAnswerRequest:
+		response = &tg.BoolTrue{}
+	default:
+		return fmt.Errorf("%T is not supported by the fake", input)
+	}
+	var buf bin.Buffer
+	if err := response.Encode(&buf); err != nil {
+		return err
+	}
+	return output.Decode(&buf)
+}
+
+// post records the message sent to the group
+func (t *Telegram) post(chatID int64, req *tg.MessagesSendMessageRequest) int {
+	message := Message{ChatID: chatID, Text: req.Message, Markup: req.ReplyMarkup}
+	if replyTo, ok := req.ReplyTo.(*tg.InputReplyToMessage); ok {
+		message.ReplyTo, message.TopicID = replyTo.ReplyToMsgID, replyTo.TopMsgID
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.lastMessage[chatID]++
+	message.ID = t.lastMessage[chatID]
+	t.sent = append(t.sent, message)
+	return message.ID
+}
+
+// editMessage changes the text of a message sent to a user
+func (t *Telegram) editMessage(req *tg.MessagesEditMessageRequest) (*tg.Updates, error) {
+	peer, ok := req.Peer.(*tg.InputPeerUser)
+	if !ok {
+		return nil, fmt.Errorf("can't edit messages of %T", req.Peer)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i := range t.sent {
+		if message := &t.sent[i]; message.UserID == peer.UserID && message.ID == req.ID {
+			message.Text, message.Markup = req.Message, req.ReplyMarkup
+			return &tg.Updates{
+				Updates: []tg.UpdateClass{&tg.UpdateEditMessage{Message: &tg.Message{
+					ID:      req.ID,
+					Out:     true,
+					PeerID:  &tg.PeerUser{UserID: peer.UserID},
+					Message: req.Message,
+				}}},
+				Date: int(time.Now().Unix()),
+			}, nil
+		}
+	}
+	return nil, fmt.Errorf("message %d of user %d wasn't sent by the fake", req.ID, peer.UserID)
+}
+
+// forwardMessages posts the file of a message a user sent to the bot, or to a group, to the channel
+func (t *Telegram) forwardMessages(req *tg.MessagesForwardMessagesRequest) (*tg.Updates, error) {
+	channel, ok := req.ToPeer.(*tg.InputPeerChannel)
+	if !ok || len(req.ID) != 1 || len(req.RandomID) != 1 {
+		return nil, fmt.Errorf("can only forward a message to a channel, not %d to %T", len(req.ID), req.ToPeer)
+	}
+	var fromID int64
+	switch from := req.FromPeer.(type) {
+	case *tg.InputPeerUser:
+		fromID = from.UserID
+	case *tg.InputPeerChannel:
+		fromID = from.ChannelID
+	default:
+		return nil, fmt.Errorf("can only forward messages of users and groups, not of %T", req.FromPeer)
+	}
+	t.mu.Lock()
+	doc := t.documents[t.userFiles[messageKey{fromID, req.ID[0]}]]
+	t.mu.Unlock()
+	if doc == nil {
+		return nil, fmt.Errorf("message %d of chat %d has no file", req.ID[0], fromID)
+	}
+	messageID := t.nextChannelMessage(channel.ChannelID)
+	t.mu.Lock()
+	t.messages[messageKey{channel.ChannelID, messageID}] = doc.file.ID
+	t.mu.Unlock()
+	return &tg.Updates{
+		Updates: []tg.UpdateClass{
+			&tg.UpdateMessageID{ID: messageID, RandomID: req.RandomID[0]},
+			&tg.UpdateNewChannelMessage{Message: &tg.Message{
+				ID:     messageID,
+				PeerID: &tg.PeerChannel{ChannelID: channel.ChannelID},
+				Date:   int(time.Now().Unix()),
+				Media: &tg.MessageMediaDocument{Document: &tg.Document{
+					ID:         doc.file.ID,
+					MimeType:   doc.file.MimeType,
+					Size:       doc.file.FileSize,
+					Attributes: []tg.DocumentAttributeClass{&tg.DocumentAttributeFilename{FileName: doc.file.FileName}},
+				}},
+			}},
+		},
+		Date: int(time.Now().Unix()),
+	}, nil
+}
+
+func (t *Telegram) getFile(ctx context.Context, req *tg.UploadGetFileRequest) (*tg.UploadFile, error) {
+	t.requests.Add(1)
+	if t.Latency > 0 {
+		timer := time.NewTimer(t.Latency)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+	}
+	location, ok := req.Location.(*tg.InputDocumentFileLocation)
+	if !ok {
+		return nil, fmt.Errorf("%T is not supported by the fake", req.Location)
+	}
+	t.mu.Lock()
+	doc, ok := t.documents[location.ID]
+	t.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("no document %d", location.ID)
+	}
+	size := max(min(int64(req.Limit), doc.file.FileSize-req.Offset), 0)
+	data := make([]byte, size)
+	n, err := doc.content.ReadAt(data, req.Offset)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return &tg.UploadFile{Type: &tg.StorageFilePartial{}, Bytes: data[:n]}, nil
+}
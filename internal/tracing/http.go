@@ -0,0 +1,70 @@
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type remoteKey struct{}
+
+// remoteParent is the span of the caller, from the traceparent header
+type remoteParent struct {
+	traceID [16]byte
+	spanID  [8]byte
+}
+
+// Middleware records a span for every HTTP request and reports server errors
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		if remote, ok := parseTraceparent(c.GetHeader("traceparent")); ok {
+			ctx = withRemoteParent(ctx, remote)
+		}
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		ctx, span := Start(ctx, c.Request.Method+" "+route, KindServer)
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+		status := c.Writer.Status()
+		span.SetAttribute("http.request.method", c.Request.Method)
+		span.SetAttribute("http.route", route)
+		span.SetAttribute("http.response.status_code", status)
+		span.SetAttribute("http.response.body.size", c.Writer.Size())
+		var err error
+		if status >= http.StatusInternalServerError {
+			err = fmt.Errorf("%s %s answered with %d", c.Request.Method, route, status)
+			if len(c.Errors) > 0 {
+				err = c.Errors.Last().Err
+			}
+			CaptureError(ctx, "http", err)
+		}
+		span.End(err)
+	}
+}
+
+func withRemoteParent(ctx context.Context, remote remoteParent) context.Context {
+	return context.WithValue(ctx, remoteKey{}, remote)
+}
+
+// parseTraceparent parses a W3C traceparent header like 00-<trace id>-<span id>-01
+func parseTraceparent(header string) (remoteParent, bool) {
+	var remote remoteParent
+	fields := strings.Split(header, "-")
+	if len(fields) != 4 || len(fields[1]) != 32 || len(fields[2]) != 16 {
+		return remote, false
+	}
+	if _, err := hex.Decode(remote.traceID[:], []byte(fields[1])); err != nil {
+		return remote, false
+	}
+	if _, err := hex.Decode(remote.spanID[:], []byte(fields[2])); err != nil {
+		return remote, false
+	}
+	return remote, remote.traceID != [16]byte{}
+}
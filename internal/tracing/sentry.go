@@ -0,0 +1,131 @@
+package tracing
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/utils"
+	"EverythingSuckz/fsb/internal/version"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// sentry is where errors are reported, parsed from a DSN like https://<key>@<host>/<project>
+var sentry struct {
+	dsn      string
+	key      string
+	envelope string
+}
+
+func loadSentry() {
+	dsn := config.ValueOf.SentryDSN
+	if dsn == "" {
+		return
+	}
+	parsed, err := url.Parse(dsn)
+	if err != nil || parsed.User == nil || strings.Trim(parsed.Path, "/") == "" {
+		log.Sugar().Warn("Ignoring invalid SENTRY_DSN, it should look like https://<key>@<host>/<project>")
+		return
+	}
+	sentry.dsn = dsn
+	sentry.key = parsed.User.Username()
+	sentry.envelope = fmt.Sprintf("%s://%s/api/%s/envelope/", parsed.Scheme, parsed.Host, strings.Trim(parsed.Path, "/"))
+	log.Sugar().Infof("Reporting errors to Sentry at %s", parsed.Host)
+}
+
+// CaptureError reports an error to Sentry, linked to the trace of the context if there is one
+func CaptureError(ctx context.Context, component string, err error) {
+	if sentry.dsn == "" || err == nil {
+		return
+	}
+	capture(ctx, component, fmt.Sprintf("%T", err), err.Error(), "")
+}
+
+// CapturePanic reports a recovered panic and its stack trace to Sentry
+func CapturePanic(component string, value interface{}, stack string) {
+	if sentry.dsn == "" {
+		return
+	}
+	capture(context.Background(), component, "panic", fmt.Sprint(value), stack)
+}
+
+func capture(ctx context.Context, component string, errorType string, message string, stack string) {
+	var id [16]byte
+	rand.Read(id[:])
+	event := map[string]interface{}{
+		"event_id":    hex.EncodeToString(id[:]),
+		"timestamp":   time.Now().UTC().Format(time.RFC3339Nano),
+		"level":       "error",
+		"platform":    "go",
+		"logger":      component,
+		"release":     "fsb@" + version.Version,
+		"server_name": utils.InstanceID,
+		"environment": environment(),
+		"tags":        map[string]string{"component": component, "commit": version.BuildCommit()},
+		"exception": map[string]interface{}{"values": []interface{}{map[string]interface{}{
+			"type":  errorType,
+			"value": message,
+		}}},
+	}
+	if stack != "" {
+		event["extra"] = map[string]string{"stack": stack}
+	}
+	if span := FromContext(ctx); span != nil {
+		event["contexts"] = map[string]interface{}{"trace": map[string]string{
+			"trace_id": span.TraceID(),
+			"span_id":  hex.EncodeToString(span.spanID[:]),
+		}}
+	}
+	go func() {
+		if err := sendEnvelope(event); err != nil {
+			log.Warn("Failed to report error to Sentry", zap.Error(err))
+		}
+	}()
+}
+
+func environment() string {
+	if config.ValueOf.Dev {
+		return "development"
+	}
+	return "production"
+}
+
+// sendEnvelope sends the event with the envelope endpoint of Sentry
+func sendEnvelope(event map[string]interface{}) error {
+	header, err := json.Marshal(map[string]string{"event_id": event["event_id"].(string), "dsn": sentry.dsn})
+	if err != nil {
+		return err
+	}
+	item, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	var body bytes.Buffer
+	body.Write(header)
+	body.WriteString("\n{\"type\":\"event\"}\n")
+	body.Write(item)
+	body.WriteString("\n")
+	req, err := http.NewRequest(http.MethodPost, sentry.envelope, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-sentry-envelope")
+	req.Header.Set("X-Sentry-Auth", fmt.Sprintf("Sentry sentry_version=7, sentry_key=%s, sentry_client=fsb/%s", sentry.key, version.Version))
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("sentry answered with %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/dispatcher/handlers"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/gotd/td/bin"
+	"github.com/gotd/td/telegram"
+	"github.com/gotd/td/tg"
+)
+
+// TelegramMiddleware records a span for every Telegram API call
+func TelegramMiddleware() telegram.Middleware {
+	return telegram.MiddlewareFunc(func(next tg.Invoker) telegram.InvokeFunc {
+		return func(ctx context.Context, input bin.Encoder, output bin.Decoder) error {
+			// calls outside of a trace, like the updates polling, would each start a trace of their own
+			if FromContext(ctx) == nil {
+				return next.Invoke(ctx, input, output)
+			}
+			name := fmt.Sprintf("%T", input)
+			if typed, ok := input.(interface{ TypeName() string }); ok {
+				name = typed.TypeName()
+			}
+			ctx, span := Start(ctx, name, KindClient)
+			span.SetAttribute("rpc.system", "mtproto")
+			err := next.Invoke(ctx, input, output)
+			span.End(err)
+			return err
+		}
+	})
+}
+
+// Dispatcher wraps the dispatcher so that every handler that handles an update records a span
+func Dispatcher(d dispatcher.Dispatcher) dispatcher.Dispatcher {
+	return &tracedDispatcher{d}
+}
+
+type tracedDispatcher struct {
+	dispatcher.Dispatcher
+}
+
+func (d *tracedDispatcher) AddHandler(h dispatcher.Handler) {
+	d.Dispatcher.AddHandler(&tracedHandler{h, handlerName(h)})
+}
+
+func (d *tracedDispatcher) AddHandlerToGroup(h dispatcher.Handler, group int) {
+	d.Dispatcher.AddHandlerToGroup(&tracedHandler{h, handlerName(h)}, group)
+}
+
+type tracedHandler struct {
+	dispatcher.Handler
+	name string
+}
+
+// CheckUpdate runs the handler in a span. Spans of handlers that let the update pass
+// through, mostly because it didn't match, are dropped.
+func (h *tracedHandler) CheckUpdate(ctx *ext.Context, u *ext.Update) error {
+	if !Enabled() {
+		return h.Handler.CheckUpdate(ctx, u)
+	}
+	parent := ctx.Context
+	traced, span := Start(parent, h.name, KindServer)
+	ctx.Context = traced
+	err := h.Handler.CheckUpdate(ctx, u)
+	ctx.Context = parent
+	if err == nil || errors.Is(err, dispatcher.ContinueGroups) || errors.Is(err, dispatcher.SkipCurrentGroup) {
+		return err
+	}
+	if user := u.EffectiveUser(); user != nil {
+		span.SetAttribute("telegram.user_id", user.ID)
+	}
+	if errors.Is(err, dispatcher.EndGroups) {
+		span.End(nil)
+		return err
+	}
+	CaptureError(traced, h.name, err)
+	span.End(err)
+	return err
+}
+
+func handlerName(h dispatcher.Handler) string {
+	switch handler := h.(type) {
+	case handlers.Command:
+		return "command /" + handler.Name
+	case handlers.CallbackQuery:
+		return "callback query"
+	case handlers.Message:
+		return "message"
+	}
+	return fmt.Sprintf("%T", h)
+}
@@ -0,0 +1,282 @@
+// Package tracing records spans of the bot update handling, the Telegram API calls and the HTTP
+// requests and exports them to an OpenTelemetry collector with OTLP over HTTP. Errors and panics
+// are reported to Sentry. Both are disabled unless OTLP_ENDPOINT or SENTRY_DSN are set.
+package tracing
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/utils"
+	"EverythingSuckz/fsb/internal/version"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	mrand "math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// exportInterval is how often the recorded spans are sent to the collector
+	exportInterval = 5 * time.Second
+	// batchSize is the maximum number of spans sent at once
+	batchSize = 512
+	// queueSize is the number of spans kept until they are sent, newer spans are dropped when it's full
+	queueSize = 4096
+)
+
+// Kind is the OTLP kind of a span
+type Kind int
+
+const (
+	KindInternal Kind = 1
+	KindServer   Kind = 2
+	KindClient   Kind = 3
+)
+
+// Span is an operation of a trace. Its methods do nothing on nil spans, which are
+// returned when tracing is disabled or the trace isn't sampled.
+type Span struct {
+	traceID    [16]byte
+	spanID     [8]byte
+	parentID   [8]byte
+	name       string
+	kind       Kind
+	start      time.Time
+	attributes map[string]interface{}
+}
+
+type spanKey struct{}
+
+var (
+	log      = zap.NewNop()
+	endpoint string
+	headers  = make(map[string]string)
+	queue    chan *exportedSpan
+	client   = &http.Client{Timeout: 10 * time.Second}
+	dropped  int
+	droppedM sync.Mutex
+)
+
+// Load configures the export of spans to OTLP_ENDPOINT and of errors to SENTRY_DSN
+func Load(l *zap.Logger) {
+	log = l.Named("tracing")
+	loadSentry()
+	endpoint = strings.TrimSuffix(config.ValueOf.OTLPEndpoint, "/")
+	if endpoint == "" {
+		return
+	}
+	if !strings.HasSuffix(endpoint, "/v1/traces") {
+		endpoint += "/v1/traces"
+	}
+	for _, header := range config.ValueOf.OTLPHeaders {
+		key, value, ok := strings.Cut(header, "=")
+		if !ok {
+			log.Sugar().Warnf("Ignoring invalid OTLP header %q, use key=value", header)
+			continue
+		}
+		headers[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	queue = make(chan *exportedSpan, queueSize)
+	go export()
+	log.Sugar().Infof("Exporting traces to %s", endpoint)
+}
+
+// Enabled reports whether spans are exported
+func Enabled() bool {
+	return queue != nil
+}
+
+// Start starts a span, as a child of the span in the context if there is one. New traces
+// are sampled with the probability TRACE_SAMPLE_RATE.
+func Start(ctx context.Context, name string, kind Kind) (context.Context, *Span) {
+	if !Enabled() {
+		return ctx, nil
+	}
+	parent := FromContext(ctx)
+	span := &Span{name: name, kind: kind, start: time.Now()}
+	if parent != nil {
+		span.traceID = parent.traceID
+		span.parentID = parent.spanID
+	} else if remote, ok := ctx.Value(remoteKey{}).(remoteParent); ok {
+		span.traceID = remote.traceID
+		span.parentID = remote.spanID
+	} else {
+		if mrand.Float64() >= config.ValueOf.TraceSampleRate {
+			return ctx, nil
+		}
+		rand.Read(span.traceID[:])
+	}
+	rand.Read(span.spanID[:])
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// FromContext returns the span of the context, or nil
+func FromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// SetAttribute adds an attribute to the span
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	if s.attributes == nil {
+		s.attributes = make(map[string]interface{})
+	}
+	s.attributes[key] = value
+}
+
+// TraceID returns the hex encoded trace ID, or an empty string for nil spans
+func (s *Span) TraceID() string {
+	if s == nil {
+		return ""
+	}
+	return hex.EncodeToString(s.traceID[:])
+}
+
+// End ends the span, marking it failed if err isn't nil, and queues it for the export
+func (s *Span) End(err error) {
+	if s == nil {
+		return
+	}
+	exported := &exportedSpan{
+		TraceID:           hex.EncodeToString(s.traceID[:]),
+		SpanID:            hex.EncodeToString(s.spanID[:]),
+		Name:              s.name,
+		Kind:              int(s.kind),
+		StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(time.Now().UnixNano(), 10),
+		Status:            exportedStatus{Code: 1},
+	}
+	if s.parentID != [8]byte{} {
+		exported.ParentSpanID = hex.EncodeToString(s.parentID[:])
+	}
+	for key, value := range s.attributes {
+		exported.Attributes = append(exported.Attributes, attribute(key, value))
+	}
+	if err != nil {
+		exported.Status = exportedStatus{Code: 2, Message: err.Error()}
+	}
+	select {
+	case queue <- exported:
+	default:
+		droppedM.Lock()
+		dropped++
+		droppedM.Unlock()
+	}
+}
+
+// the OTLP/JSON encoding of spans, see https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding
+
+type exportedSpan struct {
+	TraceID           string              `json:"traceId"`
+	SpanID            string              `json:"spanId"`
+	ParentSpanID      string              `json:"parentSpanId,omitempty"`
+	Name              string              `json:"name"`
+	Kind              int                 `json:"kind"`
+	StartTimeUnixNano string              `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string              `json:"endTimeUnixNano"`
+	Attributes        []exportedAttribute `json:"attributes,omitempty"`
+	Status            exportedStatus      `json:"status"`
+}
+
+type exportedStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type exportedAttribute struct {
+	Key   string                 `json:"key"`
+	Value map[string]interface{} `json:"value"`
+}
+
+func attribute(key string, value interface{}) exportedAttribute {
+	switch v := value.(type) {
+	case bool:
+		return exportedAttribute{key, map[string]interface{}{"boolValue": v}}
+	case int:
+		return exportedAttribute{key, map[string]interface{}{"intValue": strconv.Itoa(v)}}
+	case int64:
+		return exportedAttribute{key, map[string]interface{}{"intValue": strconv.FormatInt(v, 10)}}
+	case float64:
+		return exportedAttribute{key, map[string]interface{}{"doubleValue": v}}
+	default:
+		return exportedAttribute{key, map[string]interface{}{"stringValue": fmt.Sprint(v)}}
+	}
+}
+
+// export sends the queued spans to the collector in batches
+func export() {
+	ticker := time.NewTicker(exportInterval)
+	defer ticker.Stop()
+	batch := make([]*exportedSpan, 0, batchSize)
+	for {
+		select {
+		case span := <-queue:
+			batch = append(batch, span)
+			if len(batch) < batchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+		}
+		if err := send(batch); err != nil {
+			log.Warn("Failed to export spans", zap.Int("spans", len(batch)), zap.Error(err))
+		}
+		batch = batch[:0]
+		droppedM.Lock()
+		if dropped > 0 {
+			log.Sugar().Warnf("Dropped %d spans because the export queue was full", dropped)
+			dropped = 0
+		}
+		droppedM.Unlock()
+	}
+}
+
+func send(spans []*exportedSpan) error {
+	resource := []exportedAttribute{
+		attribute("service.name", "fsb"),
+		attribute("service.version", version.Version),
+		attribute("service.instance.id", utils.InstanceID),
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"resourceSpans": []interface{}{map[string]interface{}{
+			"resource": map[string]interface{}{"attributes": resource},
+			"scopeSpans": []interface{}{map[string]interface{}{
+				"scope": map[string]interface{}{"name": "EverythingSuckz/fsb", "version": version.Version},
+				"spans": spans,
+			}},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("collector answered with %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package types
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+// APIToken is a personal access token a user created with /apitoken for their own scripts.
+// Only the digest of the token is stored, it's shown once when it's created.
+type APIToken struct {
+	ID         uint      `gorm:"primaryKey"`
+	UserID     int64     `gorm:"index;not null"`
+	Name       string    `gorm:"not null"`
+	Digest     string    `gorm:"uniqueIndex;not null"`
+	Scopes     string    `gorm:"not null"` // comma separated, eg. read-history,stream
+	CreatedAt  time.Time `gorm:"autoCreateTime"`
+	LastUsedAt *time.Time
+}
+
+// TableName specifies the table name for APIToken
+func (APIToken) TableName() string {
+	return "api_tokens"
+}
+
+// HasScope reports whether the token was created with the scope
+func (t *APIToken) HasScope(scope string) bool {
+	return slices.Contains(strings.Split(t.Scopes, ","), scope)
+}
@@ -0,0 +1,33 @@
+package types
+
+import (
+	"time"
+)
+
+// Collection is a folder a user organizes their links in. Collections named with slashes
+// are nested in the ones named before the last slash, eg. Movies/Action in Movies.
+type Collection struct {
+	ID         uint      `gorm:"primaryKey"`
+	UserID     int64     `gorm:"uniqueIndex:idx_collection_user_name;not null"`
+	Name       string    `gorm:"uniqueIndex:idx_collection_user_name;not null"`
+	ShareToken string    `gorm:"index"` // opens the collection at /c/<token>, empty if it isn't shared
+	CreatedAt  time.Time `gorm:"autoCreateTime"`
+}
+
+// TableName specifies the table name for Collection
+func (Collection) TableName() string {
+	return "collections"
+}
+
+// CollectionItem is a link added to a collection
+type CollectionItem struct {
+	CollectionID uint      `gorm:"primaryKey;autoIncrement:false"`
+	TenantID     uint      `gorm:"primaryKey;autoIncrement:false;default:0"`
+	MessageID    int       `gorm:"primaryKey;autoIncrement:false"`
+	CreatedAt    time.Time `gorm:"autoCreateTime"`
+}
+
+// TableName specifies the table name for CollectionItem
+func (CollectionItem) TableName() string {
+	return "collection_items"
+}
@@ -0,0 +1,24 @@
+package types
+
+import (
+	"time"
+)
+
+// CommandUse records a use of a command that has a cooldown
+type CommandUse struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement"`
+	UserID    int64     `gorm:"index:idx_command_use;not null"`
+	Command   string    `gorm:"index:idx_command_use;not null"`
+	CreatedAt time.Time `gorm:"index:idx_command_use;index;autoCreateTime"`
+}
+
+// TableName specifies the table name for CommandUse
+func (CommandUse) TableName() string {
+	return "command_uses"
+}
+
+// CommandUsage counts the uses of a command by a user
+type CommandUsage struct {
+	UserID int64 `json:"user_id"`
+	Uses   int64 `json:"uses"`
+}
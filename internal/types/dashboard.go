@@ -0,0 +1,19 @@
+package types
+
+import (
+	"time"
+)
+
+// DashboardAccount is an admin account of the web dashboard created with `fsb dashboard-admin`,
+// which signs in with a password rather than through Telegram
+type DashboardAccount struct {
+	Username     string    `gorm:"primaryKey"`
+	PasswordHash string    `gorm:"not null"` // bcrypt
+	CreatedAt    time.Time `gorm:"autoCreateTime"`
+	LastLoginAt  *time.Time
+}
+
+// TableName specifies the table name for DashboardAccount
+func (DashboardAccount) TableName() string {
+	return "dashboard_accounts"
+}
@@ -0,0 +1,18 @@
+package types
+
+import (
+	"time"
+)
+
+// FeatureFlag is the state of a feature flag changed at runtime, overriding FEATURES
+type FeatureFlag struct {
+	Name      string    `gorm:"primaryKey"`
+	State     string    `gorm:"not null"` // on, off, admins or a percentage of users like 10%
+	UpdatedBy int64     `gorm:"not null;default:0"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+}
+
+// TableName specifies the table name for FeatureFlag
+func (FeatureFlag) TableName() string {
+	return "feature_flags"
+}
@@ -10,11 +10,16 @@ import (
 )
 
 type File struct {
-	Location tg.InputFileLocationClass
-	FileSize int64
-	FileName string
-	MimeType string
-	ID       int64
+	Location  tg.InputFileLocationClass
+	FileSize  int64
+	FileName  string
+	MimeType  string
+	ID        int64
+	Duration  int    // seconds, for audio and video
+	Title     string // audio tags
+	Performer string
+	Voice     bool // voice message or round video message
+	Animated  bool // GIF, sent by Telegram as a silent video
 }
 
 type HashableFileStruct struct {
@@ -0,0 +1,19 @@
+package types
+
+import (
+	"time"
+)
+
+// GroupTopic is a forum topic the bot generates links in with GROUP_MODE, enabled with
+// /enabletopic. Groups without enabled topics get links in all of their topics.
+type GroupTopic struct {
+	ChatID    int64     `gorm:"primaryKey;autoIncrement:false"`
+	TopicID   int       `gorm:"primaryKey;autoIncrement:false"` // the message that created the topic, 1 for General
+	EnabledBy int64     `gorm:"not null"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// TableName specifies the table name for GroupTopic
+func (GroupTopic) TableName() string {
+	return "group_topics"
+}
@@ -0,0 +1,39 @@
+package types
+
+import (
+	"time"
+)
+
+// States of background jobs
+const (
+	JobQueued  = "queued"
+	JobRunning = "running"
+	JobDone    = "done"
+	JobDead    = "dead" // failed every attempt, listed by /jobs until it's retried or removed
+)
+
+// Job is a task run in the background by the workers of its kind, like saving a file or
+// sending a broadcast. Jobs are stored so that they survive restarts.
+type Job struct {
+	ID         uint      `gorm:"primaryKey"`
+	Kind       string    `gorm:"not null;index:idx_jobs_claim,priority:1"`
+	State      string    `gorm:"not null;default:'queued';index:idx_jobs_claim,priority:2"`
+	RunAt      time.Time `gorm:"not null;index:idx_jobs_claim,priority:3"` // the job isn't run before, retries are delayed
+	Payload    string    `gorm:"not null;default:''"`                      // JSON
+	Attempts   int       `gorm:"not null;default:0"`
+	LastError  string
+	CreatedAt  time.Time `gorm:"autoCreateTime"`
+	FinishedAt *time.Time
+}
+
+// TableName specifies the table name for Job
+func (Job) TableName() string {
+	return "jobs"
+}
+
+// JobCount is the number of jobs of a kind in a state
+type JobCount struct {
+	Kind  string
+	State string
+	Count int64
+}
@@ -0,0 +1,85 @@
+package types
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Link represents a generated stream link and the bot reply it was sent in
+type Link struct {
+	TenantID    uint   `gorm:"primaryKey;autoIncrement:false;default:0"` // 0 for the default tenant
+	MessageID   int    `gorm:"primaryKey;autoIncrement:false"`           // message ID in the log channel of the tenant
+	Hash        string `gorm:"not null"`
+	OldHash     string // hash before /regeneratelinks, it keeps working
+	UserID      int64  `gorm:"index;not null"`
+	ReplyID     int    `gorm:"not null;default:0"`       // bot reply message ID in the user's chat
+	SourceID    int    `gorm:"not null;default:0"`       // the user's message the link was generated from
+	GroupedID   int64  `gorm:"index;not null;default:0"` // Telegram ID of the album the source message was sent in
+	FileName    string
+	FileSize    int64
+	MimeType    string
+	FileID      int64  `gorm:"index;not null;default:0"` // Telegram ID of the file, shared by its forwards
+	Duration    int    `gorm:"not null;default:0"`       // seconds, for audio and video
+	Title       string // audio tags
+	Performer   string
+	Checksum    string // SHA-256 of the file, once it was fetched completely
+	Category    string `gorm:"index"` // assigned when the link is generated, eg. Music or Movies
+	Views       int64  `gorm:"not null;default:0"`
+	EditedViews int64  `gorm:"not null;default:0"` // views shown in the reply at the last edit
+	LastAccess  *time.Time
+	RevokedAt   *time.Time // revoked with /revokeall, the link doesn't work anymore
+	RemovedAt   *time.Time // the file in the log channel or the message it was sent in was deleted
+	BumpedAt    *time.Time // moved to the top of the player queue with a reaction
+	CreatedAt   time.Time  `gorm:"autoCreateTime"`
+	UpdatedAt   time.Time  `gorm:"autoUpdateTime"`
+}
+
+// TableName specifies the table name for Link
+func (Link) TableName() string {
+	return "links"
+}
+
+// MatchesHash reports whether the hash of a request opens the link, with its current
+// hash or the one it had before it was regenerated
+func (l *Link) MatchesHash(hash string) bool {
+	return hash != "" && (hash == l.Hash || hash == l.OldHash)
+}
+
+// StorageKey identifies the link in file paths. Links of different tenants can share message IDs.
+func (l *Link) StorageKey() string {
+	if l.TenantID == 0 {
+		return strconv.Itoa(l.MessageID)
+	}
+	return fmt.Sprintf("%d-%d", l.TenantID, l.MessageID)
+}
+
+// LinkAccess records a distinct IP address that accessed a link, its country and when it last did
+type LinkAccess struct {
+	ID         uint      `gorm:"primaryKey;autoIncrement"`
+	TenantID   uint      `gorm:"uniqueIndex:idx_link_access_tenant;not null;default:0"`
+	MessageID  int       `gorm:"uniqueIndex:idx_link_access_tenant;not null"`
+	IP         string    `gorm:"uniqueIndex:idx_link_access_tenant;not null"`
+	Country    string    `gorm:"not null;default:''"` // from COUNTRY_HEADER, empty if unknown
+	CreatedAt  time.Time `gorm:"index;autoCreateTime"`
+	AccessedAt time.Time `gorm:"index"`
+}
+
+// TableName specifies the table name for LinkAccess
+func (LinkAccess) TableName() string {
+	return "link_accesses"
+}
+
+// LinkShare allows a user to open a private link. Links with shares can only be opened
+// by their owner and the users they were shared with, signed in with /weblogin.
+type LinkShare struct {
+	TenantID  uint      `gorm:"primaryKey;autoIncrement:false;default:0"`
+	MessageID int       `gorm:"primaryKey;autoIncrement:false"`
+	UserID    int64     `gorm:"primaryKey;autoIncrement:false"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// TableName specifies the table name for LinkShare
+func (LinkShare) TableName() string {
+	return "link_shares"
+}
@@ -0,0 +1,25 @@
+package types
+
+import (
+	"time"
+)
+
+// LinkMetadata is what TMDB or MusicBrainz know about the file of a link. Files that
+// weren't found are stored without a source, so that they aren't looked up again.
+type LinkMetadata struct {
+	TenantID   uint      `gorm:"primaryKey;autoIncrement:false;default:0"`
+	MessageID  int       `gorm:"primaryKey;autoIncrement:false"`
+	Source     string    // tmdb or musicbrainz, empty if nothing was found
+	ExternalID string    // eg. movie/603 or the MusicBrainz ID of the recording
+	Title      string    // proper title, eg. The Matrix
+	Artist     string    // for music
+	Year       int       `gorm:"not null;default:0"`
+	Overview   string    // synopsis of movies and shows
+	PosterURL  string    // poster or cover art
+	LookedUpAt time.Time `gorm:"autoCreateTime"`
+}
+
+// TableName specifies the table name for LinkMetadata
+func (LinkMetadata) TableName() string {
+	return "link_metadata"
+}
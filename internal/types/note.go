@@ -0,0 +1,32 @@
+package types
+
+import (
+	"time"
+)
+
+// UserNote is a free-form note an admin wrote about a user with /note
+type UserNote struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    int64     `gorm:"index;not null"`
+	Text      string    `gorm:"not null;serializer:encrypted"`
+	CreatedBy int64     `gorm:"not null"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// TableName specifies the table name for UserNote
+func (UserNote) TableName() string {
+	return "user_notes"
+}
+
+// UserTag is a tag an admin put on a user with /tag
+type UserTag struct {
+	UserID    int64     `gorm:"primaryKey;autoIncrement:false"`
+	Tag       string    `gorm:"primaryKey;index"`
+	CreatedBy int64     `gorm:"not null"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// TableName specifies the table name for UserTag
+func (UserTag) TableName() string {
+	return "user_tags"
+}
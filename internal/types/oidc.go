@@ -0,0 +1,19 @@
+package types
+
+import (
+	"time"
+)
+
+// OIDCIdentity maps an account of the OIDC provider to the Telegram user it signs in as
+type OIDCIdentity struct {
+	Issuer    string    `gorm:"primaryKey"`
+	Subject   string    `gorm:"primaryKey"`
+	UserID    int64     `gorm:"index;not null"`
+	Email     string    // when it was linked, for /sso
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// TableName specifies the table name for OIDCIdentity
+func (OIDCIdentity) TableName() string {
+	return "oidc_identities"
+}
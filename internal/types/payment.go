@@ -0,0 +1,22 @@
+package types
+
+import (
+	"time"
+)
+
+// Payment records a successful subscription payment
+type Payment struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement"`
+	UserID    int64     `gorm:"index;not null"`
+	Plan      string    `gorm:"not null"`
+	Currency  string    `gorm:"not null"` // XTR for Telegram Stars
+	Amount    int64     `gorm:"not null"` // in the smallest units of the currency
+	ChargeID  string    `gorm:"uniqueIndex;not null"`
+	PaidUntil time.Time // end of the authorization after the payment
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// TableName specifies the table name for Payment
+func (Payment) TableName() string {
+	return "payments"
+}
@@ -0,0 +1,19 @@
+package types
+
+import (
+	"time"
+)
+
+// ProcessedMessage is a message with media the bot handled, so that it isn't handled again
+// when Telegram delivers its update twice, e.g. after a reconnect
+type ProcessedMessage struct {
+	ChatID      int64     `gorm:"primaryKey;autoIncrement:false"`
+	MessageID   int       `gorm:"primaryKey;autoIncrement:false"`
+	EditDate    int       `gorm:"primaryKey;autoIncrement:false"` // 0 for new messages, edits that replace the file are handled too
+	ProcessedAt time.Time `gorm:"index;not null"`
+}
+
+// TableName specifies the table name for ProcessedMessage
+func (ProcessedMessage) TableName() string {
+	return "processed_messages"
+}
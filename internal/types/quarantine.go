@@ -0,0 +1,23 @@
+package types
+
+import (
+	"time"
+)
+
+// Quarantine records a file that was blocked by the virus scanner
+type Quarantine struct {
+	ID        uint  `gorm:"primaryKey;autoIncrement"`
+	UserID    int64 `gorm:"index;not null"`
+	FileName  string
+	FileSize  int64
+	MimeType  string
+	Signature string    `gorm:"not null"`
+	Source    string    `gorm:"not null"` // where the file came from, eg. telegram
+	Reviewed  bool      `gorm:"index;not null;default:false"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// TableName specifies the table name for Quarantine
+func (Quarantine) TableName() string {
+	return "quarantine"
+}
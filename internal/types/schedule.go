@@ -0,0 +1,32 @@
+package types
+
+import (
+	"time"
+)
+
+// Audiences of scheduled messages
+const (
+	AudienceAll          = "all"
+	AudienceAuthorized   = "authorized"
+	AudienceUnauthorized = "unauthorized"
+	AudienceAdmins       = "admins"
+)
+
+// Schedule is a message an admin scheduled with /schedule, sent once or repeatedly
+type Schedule struct {
+	ID        uint          `gorm:"primaryKey"`
+	Message   string        `gorm:"not null"`
+	Audience  string        `gorm:"not null;default:'all'"` // all, authorized, unauthorized or admins
+	RunAt     time.Time     `gorm:"index;not null"`         // next time the message is sent
+	Interval  time.Duration `gorm:"not null;default:0"`     // time between two runs, 0 for one-off messages
+	Done      bool          `gorm:"index;not null;default:false"`
+	Runs      int           `gorm:"not null;default:0"`
+	LastRunAt *time.Time
+	CreatedBy int64     `gorm:"not null"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// TableName specifies the table name for Schedule
+func (Schedule) TableName() string {
+	return "schedules"
+}
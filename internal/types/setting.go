@@ -0,0 +1,18 @@
+package types
+
+import (
+	"time"
+)
+
+// Setting is a value changed at runtime that overrides the static config
+type Setting struct {
+	Key       string    `gorm:"primaryKey"`
+	Value     string    `gorm:"not null"`
+	UpdatedBy int64     `gorm:"not null;default:0"` // admin who changed it, 0 for the bot itself
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+}
+
+// TableName specifies the table name for Setting
+func (Setting) TableName() string {
+	return "settings"
+}
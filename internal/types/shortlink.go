@@ -0,0 +1,20 @@
+package types
+
+import (
+	"time"
+)
+
+// ShortLink maps a short alias to a generated link
+type ShortLink struct {
+	Alias     string    `gorm:"primaryKey"`
+	TenantID  uint      `gorm:"not null;default:0"`
+	MessageID int       `gorm:"index;not null"` // message ID of the link in the log channel
+	UserID    int64     `gorm:"index;not null"`
+	Custom    bool      `gorm:"not null;default:false"` // chosen by the user instead of generated
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// TableName specifies the table name for ShortLink
+func (ShortLink) TableName() string {
+	return "short_links"
+}
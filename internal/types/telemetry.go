@@ -0,0 +1,34 @@
+package types
+
+import (
+	"time"
+)
+
+// PlaybackEvent is an anonymous playback event reported by the web player
+type PlaybackEvent struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement"`
+	TenantID  uint      `gorm:"not null;default:0"`
+	MessageID int       `gorm:"index;not null"`
+	Session   string    `gorm:"index;not null"` // random ID of the player session, not tied to a user
+	Type      string    `gorm:"not null"`       // play, pause, seek, stall or progress
+	Position  float64   // playback position in seconds
+	Watched   float64   // seconds watched in the session so far
+	CreatedAt time.Time `gorm:"index;autoCreateTime"`
+}
+
+// TableName specifies the table name for PlaybackEvent
+func (PlaybackEvent) TableName() string {
+	return "playback_events"
+}
+
+// PlaybackStats aggregates the playback events of a file
+type PlaybackStats struct {
+	TenantID     uint    `json:"tenant_id"`
+	MessageID    int     `json:"message_id"`
+	FileName     string  `json:"file_name"`
+	Sessions     int64   `json:"sessions"`
+	AvgWatchTime float64 `json:"avg_watch_time"` // seconds
+	Seeks        int64   `json:"seeks"`
+	Stalls       int64   `json:"stalls"`
+	StallRate    float64 `json:"stall_rate"` // stalls per session
+}
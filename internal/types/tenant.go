@@ -0,0 +1,46 @@
+package types
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Tenant is an isolated workspace with its own admins, users, quota and log channel
+type Tenant struct {
+	ID             uint      `gorm:"primaryKey;autoIncrement"`
+	Name           string    `gorm:"not null"`
+	Path           string    `gorm:"uniqueIndex;not null"`          // URL path prefix, eg. /team-a
+	InviteCode     string    `gorm:"not null;serializer:encrypted"` // users join the tenant with /start <code>
+	InviteDigest   string    `gorm:"index"`                         // looks up the tenant of an invite code, see crypt.Digest
+	LogChannelID   int64     `gorm:"not null"`
+	Admins         string    // comma separated user IDs
+	DailyLinkQuota int       `gorm:"not null;default:0"` // 0 means unlimited
+	CreatedAt      time.Time `gorm:"autoCreateTime"`
+}
+
+// TableName specifies the table name for Tenant
+func (Tenant) TableName() string {
+	return "tenants"
+}
+
+// AdminIDs returns the user IDs of the tenant admins
+func (t *Tenant) AdminIDs() []int64 {
+	var ids []int64
+	for _, field := range strings.Split(t.Admins, ",") {
+		if id, err := strconv.ParseInt(strings.TrimSpace(field), 10, 64); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// IsAdmin reports whether the user is an admin of the tenant
+func (t *Tenant) IsAdmin(userID int64) bool {
+	for _, id := range t.AdminIDs() {
+		if id == userID {
+			return true
+		}
+	}
+	return false
+}
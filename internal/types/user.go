@@ -0,0 +1,46 @@
+package types
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// User represents a Telegram user who interacted with the bot
+type User struct {
+	ID             int64 `gorm:"primaryKey;autoIncrement:false"`
+	Username       string
+	FirstName      string
+	Flagged        bool `gorm:"index;not null;default:false"`
+	FlagReason     string
+	FlaggedAt      *time.Time
+	Suspended      bool  `gorm:"not null;default:false"`
+	TenantID       uint  `gorm:"index;not null;default:0"` // tenant joined with an invite code, 0 for none
+	ProfileID      int64 `gorm:"index;not null;default:0"` // account whose player profile this account was linked to, 0 for its own
+	InvitedBy      int64 `gorm:"index;not null;default:0"` // user who vouched for this user with /invite
+	InvitedAt      *time.Time
+	InvitesRevoked bool           `gorm:"not null;default:false"`       // a user invited by this user was flagged
+	Authorized     bool           `gorm:"index;not null;default:false"` // authorized by an admin with /authorize
+	AuthorizedTill *time.Time     // end of the authorization, nil if it doesn't expire
+	Referral       string         `gorm:"index"`                 // code of the ref_ deep link the user started the bot with
+	Onboarding     string         `gorm:"not null;default:''"`   // current onboarding step, empty if onboarding never started
+	LiveViews      bool           `gorm:"not null;default:true"` // update the view count in the link replies
+	ReuseLinks     bool           `gorm:"not null;default:true"` // reply to files sent again with their existing link
+	LastSeen       *time.Time     `gorm:"index"`                 // last command, player connection or stream of one of the user's links
+	TokenVersion   int            `gorm:"not null;default:0"`    // version of the WebDAV passwords, feed and Kodi tokens signed for the user, bumped to revoke them
+	CreatedAt      time.Time      `gorm:"autoCreateTime"`
+	UpdatedAt      time.Time      `gorm:"autoUpdateTime"`
+	DeletedAt      gorm.DeletedAt `gorm:"index"` // removed with /removeuser, purged after USER_RETENTION_DAYS
+}
+
+// ReferralStats counts the users acquired through a referral code
+type ReferralStats struct {
+	Code   string `json:"code"`
+	Users  int64  `json:"users"`
+	Active int64  `json:"active"` // users who generated at least one link
+}
+
+// TableName specifies the table name for User
+func (User) TableName() string {
+	return "users"
+}
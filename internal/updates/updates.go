@@ -0,0 +1,89 @@
+// Package updates checks the release feed of the project for new versions of the bot
+package updates
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/version"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// excerptLength is the maximum length of the changelog excerpt sent to admins
+const excerptLength = 1000
+
+// Release is a release of the bot, as described by the GitHub releases API
+type Release struct {
+	Tag       string    `json:"tag_name"`
+	Name      string    `json:"name"`
+	Body      string    `json:"body"`
+	URL       string    `json:"html_url"`
+	Published time.Time `json:"published_at"`
+}
+
+// Newer reports whether the release is newer than the running version
+func (r *Release) Newer() bool {
+	return version.Newer(r.Tag)
+}
+
+// Excerpt returns the beginning of the changelog of the release
+func (r *Release) Excerpt() string {
+	body := strings.TrimSpace(strings.ReplaceAll(r.Body, "\r\n", "\n"))
+	if len(body) <= excerptLength {
+		return body
+	}
+	cut := strings.LastIndex(body[:excerptLength], "\n")
+	if cut <= 0 {
+		cut = excerptLength
+	}
+	return body[:cut] + "\n…"
+}
+
+var latest = struct {
+	sync.RWMutex
+	release *Release
+	checked time.Time
+}{}
+
+// Check fetches the latest release from UPDATE_FEED
+func Check(ctx context.Context) (*Release, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, config.ValueOf.UpdateFeed, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("User-Agent", "fsb/"+version.Version)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("release feed answered with %s", resp.Status)
+	}
+	var release Release
+	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
+		return nil, fmt.Errorf("invalid release feed: %w", err)
+	}
+	if release.Tag == "" {
+		return nil, fmt.Errorf("release feed has no tag_name")
+	}
+	latest.Lock()
+	latest.release = &release
+	latest.checked = time.Now()
+	latest.Unlock()
+	return &release, nil
+}
+
+// Latest returns the release found by the last check and when it ran, or nil if nothing was checked yet
+func Latest() (*Release, time.Time) {
+	latest.RLock()
+	defer latest.RUnlock()
+	return latest.release, latest.checked
+}
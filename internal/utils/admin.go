@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"EverythingSuckz/fsb/config"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/celestix/gotgproto/storage"
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+	"go.uber.org/zap"
+)
+
+// adminCacheTTL is how long the admin status fetched from ADMIN_CHAT is trusted
+const adminCacheTTL = 10 * time.Minute
+
+type adminStatus struct {
+	isAdmin   bool
+	expiresAt time.Time
+}
+
+var (
+	adminCache   = make(map[int64]adminStatus)
+	adminCacheMu sync.RWMutex
+)
+
+// IsAdmin reports whether the user is a bot admin, either because they are listed
+// in ADMINS or because they are an admin of ADMIN_CHAT.
+func IsAdmin(ctx context.Context, client *tg.Client, peerStorage *storage.PeerStorage, userID int64) bool {
+	if Contains(config.Runtime.Admins(), userID) {
+		return true
+	}
+	if config.ValueOf.AdminChatID == 0 {
+		return false
+	}
+	adminCacheMu.RLock()
+	status, ok := adminCache[userID]
+	adminCacheMu.RUnlock()
+	if ok && time.Now().Before(status.expiresAt) {
+		return status.isAdmin
+	}
+	isAdmin, err := isChatAdmin(ctx, client, peerStorage, userID)
+	if err != nil {
+		Logger.Error("Error checking admin chat membership",
+			zap.Error(err),
+			zap.Int64("userID", userID),
+			zap.Int64("chatID", config.ValueOf.AdminChatID))
+		return false
+	}
+	adminCacheMu.Lock()
+	adminCache[userID] = adminStatus{isAdmin: isAdmin, expiresAt: time.Now().Add(adminCacheTTL)}
+	adminCacheMu.Unlock()
+	return isAdmin
+}
+
+func isChatAdmin(ctx context.Context, client *tg.Client, peerStorage *storage.PeerStorage, userID int64) (bool, error) {
+	channel, err := GetChannelPeer(ctx, client, peerStorage, config.ValueOf.AdminChatID)
+	if err != nil {
+		return false, err
+	}
+	var participant tg.InputPeerClass = &tg.InputPeerUser{UserID: userID}
+	if peer := peerStorage.GetInputPeerById(userID); !peer.Zero() {
+		participant = peer
+	}
+	res, err := client.ChannelsGetParticipant(ctx, &tg.ChannelsGetParticipantRequest{
+		Channel:     channel,
+		Participant: participant,
+	})
+	if err != nil {
+		if tgerr.Is(err, "USER_NOT_PARTICIPANT", "PARTICIPANT_NOT_EXIST") {
+			return false, nil
+		}
+		return false, err
+	}
+	switch res.Participant.(type) {
+	case *tg.ChannelParticipantAdmin, *tg.ChannelParticipantCreator:
+		return true, nil
+	}
+	return false, nil
+}
@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"EverythingSuckz/fsb/config"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ClientCountry returns the country code the reverse proxy put in the COUNTRY_HEADER of the
+// request, eg. CF-IPCountry of Cloudflare. It's empty when the header isn't configured, the
+// country is unknown or the request didn't come from one of TRUSTED_PROXIES, as anyone could
+// claim any country otherwise.
+func ClientCountry(r *http.Request) string {
+	if config.ValueOf.CountryHeader == "" || !fromTrustedProxy(r.RemoteAddr) {
+		return ""
+	}
+	country := strings.ToUpper(strings.TrimSpace(r.Header.Get(config.ValueOf.CountryHeader)))
+	// Cloudflare sends XX when it doesn't know the country
+	if len(country) != 2 || country == "XX" {
+		return ""
+	}
+	return country
+}
+
+// fromTrustedProxy reports whether the address is one of the IPs or CIDR ranges of TRUSTED_PROXIES
+func fromTrustedProxy(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, proxy := range config.ValueOf.TrustedProxies {
+		if _, network, err := net.ParseCIDR(proxy); err == nil {
+			if network.Contains(ip) {
+				return true
+			}
+		} else if proxyIP := net.ParseIP(proxy); proxyIP != nil && proxyIP.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,29 @@
+package utils
+
+import (
+	"EverythingSuckz/fsb/config"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientCountryIsOnlyTakenFromTrustedProxies(t *testing.T) {
+	config.ValueOf.CountryHeader = "CF-IPCountry"
+	config.ValueOf.TrustedProxies = []string{"10.0.0.0/8", "192.168.1.1"}
+	t.Cleanup(func() { config.ValueOf.CountryHeader, config.ValueOf.TrustedProxies = "", nil })
+	for _, test := range []struct {
+		remoteAddr, header, country string
+	}{
+		{"10.1.2.3:4000", "de", "DE"},
+		{"192.168.1.1:4000", "BR", "BR"},
+		{"203.0.113.9:4000", "DE", ""},
+		{"10.1.2.3:4000", "XX", ""},
+		{"10.1.2.3:4000", "", ""},
+	} {
+		r := httptest.NewRequest("GET", "/stream/1", nil)
+		r.RemoteAddr = test.remoteAddr
+		r.Header.Set("CF-IPCountry", test.header)
+		if country := ClientCountry(r); country != test.country {
+			t.Errorf("%s sent %q: got country %q, expected %q", test.remoteAddr, test.header, country, test.country)
+		}
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !linux && !darwin && !freebsd && !windows
+
+package utils
+
+// DiskSpace isn't known on this platform, SAVE_MIN_FREE and DISK_MIN_FREE aren't checked
+func DiskSpace(dir string) (free int64, total int64, ok bool) {
+	return 0, 0, false
+}
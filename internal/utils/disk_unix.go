@@ -0,0 +1,14 @@
+//go:build linux || darwin || freebsd
+
+package utils
+
+import "golang.org/x/sys/unix"
+
+// DiskSpace returns the bytes available to the bot and the size of the disk of the directory
+func DiskSpace(dir string) (free int64, total int64, ok bool) {
+	var stat unix.Statfs_t
+	if err := unix.Statfs(dir, &stat); err != nil {
+		return 0, 0, false
+	}
+	return int64(stat.Bavail) * int64(stat.Bsize), int64(stat.Blocks) * int64(stat.Bsize), true
+}
@@ -0,0 +1,16 @@
+package utils
+
+import "golang.org/x/sys/windows"
+
+// DiskSpace returns the bytes available to the bot and the size of the disk of the directory
+func DiskSpace(dir string) (free int64, total int64, ok bool) {
+	path, err := windows.UTF16PtrFromString(dir)
+	if err != nil {
+		return 0, 0, false
+	}
+	var available, size, unused uint64
+	if err := windows.GetDiskFreeSpaceEx(path, &available, &size, &unused); err != nil {
+		return 0, 0, false
+	}
+	return int64(available), int64(size), true
+}
@@ -0,0 +1,184 @@
+package utils
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/types"
+	"fmt"
+	"mime"
+	"path"
+	"strings"
+	"sync"
+	"text/template"
+	"unicode"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+	"golang.org/x/text/unicode/norm"
+)
+
+// maxFileName is the length of file names in bytes, most file systems allow 255
+const maxFileName = 200
+
+// mimeExtensions are the extensions of common MIME types, mime.ExtensionsByType returns
+// them in alphabetical order, which isn't always the usual one
+var mimeExtensions = map[string]string{
+	"video/mp4":          ".mp4",
+	"video/x-matroska":   ".mkv",
+	"video/webm":         ".webm",
+	"video/quicktime":    ".mov",
+	"audio/mpeg":         ".mp3",
+	"audio/mp4":          ".m4a",
+	"audio/ogg":          ".ogg",
+	"audio/flac":         ".flac",
+	"image/jpeg":         ".jpg",
+	"image/png":          ".png",
+	"application/pdf":    ".pdf",
+	"application/zip":    ".zip",
+	"application/x-rar":  ".rar",
+	"application/x-tar":  ".tar",
+	"text/plain":         ".txt",
+	"application/x-gzip": ".gz",
+}
+
+// windowsReserved are the names Windows doesn't allow for files, with any extension
+var windowsReserved = []string{"CON", "PRN", "AUX", "NUL",
+	"COM1", "COM2", "COM3", "COM4", "COM5", "COM6", "COM7", "COM8", "COM9",
+	"LPT1", "LPT2", "LPT3", "LPT4", "LPT5", "LPT6", "LPT7", "LPT8", "LPT9"}
+
+var (
+	nameTemplateOnce sync.Once
+	nameTemplate     *template.Template
+)
+
+// FileNameData are the fields of FILE_NAME_TEMPLATE
+type FileNameData struct {
+	FileName  string // name of the sent file
+	Base      string // the name without extension
+	Ext       string // the extension with its dot, the one of the MIME type is added if the name has none
+	Title     string // audio tags
+	Performer string
+	MessageID int
+}
+
+// SanitizeFileName makes a file name sent by a user safe for headers, archives and disks:
+// it's normalized to NFC, folders are stripped, control and invisible characters like
+// right-to-left overrides are removed, characters Windows doesn't allow are replaced and
+// the extension of the MIME type is added if it has none. It returns an empty string if
+// nothing is left.
+func SanitizeFileName(name string, mimeType string) string {
+	name = norm.NFC.String(name)
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+	name = strings.Map(func(r rune) rune {
+		switch r {
+		case ':', '*', '?', '"', '<', '>', '|':
+			return '_'
+		}
+		if unicode.IsControl(r) || unicode.Is(unicode.Cf, r) || r == utf8.RuneError {
+			return -1
+		}
+		if unicode.IsSpace(r) {
+			return ' '
+		}
+		return r
+	}, name)
+	// leading dots hide files, Windows drops trailing ones
+	name = strings.TrimRight(strings.TrimLeft(name, ". "), ". ")
+	if name == "" {
+		return ""
+	}
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for _, reserved := range windowsReserved {
+		if strings.EqualFold(base, reserved) {
+			base = "_" + base
+		}
+	}
+	if ext == "" {
+		ext = mimeExtension(mimeType)
+	}
+	for len(base)+len(ext) > maxFileName && base != "" {
+		_, size := utf8.DecodeLastRuneInString(base)
+		base = base[:len(base)-size]
+	}
+	return strings.TrimRight(base, ". ") + ext
+}
+
+// mimeExtension returns the usual extension of the MIME type, or an empty string
+func mimeExtension(mimeType string) string {
+	mimeType, _, _ = strings.Cut(mimeType, ";")
+	if ext, ok := mimeExtensions[mimeType]; ok {
+		return ext
+	}
+	if exts, err := mime.ExtensionsByType(mimeType); err == nil && len(exts) > 0 {
+		return exts[0]
+	}
+	return ""
+}
+
+// FileName returns the name the file is served, exported and saved with: FILE_NAME_TEMPLATE
+// rendered with the fields of the file, or its own name if the template uses a field the
+// file doesn't have, sanitized with SanitizeFileName. Files without a name are named after
+// their message ID.
+func FileName(file *types.File, messageID int) string {
+	name := file.FileName
+	if rendered, ok := renderFileName(file, messageID); ok {
+		name = rendered
+	}
+	if sanitized := SanitizeFileName(name, file.MimeType); sanitized != "" {
+		return sanitized
+	}
+	return SanitizeFileName(fmt.Sprintf("file-%d", messageID), file.MimeType)
+}
+
+// LinkFileName is FileName for the file of a link
+func LinkFileName(link *types.Link) string {
+	return FileName(&types.File{
+		FileName:  link.FileName,
+		FileSize:  link.FileSize,
+		MimeType:  link.MimeType,
+		Title:     link.Title,
+		Performer: link.Performer,
+	}, link.MessageID)
+}
+
+// renderFileName renders FILE_NAME_TEMPLATE. ok is false without a template or if it uses
+// a field that's empty, so that files without audio tags keep their names.
+func renderFileName(file *types.File, messageID int) (string, bool) {
+	nameTemplateOnce.Do(func() {
+		if config.ValueOf.FileNameTemplate == "" {
+			return
+		}
+		var err error
+		// checked when the config is loaded
+		nameTemplate, err = template.New("name").Parse(config.ValueOf.FileNameTemplate)
+		if err != nil {
+			Logger.Error("Invalid FILE_NAME_TEMPLATE", zap.Error(err))
+		}
+	})
+	if nameTemplate == nil {
+		return "", false
+	}
+	// empty fields are rendered as a NUL character, which no file name has
+	orEmpty := func(value string) string {
+		if value == "" {
+			return "\x00"
+		}
+		return value
+	}
+	ext := path.Ext(file.FileName)
+	data := FileNameData{
+		FileName:  orEmpty(file.FileName),
+		Base:      orEmpty(strings.TrimSuffix(file.FileName, ext)),
+		Ext:       ext, // added from the MIME type if it's missing
+		Title:     orEmpty(file.Title),
+		Performer: orEmpty(file.Performer),
+		MessageID: messageID,
+	}
+	var name strings.Builder
+	if err := nameTemplate.Execute(&name, data); err != nil || strings.Contains(name.String(), "\x00") {
+		return "", false
+	}
+	return name.String(), true
+}
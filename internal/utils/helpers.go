@@ -26,9 +26,15 @@ func Contains[T comparable](s []T, e T) bool {
 	return false
 }
 
-func GetTGMessage(ctx context.Context, client *gotgproto.Client, messageID int) (*tg.Message, error) {
+// ErrMessageDeleted is returned for messages that were deleted from the log channel
+var ErrMessageDeleted = errors.New("This File was Deleted, either by an admin or after 24 hours had passed. For more updates, join @haris_garage ")
+
+// ErrChannelUnresolved is returned for channels the client can't find or isn't a member of
+var ErrChannelUnresolved = errors.New("the channel could not be resolved")
+
+func GetTGMessage(ctx context.Context, client *gotgproto.Client, channelID int64, messageID int) (*tg.Message, error) {
 	inputMessageID := tg.InputMessageClass(&tg.InputMessageID{ID: messageID})
-	channel, err := GetLogChannelPeer(ctx, client.API(), client.PeerStorage)
+	channel, err := GetChannelPeer(ctx, client.API(), client.PeerStorage, channelID)
 	if err != nil {
 		return nil, err
 	}
@@ -42,7 +48,7 @@ func GetTGMessage(ctx context.Context, client *gotgproto.Client, messageID int)
 	if _, ok := message.(*tg.Message); ok {
 		return message.(*tg.Message), nil
 	} else {
-		return nil, fmt.Errorf("This File was Deleted, either by an admin or after 24 hours had passed. For more updates, join @haris_garage ")
+		return nil, ErrMessageDeleted
 	}
 }
 
@@ -53,20 +59,29 @@ func FileFromMedia(media tg.MessageMediaClass) (*types.File, error) {
 		if !ok {
 			return nil, fmt.Errorf("unexpected type %T", media)
 		}
-		var fileName string
-		for _, attribute := range document.Attributes {
-			if name, ok := attribute.(*tg.DocumentAttributeFilename); ok {
-				fileName = name.FileName
-				break
-			}
-		}
-		return &types.File{
+		file := &types.File{
 			Location: document.AsInputDocumentFileLocation(),
 			FileSize: document.Size,
-			FileName: fileName,
 			MimeType: document.MimeType,
 			ID:       document.ID,
-		}, nil
+		}
+		for _, attribute := range document.Attributes {
+			switch attribute := attribute.(type) {
+			case *tg.DocumentAttributeFilename:
+				file.FileName = attribute.FileName
+			case *tg.DocumentAttributeAudio:
+				file.Duration = attribute.Duration
+				file.Title = attribute.Title
+				file.Performer = attribute.Performer
+				file.Voice = attribute.Voice
+			case *tg.DocumentAttributeVideo:
+				file.Duration = int(attribute.Duration)
+				file.Voice = file.Voice || attribute.RoundMessage
+			case *tg.DocumentAttributeAnimated:
+				file.Animated = true
+			}
+		}
+		return file, nil
 	case *tg.MessageMediaPhoto:
 		photo, ok := media.Photo.AsNotEmpty()
 		if !ok {
@@ -97,8 +112,8 @@ func FileFromMedia(media tg.MessageMediaClass) (*types.File, error) {
 	return nil, fmt.Errorf("unexpected type %T", media)
 }
 
-func FileFromMessage(ctx context.Context, client *gotgproto.Client, messageID int) (*types.File, error) {
-	key := fmt.Sprintf("file:%d:%d", messageID, client.Self.ID)
+func FileFromMessage(ctx context.Context, client *gotgproto.Client, channelID int64, messageID int) (*types.File, error) {
+	key := fmt.Sprintf("file:%d:%d:%d", channelID, messageID, client.Self.ID)
 	log := Logger.Named("GetMessageMedia")
 	var cachedMedia types.File
 	err := cache.GetCache().Get(key, &cachedMedia)
@@ -107,7 +122,7 @@ func FileFromMessage(ctx context.Context, client *gotgproto.Client, messageID in
 		return &cachedMedia, nil
 	}
 	log.Debug("Fetching file properties from message ID", zap.Int("messageID", messageID), zap.Int64("clientID", client.Self.ID))
-	message, err := GetTGMessage(ctx, client, messageID)
+	message, err := GetTGMessage(ctx, client, channelID, messageID)
 	if err != nil {
 		return nil, err
 	}
@@ -127,7 +142,11 @@ func FileFromMessage(ctx context.Context, client *gotgproto.Client, messageID in
 }
 
 func GetLogChannelPeer(ctx context.Context, api *tg.Client, peerStorage *storage.PeerStorage) (*tg.InputChannel, error) {
-	cachedInputPeer := peerStorage.GetInputPeerById(config.ValueOf.LogChannelID)
+	return GetChannelPeer(ctx, api, peerStorage, config.Runtime.LogChannelID())
+}
+
+func GetChannelPeer(ctx context.Context, api *tg.Client, peerStorage *storage.PeerStorage, channelID int64) (*tg.InputChannel, error) {
+	cachedInputPeer := peerStorage.GetInputPeerById(channelID)
 
 	switch peer := cachedInputPeer.(type) {
 	case *tg.InputPeerEmpty:
@@ -138,21 +157,21 @@ func GetLogChannelPeer(ctx context.Context, api *tg.Client, peerStorage *storage
 			AccessHash: peer.AccessHash,
 		}, nil
 	default:
-		return nil, errors.New("unexpected type of input peer")
+		return nil, fmt.Errorf("%w: unexpected type of input peer", ErrChannelUnresolved)
 	}
 	inputChannel := &tg.InputChannel{
-		ChannelID: config.ValueOf.LogChannelID,
+		ChannelID: channelID,
 	}
 	channels, err := api.ChannelsGetChannels(ctx, []tg.InputChannelClass{inputChannel})
 	if err != nil {
 		return nil, err
 	}
 	if len(channels.GetChats()) == 0 {
-		return nil, errors.New("no channels found")
+		return nil, fmt.Errorf("%w: no channels found", ErrChannelUnresolved)
 	}
 	channel, ok := channels.GetChats()[0].(*tg.Channel)
 	if !ok {
-		return nil, errors.New("type assertion to *tg.Channel failed")
+		return nil, fmt.Errorf("%w: type assertion to *tg.Channel failed", ErrChannelUnresolved)
 	}
 	// Bruh, I literally have to call library internal functions at this point
 	peerStorage.AddPeer(channel.GetID(), channel.AccessHash, storage.TypeChannel, "")
@@ -160,19 +179,30 @@ func GetLogChannelPeer(ctx context.Context, api *tg.Client, peerStorage *storage
 }
 
 func ForwardMessages(ctx *ext.Context, fromChatId, toChatId int64, messageID int) (*tg.Updates, error) {
+	return forwardMessages(ctx, fromChatId, toChatId, messageID, false)
+}
+
+// CopyMessage copies the message to the channel without the forward header, so the
+// copy doesn't refer to the sender or their chat
+func CopyMessage(ctx *ext.Context, fromChatId, toChatId int64, messageID int) (*tg.Updates, error) {
+	return forwardMessages(ctx, fromChatId, toChatId, messageID, true)
+}
+
+func forwardMessages(ctx *ext.Context, fromChatId, toChatId int64, messageID int, dropAuthor bool) (*tg.Updates, error) {
 	fromPeer := ctx.PeerStorage.GetInputPeerById(fromChatId)
 	if fromPeer.Zero() {
 		return nil, fmt.Errorf("fromChatId: %d is not a valid peer", fromChatId)
 	}
-	toPeer, err := GetLogChannelPeer(ctx, ctx.Raw, ctx.PeerStorage)
+	toPeer, err := GetChannelPeer(ctx, ctx.Raw, ctx.PeerStorage, toChatId)
 	if err != nil {
 		return nil, err
 	}
 	update, err := ctx.Raw.MessagesForwardMessages(ctx, &tg.MessagesForwardMessagesRequest{
-		RandomID: []int64{rand.Int63()},
-		FromPeer: fromPeer,
-		ID:       []int{messageID},
-		ToPeer:   &tg.InputPeerChannel{ChannelID: toPeer.ChannelID, AccessHash: toPeer.AccessHash},
+		RandomID:   []int64{rand.Int63()},
+		FromPeer:   fromPeer,
+		ID:         []int{messageID},
+		ToPeer:     &tg.InputPeerChannel{ChannelID: toPeer.ChannelID, AccessHash: toPeer.AccessHash},
+		DropAuthor: dropAuthor,
 	})
 	if err != nil {
 		return nil, err
@@ -0,0 +1,16 @@
+package utils
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// InstanceID identifies the running process, so that the bot can recognize
+// its own server when it fetches /healthz through the public URL
+var InstanceID = newInstanceID()
+
+func newInstanceID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
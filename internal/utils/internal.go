@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/tenant"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+)
+
+// InternalHeader marks the requests the bot makes to its own stream endpoint (eg. from ffmpeg)
+// so that they aren't counted as views.
+const InternalHeader = "X-FSB-Internal"
+
+// InternalToken is the value of InternalHeader, generated on every start
+var InternalToken = newInternalToken()
+
+func newInternalToken() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// internalAddress is the loopback address the server can be reached at over plain HTTP,
+// it's derived from PORT unless the server listens on a unix or systemd socket or TLS
+var internalAddress string
+
+// SetInternalAddress sets the loopback address the server can be reached at
+func SetInternalAddress(address string) {
+	internalAddress = address
+}
+
+// InternalStreamURL returns the stream link of a message in the log channel of the tenant on the local server
+func InternalStreamURL(tenantID uint, messageID int, hash string) string {
+	address := internalAddress
+	if address == "" {
+		address = fmt.Sprintf("127.0.0.1:%d", config.ValueOf.Port)
+	}
+	return fmt.Sprintf("http://%s%s%s/stream/%d?hash=%s", address, config.ValueOf.BasePath, tenant.Path(tenantID), messageID, hash)
+}
+
+// IsInternalRequest reports whether the request was made by the bot itself
+func IsInternalRequest(r *http.Request) bool {
+	token := r.Header.Get(InternalHeader)
+	return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(InternalToken)) == 1
+}
@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"EverythingSuckz/fsb/config"
+	"encoding/base64"
+	"fmt"
+	neturl "net/url"
+	"strings"
+)
+
+// OpenInPlayers are the native players OPEN_IN_PLAYERS can list
+var OpenInPlayers = []string{"vlc", "mpv", "iina", "android"}
+
+// PlayerLink opens a stream in a native player
+type PlayerLink struct {
+	Name string
+	URL  string
+}
+
+// OpenInLinks returns the links that hand the stream over to the native players of
+// OPEN_IN_PLAYERS, none if it's none
+func OpenInLinks(streamURL string, mimeType string) []PlayerLink {
+	links := []PlayerLink{}
+	for _, player := range config.ValueOf.OpenInPlayers {
+		switch strings.TrimSpace(player) {
+		case "vlc":
+			// VLC for Android and iOS register vlc://, on desktops it needs a handler
+			links = append(links, PlayerLink{Name: "VLC", URL: "vlc://" + streamURL})
+		case "mpv":
+			// the scheme of mpv-handler, https://github.com/akiirui/mpv-handler
+			links = append(links, PlayerLink{Name: "mpv", URL: "mpv-handler://play/" + base64.RawURLEncoding.EncodeToString([]byte(streamURL))})
+		case "iina":
+			links = append(links, PlayerLink{Name: "IINA", URL: "iina://weblink?url=" + neturl.QueryEscape(streamURL)})
+		case "android":
+			// lets Android ask which installed player to open it in
+			scheme, rest, _ := strings.Cut(streamURL, "://")
+			if mimeType == "" {
+				mimeType = "video/*"
+			}
+			links = append(links, PlayerLink{Name: "Android", URL: fmt.Sprintf("intent://%s#Intent;scheme=%s;action=android.intent.action.VIEW;type=%s;end", rest, scheme, mimeType)})
+		}
+	}
+	return links
+}
+
+// OpenInURL returns the link of the page with the links of OpenInLinks for a stream link
+func OpenInURL(tenantID uint, messageID int, hash string) string {
+	return TenantURL(tenantID, fmt.Sprintf("/open-in/%d?hash=%s", messageID, hash))
+}
@@ -1,19 +1,53 @@
 package utils
 
 import (
+	"EverythingSuckz/fsb/config"
 	"context"
+	"expvar"
 	"fmt"
 	"io"
+	"strconv"
+	"sync/atomic"
+	"time"
 
-	"github.com/celestix/gotgproto"
 	"github.com/gotd/td/tg"
 	"go.uber.org/zap"
 )
 
+// slowClientGrace is how long a stream runs before the speed of its client is judged
+const slowClientGrace = 10 * time.Second
+
+// streamChunkSize is the size of the parts fetched from Telegram
+const streamChunkSize = 1024 * 1024
+
+// StreamMemory returns the most memory a stream reader holds, the parts fetched ahead and the one being read
+func StreamMemory() int64 {
+	return int64(max(config.ValueOf.StreamReadAhead, 0)+1) * streamChunkSize
+}
+
+// Buffers of the streams, published at /debug/vars
+var (
+	// bufferedBytes is the memory held by the parts all streams fetched ahead of their clients
+	bufferedBytes = expvar.NewInt("stream_buffered_bytes")
+	// bufferHighWater holds the most memory every running stream held at once, by stream
+	bufferHighWater = expvar.NewMap("stream_buffer_high_water")
+	// slowStreams counts the streams whose read-ahead was shrunk for a slow client
+	slowStreams = expvar.NewInt("slow_streams")
+)
+
+// lastStreamID identifies the stream readers in bufferHighWater
+var lastStreamID atomic.Uint64
+
+// fetchedPart is a part of the file fetched ahead of the client
+type fetchedPart struct {
+	data []byte
+	err  error
+}
+
 type telegramReader struct {
 	ctx           context.Context
 	log           *zap.Logger
-	client        *gotgproto.Client
+	client        *tg.Client
 	location      tg.InputFileLocationClass
 	start         int64
 	end           int64
@@ -23,15 +57,35 @@ type telegramReader struct {
 	chunkSize     int64
 	i             int64
 	contentLength int64
+
+	// set for stream readers, which fetch parts ahead of the client
+	cancel    context.CancelFunc
+	parts     chan fetchedPart
+	taken     chan struct{} // tells the fetcher that the client took a part
+	slow      atomic.Bool   // the client reads slower than SLOW_CLIENT_RATE, only one part is fetched ahead
+	started   time.Time
+	buffered  atomic.Int64
+	highWater *expvar.Int
+	id        string
 }
 
-func (*telegramReader) Close() error {
+func (r *telegramReader) Close() error {
+	if r.parts == nil {
+		return nil
+	}
+	r.cancel()
+	for part := range r.parts {
+		r.release(int64(len(part.data)))
+	}
+	r.release(int64(len(r.buffer)))
+	r.buffer = nil
+	bufferHighWater.Delete(r.id)
 	return nil
 }
 
 func NewTelegramReader(
 	ctx context.Context,
-	client *gotgproto.Client,
+	client *tg.Client,
 	location tg.InputFileLocationClass,
 	start int64,
 	end int64,
@@ -45,7 +99,7 @@ func NewTelegramReader(
 		client:        client,
 		start:         start,
 		end:           end,
-		chunkSize:     int64(1024 * 1024),
+		chunkSize:     int64(streamChunkSize),
 		contentLength: contentLength,
 	}
 	r.log.Sugar().Debug("Start")
@@ -53,6 +107,34 @@ func NewTelegramReader(
 	return r, nil
 }
 
+// NewStreamReader returns a reader of the file for a client, which fetches up to STREAM_READ_AHEAD
+// parts ahead while the client reads. Fetching pauses while the parts wait for the client, and
+// clients slower than SLOW_CLIENT_RATE only get one part fetched ahead, so slow clients don't make
+// the bot hold much memory. It has to be closed.
+func NewStreamReader(
+	ctx context.Context,
+	client *tg.Client,
+	location tg.InputFileLocationClass,
+	start int64,
+	end int64,
+	contentLength int64,
+) (io.ReadCloser, error) {
+	reader, err := NewTelegramReader(ctx, client, location, start, end, contentLength)
+	if err != nil || config.ValueOf.StreamReadAhead <= 0 {
+		return reader, err
+	}
+	r := reader.(*telegramReader)
+	r.ctx, r.cancel = context.WithCancel(ctx)
+	r.parts = make(chan fetchedPart, config.ValueOf.StreamReadAhead)
+	r.taken = make(chan struct{}, 1)
+	r.started = time.Now()
+	r.id = strconv.FormatUint(lastStreamID.Add(1), 10)
+	r.highWater = new(expvar.Int)
+	bufferHighWater.Set(r.id, r.highWater)
+	go r.prefetch()
+	return r, nil
+}
+
 func (r *telegramReader) Read(p []byte) (n int, err error) {
 
 	if r.bytesread == r.contentLength {
@@ -61,6 +143,9 @@ func (r *telegramReader) Read(p []byte) (n int, err error) {
 	}
 
 	if r.i >= int64(len(r.buffer)) {
+		if r.parts != nil {
+			return r.readFetched(p)
+		}
 		r.buffer, err = r.next()
 		r.log.Debug("Next Buffer", zap.Int64("len", int64(len(r.buffer))))
 		if err != nil {
@@ -90,7 +175,7 @@ func (r *telegramReader) chunk(offset int64, limit int64) ([]byte, error) {
 		Location: r.location,
 	}
 
-	res, err := r.client.API().UploadGetFile(r.ctx, req)
+	res, err := r.client.UploadGetFile(r.ctx, req)
 
 	if err != nil {
 		return nil, err
@@ -140,3 +225,93 @@ func (r *telegramReader) partStream() func() ([]byte, error) {
 	}
 	return readData
 }
+
+// readFetched continues reading with the next part fetched ahead
+func (r *telegramReader) readFetched(p []byte) (int, error) {
+	r.release(int64(len(r.buffer)))
+	r.buffer = nil
+	part, ok := <-r.parts
+	if !ok {
+		if err := r.ctx.Err(); err != nil {
+			return 0, err
+		}
+		return 0, io.ErrUnexpectedEOF
+	}
+	select {
+	case r.taken <- struct{}{}:
+	default:
+	}
+	if part.err != nil {
+		r.release(int64(len(part.data)))
+		return 0, part.err
+	}
+	r.buffer, r.i = part.data, 0
+	r.checkSpeed()
+	n := copy(p, r.buffer)
+	r.i += int64(n)
+	r.bytesread += int64(n)
+	return n, nil
+}
+
+// prefetch fetches the parts of the file until all of them were fetched, the reader is closed or
+// fetching one fails. The parts channel limits how far it gets ahead of the client.
+func (r *telegramReader) prefetch() {
+	defer close(r.parts)
+	for fetched := int64(0); fetched < r.contentLength; {
+		// a slow client gets the next part only once it took the previous one
+		for r.slow.Load() && len(r.parts) > 0 {
+			select {
+			case <-r.taken:
+			case <-r.ctx.Done():
+				return
+			}
+		}
+		data, err := r.next()
+		if err == nil && len(data) == 0 {
+			r.next = r.partStream()
+			data, err = r.next()
+		}
+		r.hold(int64(len(data)))
+		select {
+		case r.parts <- fetchedPart{data: data, err: err}:
+		case <-r.ctx.Done():
+			r.release(int64(len(data)))
+			return
+		}
+		if err != nil || len(data) == 0 {
+			return
+		}
+		fetched += int64(len(data))
+	}
+}
+
+// checkSpeed shrinks the read-ahead once the client turns out to read slower than SLOW_CLIENT_RATE
+func (r *telegramReader) checkSpeed() {
+	elapsed := time.Since(r.started)
+	rate := int64(config.ValueOf.SlowClientRate)
+	if r.slow.Load() || rate <= 0 || elapsed < slowClientGrace {
+		return
+	}
+	if float64(r.bytesread)/elapsed.Seconds() < float64(rate) {
+		r.slow.Store(true)
+		slowStreams.Add(1)
+		r.log.Debug("Slow client, shrinking read-ahead", zap.Int64("bytesRead", r.bytesread), zap.Duration("elapsed", elapsed))
+	}
+}
+
+// hold accounts for the memory of a fetched part
+func (r *telegramReader) hold(size int64) {
+	bufferedBytes.Add(size)
+	if buffered := r.buffered.Add(size); buffered > r.highWater.Value() {
+		r.highWater.Set(buffered)
+	}
+}
+
+// release accounts for a part that isn't held anymore
+func (r *telegramReader) release(size int64) {
+	if size == 0 {
+		return
+	}
+	bufferedBytes.Add(-size)
+	r.buffered.Add(-size)
+}
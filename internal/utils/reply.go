@@ -0,0 +1,99 @@
+package utils
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/tenant"
+	"EverythingSuckz/fsb/internal/types"
+	"fmt"
+	neturl "net/url"
+	"strings"
+
+	"github.com/gotd/td/tg"
+)
+
+// PublicURL returns the public link of a path served by the bot, including BASE_PATH
+func PublicURL(path string) string {
+	return config.Runtime.Host() + config.ValueOf.BasePath + path
+}
+
+// TenantURL returns the public link of a path served under the path of the tenant
+func TenantURL(tenantID uint, path string) string {
+	return PublicURL(tenant.Path(tenantID) + path)
+}
+
+// StreamURL returns the public stream link for a message in the log channel of the tenant
+func StreamURL(tenantID uint, messageID int, hash string) string {
+	return TenantURL(tenantID, fmt.Sprintf("/stream/%d?hash=%s", messageID, hash))
+}
+
+// EmbedURL returns the link of the player other sites can embed in an iframe
+func EmbedURL(tenantID uint, messageID int, hash string) string {
+	return TenantURL(tenantID, fmt.Sprintf("/embed/%d/%s", messageID, hash))
+}
+
+// QRCodeURL returns the link of the QR code image of a stream link
+func QRCodeURL(tenantID uint, messageID int, hash string) string {
+	return TenantURL(tenantID, fmt.Sprintf("/qr/%d/%s.png", messageID, hash))
+}
+
+// LinkReply builds the text and the inline keyboard of the bot reply for a generated link.
+// The markup is nil for links that can't be opened from Telegram (localhost).
+func LinkReply(link *types.Link) (string, tg.ReplyMarkupClass) {
+	url := StreamURL(link.TenantID, link.MessageID, link.Hash)
+	message := fmt.Sprintf("📄 File Name: %s\n\n📥 Download Link:\n%s\n\n⏳ Link validity is 24 hours", link.FileName, url)
+	if link.Views > 0 {
+		message += fmt.Sprintf("\n\n👁 Views: %d", link.Views)
+		if link.LastAccess != nil {
+			message += "\n🕒 Last access: " + link.LastAccess.Format("2006-01-02 15:04:05")
+		}
+	}
+	if strings.Contains(url, "http://localhost") {
+		return message, nil
+	}
+	row := tg.KeyboardButtonRow{
+		Buttons: []tg.KeyboardButtonClass{
+			&tg.KeyboardButtonURL{
+				Text: "Download",
+				URL:  url + "&d=true",
+			},
+		},
+	}
+	// Add Stream button only for video files
+	if strings.Contains(link.MimeType, "video") {
+		streamURL := fmt.Sprintf("https://stream.hariharantelegram.workers.dev/?video=%s", url)
+		row.Buttons = append(row.Buttons, &tg.KeyboardButtonURL{
+			Text: "Stream",
+			URL:  streamURL,
+		})
+	}
+	extraRow := tg.KeyboardButtonRow{}
+	if strings.Contains(link.MimeType, "video") || strings.Contains(link.MimeType, "audio") {
+		playerPath := fmt.Sprintf("/player/%d?hash=%s", link.MessageID, link.Hash)
+		extraRow.Buttons = append(extraRow.Buttons, &tg.KeyboardButtonURL{
+			Text: "Player",
+			URL:  TenantURL(link.TenantID, playerPath),
+		})
+		// Telegram only opens Mini Apps over https
+		if strings.HasPrefix(url, "https://") {
+			next := config.ValueOf.BasePath + tenant.Path(link.TenantID) + playerPath
+			extraRow.Buttons = append(extraRow.Buttons, &tg.KeyboardButtonWebView{
+				Text: "Play in Telegram",
+				URL:  TenantURL(link.TenantID, "/webapp/open?next="+neturl.QueryEscape(next)),
+			})
+		}
+	}
+	// Telegram only opens http links from buttons, the page has the links of the players
+	if (strings.Contains(link.MimeType, "video") || strings.Contains(link.MimeType, "audio")) && len(OpenInLinks(url, link.MimeType)) > 0 {
+		extraRow.Buttons = append(extraRow.Buttons, &tg.KeyboardButtonURL{
+			Text: "Open in app",
+			URL:  OpenInURL(link.TenantID, link.MessageID, link.Hash),
+		})
+	}
+	extraRow.Buttons = append(extraRow.Buttons, &tg.KeyboardButtonURL{
+		Text: "QR",
+		URL:  QRCodeURL(link.TenantID, link.MessageID, link.Hash),
+	})
+	return message, &tg.ReplyInlineMarkup{
+		Rows: []tg.KeyboardButtonRow{row, extraRow},
+	}
+}
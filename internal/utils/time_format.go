@@ -3,6 +3,9 @@ package utils
 import (
 	"fmt"
 	"math/bits"
+	"strconv"
+	"strings"
+	"time"
 )
 
 func TimeFormat(seconds uint64) (timeStr string) {
@@ -40,3 +43,39 @@ func TimeFormat(seconds uint64) (timeStr string) {
 	}
 	return timeStr
 }
+
+// ParseTimestamp parses timestamps like 90, 1:30 or 01:02:03.5 into seconds
+func ParseTimestamp(timestamp string) (float64, error) {
+	parts := strings.Split(timestamp, ":")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("invalid timestamp: %s", timestamp)
+	}
+	var seconds float64
+	for _, part := range parts {
+		value, err := strconv.ParseFloat(part, 64)
+		if err != nil || value < 0 {
+			return 0, fmt.Errorf("invalid timestamp: %s", timestamp)
+		}
+		seconds = seconds*60 + value
+	}
+	return seconds, nil
+}
+
+// ParsePeriod parses periods like 30d, 2w or 12h. Days and weeks are supported
+// in addition to the units of time.ParseDuration.
+func ParsePeriod(period string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if value, ok := strings.CutSuffix(period, suffix); ok {
+			n, err := strconv.Atoi(value)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid period: %s", period)
+			}
+			return time.Duration(n) * unit, nil
+		}
+	}
+	duration, err := time.ParseDuration(period)
+	if err != nil || duration <= 0 {
+		return 0, fmt.Errorf("invalid period: %s", period)
+	}
+	return duration, nil
+}
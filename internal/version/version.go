@@ -0,0 +1,95 @@
+// Package version describes the running build of the bot
+package version
+
+import (
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Version is the released version of the bot
+const Version = "3.1.0"
+
+// Commit is the commit the bot was built from. It can be set with
+// -ldflags "-X EverythingSuckz/fsb/internal/version.Commit=<sha>", otherwise
+// it's read from the VCS information Go embeds in the binary.
+var Commit string
+
+var startTime = time.Now()
+
+// BuildCommit returns the commit the bot was built from, or "unknown"
+func BuildCommit() string {
+	if Commit != "" {
+		return Commit
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	var revision string
+	var modified bool
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.modified":
+			modified = setting.Value == "true"
+		}
+	}
+	if revision == "" {
+		return "unknown"
+	}
+	if len(revision) > 12 {
+		revision = revision[:12]
+	}
+	if modified {
+		revision += "-dirty"
+	}
+	return revision
+}
+
+// GoVersion returns the version of Go the bot was built with
+func GoVersion() string {
+	return runtime.Version()
+}
+
+// Uptime returns how long the bot is running
+func Uptime() time.Duration {
+	return time.Since(startTime)
+}
+
+// Newer reports whether the version, like v3.2.0 or 3.2.0, is newer than the running one
+func Newer(other string) bool {
+	theirs, ok := parse(other)
+	if !ok {
+		return false
+	}
+	ours, _ := parse(Version)
+	for i := range ours {
+		if theirs[i] != ours[i] {
+			return theirs[i] > ours[i]
+		}
+	}
+	return false
+}
+
+// parse parses the major, minor and patch numbers of a version, ignoring pre-release suffixes
+func parse(version string) ([3]int, bool) {
+	var numbers [3]int
+	version = strings.TrimPrefix(strings.TrimSpace(version), "v")
+	version, _, _ = strings.Cut(version, "-")
+	fields := strings.Split(version, ".")
+	if len(fields) == 0 || len(fields) > 3 {
+		return numbers, false
+	}
+	for i, field := range fields {
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 0 {
+			return numbers, false
+		}
+		numbers[i] = n
+	}
+	return numbers, true
+}
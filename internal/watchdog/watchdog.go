@@ -0,0 +1,157 @@
+// Package watchdog watches the free space of the disks of the generated media, the frames,
+// transcoded renditions and clips, and of SAVE_DIR. When one has less than DISK_MIN_FREE left,
+// the media used the longest time ago are removed, saving files with /save is paused while
+// SAVE_DIR stays low and ADMINS get a message, and another one once there's enough space again.
+package watchdog
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/alerts"
+	"EverythingSuckz/fsb/internal/crash"
+	"EverythingSuckz/fsb/internal/downloads"
+	"EverythingSuckz/fsb/internal/media"
+	"EverythingSuckz/fsb/internal/utils"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// checkInterval is how often the free space is checked
+const checkInterval = time.Minute
+
+// Disk is the space of the disk of a watched directory
+type Disk struct {
+	Name  string // media or saves
+	Path  string
+	Free  int64
+	Total int64
+	Low   bool // less than DISK_MIN_FREE is free
+}
+
+var (
+	log   = zap.NewNop()
+	mu    sync.Mutex
+	disks []Disk
+)
+
+// Start checks the free space now and then every minute
+func Start(l *zap.Logger) {
+	log = l.Named("watchdog")
+	check()
+	go func() {
+		ticker := time.NewTicker(checkInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			check()
+		}
+	}()
+}
+
+// Usage returns the disks of the watched directories as of the last check
+func Usage() []Disk {
+	mu.Lock()
+	defer mu.Unlock()
+	return append([]Disk{}, disks...)
+}
+
+// space returns the space of the disk of the directory, or of its closest parent that
+// exists, as the directories are only created when they're first needed
+func space(path string) (int64, int64, bool) {
+	for {
+		if free, total, ok := utils.DiskSpace(path); ok {
+			return free, total, true
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return 0, 0, false
+		}
+		path = parent
+	}
+}
+
+// measure returns the space of the watched directories
+func measure() []Disk {
+	watched := []Disk{{Name: "media", Path: media.Dir}}
+	if config.ValueOf.SaveDir != "" {
+		watched = append(watched, Disk{Name: "saves", Path: config.ValueOf.SaveDir})
+	}
+	minFree := int64(config.ValueOf.DiskMinFree)
+	measured := watched[:0]
+	for _, disk := range watched {
+		var ok bool
+		disk.Free, disk.Total, ok = space(disk.Path)
+		if !ok {
+			continue
+		}
+		disk.Low = minFree > 0 && disk.Free < minFree
+		measured = append(measured, disk)
+	}
+	return measured
+}
+
+func check() {
+	defer crash.Recover("watchdog")
+	measured := measure()
+	low := func() bool {
+		for _, disk := range measured {
+			if disk.Low {
+				return true
+			}
+		}
+		return false
+	}
+	// the generated media can be generated again, they're removed first
+	var evicted int64
+	if low() {
+		evicted = media.Evict(log, func() bool {
+			measured = measure()
+			return !low()
+		})
+		if evicted > 0 {
+			log.Info("Removed generated media to free disk space", zap.Int64("bytes", evicted))
+		}
+	}
+	mu.Lock()
+	previous := disks
+	disks = measured
+	mu.Unlock()
+	for _, disk := range measured {
+		wasLow := false
+		for _, before := range previous {
+			if before.Name == disk.Name {
+				wasLow = before.Low
+			}
+		}
+		if disk.Name == "saves" {
+			if disk.Low && !downloads.Paused() {
+				downloads.Pause()
+			} else if !disk.Low && downloads.Paused() {
+				downloads.Resume()
+			}
+		}
+		switch {
+		case disk.Low && !wasLow:
+			log.Warn("Disk space is low", zap.String("dir", disk.Path), zap.Int64("free", disk.Free))
+			message := fmt.Sprintf("💾 Only %s of %s are free on the disk of the %s (%s), less than DISK_MIN_FREE (%s).",
+				utils.FormatFileSizeShort(disk.Free), utils.FormatFileSizeShort(disk.Total), disk.Name, disk.Path,
+				utils.FormatFileSizeShort(int64(config.ValueOf.DiskMinFree)))
+			if evicted > 0 {
+				message += fmt.Sprintf(" Removed %s of generated media.", utils.FormatFileSizeShort(evicted))
+			}
+			if disk.Name == "saves" {
+				message += " Saving files with /save is paused until there's enough space again."
+			}
+			alerts.Admins(message)
+		case !disk.Low && wasLow:
+			log.Info("Disk space is back", zap.String("dir", disk.Path), zap.Int64("free", disk.Free))
+			message := fmt.Sprintf("✅ %s are free again on the disk of the %s.", utils.FormatFileSizeShort(disk.Free), disk.Name)
+			if disk.Name == "saves" {
+				message += " Saving files resumed."
+			}
+			alerts.Admins(message)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package web
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+)
+
+// iconSizes are the sizes of the app icons listed in the manifest
+var iconSizes = []int{192, 512}
+
+// drawIcon draws the default app icon, a white play button on the theme color
+func drawIcon(size int, background color.RGBA) ([]byte, error) {
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	white := color.RGBA{255, 255, 255, 255}
+	// triangle pointing right, centered and taking a bit less than half of the icon
+	left, right := float64(size)*0.36, float64(size)*0.70
+	top, bottom := float64(size)*0.28, float64(size)*0.72
+	middle := float64(size) / 2
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			img.SetRGBA(x, y, background)
+			fx, fy := float64(x)+0.5, float64(y)+0.5
+			if fx < left || fx > right || fy < top || fy > bottom {
+				continue
+			}
+			// the half height of the triangle shrinks linearly towards its tip
+			halfHeight := (middle - top) * (right - fx) / (right - left)
+			if fy >= middle-halfHeight && fy <= middle+halfHeight {
+				img.SetRGBA(x, y, white)
+			}
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// parseHexColor parses colors like #1e88e5
+func parseHexColor(value string) (color.RGBA, error) {
+	c := color.RGBA{A: 255}
+	_, err := fmt.Sscanf(value, "#%02x%02x%02x", &c.R, &c.G, &c.B)
+	if err != nil {
+		return c, fmt.Errorf("invalid color %q: %w", value, err)
+	}
+	return c, nil
+}
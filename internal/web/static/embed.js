@@ -0,0 +1,46 @@
+// Tells the page embedding the player what it's doing, as messages like
+// { source: "fsb", event: "play", messageId: 42, currentTime: 0, duration: 120 }
+(function () {
+  var video = document.getElementById("player");
+  if (window.parent === window) {
+    return;
+  }
+  function post(event) {
+    window.parent.postMessage({
+      source: "fsb",
+      event: event,
+      messageId: Number(video.dataset.messageId),
+      currentTime: video.currentTime,
+      duration: isFinite(video.duration) ? video.duration : null
+    }, "*");
+  }
+  ["play", "pause", "ended", "seeked", "error"].forEach(function (event) {
+    video.addEventListener(event, function () { post(event); });
+  });
+  video.addEventListener("loadedmetadata", function () { post("ready"); });
+  var lastProgress = 0;
+  video.addEventListener("timeupdate", function () {
+    if (Math.abs(video.currentTime - lastProgress) >= 5) {
+      lastProgress = video.currentTime;
+      post("progress");
+    }
+  });
+  // the embedding page can control the player with { source: "fsb", command: "play" | "pause" | "seek", time: 10 }
+  window.addEventListener("message", function (message) {
+    var data = message.data;
+    if (message.source !== window.parent || !data || data.source !== "fsb") {
+      return;
+    }
+    switch (data.command) {
+      case "play":
+        video.play();
+        break;
+      case "pause":
+        video.pause();
+        break;
+      case "seek":
+        video.currentTime = Number(data.time) || 0;
+        break;
+    }
+  });
+})();
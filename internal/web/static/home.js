@@ -0,0 +1,119 @@
+(function () {
+  var list = document.getElementById("recent");
+  var main = document.querySelector("main");
+  var webApp = window.Telegram && Telegram.WebApp;
+
+  function show(items) {
+    list.textContent = "";
+    document.getElementById("empty").hidden = items.length > 0;
+    items.forEach(function (item) {
+      var entry = document.createElement("li");
+      var link = document.createElement("a");
+      link.href = item.url;
+      link.textContent = item.name;
+      entry.appendChild(link);
+      list.appendChild(entry);
+    });
+  }
+
+  function item(file) {
+    var entry = document.createElement("li");
+    var link = document.createElement("a");
+    link.href = file.url;
+    link.textContent = file.file_name;
+    entry.appendChild(link);
+    return entry;
+  }
+
+  // collections come before the ones nested in them, which are listed inside their parent
+  function showCollections(collections) {
+    var tree = document.getElementById("collection-tree");
+    var lists = {};
+    tree.textContent = "";
+    document.getElementById("collections").hidden = collections.length === 0;
+    collections.forEach(function (collection) {
+      var entry = document.createElement("li");
+      var details = document.createElement("details");
+      var summary = document.createElement("summary");
+      var list = document.createElement("ul");
+      summary.textContent = collection.name.split("/").pop() + " (" + collection.items.length + ")";
+      details.appendChild(summary);
+      details.appendChild(list);
+      entry.appendChild(details);
+      (lists[collection.parent] || tree).appendChild(entry);
+      lists[collection.name] = list;
+      collection.items.forEach(function (file) {
+        list.appendChild(item(file));
+      });
+    });
+  }
+
+  // the categories of the recent links filter the list, the selected one again shows all of them
+  function showCategories(items) {
+    var nav = document.getElementById("categories");
+    var names = [];
+    items.forEach(function (item) {
+      if (item.category && names.indexOf(item.category) < 0) {
+        names.push(item.category);
+      }
+    });
+    nav.hidden = names.length < 2;
+    names.forEach(function (name) {
+      var button = document.createElement("button");
+      button.type = "button";
+      button.textContent = name;
+      button.addEventListener("click", function () {
+        var selected = button.getAttribute("aria-pressed") !== "true";
+        nav.querySelectorAll("button").forEach(function (other) {
+          other.setAttribute("aria-pressed", "false");
+        });
+        button.setAttribute("aria-pressed", String(selected));
+        loadLinks(selected ? name : "");
+      });
+      nav.appendChild(button);
+    });
+  }
+
+  function loadLinks(category) {
+    var query = category ? "?category=" + encodeURIComponent(category) : "";
+    return fetch(main.dataset.scope + "webapp/links" + query, { credentials: "include" }).then(function (response) {
+      return response.json();
+    }).then(function (items) {
+      show(items.map(function (item) {
+        return { name: item.file_name, url: item.url };
+      }));
+      return items;
+    });
+  }
+
+  var recent = [];
+  try {
+    recent = JSON.parse(localStorage.getItem("recent") || "[]");
+  } catch (e) {}
+  show(recent);
+
+  // opened from the menu button of the bot, sign in with the init data and list the links of the user
+  if (webApp && webApp.initData) {
+    webApp.ready();
+    webApp.expand();
+    fetch(main.dataset.scope + "webapp/login", {
+      method: "POST",
+      credentials: "include",
+      body: new URLSearchParams({ init_data: webApp.initData })
+    }).then(function (response) {
+      if (!response.ok) {
+        throw new Error(response.statusText);
+      }
+      return loadLinks("");
+    }).then(function (items) {
+      showCategories(items);
+      return fetch(main.dataset.scope + "webapp/collections", { credentials: "include" });
+    }).then(function (response) {
+      return response.json();
+    }).then(showCollections).catch(function () {});
+  }
+
+  if ("serviceWorker" in navigator && main.dataset.serviceWorker) {
+    navigator.serviceWorker.register(main.dataset.serviceWorker, { scope: main.dataset.scope });
+  }
+})();
@@ -0,0 +1,172 @@
+(function () {
+  var video = document.getElementById("player");
+  var socketURL = (location.protocol === "https:" ? "wss://" : "ws://") + location.host + video.dataset.socketUrl;
+  var socket = null;
+  var watched = 0, lastTime = null;
+  var queue = [];
+
+  function formatTime(seconds) {
+    var h = Math.floor(seconds / 3600), m = Math.floor(seconds / 60) % 60, s = Math.floor(seconds % 60);
+    return (h ? h + ":" + String(m).padStart(2, "0") : m) + ":" + String(s).padStart(2, "0");
+  }
+
+  function send(type) {
+    if (socket && socket.readyState === WebSocket.OPEN) {
+      socket.send(JSON.stringify({ type: type, position: video.currentTime, watched: watched }));
+    }
+  }
+
+  function useHLS(url) {
+    var position = video.currentTime;
+    if (video.canPlayType("application/vnd.apple.mpegurl")) {
+      video.src = url;
+    } else if (window.Hls && Hls.isSupported()) {
+      var hls = new Hls();
+      hls.loadSource(url);
+      hls.attachMedia(video);
+    } else {
+      return;
+    }
+    video.currentTime = position;
+  }
+
+  function showChapters(chapters) {
+    var list = document.getElementById("chapters");
+    (chapters || []).forEach(function (chapter) {
+      var item = document.createElement("li");
+      var time = document.createElement("span");
+      time.textContent = formatTime(chapter.start);
+      item.appendChild(time);
+      item.appendChild(document.createTextNode(chapter.title));
+      item.onclick = function () { video.currentTime = chapter.start; video.play(); };
+      list.appendChild(item);
+    });
+  }
+
+  function showQueue(items) {
+    queue = items;
+    var list = document.getElementById("queue");
+    list.textContent = "";
+    document.getElementById("queue-title").hidden = items.length < 2;
+    if (items.length < 2) {
+      return;
+    }
+    items.forEach(function (item) {
+      var entry = document.createElement("li");
+      var link = document.createElement("a");
+      link.href = item.url;
+      link.textContent = item.file_name;
+      entry.className = item.current ? "current" : "";
+      entry.appendChild(link);
+      list.appendChild(entry);
+    });
+  }
+
+  // control runs the remote control actions sent from the chat with /remote
+  function control(action) {
+    switch (action) {
+      case "play":
+        video.play();
+        break;
+      case "pause":
+        video.pause();
+        break;
+      case "toggle":
+        if (video.paused) { video.play(); } else { video.pause(); }
+        break;
+      case "back":
+        video.currentTime = Math.max(video.currentTime - 30, 0);
+        break;
+      case "forward":
+        video.currentTime = Math.min(video.currentTime + 30, video.duration || Infinity);
+        break;
+      case "volume_down":
+        video.volume = Math.max(Math.round(video.volume * 10 - 1) / 10, 0);
+        break;
+      case "volume_up":
+        video.muted = false;
+        video.volume = Math.min(Math.round(video.volume * 10 + 1) / 10, 1);
+        break;
+      case "next":
+        for (var i = 0; i < queue.length - 1; i++) {
+          if (queue[i].current) {
+            send("progress");
+            location.href = queue[i + 1].url;
+            return;
+          }
+        }
+        break;
+      case "latest":
+        if (queue.length && !queue[0].current) {
+          send("progress");
+          location.href = queue[0].url;
+        }
+        break;
+    }
+  }
+
+  function connect() {
+    socket = new WebSocket(socketURL);
+    socket.onmessage = function (event) {
+      var message = JSON.parse(event.data);
+      if (message.type === "queue") {
+        showQueue(message.items || []);
+        return;
+      }
+      if (message.type === "control") {
+        control(message.action);
+        return;
+      }
+      if (message.type !== "info") {
+        return;
+      }
+      showChapters(message.chapters);
+      if (message.hls_url && message.direct_play === false) {
+        useHLS(message.hls_url);
+      }
+    };
+  }
+
+  video.addEventListener("timeupdate", function () {
+    var now = Date.now();
+    if (lastTime !== null && !video.paused && !video.seeking) {
+      watched += Math.min((now - lastTime) / 1000, 1);
+    }
+    lastTime = now;
+  });
+  video.addEventListener("play", function () { send("play"); });
+  video.addEventListener("pause", function () { send("pause"); });
+  video.addEventListener("waiting", function () { if (!video.seeking) { send("stall"); } });
+  video.addEventListener("seeking", function () { send("seek"); });
+  setInterval(function () { if (!video.paused) { send("progress"); } }, 30000);
+  window.addEventListener("pagehide", function () { send("progress"); });
+  function remember() {
+    try {
+      var recent = JSON.parse(localStorage.getItem("recent") || "[]").filter(function (item) {
+        return item.url !== location.href;
+      });
+      recent.unshift({ name: document.title, url: location.href, time: Date.now() });
+      localStorage.setItem("recent", JSON.stringify(recent.slice(0, 20)));
+    } catch (e) {}
+  }
+
+  // opened as a Mini App, the back button of Telegram goes back to the previous file of the queue
+  function useWebApp(webApp) {
+    webApp.ready();
+    webApp.expand();
+    webApp.setHeaderColor("bg_color");
+    if (history.length > 1) {
+      webApp.BackButton.onClick(function () { history.back(); });
+      webApp.BackButton.show();
+    }
+  }
+
+  if ("serviceWorker" in navigator && video.dataset.serviceWorker) {
+    navigator.serviceWorker.register(video.dataset.serviceWorker, { scope: video.dataset.scope });
+  }
+  if (window.Telegram && Telegram.WebApp && Telegram.WebApp.initData) {
+    useWebApp(Telegram.WebApp);
+  }
+  remember();
+  connect();
+})();
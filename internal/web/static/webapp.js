@@ -0,0 +1,22 @@
+(function () {
+  var main = document.getElementById("webapp");
+  var webApp = window.Telegram && Telegram.WebApp;
+  if (!webApp || !webApp.initData) {
+    location.replace(main.dataset.next);
+    return;
+  }
+  webApp.ready();
+  webApp.expand();
+  fetch(main.dataset.login, {
+    method: "POST",
+    credentials: "include",
+    body: new URLSearchParams({ init_data: webApp.initData })
+  }).then(function (response) {
+    if (!response.ok) {
+      return response.text().then(function (text) { throw new Error(text); });
+    }
+    location.replace(main.dataset.next);
+  }).catch(function (error) {
+    document.getElementById("status").textContent = "Couldn't sign in: " + error.message;
+  });
+})();
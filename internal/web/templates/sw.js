@@ -0,0 +1,43 @@
+// The service worker caches the app shell, so the installed app opens offline.
+// Streams are never cached.
+var CACHE = "fsb-{{.Version}}";
+var HOME = "{{.Home}}";
+var SHELL = [HOME{{range .Assets}}, "{{.}}"{{end}}];
+
+self.addEventListener("install", function (event) {
+  event.waitUntil(caches.open(CACHE).then(function (cache) {
+    return cache.addAll(SHELL);
+  }).then(function () {
+    return self.skipWaiting();
+  }));
+});
+
+self.addEventListener("activate", function (event) {
+  event.waitUntil(caches.keys().then(function (keys) {
+    return Promise.all(keys.filter(function (key) {
+      return key !== CACHE;
+    }).map(function (key) {
+      return caches.delete(key);
+    }));
+  }).then(function () {
+    return self.clients.claim();
+  }));
+});
+
+self.addEventListener("fetch", function (event) {
+  var request = event.request;
+  if (request.method !== "GET") {
+    return;
+  }
+  if (request.mode === "navigate") {
+    event.respondWith(fetch(request).catch(function () {
+      return caches.match(HOME);
+    }));
+    return;
+  }
+  if (SHELL.indexOf(new URL(request.url).pathname) !== -1) {
+    event.respondWith(caches.match(request).then(function (cached) {
+      return cached || fetch(request);
+    }));
+  }
+});
@@ -0,0 +1,380 @@
+// Package web contains the pages and static assets served to browsers, like the web player.
+package web
+
+import (
+	"EverythingSuckz/fsb/config"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	textTemplate "text/template"
+
+	"go.uber.org/zap"
+)
+
+//go:embed templates static
+var embedded embed.FS
+
+// Asset is a static file served with a content hashed name
+type Asset struct {
+	Name        string // content hashed name, eg. player.3f2a1b9c.js
+	ContentType string
+	Content     []byte
+}
+
+var (
+	// assets maps the hashed names to the assets
+	assets = make(map[string]*Asset)
+	// hashedNames maps the original names to the hashed names
+	hashedNames = make(map[string]string)
+)
+
+// Templates, they are set by Load
+var (
+	// Player renders the web player page of a file
+	Player *template.Template
+	// Landing renders the page shown instead of the media when a link is opened
+	// in a browser or by a link preview crawler
+	Landing *template.Template
+	// Home renders the start page of the installed app, listing recently played files
+	Home *template.Template
+	// Index renders the public list of recently shared files of PUBLIC_INDEX
+	Index *template.Template
+	// Gone renders the reason a link doesn't work anymore for browsers
+	Gone *template.Template
+	// WebApp renders the page Mini App buttons open, which signs in with the init data
+	// of Telegram before opening the page it was given
+	WebApp *template.Template
+	// OpenIn renders the links that open a stream in native players
+	OpenIn *template.Template
+	// Embed renders the player without anything around it, for other sites to put in an iframe
+	Embed *template.Template
+	// Collection renders a shared collection and the collections nested in it
+	Collection *template.Template
+	// Dashboard renders the admin dashboard, or its sign in form
+	Dashboard *template.Template
+	// ServiceWorker renders the service worker caching the app shell
+	ServiceWorker *textTemplate.Template
+)
+
+// LandingData is passed to the Landing template
+type LandingData struct {
+	FileName     string
+	Title        string // the title found on TMDB or MusicBrainz, the file name otherwise
+	Description  string
+	FileSize     string
+	MimeType     string
+	Kind         string // video, audio or image
+	URL          string // the link itself, serving the media to non-browser clients
+	DownloadURL  string
+	PlayerURL    string
+	ThumbnailURL string
+	OEmbedURL    string
+	Duration     int // seconds, 0 if unknown
+	Width        int
+	Height       int
+}
+
+// App holds the branding of the installable web app
+type App struct {
+	Name            string
+	ShortName       string
+	ThemeColor      string
+	BackgroundColor string
+}
+
+// GetApp returns the branding of the web app from the config
+func GetApp() App {
+	return App{
+		Name:            config.ValueOf.AppName,
+		ShortName:       config.ValueOf.AppShortName,
+		ThemeColor:      config.ValueOf.AppThemeColor,
+		BackgroundColor: config.ValueOf.AppBackgroundColor,
+	}
+}
+
+// ServiceWorkerData is passed to the ServiceWorker template
+type ServiceWorkerData struct {
+	Version string   // changes whenever an asset changes, so the old cache is dropped
+	Home    string   // path of the home page, served when offline
+	Assets  []string // paths of the cached assets
+}
+
+// IndexItem is a file listed by the Index template
+type IndexItem struct {
+	FileName  string
+	FileSize  string
+	Kind      string // video, audio, image or empty for other files
+	URL       string // the player for videos and audio, the link itself otherwise
+	CreatedAt string
+}
+
+// CollectionData is passed to the Collection template
+type CollectionData struct {
+	Name     string
+	Sections []CollectionSection // the collection first, then the ones nested in it
+}
+
+// CollectionSection lists the files of a collection
+type CollectionSection struct {
+	Name  string // relative to the shared collection, empty for the shared collection itself
+	Items []IndexItem
+}
+
+// PlayerData is passed to the Player template
+type PlayerData struct {
+	FileName  string
+	Title     string // the title found on TMDB or MusicBrainz, the file name otherwise
+	Overview  string
+	PosterURL string
+	MimeType  string
+	StreamURL string
+	SocketURL string // path of the websocket endpoint, the host is taken from the page location
+	OpenIn    []OpenInLink
+}
+
+// OpenInLink opens the stream in a native player. Its URL has the scheme of the player, which
+// templates would filter out of a string.
+type OpenInLink struct {
+	Name string
+	URL  template.URL
+}
+
+// OpenInData is passed to the OpenIn template
+type OpenInData struct {
+	FileName  string
+	StreamURL string
+	Links     []OpenInLink
+}
+
+// EmbedData is passed to the Embed template
+type EmbedData struct {
+	FileName  string
+	MessageID int
+	StreamURL string
+}
+
+// DashboardData is passed to the Dashboard template
+type DashboardData struct {
+	Admin   string // the signed in admin, empty on the sign in form
+	Error   string
+	Message string // the result of the last action
+	Query   string // the search of the user list
+	Users   []DashboardUser
+	Total   int64          // the users matching the search
+	User    *DashboardUser // the user whose links are listed
+	Links   []DashboardLink
+	Disks   []DashboardDisk
+	Paused  bool // saving files is paused because SAVE_DIR is low on space
+	Jobs    []DashboardJobCount
+	Dead    []DashboardJob // the jobs that failed every attempt
+}
+
+// DashboardJobCount is the number of background jobs of a kind in a state
+type DashboardJobCount struct {
+	Kind  string
+	State string
+	Count int64
+}
+
+// DashboardJob is a dead background job listed on the dashboard
+type DashboardJob struct {
+	ID         uint
+	Kind       string
+	Attempts   int
+	Error      string
+	FinishedAt string
+}
+
+// DashboardUser is a user listed on the dashboard
+type DashboardUser struct {
+	ID        int64
+	Name      string
+	Joined    string
+	LastSeen  string
+	Suspended bool
+}
+
+// DashboardDisk is the space of the disk of a watched directory
+type DashboardDisk struct {
+	Name  string
+	Path  string
+	Free  string
+	Total string
+	Low   bool
+}
+
+// DashboardLink is a link listed on the dashboard
+type DashboardLink struct {
+	TenantID  uint
+	MessageID int
+	FileName  string
+	FileSize  string
+	CreatedAt string
+	URL       string
+}
+
+// Load reads the embedded templates and assets. Files with the same name in
+// WEB_OVERRIDE_DIR (eg. static/player.css) replace the embedded ones.
+func Load(log *zap.Logger) error {
+	log = log.Named("web")
+	defer log.Info("Loaded web assets")
+	static, err := fs.ReadDir(embedded, "static")
+	if err != nil {
+		return err
+	}
+	for _, entry := range static {
+		content, err := readFile(log, "static/"+entry.Name())
+		if err != nil {
+			return err
+		}
+		addAsset(entry.Name(), content)
+	}
+	if err := loadIcons(log); err != nil {
+		return err
+	}
+	funcs := template.FuncMap{
+		"asset": AssetURL,
+		"base":  func() string { return config.ValueOf.BasePath },
+		"app":   GetApp,
+	}
+	if Player, err = parseTemplate(log, "player", funcs); err != nil {
+		return err
+	}
+	if Landing, err = parseTemplate(log, "landing", funcs); err != nil {
+		return err
+	}
+	if Home, err = parseTemplate(log, "home", funcs); err != nil {
+		return err
+	}
+	if Index, err = parseTemplate(log, "index", funcs); err != nil {
+		return err
+	}
+	if Gone, err = parseTemplate(log, "gone", funcs); err != nil {
+		return err
+	}
+	if WebApp, err = parseTemplate(log, "webapp", funcs); err != nil {
+		return err
+	}
+	if Embed, err = parseTemplate(log, "embed", funcs); err != nil {
+		return err
+	}
+	if OpenIn, err = parseTemplate(log, "openin", funcs); err != nil {
+		return err
+	}
+	if Collection, err = parseTemplate(log, "collection", funcs); err != nil {
+		return err
+	}
+	if Dashboard, err = parseTemplate(log, "dashboard", funcs); err != nil {
+		return err
+	}
+	sw, err := readFile(log, "templates/sw.js")
+	if err != nil {
+		return err
+	}
+	ServiceWorker, err = textTemplate.New("sw").Parse(string(sw))
+	return err
+}
+
+// parseTemplate parses templates/<name>.html
+func parseTemplate(log *zap.Logger, name string, funcs template.FuncMap) (*template.Template, error) {
+	content, err := readFile(log, "templates/"+name+".html")
+	if err != nil {
+		return nil, err
+	}
+	return template.New(name).Funcs(funcs).Parse(string(content))
+}
+
+// loadIcons adds the app icons, drawn in the theme color unless they're overridden
+func loadIcons(log *zap.Logger) error {
+	background, err := parseHexColor(config.ValueOf.AppThemeColor)
+	if err != nil {
+		return err
+	}
+	for _, size := range iconSizes {
+		name := fmt.Sprintf("icon-%d.png", size)
+		content, err := readFile(log, "static/"+name)
+		if errors.Is(err, fs.ErrNotExist) {
+			content, err = drawIcon(size, background)
+		}
+		if err != nil {
+			return err
+		}
+		addAsset(name, content)
+	}
+	return nil
+}
+
+// readFile reads a file from WEB_OVERRIDE_DIR if it exists there, or from the embedded files
+func readFile(log *zap.Logger, name string) ([]byte, error) {
+	if dir := config.ValueOf.WebOverrideDir; dir != "" {
+		content, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err == nil {
+			log.Info("Using overridden web file", zap.String("name", name))
+			return content, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	return embedded.ReadFile(name)
+}
+
+func addAsset(name string, content []byte) {
+	sum := sha256.Sum256(content)
+	ext := path.Ext(name)
+	hashed := strings.TrimSuffix(name, ext) + "." + hex.EncodeToString(sum[:4]) + ext
+	contentType := mime.TypeByExtension(ext)
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	assets[hashed] = &Asset{Name: hashed, ContentType: contentType, Content: content}
+	hashedNames[name] = hashed
+}
+
+// AssetURL returns the path of the static asset with the given original name
+func AssetURL(name string) string {
+	if hashed, ok := hashedNames[name]; ok {
+		name = hashed
+	}
+	return config.ValueOf.BasePath + "/static/" + name
+}
+
+// IconURLs returns the paths of the app icons by their size
+func IconURLs() map[int]string {
+	icons := make(map[int]string, len(iconSizes))
+	for _, size := range iconSizes {
+		icons[size] = AssetURL(fmt.Sprintf("icon-%d.png", size))
+	}
+	return icons
+}
+
+// ServiceWorkerInfo returns the data of the service worker template
+func ServiceWorkerInfo() ServiceWorkerData {
+	data := ServiceWorkerData{Home: config.ValueOf.BasePath + "/app"}
+	hash := sha256.New()
+	for name := range hashedNames {
+		data.Assets = append(data.Assets, AssetURL(name))
+	}
+	sort.Strings(data.Assets)
+	for _, asset := range data.Assets {
+		hash.Write([]byte(asset))
+	}
+	data.Version = hex.EncodeToString(hash.Sum(nil)[:4])
+	return data
+}
+
+// GetAsset returns the asset with the given hashed name
+func GetAsset(name string) (*Asset, bool) {
+	asset, ok := assets[name]
+	return asset, ok
+}
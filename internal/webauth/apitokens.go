@@ -0,0 +1,91 @@
+package webauth
+
+import (
+	"EverythingSuckz/fsb/internal/crypt"
+	"EverythingSuckz/fsb/internal/database"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// APITokenPrefix starts the personal access tokens of /apitoken, so that they're told apart
+// from the other tokens and found by secret scanners
+const APITokenPrefix = "fsb_"
+
+// The scopes of API tokens
+const (
+	// ScopeHistory lists the links of the user, like /history, playlists and the Kodi API
+	ScopeHistory = "read-history"
+	// ScopeStream opens the private links of the user and the ones shared with them
+	ScopeStream = "stream"
+	// ScopeUpload generates links for files uploaded to /api/upload
+	ScopeUpload = "upload"
+)
+
+// Scopes are the scopes API tokens can be created with
+var Scopes = []string{ScopeHistory, ScopeStream, ScopeUpload}
+
+var (
+	ErrInvalidAPIToken = errors.New("invalid API token, create one with /apitoken")
+	ErrMissingScope    = errors.New("the API token doesn't have the scope of this endpoint")
+)
+
+// touchInterval is how often the last use of a token is stored
+const touchInterval = time.Minute
+
+// NewAPIToken returns a new random token and the digest it's stored by
+func NewAPIToken() (token string, digest string, err error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+	token = APITokenPrefix + base64.RawURLEncoding.EncodeToString(secret)
+	return token, crypt.Digest(token), nil
+}
+
+// BearerToken returns the API token of the request, from the Authorization header or the
+// api_token query param for clients that can't set headers, like players
+func BearerToken(r *http.Request) string {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && strings.HasPrefix(token, APITokenPrefix) {
+		return token
+	}
+	return r.URL.Query().Get("api_token")
+}
+
+// APITokenUser returns the user of the API token if it has the scope
+func APITokenUser(token string, scope string) (int64, error) {
+	apiTokenRepository := database.GetAPITokenRepository()
+	if apiTokenRepository == nil || !strings.HasPrefix(token, APITokenPrefix) {
+		return 0, ErrInvalidAPIToken
+	}
+	stored, err := apiTokenRepository.FindByDigest(crypt.Digest(token))
+	if err != nil {
+		return 0, err
+	}
+	if stored == nil {
+		return 0, ErrInvalidAPIToken
+	}
+	if !stored.HasScope(scope) {
+		return 0, ErrMissingScope
+	}
+	if now := time.Now(); stored.LastUsedAt == nil || now.Sub(*stored.LastUsedAt) > touchInterval {
+		apiTokenRepository.Touch(stored.ID, now)
+	}
+	return stored.UserID, nil
+}
+
+// RequestUser returns the user signed in to the browser of the request, or the user of its
+// API token if it has the scope
+func RequestUser(r *http.Request, scope string) (int64, bool) {
+	if userID, ok := UserID(r); ok {
+		return userID, true
+	}
+	if token := BearerToken(r); token != "" {
+		userID, err := APITokenUser(token, scope)
+		return userID, err == nil
+	}
+	return 0, false
+}
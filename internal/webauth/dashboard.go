@@ -0,0 +1,140 @@
+package webauth
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/database"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// DashboardCookieName is the cookie that identifies the admin signed in to the dashboard
+	DashboardCookieName = "fsb_dashboard"
+	// DashboardTTL is how long an admin stays signed in to the dashboard
+	DashboardTTL = 12 * time.Hour
+)
+
+const purposeDashboard = "dashboard"
+
+// dummyHash is compared against for unknown usernames, so that they take as long as wrong passwords
+var dummyHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("fsb-dashboard"), bcrypt.DefaultCost)
+	return hash
+})
+
+// HashPassword returns the bcrypt hash of a dashboard password
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	return string(hash), err
+}
+
+// accountHash returns the password hash of the dashboard account, from DASHBOARD_ADMINS or the
+// accounts of `fsb dashboard-admin`, or an empty string if there's none
+func accountHash(username string) (string, error) {
+	for _, entry := range config.ValueOf.DashboardAdmins {
+		if name, hash, _ := strings.Cut(strings.TrimSpace(entry), ":"); name == username {
+			return hash, nil
+		}
+	}
+	dashboardRepository := database.GetDashboardRepository()
+	if dashboardRepository == nil {
+		return "", nil
+	}
+	account, err := dashboardRepository.Get(username)
+	if err != nil || account == nil {
+		return "", err
+	}
+	return account.PasswordHash, nil
+}
+
+// CheckDashboardPassword reports whether the password is the one of the dashboard account
+func CheckDashboardPassword(username string, password string) (bool, error) {
+	hash, err := accountHash(username)
+	if err != nil {
+		return false, err
+	}
+	if hash == "" {
+		bcrypt.CompareHashAndPassword(dummyHash(), []byte(password))
+		return false, nil
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+		return false, nil
+	}
+	if dashboardRepository := database.GetDashboardRepository(); dashboardRepository != nil {
+		dashboardRepository.Touch(username, time.Now())
+	}
+	return true, nil
+}
+
+// StartDashboardSession stores the dashboard cookie of the account. It's only sent by pages of
+// this server, so that other sites can't submit the forms of the dashboard.
+func StartDashboardSession(w http.ResponseWriter, username string) error {
+	hash, err := accountHash(username)
+	if err != nil {
+		return err
+	}
+	expires := time.Now().Add(DashboardTTL)
+	payload := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%s:%d:%s:%s", purposeDashboard, expires.Unix(), fingerprint(hash), username)))
+	http.SetCookie(w, &http.Cookie{
+		Name:     DashboardCookieName,
+		Value:    payload + "." + signature(payload),
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(config.Runtime.Host(), "https://"),
+		SameSite: http.SameSiteStrictMode,
+	})
+	return nil
+}
+
+// EndDashboardSession signs the browser out of the dashboard
+func EndDashboardSession(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{Name: DashboardCookieName, Value: "", Path: "/", MaxAge: -1})
+}
+
+// DashboardUser returns the dashboard account signed in to the browser of the request. Changing
+// the password of the account or removing it signs it out.
+func DashboardUser(r *http.Request) (string, bool) {
+	cookie, err := r.Cookie(DashboardCookieName)
+	if err != nil {
+		return "", false
+	}
+	payload, sig, ok := strings.Cut(cookie.Value, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(signature(payload))) {
+		return "", false
+	}
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return "", false
+	}
+	fields := strings.SplitN(string(data), ":", 4)
+	if len(fields) != 4 || fields[0] != purposeDashboard {
+		return "", false
+	}
+	expires, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return "", false
+	}
+	username := fields[3]
+	hash, err := accountHash(username)
+	if err != nil || hash == "" || fingerprint(hash) != fields[2] {
+		return "", false
+	}
+	return username, true
+}
+
+// fingerprint identifies a password hash without revealing it
+func fingerprint(hash string) string {
+	sum := sha256.Sum256([]byte(hash))
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,78 @@
+package webauth
+
+import (
+	"EverythingSuckz/fsb/config"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// InitDataTTL is how long the init data Telegram passes to the web app can be used to sign in
+const InitDataTTL = 24 * time.Hour
+
+var (
+	ErrInvalidInitData = errors.New("invalid init data")
+	ErrExpiredInitData = errors.New("init data has expired")
+)
+
+// ValidateInitData checks the signature Telegram added to the init data of the web app,
+// see https://core.telegram.org/bots/webapps#validating-data-received-via-the-mini-app.
+// It returns the ID of the user who opened the web app.
+func ValidateInitData(initData string) (int64, error) {
+	values, err := url.ParseQuery(initData)
+	if err != nil {
+		return 0, ErrInvalidInitData
+	}
+	hash := values.Get("hash")
+	if hash == "" {
+		return 0, ErrInvalidInitData
+	}
+	pairs := make([]string, 0, len(values))
+	for key := range values {
+		if key != "hash" {
+			pairs = append(pairs, key+"="+values.Get(key))
+		}
+	}
+	sort.Strings(pairs)
+	secret := hmac.New(sha256.New, []byte("WebAppData"))
+	secret.Write([]byte(config.ValueOf.BotToken))
+	mac := hmac.New(sha256.New, secret.Sum(nil))
+	mac.Write([]byte(strings.Join(pairs, "\n")))
+	expected, err := hex.DecodeString(hash)
+	if err != nil || !hmac.Equal(expected, mac.Sum(nil)) {
+		return 0, ErrInvalidInitData
+	}
+	authDate, err := strconv.ParseInt(values.Get("auth_date"), 10, 64)
+	if err != nil {
+		return 0, ErrInvalidInitData
+	}
+	if time.Since(time.Unix(authDate, 0)) > InitDataTTL {
+		return 0, ErrExpiredInitData
+	}
+	var user struct {
+		ID int64 `json:"id"`
+	}
+	if err := json.Unmarshal([]byte(values.Get("user")), &user); err != nil || user.ID == 0 {
+		return 0, ErrInvalidInitData
+	}
+	return user.ID, nil
+}
+
+// StartWebAppSession stores the session cookie of the user whose init data was validated.
+// The cookie can be sent from the frame Telegram Web opens the web app in, when the server
+// uses https.
+func StartWebAppSession(w http.ResponseWriter, userID int64) {
+	sameSite := http.SameSiteLaxMode
+	if strings.HasPrefix(config.Runtime.Host(), "https://") {
+		sameSite = http.SameSiteNoneMode
+	}
+	startSession(w, userID, sameSite)
+}
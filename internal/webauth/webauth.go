@@ -0,0 +1,213 @@
+// Package webauth identifies the Telegram users behind web requests, so that private links
+// only play for the users they were shared with. Users sign in by opening a login link the
+// bot sends with /weblogin, which stores a signed cookie in their browser, or by opening the
+// web app from the menu button of the bot, which Telegram signs the init data of.
+package webauth
+
+import (
+	"EverythingSuckz/fsb/config"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// CookieName is the cookie that identifies the signed in user
+	CookieName = "fsb_user"
+	// LoginTTL is how long a login link can be opened
+	LoginTTL = 10 * time.Minute
+	// SessionTTL is how long a browser stays signed in
+	SessionTTL = 30 * 24 * time.Hour
+	// WebDAVTTL is how long a WebDAV password of /webdav works
+	WebDAVTTL = 365 * 24 * time.Hour
+)
+
+// purposes keep login tokens from being used as session cookies and the other way around
+const (
+	purposeLogin   = "login"
+	purposeSession = "session"
+	purposeWebDAV  = "webdav"
+	purposeFeed    = "feed"
+	purposeKodi    = "kodi"
+	purposeSSO     = "sso"
+)
+
+// LoginToken returns a token that signs the user in when the login route gets it within LoginTTL
+func LoginToken(userID int64) string {
+	return sign(purposeLogin, userID, time.Now().Add(LoginTTL))
+}
+
+// Login checks the login token and stores the session cookie of its user. It returns the user ID.
+func Login(w http.ResponseWriter, r *http.Request, token string) (int64, bool) {
+	userID, ok := verify(purposeLogin, token)
+	if !ok {
+		return 0, false
+	}
+	startSession(w, userID, http.SameSiteLaxMode)
+	return userID, true
+}
+
+// WebDAVPassword returns a password that signs WebDAV clients in as the user within WebDAVTTL.
+// The version is the token version of the user, the password stops working when it's bumped.
+func WebDAVPassword(userID int64, version int) string {
+	return sign(versioned(purposeWebDAV, version), userID, time.Now().Add(WebDAVTTL))
+}
+
+// WebDAVUser checks the basic auth credentials of a WebDAV request, the user ID and a
+// password of WebDAVPassword. It returns the user ID and the token version of the password.
+func WebDAVUser(r *http.Request) (int64, int, bool) {
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		return 0, 0, false
+	}
+	userID, version, ok := verifyVersioned(purposeWebDAV, password)
+	if !ok || strconv.FormatInt(userID, 10) != username {
+		return 0, 0, false
+	}
+	return userID, version, true
+}
+
+// FeedToken returns the token in the URL of the podcast feed of the user. Podcast apps keep
+// the URL, so it doesn't expire, bumping the token version of the user revokes it.
+func FeedToken(userID int64, version int) string {
+	return sign(versioned(purposeFeed, version), userID, time.Unix(0, 0))
+}
+
+// FeedUser returns the user and the token version of a token of FeedToken
+func FeedUser(token string) (int64, int, bool) {
+	return verifyVersioned(purposeFeed, token)
+}
+
+// KodiToken returns the token the Kodi add-on signs in to the API as the user with. Like
+// feed tokens it doesn't expire, bumping the token version of the user revokes it.
+func KodiToken(userID int64, version int) string {
+	return sign(versioned(purposeKodi, version), userID, time.Unix(0, 0))
+}
+
+// KodiUser returns the user and the token version of a token of KodiToken
+func KodiUser(token string) (int64, int, bool) {
+	return verifyVersioned(purposeKodi, token)
+}
+
+// SSOLinkToken returns a token that links the account of the OIDC provider the user signs in
+// with to them, when it's used within LoginTTL
+func SSOLinkToken(userID int64) string {
+	return sign(purposeSSO, userID, time.Now().Add(LoginTTL))
+}
+
+// SSOLinkUser returns the user of a token of SSOLinkToken
+func SSOLinkUser(token string) (int64, bool) {
+	return verify(purposeSSO, token)
+}
+
+// StartSession stores the session cookie of the user who signed in some other way than a
+// login link, like with the OIDC provider
+func StartSession(w http.ResponseWriter, userID int64) {
+	startSession(w, userID, http.SameSiteLaxMode)
+}
+
+// startSession stores the session cookie of the user
+func startSession(w http.ResponseWriter, userID int64, sameSite http.SameSite) {
+	expires := time.Now().Add(SessionTTL)
+	http.SetCookie(w, &http.Cookie{
+		Name:     CookieName,
+		Value:    sign(purposeSession, userID, expires),
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(config.Runtime.Host(), "https://"),
+		SameSite: sameSite,
+	})
+}
+
+// UserID returns the signed in user of the request
+func UserID(r *http.Request) (int64, bool) {
+	cookie, err := r.Cookie(CookieName)
+	if err != nil {
+		return 0, false
+	}
+	return verify(purposeSession, cookie.Value)
+}
+
+// sign returns <payload>.<signature>, the payload holds the purpose, the user ID and the expiry
+func sign(purpose string, userID int64, expires time.Time) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%s:%d:%d", purpose, userID, expires.Unix())))
+	return payload + "." + signature(payload)
+}
+
+func verify(purpose string, token string) (int64, bool) {
+	signed, userID, ok := open(token)
+	if !ok || signed != purpose {
+		return 0, false
+	}
+	return userID, true
+}
+
+// versioned is the purpose of a token that's revoked by bumping the token version of the user.
+// Version 0 is the purpose alone, tokens signed before versions existed are version 0.
+func versioned(purpose string, version int) string {
+	if version == 0 {
+		return purpose
+	}
+	return purpose + "@" + strconv.Itoa(version)
+}
+
+// verifyVersioned is verify for tokens of versioned, it also returns the version of the token
+func verifyVersioned(purpose string, token string) (int64, int, bool) {
+	signed, userID, ok := open(token)
+	if !ok {
+		return 0, 0, false
+	}
+	signed, v, hasVersion := strings.Cut(signed, "@")
+	if signed != purpose {
+		return 0, 0, false
+	}
+	version := 0
+	if hasVersion {
+		var err error
+		if version, err = strconv.Atoi(v); err != nil {
+			return 0, 0, false
+		}
+	}
+	return userID, version, true
+}
+
+// open checks the signature and the expiry of the token and returns its purpose and user ID
+func open(token string) (string, int64, bool) {
+	payload, sig, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(signature(payload))) {
+		return "", 0, false
+	}
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return "", 0, false
+	}
+	fields := strings.Split(string(data), ":")
+	if len(fields) != 3 {
+		return "", 0, false
+	}
+	userID, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return "", 0, false
+	}
+	expires, err := strconv.ParseInt(fields[2], 10, 64)
+	// tokens with the expiry 0 don't expire
+	if err != nil || (expires != 0 && time.Now().Unix() > expires) {
+		return "", 0, false
+	}
+	return fields[0], userID, true
+}
+
+// signature signs the payload with a key derived from the bot token, so changing the
+// bot token signs everyone out
+func signature(payload string) string {
+	key := sha256.Sum256([]byte("fsb-webauth:" + config.ValueOf.BotToken))
+	mac := hmac.New(sha256.New, key[:])
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}